@@ -27,50 +27,74 @@ import (
 
 // App holds all services and manages the application lifecycle.
 type App struct {
-	engine            *gin.Engine
-	configManager     types.ConfigManager
-	settingsManager   *config.SystemSettingsManager
-	groupManager      *services.GroupManager
-	logCleanupService *services.LogCleanupService
-	requestLogService *services.RequestLogService
-	cronChecker       *keypool.CronChecker
-	keyPoolProvider   *keypool.KeyProvider
-	proxyServer       *proxy.ProxyServer
-	storage           store.Store
-	db                *gorm.DB
-	httpServer        *http.Server
+	engine             *gin.Engine
+	configManager      types.ConfigManager
+	settingsManager    *config.SystemSettingsManager
+	groupManager       *services.GroupManager
+	modelPriceService  *services.ModelPriceService
+	proxyKeyService    *services.ProxyKeyService
+	authGuardService   *services.AuthGuardService
+	logCleanupService  *services.LogCleanupService
+	requestLogService  *services.RequestLogService
+	keyRewrapService   *services.KeyRewrapService
+	keyRotationService *services.EncryptionKeyRotationService
+	keySourceService   *services.KeySourceService
+	configService      *services.ConfigService
+	cronChecker        *keypool.CronChecker
+	keyScheduler       *keypool.KeyScheduler
+	keyPoolProvider    *keypool.KeyProvider
+	proxyServer        *proxy.ProxyServer
+	storage            store.Store
+	db                 *gorm.DB
+	httpServer         *http.Server
 }
 
 // AppParams defines the dependencies for the App.
 type AppParams struct {
 	dig.In
-	Engine            *gin.Engine
-	ConfigManager     types.ConfigManager
-	SettingsManager   *config.SystemSettingsManager
-	GroupManager      *services.GroupManager
-	LogCleanupService *services.LogCleanupService
-	RequestLogService *services.RequestLogService
-	CronChecker       *keypool.CronChecker
-	KeyPoolProvider   *keypool.KeyProvider
-	ProxyServer       *proxy.ProxyServer
-	Storage           store.Store
-	DB                *gorm.DB
+	Engine             *gin.Engine
+	ConfigManager      types.ConfigManager
+	SettingsManager    *config.SystemSettingsManager
+	GroupManager       *services.GroupManager
+	ModelPriceService  *services.ModelPriceService
+	ProxyKeyService    *services.ProxyKeyService
+	AuthGuardService   *services.AuthGuardService
+	LogCleanupService  *services.LogCleanupService
+	RequestLogService  *services.RequestLogService
+	KeyRewrapService   *services.KeyRewrapService
+	KeyRotationService *services.EncryptionKeyRotationService
+	KeySourceService   *services.KeySourceService
+	ConfigService      *services.ConfigService
+	CronChecker        *keypool.CronChecker
+	KeyScheduler       *keypool.KeyScheduler
+	KeyPoolProvider    *keypool.KeyProvider
+	ProxyServer        *proxy.ProxyServer
+	Storage            store.Store
+	DB                 *gorm.DB
 }
 
 // NewApp is the constructor for App, with dependencies injected by dig.
 func NewApp(params AppParams) *App {
 	return &App{
-		engine:            params.Engine,
-		configManager:     params.ConfigManager,
-		settingsManager:   params.SettingsManager,
-		groupManager:      params.GroupManager,
-		logCleanupService: params.LogCleanupService,
-		requestLogService: params.RequestLogService,
-		cronChecker:       params.CronChecker,
-		keyPoolProvider:   params.KeyPoolProvider,
-		proxyServer:       params.ProxyServer,
-		storage:           params.Storage,
-		db:                params.DB,
+		engine:             params.Engine,
+		configManager:      params.ConfigManager,
+		settingsManager:    params.SettingsManager,
+		groupManager:       params.GroupManager,
+		modelPriceService:  params.ModelPriceService,
+		proxyKeyService:    params.ProxyKeyService,
+		authGuardService:   params.AuthGuardService,
+		logCleanupService:  params.LogCleanupService,
+		requestLogService:  params.RequestLogService,
+		keyRewrapService:   params.KeyRewrapService,
+		keyRotationService: params.KeyRotationService,
+		keySourceService:   params.KeySourceService,
+		configService:      params.ConfigService,
+		cronChecker:        params.CronChecker,
+		keyScheduler:       params.KeyScheduler,
+		keyPoolProvider:    params.KeyPoolProvider,
+		proxyServer:        params.ProxyServer,
+		storage:            params.Storage,
+		db:                 params.DB,
 	}
 }
 
@@ -99,6 +123,13 @@ func (a *App) Start() error {
 			&models.APIKey{},
 			&models.RequestLog{},
 			&models.GroupHourlyStat{},
+			&models.KeyHourlyStat{},
+			&models.ModelPrice{},
+			&models.KeySource{},
+			&models.AdminUser{},
+			&models.AdminToken{},
+			&models.AuditLog{},
+			&models.ProxyKey{},
 		); err != nil {
 			return fmt.Errorf("database auto-migration failed: %w", err)
 		}
@@ -109,11 +140,16 @@ func (a *App) Start() error {
 		logrus.Info("Database auto-migration completed.")
 
 		// 初始化系统设置
-		if err := a.settingsManager.EnsureSettingsInitialized(a.configManager.GetAuthConfig()); err != nil {
+		if err := a.settingsManager.EnsureSettingsInitialized(); err != nil {
 			return fmt.Errorf("failed to initialize system settings: %w", err)
 		}
 		logrus.Info("System settings initialized in DB.")
 
+		// 加载加密密钥轮换状态，需在解密任何密钥之前完成
+		if err := a.keyRotationService.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize encryption key rotation: %w", err)
+		}
+
 		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
 
 		// 从数据库加载密钥到 Redis
@@ -126,8 +162,15 @@ func (a *App) Start() error {
 		a.requestLogService.Start()
 		a.logCleanupService.Start()
 		a.cronChecker.Start()
+		a.keyScheduler.Start()
+		a.keySourceService.Start()
+		a.keyRewrapService.Start()
+		a.keyRotationService.Start()
 	} else {
 		logrus.Info("Starting as Slave Node.")
+		if err := a.keyRotationService.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize encryption key rotation: %w", err)
+		}
 		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
 	}
 
@@ -136,6 +179,31 @@ func (a *App) Start() error {
 
 	a.groupManager.Initialize()
 
+	if err := a.modelPriceService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize model prices: %w", err)
+	}
+
+	if err := a.proxyKeyService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize proxy keys: %w", err)
+	}
+	if a.configManager.IsMaster() {
+		if err := a.proxyKeyService.EnsureInitialKey(context.Background(), a.configManager.GetAuthConfig().Key); err != nil {
+			return fmt.Errorf("failed to initialize proxy keys: %w", err)
+		}
+		a.proxyKeyService.Start()
+
+		// 按配置文件同步分组和设置
+		if declarativeConfig := a.configManager.GetDeclarativeConfig(); declarativeConfig.File != "" {
+			if err := a.configService.ReconcileFile(context.Background(), declarativeConfig.File, declarativeConfig.Prune); err != nil {
+				return fmt.Errorf("failed to apply config file: %w", err)
+			}
+		}
+	}
+
+	if err := a.authGuardService.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize auth guard: %w", err)
+	}
+
 	// Create HTTP server
 	serverConfig := a.configManager.GetEffectiveServerConfig()
 	a.httpServer = &http.Server{
@@ -184,12 +252,19 @@ func (a *App) Stop(ctx context.Context) {
 	// 使用原始的总超时 context 继续关闭其他后台服务
 	stoppableServices := []func(context.Context){
 		a.groupManager.Stop,
+		a.modelPriceService.Stop,
+		a.proxyKeyService.Stop,
+		a.authGuardService.Stop,
+		a.keyRewrapService.Stop,
+		a.keyRotationService.Stop,
 		a.settingsManager.Stop,
 	}
 
 	if serverConfig.IsMaster {
 		stoppableServices = append(stoppableServices,
 			a.cronChecker.Stop,
+			a.keyScheduler.Stop,
+			a.keySourceService.Stop,
 			a.logCleanupService.Stop,
 			a.requestLogService.Stop,
 		)
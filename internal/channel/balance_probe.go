@@ -0,0 +1,259 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Balance probe providers, selected by the balance_probe setting.
+const (
+	BalanceProbeOff           = "off"
+	BalanceProbeAuto          = "auto"
+	BalanceProbeOpenAIBilling = "openai_billing"
+	BalanceProbeDeepSeek      = "deepseek"
+	BalanceProbeOpenRouter    = "openrouter"
+)
+
+// ErrBalanceProbeUnsupported is returned when the upstream of a group has no known balance endpoint.
+var ErrBalanceProbeUnsupported = errors.New("balance probe is not supported for this upstream")
+
+// errQuotaExceeded marks a balance request the upstream rejected because the key has no quota left.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// KeyBalance is what an upstream reports about the remaining balance, quota or rate tier of a key.
+type KeyBalance struct {
+	Balance   *float64   // Remaining balance or credit, nil when the upstream reports none (e.g. unlimited)
+	Estimated bool       // Balance is computed from a limit and the usage rather than reported, and never disables the key
+	Currency  string     // Currency or unit of Balance
+	RateTier  string     // Plan or rate tier of the key, if reported
+	ExpiresAt *time.Time // Expiry of the key or its credit, if reported
+}
+
+// BalanceProber is implemented by channels whose upstreams can report the balance of a key.
+type BalanceProber interface {
+	// ProbeBalance fetches the balance of the given key using the probe configured for the group.
+	ProbeBalance(ctx context.Context, apiKey *models.APIKey, group *models.Group) (*KeyBalance, error)
+}
+
+// ProbeBalance fetches the balance of the key from an OpenAI-compatible provider.
+func (ch *OpenAIChannel) ProbeBalance(ctx context.Context, apiKey *models.APIKey, group *models.Group) (*KeyBalance, error) {
+	return probeOpenAICompatibleBalance(ctx, ch.BaseChannel, apiKey, group)
+}
+
+// ProbeBalance fetches the balance of the key from an OpenAI-compatible provider.
+func (ch *OpenAIResponseChannel) ProbeBalance(ctx context.Context, apiKey *models.APIKey, group *models.Group) (*KeyBalance, error) {
+	return probeOpenAICompatibleBalance(ctx, ch.BaseChannel, apiKey, group)
+}
+
+// ResolveBalanceProbe returns the probe to use for an upstream. In auto mode the
+// provider is detected from the upstream host, and "" is returned when it is unknown.
+func ResolveBalanceProbe(probe string, upstreamURL *url.URL) string {
+	if probe != BalanceProbeAuto {
+		if probe == BalanceProbeOff {
+			return ""
+		}
+		return probe
+	}
+	if upstreamURL == nil {
+		return ""
+	}
+
+	host := strings.ToLower(upstreamURL.Hostname())
+	switch {
+	case host == "deepseek.com" || strings.HasSuffix(host, ".deepseek.com"):
+		return BalanceProbeDeepSeek
+	case host == "openrouter.ai" || strings.HasSuffix(host, ".openrouter.ai"):
+		return BalanceProbeOpenRouter
+	default:
+		return ""
+	}
+}
+
+func probeOpenAICompatibleBalance(ctx context.Context, b *BaseChannel, apiKey *models.APIKey, group *models.Group) (*KeyBalance, error) {
+	upstreamURL := b.getUpstreamURL()
+	if upstreamURL == nil {
+		return nil, fmt.Errorf("no upstream URL configured for channel %s", b.Name)
+	}
+
+	get := func(path string, query url.Values, out any) error {
+		return probeGet(ctx, b.HTTPClient, upstreamURL, path, query, apiKey, group, out)
+	}
+
+	var balance *KeyBalance
+	var err error
+	switch ResolveBalanceProbe(group.EffectiveConfig.BalanceProbe, upstreamURL) {
+	case BalanceProbeDeepSeek:
+		balance, err = probeDeepSeekBalance(get)
+	case BalanceProbeOpenRouter:
+		balance, err = probeOpenRouterBalance(get)
+	case BalanceProbeOpenAIBilling:
+		balance, err = probeOpenAIBillingBalance(get)
+	default:
+		return nil, ErrBalanceProbeUnsupported
+	}
+
+	// A quota error is the upstream's own statement that nothing is left
+	if errors.Is(err, errQuotaExceeded) {
+		zero := 0.0
+		return &KeyBalance{Balance: &zero}, nil
+	}
+	return balance, err
+}
+
+// probeGet sends an authenticated GET request to the upstream and decodes the JSON response into out.
+func probeGet(ctx context.Context, client *http.Client, upstreamURL *url.URL, path string, query url.Values, apiKey *models.APIKey, group *models.Group, out any) error {
+	finalURL := *upstreamURL
+	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + path
+	finalURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, finalURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create balance request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+	req.Header.Set("Accept", "application/json")
+
+	if len(group.HeaderRuleList) > 0 {
+		headerCtx := utils.NewHeaderVariableContext(group, apiKey)
+		utils.ApplyHeaderRules(req, group.HeaderRuleList, headerCtx)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send balance request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read balance response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusPaymentRequired || strings.Contains(string(body), "insufficient_quota") {
+			return fmt.Errorf("[status %d] %s: %w", resp.StatusCode, app_errors.ParseUpstreamError(body), errQuotaExceeded)
+		}
+		return fmt.Errorf("[status %d] %s", resp.StatusCode, app_errors.ParseUpstreamError(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse balance response: %w", err)
+	}
+	return nil
+}
+
+type probeGetFunc func(path string, query url.Values, out any) error
+
+// probeDeepSeekBalance reads GET /user/balance.
+func probeDeepSeekBalance(get probeGetFunc) (*KeyBalance, error) {
+	var resp struct {
+		IsAvailable  bool `json:"is_available"`
+		BalanceInfos []struct {
+			Currency     string `json:"currency"`
+			TotalBalance string `json:"total_balance"`
+		} `json:"balance_infos"`
+	}
+	if err := get("/user/balance", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &KeyBalance{}
+	if len(resp.BalanceInfos) > 0 {
+		info := resp.BalanceInfos[0]
+		total, err := strconv.ParseFloat(info.TotalBalance, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid total_balance %q: %w", info.TotalBalance, err)
+		}
+		result.Balance = &total
+		result.Currency = info.Currency
+	} else if !resp.IsAvailable {
+		zero := 0.0
+		result.Balance = &zero
+	}
+	return result, nil
+}
+
+// probeOpenRouterBalance reads the account credits from GET /v1/credits and the
+// key limit and tier from GET /v1/key. The lower of the two is reported.
+func probeOpenRouterBalance(get probeGetFunc) (*KeyBalance, error) {
+	var keyResp struct {
+		Data struct {
+			LimitRemaining *float64 `json:"limit_remaining"`
+			IsFreeTier     bool     `json:"is_free_tier"`
+		} `json:"data"`
+	}
+	if err := get("/v1/key", nil, &keyResp); err != nil {
+		return nil, err
+	}
+
+	result := &KeyBalance{Currency: "USD", RateTier: "paid"}
+	if keyResp.Data.IsFreeTier {
+		result.RateTier = "free"
+	}
+	result.Balance = keyResp.Data.LimitRemaining
+
+	var creditsResp struct {
+		Data struct {
+			TotalCredits float64 `json:"total_credits"`
+			TotalUsage   float64 `json:"total_usage"`
+		} `json:"data"`
+	}
+	// Credits need a key with account access on some deployments, so the key limit alone is still useful.
+	if err := get("/v1/credits", nil, &creditsResp); err == nil {
+		credits := creditsResp.Data.TotalCredits - creditsResp.Data.TotalUsage
+		if result.Balance == nil || credits < *result.Balance {
+			result.Balance = &credits
+		}
+	}
+	return result, nil
+}
+
+// probeOpenAIBillingBalance reads the legacy dashboard billing endpoints, which are
+// still served by many OpenAI-compatible relays. The hard limit applies per calendar month,
+// so the balance is the limit minus the usage of the current month. It is only an estimate,
+// as relays differ in how they reset and report usage.
+func probeOpenAIBillingBalance(get probeGetFunc) (*KeyBalance, error) {
+	var sub struct {
+		HardLimitUSD float64 `json:"hard_limit_usd"`
+		AccessUntil  int64   `json:"access_until"`
+		Plan         struct {
+			Title string `json:"title"`
+			ID    string `json:"id"`
+		} `json:"plan"`
+	}
+	if err := get("/v1/dashboard/billing/subscription", nil, &sub); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	query := url.Values{}
+	query.Set("start_date", time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02"))
+	query.Set("end_date", now.AddDate(0, 0, 1).Format("2006-01-02"))
+	var usage struct {
+		TotalUsage float64 `json:"total_usage"` // In cents
+	}
+	if err := get("/v1/dashboard/billing/usage", query, &usage); err != nil {
+		return nil, err
+	}
+
+	balance := sub.HardLimitUSD - usage.TotalUsage/100
+	result := &KeyBalance{Balance: &balance, Estimated: true, Currency: "USD", RateTier: sub.Plan.Title}
+	if result.RateTier == "" {
+		result.RateTier = sub.Plan.ID
+	}
+	if sub.AccessUntil > 0 {
+		expiresAt := time.Unix(sub.AccessUntil, 0).UTC()
+		result.ExpiresAt = &expiresAt
+	}
+	return result, nil
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/container"
+	"gpt-load/internal/i18n"
+	"gpt-load/internal/models"
+	"gpt-load/internal/services"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RunConfig handles the config command entry point
+func RunConfig(args []string) {
+	if len(args) == 0 {
+		printConfigUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "export":
+		runConfigExport(args[1:])
+	case "diff":
+		runConfigReconcile("diff", args[1:])
+	case "apply":
+		runConfigReconcile("apply", args[1:])
+	case "help", "-h", "--help":
+		printConfigUsage()
+	default:
+		fmt.Printf("Unknown config command: %s\n", args[0])
+		printConfigUsage()
+		os.Exit(1)
+	}
+}
+
+func printConfigUsage() {
+	fmt.Println("GPT-Load Declarative Configuration Tool")
+	fmt.Println()
+	fmt.Println("Exports the settings, groups and aggregate membership as a YAML or JSON document,")
+	fmt.Println("and shows or applies the changes needed to make the database match one.")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  gpt-load config export [--format yaml|json] [--include-keys] [--output file]")
+	fmt.Println("  gpt-load config diff [--prune] <file>")
+	fmt.Println("  gpt-load config apply [--prune] <file>")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --prune   Delete groups, sub-group links and listed groups' keys missing from the file")
+	fmt.Println()
+	fmt.Println("⚠️  Important Notes:")
+	fmt.Println("  1. Keys are exported encrypted and can only be imported with the same encryption configuration")
+	fmt.Println("  2. Always backup database before applying with --prune")
+}
+
+func runConfigExport(args []string) {
+	exportCmd := flag.NewFlagSet("config export", flag.ExitOnError)
+	format := exportCmd.String("format", services.ConfigFormatYAML, "Output format: yaml or json")
+	includeKeys := exportCmd.Bool("include-keys", false, "Include the encrypted keys of standard groups")
+	output := exportCmd.String("output", "", "Output file (default: stdout)")
+	exportCmd.Usage = printConfigUsage
+
+	if err := exportCmd.Parse(args); err != nil {
+		logrus.Fatalf("Parameter parsing failed: %v", err)
+	}
+	if *format != services.ConfigFormatYAML && *format != services.ConfigFormatJSON {
+		logrus.Fatalf("Invalid format '%s', must be yaml or json", *format)
+	}
+
+	withConfigService(false, func(configService *services.ConfigService) {
+		doc, err := configService.Export(context.Background(), *includeKeys)
+		if err != nil {
+			logrus.Fatalf("Config export failed: %s", services.ConfigErrorMessage(err))
+		}
+		data, err := services.MarshalConfigDocument(doc, *format)
+		if err != nil {
+			logrus.Fatalf("Config export failed: %v", err)
+		}
+
+		if *output == "" {
+			if _, err := os.Stdout.Write(data); err != nil {
+				logrus.Fatalf("Failed to write config: %v", err)
+			}
+			return
+		}
+		if err := os.WriteFile(*output, data, 0600); err != nil {
+			logrus.Fatalf("Failed to write config file: %v", err)
+		}
+		logrus.Infof("Config exported to %s", *output)
+	})
+}
+
+func runConfigReconcile(command string, args []string) {
+	reconcileCmd := flag.NewFlagSet("config "+command, flag.ExitOnError)
+	prune := reconcileCmd.Bool("prune", false, "Delete what is missing from the file")
+	reconcileCmd.Usage = printConfigUsage
+
+	if err := reconcileCmd.Parse(args); err != nil {
+		logrus.Fatalf("Parameter parsing failed: %v", err)
+	}
+	if reconcileCmd.NArg() != 1 {
+		printConfigUsage()
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(reconcileCmd.Arg(0))
+	if err != nil {
+		logrus.Fatalf("Failed to read config file: %v", err)
+	}
+
+	apply := command == "apply"
+	withConfigService(apply, func(configService *services.ConfigService) {
+		doc, err := services.ParseConfigDocument(data)
+		if err != nil {
+			logrus.Fatalf("Invalid config file: %s", services.ConfigErrorMessage(err))
+		}
+
+		var plan *services.ConfigPlan
+		if apply {
+			plan, err = configService.Apply(context.Background(), doc, *prune)
+		} else {
+			plan, err = configService.Plan(context.Background(), doc, *prune)
+		}
+		if err != nil {
+			logrus.Fatalf("Config %s failed: %s", command, services.ConfigErrorMessage(err))
+		}
+
+		printConfigPlan(plan)
+	})
+}
+
+// withConfigService prepares the database and settings for a config command and runs it.
+func withConfigService(apply bool, run func(configService *services.ConfigService)) {
+	cont, err := container.BuildContainer()
+	if err != nil {
+		logrus.Fatalf("Failed to build container: %v", err)
+	}
+
+	if err := cont.Invoke(func(configManager types.ConfigManager) {
+		utils.SetupLogger(configManager)
+	}); err != nil {
+		logrus.Fatalf("Failed to setup logger: %v", err)
+	}
+	// Keep stdout for the exported document and the plan
+	logrus.SetOutput(os.Stderr)
+
+	// Validation errors are printed in English
+	if err := i18n.Init(); err != nil {
+		logrus.Fatalf("Failed to initialize i18n: %v", err)
+	}
+
+	if err := cont.Invoke(func(
+		db *gorm.DB,
+		cacheStore store.Store,
+		settingsManager *config.SystemSettingsManager,
+		groupManager *services.GroupManager,
+		keyRotationService *services.EncryptionKeyRotationService,
+		configService *services.ConfigService,
+	) {
+		if err := db.AutoMigrate(
+			&models.SystemSetting{},
+			&models.Group{},
+			&models.GroupSubGroup{},
+			&models.APIKey{},
+			&models.AuditLog{},
+		); err != nil {
+			logrus.Fatalf("Database auto-migration failed: %v", err)
+		}
+
+		// Keys can only be decrypted once the rotation state is loaded
+		if err := keyRotationService.Initialize(); err != nil {
+			logrus.Fatalf("Failed to initialize encryption key rotation: %v", err)
+		}
+		if err := settingsManager.Initialize(cacheStore, groupManager, false); err != nil {
+			logrus.Fatalf("Failed to initialize system settings: %v", err)
+		}
+		if apply {
+			if err := groupManager.Initialize(); err != nil {
+				logrus.Fatalf("Failed to initialize groups: %v", err)
+			}
+		}
+
+		run(configService)
+
+		// Notify running instances to reload their settings and groups
+		if apply {
+			for _, channel := range []string{config.SettingsUpdateChannel, services.GroupUpdateChannel} {
+				if err := cacheStore.Publish(channel, []byte("reload")); err != nil {
+					logrus.Warnf("Failed to notify running instances, restart the service to apply: %v", err)
+				}
+			}
+		}
+	}); err != nil {
+		logrus.Fatalf("Failed to execute config command: %v", err)
+	}
+}
+
+// printConfigPlan prints the changes of a plan, one per line.
+func printConfigPlan(plan *services.ConfigPlan) {
+	if len(plan.Changes) == 0 {
+		fmt.Println("No changes. The database matches the config file.")
+		return
+	}
+
+	symbols := map[string]string{
+		services.ConfigActionCreate: "+",
+		services.ConfigActionUpdate: "~",
+		services.ConfigActionDelete: "-",
+	}
+	counts := make(map[string]int)
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+		line := fmt.Sprintf("%s %s %s", symbols[change.Action], change.Kind, change.Target)
+		if change.Count > 0 {
+			line += fmt.Sprintf(" (%d)", change.Count)
+		}
+		fmt.Println(line)
+
+		if change.Action == services.ConfigActionDelete || change.After == nil {
+			continue
+		}
+		before, _ := change.Before.(map[string]any)
+		after, ok := change.After.(map[string]any)
+		if !ok {
+			fmt.Printf("    %s -> %s\n", formatConfigValue(change.Before), formatConfigValue(change.After))
+			continue
+		}
+		fields := make([]string, 0, len(after))
+		for field := range after {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if change.Action == services.ConfigActionCreate {
+				fmt.Printf("    %s: %s\n", field, formatConfigValue(after[field]))
+			} else {
+				fmt.Printf("    %s: %s -> %s\n", field, formatConfigValue(before[field]), formatConfigValue(after[field]))
+			}
+		}
+	}
+
+	prefix := "Plan"
+	if !plan.DryRun {
+		prefix = "Applied"
+	}
+	fmt.Printf("\n%s: %d to create, %d to update, %d to delete.\n", prefix,
+		counts[services.ConfigActionCreate], counts[services.ConfigActionUpdate], counts[services.ConfigActionDelete])
+}
+
+func formatConfigValue(value any) string {
+	if value == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -7,6 +7,7 @@ import (
 	db "gpt-load/internal/db/migrations"
 	"gpt-load/internal/encryption"
 	"gpt-load/internal/models"
+	"gpt-load/internal/services"
 	"gpt-load/internal/store"
 	"gpt-load/internal/types"
 	"gpt-load/internal/utils"
@@ -67,6 +68,14 @@ func RunMigrateKeys(args []string) {
 
 	// Execute migration command
 	if err := cont.Invoke(func(db *gorm.DB, configManager types.ConfigManager, cacheStore store.Store) {
+		if provider := configManager.GetEncryptionConfig().Provider; provider != "" {
+			logrus.Fatalf("migrate-keys only handles ENCRYPTION_KEY, unset ENCRYPTION_PROVIDER (%s) to run it", provider)
+		}
+		if pending, err := services.HasPendingEncryptionKeyRotation(db); err != nil {
+			logrus.Fatalf("Failed to check encryption key rotation state: %v", err)
+		} else if pending {
+			logrus.Fatal("An online encryption key rotation is recorded, set ENCRYPTION_KEY to the rotated key and start the service once before running migrate-keys")
+		}
 		migrateKeysCmd := NewMigrateKeysCommand(db, configManager, cacheStore, *fromKey, *toKey)
 		if err := migrateKeysCmd.Execute(); err != nil {
 			logrus.Fatalf("Key migration failed: %v", err)
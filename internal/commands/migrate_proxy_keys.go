@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/container"
+	"gpt-load/internal/models"
+	"gpt-load/internal/services"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RunMigrateProxyKeys handles the migrate-proxy-keys command entry point
+func RunMigrateProxyKeys(args []string) {
+	migrateCmd := flag.NewFlagSet("migrate-proxy-keys", flag.ExitOnError)
+
+	migrateCmd.Usage = func() {
+		fmt.Println("GPT-Load Proxy Key Migration Tool")
+		fmt.Println()
+		fmt.Println("Converts the plain-text proxy keys of the system settings and of every group")
+		fmt.Println("into salted hashes and removes the plain-text values.")
+		fmt.Println()
+		fmt.Println("Usage:")
+		fmt.Println("  gpt-load migrate-proxy-keys")
+		fmt.Println()
+		fmt.Println("⚠️  Important Notes:")
+		fmt.Println("  1. Always backup database before migration")
+		fmt.Println("  2. Keys can no longer be displayed after migration, make sure clients still have them")
+	}
+
+	if err := migrateCmd.Parse(args); err != nil {
+		logrus.Fatalf("Parameter parsing failed: %v", err)
+	}
+
+	cont, err := container.BuildContainer()
+	if err != nil {
+		logrus.Fatalf("Failed to build container: %v", err)
+	}
+
+	if err := cont.Invoke(func(configManager types.ConfigManager) {
+		utils.SetupLogger(configManager)
+	}); err != nil {
+		logrus.Fatalf("Failed to setup logger: %v", err)
+	}
+
+	if err := cont.Invoke(func(db *gorm.DB, cacheStore store.Store, proxyKeyService *services.ProxyKeyService) {
+		if err := db.AutoMigrate(&models.SystemSetting{}, &models.Group{}, &models.ProxyKey{}); err != nil {
+			logrus.Fatalf("Database auto-migration failed: %v", err)
+		}
+
+		converted, err := proxyKeyService.MigrateLegacyKeys(context.Background())
+		if err != nil {
+			logrus.Fatalf("Proxy key migration failed: %v", err)
+		}
+		logrus.Infof("Converted %d proxy keys", converted)
+
+		// Notify running instances to reload their settings, groups and proxy keys
+		for _, channel := range []string{config.SettingsUpdateChannel, services.GroupUpdateChannel, services.ProxyKeyUpdateChannel} {
+			if err := cacheStore.Publish(channel, []byte("reload")); err != nil {
+				logrus.Warnf("Failed to notify running instances, restart the service to apply: %v", err)
+			}
+		}
+	}); err != nil {
+		logrus.Fatalf("Failed to execute migration: %v", err)
+	}
+
+	logrus.Info("Proxy key migration command completed")
+}
@@ -4,9 +4,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"gpt-load/internal/encryption"
 	"gpt-load/internal/errors"
+	"gpt-load/internal/models"
 	"gpt-load/internal/types"
 	"gpt-load/internal/utils"
 
@@ -34,6 +38,11 @@ var DefaultConstants = Constants{
 	DefaultMaxFreeSockets: 10,
 }
 
+// defaultTrustedProxies are the reverse proxies whose forwarding headers are trusted when
+// TRUSTED_PROXIES is not set: loopback only. Proxies on other hosts, such as a Docker network,
+// must be listed explicitly, otherwise any host on those networks could spoof its client IP.
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
 // Manager implements the ConfigManager interface
 type Manager struct {
 	config          *Config
@@ -44,12 +53,16 @@ type Manager struct {
 type Config struct {
 	Server        types.ServerConfig
 	Auth          types.AuthConfig
+	OIDC          types.OIDCConfig
 	CORS          types.CORSConfig
 	Performance   types.PerformanceConfig
 	Log           types.LogConfig
 	Database      types.DatabaseConfig
 	RedisDSN      string
 	EncryptionKey string
+	Encryption    types.EncryptionConfig
+	Declarative   types.DeclarativeConfig
+	KeySource     types.KeySourceConfig
 }
 
 // NewManager creates a new configuration manager
@@ -78,10 +91,23 @@ func (m *Manager) ReloadConfig() error {
 			WriteTimeout:            utils.ParseInteger(os.Getenv("SERVER_WRITE_TIMEOUT"), 600),
 			IdleTimeout:             utils.ParseInteger(os.Getenv("SERVER_IDLE_TIMEOUT"), 120),
 			GracefulShutdownTimeout: utils.ParseInteger(os.Getenv("SERVER_GRACEFUL_SHUTDOWN_TIMEOUT"), 10),
+			TrustedProxies:          utils.ParseArray(os.Getenv("TRUSTED_PROXIES"), defaultTrustedProxies),
 		},
 		Auth: types.AuthConfig{
 			Key: os.Getenv("AUTH_KEY"),
 		},
+		OIDC: types.OIDCConfig{
+			Enabled:       os.Getenv("OIDC_ISSUER") != "",
+			Issuer:        strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+			ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:        utils.ParseArray(os.Getenv("OIDC_SCOPES"), []string{"openid", "profile", "email"}),
+			UsernameClaim: utils.GetEnvOrDefault("OIDC_USERNAME_CLAIM", "preferred_username"),
+			RoleClaim:     utils.GetEnvOrDefault("OIDC_ROLE_CLAIM", "groups"),
+			RoleMapping:   parseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING")),
+			DefaultRole:   os.Getenv("OIDC_DEFAULT_ROLE"),
+		},
 		CORS: types.CORSConfig{
 			Enabled:          utils.ParseBoolean(os.Getenv("ENABLE_CORS"), false),
 			AllowedOrigins:   utils.ParseArray(os.Getenv("ALLOWED_ORIGINS"), []string{}),
@@ -103,6 +129,28 @@ func (m *Manager) ReloadConfig() error {
 		},
 		RedisDSN:      os.Getenv("REDIS_DSN"),
 		EncryptionKey: os.Getenv("ENCRYPTION_KEY"),
+		Encryption: types.EncryptionConfig{
+			Provider:              strings.ToLower(strings.TrimSpace(os.Getenv("ENCRYPTION_PROVIDER"))),
+			LocalKeyFile:          os.Getenv("ENCRYPTION_LOCAL_KEY_FILE"),
+			VaultAddr:             strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+			VaultToken:            os.Getenv("VAULT_TOKEN"),
+			VaultNamespace:        os.Getenv("VAULT_NAMESPACE"),
+			VaultTransitMount:     strings.Trim(utils.GetEnvOrDefault("VAULT_TRANSIT_MOUNT", "transit"), "/"),
+			VaultTransitKey:       utils.GetEnvOrDefault("VAULT_TRANSIT_KEY", "gpt-load"),
+			KMSURL:                strings.TrimSuffix(os.Getenv("ENCRYPTION_KMS_URL"), "/"),
+			KMSToken:              os.Getenv("ENCRYPTION_KMS_TOKEN"),
+			KMSKeyID:              os.Getenv("ENCRYPTION_KMS_KEY_ID"),
+			DataKeyMaxUses:        utils.ParseInteger(os.Getenv("ENCRYPTION_DATA_KEY_MAX_USES"), encryption.DefaultDataKeyMaxUses),
+			RewrapIntervalMinutes: utils.ParseInteger(os.Getenv("ENCRYPTION_REWRAP_INTERVAL_MINUTES"), 60),
+		},
+		Declarative: types.DeclarativeConfig{
+			File:  strings.TrimSpace(os.Getenv("CONFIG_FILE")),
+			Prune: utils.ParseBoolean(os.Getenv("CONFIG_PRUNE"), false),
+		},
+		KeySource: types.KeySourceConfig{
+			Dir:                  strings.TrimSpace(os.Getenv("KEY_SOURCE_DIR")),
+			AllowPrivateNetworks: utils.ParseBoolean(os.Getenv("KEY_SOURCE_ALLOW_PRIVATE_NETWORKS"), false),
+		},
 	}
 	m.config = config
 
@@ -124,6 +172,11 @@ func (m *Manager) GetAuthConfig() types.AuthConfig {
 	return m.config.Auth
 }
 
+// GetOIDCConfig returns OpenID Connect configuration
+func (m *Manager) GetOIDCConfig() types.OIDCConfig {
+	return m.config.OIDC
+}
+
 // GetCORSConfig returns CORS configuration
 func (m *Manager) GetCORSConfig() types.CORSConfig {
 	return m.config.CORS
@@ -154,6 +207,21 @@ func (m *Manager) GetEncryptionKey() string {
 	return m.config.EncryptionKey
 }
 
+// GetEncryptionConfig returns envelope encryption configuration
+func (m *Manager) GetEncryptionConfig() types.EncryptionConfig {
+	return m.config.Encryption
+}
+
+// GetDeclarativeConfig returns the configuration file reconciled on startup
+func (m *Manager) GetDeclarativeConfig() types.DeclarativeConfig {
+	return m.config.Declarative
+}
+
+// GetKeySourceConfig returns the restrictions of key sources
+func (m *Manager) GetKeySourceConfig() types.KeySourceConfig {
+	return m.config.KeySource
+}
+
 // GetEffectiveServerConfig returns server configuration merged with system settings
 func (m *Manager) GetEffectiveServerConfig() types.ServerConfig {
 	return m.config.Server
@@ -179,6 +247,70 @@ func (m *Manager) Validate() error {
 		utils.ValidatePasswordStrength(m.config.Auth.Key, "AUTH_KEY")
 	}
 
+	// Validate OIDC
+	if m.config.OIDC.Enabled {
+		if m.config.OIDC.ClientID == "" || m.config.OIDC.RedirectURL == "" {
+			validationErrors = append(validationErrors, "OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+		}
+		if !slices.Contains(m.config.OIDC.Scopes, "openid") {
+			m.config.OIDC.Scopes = append([]string{"openid"}, m.config.OIDC.Scopes...)
+		}
+		for claimValue, role := range m.config.OIDC.RoleMapping {
+			if models.AdminRoleRank(role) == 0 {
+				validationErrors = append(validationErrors, fmt.Sprintf("OIDC_ROLE_MAPPING has invalid role '%s' for '%s'", role, claimValue))
+			}
+		}
+		if m.config.OIDC.DefaultRole != "" && models.AdminRoleRank(m.config.OIDC.DefaultRole) == 0 {
+			validationErrors = append(validationErrors, fmt.Sprintf("OIDC_DEFAULT_ROLE has invalid role '%s'", m.config.OIDC.DefaultRole))
+		}
+		if len(m.config.OIDC.RoleMapping) == 0 && m.config.OIDC.DefaultRole == "" {
+			logrus.Warn("OIDC is enabled without OIDC_ROLE_MAPPING or OIDC_DEFAULT_ROLE, no SSO user will be able to log in.")
+		}
+	}
+
+	// Validate envelope encryption
+	encryptionConfig := &m.config.Encryption
+	switch encryptionConfig.Provider {
+	case "":
+	case "local":
+		if encryptionConfig.LocalKeyFile == "" {
+			validationErrors = append(validationErrors, "ENCRYPTION_LOCAL_KEY_FILE is required when ENCRYPTION_PROVIDER is local")
+		}
+	case "vault":
+		if encryptionConfig.VaultAddr == "" || encryptionConfig.VaultToken == "" {
+			validationErrors = append(validationErrors, "VAULT_ADDR and VAULT_TOKEN are required when ENCRYPTION_PROVIDER is vault")
+		}
+	case "kms":
+		if encryptionConfig.KMSURL == "" || encryptionConfig.KMSKeyID == "" {
+			validationErrors = append(validationErrors, "ENCRYPTION_KMS_URL and ENCRYPTION_KMS_KEY_ID are required when ENCRYPTION_PROVIDER is kms")
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("ENCRYPTION_PROVIDER has invalid value '%s', expected local, vault or kms", encryptionConfig.Provider))
+	}
+	if encryptionConfig.DataKeyMaxUses < 1 {
+		logrus.Warnf("ENCRYPTION_DATA_KEY_MAX_USES value %d is invalid, resetting to 1.", encryptionConfig.DataKeyMaxUses)
+		encryptionConfig.DataKeyMaxUses = 1
+	}
+	if encryptionConfig.RewrapIntervalMinutes < 0 {
+		encryptionConfig.RewrapIntervalMinutes = 0
+	}
+
+	// Validate key source directory
+	if m.config.KeySource.Dir != "" {
+		if !filepath.IsAbs(m.config.KeySource.Dir) {
+			validationErrors = append(validationErrors, "KEY_SOURCE_DIR must be an absolute path")
+		} else {
+			m.config.KeySource.Dir = filepath.Clean(m.config.KeySource.Dir)
+		}
+	}
+
+	// Validate trusted proxies
+	if len(m.config.Server.TrustedProxies) == 1 && strings.EqualFold(m.config.Server.TrustedProxies[0], "none") {
+		m.config.Server.TrustedProxies = nil
+	} else if _, err := utils.ParseIPList(strings.Join(m.config.Server.TrustedProxies, ",")); err != nil {
+		validationErrors = append(validationErrors, fmt.Sprintf("TRUSTED_PROXIES is invalid: %v", err))
+	}
+
 	// Validate GracefulShutdownTimeout and reset if necessary
 	if m.config.Server.GracefulShutdownTimeout < 10 {
 		logrus.Warnf("SERVER_GRACEFUL_SHUTDOWN_TIMEOUT value %ds is too short, resetting to minimum 10s.", m.config.Server.GracefulShutdownTimeout)
@@ -228,7 +360,12 @@ func (m *Manager) DisplayServerConfig() {
 
 	logrus.Info("  --- Security ---")
 	logrus.Infof("    Authentication: enabled (key loaded)")
-	if encryptionKey != "" {
+	if oidcConfig := m.GetOIDCConfig(); oidcConfig.Enabled {
+		logrus.Infof("    OIDC SSO: enabled (Issuer: %s)", oidcConfig.Issuer)
+	}
+	if encryptionConfig := m.GetEncryptionConfig(); encryptionConfig.Provider != "" {
+		logrus.Infof("    Encryption: envelope (Provider: %s)", encryptionConfig.Provider)
+	} else if encryptionKey != "" {
 		logrus.Info("    Encryption: enabled")
 	} else {
 		logrus.Warn("    Encryption: disabled - WARNING: Sensitive data may be stored unencrypted, which poses security risks including potential key exposure")
@@ -238,6 +375,19 @@ func (m *Manager) DisplayServerConfig() {
 		corsStatus = fmt.Sprintf("enabled (Origins: %s)", strings.Join(corsConfig.AllowedOrigins, ", "))
 	}
 	logrus.Infof("    CORS: %s", corsStatus)
+	if len(serverConfig.TrustedProxies) > 0 {
+		logrus.Infof("    Trusted Proxies: %s", strings.Join(serverConfig.TrustedProxies, ", "))
+	} else {
+		logrus.Info("    Trusted Proxies: none (forwarding headers are ignored)")
+	}
+
+	keySourceConfig := m.GetKeySourceConfig()
+	if keySourceConfig.Dir != "" {
+		logrus.Infof("    Key Source Directory: %s", keySourceConfig.Dir)
+	}
+	if keySourceConfig.AllowPrivateNetworks {
+		logrus.Warn("    Key Sources: private and loopback addresses allowed")
+	}
 
 	logrus.Info("  --- Logging ---")
 	logrus.Infof("    Log Level: %s", logConfig.Level)
@@ -247,6 +397,12 @@ func (m *Manager) DisplayServerConfig() {
 		logrus.Infof("    Log File Path: %s", logConfig.FilePath)
 	}
 
+	if declarativeConfig := m.GetDeclarativeConfig(); declarativeConfig.File != "" {
+		logrus.Info("  --- Declarative Config ---")
+		logrus.Infof("    Config File: %s", declarativeConfig.File)
+		logrus.Infof("    Prune: %t", declarativeConfig.Prune)
+	}
+
 	logrus.Info("  --- Dependencies ---")
 	if dbConfig.DSN != "" {
 		logrus.Info("    Database: configured")
@@ -261,3 +417,17 @@ func (m *Manager) DisplayServerConfig() {
 	logrus.Info("====================================")
 	logrus.Info("")
 }
+
+// parseRoleMapping parses OIDC_ROLE_MAPPING, a comma-separated list of claim=role pairs
+func parseRoleMapping(value string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range utils.ParseArray(value, nil) {
+		claimValue, role, found := strings.Cut(pair, "=")
+		if !found {
+			logrus.Warnf("Ignoring invalid OIDC_ROLE_MAPPING entry '%s', expected claim=role", pair)
+			continue
+		}
+		mapping[strings.TrimSpace(claimValue)] = strings.TrimSpace(role)
+	}
+	return mapping
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default is loopback only", env: "", want: defaultTrustedProxies},
+		{name: "CIDR list", env: "10.0.0.0/8, 172.16.0.0/12", want: []string{"10.0.0.0/8", "172.16.0.0/12"}},
+		{name: "single addresses", env: "192.168.1.10,::1", want: []string{"192.168.1.10", "::1"}},
+		{name: "none disables forwarding headers", env: "none", want: nil},
+		{name: "none is case insensitive", env: "NONE", want: nil},
+		{name: "invalid address", env: "10.0.0.0/8,proxy.local", wantErr: true},
+		{name: "invalid prefix length", env: "10.0.0.0/40", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_KEY", "test-auth-key-1234567")
+			t.Setenv("TRUSTED_PROXIES", tt.env)
+
+			manager, err := NewManager(NewSystemSettingsManager())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewManager with TRUSTED_PROXIES=%q error = %v, wantErr %v", tt.env, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := manager.GetEffectiveServerConfig().TrustedProxies; !slices.Equal(got, tt.want) {
+				t.Errorf("TrustedProxies with TRUSTED_PROXIES=%q = %v, want %v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
@@ -12,11 +12,13 @@ import (
 	"gpt-load/internal/utils"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -51,29 +53,7 @@ func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager,
 
 		// Start with default settings, then override with values from the database.
 		settings := utils.DefaultSystemSettings()
-		v := reflect.ValueOf(&settings).Elem()
-		t := v.Type()
-		jsonToField := make(map[string]string)
-		for i := range t.NumField() {
-			field := t.Field(i)
-			jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
-			if jsonTag != "" {
-				jsonToField[jsonTag] = field.Name
-			}
-		}
-
-		for key, valStr := range settingsMap {
-			if fieldName, ok := jsonToField[key]; ok {
-				fieldValue := v.FieldByName(fieldName)
-				if fieldValue.IsValid() && fieldValue.CanSet() {
-					if err := utils.SetFieldFromString(fieldValue, valStr); err != nil {
-						logrus.Warnf("Failed to set value from map for field %s: %v", fieldName, err)
-					}
-				}
-			}
-		}
-
-		settings.ProxyKeysMap = utils.StringToSet(settings.ProxyKeys, ",")
+		applySettingValues(&settings, settingsMap)
 
 		sm.DisplaySystemConfig(settings)
 
@@ -102,6 +82,31 @@ func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager,
 	return nil
 }
 
+// applySettingValues sets the fields of settings from values keyed by their JSON names.
+func applySettingValues(settings *types.SystemSettings, values map[string]string) {
+	v := reflect.ValueOf(settings).Elem()
+	t := v.Type()
+	jsonToField := make(map[string]string)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonTag != "" {
+			jsonToField[jsonTag] = field.Name
+		}
+	}
+
+	for key, valStr := range values {
+		if fieldName, ok := jsonToField[key]; ok {
+			fieldValue := v.FieldByName(fieldName)
+			if fieldValue.IsValid() && fieldValue.CanSet() {
+				if err := utils.SetFieldFromString(fieldValue, valStr); err != nil {
+					logrus.Warnf("Failed to set value from map for field %s: %v", fieldName, err)
+				}
+			}
+		}
+	}
+}
+
 // Stop gracefully stops the SystemSettingsManager's background syncer.
 func (sm *SystemSettingsManager) Stop(ctx context.Context) {
 	if sm.syncer != nil {
@@ -110,7 +115,7 @@ func (sm *SystemSettingsManager) Stop(ctx context.Context) {
 }
 
 // EnsureSettingsInitialized 确保数据库中存在所有系统设置的记录。
-func (sm *SystemSettingsManager) EnsureSettingsInitialized(authConfig types.AuthConfig) error {
+func (sm *SystemSettingsManager) EnsureSettingsInitialized() error {
 	defaultSettings := utils.DefaultSystemSettings()
 	metadata := utils.GenerateSettingsMetadata(&defaultSettings)
 
@@ -131,8 +136,9 @@ func (sm *SystemSettingsManager) EnsureSettingsInitialized(authConfig types.Auth
 				value = fmt.Sprintf("http://%s:%s", host, port)
 			}
 
+			// 代理密钥只以哈希形式保存在 proxy_keys 表中，不再写入明文
 			if meta.Key == "proxy_keys" {
-				value = authConfig.Key
+				value = ""
 			}
 
 			setting := models.SystemSetting{
@@ -180,6 +186,17 @@ func (sm *SystemSettingsManager) GetAppUrl() string {
 
 // UpdateSettings 更新系统配置
 func (sm *SystemSettingsManager) UpdateSettings(settingsMap map[string]any) error {
+	if err := sm.SaveSettings(db.DB, settingsMap); err != nil {
+		return err
+	}
+
+	// 触发所有实例重新加载
+	return sm.Invalidate()
+}
+
+// SaveSettings 验证并在给定的数据库连接（可以是事务）中保存配置，不触发重新加载。
+// 事务提交后需调用 Invalidate。
+func (sm *SystemSettingsManager) SaveSettings(tx *gorm.DB, settingsMap map[string]any) error {
 	// 验证配置项
 	if err := sm.ValidateSettings(settingsMap); err != nil {
 		return err
@@ -195,18 +212,32 @@ func (sm *SystemSettingsManager) UpdateSettings(settingsMap map[string]any) erro
 	}
 
 	if len(settingsToUpdate) > 0 {
-		if err := db.DB.Clauses(clause.OnConflict{
+		if err := tx.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "setting_key"}},
 			DoUpdates: clause.AssignmentColumns([]string{"setting_value", "updated_at"}),
 		}).Create(&settingsToUpdate).Error; err != nil {
 			return fmt.Errorf("failed to update system settings: %w", err)
 		}
 	}
+	return nil
+}
 
-	// 触发所有实例重新加载
+// Invalidate 触发所有实例重新加载系统配置
+func (sm *SystemSettingsManager) Invalidate() error {
 	return sm.syncer.Invalidate()
 }
 
+// SettingsWith 返回应用给定配置项后的系统配置，用于在重新加载完成前读取刚保存的配置。
+func (sm *SystemSettingsManager) SettingsWith(settingsMap map[string]any) types.SystemSettings {
+	settings := sm.GetSettings()
+	values := make(map[string]string, len(settingsMap))
+	for key, value := range settingsMap {
+		values[key] = fmt.Sprintf("%v", value)
+	}
+	applySettingValues(&settings, values)
+	return settings
+}
+
 // GetEffectiveConfig 获取有效配置 (系统配置 + 分组覆盖)
 func (sm *SystemSettingsManager) GetEffectiveConfig(groupConfigJSON datatypes.JSONMap) types.SystemSettings {
 	effectiveConfig := sm.GetSettings()
@@ -289,6 +320,12 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 						return fmt.Errorf("value for %s (%d) is below minimum value (%d)", key, intVal, minVal)
 					}
 				}
+				if strings.HasPrefix(trimmedRule, "max=") {
+					maxVal, _ := strconv.Atoi(strings.TrimPrefix(trimmedRule, "max="))
+					if intVal > maxVal {
+						return fmt.Errorf("value for %s (%d) is above maximum value (%d)", key, intVal, maxVal)
+					}
+				}
 			}
 		case reflect.Bool:
 			if _, ok := value.(bool); !ok {
@@ -306,6 +343,22 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if trimmedRule == "ip_list" {
+					if _, err := utils.ParseIPList(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %w", key, err)
+					}
+				}
+				if trimmedRule == "cron" && strVal != "" {
+					if _, err := utils.ParseCron(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %w", key, err)
+					}
+				}
+				if strings.HasPrefix(trimmedRule, "oneof=") {
+					allowed := strings.Fields(strings.TrimPrefix(trimmedRule, "oneof="))
+					if !slices.Contains(allowed, strVal) {
+						return fmt.Errorf("invalid value for %s: must be one of %s", key, strings.Join(allowed, ", "))
+					}
+				}
 			}
 		default:
 			return fmt.Errorf("unsupported type for setting key validation: %s", key)
@@ -363,6 +416,12 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 						return fmt.Errorf("value for %s (%d) is below minimum value (%d)", key, intVal, minVal)
 					}
 				}
+				if strings.HasPrefix(trimmedRule, "max=") {
+					maxVal, _ := strconv.Atoi(strings.TrimPrefix(trimmedRule, "max="))
+					if intVal > maxVal {
+						return fmt.Errorf("value for %s (%d) is above maximum value (%d)", key, intVal, maxVal)
+					}
+				}
 			}
 		case reflect.String:
 			strVal, ok := value.(string)
@@ -376,6 +435,22 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 						return fmt.Errorf("value for %s is required", key)
 					}
 				}
+				if trimmedRule == "ip_list" {
+					if _, err := utils.ParseIPList(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %w", key, err)
+					}
+				}
+				if trimmedRule == "cron" && strVal != "" {
+					if _, err := utils.ParseCron(strVal); err != nil {
+						return fmt.Errorf("invalid value for %s: %w", key, err)
+					}
+				}
+				if strings.HasPrefix(trimmedRule, "oneof=") {
+					allowed := strings.Fields(strings.TrimPrefix(trimmedRule, "oneof="))
+					if !slices.Contains(allowed, strVal) {
+						return fmt.Errorf("invalid value for %s: must be one of %s", key, strings.Join(allowed, ", "))
+					}
+				}
 			}
 		case reflect.Bool:
 			_, ok := value.(bool)
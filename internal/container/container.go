@@ -27,8 +27,13 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(config.NewManager); err != nil {
 		return nil, err
 	}
-	if err := container.Provide(func(configManager types.ConfigManager) (encryption.Service, error) {
-		return encryption.NewService(configManager.GetEncryptionKey())
+	if err := container.Provide(func(configManager types.ConfigManager) (*encryption.KeyRing, error) {
+		return encryption.NewKeyRing(configManager.GetEncryptionKey())
+	}); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(func(configManager types.ConfigManager, keyRing *encryption.KeyRing) (encryption.Service, error) {
+		return encryption.NewServiceFromConfig(configManager.GetEncryptionConfig(), keyRing)
 	}); err != nil {
 		return nil, err
 	}
@@ -61,9 +66,15 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(services.NewKeyImportService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewKeySourceService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewKeyDeleteService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewKeyTransferService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewLogService); err != nil {
 		return nil, err
 	}
@@ -73,6 +84,39 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(services.NewRequestLogService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewModelPriceService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewBudgetService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewRateLimitService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewRequestQueueService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewAdminAuthService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewAuditService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewOIDCService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewProxyKeyService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewAuthGuardService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewKeyRewrapService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewEncryptionKeyRotationService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewSubGroupManager); err != nil {
 		return nil, err
 	}
@@ -85,6 +129,9 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(services.NewAggregateGroupService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewConfigService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(keypool.NewProvider); err != nil {
 		return nil, err
 	}
@@ -94,6 +141,9 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(keypool.NewCronChecker); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(keypool.NewKeyScheduler); err != nil {
+		return nil, err
+	}
 
 	// Handlers
 	if err := container.Provide(handler.NewServer); err != nil {
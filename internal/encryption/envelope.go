@@ -0,0 +1,413 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gpt-load/internal/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// envelopePrefix marks values written by the envelope service:
+	// gle1:<master key version>:<base64url wrapped data key>:<base64url nonce+ciphertext>
+	envelopePrefix = "gle1:"
+
+	// versionCacheTTL is how long the current master key version is cached, so
+	// rotations at the provider are picked up without a restart
+	versionCacheTTL = time.Minute
+
+	// dataKeyMaxAge bounds how long a data key is reused when ENCRYPTION_DATA_KEY_MAX_USES > 1
+	dataKeyMaxAge = 5 * time.Minute
+
+	// DefaultDataKeyMaxUses is the default number of records encrypted with one data key.
+	// Every record gets a fresh data key unless reuse is enabled with ENCRYPTION_DATA_KEY_MAX_USES.
+	DefaultDataKeyMaxUses = 1
+
+	// unwrapCacheSize bounds the number of unwrapped data keys kept in memory
+	unwrapCacheSize = 10000
+)
+
+// EnvelopeService is implemented by services that encrypt each record with a data key
+// wrapped by a versioned master key, which allows rotating the master key online.
+type EnvelopeService interface {
+	Service
+	// ProviderName returns the master key provider type.
+	ProviderName() string
+	// CurrentKeyVersion returns the master key version used for new records.
+	CurrentKeyVersion(ctx context.Context) (uint32, error)
+	// KeyVersion returns the master key version of an envelope ciphertext.
+	KeyVersion(ciphertext string) (uint32, bool)
+	// Rewrap re-wraps the data key of a ciphertext with the current master key version
+	// without decrypting the record. It reports whether the ciphertext changed.
+	Rewrap(ctx context.Context, ciphertext string) (string, bool, error)
+}
+
+// EnvelopePrefix returns the prefix shared by all envelope ciphertexts.
+func EnvelopePrefix() string {
+	return envelopePrefix
+}
+
+// EnvelopeVersionPrefix returns the prefix of envelope ciphertexts wrapped with the given version.
+func EnvelopeVersionPrefix(version uint32) string {
+	return envelopePrefix + strconv.FormatUint(uint64(version), 10) + ":"
+}
+
+// NewServiceFromConfig creates the encryption service for the configured provider.
+// Without a provider it returns legacy, the service for ENCRYPTION_KEY.
+// ENCRYPTION_KEY keeps decrypting values written before envelope encryption was enabled
+// and keeps the key hashes stable.
+func NewServiceFromConfig(cfg types.EncryptionConfig, legacy Service) (Service, error) {
+	if cfg.Provider == "" {
+		return legacy, nil
+	}
+
+	provider, err := NewMasterKeyProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvelopeService(provider, legacy, cfg.DataKeyMaxUses)
+}
+
+// NewEnvelopeService creates an envelope encryption service. Values that are not envelope
+// ciphertexts are handled by legacy, which is also used for hashing.
+func NewEnvelopeService(provider MasterKeyProvider, legacy Service, dataKeyMaxUses int) (EnvelopeService, error) {
+	if dataKeyMaxUses < 1 {
+		dataKeyMaxUses = 1
+	}
+	s := &envelopeService{
+		provider:       provider,
+		legacy:         legacy,
+		dataKeyMaxUses: dataKeyMaxUses,
+		unwrapCache:    make(map[string]cipher.AEAD),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+	version, err := s.CurrentKeyVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s master key provider: %w", provider.Name(), err)
+	}
+	logrus.Debugf("Envelope encryption using %s master key version %d", provider.Name(), version)
+
+	// Create the first data key now, so the first record does not wait for the provider
+	if dataKeyMaxUses > 1 {
+		key, err := s.newDataKey(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data key with %s master key provider: %w", provider.Name(), err)
+		}
+		s.current = key
+	}
+	return s, nil
+}
+
+// dataKey is a data key ready for encryption, together with its wrapped form.
+type dataKey struct {
+	gcm       cipher.AEAD
+	version   uint32
+	wrapped   string
+	uses      int
+	createdAt time.Time
+}
+
+// envelopeService encrypts each record with a random data key wrapped by the master key provider.
+type envelopeService struct {
+	provider       MasterKeyProvider
+	legacy         Service
+	dataKeyMaxUses int
+
+	mu               sync.Mutex
+	current          *dataKey
+	cachedVersion    uint32
+	versionCheckedAt time.Time
+
+	// Data keys and the master key version are refreshed in the background before they expire,
+	// so encrypting, e.g. request logs on the proxy path, does not wait for the provider
+	refreshingKey     atomic.Bool
+	refreshingVersion atomic.Bool
+
+	cacheMu     sync.RWMutex
+	unwrapCache map[string]cipher.AEAD
+}
+
+func (s *envelopeService) ProviderName() string {
+	return s.provider.Name()
+}
+
+// CurrentKeyVersion returns the cached master key version. Once the cache is stale the cached
+// version is still returned while it is refreshed in the background.
+func (s *envelopeService) CurrentKeyVersion(ctx context.Context) (uint32, error) {
+	s.mu.Lock()
+	version, checkedAt := s.cachedVersion, s.versionCheckedAt
+	s.mu.Unlock()
+
+	if version == 0 {
+		return s.refreshVersion(ctx)
+	}
+	if time.Since(checkedAt) >= versionCacheTTL && s.refreshingVersion.CompareAndSwap(false, true) {
+		go func() {
+			defer s.refreshingVersion.Store(false)
+			ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+			defer cancel()
+			if _, err := s.refreshVersion(ctx); err != nil {
+				logrus.WithError(err).Warnf("Failed to refresh %s master key version", s.provider.Name())
+			}
+		}()
+	}
+	return version, nil
+}
+
+// refreshVersion reads the current master key version from the provider and caches it.
+func (s *envelopeService) refreshVersion(ctx context.Context) (uint32, error) {
+	version, err := s.provider.CurrentVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.cachedVersion = version
+	s.versionCheckedAt = time.Now()
+	s.mu.Unlock()
+	return version, nil
+}
+
+func (s *envelopeService) Encrypt(plaintext string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+
+	key, err := s.nextDataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, key.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := key.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return formatEnvelope(key.version, key.wrapped, base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+func (s *envelopeService) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return s.legacy.Decrypt(ciphertext)
+	}
+
+	version, wrapped, data, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+	gcm, err := s.unwrap(ctx, version, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid envelope data: %w", err)
+	}
+	nonceSize := gcm.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decryption failed: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Hash uses the legacy service so key hashes stay stable across master key rotations.
+func (s *envelopeService) Hash(plaintext string) string {
+	return s.legacy.Hash(plaintext)
+}
+
+func (s *envelopeService) Hashes(plaintext string) []string {
+	return HashCandidates(s.legacy, plaintext)
+}
+
+func (s *envelopeService) KeyVersion(ciphertext string) (uint32, bool) {
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return 0, false
+	}
+	version, _, _, err := parseEnvelope(ciphertext)
+	return version, err == nil
+}
+
+func (s *envelopeService) Rewrap(ctx context.Context, ciphertext string) (string, bool, error) {
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return ciphertext, false, nil
+	}
+
+	version, wrapped, data, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	current, err := s.CurrentKeyVersion(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if version == current {
+		return ciphertext, false, nil
+	}
+
+	wrappedBytes, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+	rawKey, err := s.provider.Unwrap(ctx, version, wrappedBytes)
+	if err != nil {
+		return "", false, err
+	}
+	newVersion, newWrapped, err := s.provider.Wrap(ctx, rawKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	return formatEnvelope(newVersion, base64.RawURLEncoding.EncodeToString(newWrapped), data), true, nil
+}
+
+// nextDataKey returns the data key for the next record, creating a new one when the current
+// key reached its use limit or age, or was wrapped with an outdated master key version.
+// A key reaching three quarters of its limits is replaced in the background.
+func (s *envelopeService) nextDataKey(ctx context.Context) (*dataKey, error) {
+	version, err := s.CurrentKeyVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if key := s.current; key != nil && key.version == version && key.uses < s.dataKeyMaxUses && time.Since(key.createdAt) < dataKeyMaxAge {
+		key.uses++
+		refresh := key.uses*4 >= s.dataKeyMaxUses*3 || time.Since(key.createdAt)*4 >= dataKeyMaxAge*3
+		s.mu.Unlock()
+		if refresh && s.refreshingKey.CompareAndSwap(false, true) {
+			go s.refreshDataKey()
+		}
+		return key, nil
+	}
+	s.mu.Unlock()
+
+	key, err := s.newDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key.uses = 1
+	if s.dataKeyMaxUses > 1 {
+		s.mu.Lock()
+		s.current = key
+		s.mu.Unlock()
+	}
+	return key, nil
+}
+
+// refreshDataKey replaces the current data key before it runs out.
+func (s *envelopeService) refreshDataKey() {
+	defer s.refreshingKey.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), providerRequestTimeout)
+	defer cancel()
+
+	key, err := s.newDataKey(ctx)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to refresh data key with %s master key provider", s.provider.Name())
+		return
+	}
+	s.mu.Lock()
+	s.current = key
+	s.mu.Unlock()
+}
+
+// newDataKey creates a random data key wrapped with the current master key version.
+func (s *envelopeService) newDataKey(ctx context.Context) (*dataKey, error) {
+	rawKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, rawKey); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedVersion, wrapped, err := s.provider.Wrap(ctx, rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataKey{
+		gcm:       gcm,
+		version:   wrappedVersion,
+		wrapped:   base64.RawURLEncoding.EncodeToString(wrapped),
+		createdAt: time.Now(),
+	}, nil
+}
+
+// unwrap returns the cipher for a wrapped data key, caching it to avoid repeated provider calls.
+func (s *envelopeService) unwrap(ctx context.Context, version uint32, wrapped string) (cipher.AEAD, error) {
+	cacheKey := strconv.FormatUint(uint64(version), 10) + ":" + wrapped
+	s.cacheMu.RLock()
+	gcm, ok := s.unwrapCache[cacheKey]
+	s.cacheMu.RUnlock()
+	if ok {
+		return gcm, nil
+	}
+
+	wrappedBytes, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+	rawKey, err := s.provider.Unwrap(ctx, version, wrappedBytes)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err = newGCM(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheMu.Lock()
+	if len(s.unwrapCache) >= unwrapCacheSize {
+		s.unwrapCache = make(map[string]cipher.AEAD)
+	}
+	s.unwrapCache[cacheKey] = gcm
+	s.cacheMu.Unlock()
+	return gcm, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func formatEnvelope(version uint32, wrapped, data string) string {
+	return EnvelopeVersionPrefix(version) + wrapped + ":" + data
+}
+
+func parseEnvelope(ciphertext string) (uint32, string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(ciphertext, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return 0, "", "", errors.New("invalid envelope ciphertext format")
+	}
+	version, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || version == 0 {
+		return 0, "", "", errors.New("invalid master key version in envelope ciphertext")
+	}
+	return uint32(version), parts[1], parts[2], nil
+}
@@ -0,0 +1,375 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gpt-load/internal/types"
+)
+
+// writeKeyFile writes a local master key file with a random key for each version.
+func writeKeyFile(t *testing.T, path string, versions ...uint32) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("# test master keys\n")
+	for _, version := range versions {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%d:%s\n", version, base64.StdEncoding.EncodeToString(key))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newLocalEnvelope(t *testing.T, path string, legacy Service, maxUses int) EnvelopeService {
+	t.Helper()
+	provider, err := newLocalProvider(path)
+	if err != nil {
+		t.Fatalf("newLocalProvider: %v", err)
+	}
+	svc, err := NewEnvelopeService(provider, legacy, maxUses)
+	if err != nil {
+		t.Fatalf("NewEnvelopeService: %v", err)
+	}
+	return svc
+}
+
+func wrappedKey(t *testing.T, ciphertext string) string {
+	t.Helper()
+	_, wrapped, _, err := parseEnvelope(ciphertext)
+	if err != nil {
+		t.Fatalf("parseEnvelope(%q): %v", ciphertext, err)
+	}
+	return wrapped
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.keys")
+	writeKeyFile(t, path, 1, 3, 2)
+
+	tests := []struct {
+		name           string
+		maxUses        int
+		plaintext      string
+		sharedDataKeys bool
+	}{
+		{name: "fresh data key per record", maxUses: 1, plaintext: "sk-test-1234567890"},
+		{name: "invalid limit means fresh data keys", maxUses: 0, plaintext: "sk-test-1234567890"},
+		{name: "empty plaintext", maxUses: 1, plaintext: ""},
+		{name: "unicode plaintext", maxUses: 1, plaintext: "密钥 🔑 キー"},
+		{name: "data key reuse opt-in", maxUses: 10, plaintext: "sk-test-1234567890", sharedDataKeys: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newLocalEnvelope(t, path, &noopService{}, tt.maxUses)
+
+			first, err := svc.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			second, err := svc.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+
+			if !strings.HasPrefix(first, EnvelopeVersionPrefix(3)) {
+				t.Errorf("ciphertext %q is not wrapped with the highest master key version", first)
+			}
+			if version, ok := svc.KeyVersion(first); !ok || version != 3 {
+				t.Errorf("KeyVersion = %d, %v, want 3, true", version, ok)
+			}
+			if first == second {
+				t.Error("two encryptions of the same plaintext produced the same ciphertext")
+			}
+			if shared := wrappedKey(t, first) == wrappedKey(t, second); shared != tt.sharedDataKeys {
+				t.Errorf("records share a data key = %v, want %v", shared, tt.sharedDataKeys)
+			}
+
+			for _, ciphertext := range []string{first, second} {
+				got, err := svc.Decrypt(ciphertext)
+				if err != nil {
+					t.Fatalf("Decrypt: %v", err)
+				}
+				if got != tt.plaintext {
+					t.Errorf("Decrypt = %q, want %q", got, tt.plaintext)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvelopeDecryptErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.keys")
+	writeKeyFile(t, path, 1)
+	svc := newLocalEnvelope(t, path, &noopService{}, 1)
+
+	valid, err := svc.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, wrapped, data, err := parseEnvelope(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.RawURLEncoding.DecodeString(data)
+	sealed[len(sealed)-1] ^= 0xff
+	tampered := base64.RawURLEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name       string
+		ciphertext string
+	}{
+		{name: "missing parts", ciphertext: envelopePrefix + "1:abc"},
+		{name: "version zero", ciphertext: formatEnvelope(0, wrapped, data)},
+		{name: "version not a number", ciphertext: envelopePrefix + "v1:" + wrapped + ":" + data},
+		{name: "unknown master key version", ciphertext: formatEnvelope(version+1, wrapped, data)},
+		{name: "wrapped key not base64", ciphertext: formatEnvelope(version, "!!", data)},
+		{name: "data not base64", ciphertext: formatEnvelope(version, wrapped, "!!")},
+		{name: "data too short", ciphertext: formatEnvelope(version, wrapped, "AAAA")},
+		{name: "tampered data", ciphertext: formatEnvelope(version, wrapped, tampered)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := svc.Decrypt(tt.ciphertext); err == nil {
+				t.Errorf("Decrypt(%q) = %q, want error", tt.ciphertext, got)
+			}
+		})
+	}
+}
+
+func TestEnvelopeLegacyFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.keys")
+	writeKeyFile(t, path, 1)
+	legacy, err := NewService("legacy-encryption-key-123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := newLocalEnvelope(t, path, legacy, 1)
+
+	legacyCiphertext, err := legacy.Encrypt("sk-legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Decrypt(legacyCiphertext)
+	if err != nil || got != "sk-legacy" {
+		t.Errorf("Decrypt of a legacy ciphertext = %q, %v, want sk-legacy", got, err)
+	}
+	if _, ok := svc.KeyVersion(legacyCiphertext); ok {
+		t.Error("KeyVersion reported a version for a legacy ciphertext")
+	}
+	if rewrapped, changed, err := svc.Rewrap(context.Background(), legacyCiphertext); err != nil || changed || rewrapped != legacyCiphertext {
+		t.Errorf("Rewrap of a legacy ciphertext = %q, %v, %v, want it unchanged", rewrapped, changed, err)
+	}
+	if svc.Hash("sk-legacy") != legacy.Hash("sk-legacy") {
+		t.Error("Hash does not match the legacy hash")
+	}
+}
+
+func TestEnvelopeRewrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.keys")
+	writeKeyFile(t, path, 1)
+	before := newLocalEnvelope(t, path, &noopService{}, 1)
+
+	oldCiphertext, err := before.Encrypt("sk-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Append a new master key version, as an operator rotating the key would
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	content = append(content, fmt.Sprintf("2:%s\n", base64.StdEncoding.EncodeToString(key))...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	after := newLocalEnvelope(t, path, &noopService{}, 1)
+
+	rewrapped, changed, err := after.Rewrap(context.Background(), oldCiphertext)
+	if err != nil || !changed {
+		t.Fatalf("Rewrap = %v, %v, want changed", changed, err)
+	}
+	if version, _ := after.KeyVersion(rewrapped); version != 2 {
+		t.Errorf("rewrapped version = %d, want 2", version)
+	}
+	if oldData, newData := oldCiphertext[strings.LastIndex(oldCiphertext, ":"):], rewrapped[strings.LastIndex(rewrapped, ":"):]; oldData != newData {
+		t.Error("Rewrap re-encrypted the record instead of only re-wrapping its data key")
+	}
+
+	for _, ciphertext := range []string{oldCiphertext, rewrapped} {
+		if got, err := after.Decrypt(ciphertext); err != nil || got != "sk-rotate" {
+			t.Errorf("Decrypt(%q) = %q, %v, want sk-rotate", ciphertext, got, err)
+		}
+	}
+
+	if again, changed, err := after.Rewrap(context.Background(), rewrapped); err != nil || changed || again != rewrapped {
+		t.Errorf("Rewrap of a current ciphertext = %v, %v, want it unchanged", changed, err)
+	}
+}
+
+// fakeVault implements the transit endpoints used by vaultProvider. Its "ciphertexts" are the
+// base64 plaintext tagged with the latest key version, which is enough to test the wrapping flow.
+func fakeVault(t *testing.T, token string, latestVersion uint32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		var body map[string]string
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var data map[string]any
+		switch r.URL.Path {
+		case "/v1/transit/keys/gpt-load":
+			data = map[string]any{"latest_version": latestVersion}
+		case "/v1/transit/encrypt/gpt-load":
+			data = map[string]any{"ciphertext": fmt.Sprintf("vault:v%d:%s", latestVersion, body["plaintext"])}
+		case "/v1/transit/decrypt/gpt-load":
+			parts := strings.SplitN(body["ciphertext"], ":", 3)
+			if len(parts) != 3 {
+				http.Error(w, `{"errors":["invalid ciphertext"]}`, http.StatusBadRequest)
+				return
+			}
+			data = map[string]any{"plaintext": parts[2]}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+}
+
+func TestVaultEnvelope(t *testing.T) {
+	server := fakeVault(t, "vault-token", 4)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid token", token: "vault-token"},
+		{name: "rejected token", token: "wrong-token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, err := NewServiceFromConfig(types.EncryptionConfig{
+				Provider:          "vault",
+				VaultAddr:         server.URL,
+				VaultToken:        tt.token,
+				VaultTransitMount: "transit",
+				VaultTransitKey:   "gpt-load",
+				DataKeyMaxUses:    DefaultDataKeyMaxUses,
+			}, &noopService{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewServiceFromConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			ciphertext, err := svc.Encrypt("sk-vault")
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if !strings.HasPrefix(ciphertext, EnvelopeVersionPrefix(4)) {
+				t.Errorf("ciphertext %q is not wrapped with vault key version 4", ciphertext)
+			}
+			if got, err := svc.Decrypt(ciphertext); err != nil || got != "sk-vault" {
+				t.Errorf("Decrypt = %q, %v, want sk-vault", got, err)
+			}
+		})
+	}
+}
+
+func TestParseVaultVersion(t *testing.T) {
+	tests := []struct {
+		ciphertext string
+		want       uint32
+		wantErr    bool
+	}{
+		{ciphertext: "vault:v1:abc", want: 1},
+		{ciphertext: "vault:v12:abc:def", want: 12},
+		{ciphertext: "vault:v0:abc", wantErr: true},
+		{ciphertext: "vault:1:abc", wantErr: true},
+		{ciphertext: "other:v1:abc", wantErr: true},
+		{ciphertext: "vault:v1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ciphertext, func(t *testing.T) {
+			got, err := parseVaultVersion(tt.ciphertext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVaultVersion(%q) error = %v, wantErr %v", tt.ciphertext, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseVaultVersion(%q) = %d, want %d", tt.ciphertext, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalProviderKeyFile(t *testing.T) {
+	validKey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name    string
+		content string
+		want    uint32
+		wantErr bool
+	}{
+		{name: "highest version is current", content: "1:" + validKey + "\n5:" + validKey + "\n3:" + validKey, want: 5},
+		{name: "comments and blank lines", content: "# keys\n\n 2 : " + validKey + "\n", want: 2},
+		{name: "no keys", content: "# nothing here\n", wantErr: true},
+		{name: "missing separator", content: validKey, wantErr: true},
+		{name: "version zero", content: "0:" + validKey, wantErr: true},
+		{name: "short key", content: "1:" + base64.StdEncoding.EncodeToString(make([]byte, 16)), wantErr: true},
+		{name: "duplicate version", content: "1:" + validKey + "\n1:" + validKey, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "master.keys")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			provider, err := newLocalProvider(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLocalProvider error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, _ := provider.CurrentVersion(context.Background()); got != tt.want {
+				t.Errorf("CurrentVersion = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"sync"
+)
+
+// KeyRing holds the service for ENCRYPTION_KEY and allows replacing it while running.
+// During a key rotation it also keeps the service of the previous key, so values written
+// before the rotation stay readable until they have been re-encrypted.
+type KeyRing struct {
+	mu       sync.RWMutex
+	current  Service
+	previous Service
+}
+
+// NewKeyRing creates a key ring for the given ENCRYPTION_KEY.
+func NewKeyRing(encryptionKey string) (*KeyRing, error) {
+	svc, err := NewService(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyRing{current: svc}, nil
+}
+
+// SetKeys replaces the keys of the ring. previous may be nil once no value uses it anymore.
+func (k *KeyRing) SetKeys(current, previous Service) {
+	k.mu.Lock()
+	k.current = current
+	k.previous = previous
+	k.mu.Unlock()
+}
+
+// Rotating reports whether a previous key is still accepted for decryption.
+func (k *KeyRing) Rotating() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.previous != nil
+}
+
+func (k *KeyRing) keys() (Service, Service) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current, k.previous
+}
+
+func (k *KeyRing) Encrypt(plaintext string) (string, error) {
+	current, _ := k.keys()
+	return current.Encrypt(plaintext)
+}
+
+// Decrypt tries the current key first and falls back to the previous key during a rotation.
+func (k *KeyRing) Decrypt(ciphertext string) (string, error) {
+	current, previous := k.keys()
+	plaintext, err := current.Decrypt(ciphertext)
+	if err == nil || previous == nil {
+		return plaintext, err
+	}
+	if plaintext, prevErr := previous.Decrypt(ciphertext); prevErr == nil {
+		return plaintext, nil
+	}
+	return "", err
+}
+
+func (k *KeyRing) Hash(plaintext string) string {
+	current, _ := k.keys()
+	return current.Hash(plaintext)
+}
+
+// Hashes returns the hash under the current key and, during a rotation, under the previous key.
+func (k *KeyRing) Hashes(plaintext string) []string {
+	current, previous := k.keys()
+	hashes := []string{current.Hash(plaintext)}
+	if previous != nil {
+		if hash := previous.Hash(plaintext); hash != hashes[0] {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// multiHasher is implemented by services that may have stored values hashed with more than one key.
+type multiHasher interface {
+	Hashes(plaintext string) []string
+}
+
+// HashCandidates returns every hash a stored value of plaintext may have. Lookups by key_hash
+// should match all of them, since rows are re-hashed gradually during a key rotation.
+func HashCandidates(svc Service, plaintext string) []string {
+	if plaintext == "" {
+		return nil
+	}
+	if hasher, ok := svc.(multiHasher); ok {
+		return hasher.Hashes(plaintext)
+	}
+	return []string{svc.Hash(plaintext)}
+}
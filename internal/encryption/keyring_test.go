@@ -0,0 +1,124 @@
+package encryption
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeyRingDecrypt(t *testing.T) {
+	oldKey, err := NewService("old-encryption-key-123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := NewService("new-encryption-key-654321")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, err := NewService("unrelated-encryption-key-000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt := func(svc Service) string {
+		ciphertext, err := svc.Encrypt("sk-ring")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertext
+	}
+
+	tests := []struct {
+		name       string
+		current    Service
+		previous   Service
+		ciphertext string
+		wantErr    bool
+	}{
+		{name: "current key", current: newKey, previous: oldKey, ciphertext: encrypt(newKey)},
+		{name: "previous key during rotation", current: newKey, previous: oldKey, ciphertext: encrypt(oldKey)},
+		{name: "previous key after rotation", current: newKey, ciphertext: encrypt(oldKey), wantErr: true},
+		{name: "unknown key", current: newKey, previous: oldKey, ciphertext: encrypt(unrelated), wantErr: true},
+		{name: "encryption being enabled", current: newKey, previous: &noopService{}, ciphertext: "sk-ring"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ring.SetKeys(tt.current, tt.previous)
+			if ring.Rotating() != (tt.previous != nil) {
+				t.Errorf("Rotating = %v, want %v", ring.Rotating(), tt.previous != nil)
+			}
+
+			got, err := ring.Decrypt(tt.ciphertext)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decrypt = %q, want error", got)
+				}
+				return
+			}
+			if err != nil || got != "sk-ring" {
+				t.Errorf("Decrypt = %q, %v, want sk-ring", got, err)
+			}
+		})
+	}
+}
+
+func TestKeyRingEncryptUsesCurrentKey(t *testing.T) {
+	oldKey, _ := NewService("old-encryption-key-123456")
+	newKey, _ := NewService("new-encryption-key-654321")
+	ring, err := NewKeyRing("old-encryption-key-123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring.SetKeys(newKey, oldKey)
+
+	ciphertext, err := ring.Encrypt("sk-ring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newKey.Decrypt(ciphertext); err != nil || got != "sk-ring" {
+		t.Errorf("current key Decrypt = %q, %v, want sk-ring", got, err)
+	}
+	if _, err := oldKey.Decrypt(ciphertext); err == nil {
+		t.Error("a new ciphertext is still readable with the previous key")
+	}
+}
+
+func TestKeyRingHashes(t *testing.T) {
+	oldKey, _ := NewService("old-encryption-key-123456")
+	newKey, _ := NewService("new-encryption-key-654321")
+
+	tests := []struct {
+		name     string
+		current  Service
+		previous Service
+		want     []string
+	}{
+		{name: "single key", current: newKey, want: []string{newKey.Hash("sk-ring")}},
+		{name: "rotation", current: newKey, previous: oldKey, want: []string{newKey.Hash("sk-ring"), oldKey.Hash("sk-ring")}},
+		{name: "same key twice", current: newKey, previous: newKey, want: []string{newKey.Hash("sk-ring")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ring.SetKeys(tt.current, tt.previous)
+			if got := HashCandidates(ring, "sk-ring"); !slices.Equal(got, tt.want) {
+				t.Errorf("HashCandidates = %v, want %v", got, tt.want)
+			}
+			if ring.Hash("sk-ring") != tt.want[0] {
+				t.Error("Hash does not use the current key")
+			}
+		})
+	}
+
+	if got := HashCandidates(newKey, ""); got != nil {
+		t.Errorf("HashCandidates of an empty value = %v, want nil", got)
+	}
+}
@@ -0,0 +1,89 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"gpt-load/internal/types"
+)
+
+// kmsProvider wraps data keys through a KMS-compatible HTTP API:
+//
+//	GET  {url}/keys/{key_id}          -> {"current_version": 2}
+//	POST {url}/keys/{key_id}/encrypt  {"plaintext": "<base64>"} -> {"ciphertext": "<base64>", "key_version": 2}
+//	POST {url}/keys/{key_id}/decrypt  {"ciphertext": "<base64>", "key_version": 1} -> {"plaintext": "<base64>"}
+//
+// Requests are authenticated with a bearer token when ENCRYPTION_KMS_TOKEN is set.
+type kmsProvider struct {
+	client  *http.Client
+	keyURL  string
+	headers map[string]string
+}
+
+func newKMSProvider(cfg types.EncryptionConfig) *kmsProvider {
+	headers := map[string]string{}
+	if cfg.KMSToken != "" {
+		headers["Authorization"] = "Bearer " + cfg.KMSToken
+	}
+	return &kmsProvider{
+		client:  &http.Client{Timeout: providerRequestTimeout},
+		keyURL:  fmt.Sprintf("%s/keys/%s", cfg.KMSURL, url.PathEscape(cfg.KMSKeyID)),
+		headers: headers,
+	}
+}
+
+func (p *kmsProvider) Name() string {
+	return "kms"
+}
+
+func (p *kmsProvider) CurrentVersion(ctx context.Context) (uint32, error) {
+	var resp struct {
+		CurrentVersion uint32 `json:"current_version"`
+	}
+	if err := doJSON(ctx, p.client, http.MethodGet, p.keyURL, p.headers, nil, &resp); err != nil {
+		return 0, fmt.Errorf("kms: failed to read key: %w", err)
+	}
+	if resp.CurrentVersion == 0 {
+		return 0, fmt.Errorf("kms: key has no current version")
+	}
+	return resp.CurrentVersion, nil
+}
+
+func (p *kmsProvider) Wrap(ctx context.Context, dataKey []byte) (uint32, []byte, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+		KeyVersion uint32 `json:"key_version"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := doJSON(ctx, p.client, http.MethodPost, p.keyURL+"/encrypt", p.headers, body, &resp); err != nil {
+		return 0, nil, fmt.Errorf("kms: failed to wrap data key: %w", err)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(resp.Ciphertext)
+	if err != nil || resp.KeyVersion == 0 {
+		return 0, nil, fmt.Errorf("kms: invalid encrypt response")
+	}
+	return resp.KeyVersion, wrapped, nil
+}
+
+func (p *kmsProvider) Unwrap(ctx context.Context, version uint32, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	body := map[string]any{
+		"ciphertext":  base64.StdEncoding.EncodeToString(wrapped),
+		"key_version": version,
+	}
+	if err := doJSON(ctx, p.client, http.MethodPost, p.keyURL+"/decrypt", p.headers, body, &resp); err != nil {
+		return nil, fmt.Errorf("kms: failed to unwrap data key: %w", err)
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("kms: invalid plaintext in decrypt response: %w", err)
+	}
+	return dataKey, nil
+}
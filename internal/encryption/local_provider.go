@@ -0,0 +1,187 @@
+package encryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// localProvider reads versioned master keys from a file. Each line has the form
+// "<version>:<base64 encoded 32 byte key>", lines starting with # are ignored and
+// the highest version is current. The file is reloaded when it changes, so a new
+// key can be appended without a restart.
+type localProvider struct {
+	path string
+
+	mu       sync.RWMutex
+	keys     map[uint32]cipher.AEAD
+	current  uint32
+	modTime  time.Time
+	lastStat time.Time
+}
+
+// localReloadInterval limits how often the key file is checked for changes
+const localReloadInterval = 10 * time.Second
+
+func newLocalProvider(path string) (*localProvider, error) {
+	p := &localProvider{path: path}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *localProvider) Name() string {
+	return "local"
+}
+
+func (p *localProvider) CurrentVersion(ctx context.Context) (uint32, error) {
+	p.reloadIfChanged()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.current, nil
+}
+
+func (p *localProvider) Wrap(ctx context.Context, dataKey []byte) (uint32, []byte, error) {
+	p.reloadIfChanged()
+	p.mu.RLock()
+	version := p.current
+	gcm := p.keys[version]
+	p.mu.RUnlock()
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return 0, nil, err
+	}
+	return version, gcm.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func (p *localProvider) Unwrap(ctx context.Context, version uint32, wrapped []byte) ([]byte, error) {
+	p.mu.RLock()
+	gcm, ok := p.keys[version]
+	p.mu.RUnlock()
+	if !ok {
+		// The version may have been added by another node since the last reload
+		p.reloadIfChanged()
+		p.mu.RLock()
+		gcm, ok = p.keys[version]
+		p.mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("master key version %d not found in %s", version, p.path)
+		}
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped data key too short")
+	}
+	dataKey, err := gcm.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key version %d: %w", version, err)
+	}
+	return dataKey, nil
+}
+
+// reloadIfChanged reloads the key file if its modification time changed.
+// A file that became unreadable keeps the previously loaded keys.
+func (p *localProvider) reloadIfChanged() {
+	p.mu.RLock()
+	recent := time.Since(p.lastStat) < localReloadInterval
+	p.mu.RUnlock()
+	if recent {
+		return
+	}
+
+	info, err := os.Stat(p.path)
+	p.mu.Lock()
+	p.lastStat = time.Now()
+	changed := err == nil && !info.ModTime().Equal(p.modTime)
+	p.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if err := p.load(); err != nil {
+		// Keep serving the keys loaded before, the file may be in the middle of an edit
+		logrus.WithError(err).Errorf("Failed to reload master keys from %s", p.path)
+	}
+}
+
+func (p *localProvider) load() error {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to open master key file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat master key file: %w", err)
+	}
+
+	keys := make(map[uint32]cipher.AEAD)
+	var current uint32
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		versionStr, encodedKey, found := strings.Cut(line, ":")
+		if !found {
+			return fmt.Errorf("master key file line %d: expected <version>:<base64 key>", lineNumber)
+		}
+		version, err := strconv.ParseUint(strings.TrimSpace(versionStr), 10, 32)
+		if err != nil || version == 0 {
+			return fmt.Errorf("master key file line %d: version must be a positive integer", lineNumber)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("master key file line %d: key must be 32 bytes encoded as base64", lineNumber)
+		}
+		if _, exists := keys[uint32(version)]; exists {
+			return fmt.Errorf("master key file line %d: duplicate version %d", lineNumber, version)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		keys[uint32(version)] = gcm
+		if uint32(version) > current {
+			current = uint32(version)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read master key file: %w", err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("master key file %s contains no keys", p.path)
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.current = current
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
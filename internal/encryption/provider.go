@@ -0,0 +1,90 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gpt-load/internal/types"
+)
+
+// providerRequestTimeout bounds each call to a remote master key provider
+const providerRequestTimeout = 10 * time.Second
+
+// MasterKeyProvider wraps and unwraps data keys with a versioned master key.
+// Implementations must keep older versions available for unwrapping until all
+// data keys have been re-wrapped with the current version.
+type MasterKeyProvider interface {
+	// Name returns the provider type, e.g. "local" or "vault".
+	Name() string
+	// CurrentVersion returns the master key version used for new data keys.
+	CurrentVersion(ctx context.Context) (uint32, error)
+	// Wrap encrypts a data key with the current master key and returns the version used.
+	Wrap(ctx context.Context, dataKey []byte) (uint32, []byte, error)
+	// Unwrap decrypts a data key wrapped with the given master key version.
+	Unwrap(ctx context.Context, version uint32, wrapped []byte) ([]byte, error)
+}
+
+// NewMasterKeyProvider creates the provider selected by ENCRYPTION_PROVIDER.
+func NewMasterKeyProvider(cfg types.EncryptionConfig) (MasterKeyProvider, error) {
+	switch cfg.Provider {
+	case "local":
+		return newLocalProvider(cfg.LocalKeyFile)
+	case "vault":
+		return newVaultProvider(cfg), nil
+	case "kms":
+		return newKMSProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported encryption provider: %s", cfg.Provider)
+	}
+}
+
+// doJSON sends a JSON request to a remote provider and decodes the JSON response into out.
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned status %d: %s", method, url, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gpt-load/internal/types"
+)
+
+// vaultProvider wraps data keys with a HashiCorp Vault transit key.
+// Vault manages the key versions itself and embeds them in its ciphertexts ("vault:v3:...").
+type vaultProvider struct {
+	client  *http.Client
+	baseURL string
+	keyName string
+	headers map[string]string
+}
+
+func newVaultProvider(cfg types.EncryptionConfig) *vaultProvider {
+	return &vaultProvider{
+		client:  &http.Client{Timeout: providerRequestTimeout},
+		baseURL: fmt.Sprintf("%s/v1/%s", cfg.VaultAddr, cfg.VaultTransitMount),
+		keyName: cfg.VaultTransitKey,
+		headers: map[string]string{
+			"X-Vault-Token":     cfg.VaultToken,
+			"X-Vault-Namespace": cfg.VaultNamespace,
+		},
+	}
+}
+
+func (p *vaultProvider) Name() string {
+	return "vault"
+}
+
+func (p *vaultProvider) keyURL(action string) string {
+	return fmt.Sprintf("%s/%s/%s", p.baseURL, action, url.PathEscape(p.keyName))
+}
+
+func (p *vaultProvider) CurrentVersion(ctx context.Context) (uint32, error) {
+	var resp struct {
+		Data struct {
+			LatestVersion uint32 `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, p.client, http.MethodGet, p.keyURL("keys"), p.headers, nil, &resp); err != nil {
+		return 0, fmt.Errorf("vault: failed to read transit key: %w", err)
+	}
+	if resp.Data.LatestVersion == 0 {
+		return 0, fmt.Errorf("vault: transit key has no versions")
+	}
+	return resp.Data.LatestVersion, nil
+}
+
+func (p *vaultProvider) Wrap(ctx context.Context, dataKey []byte) (uint32, []byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := doJSON(ctx, p.client, http.MethodPost, p.keyURL("encrypt"), p.headers, body, &resp); err != nil {
+		return 0, nil, fmt.Errorf("vault: failed to wrap data key: %w", err)
+	}
+
+	version, err := parseVaultVersion(resp.Data.Ciphertext)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultProvider) Unwrap(ctx context.Context, version uint32, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"ciphertext": string(wrapped)}
+	if err := doJSON(ctx, p.client, http.MethodPost, p.keyURL("decrypt"), p.headers, body, &resp); err != nil {
+		return nil, fmt.Errorf("vault: failed to unwrap data key: %w", err)
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault: invalid plaintext in decrypt response: %w", err)
+	}
+	return dataKey, nil
+}
+
+// parseVaultVersion extracts the key version from a transit ciphertext such as "vault:v3:...".
+func parseVaultVersion(ciphertext string) (uint32, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, fmt.Errorf("vault: unexpected ciphertext format")
+	}
+	version, err := strconv.ParseUint(parts[1][1:], 10, 32)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("vault: invalid key version in ciphertext")
+	}
+	return uint32(version), nil
+}
@@ -38,6 +38,11 @@ var (
 	ErrNoActiveKeys       = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_ACTIVE_KEYS", Message: "No active API keys available for this group"}
 	ErrMaxRetriesExceeded = &APIError{HTTPStatus: http.StatusBadGateway, Code: "MAX_RETRIES_EXCEEDED", Message: "Request failed after maximum retries"}
 	ErrNoKeysAvailable    = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_KEYS_AVAILABLE", Message: "No API keys available to process the request"}
+	ErrModelNotSupported  = &APIError{HTTPStatus: http.StatusNotFound, Code: "MODEL_NOT_SUPPORTED", Message: "The requested model is not supported by this group"}
+	ErrQuotaExceeded      = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "QUOTA_EXCEEDED", Message: "The group has exceeded its spend limit"}
+	ErrTooManyAttempts    = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "TOO_MANY_ATTEMPTS", Message: "Too many failed authentication attempts, please try again later"}
+	ErrRateLimited        = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "RATE_LIMITED", Message: "Rate limit exceeded, please try again later"}
+	ErrGroupUnavailable   = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "GROUP_UNAVAILABLE", Message: "The group is not accepting requests"}
 )
 
 // NewAPIError creates a new APIError with a custom message.
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/middleware"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logout revokes the current session and clears the SSO session cookie
+func (s *Server) Logout(c *gin.Context) {
+	credential := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if cookie, err := c.Cookie(services.AdminSessionCookie); err == nil {
+		if err := s.AdminAuthService.Logout(cookie); err != nil {
+			response.Error(c, app_errors.ErrInternalServer)
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(services.AdminSessionCookie, "", -1, "/", "", s.OIDCService.SecureCookies(), true)
+	}
+
+	if err := s.AdminAuthService.Logout(credential); err != nil {
+		response.Error(c, app_errors.ErrInternalServer)
+		return
+	}
+
+	response.SuccessI18n(c, "auth.logout_success", nil)
+}
+
+// GetCurrentAdmin returns the principal of the current request
+func (s *Server) GetCurrentAdmin(c *gin.Context) {
+	response.Success(c, middleware.GetAdminPrincipal(c))
+}
+
+// ListAdminUsers handles listing admin users
+func (s *Server) ListAdminUsers(c *gin.Context) {
+	users, err := s.AdminAuthService.ListUsers(c.Request.Context())
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, users)
+}
+
+// CreateAdminUser handles creating an admin user
+func (s *Server) CreateAdminUser(c *gin.Context) {
+	var req services.AdminUserParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	user, err := s.AdminAuthService.CreateUser(c.Request.Context(), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	s.recordAdminAudit(c, "admin_user.create", user.ID, user.Username, map[string]any{"role": user.Role, "enabled": user.Enabled})
+
+	response.Success(c, user)
+}
+
+// UpdateAdminUser handles updating the password, role or state of an admin user
+func (s *Server) UpdateAdminUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_admin_user_id")
+		return
+	}
+
+	var req services.AdminUserParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	user, err := s.AdminAuthService.UpdateUser(c.Request.Context(), uint(id), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	s.recordAdminAudit(c, "admin_user.update", user.ID, user.Username, map[string]any{
+		"role":             user.Role,
+		"enabled":          user.Enabled,
+		"password_changed": req.Password != nil && *req.Password != "",
+	})
+
+	response.Success(c, user)
+}
+
+// DeleteAdminUser handles deleting an admin user
+func (s *Server) DeleteAdminUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_admin_user_id")
+		return
+	}
+
+	if s.handleGroupError(c, s.AdminAuthService.DeleteUser(c.Request.Context(), uint(id))) {
+		return
+	}
+
+	s.recordAdminAudit(c, "admin_user.delete", id, "", nil)
+
+	response.SuccessI18n(c, "success.admin_user_deleted", nil)
+}
+
+// ListAdminTokens handles listing API tokens visible to the current principal
+func (s *Server) ListAdminTokens(c *gin.Context) {
+	tokens, err := s.AdminAuthService.ListTokens(c.Request.Context(), middleware.GetAdminPrincipal(c))
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateAdminToken handles issuing an API token. The plain token is only returned here.
+func (s *Server) CreateAdminToken(c *gin.Context) {
+	var req services.AdminTokenParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	token, err := s.AdminAuthService.CreateToken(c.Request.Context(), middleware.GetAdminPrincipal(c), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	s.recordAdminAudit(c, "admin_token.create", token.ID, token.Name, map[string]any{
+		"prefix":     token.TokenPrefix,
+		"role":       token.Role,
+		"expires_at": token.ExpiresAt,
+	})
+
+	response.Success(c, token)
+}
+
+// DeleteAdminToken handles revoking an API token
+func (s *Server) DeleteAdminToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_admin_token_id")
+		return
+	}
+
+	if s.handleGroupError(c, s.AdminAuthService.DeleteToken(c.Request.Context(), middleware.GetAdminPrincipal(c), uint(id))) {
+		return
+	}
+
+	s.recordAdminAudit(c, "admin_token.delete", id, "", nil)
+
+	response.SuccessI18n(c, "success.admin_token_deleted", nil)
+}
+
+// recordAdminAudit records a change to admin accounts or tokens in the audit log
+func (s *Server) recordAdminAudit(c *gin.Context, action string, targetID any, targetName string, details map[string]any) {
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     action,
+		TargetType: models.AuditTargetAdmin,
+		TargetID:   targetID,
+		TargetName: targetName,
+		After:      details,
+	})
+}
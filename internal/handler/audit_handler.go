@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/i18n"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetAuditLogs handles fetching audit logs with filters and pagination
+func (s *Server) GetAuditLogs(c *gin.Context) {
+	query := s.AuditService.GetAuditLogsQuery(c).Order("timestamp desc")
+
+	var logs []models.AuditLog
+	pagination, err := response.Paginate(c, query, &logs)
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	pagination.Items = logs
+	response.Success(c, pagination)
+}
+
+// ExportAuditLogs handles exporting filtered audit logs to a CSV file
+func (s *Server) ExportAuditLogs(c *gin.Context) {
+	filename := fmt.Sprintf("audit_logs_export_%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	if err := s.AuditService.StreamAuditLogsToCSV(c, c.Writer); err != nil {
+		logrus.WithError(err).Error("Failed to stream audit logs to CSV")
+		c.JSON(500, gin.H{"error": i18n.Message(c, "error.export_audit_logs")})
+		return
+	}
+}
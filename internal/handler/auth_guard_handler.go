@@ -0,0 +1,38 @@
+package handler
+
+import (
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthGuardStatus is the response of GetAuthGuardStatus
+type AuthGuardStatus struct {
+	Stats services.AuthGuardStats `json:"stats"`
+	Bans  []services.AuthBan      `json:"bans"`
+}
+
+// GetAuthGuardStatus handles listing the active authentication bans together with the failure counters
+func (s *Server) GetAuthGuardStatus(c *gin.Context) {
+	response.Success(c, AuthGuardStatus{
+		Stats: s.AuthGuardService.Stats(),
+		Bans:  s.AuthGuardService.ListBans(),
+	})
+}
+
+// DeleteAuthBan handles lifting an authentication ban, identified by the subject query parameter
+func (s *Server) DeleteAuthBan(c *gin.Context) {
+	subject := c.Query("subject")
+	if subject == "" {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "auth_guard.subject_required")
+		return
+	}
+
+	if s.handleGroupError(c, s.AuthGuardService.Unban(c.Request.Context(), subject)) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.auth_ban_removed", nil)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/i18n"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxConfigDocumentSize limits the size of an imported configuration document.
+const maxConfigDocumentSize = 32 << 20
+
+// ExportConfig handles downloading the settings and groups as a YAML or JSON configuration document.
+// Keys are only included with include_keys=true, in their encrypted form.
+func (s *Server) ExportConfig(c *gin.Context) {
+	format := c.DefaultQuery("format", services.ConfigFormatYAML)
+	if format != services.ConfigFormatYAML && format != services.ConfigFormatJSON {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_config_format")
+		return
+	}
+
+	doc, err := s.ConfigService.Export(c.Request.Context(), c.Query("include_keys") == "true")
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	data, err := services.MarshalConfigDocument(doc, format)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal config document")
+		response.Error(c, app_errors.ErrInternalServer)
+		return
+	}
+
+	filename := fmt.Sprintf("gpt_load_config_%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	contentType := "application/yaml; charset=utf-8"
+	if format == services.ConfigFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Data(200, contentType, data)
+}
+
+// ImportConfig handles reconciling the database with a YAML or JSON configuration document sent as
+// the request body. With dry_run=true only the changes are returned; with prune=true groups and keys
+// missing from the document are deleted.
+func (s *Server) ImportConfig(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConfigDocumentSize+1))
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		return
+	}
+	if len(data) > maxConfigDocumentSize {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.config_document_too_large")
+		return
+	}
+
+	doc, err := services.ParseConfigDocument(data)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	// 拒绝会把当前管理员自己拦截在外的管理端 IP 规则
+	if len(doc.Settings) > 0 && !adminIPRulesKeepAccess(s.SettingsManager.GetSettings(), doc.Settings, c.ClientIP()) {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "settings.admin_ip_lockout", map[string]any{"ip": c.ClientIP()})
+		return
+	}
+
+	prune := c.Query("prune") == "true"
+	var plan *services.ConfigPlan
+	if c.Query("dry_run") == "true" {
+		plan, err = s.ConfigService.Plan(c.Request.Context(), doc, prune)
+	} else {
+		plan, err = s.ConfigService.Apply(c.Request.Context(), doc, prune)
+	}
+	if err != nil {
+		s.handleConfigError(c, err)
+		return
+	}
+
+	response.Success(c, plan)
+}
+
+// handleConfigError reports the group a configuration error belongs to along with the translated cause.
+func (s *Server) handleConfigError(c *gin.Context, err error) {
+	var groupErr *services.ConfigGroupError
+	if !errors.As(err, &groupErr) {
+		s.handleGroupError(c, err)
+		return
+	}
+
+	apiErr := app_errors.ErrValidation
+	var cause string
+	var i18nErr *services.I18nError
+	var innerAPIErr *app_errors.APIError
+	switch {
+	case errors.As(groupErr.Err, &i18nErr):
+		apiErr = i18nErr.APIError
+		cause = i18n.Message(c, i18nErr.MessageID, i18nErr.Template)
+	case errors.As(groupErr.Err, &innerAPIErr):
+		apiErr = innerAPIErr
+		cause = innerAPIErr.Message
+	default:
+		if errors.Is(groupErr.Err, services.ErrInvalidKeyMetadata) {
+			cause = groupErr.Err.Error()
+			break
+		}
+		logrus.WithContext(c.Request.Context()).WithError(err).Error("unexpected config import error")
+		apiErr = app_errors.ErrInternalServer
+		cause = apiErr.Message
+	}
+
+	response.ErrorI18nFromAPIError(c, apiErr, "validation.config_group_invalid", map[string]any{
+		"group": groupErr.Group,
+		"error": cause,
+	})
+}
@@ -88,6 +88,16 @@ func (s *Server) Stats(c *gin.Context) {
 		errorRateTrendIsGrowth = true
 	}
 
+	// 计算费用趋势
+	costTrend := 0.0
+	costTrendIsGrowth := true
+	if previousPeriod.TotalCost > 0 {
+		costTrend = (currentPeriod.TotalCost - previousPeriod.TotalCost) / previousPeriod.TotalCost * 100
+		costTrendIsGrowth = costTrend >= 0
+	} else if currentPeriod.TotalCost > 0 {
+		costTrend = 100.0
+	}
+
 	// 获取安全警告信息
 	securityWarnings := s.getSecurityWarnings(c)
 
@@ -108,6 +118,11 @@ func (s *Server) Stats(c *gin.Context) {
 			Trend:         errorRateTrend,
 			TrendIsGrowth: errorRateTrendIsGrowth,
 		},
+		Cost: models.StatCard{
+			Value:         currentPeriod.TotalCost,
+			Trend:         costTrend,
+			TrendIsGrowth: costTrendIsGrowth,
+		},
 		SecurityWarnings: securityWarnings,
 	}
 
@@ -181,9 +196,58 @@ func (s *Server) Chart(c *gin.Context) {
 	response.Success(c, chartData)
 }
 
+// CostChart Get dashboard cost chart data
+func (s *Server) CostChart(c *gin.Context) {
+	groupID := c.Query("groupId")
+
+	now := time.Now()
+	endHour := now.Truncate(time.Hour)
+	startHour := endHour.Add(-23 * time.Hour)
+
+	var hourlyStats []models.GroupHourlyStat
+	query := s.DB.Table("group_hourly_stats").
+		Where("time >= ? AND time < ?", startHour, endHour.Add(time.Hour))
+	if groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else {
+		query = query.Where("group_id NOT IN (?)",
+			s.DB.Table("groups").Select("id").Where("group_type = ?", "aggregate"))
+	}
+	if err := query.Order("time asc").Find(&hourlyStats).Error; err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrDatabase, "database.chart_data_failed")
+		return
+	}
+
+	costByHour := make(map[time.Time]float64)
+	for _, stat := range hourlyStats {
+		hour := stat.Time.Local().Truncate(time.Hour)
+		costByHour[hour] += stat.Cost
+	}
+
+	var labels []string
+	var costData []float64
+	for i := range 24 {
+		hour := startHour.Add(time.Duration(i) * time.Hour)
+		labels = append(labels, hour.Format(time.RFC3339))
+		costData = append(costData, costByHour[hour])
+	}
+
+	response.Success(c, models.CostChartData{
+		Labels: labels,
+		Datasets: []models.CostChartDataset{
+			{
+				Label: i18n.Message(c, "dashboard.cost"),
+				Data:  costData,
+				Color: "rgba(240, 160, 32, 1)",
+			},
+		},
+	})
+}
+
 type hourlyStatResult struct {
 	TotalRequests int64
 	TotalFailures int64
+	TotalCost     float64
 }
 
 func (s *Server) getHourlyStats(startTime, endTime time.Time) (hourlyStatResult, error) {
@@ -192,7 +256,7 @@ func (s *Server) getHourlyStats(startTime, endTime time.Time) (hourlyStatResult,
 		Where("time >= ? AND time < ?", startTime, endTime).
 		Where("group_id NOT IN (?)",
 			s.DB.Table("groups").Select("id").Where("group_type = ?", "aggregate")).
-		Select("COALESCE(SUM(success_count), 0) + COALESCE(SUM(failure_count), 0) as total_requests, COALESCE(SUM(failure_count), 0) as total_failures").
+		Select("COALESCE(SUM(success_count), 0) + COALESCE(SUM(failure_count), 0) as total_requests, COALESCE(SUM(failure_count), 0) as total_failures, COALESCE(SUM(cost), 0) as total_cost").
 		Scan(&result).Error
 	return result, err
 }
@@ -416,6 +480,11 @@ func (s *Server) EncryptionStatus(c *gin.Context) {
 
 // checkEncryptionMismatch detects encryption configuration mismatches
 func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string, string) {
+	// Records use both keys until an online key rotation completes
+	if s.KeyRotationService.InProgress() {
+		return false, ScenarioNone, "", ""
+	}
+
 	encryptionKey := s.config.GetEncryptionKey()
 
 	// Sample check API keys
@@ -448,20 +517,18 @@ func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string,
 
 	unencryptedConsistencyRate := float64(unencryptedHashMatchCount) / float64(len(sampleKeys))
 
-	// If ENCRYPTION_KEY is configured, also check if current key can decrypt the data
+	// If encryption is configured, also check if the current service can decrypt the data
+	_, envelopeEnabled := s.EncryptionSvc.(encryption.EnvelopeService)
 	var currentKeyHashMatchCount int
-	if encryptionKey != "" {
-		currentService, err := encryption.NewService(encryptionKey)
-		if err == nil {
-			for _, key := range sampleKeys {
-				// Try to decrypt and re-hash to check if current key matches
-				decrypted, err := currentService.Decrypt(key.KeyValue)
-				if err == nil {
-					// Successfully decrypted, check if hash matches
-					expectedHash := currentService.Hash(decrypted)
-					if expectedHash == key.KeyHash {
-						currentKeyHashMatchCount++
-					}
+	if encryptionKey != "" || envelopeEnabled {
+		for _, key := range sampleKeys {
+			// Try to decrypt and re-hash to check if current key matches
+			decrypted, err := s.EncryptionSvc.Decrypt(key.KeyValue)
+			if err == nil {
+				// Successfully decrypted, check if hash matches
+				expectedHash := s.EncryptionSvc.Hash(decrypted)
+				if expectedHash == key.KeyHash {
+					currentKeyHashMatchCount++
 				}
 			}
 		}
@@ -477,7 +544,7 @@ func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string,
 	}
 
 	// Scenario B: ENCRYPTION_KEY not configured but data is encrypted
-	if encryptionKey == "" && unencryptedConsistencyRate < 0.2 {
+	if encryptionKey == "" && !envelopeEnabled && unencryptedConsistencyRate < 0.2 {
 		return true,
 			ScenarioKeyNotConfigured,
 			i18n.Message(c, "dashboard.data_encrypted_but_key_not_configured"),
@@ -485,7 +552,7 @@ func (s *Server) checkEncryptionMismatch(c *gin.Context) (bool, string, string,
 	}
 
 	// Scenario C: ENCRYPTION_KEY configured but doesn't match encrypted data
-	if encryptionKey != "" && unencryptedConsistencyRate < 0.2 && currentKeyConsistencyRate < 0.2 {
+	if (encryptionKey != "" || envelopeEnabled) && unencryptedConsistencyRate < 0.2 && currentKeyConsistencyRate < 0.2 {
 		return true,
 			ScenarioKeyMismatch,
 			i18n.Message(c, "dashboard.encryption_key_mismatch"),
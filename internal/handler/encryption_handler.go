@@ -0,0 +1,49 @@
+package handler
+
+import (
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetKeyRewrapStatus handles reading the master key version and re-wrap progress
+func (s *Server) GetKeyRewrapStatus(c *gin.Context) {
+	status, err := s.KeyRewrapService.GetStatus(c.Request.Context())
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// StartKeyRewrap handles starting a re-wrap of data keys with the current master key version
+func (s *Server) StartKeyRewrap(c *gin.Context) {
+	if s.handleGroupError(c, s.KeyRewrapService.TriggerRewrap(c.Request.Context())) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.encryption_rewrap_started", nil)
+}
+
+// GetEncryptionKeyRotationStatus handles reading the progress of an online ENCRYPTION_KEY rotation
+func (s *Server) GetEncryptionKeyRotationStatus(c *gin.Context) {
+	response.Success(c, s.KeyRotationService.GetStatus())
+}
+
+// StartEncryptionKeyRotation handles switching to a new ENCRYPTION_KEY while the service keeps running
+func (s *Server) StartEncryptionKeyRotation(c *gin.Context) {
+	var req services.EncryptionKeyRotationParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	status, err := s.KeyRotationService.StartRotation(c.Request.Context(), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.encryption_key_rotation_started", status)
+}
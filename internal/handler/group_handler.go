@@ -62,6 +62,7 @@ type GroupCreateRequest struct {
 	ModelRedirectStrict bool                `json:"model_redirect_strict"`
 	Config              map[string]any      `json:"config"`
 	HeaderRules         []models.HeaderRule `json:"header_rules"`
+	Budgets             []models.BudgetRule `json:"budgets"`
 	ProxyKeys           string              `json:"proxy_keys"`
 }
 
@@ -88,6 +89,7 @@ func (s *Server) CreateGroup(c *gin.Context) {
 		ModelRedirectStrict: req.ModelRedirectStrict,
 		Config:              req.Config,
 		HeaderRules:         req.HeaderRules,
+		Budgets:             req.Budgets,
 		ProxyKeys:           req.ProxyKeys,
 	}
 
@@ -131,6 +133,7 @@ type GroupUpdateRequest struct {
 	ModelRedirectStrict *bool               `json:"model_redirect_strict"`
 	Config              map[string]any      `json:"config"`
 	HeaderRules         []models.HeaderRule `json:"header_rules"`
+	Budgets             []models.BudgetRule `json:"budgets"`
 	ProxyKeys           *string             `json:"proxy_keys,omitempty"`
 }
 
@@ -209,6 +212,11 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		params.HeaderRules = &rules
 	}
 
+	if req.Budgets != nil {
+		budgets := req.Budgets
+		params.Budgets = &budgets
+	}
+
 	group, err := s.GroupService.UpdateGroup(c.Request.Context(), uint(id), params)
 	if s.handleGroupError(c, err) {
 		return
@@ -217,6 +225,40 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 	response.Success(c, s.newGroupResponse(group))
 }
 
+// GroupStateRequest defines the payload for changing the state of a group.
+// The message and status code are served to new requests while the group is not enabled.
+type GroupStateRequest struct {
+	State      string `json:"state" binding:"required"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
+
+// UpdateGroupState handles enabling, draining, disabling or putting a group under maintenance.
+func (s *Server) UpdateGroupState(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	var req GroupStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	group, err := s.GroupService.UpdateGroupState(c.Request.Context(), uint(id), services.GroupStateParams{
+		State:      req.State,
+		Message:    req.Message,
+		StatusCode: req.StatusCode,
+	})
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, s.newGroupResponse(group))
+}
+
 // ReorderGroups handles batch reorder updates for groups.
 func (s *Server) ReorderGroups(c *gin.Context) {
 	var req GroupReorderRequest
@@ -246,26 +288,32 @@ func (s *Server) ReorderGroups(c *gin.Context) {
 
 // GroupResponse defines the structure for a group response, excluding sensitive or large fields.
 type GroupResponse struct {
-	ID                  uint                `json:"id"`
-	Name                string              `json:"name"`
-	Endpoint            string              `json:"endpoint"`
-	DisplayName         string              `json:"display_name"`
-	Description         string              `json:"description"`
-	GroupType           string              `json:"group_type"`
-	Upstreams           datatypes.JSON      `json:"upstreams"`
-	ChannelType         string              `json:"channel_type"`
-	Sort                int                 `json:"sort"`
-	TestModel           string              `json:"test_model"`
-	ValidationEndpoint  string              `json:"validation_endpoint"`
-	ParamOverrides      datatypes.JSONMap   `json:"param_overrides"`
-	ModelRedirectRules  datatypes.JSONMap   `json:"model_redirect_rules"`
-	ModelRedirectStrict bool                `json:"model_redirect_strict"`
-	Config              datatypes.JSONMap   `json:"config"`
-	HeaderRules         []models.HeaderRule `json:"header_rules"`
-	ProxyKeys           string              `json:"proxy_keys"`
-	LastValidatedAt     *time.Time          `json:"last_validated_at"`
-	CreatedAt           time.Time           `json:"created_at"`
-	UpdatedAt           time.Time           `json:"updated_at"`
+	ID                    uint                `json:"id"`
+	Name                  string              `json:"name"`
+	Endpoint              string              `json:"endpoint"`
+	DisplayName           string              `json:"display_name"`
+	Description           string              `json:"description"`
+	GroupType             string              `json:"group_type"`
+	Upstreams             datatypes.JSON      `json:"upstreams"`
+	ChannelType           string              `json:"channel_type"`
+	Sort                  int                 `json:"sort"`
+	TestModel             string              `json:"test_model"`
+	ValidationEndpoint    string              `json:"validation_endpoint"`
+	ParamOverrides        datatypes.JSONMap   `json:"param_overrides"`
+	ModelRedirectRules    datatypes.JSONMap   `json:"model_redirect_rules"`
+	ModelRedirectStrict   bool                `json:"model_redirect_strict"`
+	Config                datatypes.JSONMap   `json:"config"`
+	HeaderRules           []models.HeaderRule `json:"header_rules"`
+	Budgets               []models.BudgetRule `json:"budgets"`
+	State                 string              `json:"state"`
+	StateMessage          string              `json:"state_message"`
+	StateStatusCode       int                 `json:"state_status_code"`
+	ProxyKeys             string              `json:"proxy_keys"` // 始终为空，代理密钥只以哈希形式保存
+	ProxyKeyCount         int                 `json:"proxy_key_count"`
+	LastValidatedAt       *time.Time          `json:"last_validated_at"`
+	LastActiveValidatedAt *time.Time          `json:"last_active_validated_at"`
+	CreatedAt             time.Time           `json:"created_at"`
+	UpdatedAt             time.Time           `json:"updated_at"`
 }
 
 // newGroupResponse creates a new GroupResponse from a models.Group.
@@ -289,27 +337,40 @@ func (s *Server) newGroupResponse(group *models.Group) *GroupResponse {
 		}
 	}
 
+	budgets := make([]models.BudgetRule, 0)
+	if len(group.Budgets) > 0 {
+		if err := json.Unmarshal(group.Budgets, &budgets); err != nil {
+			logrus.WithError(err).Error("Failed to unmarshal budget rules")
+			budgets = make([]models.BudgetRule, 0)
+		}
+	}
+
 	return &GroupResponse{
-		ID:                  group.ID,
-		Name:                group.Name,
-		Endpoint:            endpoint,
-		DisplayName:         group.DisplayName,
-		Description:         group.Description,
-		GroupType:           group.GroupType,
-		Upstreams:           group.Upstreams,
-		ChannelType:         group.ChannelType,
-		Sort:                group.Sort,
-		TestModel:           group.TestModel,
-		ValidationEndpoint:  group.ValidationEndpoint,
-		ParamOverrides:      group.ParamOverrides,
-		ModelRedirectRules:  group.ModelRedirectRules,
-		ModelRedirectStrict: group.ModelRedirectStrict,
-		Config:              group.Config,
-		HeaderRules:         headerRules,
-		ProxyKeys:           group.ProxyKeys,
-		LastValidatedAt:     group.LastValidatedAt,
-		CreatedAt:           group.CreatedAt,
-		UpdatedAt:           group.UpdatedAt,
+		ID:                    group.ID,
+		Name:                  group.Name,
+		Endpoint:              endpoint,
+		DisplayName:           group.DisplayName,
+		Description:           group.Description,
+		GroupType:             group.GroupType,
+		Upstreams:             group.Upstreams,
+		ChannelType:           group.ChannelType,
+		Sort:                  group.Sort,
+		TestModel:             group.TestModel,
+		ValidationEndpoint:    group.ValidationEndpoint,
+		ParamOverrides:        group.ParamOverrides,
+		ModelRedirectRules:    group.ModelRedirectRules,
+		ModelRedirectStrict:   group.ModelRedirectStrict,
+		Config:                group.Config,
+		HeaderRules:           headerRules,
+		Budgets:               budgets,
+		State:                 group.State,
+		StateMessage:          group.StateMessage,
+		StateStatusCode:       group.StateStatusCode,
+		ProxyKeyCount:         s.ProxyKeyService.CountKeys(group.ID),
+		LastValidatedAt:       group.LastValidatedAt,
+		LastActiveValidatedAt: group.LastActiveValidatedAt,
+		CreatedAt:             group.CreatedAt,
+		UpdatedAt:             group.UpdatedAt,
 	}
 }
 
@@ -380,6 +441,50 @@ func (s *Server) GetGroupStats(c *gin.Context) {
 	response.Success(c, stats)
 }
 
+// GetGroupBudgets handles getting the budget usage of a group.
+func (s *Server) GetGroupBudgets(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	statuses, err := s.BudgetService.GetBudgetStatus(c.Request.Context(), uint(id))
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, statuses)
+}
+
+// GetGroupQueue returns the request queue settings and statistics of a group.
+func (s *Server) GetGroupQueue(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	group, ok := s.findGroupByID(c, uint(id))
+	if !ok {
+		return
+	}
+	// The cached group carries the effective configuration
+	cachedGroup, err := s.GroupManager.GetGroupByName(group.Name)
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	status, err := s.RequestQueueService.GetStatus(cachedGroup)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+
+	response.Success(c, status)
+}
+
 // GroupCopyRequest defines the payload for copying a group.
 type GroupCopyRequest struct {
 	CopyKeys string `json:"copy_keys"` // "none"|"valid_only"|"all"
@@ -438,6 +543,21 @@ type UpdateSubGroupWeightRequest struct {
 	Weight int `json:"weight"`
 }
 
+// UpdateSubGroupPriorityRequest defines the payload for updating a sub group priority
+type UpdateSubGroupPriorityRequest struct {
+	Priority int `json:"priority"`
+}
+
+// UpdateSubGroupModelsRequest defines the payload for updating the models served by a sub group
+type UpdateSubGroupModelsRequest struct {
+	Models []string `json:"models"`
+}
+
+// UpdateSubGroupScheduleRequest defines the payload for updating the active time windows of a sub group
+type UpdateSubGroupScheduleRequest struct {
+	ActiveSchedule *models.ActiveSchedule `json:"active_schedule"`
+}
+
 // GetSubGroups handles getting sub groups of an aggregate group
 func (s *Server) GetSubGroups(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -502,6 +622,87 @@ func (s *Server) UpdateSubGroupWeight(c *gin.Context) {
 	response.SuccessI18n(c, "success.sub_group_weight_updated", nil)
 }
 
+// UpdateSubGroupPriority handles updating the priority tier of a sub group
+func (s *Server) UpdateSubGroupPriority(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	subGroupID, err := strconv.Atoi(c.Param("subGroupId"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_sub_group_id")
+		return
+	}
+
+	var req UpdateSubGroupPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if err := s.AggregateGroupService.UpdateSubGroupPriority(c.Request.Context(), uint(id), uint(subGroupID), req.Priority); s.handleGroupError(c, err) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.sub_group_priority_updated", nil)
+}
+
+// UpdateSubGroupModels handles updating the declared models of a sub group
+func (s *Server) UpdateSubGroupModels(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	subGroupID, err := strconv.Atoi(c.Param("subGroupId"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_sub_group_id")
+		return
+	}
+
+	var req UpdateSubGroupModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if err := s.AggregateGroupService.UpdateSubGroupModels(c.Request.Context(), uint(id), uint(subGroupID), req.Models); s.handleGroupError(c, err) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.sub_group_models_updated", nil)
+}
+
+// UpdateSubGroupSchedule handles updating the active time windows of a sub group
+func (s *Server) UpdateSubGroupSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	subGroupID, err := strconv.Atoi(c.Param("subGroupId"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_sub_group_id")
+		return
+	}
+
+	var req UpdateSubGroupScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if err := s.AggregateGroupService.UpdateSubGroupSchedule(c.Request.Context(), uint(id), uint(subGroupID), req.ActiveSchedule); s.handleGroupError(c, err) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.sub_group_schedule_updated", nil)
+}
+
 // DeleteSubGroup handles deleting a sub group from an aggregate group
 func (s *Server) DeleteSubGroup(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -2,8 +2,9 @@
 package handler
 
 import (
-	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"gpt-load/internal/config"
@@ -13,6 +14,7 @@ import (
 	"gpt-load/internal/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"go.uber.org/dig"
 	"gorm.io/gorm"
 )
@@ -29,8 +31,21 @@ type Server struct {
 	TaskService                *services.TaskService
 	KeyService                 *services.KeyService
 	KeyImportService           *services.KeyImportService
+	KeySourceService           *services.KeySourceService
 	KeyDeleteService           *services.KeyDeleteService
+	KeyTransferService         *services.KeyTransferService
 	LogService                 *services.LogService
+	ModelPriceService          *services.ModelPriceService
+	BudgetService              *services.BudgetService
+	RequestQueueService        *services.RequestQueueService
+	AdminAuthService           *services.AdminAuthService
+	AuditService               *services.AuditService
+	OIDCService                *services.OIDCService
+	ProxyKeyService            *services.ProxyKeyService
+	AuthGuardService           *services.AuthGuardService
+	KeyRewrapService           *services.KeyRewrapService
+	KeyRotationService         *services.EncryptionKeyRotationService
+	ConfigService              *services.ConfigService
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
 }
@@ -48,8 +63,21 @@ type NewServerParams struct {
 	TaskService                *services.TaskService
 	KeyService                 *services.KeyService
 	KeyImportService           *services.KeyImportService
+	KeySourceService           *services.KeySourceService
 	KeyDeleteService           *services.KeyDeleteService
+	KeyTransferService         *services.KeyTransferService
 	LogService                 *services.LogService
+	ModelPriceService          *services.ModelPriceService
+	BudgetService              *services.BudgetService
+	RequestQueueService        *services.RequestQueueService
+	AdminAuthService           *services.AdminAuthService
+	AuditService               *services.AuditService
+	OIDCService                *services.OIDCService
+	ProxyKeyService            *services.ProxyKeyService
+	AuthGuardService           *services.AuthGuardService
+	KeyRewrapService           *services.KeyRewrapService
+	KeyRotationService         *services.EncryptionKeyRotationService
+	ConfigService              *services.ConfigService
 	CommonHandler              *CommonHandler
 	EncryptionSvc              encryption.Service
 }
@@ -67,28 +95,48 @@ func NewServer(params NewServerParams) *Server {
 		TaskService:                params.TaskService,
 		KeyService:                 params.KeyService,
 		KeyImportService:           params.KeyImportService,
+		KeySourceService:           params.KeySourceService,
 		KeyDeleteService:           params.KeyDeleteService,
+		KeyTransferService:         params.KeyTransferService,
 		LogService:                 params.LogService,
+		ModelPriceService:          params.ModelPriceService,
+		BudgetService:              params.BudgetService,
+		RequestQueueService:        params.RequestQueueService,
+		AdminAuthService:           params.AdminAuthService,
+		AuditService:               params.AuditService,
+		OIDCService:                params.OIDCService,
+		ProxyKeyService:            params.ProxyKeyService,
+		AuthGuardService:           params.AuthGuardService,
+		KeyRewrapService:           params.KeyRewrapService,
+		KeyRotationService:         params.KeyRotationService,
+		ConfigService:              params.ConfigService,
 		CommonHandler:              params.CommonHandler,
 		EncryptionSvc:              params.EncryptionSvc,
 	}
 }
 
-// LoginRequest represents the login request payload
+// LoginRequest represents the login request payload.
+// Either auth_key or username and password must be provided.
 type LoginRequest struct {
-	AuthKey string `json:"auth_key" binding:"required"`
+	AuthKey  string `json:"auth_key"`
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success   bool       `json:"success"`
+	Message   string     `json:"message"`
+	Token     string     `json:"token,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Role      string     `json:"role,omitempty"`
 }
 
-// Login handles authentication verification
+// Login handles authentication verification and creates a session for the web UI
 func (s *Server) Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || (req.AuthKey == "" && (req.Username == "" || req.Password == "")) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": i18n.Message(c, "auth.invalid_request"),
@@ -96,21 +144,52 @@ func (s *Server) Login(c *gin.Context) {
 		return
 	}
 
-	authConfig := s.config.GetAuthConfig()
-
-	isValid := subtle.ConstantTimeCompare([]byte(req.AuthKey), []byte(authConfig.Key)) == 1
+	attempt := services.AuthAttempt{Scope: services.AuthScopeLogin, IP: c.ClientIP(), Key: req.AuthKey}
+	if req.AuthKey == "" {
+		attempt.Username = req.Username
+	}
+	if ban := s.AuthGuardService.Check(attempt); ban != nil {
+		respondLoginBanned(c, ban)
+		return
+	}
 
-	if isValid {
-		c.JSON(http.StatusOK, LoginResponse{
-			Success: true,
-			Message: i18n.Message(c, "auth.authentication_successful"),
-		})
-	} else {
+	result, err := s.AdminAuthService.Login(c.Request.Context(), req.AuthKey, req.Username, req.Password)
+	if err != nil {
+		if !errors.Is(err, services.ErrAdminUnauthorized) {
+			logrus.WithError(err).Error("Failed to process login")
+		} else {
+			services.DelayFailure(c.Request.Context(), s.AuthGuardService.RecordFailure(attempt))
+			// Key prefix and username bans only apply once the credential failed
+			if ban := s.AuthGuardService.CheckFailure(attempt); ban != nil {
+				respondLoginBanned(c, ban)
+				return
+			}
+		}
 		c.JSON(http.StatusUnauthorized, LoginResponse{
 			Success: false,
 			Message: i18n.Message(c, "auth.authentication_failed"),
 		})
+		return
 	}
+	s.AuthGuardService.RecordSuccess(attempt)
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Success:   true,
+		Message:   i18n.Message(c, "auth.authentication_successful"),
+		Token:     result.Token,
+		ExpiresAt: &result.ExpiresAt,
+		Username:  result.Principal.Username,
+		Role:      result.Principal.Role,
+	})
+}
+
+// respondLoginBanned rejects a login attempt of a banned subject until the ban expires.
+func respondLoginBanned(c *gin.Context, ban *services.AuthBan) {
+	c.Header("Retry-After", strconv.Itoa(ban.RetryAfter()))
+	c.JSON(http.StatusTooManyRequests, LoginResponse{
+		Success: false,
+		Message: i18n.Message(c, "auth.too_many_attempts", map[string]any{"minutes": (ban.RetryAfter() + 59) / 60}),
+	})
 }
 
 // Health handles health check requests
@@ -4,12 +4,12 @@ import (
 	"strings"
 
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/middleware"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 // IntegrationGroupInfo represents group info for integration response
@@ -27,73 +27,34 @@ type IntegrationInfoResponse struct {
 	Data    []IntegrationGroupInfo `json:"data"`
 }
 
-// GetIntegrationInfo handles the integration info request
+// GetIntegrationInfo handles the integration info request.
+// The proxy key is checked by ProxyAuth for a group path and by IntegrationAuth for the global path.
 func (s *Server) GetIntegrationInfo(c *gin.Context) {
-	key := c.Query("key")
-	if key == "" {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "Proxy key is required"))
-		return
-	}
-
-	path := c.Request.URL.Path
-	isGroupSpecific := strings.HasPrefix(path, "/proxy/")
-
-	var groupsToCheck []*models.Group
+	isGroupSpecific := strings.HasPrefix(c.Request.URL.Path, "/proxy/")
 
+	var groups []*models.Group
 	if isGroupSpecific {
-		parts := strings.Split(strings.TrimPrefix(path, "/proxy/"), "/")
-		if len(parts) == 0 || parts[0] == "" {
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "Invalid group path"))
-			return
-		}
-
-		groupName := parts[0]
-
-		// Get group from GroupManager cache (already has ProxyKeysMap parsed)
-		group, err := s.GroupManager.GetGroupByName(groupName)
+		group, err := s.GroupManager.GetGroupByName(c.Param("group_name"))
 		if err != nil {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrResourceNotFound, "Group not found"))
 			return
 		}
-
-		groupsToCheck = []*models.Group{group}
-	} else {
-		// Get all groups
-		groups, err := s.GroupService.ListGroups(c.Request.Context())
-		if err != nil {
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Internal server error"))
-			return
-		}
-
-		// Convert to pointer slice and load from cache to get ProxyKeysMap
-		for i := range groups {
-			cachedGroup, err := s.GroupManager.GetGroupByName(groups[i].Name)
-			if err != nil {
-				logrus.Warnf("Failed to get group %s from cache: %v", groups[i].Name, err)
-				continue
-			}
-			groupsToCheck = append(groupsToCheck, cachedGroup)
-		}
+		groups = []*models.Group{group}
+	} else if value, exists := c.Get(middleware.IntegrationGroupsKey); exists {
+		groups, _ = value.([]*models.Group)
 	}
 
-	var result []IntegrationGroupInfo
-	for _, group := range groupsToCheck {
-		if hasProxyKeyPermission(group, key) {
-			channelType := getEffectiveChannelType(group)
-			path := buildPath(isGroupSpecific, group.Name, channelType, group.ValidationEndpoint)
-
-			result = append(result, IntegrationGroupInfo{
-				Name:        group.Name,
-				DisplayName: group.DisplayName,
-				ChannelType: channelType,
-				Path:        path,
-			})
-		}
-	}
-
-	if len(result) == 0 {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "Invalid or unauthorized proxy key"))
-		return
+	result := make([]IntegrationGroupInfo, 0, len(groups))
+	for _, group := range groups {
+		channelType := getEffectiveChannelType(group)
+		path := buildPath(isGroupSpecific, group.Name, channelType, group.ValidationEndpoint)
+
+		result = append(result, IntegrationGroupInfo{
+			Name:        group.Name,
+			DisplayName: group.DisplayName,
+			ChannelType: channelType,
+			Path:        path,
+		})
 	}
 
 	response.Success(c, result)
@@ -125,13 +86,6 @@ func getEffectiveChannelType(group *models.Group) string {
 	return "custom"
 }
 
-// hasProxyKeyPermission checks if the key has permission to access the group
-func hasProxyKeyPermission(group *models.Group, key string) bool {
-	_, exists1 := group.ProxyKeysMap[key]
-	_, exists2 := group.EffectiveConfig.ProxyKeysMap[key]
-	return exists1 || exists2
-}
-
 // buildPath returns the appropriate path based on request type and channel type
 func buildPath(isGroupSpecific bool, groupName string, channelType string, validationEndpoint string) string {
 	if channelType == "custom" {
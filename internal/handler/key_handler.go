@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"gpt-load/internal/encryption"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/middleware"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"gpt-load/internal/utils"
 	"io"
 	"log"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -86,7 +92,8 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -96,7 +103,9 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 
 	result, err := s.KeyService.AddMultipleKeys(req.GroupID, req.KeysText)
 	if err != nil {
-		if strings.Contains(err.Error(), "batch size exceeds the limit") {
+		if errors.Is(err, services.ErrInvalidKeyMetadata) {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else if strings.Contains(err.Error(), "batch size exceeds the limit") {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
 		} else if err.Error() == "no valid keys found in the input text" {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
@@ -106,13 +115,21 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.add", group, map[string]any{
+		"keys":   s.maskedKeysForAudit(req.KeysText),
+		"result": result,
+	})
+
 	response.Success(c, result)
 }
 
 // AddMultipleKeysAsync handles creating new keys from a text block or file within a specific group.
+// Uploaded files may be .txt, .csv (with metadata columns) or .json.
 func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 	var groupID uint
 	var keysText string
+	var entries []services.KeyEntry
+	var parseErr error
 
 	// Check content type to determine if it's a file upload or JSON request
 	contentType := c.ContentType()
@@ -141,8 +158,8 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 
 		// Validate file extension
 		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if ext != ".txt" {
-			response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.only_txt_supported")
+		if ext != ".txt" && ext != ".csv" && ext != ".json" {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.unsupported_key_file")
 			return
 		}
 
@@ -161,6 +178,7 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 			return
 		}
 		keysText = string(buf)
+		entries, parseErr = s.KeyService.ParseKeyEntries(buf, services.DetectKeyFormat(file.Filename, ""))
 	} else {
 		// Handle JSON request (original behavior)
 		var req KeyTextRequest
@@ -170,6 +188,7 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 		}
 		groupID = req.GroupID
 		keysText = req.KeysText
+		entries, parseErr = s.KeyService.ParseKeyEntriesFromText(keysText)
 	}
 
 	group, ok := s.findGroupByID(c, groupID)
@@ -180,13 +199,84 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 	if !validateKeysText(c, keysText) {
 		return
 	}
+	if parseErr != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, parseErr.Error()))
+		return
+	}
+
+	s.startKeyImport(c, group, entries, "key.add_async", nil)
+}
+
+// ImportKeysFromURLRequest defines the payload for importing keys from a URL.
+type ImportKeysFromURLRequest struct {
+	GroupID uint   `json:"group_id" binding:"required"`
+	URL     string `json:"url" binding:"required"`
+	Token   string `json:"token"`  // Optional bearer token for the URL
+	Format  string `json:"format"` // auto, txt, csv or json
+}
+
+// ImportKeysFromURL handles importing keys downloaded from an HTTP(S) URL.
+func (s *Server) ImportKeysFromURL(c *gin.Context) {
+	var req ImportKeysFromURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	switch req.Format {
+	case "", services.KeyFormatAuto, services.KeyFormatText, services.KeyFormatCSV, services.KeyFormatJSON:
+	default:
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_key_format")
+		return
+	}
+
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
+		return
+	}
+
+	entries, err := s.KeySourceService.FetchURL(c.Request.Context(), req.URL, req.Token, req.Format)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		return
+	}
+
+	s.startKeyImport(c, group, entries, "key.import_url", map[string]any{"url": redactURL(req.URL)})
+}
+
+// redactURL drops credentials and the query string of a URL, which may carry tokens.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
 
-	taskStatus, err := s.KeyImportService.StartImportTask(group, keysText)
+// startKeyImport starts an import task for parsed keys and records it in the audit log.
+func (s *Server) startKeyImport(c *gin.Context, group *models.Group, entries []services.KeyEntry, auditAction string, auditDetails map[string]any) {
+	taskStatus, err := s.KeyImportService.StartImportEntriesTask(group, entries)
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrTaskInProgress, err.Error()))
 		return
 	}
 
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	details := map[string]any{
+		"keys":  maskKeysForAudit(keys),
+		"total": taskStatus.Total,
+	}
+	for name, value := range auditDetails {
+		details[name] = value
+	}
+	s.recordKeyAudit(c, auditAction, group, details)
+
 	response.Success(c, taskStatus)
 }
 
@@ -202,18 +292,28 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 	}
 
 	statusFilter := c.Query("status")
-	if statusFilter != "" && statusFilter != models.KeyStatusActive && statusFilter != models.KeyStatusInvalid {
+	if statusFilter != "" && statusFilter != models.KeyStatusActive && statusFilter != models.KeyStatusInvalid && statusFilter != models.KeyStatusDisabled {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
 
 	searchKeyword := c.Query("key_value")
-	searchHash := ""
+	var searchHashes []string
 	if searchKeyword != "" {
-		searchHash = s.EncryptionSvc.Hash(searchKeyword)
+		searchHashes = encryption.HashCandidates(s.EncryptionSvc, searchKeyword)
+	}
+
+	var tagFilters []services.KeyTagFilter
+	for _, value := range c.QueryArray("tag") {
+		filter, err := services.ParseKeyTagFilter(value)
+		if err != nil {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_tag_filter")
+			return
+		}
+		tagFilters = append(tagFilters, filter)
 	}
 
-	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHash)
+	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchHashes, tagFilters)
 
 	var keys []models.APIKey
 	paginatedResult, err := response.Paginate(c, query, &keys)
@@ -222,14 +322,17 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 		return
 	}
 
-	// Decrypt all keys for display
+	// Decrypt all keys for display, viewers only see masked values
+	revealKeys := canRevealKeyValues(c)
 	for i := range keys {
 		decryptedValue, err := s.EncryptionSvc.Decrypt(keys[i].KeyValue)
 		if err != nil {
 			logrus.WithError(err).WithField("key_id", keys[i].ID).Error("Failed to decrypt key value for listing")
 			keys[i].KeyValue = "failed-to-decrypt"
-		} else {
+		} else if revealKeys {
 			keys[i].KeyValue = decryptedValue
+		} else {
+			keys[i].KeyValue = utils.MaskAPIKey(decryptedValue)
 		}
 	}
 	paginatedResult.Items = keys
@@ -237,6 +340,41 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 	response.Success(c, paginatedResult)
 }
 
+// canRevealKeyValues reports whether the admin may see upstream keys in plain text, like exporting them.
+func canRevealKeyValues(c *gin.Context) bool {
+	return middleware.GetAdminPrincipal(c).HasRole(models.AdminRoleOperator)
+}
+
+// GetKeyDetails handles getting a key with its hourly usage history, recent errors and the groups it is used in.
+func (s *Server) GetKeyDetails(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || keyID <= 0 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "invalid key ID format"))
+		return
+	}
+	hours, _ := strconv.Atoi(c.Query("hours"))
+
+	details, err := s.KeyService.GetKeyDetails(c.Request.Context(), uint(keyID), hours)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, app_errors.ErrResourceNotFound)
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	for i := range details.Groups {
+		parentGroups, err := s.AggregateGroupService.GetParentAggregateGroups(c.Request.Context(), details.Groups[i].GroupID)
+		if s.handleGroupError(c, err) {
+			return
+		}
+		details.Groups[i].AggregateGroups = parentGroups
+	}
+
+	response.Success(c, details)
+}
+
 // DeleteMultipleKeys handles deleting keys from a text block within a specific group.
 func (s *Server) DeleteMultipleKeys(c *gin.Context) {
 	var req KeyTextRequest
@@ -245,7 +383,8 @@ func (s *Server) DeleteMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -265,6 +404,11 @@ func (s *Server) DeleteMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.delete", group, map[string]any{
+		"keys":   s.maskedKeysForAudit(req.KeysText),
+		"result": result,
+	})
+
 	response.Success(c, result)
 }
 
@@ -291,6 +435,11 @@ func (s *Server) DeleteMultipleKeysAsync(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.delete_async", group, map[string]any{
+		"keys":  s.maskedKeysForAudit(req.KeysText),
+		"total": taskStatus.Total,
+	})
+
 	response.Success(c, taskStatus)
 }
 
@@ -302,7 +451,8 @@ func (s *Server) RestoreMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -322,6 +472,11 @@ func (s *Server) RestoreMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.restore", group, map[string]any{
+		"keys":   s.maskedKeysForAudit(req.KeysText),
+		"result": result,
+	})
+
 	response.Success(c, result)
 }
 
@@ -377,7 +532,7 @@ func (s *Server) ValidateGroupKeys(c *gin.Context) {
 	}
 
 	// Validate status if provided
-	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid {
+	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid && req.Status != models.KeyStatusDisabled {
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_value")
 		return
 	}
@@ -410,7 +565,8 @@ func (s *Server) RestoreAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -420,6 +576,8 @@ func (s *Server) RestoreAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.restore_all_invalid", group, map[string]any{"count": rowsAffected})
+
 	response.SuccessI18n(c, "success.keys_restored", nil, map[string]any{"count": rowsAffected})
 }
 
@@ -431,7 +589,8 @@ func (s *Server) ClearAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -441,6 +600,8 @@ func (s *Server) ClearAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.clear_all_invalid", group, map[string]any{"count": rowsAffected})
+
 	response.SuccessI18n(c, "success.invalid_keys_cleared", nil, map[string]any{"count": rowsAffected})
 }
 
@@ -452,7 +613,8 @@ func (s *Server) ClearAllKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -462,6 +624,8 @@ func (s *Server) ClearAllKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeyAudit(c, "key.clear_all", group, map[string]any{"count": rowsAffected})
+
 	response.SuccessI18n(c, "success.all_keys_cleared", nil, map[string]any{"count": rowsAffected})
 }
 
@@ -478,22 +642,35 @@ func (s *Server) ExportKeys(c *gin.Context) {
 	}
 
 	switch statusFilter {
-	case "all", models.KeyStatusActive, models.KeyStatusInvalid:
+	case "all", models.KeyStatusActive, models.KeyStatusInvalid, models.KeyStatusDisabled:
 	default:
 		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
 		return
 	}
 
+	format := c.DefaultQuery("format", "txt")
+	if format != "txt" && format != "csv" {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_export_format")
+		return
+	}
+
 	group, ok := s.findGroupByID(c, groupID)
 	if !ok {
 		return
 	}
 
-	filename := fmt.Sprintf("keys-%s-%s.txt", group.Name, statusFilter)
+	filename := fmt.Sprintf("keys-%s-%s.%s", group.Name, statusFilter, format)
 	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "text/plain; charset=utf-8")
 
-	if err := s.KeyService.StreamKeysToWriter(groupID, statusFilter, c.Writer); err != nil {
+	var err error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = s.KeyService.StreamKeysCSVToWriter(groupID, statusFilter, c.Writer)
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		err = s.KeyService.StreamKeysToWriter(groupID, statusFilter, c.Writer)
+	}
+	if err != nil {
 		log.Printf("Failed to stream keys: %v", err)
 	}
 }
@@ -542,5 +719,161 @@ func (s *Server) UpdateKeyNotes(c *gin.Context) {
 		return
 	}
 
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     "key.update_notes",
+		TargetType: models.AuditTargetKey,
+		TargetID:   key.ID,
+		TargetName: utils.MaskAPIKey(key.KeyValue),
+		Before:     map[string]any{"notes": key.Notes},
+		After:      map[string]any{"notes": req.Notes},
+	})
+
+	response.Success(c, nil)
+}
+
+// UpdateKeyTagsRequest defines the payload for adding and removing tags on many keys of a group.
+type UpdateKeyTagsRequest struct {
+	GroupID    uint              `json:"group_id" binding:"required"`
+	KeyIDs     []uint            `json:"key_ids"`
+	KeysText   string            `json:"keys_text"`
+	AddTags    map[string]string `json:"add_tags"`
+	RemoveTags []string          `json:"remove_tags"`
+}
+
+// UpdateKeyTags handles bulk tag editing on keys selected by ID or by value.
+func (s *Server) UpdateKeyTags(c *gin.Context) {
+	var req UpdateKeyTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
+		return
+	}
+
+	updatedCount, err := s.KeyService.UpdateKeyTags(req.GroupID, services.KeyTagUpdate{
+		KeyIDs:     req.KeyIDs,
+		KeysText:   req.KeysText,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidKeyMetadata) ||
+			strings.Contains(err.Error(), "batch size exceeds the limit") ||
+			err.Error() == "no valid keys found in the input text" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	s.recordKeyAudit(c, "key.update_tags", group, map[string]any{
+		"key_ids":       req.KeyIDs,
+		"keys":          s.maskedKeysForAudit(req.KeysText),
+		"add_tags":      req.AddTags,
+		"remove_tags":   req.RemoveTags,
+		"updated_count": updatedCount,
+	})
+
+	response.Success(c, gin.H{"updated_count": updatedCount})
+}
+
+// UpdateKeyMetadataRequest defines the payload for updating the metadata of a key.
+// Omitted fields are left unchanged; an empty expires_at clears the expiry.
+type UpdateKeyMetadataRequest struct {
+	Tags      map[string]string `json:"tags"`
+	Priority  *int              `json:"priority"`
+	ExpiresAt *string           `json:"expires_at"`
+	RPMLimit  *int              `json:"rpm_limit"`
+	// ActiveSchedule replaces the active time windows; an empty window list removes them
+	ActiveSchedule *models.ActiveSchedule `json:"active_schedule"`
+}
+
+// UpdateKeyMetadata handles updating the tags, priority, expiry, RPM limit and schedule of a specific API key.
+func (s *Server) UpdateKeyMetadata(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || keyID <= 0 {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "invalid key ID format"))
+		return
+	}
+
+	var req UpdateKeyMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	before, after, err := s.KeyService.UpdateKeyMetadata(uint(keyID), services.KeyMetadataParams{
+		Tags:           req.Tags,
+		Priority:       req.Priority,
+		ExpiresAt:      req.ExpiresAt,
+		RPMLimit:       req.RPMLimit,
+		ActiveSchedule: req.ActiveSchedule,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidKeyMetadata) {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, app_errors.ErrResourceNotFound)
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     "key.update_metadata",
+		TargetType: models.AuditTargetKey,
+		TargetID:   before.ID,
+		TargetName: utils.MaskAPIKey(before.KeyValue),
+		Before:     keyMetadataForAudit(before),
+		After:      keyMetadataForAudit(after),
+	})
+
 	response.Success(c, nil)
 }
+
+// keyMetadataForAudit returns the metadata fields of a key for the audit log
+func keyMetadataForAudit(key *models.APIKey) map[string]any {
+	return map[string]any{
+		"tags":       key.Tags,
+		"priority":   key.Priority,
+		"expires_at": key.ExpiresAt,
+		"rpm_limit":  key.RPMLimit,
+		"schedule":   key.Schedule,
+	}
+}
+
+// maxAuditedKeys caps how many masked keys are stored in a single audit entry
+const maxAuditedKeys = 100
+
+// recordKeyAudit records a key operation on a group in the audit log
+func (s *Server) recordKeyAudit(c *gin.Context, action string, group *models.Group, details map[string]any) {
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     action,
+		TargetType: models.AuditTargetKey,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		After:      details,
+	})
+}
+
+// maskedKeysForAudit returns the masked keys of an input text, so the audit log never holds plain keys
+func (s *Server) maskedKeysForAudit(keysText string) []string {
+	return maskKeysForAudit(s.KeyService.ParseKeysFromText(keysText))
+}
+
+// maskKeysForAudit masks keys for the audit log, keeping at most maxAuditedKeys of them.
+func maskKeysForAudit(keys []string) []string {
+	if len(keys) > maxAuditedKeys {
+		keys = keys[:maxAuditedKeys]
+	}
+	masked := make([]string, len(keys))
+	for i, key := range keys {
+		masked[i] = utils.MaskAPIKey(key)
+	}
+	return masked
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/middleware"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseKeySourceID parses the key source ID path parameter.
+func parseKeySourceID(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_key_source_id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// keySourceAuditDetails describes a key source in the audit log, without its token.
+func keySourceAuditDetails(source *models.KeySource) map[string]any {
+	return map[string]any{
+		"source_id":        source.ID,
+		"name":             source.Name,
+		"type":             source.Type,
+		"address":          redactURL(source.Address),
+		"path":             source.Path,
+		"interval_minutes": source.IntervalMinutes,
+		"missing_action":   source.MissingAction,
+		"enabled":          source.Enabled,
+	}
+}
+
+// checkDirectorySourceAccess allows only owners to configure directory sources, which read files on the server.
+func checkDirectorySourceAccess(c *gin.Context, sourceTypes ...string) bool {
+	for _, sourceType := range sourceTypes {
+		if strings.TrimSpace(sourceType) == models.KeySourceTypeDirectory && !middleware.GetAdminPrincipal(c).HasRole(models.AdminRoleOwner) {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrForbidden, "key_source.directory_owner_only")
+			return false
+		}
+	}
+	return true
+}
+
+// ListKeySources handles listing the key sources of a group.
+func (s *Server) ListKeySources(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	sources, err := s.KeySourceService.ListSources(c.Request.Context(), uint(id))
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, sources)
+}
+
+// CreateKeySource handles adding a key source to a group.
+func (s *Server) CreateKeySource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+		return
+	}
+
+	group, ok := s.findGroupByID(c, uint(id))
+	if !ok {
+		return
+	}
+
+	var req services.KeySourceParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+	if !checkDirectorySourceAccess(c, req.Type) {
+		return
+	}
+
+	source, err := s.KeySourceService.CreateSource(c.Request.Context(), group.ID, req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	s.recordKeyAudit(c, "key_source.create", group, keySourceAuditDetails(source))
+	response.Success(c, source)
+}
+
+// UpdateKeySource handles updating a key source.
+func (s *Server) UpdateKeySource(c *gin.Context) {
+	id, ok := parseKeySourceID(c)
+	if !ok {
+		return
+	}
+
+	var req services.KeySourceParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	current, err := s.KeySourceService.GetSource(c.Request.Context(), id)
+	if s.handleGroupError(c, err) {
+		return
+	}
+	if !checkDirectorySourceAccess(c, current.Type, req.Type) {
+		return
+	}
+
+	source, err := s.KeySourceService.UpdateSource(c.Request.Context(), id, req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	if group, ok := s.findGroupByID(c, source.GroupID); ok {
+		s.recordKeyAudit(c, "key_source.update", group, keySourceAuditDetails(source))
+		response.Success(c, source)
+	}
+}
+
+// DeleteKeySource handles removing a key source. The keys it imported are kept.
+func (s *Server) DeleteKeySource(c *gin.Context) {
+	id, ok := parseKeySourceID(c)
+	if !ok {
+		return
+	}
+
+	source, err := s.KeySourceService.GetSource(c.Request.Context(), id)
+	if s.handleGroupError(c, err) {
+		return
+	}
+	group, ok := s.findGroupByID(c, source.GroupID)
+	if !ok {
+		return
+	}
+
+	if s.handleGroupError(c, s.KeySourceService.DeleteSource(c.Request.Context(), id)) {
+		return
+	}
+
+	s.recordKeyAudit(c, "key_source.delete", group, keySourceAuditDetails(source))
+	response.SuccessI18n(c, "success.key_source_deleted", nil)
+}
+
+// SyncKeySource handles starting a sync of a key source now.
+func (s *Server) SyncKeySource(c *gin.Context) {
+	id, ok := parseKeySourceID(c)
+	if !ok {
+		return
+	}
+
+	source, err := s.KeySourceService.GetSource(c.Request.Context(), id)
+	if s.handleGroupError(c, err) {
+		return
+	}
+	group, ok := s.findGroupByID(c, source.GroupID)
+	if !ok {
+		return
+	}
+
+	taskStatus, err := s.KeySourceService.StartSync(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrKeySourceFetch) {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		} else {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrTaskInProgress, err.Error()))
+		}
+		return
+	}
+
+	s.recordKeyAudit(c, "key_source.sync", group, keySourceAuditDetails(source))
+	response.Success(c, taskStatus)
+}
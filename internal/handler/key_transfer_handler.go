@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDuplicateReportLimit = 1000
+
+// TransferKeysRequest defines the payload for moving or copying keys to another group.
+// Keys are selected by ID and/or value; when neither is given, the status and tag filters are used.
+type TransferKeysRequest struct {
+	SourceGroupID uint     `json:"source_group_id" binding:"required"`
+	TargetGroupID uint     `json:"target_group_id" binding:"required"`
+	Mode          string   `json:"mode" binding:"required"`
+	KeyIDs        []uint   `json:"key_ids"`
+	KeysText      string   `json:"keys_text"`
+	Status        string   `json:"status"`
+	Tags          []string `json:"tags"`
+}
+
+// TransferKeys handles starting a task that moves or copies keys from one group to another.
+func (s *Server) TransferKeys(c *gin.Context) {
+	var req TransferKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if req.Status != "" && req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid && req.Status != models.KeyStatusDisabled {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_status_filter")
+		return
+	}
+
+	var tagFilters []services.KeyTagFilter
+	for _, value := range req.Tags {
+		filter, err := services.ParseKeyTagFilter(value)
+		if err != nil {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "validation.invalid_tag_filter")
+			return
+		}
+		tagFilters = append(tagFilters, filter)
+	}
+
+	source, ok := s.findGroupByID(c, req.SourceGroupID)
+	if !ok {
+		return
+	}
+	target, ok := s.findGroupByID(c, req.TargetGroupID)
+	if !ok {
+		return
+	}
+
+	taskStatus, err := s.KeyTransferService.StartTransferTask(source, target, services.KeyTransferParams{
+		Mode:       req.Mode,
+		KeyIDs:     req.KeyIDs,
+		KeysText:   req.KeysText,
+		Status:     req.Status,
+		TagFilters: tagFilters,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTaskAlreadyRunning):
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrTaskInProgress, err.Error()))
+		case strings.Contains(err.Error(), "batch size exceeds the limit"):
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		default:
+			s.handleGroupError(c, err)
+		}
+		return
+	}
+
+	s.recordKeyAudit(c, "key.transfer", source, map[string]any{
+		"mode":            req.Mode,
+		"target_group_id": target.ID,
+		"target_group":    target.Name,
+		"key_ids":         req.KeyIDs,
+		"keys":            s.maskedKeysForAudit(req.KeysText),
+		"status":          req.Status,
+		"tags":            req.Tags,
+		"total":           taskStatus.Total,
+	})
+
+	response.Success(c, taskStatus)
+}
+
+// ListDuplicateKeys handles reporting key values that are held by more than one group.
+// An optional group_id limits the report to the key values of that group.
+func (s *Server) ListDuplicateKeys(c *gin.Context) {
+	var groupID uint
+	if c.Query("group_id") != "" {
+		id, ok := validateGroupIDFromQuery(c)
+		if !ok {
+			return
+		}
+		groupID = id
+	}
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_limit")
+			return
+		}
+		limit = min(parsed, maxDuplicateReportLimit)
+	}
+
+	report, err := s.KeyService.FindDuplicateKeys(c.Request.Context(), groupID, limit)
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	response.Success(c, report)
+}
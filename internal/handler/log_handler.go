@@ -6,6 +6,7 @@ import (
 	"gpt-load/internal/i18n"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/utils"
 	"log"
 	"time"
 
@@ -30,15 +31,18 @@ func (s *Server) GetLogs(c *gin.Context) {
 		return
 	}
 
-	// 解密所有日志中的密钥用于前端显示
+	// 解密所有日志中的密钥用于前端显示，只读角色只能看到脱敏后的密钥
+	revealKeys := canRevealKeyValues(c)
 	for i := range logs {
 		if logs[i].KeyValue != "" {
 			decryptedValue, err := s.EncryptionSvc.Decrypt(logs[i].KeyValue)
 			if err != nil {
 				logrus.WithError(err).WithField("log_id", logs[i].ID).Error("Failed to decrypt log key value")
 				logs[i].KeyValue = "failed-to-decrypt"
-			} else {
+			} else if revealKeys {
 				logs[i].KeyValue = decryptedValue
+			} else {
+				logs[i].KeyValue = utils.MaskAPIKey(decryptedValue)
 			}
 		}
 	}
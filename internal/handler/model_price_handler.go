@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"strconv"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListModelPrices handles listing the model price table
+func (s *Server) ListModelPrices(c *gin.Context) {
+	prices, err := s.ModelPriceService.ListPrices(c.Request.Context())
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, prices)
+}
+
+// CreateModelPrice handles creating a model price entry
+func (s *Server) CreateModelPrice(c *gin.Context) {
+	var req services.ModelPriceParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	price, err := s.ModelPriceService.CreatePrice(c.Request.Context(), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, price)
+}
+
+// UpdateModelPrice handles updating a model price entry
+func (s *Server) UpdateModelPrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_model_price_id")
+		return
+	}
+
+	var req services.ModelPriceParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	price, err := s.ModelPriceService.UpdatePrice(c.Request.Context(), uint(id), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, price)
+}
+
+// DeleteModelPrice handles deleting a model price entry
+func (s *Server) DeleteModelPrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_model_price_id")
+		return
+	}
+
+	if s.handleGroupError(c, s.ModelPriceService.DeletePrice(c.Request.Context(), uint(id))) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.model_price_deleted", nil)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetOIDCConfig tells the login page whether SSO login is available
+func (s *Server) GetOIDCConfig(c *gin.Context) {
+	response.Success(c, gin.H{"enabled": s.OIDCService.Enabled()})
+}
+
+// OIDCLogin redirects the browser to the identity provider
+func (s *Server) OIDCLogin(c *gin.Context) {
+	authURL, state, err := s.OIDCService.StartLogin(c.Request.Context())
+	if err != nil {
+		if !errors.Is(err, services.ErrOIDCDisabled) {
+			logrus.WithError(err).Error("Failed to start OIDC login")
+		}
+		redirectToLogin(c, "sso_error", "unavailable")
+		return
+	}
+
+	// Lax still sends the cookie on the top-level redirect back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OIDCStateCookie, state, int(services.OIDCStateTTL.Seconds()), "/", "", s.OIDCService.SecureCookies(), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes the SSO login, stores the session in a cookie and returns to the web UI
+func (s *Server) OIDCCallback(c *gin.Context) {
+	// The state cookie is single use, whatever the outcome of the callback
+	browserState, _ := c.Cookie(services.OIDCStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.OIDCStateCookie, "", -1, "/", "", s.OIDCService.SecureCookies(), true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		logrus.WithFields(logrus.Fields{
+			"error":       providerErr,
+			"description": c.Query("error_description"),
+		}).Warn("OIDC provider returned an error")
+		redirectToLogin(c, "sso_error", "failed")
+		return
+	}
+
+	result, err := s.OIDCService.HandleCallback(c.Request.Context(), c.Query("state"), browserState, c.Query("code"))
+	if err != nil {
+		reason := "failed"
+		switch {
+		case errors.Is(err, services.ErrOIDCNoRole), errors.Is(err, services.ErrAdminUnauthorized):
+			reason = "denied"
+		case errors.Is(err, services.ErrAdminUsernameTaken):
+			logrus.WithError(err).Warn("OIDC login rejected")
+			reason = "denied"
+		case errors.Is(err, services.ErrOIDCDisabled):
+			reason = "unavailable"
+		default:
+			logrus.WithError(err).Error("Failed to complete OIDC login")
+		}
+		redirectToLogin(c, "sso_error", reason)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(services.AdminSessionCookie, result.Token, int(time.Until(result.ExpiresAt).Seconds()), "/", "", s.OIDCService.SecureCookies(), true)
+
+	logrus.WithFields(logrus.Fields{
+		"username": result.Principal.Username,
+		"role":     result.Principal.Role,
+	}).Info("Admin logged in via OIDC")
+	redirectToLogin(c, "sso", "success")
+}
+
+// redirectToLogin returns the browser to the login page with a status parameter
+func redirectToLogin(c *gin.Context, key, value string) {
+	c.Redirect(http.StatusFound, "/login?"+url.Values{key: {value}}.Encode())
+}
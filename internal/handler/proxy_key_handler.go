@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"strconv"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProxyKeys handles listing the proxy keys of a group, or the global keys when group_id is 0 or omitted
+func (s *Server) ListProxyKeys(c *gin.Context) {
+	var groupID uint
+	if raw := c.Query("group_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 0 {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_group_id")
+			return
+		}
+		groupID = uint(id)
+	}
+
+	keys, err := s.ProxyKeyService.ListKeys(c.Request.Context(), groupID)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, keys)
+}
+
+// CreateProxyKey handles creating a proxy key. The full key is only returned in this response.
+func (s *Server) CreateProxyKey(c *gin.Context) {
+	var req services.ProxyKeyParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	key, err := s.ProxyKeyService.CreateKey(c.Request.Context(), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, key)
+}
+
+// UpdateProxyKey handles changing the name or the IP rules of a proxy key
+func (s *Server) UpdateProxyKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_proxy_key_id")
+		return
+	}
+
+	var req services.ProxyKeyUpdateParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	key, err := s.ProxyKeyService.UpdateKey(c.Request.Context(), uint(id), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, key)
+}
+
+// RotateProxyKey handles replacing a proxy key, keeping the old one valid for a grace period
+func (s *Server) RotateProxyKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_proxy_key_id")
+		return
+	}
+
+	var req services.ProxyKeyRotateParams
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+			return
+		}
+	}
+
+	key, err := s.ProxyKeyService.RotateKey(c.Request.Context(), uint(id), req)
+	if s.handleGroupError(c, err) {
+		return
+	}
+
+	response.Success(c, key)
+}
+
+// DeleteProxyKey handles revoking a proxy key
+func (s *Server) DeleteProxyKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_proxy_key_id")
+		return
+	}
+
+	if s.handleGroupError(c, s.ProxyKeyService.DeleteKey(c.Request.Context(), uint(id))) {
+		return
+	}
+
+	response.SuccessI18n(c, "success.proxy_key_deleted", nil)
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/i18n"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"gpt-load/internal/types"
 	"gpt-load/internal/utils"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +20,8 @@ import (
 // It retrieves all system settings, groups them by category, and returns them.
 func (s *Server) GetSettings(c *gin.Context) {
 	currentSettings := s.SettingsManager.GetSettings()
+	// 代理密钥只以哈希形式保存，不回显明文
+	currentSettings.ProxyKeys = ""
 	settingsInfo := utils.GenerateSettingsMetadata(&currentSettings)
 
 	// Translate settings info
@@ -69,12 +75,24 @@ func (s *Server) UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	// Sanitize proxy_keys input
+	before := settingsAuditSnapshot(s.SettingsManager.GetSettings())
+
+	// 拒绝会把当前管理员自己拦截在外的管理端 IP 规则
+	if !adminIPRulesKeepAccess(s.SettingsManager.GetSettings(), settingsMap, c.ClientIP()) {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrValidation, "settings.admin_ip_lockout", map[string]any{"ip": c.ClientIP()})
+		return
+	}
+
+	// Proxy keys entered here are added to the hashed global keys instead of being stored in plain text
+	addedProxyKeys := 0
 	if proxyKeys, ok := settingsMap["proxy_keys"]; ok {
-		if proxyKeysStr, ok := proxyKeys.(string); ok {
-			cleanedKeys := utils.SplitAndTrim(proxyKeysStr, ",")
-			settingsMap["proxy_keys"] = strings.Join(cleanedKeys, ",")
+		proxyKeysStr, _ := proxyKeys.(string)
+		added, err := s.ProxyKeyService.ImportSettingKeys(c.Request.Context(), proxyKeysStr)
+		if s.handleGroupError(c, err) {
+			return
 		}
+		addedProxyKeys = added
+		settingsMap["proxy_keys"] = ""
 	}
 
 	// 更新配置
@@ -85,5 +103,52 @@ func (s *Server) UpdateSettings(c *gin.Context) {
 
 	time.Sleep(100 * time.Millisecond) // 等待异步更新配置
 
+	after := settingsAuditSnapshot(s.SettingsManager.GetSettings())
+	if addedProxyKeys > 0 {
+		after["proxy_keys_added"] = addedProxyKeys
+	}
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     "settings.update",
+		TargetType: models.AuditTargetSettings,
+		TargetName: "system",
+		Before:     before,
+		After:      after,
+	})
+
 	response.SuccessI18n(c, "settings.update_success", nil)
 }
+
+// adminIPRulesKeepAccess reports whether the admin IP rules resulting from settingsMap still allow ip.
+// Lists that cannot be parsed are left to the settings validation.
+func adminIPRulesKeepAccess(current types.SystemSettings, settingsMap map[string]any, ip string) bool {
+	allow, deny := current.AdminIPAllowlist, current.AdminIPDenylist
+	if value, ok := settingsMap["admin_ip_allowlist"].(string); ok {
+		allow = value
+	}
+	if value, ok := settingsMap["admin_ip_denylist"].(string); ok {
+		deny = value
+	}
+	rules, err := utils.CompileIPRules(allow, deny)
+	if err != nil {
+		return true
+	}
+	return rules.Allowed(ip)
+}
+
+// settingsAuditSnapshot returns the system settings for the audit log, with proxy keys masked
+func settingsAuditSnapshot(settings types.SystemSettings) map[string]any {
+	snapshot := make(map[string]any)
+	data, err := json.Marshal(settings)
+	if err != nil || json.Unmarshal(data, &snapshot) != nil {
+		return snapshot
+	}
+
+	proxyKeys := utils.SplitAndTrim(settings.ProxyKeys, ",")
+	for i, key := range proxyKeys {
+		proxyKeys[i] = utils.MaskAPIKey(key)
+	}
+	sort.Strings(proxyKeys)
+	snapshot["proxy_keys"] = strings.Join(proxyKeys, ",")
+
+	return snapshot
+}
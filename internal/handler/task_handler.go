@@ -1,13 +1,24 @@
 package handler
 
 import (
+	"errors"
+	"strconv"
+
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetTaskStatus handles requests for the status of the global long-running task.
+const (
+	defaultTaskListLimit = 50
+	maxTaskListLimit     = 200
+)
+
+// GetTaskStatus handles requests for the status of the latest long-running task.
+// It reports the most recently started running task, or the last finished one.
 func (s *Server) GetTaskStatus(c *gin.Context) {
 	taskStatus, err := s.TaskService.GetTaskStatus()
 	if err != nil {
@@ -16,3 +27,66 @@ func (s *Server) GetTaskStatus(c *gin.Context) {
 	}
 	response.Success(c, taskStatus)
 }
+
+// ListTasks handles listing recent tasks, optionally filtered by group name or running state.
+func (s *Server) ListTasks(c *gin.Context) {
+	limit := defaultTaskListLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "validation.invalid_limit")
+			return
+		}
+		limit = min(parsed, maxTaskListLimit)
+	}
+
+	tasks, err := s.TaskService.ListTasks(c.Query("group_name"), c.Query("running") == "true", limit)
+	if err != nil {
+		response.ErrorI18nFromAPIError(c, app_errors.ErrInternalServer, "task.get_status_failed")
+		return
+	}
+	response.Success(c, tasks)
+}
+
+// GetTask handles requests for the progress and result of a single task.
+func (s *Server) GetTask(c *gin.Context) {
+	taskStatus, err := s.TaskService.GetTask(c.Param("id"))
+	if err != nil {
+		s.handleTaskError(c, err)
+		return
+	}
+	response.Success(c, taskStatus)
+}
+
+// CancelTask handles requests to stop a running task.
+func (s *Server) CancelTask(c *gin.Context) {
+	taskStatus, err := s.TaskService.CancelTask(c.Param("id"))
+	if err != nil {
+		s.handleTaskError(c, err)
+		return
+	}
+
+	s.AuditService.Record(c.Request.Context(), services.AuditEntry{
+		Action:     "task.cancel",
+		TargetType: models.AuditTargetTask,
+		TargetID:   taskStatus.ID,
+		TargetName: taskStatus.TaskType,
+		After: map[string]any{
+			"group_name": taskStatus.GroupName,
+			"processed":  taskStatus.Processed,
+			"total":      taskStatus.Total,
+		},
+	})
+	response.Success(c, taskStatus)
+}
+
+func (s *Server) handleTaskError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaskNotFound):
+		response.ErrorI18nFromAPIError(c, app_errors.ErrResourceNotFound, "task.not_found")
+	case errors.Is(err, services.ErrTaskNotCancelable):
+		response.ErrorI18nFromAPIError(c, app_errors.ErrBadRequest, "task.not_cancelable")
+	default:
+		response.ErrorI18nFromAPIError(c, app_errors.ErrInternalServer, "task.get_status_failed")
+	}
+}
@@ -48,14 +48,56 @@ var MessagesEnUS = map[string]string{
 	"validation.invalid_group_name":      "Invalid group name. Can only contain lowercase letters, numbers, hyphens or underscores, 1-100 characters",
 	"validation.invalid_test_path":       "Invalid test path. If provided, must be a valid path starting with / and not a full URL.",
 	"validation.duplicate_header":        "Duplicate header: {{.key}}",
+	"validation.invalid_budget_period":   "Invalid budget period: {{.period}}, must be daily or monthly",
+	"validation.invalid_budget_metric":   "Invalid budget metric: {{.metric}}, must be requests, tokens or cost",
+	"validation.budget_limit_negative":   "Budget limits cannot be negative",
+	"validation.budget_soft_exceeds_hard": "Budget soft limit cannot exceed the hard limit",
+	"validation.duplicate_budget":        "Duplicate budget for {{.period}} {{.metric}}",
 	"validation.group_not_found":         "Group not found",
 	"validation.invalid_status_filter":   "Invalid status filter",
+	"validation.invalid_export_format":   "Invalid export format, must be txt or csv",
+	"validation.invalid_transfer_mode":   "Invalid transfer mode, must be move or copy",
+	"validation.transfer_same_group":     "Source and target group must be different",
+	"validation.transfer_to_aggregate_group": "Keys cannot be transferred to an aggregate group",
+	"validation.copy_keys_not_unique":    "Keys cannot be copied while global key uniqueness is enforced, move them instead",
+	"validation.no_keys_selected":        "No keys match the selection",
+	"validation.invalid_active_schedule": "Invalid active schedule: {{.error}}",
+	"validation.invalid_group_state":     "Invalid group state, must be enabled, draining, disabled or maintenance",
+	"validation.invalid_state_status_code": "State status code must be between 400 and 599",
+	"validation.state_message_too_long":  "State message must be at most 512 characters",
+	"validation.invalid_config_document": "Invalid config document: {{.error}}",
+	"validation.unsupported_config_version": "Unsupported config version {{.version}}, must be 1",
+	"validation.invalid_config_format":   "Invalid format, must be yaml or json",
+	"validation.config_document_too_large": "Config document is too large",
+	"validation.config_duplicate_group":  "Group '{{.group}}' is declared more than once",
+	"validation.config_group_type_changed": "The group type cannot be changed, delete the group first",
+	"validation.config_invalid_settings": "Invalid settings: {{.error}}",
+	"validation.config_invalid_sub_group": "Sub-group '{{.sub_group}}' is missing, duplicated or not declared",
+	"validation.config_aggregate_keys":   "Aggregate groups cannot have keys",
+	"validation.config_keys_require_encryption": "Keys can only be exported or imported when encryption is enabled",
+	"validation.config_key_decrypt_failed": "Key #{{.index}} cannot be decrypted with the current encryption configuration",
+	"validation.config_invalid_key":      "Key #{{.index}} is invalid: {{.error}}",
+	"validation.config_group_invalid":    "Group '{{.group}}': {{.error}}",
+	"validation.unsupported_key_file":    "Unsupported key file, must be .txt, .csv or .json",
+	"validation.invalid_key_format":      "Invalid key format, must be auto, txt, csv or json",
+	"validation.invalid_key_source_id":   "Invalid key source ID",
+	"validation.key_source_name_required": "Key source name is required",
+	"validation.invalid_key_source_type": "Invalid key source type, must be url, vault or directory",
+	"validation.invalid_key_source_address": "Invalid key source address, must be an http(s) URL or a directory path",
+	"validation.key_source_address_not_allowed": "Key sources cannot use loopback, private or link-local addresses unless KEY_SOURCE_ALLOW_PRIVATE_NETWORKS is enabled",
+	"validation.key_source_directory_disabled": "Directory sources are disabled, set KEY_SOURCE_DIR to enable them",
+	"validation.key_source_directory_outside_root": "The directory must be inside {{.root}}",
+	"validation.key_source_path_required": "Vault secret path is required",
+	"validation.invalid_missing_action":  "Invalid missing key action, must be keep, disable or remove",
+	"validation.invalid_sync_interval":   "Sync interval must be 0 or greater",
+	"validation.invalid_tag_filter":      "Invalid tag filter, use name or name:value",
 	"validation.invalid_group_id":        "Invalid group ID format",
 	"validation.test_model_required":     "Test model is required",
 	"validation.invalid_copy_keys_value": "Invalid copy_keys value. Must be 'none', 'valid_only', or 'all'",
 	"validation.invalid_channel_type":    "Invalid channel type. Supported types: {{.types}}",
 	"validation.test_model_empty":        "Test model cannot be empty or contain only spaces",
 	"validation.invalid_status_value":    "Invalid status value",
+	"validation.invalid_limit":           "Invalid limit, must be a positive integer",
 	"validation.invalid_upstreams":       "Invalid upstreams configuration: {{.error}}",
 	"validation.group_id_required":       "group_id query parameter is required",
 	"validation.invalid_group_id_format": "Invalid group_id format",
@@ -73,6 +115,8 @@ var MessagesEnUS = map[string]string{
 	"validation.sub_group_validation_endpoint_mismatch": "Sub-group endpoints are inconsistent. Aggregate groups require unified upstream request paths for successful proxying",
 	"validation.sub_group_weight_negative":     "Sub-group weight cannot be negative",
 	"validation.sub_group_weight_max_exceeded": "Sub-group weight cannot exceed 1000",
+	"validation.sub_group_model_empty": "Sub-group model names cannot be empty",
+	"validation.sub_group_priority_out_of_range": "Sub-group priority must be between 0 and 100",
 	"validation.sub_group_referenced_cannot_modify": "This group is referenced by {{.count}} aggregate group(s) as a sub-group. Cannot modify channel type or validation endpoint. Please remove this group from related aggregate groups before making changes",
 	"validation.standard_group_requires_upstreams_testmodel": "Converting to standard group requires providing upstreams and test model",
 	"validation.aggregate_no_model_redirect": "Aggregate groups do not support model redirect rules",
@@ -81,6 +125,21 @@ var MessagesEnUS = map[string]string{
 	"validation.reorder_sort_negative":  "Sort value cannot be negative",
 	"validation.reorder_duplicate_group": "Duplicate group ID in reorder items: {{.id}}",
 	"validation.reorder_group_not_found": "Reorder items contain non-existent group",
+	"validation.invalid_model_price_id":  "Invalid model price ID",
+	"validation.model_pattern_required":  "Model pattern is required",
+	"validation.model_price_negative":    "Model prices cannot be negative",
+	"validation.invalid_proxy_key_id":    "Invalid proxy key ID",
+	"validation.proxy_key_invalid":       "Proxy keys cannot contain commas or whitespace and must not exceed {{.max}} characters",
+	"validation.ip_list_invalid":         "Invalid IP rules: {{.error}}",
+	"validation.rate_limit_invalid":      "Rate limits cannot be negative",
+	"validation.proxy_key_grace_invalid": "Grace period must be between 0 and {{.max}} minutes",
+	"validation.invalid_admin_user_id":   "Invalid admin user ID",
+	"validation.invalid_admin_token_id":  "Invalid API token ID",
+	"validation.admin_username_invalid":  "Username is required and cannot be root",
+	"validation.admin_password_too_short": "Password must be at least {{.min}} characters",
+	"validation.admin_role_invalid":      "Invalid role: {{.role}}, must be owner, operator or viewer",
+	"validation.admin_token_name_required": "Token name is required",
+	"validation.admin_token_expiry_invalid": "Token expiry cannot be negative",
 
 	// Task related
 	"task.validation_started": "Key validation task started",
@@ -88,11 +147,14 @@ var MessagesEnUS = map[string]string{
 	"task.delete_started":     "Key deletion task started",
 	"task.already_running":    "A task is already running",
 	"task.get_status_failed":  "Failed to get task status",
+	"task.not_found":          "Task not found or its result has expired",
+	"task.not_cancelable":     "The task has finished or cannot be cancelled",
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "Invalid Keys",
 	"dashboard.success_requests":                                 "Success",
 	"dashboard.failed_requests":                                  "Failed",
+	"dashboard.cost":                                             "Cost",
 	"dashboard.auth_key_missing":                                 "AUTH_KEY is not set, system cannot function properly",
 	"dashboard.auth_key_required":                                "AUTH_KEY must be set to protect the admin interface",
 	"dashboard.encryption_key_missing":                           "ENCRYPTION_KEY is not set, sensitive data will be stored in plain text",
@@ -116,10 +178,18 @@ var MessagesEnUS = map[string]string{
 
 	// Success messages
 	"success.group_deleted":        "Group and related keys deleted successfully",
+	"success.model_price_deleted":  "Model price deleted successfully",
+	"success.proxy_key_deleted":    "Proxy key deleted successfully",
+	"success.auth_ban_removed":     "Ban lifted successfully",
+	"success.encryption_rewrap_started": "Data key re-wrap started in the background",
+	"success.encryption_key_rotation_started": "Encryption key rotation started, records are re-encrypted in the background",
+	"success.admin_user_deleted":   "Admin user deleted successfully",
+	"success.admin_token_deleted":  "API token revoked successfully",
 	"success.keys_restored":        "{{.count}} keys restored",
 	"success.invalid_keys_cleared": "{{.count}} invalid keys cleared",
 	"success.all_keys_cleared":     "{{.count}} keys cleared",
 	"success.groups_reordered":     "Group order saved",
+	"success.key_source_deleted":   "Key source deleted, its keys are kept",
 
 	// Password security related
 	"security.password_too_short":         "{{.keyType}} is too short ({{.length}} characters), recommend at least 16 characters",
@@ -136,7 +206,7 @@ var MessagesEnUS = map[string]string{
 	"config.app_url":                          "Application URL",
 	"config.app_url_desc":                     "Base URL of the application, used for constructing group endpoint addresses. System config takes precedence over APP_URL environment variable.",
 	"config.proxy_keys":                       "Global Proxy Keys",
-	"config.proxy_keys_desc":                  "Global proxy keys for accessing all group proxy endpoints. Separate multiple keys with commas.",
+	"config.proxy_keys_desc":                  "Global proxy keys for accessing all group proxy endpoints. Keys entered here are added as hashes and cannot be displayed again. Separate multiple keys with commas.",
 	"config.log_retention_days":               "Log Retention Days",
 	"config.log_retention_days_desc":          "Number of days to retain request logs in database, 0 to keep logs forever.",
 	"config.log_write_interval":               "Log Write Interval (minutes)",
@@ -171,11 +241,58 @@ var MessagesEnUS = map[string]string{
 	"config.key_validation_concurrency_desc": "Concurrency level for background invalid key validation. Keep below 20 for SQLite or low-performance environments to avoid data consistency issues.",
 	"config.key_validation_timeout":          "Key Validation Timeout (seconds)",
 	"config.key_validation_timeout_desc":     "API request timeout (seconds) when validating a single key in the background.",
+	"config.balance_probe":                   "Balance Probe",
+	"config.balance_probe_desc":              "Fetch the balance of active keys during background validation: off, auto (detect by upstream host), openai_billing, deepseek or openrouter. Keys with no balance left are disabled.",
+	"config.key_validation_cron":             "Validation Cron Schedule",
+	"config.key_validation_cron_desc":        "Cron expression (minute hour day month weekday, in server time) for background key validation, such as '0 */2 * * *'. Replaces the validation interval when set.",
+	"config.key_validation_jitter":           "Validation Jitter (seconds)",
+	"config.key_validation_jitter_desc":      "Background validation of a group starts after a random delay up to this many seconds, so groups do not all validate at once. 0 disables the delay.",
+	"config.active_key_validation_interval":  "Active Key Validation Interval (minutes)",
+	"config.active_key_validation_interval_desc": "How often active keys are validated proactively, so dead keys are found before user traffic hits them. 0 disables it.",
+	"config.active_key_validation_sample_percent": "Active Key Validation Sample (%)",
+	"config.active_key_validation_sample_percent_desc": "Share of active keys validated in each run, starting with the keys validated least recently. 100 validates all of them.",
+	"config.enforce_global_key_uniqueness":             "Enforce Global Key Uniqueness",
+	"config.enforce_global_key_uniqueness_desc":        "Reject keys that already exist in any other group when adding or importing keys, and disallow copying keys between groups.",
+
+	// Aggregate group settings related
+	"config.enable_adaptive_weights":      "Enable Adaptive Weights",
+	"config.enable_adaptive_weights_desc": "Scale sub-group weights of aggregate groups by observed latency and error rate, so degraded providers receive less traffic.",
+	"config.adaptive_min_weight":          "Adaptive Min Weight",
+	"config.adaptive_min_weight_desc":     "Lower bound of the effective weight of a sub-group in adaptive mode.",
+	"config.adaptive_max_weight":          "Adaptive Max Weight",
+	"config.adaptive_max_weight_desc":     "Upper bound of the effective weight of a sub-group in adaptive mode.",
+	"config.proxy_ip_allowlist":           "Proxy IP Allowlist",
+	"config.proxy_ip_allowlist_desc":      "IPs or CIDRs allowed to use the proxy endpoints, separated by commas. Empty allows all addresses. Groups can set their own list, which applies in addition to the global list.",
+	"config.proxy_ip_denylist":            "Proxy IP Denylist",
+	"config.proxy_ip_denylist_desc":       "IPs or CIDRs that may not use the proxy endpoints, separated by commas. The denylist takes precedence over the allowlist.",
+	"config.admin_ip_allowlist":           "Admin IP Allowlist",
+	"config.admin_ip_allowlist_desc":      "IPs or CIDRs allowed to access the admin console and API, separated by commas. Empty allows all addresses. Your current address must remain allowed.",
+	"config.admin_ip_denylist":            "Admin IP Denylist",
+	"config.admin_ip_denylist_desc":       "IPs or CIDRs that may not access the admin console and API, separated by commas.",
+	"config.auth_max_failures":            "Max Failed Auth Attempts",
+	"config.auth_max_failures_desc":       "Failed login or proxy key attempts from one IP or against one key prefix within the window before a temporary ban. Failures are delayed progressively before that. 0 disables the protection.",
+	"config.auth_failure_window":          "Failed Attempt Window (minutes)",
+	"config.auth_failure_window_desc":     "Time window in which failed authentication attempts are counted.",
+	"config.auth_ban_minutes":             "Ban Duration (minutes)",
+	"config.auth_ban_minutes_desc":        "Duration of the first temporary ban. Repeated bans within 24 hours double the duration, up to 24 hours.",
+	"config.rate_limit_rpm":               "Requests per Minute",
+	"config.rate_limit_rpm_desc":          "Maximum requests per minute for a group across all nodes, in a sliding window. 0 means unlimited.",
+	"config.rate_limit_tpm":               "Tokens per Minute",
+	"config.rate_limit_tpm_desc":          "Maximum input and output tokens per minute for a group across all nodes. Requests are rejected once the limit is reached. 0 means unlimited.",
+	"config.rate_limit_concurrency":       "Concurrent Requests",
+	"config.rate_limit_concurrency_desc":  "Maximum requests and streams a group serves at the same time across all nodes. 0 means unlimited.",
+	"config.request_queue_size":           "Request Queue Size",
+	"config.request_queue_size_desc":      "Maximum requests waiting for a concurrency slot or an active key instead of failing immediately, across all nodes. Clients take turns by proxy key. 0 disables queueing.",
+	"config.request_queue_timeout":        "Max Queue Wait (seconds)",
+	"config.request_queue_timeout_desc":   "Maximum time a request waits in the queue before it is rejected.",
 
 	// Category labels
-	"config.category.basic":   "Basic",
-	"config.category.request": "Request Settings",
-	"config.category.key":     "Key Configuration",
+	"config.category.basic":      "Basic",
+	"config.category.request":    "Request Settings",
+	"config.category.key":        "Key Configuration",
+	"config.category.aggregate":  "Aggregate Groups",
+	"config.category.access":     "Access Control",
+	"config.category.rate_limit": "Rate Limiting",
 
 	// Internal error messages (for fmt.Errorf usage)
 	"error.upstreams_required":       "upstreams field is required",
@@ -186,26 +303,59 @@ var MessagesEnUS = map[string]string{
 	"error.marshal_upstreams_failed": "failed to marshal cleaned upstreams",
 	"error.invalid_config_format":    "Invalid config format: {{.error}}",
 	"error.process_header_rules":     "Failed to process header rules: {{.error}}",
+	"error.process_budgets":          "Failed to process budgets: {{.error}}",
 	"error.invalidate_group_cache":   "failed to invalidate group cache",
 	"error.unmarshal_header_rules":   "Failed to unmarshal header rules",
 	"error.delete_group_cache":       "Failed to delete group: unable to clean up cache",
 	"error.decrypt_key_copy":         "Failed to decrypt key during group copy, skipping",
 	"error.start_import_task":        "Failed to start async key import task for group copy",
 	"error.export_logs":              "Failed to export logs",
+	"error.export_audit_logs":        "Failed to export audit logs",
 
 	// Login related
 	"auth.invalid_request":           "Invalid request format",
 	"auth.authentication_successful": "Authentication successful",
 	"auth.authentication_failed":     "Authentication failed",
+	"auth.ip_not_allowed":            "Access from this IP address is not allowed",
+	"auth.too_many_attempts":         "Too many failed attempts, please try again in {{.minutes}} minutes",
 
 	// Settings success message
-	"settings.update_success": "Settings updated successfully. Configuration will be reloaded in the background across all instances.",
+	"settings.update_success":   "Settings updated successfully. Configuration will be reloaded in the background across all instances.",
+	"settings.admin_ip_lockout": "These admin IP rules would block your current address {{.ip}}. Allow it before saving.",
 
 	// Sub-groups related
-	"success.sub_groups_added":         "Sub groups added successfully",
-	"success.sub_group_weight_updated": "Sub group weight updated successfully",
-	"success.sub_group_deleted":        "Sub group deleted successfully",
-	"group.not_aggregate":              "Group is not an aggregate group",
-	"group.sub_group_already_exists":   "Sub group {{.sub_group_id}} already exists",
-	"group.sub_group_not_found":        "Sub group not found",
+	"success.sub_groups_added":           "Sub groups added successfully",
+	"success.sub_group_weight_updated":   "Sub group weight updated successfully",
+	"success.sub_group_models_updated":   "Sub group models updated successfully",
+	"success.sub_group_schedule_updated": "Sub group schedule updated successfully",
+	"success.sub_group_priority_updated": "Sub group priority updated successfully",
+	"success.sub_group_deleted":          "Sub group deleted successfully",
+	"group.not_aggregate":                "Group is not an aggregate group",
+	"group.sub_group_already_exists":     "Sub group {{.sub_group_id}} already exists",
+	"group.sub_group_not_found":          "Sub group not found",
+
+	// Model price related
+	"model_price.not_found": "Model price not found",
+	"proxy_key.not_found":   "Proxy key not found",
+	"key_source.not_found":  "Key source not found",
+	"key_source.directory_owner_only": "Only owners can configure directory sources",
+	"proxy_key.duplicate":   "This proxy key already exists",
+	"proxy_key.already_rotated": "This proxy key has already been rotated",
+	"auth_guard.ban_not_found":  "Ban not found or already expired",
+	"auth_guard.subject_required": "Ban subject is required",
+	"encryption.envelope_disabled": "Envelope encryption is not enabled, set ENCRYPTION_PROVIDER to use it",
+	"encryption.rewrap_running": "A data key re-wrap is already running",
+	"encryption.provider_unavailable": "Master key provider is unavailable: {{.error}}",
+	"encryption.new_key_required":     "New encryption key is required",
+	"encryption.key_rotation_requires_key": "Online rotation requires a configured ENCRYPTION_KEY, use migrate-keys --to to enable encryption",
+	"encryption.key_unchanged":        "New encryption key must differ from the current key",
+	"encryption.key_rotation_running": "An encryption key rotation is already running",
+	"encryption.key_rotation_blocked": "Encryption key rotation is blocked: {{.reason}}",
+
+	// Admin account related
+	"admin.user_not_found":           "Admin user not found",
+	"admin.token_not_found":          "API token not found",
+	"admin.token_scope_exceeds_role": "Token scope cannot exceed your own role",
+	"admin.token_requires_account":   "API tokens can only be managed by an admin account",
+	"admin.last_owner":               "At least one enabled owner account must remain",
 }
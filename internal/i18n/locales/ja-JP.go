@@ -48,14 +48,56 @@ var MessagesJaJP = map[string]string{
 	"validation.invalid_group_name":      "無効なグループ名。小文字、数字、ハイフン、アンダースコアのみ使用可能、1-100文字",
 	"validation.invalid_test_path":       "無効なテストパス。指定する場合は / で始まる有効なパスであり、完全なURLではない必要があります。",
 	"validation.duplicate_header":        "重複ヘッダー: {{.key}}",
+	"validation.invalid_budget_period":   "無効な予算期間: {{.period}}、daily または monthly を指定してください",
+	"validation.invalid_budget_metric":   "無効な予算指標: {{.metric}}、requests、tokens または cost を指定してください",
+	"validation.budget_limit_negative":   "予算上限に負の値は指定できません",
+	"validation.budget_soft_exceeds_hard": "予算のソフト上限はハード上限を超えられません",
+	"validation.duplicate_budget":        "重複した予算: {{.period}} {{.metric}}",
 	"validation.group_not_found":         "グループが見つかりません",
 	"validation.invalid_status_filter":   "無効なステータスフィルター",
+	"validation.invalid_export_format":   "無効なエクスポート形式です。txt または csv を指定してください",
+	"validation.invalid_transfer_mode":   "無効な転送モードです。move または copy を指定してください",
+	"validation.transfer_same_group":     "転送元と転送先のグループは異なる必要があります",
+	"validation.transfer_to_aggregate_group": "集約グループにキーを転送することはできません",
+	"validation.copy_keys_not_unique":    "グローバルなキーの一意性が有効なため、キーをコピーできません。移動してください",
+	"validation.no_keys_selected":        "選択条件に一致するキーがありません",
+	"validation.invalid_active_schedule": "無効な有効時間帯：{{.error}}",
+	"validation.invalid_group_state":     "無効なグループ状態です。enabled、draining、disabled、maintenance のいずれかを指定してください",
+	"validation.invalid_state_status_code": "ステータスコードは 400 から 599 の間で指定してください",
+	"validation.state_message_too_long":  "状態メッセージは 512 文字以内で指定してください",
+	"validation.invalid_config_document": "無効な設定ファイルです：{{.error}}",
+	"validation.unsupported_config_version": "サポートされていない設定バージョン {{.version}} です。1 を指定してください",
+	"validation.invalid_config_format":   "無効な形式です。yaml または json を指定してください",
+	"validation.config_document_too_large": "設定ファイルが大きすぎます",
+	"validation.config_duplicate_group":  "グループ '{{.group}}' が重複して宣言されています",
+	"validation.config_group_type_changed": "グループタイプは変更できません。先にグループを削除してください",
+	"validation.config_invalid_settings": "無効なシステム設定です：{{.error}}",
+	"validation.config_invalid_sub_group": "サブグループ '{{.sub_group}}' が空、重複、または未宣言です",
+	"validation.config_aggregate_keys":   "集約グループはキーを持てません",
+	"validation.config_keys_require_encryption": "キーのエクスポートとインポートは暗号化が有効な場合のみ可能です",
+	"validation.config_key_decrypt_failed": "{{.index}} 番目のキーを現在の暗号化設定で復号できません",
+	"validation.config_invalid_key":      "{{.index}} 番目のキーが無効です：{{.error}}",
+	"validation.config_group_invalid":    "グループ '{{.group}}'：{{.error}}",
+	"validation.unsupported_key_file":    "サポートされていないキーファイルです。.txt、.csv、.json のいずれかである必要があります",
+	"validation.invalid_key_format":      "無効なキー形式です。auto、txt、csv、json のいずれかである必要があります",
+	"validation.invalid_key_source_id":   "無効なキーソースIDです",
+	"validation.key_source_name_required": "キーソース名は必須です",
+	"validation.invalid_key_source_type": "無効なキーソースタイプです。url、vault、directory のいずれかである必要があります",
+	"validation.invalid_key_source_address": "無効なキーソースアドレスです。http(s) URL またはディレクトリパスである必要があります",
+	"validation.key_source_address_not_allowed": "KEY_SOURCE_ALLOW_PRIVATE_NETWORKS を有効にしない限り、キーソースにループバック、プライベート、リンクローカルアドレスは使用できません",
+	"validation.key_source_directory_disabled": "ディレクトリソースは無効です。有効にするには KEY_SOURCE_DIR を設定してください",
+	"validation.key_source_directory_outside_root": "ディレクトリは {{.root}} の中にある必要があります",
+	"validation.key_source_path_required": "Vault シークレットパスは必須です",
+	"validation.invalid_missing_action":  "無効な欠落キーの処理です。keep、disable、remove のいずれかである必要があります",
+	"validation.invalid_sync_interval":   "同期間隔は 0 以上である必要があります",
+	"validation.invalid_tag_filter":      "無効なタグフィルターです。name または name:value を使用してください",
 	"validation.invalid_group_id":        "無効なグループID形式",
 	"validation.test_model_required":     "テストモデルが必要です",
 	"validation.invalid_copy_keys_value": "無効なcopy_keys値。'none'、'valid_only'、'all'のいずれかである必要があります",
 	"validation.invalid_channel_type":    "無効なチャンネルタイプ。サポートされるタイプ: {{.types}}",
 	"validation.test_model_empty":        "テストモデルは空またはスペースのみにできません",
 	"validation.invalid_status_value":    "無効なステータス値",
+	"validation.invalid_limit":           "無効な件数制限です。正の整数である必要があります",
 	"validation.invalid_upstreams":       "無効なupstreams設定: {{.error}}",
 	"validation.group_id_required":       "group_idクエリパラメータが必要です",
 	"validation.invalid_group_id_format": "無効なgroup_id形式",
@@ -73,6 +115,8 @@ var MessagesJaJP = map[string]string{
 	"validation.sub_group_validation_endpoint_mismatch": "サブグループのエンドポイントが一致していません。集約グループには、リクエストの転送を成功させるため統一されたアップストリームパスが必要です",
 	"validation.sub_group_weight_negative":     "サブグループの重みは負の値にできません",
 	"validation.sub_group_weight_max_exceeded": "サブグループの重みは1000を超えることはできません",
+	"validation.sub_group_model_empty": "サブグループのモデル名は空にできません",
+	"validation.sub_group_priority_out_of_range": "サブグループの優先度は0から100の間である必要があります",
 	"validation.sub_group_referenced_cannot_modify": "このグループは {{.count}} 個の集約グループでサブグループとして参照されています。チャンネルタイプまたは検証エンドポイントは変更できません。変更前に関連する集約グループからこのグループを削除してください",
 	"validation.standard_group_requires_upstreams_testmodel": "標準グループへの変換にはアップストリームサーバーとテストモデルの提供が必要です",
 	"validation.aggregate_no_model_redirect": "集約グループはモデルリダイレクトルールをサポートしていません",
@@ -81,6 +125,21 @@ var MessagesJaJP = map[string]string{
 	"validation.reorder_sort_negative":  "並び順の値は負数にできません",
 	"validation.reorder_duplicate_group": "並び替え項目に重複したグループIDがあります: {{.id}}",
 	"validation.reorder_group_not_found": "並び替え項目に存在しないグループが含まれています",
+	"validation.invalid_model_price_id":  "無効なモデル価格ID",
+	"validation.model_pattern_required":  "モデルパターンは必須です",
+	"validation.model_price_negative":    "モデル価格は負の値にできません",
+	"validation.invalid_proxy_key_id":    "無効なプロキシキーIDです",
+	"validation.proxy_key_invalid":       "プロキシキーにはカンマや空白を含めることができず、{{.max}}文字以内である必要があります",
+	"validation.ip_list_invalid":         "無効な IP ルール：{{.error}}",
+	"validation.rate_limit_invalid":      "レート制限の値は負にできません",
+	"validation.proxy_key_grace_invalid": "猶予期間は0から{{.max}}分の間で指定してください",
+	"validation.invalid_admin_user_id":   "無効な管理者アカウントID",
+	"validation.invalid_admin_token_id":  "無効なAPIトークンID",
+	"validation.admin_username_invalid":  "ユーザー名は必須で、root は使用できません",
+	"validation.admin_password_too_short": "パスワードは {{.min}} 文字以上である必要があります",
+	"validation.admin_role_invalid":      "無効なロール: {{.role}}、owner、operator または viewer を指定してください",
+	"validation.admin_token_name_required": "トークン名は必須です",
+	"validation.admin_token_expiry_invalid": "トークンの有効期限に負の値は指定できません",
 
 	// Task related
 	"task.validation_started": "キー検証タスクが開始されました",
@@ -88,11 +147,14 @@ var MessagesJaJP = map[string]string{
 	"task.delete_started":     "キー削除タスクが開始されました",
 	"task.already_running":    "タスクが既に実行中です",
 	"task.get_status_failed":  "タスクステータスの取得に失敗しました",
+	"task.not_found":          "タスクが見つからないか、結果の保存期限が切れています",
+	"task.not_cancelable":     "タスクは終了しているか、キャンセルできません",
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "無効なキー",
 	"dashboard.success_requests":                                 "成功",
 	"dashboard.failed_requests":                                  "失敗",
+	"dashboard.cost":                                             "コスト",
 	"dashboard.auth_key_missing":                                 "AUTH_KEYが設定されていません。システムが正常に動作しません",
 	"dashboard.auth_key_required":                                "管理インターフェースを保護するためAUTH_KEYを設定する必要があります",
 	"dashboard.encryption_key_missing":                           "ENCRYPTION_KEYが設定されていません。機密データがプレーンテキストで保存されます",
@@ -116,10 +178,18 @@ var MessagesJaJP = map[string]string{
 
 	// Success messages
 	"success.group_deleted":        "グループと関連キーが正常に削除されました",
+	"success.model_price_deleted":  "モデル価格が正常に削除されました",
+	"success.proxy_key_deleted":    "プロキシキーが正常に削除されました",
+	"success.auth_ban_removed":     "禁止を解除しました",
+	"success.encryption_rewrap_started": "データキーの再ラップをバックグラウンドで開始しました",
+	"success.encryption_key_rotation_started": "暗号化キーのローテーションを開始しました。データはバックグラウンドで再暗号化されます",
+	"success.admin_user_deleted":   "管理者アカウントが正常に削除されました",
+	"success.admin_token_deleted":  "APIトークンが取り消されました",
 	"success.keys_restored":        "{{.count}}個のキーが復元されました",
 	"success.invalid_keys_cleared": "{{.count}}個の無効なキーがクリアされました",
 	"success.all_keys_cleared":     "{{.count}}個のキーがクリアされました",
 	"success.groups_reordered":     "グループの並び順を保存しました",
+	"success.key_source_deleted":   "キーソースを削除しました。インポートされたキーは保持されます",
 
 	// Password security related
 	"security.password_too_short":         "{{.keyType}}が短すぎます（{{.length}}文字）。少なくとも16文字を推奨します",
@@ -136,7 +206,7 @@ var MessagesJaJP = map[string]string{
 	"config.app_url":                          "アプリケーションURL",
 	"config.app_url_desc":                     "アプリケーションのベースURL。グループエンドポイントアドレスの構築に使用されます。システム設定が環境変数APP_URLより優先されます。",
 	"config.proxy_keys":                       "グローバルプロキシキー",
-	"config.proxy_keys_desc":                  "すべてのグループプロキシエンドポイントにアクセスするためのグローバルプロキシキー。複数のキーはカンマで区切ります。",
+	"config.proxy_keys_desc":                  "すべてのグループプロキシエンドポイントにアクセスするためのグローバルプロキシキー。ここで入力したキーはハッシュとして追加され、保存後は再表示できません。複数のキーはカンマで区切ります。",
 	"config.log_retention_days":               "ログ保存期間（日）",
 	"config.log_retention_days_desc":          "データベースにリクエストログを保持する日数、0でログを永久保存。",
 	"config.log_write_interval":               "ログ書き込み間隔（分）",
@@ -171,11 +241,58 @@ var MessagesJaJP = map[string]string{
 	"config.key_validation_concurrency_desc": "バックグラウンドで無効なキーを検証する際の並行数。SQLiteや低性能環境では20以下を維持し、データ不整合を回避してください。",
 	"config.key_validation_timeout":          "キー検証タイムアウト（秒）",
 	"config.key_validation_timeout_desc":     "バックグラウンドで単一キーを検証する際のAPIリクエストタイムアウト（秒）。",
+	"config.balance_probe":                   "残高プローブ",
+	"config.balance_probe_desc":              "バックグラウンド検証時に有効なキーの残高を取得します：off、auto（アップストリームのホストで判定）、openai_billing、deepseek、openrouter。残高がなくなったキーは自動的に無効化されます。",
+	"config.key_validation_cron":             "検証 Cron スケジュール",
+	"config.key_validation_cron_desc":        "バックグラウンドでのキー検証の Cron 式（分 時 日 月 曜日、サーバー時刻）。例：'0 */2 * * *'。設定すると検証間隔の代わりに使用されます。",
+	"config.key_validation_jitter":           "検証ジッター（秒）",
+	"config.key_validation_jitter_desc":      "グループのバックグラウンド検証は最大この秒数のランダムな遅延の後に開始され、すべてのグループが同時に検証されるのを防ぎます。0 で遅延なし。",
+	"config.active_key_validation_interval":  "有効キー検証間隔（分）",
+	"config.active_key_validation_interval_desc": "有効なキーを能動的に検証する間隔です。ユーザーのリクエストが失敗する前に無効になったキーを見つけます。0 で無効。",
+	"config.active_key_validation_sample_percent": "有効キー検証サンプル率（%）",
+	"config.active_key_validation_sample_percent_desc": "各実行で検証する有効キーの割合で、最も長く検証されていないキーから検証します。100 ですべて検証します。",
+	"config.enforce_global_key_uniqueness":             "キーのグローバル一意性を強制",
+	"config.enforce_global_key_uniqueness_desc":        "キーの追加やインポート時に他のグループに既に存在するキーを拒否し、グループ間のキーのコピーを禁止します。",
+
+	// Aggregate group settings related
+	"config.enable_adaptive_weights":      "適応型重みを有効化",
+	"config.enable_adaptive_weights_desc": "観測されたレイテンシとエラー率に基づいて集約グループのサブグループの重みを調整し、劣化したプロバイダーへのトラフィックを減らします。",
+	"config.adaptive_min_weight":          "適応型最小重み",
+	"config.adaptive_min_weight_desc":     "適応モードにおけるサブグループの実効重みの下限。",
+	"config.adaptive_max_weight":          "適応型最大重み",
+	"config.adaptive_max_weight_desc":     "適応モードにおけるサブグループの実効重みの上限。",
+	"config.proxy_ip_allowlist":           "プロキシ IP 許可リスト",
+	"config.proxy_ip_allowlist_desc":      "プロキシエンドポイントの利用を許可する IP または CIDR（カンマ区切り）。空の場合は制限しません。グループ独自のリストはグローバルリストに加えて適用されます。",
+	"config.proxy_ip_denylist":            "プロキシ IP 拒否リスト",
+	"config.proxy_ip_denylist_desc":       "プロキシエンドポイントの利用を拒否する IP または CIDR（カンマ区切り）。拒否リストは許可リストより優先されます。",
+	"config.admin_ip_allowlist":           "管理 IP 許可リスト",
+	"config.admin_ip_allowlist_desc":      "管理画面と管理 API へのアクセスを許可する IP または CIDR（カンマ区切り）。空の場合は制限しません。現在のアドレスは許可されたままである必要があります。",
+	"config.admin_ip_denylist":            "管理 IP 拒否リスト",
+	"config.admin_ip_denylist_desc":       "管理画面と管理 API へのアクセスを拒否する IP または CIDR（カンマ区切り）。",
+	"config.auth_max_failures":            "認証失敗の上限回数",
+	"config.auth_max_failures_desc":       "同じ IP または同じキープレフィックスでのログインやプロキシキー認証の失敗がウィンドウ内でこの回数に達すると一時的に禁止されます。それまでは失敗の応答が段階的に遅延します。0 で保護を無効化します。",
+	"config.auth_failure_window":          "失敗カウントウィンドウ（分）",
+	"config.auth_failure_window_desc":     "認証失敗回数を数える時間枠。",
+	"config.auth_ban_minutes":             "禁止時間（分）",
+	"config.auth_ban_minutes_desc":        "最初の一時禁止の時間。24 時間以内に再度禁止されると時間が倍になり、最長 24 時間です。",
+	"config.rate_limit_rpm":               "1 分あたりのリクエスト数",
+	"config.rate_limit_rpm_desc":          "全ノードでのグループの 1 分あたりの最大リクエスト数（スライディングウィンドウ）。0 は無制限です。",
+	"config.rate_limit_tpm":               "1 分あたりのトークン数",
+	"config.rate_limit_tpm_desc":          "全ノードでのグループの 1 分あたりの最大入出力トークン数。上限に達するとリクエストを拒否します。0 は無制限です。",
+	"config.rate_limit_concurrency":       "同時リクエスト数",
+	"config.rate_limit_concurrency_desc":  "全ノードでグループが同時に処理する最大リクエスト数とストリーム数。0 は無制限です。",
+	"config.request_queue_size":           "リクエストキューサイズ",
+	"config.request_queue_size_desc":      "即座に失敗させずに同時実行枠または有効なキーを待つ最大リクエスト数（全ノード合計）。プロキシキーごとに順番に処理されます。0 でキューを無効にします。",
+	"config.request_queue_timeout":        "最大待機時間（秒）",
+	"config.request_queue_timeout_desc":   "リクエストがキューで待機する最大時間。超えると拒否されます。",
 
 	// Category labels
-	"config.category.basic":   "基本設定",
-	"config.category.request": "リクエスト設定",
-	"config.category.key":     "キー設定",
+	"config.category.basic":      "基本設定",
+	"config.category.request":    "リクエスト設定",
+	"config.category.key":        "キー設定",
+	"config.category.aggregate":  "集約グループ",
+	"config.category.access":     "アクセス制御",
+	"config.category.rate_limit": "レート制限",
 
 	// Internal error messages (for fmt.Errorf usage)
 	"error.upstreams_required":       "upstreamsフィールドは必須です",
@@ -186,26 +303,59 @@ var MessagesJaJP = map[string]string{
 	"error.marshal_upstreams_failed": "クリーンアップされたupstreamsのシリアル化に失敗しました",
 	"error.invalid_config_format":    "無効な設定形式: {{.error}}",
 	"error.process_header_rules":     "ヘッダールールの処理に失敗しました: {{.error}}",
+	"error.process_budgets":          "予算設定の処理に失敗しました: {{.error}}",
 	"error.invalidate_group_cache":   "グループキャッシュの無効化に失敗しました",
 	"error.unmarshal_header_rules":   "ヘッダールールのアンマーシャルに失敗しました",
 	"error.delete_group_cache":       "グループの削除に失敗: キャッシュをクリーンアップできません",
 	"error.decrypt_key_copy":         "グループコピー中のキー復号化に失敗、スキップします",
 	"error.start_import_task":        "グループコピー用の非同期キーインポートタスクの開始に失敗しました",
 	"error.export_logs":              "ログのエクスポートに失敗しました",
+	"error.export_audit_logs":        "監査ログのエクスポートに失敗しました",
 
 	// Login related
 	"auth.invalid_request":           "無効なリクエスト形式",
 	"auth.authentication_successful": "認証成功",
 	"auth.authentication_failed":     "認証失敗",
+	"auth.ip_not_allowed":            "この IP アドレスからのアクセスは許可されていません",
+	"auth.too_many_attempts":         "失敗回数が多すぎます。{{.minutes}} 分後に再試行してください",
 
 	// Settings success message
-	"settings.update_success": "設定が正常に更新されました。設定はすべてのインスタンスでバックグラウンドで再読み込みされます。",
+	"settings.update_success":   "設定が正常に更新されました。設定はすべてのインスタンスでバックグラウンドで再読み込みされます。",
+	"settings.admin_ip_lockout": "この管理 IP ルールでは現在のアドレス {{.ip}} がブロックされます。保存する前に許可してください。",
 
 	// Sub-groups related
-	"success.sub_groups_added":         "サブグループが正常に追加されました",
-	"success.sub_group_weight_updated": "サブグループの重みが正常に更新されました",
-	"success.sub_group_deleted":        "サブグループが正常に削除されました",
-	"group.not_aggregate":              "グループはアグリゲートグループではありません",
-	"group.sub_group_already_exists":   "サブグループ{{.sub_group_id}}は既に存在します",
-	"group.sub_group_not_found":        "サブグループが見つかりません",
+	"success.sub_groups_added":           "サブグループが正常に追加されました",
+	"success.sub_group_weight_updated":   "サブグループの重みが正常に更新されました",
+	"success.sub_group_models_updated":   "サブグループのモデルが正常に更新されました",
+	"success.sub_group_schedule_updated": "サブグループの時間帯が正常に更新されました",
+	"success.sub_group_priority_updated": "サブグループの優先度が正常に更新されました",
+	"success.sub_group_deleted":          "サブグループが正常に削除されました",
+	"group.not_aggregate":                "グループはアグリゲートグループではありません",
+	"group.sub_group_already_exists":     "サブグループ{{.sub_group_id}}は既に存在します",
+	"group.sub_group_not_found":          "サブグループが見つかりません",
+
+	// Model price related
+	"model_price.not_found": "モデル価格が見つかりません",
+	"proxy_key.not_found":   "プロキシキーが見つかりません",
+	"key_source.not_found":  "キーソースが見つかりません",
+	"key_source.directory_owner_only": "ディレクトリソースを設定できるのはオーナーのみです",
+	"proxy_key.duplicate":   "このプロキシキーは既に存在します",
+	"proxy_key.already_rotated": "このプロキシキーは既にローテーションされています",
+	"auth_guard.ban_not_found":  "禁止が見つからないか、すでに期限切れです",
+	"auth_guard.subject_required": "禁止対象が必要です",
+	"encryption.envelope_disabled": "エンベロープ暗号化が有効になっていません。ENCRYPTION_PROVIDER を設定してください",
+	"encryption.rewrap_running": "データキーの再ラップは既に実行中です",
+	"encryption.provider_unavailable": "マスターキープロバイダーを利用できません：{{.error}}",
+	"encryption.new_key_required":     "新しい暗号化キーは必須です",
+	"encryption.key_rotation_requires_key": "オンラインローテーションには ENCRYPTION_KEY の設定が必要です。暗号化を有効にするには migrate-keys --to を使用してください",
+	"encryption.key_unchanged":        "新しい暗号化キーは現在のキーと異なる必要があります",
+	"encryption.key_rotation_running": "暗号化キーのローテーションは既に実行中です",
+	"encryption.key_rotation_blocked": "暗号化キーをローテーションできません：{{.reason}}",
+
+	// Admin account related
+	"admin.user_not_found":           "管理者アカウントが見つかりません",
+	"admin.token_not_found":          "APIトークンが見つかりません",
+	"admin.token_scope_exceeds_role": "トークンの権限は自分のロールを超えられません",
+	"admin.token_requires_account":   "API トークンは管理者アカウントでのみ管理できます",
+	"admin.last_owner":               "有効な owner アカウントを少なくとも1つ残す必要があります",
 }
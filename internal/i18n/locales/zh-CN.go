@@ -48,14 +48,56 @@ var MessagesZhCN = map[string]string{
 	"validation.invalid_group_name":      "无效的分组名称。只能包含小写字母、数字、中划线或下划线，长度1-100位",
 	"validation.invalid_test_path":       "无效的测试路径。如果提供，必须是以 / 开头的有效路径，且不能是完整的URL。",
 	"validation.duplicate_header":        "重复的请求头: {{.key}}",
+	"validation.invalid_budget_period":   "无效的预算周期: {{.period}}，必须为 daily 或 monthly",
+	"validation.invalid_budget_metric":   "无效的预算指标: {{.metric}}，必须为 requests、tokens 或 cost",
+	"validation.budget_limit_negative":   "预算限额不能为负数",
+	"validation.budget_soft_exceeds_hard": "预算软限额不能超过硬限额",
+	"validation.duplicate_budget":        "重复的预算: {{.period}} {{.metric}}",
 	"validation.group_not_found":         "分组不存在",
 	"validation.invalid_status_filter":   "无效的状态过滤器",
+	"validation.invalid_export_format":   "无效的导出格式，必须为 txt 或 csv",
+	"validation.invalid_transfer_mode":   "无效的转移模式，必须为 move 或 copy",
+	"validation.transfer_same_group":     "源分组和目标分组不能相同",
+	"validation.transfer_to_aggregate_group": "不能将密钥转移到聚合分组",
+	"validation.copy_keys_not_unique":    "已启用全局密钥唯一性，无法复制密钥，请改为移动",
+	"validation.no_keys_selected":        "没有符合选择条件的密钥",
+	"validation.invalid_active_schedule": "无效的生效时间窗口：{{.error}}",
+	"validation.invalid_group_state":     "无效的分组状态，必须是 enabled、draining、disabled 或 maintenance",
+	"validation.invalid_state_status_code": "状态码必须在 400 到 599 之间",
+	"validation.state_message_too_long":  "状态提示信息不能超过 512 个字符",
+	"validation.invalid_config_document": "无效的配置文件：{{.error}}",
+	"validation.unsupported_config_version": "不支持的配置版本 {{.version}}，必须为 1",
+	"validation.invalid_config_format":   "无效的格式，必须是 yaml 或 json",
+	"validation.config_document_too_large": "配置文件过大",
+	"validation.config_duplicate_group":  "分组 '{{.group}}' 被重复声明",
+	"validation.config_group_type_changed": "不能修改分组类型，请先删除该分组",
+	"validation.config_invalid_settings": "无效的系统设置：{{.error}}",
+	"validation.config_invalid_sub_group": "子分组 '{{.sub_group}}' 为空、重复或未声明",
+	"validation.config_aggregate_keys":   "聚合分组不能包含密钥",
+	"validation.config_keys_require_encryption": "只有启用加密后才能导出或导入密钥",
+	"validation.config_key_decrypt_failed": "第 {{.index}} 个密钥无法用当前加密配置解密",
+	"validation.config_invalid_key":      "第 {{.index}} 个密钥无效：{{.error}}",
+	"validation.config_group_invalid":    "分组 '{{.group}}'：{{.error}}",
+	"validation.unsupported_key_file":    "不支持的密钥文件，必须是 .txt、.csv 或 .json",
+	"validation.invalid_key_format":      "无效的密钥格式，必须是 auto、txt、csv 或 json",
+	"validation.invalid_key_source_id":   "无效的密钥来源ID",
+	"validation.key_source_name_required": "密钥来源名称不能为空",
+	"validation.invalid_key_source_type": "无效的密钥来源类型，必须是 url、vault 或 directory",
+	"validation.invalid_key_source_address": "无效的密钥来源地址，必须是 http(s) URL 或目录路径",
+	"validation.key_source_address_not_allowed": "除非启用 KEY_SOURCE_ALLOW_PRIVATE_NETWORKS，密钥来源不能使用回环、私有或链路本地地址",
+	"validation.key_source_directory_disabled": "目录来源未启用，请设置 KEY_SOURCE_DIR",
+	"validation.key_source_directory_outside_root": "目录必须位于 {{.root}} 之内",
+	"validation.key_source_path_required": "Vault 密钥路径不能为空",
+	"validation.invalid_missing_action":  "无效的缺失密钥处理方式，必须是 keep、disable 或 remove",
+	"validation.invalid_sync_interval":   "同步间隔必须大于或等于 0",
+	"validation.invalid_tag_filter":      "无效的标签过滤器，请使用 name 或 name:value",
 	"validation.invalid_group_id":        "无效的分组ID格式",
 	"validation.test_model_required":     "测试模型是必需的",
 	"validation.invalid_copy_keys_value": "无效的copy_keys值。必须是'none'、'valid_only'或'all'",
 	"validation.invalid_channel_type":    "无效的通道类型。支持的类型有: {{.types}}",
 	"validation.test_model_empty":        "测试模型不能为空或只有空格",
 	"validation.invalid_status_value":    "无效的状态值",
+	"validation.invalid_limit":           "无效的数量限制，必须是正整数",
 	"validation.invalid_upstreams":       "upstreams配置错误: {{.error}}",
 	"validation.group_id_required":       "需要提供group_id参数",
 	"validation.invalid_group_id_format": "无效的group_id格式",
@@ -73,6 +115,8 @@ var MessagesZhCN = map[string]string{
 	"validation.sub_group_validation_endpoint_mismatch": "子分组请求端点不一致，聚合分组需要统一的上游请求路径以确保透传成功",
 	"validation.sub_group_weight_negative":     "子分组权重不能为负数",
 	"validation.sub_group_weight_max_exceeded": "子分组权重不能超过1000",
+	"validation.sub_group_model_empty": "子分组模型名称不能为空",
+	"validation.sub_group_priority_out_of_range": "子分组优先级必须在0到100之间",
 	"validation.sub_group_referenced_cannot_modify": "该分组正被 {{.count}} 个聚合分组引用为子分组，无法修改渠道类型或验证端点。请先从相关聚合分组中移除此分组后再进行修改",
 	"validation.standard_group_requires_upstreams_testmodel": "转换为标准分组需要提供上游服务器和测试模型",
 	"validation.aggregate_no_model_redirect": "聚合分组不支持配置模型重定向规则",
@@ -81,6 +125,21 @@ var MessagesZhCN = map[string]string{
 	"validation.reorder_sort_negative":  "排序值不能为负数",
 	"validation.reorder_duplicate_group": "排序项中存在重复分组ID: {{.id}}",
 	"validation.reorder_group_not_found": "排序项包含不存在的分组",
+	"validation.invalid_model_price_id":  "无效的模型价格ID",
+	"validation.model_pattern_required":  "模型匹配规则不能为空",
+	"validation.model_price_negative":    "模型价格不能为负数",
+	"validation.invalid_proxy_key_id":    "无效的代理密钥ID",
+	"validation.proxy_key_invalid":       "代理密钥不能包含逗号或空白字符，且长度不能超过{{.max}}个字符",
+	"validation.ip_list_invalid":         "IP 规则无效：{{.error}}",
+	"validation.rate_limit_invalid":      "限流值不能为负数",
+	"validation.proxy_key_grace_invalid": "宽限期必须在0到{{.max}}分钟之间",
+	"validation.invalid_admin_user_id":   "无效的管理员账号ID",
+	"validation.invalid_admin_token_id":  "无效的 API 令牌ID",
+	"validation.admin_username_invalid":  "用户名不能为空且不能为 root",
+	"validation.admin_password_too_short": "密码长度至少为 {{.min}} 个字符",
+	"validation.admin_role_invalid":      "无效的角色: {{.role}}，必须为 owner、operator 或 viewer",
+	"validation.admin_token_name_required": "令牌名称不能为空",
+	"validation.admin_token_expiry_invalid": "令牌有效期不能为负数",
 
 	// Task related
 	"task.validation_started": "密钥验证任务已开始",
@@ -88,11 +147,14 @@ var MessagesZhCN = map[string]string{
 	"task.delete_started":     "密钥删除任务已开始",
 	"task.already_running":    "已有任务正在运行",
 	"task.get_status_failed":  "获取任务状态失败",
+	"task.not_found":          "任务不存在或其结果已过期",
+	"task.not_cancelable":     "任务已结束或无法取消",
 
 	// Dashboard related
 	"dashboard.invalid_keys":                                     "无效密钥数量",
 	"dashboard.success_requests":                                 "成功请求",
 	"dashboard.failed_requests":                                  "失败请求",
+	"dashboard.cost":                                             "费用",
 	"dashboard.auth_key_missing":                                 "AUTH_KEY未设置，系统无法正常工作",
 	"dashboard.auth_key_required":                                "必须设置AUTH_KEY以保护管理界面",
 	"dashboard.encryption_key_missing":                           "未设置ENCRYPTION_KEY，敏感数据将明文存储",
@@ -116,10 +178,18 @@ var MessagesZhCN = map[string]string{
 
 	// Success messages
 	"success.group_deleted":        "分组及相关密钥删除成功",
+	"success.model_price_deleted":  "模型价格删除成功",
+	"success.proxy_key_deleted":    "代理密钥删除成功",
+	"success.auth_ban_removed":     "已解除封禁",
+	"success.encryption_rewrap_started": "已在后台开始重新包装数据密钥",
+	"success.encryption_key_rotation_started": "已开始轮换加密密钥，数据将在后台重新加密",
+	"success.admin_user_deleted":   "管理员账号删除成功",
+	"success.admin_token_deleted":  "API 令牌已撤销",
 	"success.keys_restored":        "{{.count}}个密钥已恢复",
 	"success.invalid_keys_cleared": "{{.count}}个无效密钥已清除",
 	"success.all_keys_cleared":     "{{.count}}个密钥已清除",
 	"success.groups_reordered":     "分组排序已保存",
+	"success.key_source_deleted":   "密钥来源已删除，其导入的密钥已保留",
 
 	// Password security related
 	"security.password_too_short":         "{{.keyType}}长度不足（{{.length}}字符），建议至少16字符",
@@ -136,7 +206,7 @@ var MessagesZhCN = map[string]string{
 	"config.app_url":                          "项目地址",
 	"config.app_url_desc":                     "项目的基础 URL，用于拼接分组终端节点地址。系统配置优先于环境变量 APP_URL。",
 	"config.proxy_keys":                       "全局代理密钥",
-	"config.proxy_keys_desc":                  "全局代理密钥，用于访问所有分组的代理端点。多个密钥请用逗号分隔。",
+	"config.proxy_keys_desc":                  "全局代理密钥，用于访问所有分组的代理端点。在此输入的密钥将以哈希形式添加，保存后无法再次查看。多个密钥请用逗号分隔。",
 	"config.log_retention_days":               "日志保留时长（天）",
 	"config.log_retention_days_desc":          "请求日志在数据库中的保留天数，0为不清理日志。",
 	"config.log_write_interval":               "日志延迟写入周期（分钟）",
@@ -171,11 +241,58 @@ var MessagesZhCN = map[string]string{
 	"config.key_validation_concurrency_desc": "后台定时验证无效 Key 时的并发数，如果使用SQLite或者运行环境性能不佳，请尽量保证20以下，避免过高的并发导致数据不一致问题。",
 	"config.key_validation_timeout":          "密钥验证超时（秒）",
 	"config.key_validation_timeout_desc":     "后台定时验证单个 Key 时的 API 请求超时时间（秒）。",
+	"config.balance_probe":                   "余额探测",
+	"config.balance_probe_desc":              "后台定时验证时获取有效 Key 的余额：off、auto（根据上游域名识别）、openai_billing、deepseek 或 openrouter。余额耗尽的 Key 将被自动禁用。",
+	"config.key_validation_cron":             "验证 Cron 计划",
+	"config.key_validation_cron_desc":        "后台验证密钥的 Cron 表达式（分 时 日 月 周，使用服务器时间），例如 '0 */2 * * *'。设置后将替代验证间隔。",
+	"config.key_validation_jitter":           "验证抖动（秒）",
+	"config.key_validation_jitter_desc":      "分组的后台验证会在不超过该秒数的随机延迟后开始，避免所有分组同时验证。0 表示不延迟。",
+	"config.active_key_validation_interval":  "有效密钥验证间隔（分钟）",
+	"config.active_key_validation_interval_desc": "主动验证有效密钥的周期，以便在用户请求失败前发现失效的密钥。0 表示关闭。",
+	"config.active_key_validation_sample_percent": "有效密钥验证抽样比例（%）",
+	"config.active_key_validation_sample_percent_desc": "每次验证的有效密钥比例，优先验证最久未验证的密钥。100 表示全部验证。",
+	"config.enforce_global_key_uniqueness":             "全局密钥唯一",
+	"config.enforce_global_key_uniqueness_desc":        "添加或导入密钥时拒绝已存在于其他分组中的密钥，并禁止在分组之间复制密钥。",
+
+	// Aggregate group settings related
+	"config.enable_adaptive_weights":      "启用自适应权重",
+	"config.enable_adaptive_weights_desc": "根据观测到的延迟和错误率动态调整聚合分组中子分组的权重，降低劣化服务商的流量。",
+	"config.adaptive_min_weight":          "自适应最小权重",
+	"config.adaptive_min_weight_desc":     "自适应模式下子分组有效权重的下限。",
+	"config.adaptive_max_weight":          "自适应最大权重",
+	"config.adaptive_max_weight_desc":     "自适应模式下子分组有效权重的上限。",
+	"config.proxy_ip_allowlist":           "代理 IP 白名单",
+	"config.proxy_ip_allowlist_desc":      "允许使用代理端点的 IP 或 CIDR，多个用逗号分隔。留空表示不限制。分组可设置自己的名单，与全局名单同时生效。",
+	"config.proxy_ip_denylist":            "代理 IP 黑名单",
+	"config.proxy_ip_denylist_desc":       "禁止使用代理端点的 IP 或 CIDR，多个用逗号分隔。黑名单优先于白名单。",
+	"config.admin_ip_allowlist":           "管理端 IP 白名单",
+	"config.admin_ip_allowlist_desc":      "允许访问管理后台及管理 API 的 IP 或 CIDR，多个用逗号分隔。留空表示不限制。当前访问地址必须仍在允许范围内。",
+	"config.admin_ip_denylist":            "管理端 IP 黑名单",
+	"config.admin_ip_denylist_desc":       "禁止访问管理后台及管理 API 的 IP 或 CIDR，多个用逗号分隔。",
+	"config.auth_max_failures":            "最大鉴权失败次数",
+	"config.auth_max_failures_desc":       "同一 IP 或同一密钥前缀在统计窗口内登录或代理鉴权失败达到此次数后将被临时封禁，在此之前失败响应会逐步延迟。0 表示关闭此保护。",
+	"config.auth_failure_window":          "失败统计窗口（分钟）",
+	"config.auth_failure_window_desc":     "统计鉴权失败次数的时间窗口。",
+	"config.auth_ban_minutes":             "封禁时长（分钟）",
+	"config.auth_ban_minutes_desc":        "首次临时封禁的时长。24 小时内再次被封禁时时长翻倍，最长 24 小时。",
+	"config.rate_limit_rpm":               "每分钟请求数",
+	"config.rate_limit_rpm_desc":          "分组在所有节点上每分钟允许的最大请求数，按滑动窗口统计。0 表示不限制。",
+	"config.rate_limit_tpm":               "每分钟 Token 数",
+	"config.rate_limit_tpm_desc":          "分组在所有节点上每分钟允许的最大输入与输出 Token 数，达到上限后拒绝请求。0 表示不限制。",
+	"config.rate_limit_concurrency":       "并发请求数",
+	"config.rate_limit_concurrency_desc":  "分组在所有节点上同时处理的最大请求及流式连接数。0 表示不限制。",
+	"config.request_queue_size":           "请求队列长度",
+	"config.request_queue_size_desc":      "在所有节点上等待并发名额或可用密钥的最大请求数，避免立即失败。不同代理密钥轮流出队。0 表示不排队。",
+	"config.request_queue_timeout":        "最长排队时间（秒）",
+	"config.request_queue_timeout_desc":   "请求在队列中等待的最长时间，超时后拒绝请求。",
 
 	// Category labels
-	"config.category.basic":   "基础参数",
-	"config.category.request": "请求设置",
-	"config.category.key":     "密钥配置",
+	"config.category.basic":      "基础参数",
+	"config.category.request":    "请求设置",
+	"config.category.key":        "密钥配置",
+	"config.category.aggregate":  "聚合分组",
+	"config.category.access":     "访问控制",
+	"config.category.rate_limit": "限流",
 
 	// Internal error messages (for fmt.Errorf usage)
 	"error.upstreams_required":       "upstreams字段是必需的",
@@ -186,26 +303,59 @@ var MessagesZhCN = map[string]string{
 	"error.marshal_upstreams_failed": "序列化清理后的upstreams失败",
 	"error.invalid_config_format":    "无效的配置格式: {{.error}}",
 	"error.process_header_rules":     "处理请求头规则失败: {{.error}}",
+	"error.process_budgets":          "处理预算配置失败: {{.error}}",
 	"error.invalidate_group_cache":   "刷新分组缓存失败",
 	"error.unmarshal_header_rules":   "解析请求头规则失败",
 	"error.delete_group_cache":       "删除分组失败: 无法清理缓存",
 	"error.decrypt_key_copy":         "解密密钥时失败，跳过该密钥",
 	"error.start_import_task":        "启动异步密钥导入任务失败",
 	"error.export_logs":              "导出日志失败",
+	"error.export_audit_logs":        "导出审计日志失败",
 
 	// Login related
 	"auth.invalid_request":           "无效的请求格式",
 	"auth.authentication_successful": "认证成功",
 	"auth.authentication_failed":     "认证失败",
+	"auth.ip_not_allowed":            "不允许从此 IP 地址访问",
+	"auth.too_many_attempts":         "失败次数过多，请在 {{.minutes}} 分钟后重试",
 
 	// Settings success message
-	"settings.update_success": "设置更新成功。配置将在后台在所有实例间重新加载。",
+	"settings.update_success":   "设置更新成功。配置将在后台在所有实例间重新加载。",
+	"settings.admin_ip_lockout": "该管理端 IP 规则会拦截您当前的地址 {{.ip}}，请先将其加入允许范围再保存。",
 
 	// Sub-groups related
-	"success.sub_groups_added":         "子分组添加成功",
-	"success.sub_group_weight_updated": "子分组权重更新成功",
-	"success.sub_group_deleted":        "子分组删除成功",
-	"group.not_aggregate":              "该分组不是聚合分组",
-	"group.sub_group_already_exists":   "子分组{{.sub_group_id}}已存在",
-	"group.sub_group_not_found":        "子分组不存在",
+	"success.sub_groups_added":           "子分组添加成功",
+	"success.sub_group_weight_updated":   "子分组权重更新成功",
+	"success.sub_group_models_updated":   "子分组模型更新成功",
+	"success.sub_group_schedule_updated": "子分组时间窗口更新成功",
+	"success.sub_group_priority_updated": "子分组优先级更新成功",
+	"success.sub_group_deleted":          "子分组删除成功",
+	"group.not_aggregate":                "该分组不是聚合分组",
+	"group.sub_group_already_exists":     "子分组{{.sub_group_id}}已存在",
+	"group.sub_group_not_found":          "子分组不存在",
+
+	// Model price related
+	"model_price.not_found": "模型价格不存在",
+	"proxy_key.not_found":   "代理密钥不存在",
+	"key_source.not_found":  "密钥来源不存在",
+	"key_source.directory_owner_only": "只有所有者可以配置目录来源",
+	"proxy_key.duplicate":   "该代理密钥已存在",
+	"proxy_key.already_rotated": "该代理密钥已轮换过",
+	"auth_guard.ban_not_found":  "封禁不存在或已过期",
+	"auth_guard.subject_required": "缺少封禁对象",
+	"encryption.envelope_disabled": "未启用信封加密，请设置 ENCRYPTION_PROVIDER",
+	"encryption.rewrap_running": "数据密钥重新包装正在进行中",
+	"encryption.provider_unavailable": "主密钥提供方不可用：{{.error}}",
+	"encryption.new_key_required":     "新的加密密钥不能为空",
+	"encryption.key_rotation_requires_key": "在线轮换需要先配置 ENCRYPTION_KEY，启用加密请使用 migrate-keys --to",
+	"encryption.key_unchanged":        "新的加密密钥不能与当前密钥相同",
+	"encryption.key_rotation_running": "加密密钥轮换正在进行中",
+	"encryption.key_rotation_blocked": "无法轮换加密密钥：{{.reason}}",
+
+	// Admin account related
+	"admin.user_not_found":           "管理员账号不存在",
+	"admin.token_not_found":          "API 令牌不存在",
+	"admin.token_scope_exceeds_role": "令牌权限不能超过自身角色",
+	"admin.token_requires_account":   "只有管理员账号可以管理 API 令牌",
+	"admin.last_owner":               "必须保留至少一个启用的 owner 账号",
 }
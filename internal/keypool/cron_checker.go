@@ -2,9 +2,14 @@ package keypool
 
 import (
 	"context"
+	"errors"
+	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
 	"gpt-load/internal/encryption"
 	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+	"math"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,7 +18,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// NewCronChecker is responsible for periodically validating invalid keys.
+// NewCronChecker is responsible for periodically validating invalid keys and probing key balances.
 type CronChecker struct {
 	DB              *gorm.DB
 	SettingsManager *config.SystemSettingsManager
@@ -21,6 +26,7 @@ type CronChecker struct {
 	EncryptionSvc   encryption.Service
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
+	running         sync.Map // IDs of groups being validated
 }
 
 // NewCronChecker creates a new CronChecker.
@@ -70,7 +76,8 @@ func (s *CronChecker) runLoop() {
 
 	s.submitValidationJobs()
 
-	ticker := time.NewTicker(5 * time.Minute)
+	// Ticks every minute so cron schedules are honored; groups only run when they are due.
+	ticker := time.NewTicker(checkTickInterval)
 	defer ticker.Stop()
 
 	for {
@@ -84,7 +91,11 @@ func (s *CronChecker) runLoop() {
 	}
 }
 
-// submitValidationJobs finds groups whose keys need validation and validates them concurrently.
+// checkTickInterval is how often the checker looks for groups that are due.
+const checkTickInterval = time.Minute
+
+// submitValidationJobs finds groups whose keys need validation and starts validating them
+// in the background. A group that is still being validated is skipped.
 func (s *CronChecker) submitValidationJobs() {
 	var groups []models.Group
 	if err := s.DB.Where("group_type != ? OR group_type IS NULL", "aggregate").Find(&groups).Error; err != nil {
@@ -93,27 +104,92 @@ func (s *CronChecker) submitValidationJobs() {
 	}
 
 	validationStartTime := time.Now()
-	var wg sync.WaitGroup
 
 	for i := range groups {
 		group := &groups[i]
 		group.EffectiveConfig = s.SettingsManager.GetEffectiveConfig(group.Config)
-		interval := time.Duration(group.EffectiveConfig.KeyValidationIntervalMinutes) * time.Minute
 
-		if group.LastValidatedAt == nil || validationStartTime.Sub(*group.LastValidatedAt) > interval {
-			wg.Add(1)
-			g := group
-			go func() {
-				defer wg.Done()
+		invalidDue := s.isValidationDue(group, validationStartTime)
+		activeDue := isActiveValidationDue(group, validationStartTime)
+		if !invalidDue && !activeDue {
+			continue
+		}
+		if _, running := s.running.LoadOrStore(group.ID, struct{}{}); running {
+			continue
+		}
+
+		s.wg.Add(1)
+		g := group
+		go func() {
+			defer s.wg.Done()
+			defer s.running.Delete(g.ID)
+
+			if !s.waitJitter(g) {
+				return
+			}
+			if invalidDue {
 				s.validateGroupKeys(g)
-			}()
+			}
+			if activeDue {
+				s.validateActiveKeys(g, validationStartTime)
+			}
+		}()
+	}
+}
+
+// isValidationDue reports whether the regular validation of a group is due, using its
+// cron schedule when one is set and its validation interval otherwise.
+func (s *CronChecker) isValidationDue(group *models.Group, now time.Time) bool {
+	if group.LastValidatedAt == nil {
+		return true
+	}
+
+	if expr := group.EffectiveConfig.KeyValidationCron; expr != "" {
+		schedule, err := utils.ParseCron(expr)
+		if err != nil {
+			logrus.Warnf("CronChecker: Group '%s' has an invalid validation cron expression '%s': %v", group.Name, expr, err)
+			return false
 		}
+		next := schedule.Next(group.LastValidatedAt.In(now.Location()))
+		return !next.IsZero() && !next.After(now)
+	}
+
+	interval := time.Duration(group.EffectiveConfig.KeyValidationIntervalMinutes) * time.Minute
+	return now.Sub(*group.LastValidatedAt) > interval
+}
+
+// isActiveValidationDue reports whether the proactive validation of active keys is due.
+// Runs are recorded at the tick that started them, so half a tick of slack keeps an
+// interval from slipping to the following tick.
+func isActiveValidationDue(group *models.Group, now time.Time) bool {
+	minutes := group.EffectiveConfig.ActiveKeyValidationIntervalMinutes
+	if minutes <= 0 {
+		return false
+	}
+	interval := time.Duration(minutes) * time.Minute
+	return group.LastActiveValidatedAt == nil || now.Sub(*group.LastActiveValidatedAt) > interval-checkTickInterval/2
+}
+
+// waitJitter sleeps for a random part of the group's validation jitter, so groups that
+// become due together do not all validate at once. It returns false if the checker stops.
+func (s *CronChecker) waitJitter(group *models.Group) bool {
+	jitter := group.EffectiveConfig.KeyValidationJitterSeconds
+	if jitter <= 0 {
+		return true
 	}
 
-	wg.Wait()
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(jitter) * int64(time.Second))))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.stopChan:
+		return false
+	}
 }
 
-// validateGroupKeys validates all invalid keys for a single group concurrently.
+// validateGroupKeys validates all invalid keys for a single group concurrently,
+// then probes the balance of its active keys when a balance probe is configured.
 func (s *CronChecker) validateGroupKeys(group *models.Group) {
 	groupProcessStart := time.Now()
 
@@ -125,16 +201,132 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 	}
 
 	if len(invalidKeys) == 0 {
-		if err := s.DB.Model(group).Update("last_validated_at", time.Now()).Error; err != nil {
-			logrus.Errorf("CronChecker: Failed to update last_validated_at for group %s: %v", group.Name, err)
-		}
 		logrus.Infof("CronChecker: Group '%s' has no invalid keys to check.", group.Name)
+	} else {
+		var becameValidCount int32
+		s.forEachKey(group, invalidKeys, func(key *models.APIKey) {
+			isValid, _ := s.Validator.ValidateSingleKey(key, group)
+			if isValid {
+				atomic.AddInt32(&becameValidCount, 1)
+			}
+		})
+
+		logrus.Infof(
+			"CronChecker: Group '%s' validation finished. Total checked: %d, became valid: %d. Duration: %s.",
+			group.Name,
+			len(invalidKeys),
+			becameValidCount,
+			time.Since(groupProcessStart).String(),
+		)
+	}
+
+	if group.EffectiveConfig.BalanceProbe != "" && group.EffectiveConfig.BalanceProbe != channel.BalanceProbeOff {
+		s.probeGroupBalances(group)
+	}
+
+	if err := s.DB.Model(group).Update("last_validated_at", time.Now()).Error; err != nil {
+		logrus.Errorf("CronChecker: Failed to update last_validated_at for group %s: %v", group.Name, err)
+	}
+}
+
+// validateActiveKeys proactively validates the active keys of a group, or the configured
+// share of them, starting with the keys that were validated least recently.
+func (s *CronChecker) validateActiveKeys(group *models.Group, startedAt time.Time) {
+	start := time.Now()
+
+	query := s.DB.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", group.ID, models.KeyStatusActive)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("CronChecker: Failed to count active keys for group %s: %v", group.Name, err)
+		return
+	}
+
+	var activeKeys []models.APIKey
+	if total > 0 {
+		limit := int(math.Ceil(float64(total) * float64(group.EffectiveConfig.ActiveKeyValidationSamplePercent) / 100))
+		err := s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusActive).
+			Order("CASE WHEN last_validated_at IS NULL THEN 0 ELSE 1 END, last_validated_at ASC, id ASC").
+			Limit(limit).
+			Find(&activeKeys).Error
+		if err != nil {
+			logrus.Errorf("CronChecker: Failed to get active keys for group %s: %v", group.Name, err)
+			return
+		}
+	}
+
+	var failedCount int32
+	s.forEachKey(group, activeKeys, func(key *models.APIKey) {
+		if isValid, _ := s.Validator.ValidateSingleKey(key, group); !isValid {
+			atomic.AddInt32(&failedCount, 1)
+		}
+	})
+
+	if err := s.DB.Model(group).Update("last_active_validated_at", startedAt).Error; err != nil {
+		logrus.Errorf("CronChecker: Failed to update last_active_validated_at for group %s: %v", group.Name, err)
+	}
+
+	logrus.Infof(
+		"CronChecker: Group '%s' active key validation finished. Checked: %d of %d, failed: %d. Duration: %s.",
+		group.Name,
+		len(activeKeys),
+		total,
+		failedCount,
+		time.Since(start).String(),
+	)
+}
+
+// probeGroupBalances fetches the balance of all active keys of a group concurrently.
+func (s *CronChecker) probeGroupBalances(group *models.Group) {
+	probeStart := time.Now()
+
+	var activeKeys []models.APIKey
+	if err := s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusActive).Find(&activeKeys).Error; err != nil {
+		logrus.Errorf("CronChecker: Failed to get active keys for group %s: %v", group.Name, err)
+		return
+	}
+	if len(activeKeys) == 0 {
+		return
+	}
+
+	var probedCount, disabledCount int32
+	var unsupported atomic.Bool
+	s.forEachKey(group, activeKeys, func(key *models.APIKey) {
+		if unsupported.Load() {
+			return
+		}
+		disabled, err := s.Validator.ProbeKeyBalance(key, group)
+		if err != nil {
+			if errors.Is(err, channel.ErrBalanceProbeUnsupported) {
+				unsupported.Store(true)
+			}
+			return
+		}
+		atomic.AddInt32(&probedCount, 1)
+		if disabled {
+			atomic.AddInt32(&disabledCount, 1)
+		}
+	})
+
+	if unsupported.Load() {
+		logrus.Warnf("CronChecker: Group '%s' has a balance probe configured, but its upstream does not support one.", group.Name)
 		return
 	}
 
-	var becameValidCount int32
+	logrus.Infof(
+		"CronChecker: Group '%s' balance probe finished. Total probed: %d/%d, disabled: %d. Duration: %s.",
+		group.Name,
+		probedCount,
+		len(activeKeys),
+		disabledCount,
+		time.Since(probeStart).String(),
+	)
+}
+
+// forEachKey decrypts the given keys and runs fn on each of them using the
+// group's validation concurrency. It stops early when the checker is stopped.
+func (s *CronChecker) forEachKey(group *models.Group, keys []models.APIKey, fn func(key *models.APIKey)) {
 	var keyWg sync.WaitGroup
-	jobs := make(chan *models.APIKey, len(invalidKeys))
+	jobs := make(chan *models.APIKey, len(keys))
 
 	concurrency := group.EffectiveConfig.KeyValidationConcurrency
 	for range concurrency {
@@ -159,10 +351,7 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 					keyForValidation := *key
 					keyForValidation.KeyValue = decryptedKey
 
-					isValid, _ := s.Validator.ValidateSingleKey(&keyForValidation, group)
-					if isValid {
-						atomic.AddInt32(&becameValidCount, 1)
-					}
+					fn(&keyForValidation)
 				case <-s.stopChan:
 					return
 				}
@@ -171,9 +360,9 @@ func (s *CronChecker) validateGroupKeys(group *models.Group) {
 	}
 
 DistributeLoop:
-	for i := range invalidKeys {
+	for i := range keys {
 		select {
-		case jobs <- &invalidKeys[i]:
+		case jobs <- &keys[i]:
 		case <-s.stopChan:
 			break DistributeLoop
 		}
@@ -181,17 +370,4 @@ DistributeLoop:
 	close(jobs)
 
 	keyWg.Wait()
-
-	if err := s.DB.Model(group).Update("last_validated_at", time.Now()).Error; err != nil {
-		logrus.Errorf("CronChecker: Failed to update last_validated_at for group %s: %v", group.Name, err)
-	}
-
-	duration := time.Since(groupProcessStart)
-	logrus.Infof(
-		"CronChecker: Group '%s' validation finished. Total checked: %d, became valid: %d. Duration: %s.",
-		group.Name,
-		len(invalidKeys),
-		becameValidCount,
-		duration.String(),
-	)
 }
@@ -0,0 +1,83 @@
+package keypool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleTickInterval is how often key schedules are checked. Windows have minute resolution.
+const scheduleTickInterval = time.Minute
+
+// KeyScheduler moves keys with an active schedule in and out of rotation as their windows open and close.
+type KeyScheduler struct {
+	provider *KeyProvider
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewKeyScheduler creates a new KeyScheduler.
+func NewKeyScheduler(provider *KeyProvider) *KeyScheduler {
+	return &KeyScheduler{
+		provider: provider,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins checking key schedules at the start of every minute.
+func (s *KeyScheduler) Start() {
+	logrus.Debug("Starting KeyScheduler...")
+	s.wg.Add(1)
+	go s.runLoop()
+}
+
+// Stop stops the scheduler, respecting the context for shutdown timeout.
+func (s *KeyScheduler) Stop(ctx context.Context) {
+	close(s.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("KeyScheduler stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("KeyScheduler stop timed out.")
+	}
+}
+
+func (s *KeyScheduler) runLoop() {
+	defer s.wg.Done()
+
+	s.refresh()
+
+	// Align with the minute boundary, so windows open and close on time.
+	timer := time.NewTimer(time.Until(time.Now().Truncate(scheduleTickInterval).Add(scheduleTickInterval)))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			s.refresh()
+			timer.Reset(time.Until(time.Now().Truncate(scheduleTickInterval).Add(scheduleTickInterval)))
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *KeyScheduler) refresh() {
+	opened, closed, err := s.provider.RefreshScheduledKeys(time.Now())
+	if err != nil {
+		logrus.WithError(err).Error("KeyScheduler: failed to refresh scheduled keys")
+		return
+	}
+	if opened > 0 || closed > 0 {
+		logrus.WithFields(logrus.Fields{"opened": opened, "closed": closed}).Info("KeyScheduler: updated scheduled keys")
+	}
+}
@@ -3,12 +3,15 @@ package keypool
 import (
 	"errors"
 	"fmt"
+	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
 	"gpt-load/internal/encryption"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -34,67 +37,223 @@ func NewProvider(db *gorm.DB, store store.Store, settingsManager *config.SystemS
 	}
 }
 
+// keyRPMWindowTTL keeps a per-key request counter a little longer than its minute window.
+const keyRPMWindowTTL = 2 * time.Minute
+
+// ActiveKeysListKey returns the store list holding the active keys of a group at a priority.
+// Priority 0 keeps the original list name, so groups without priorities are unaffected.
+func ActiveKeysListKey(groupID uint, priority int) string {
+	if priority == 0 {
+		return fmt.Sprintf("group:%d:active_keys", groupID)
+	}
+	return fmt.Sprintf("group:%d:active_keys:%d", groupID, priority)
+}
+
+// keyPrioritiesKey returns the store hash recording which priorities a group has keys at.
+func keyPrioritiesKey(groupID uint) string {
+	return fmt.Sprintf("group:%d:key_priorities", groupID)
+}
+
+// ActiveKeyLists returns the active key lists of a group, lowest priority value first.
+func ActiveKeyLists(s store.Store, groupID uint) ([]string, error) {
+	fields, err := s.HGetAll(keyPrioritiesKey(groupID))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	priorities := []int{0}
+	for field := range fields {
+		if priority, err := strconv.Atoi(field); err == nil && priority != 0 {
+			priorities = append(priorities, priority)
+		}
+	}
+	sort.Ints(priorities)
+
+	lists := make([]string, len(priorities))
+	for i, priority := range priorities {
+		lists[i] = ActiveKeysListKey(groupID, priority)
+	}
+	return lists, nil
+}
+
 // SelectKey 为指定的分组原子性地选择并轮换一个可用的 APIKey。
+// Keys with a lower priority value are used first; a lower tier is only used when no key above it can serve.
 func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-
-	// 1. Atomically rotate the key ID from the list
-	keyIDStr, err := p.store.Rotate(activeKeysListKey)
+	lists, err := ActiveKeyLists(p.store, groupID)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return nil, app_errors.ErrNoActiveKeys
+		return nil, fmt.Errorf("failed to get key priorities from store: %w", err)
+	}
+
+	rpmLimited := false
+	for _, listKey := range lists {
+		apiKey, limited, err := p.selectFromList(groupID, listKey)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to rotate key from store: %w", err)
+		if apiKey != nil {
+			return apiKey, nil
+		}
+		rpmLimited = rpmLimited || limited
 	}
 
-	keyID, err := strconv.ParseUint(keyIDStr, 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
+	if rpmLimited {
+		return nil, fmt.Errorf("%w: all active keys have reached their rpm limit", app_errors.ErrNoActiveKeys)
 	}
+	return nil, app_errors.ErrNoActiveKeys
+}
 
-	// 2. Get key details from HASH
-	keyHashKey := fmt.Sprintf("key:%d", keyID)
-	keyDetails, err := p.store.HGetAll(keyHashKey)
+// selectFromList rotates through one active key list until it finds a usable key.
+// It returns nil when the list is empty or every key in it is expired or over its RPM limit.
+func (p *KeyProvider) selectFromList(groupID uint, listKey string) (*models.APIKey, bool, error) {
+	length, err := p.store.LLen(listKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get key details for key ID %d: %w", keyID, err)
+		return nil, false, fmt.Errorf("failed to get length of active key list: %w", err)
 	}
 
-	// 3. Manually unmarshal the map into an APIKey struct
-	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
-	createdAt, _ := strconv.ParseInt(keyDetails["created_at"], 10, 64)
+	now := time.Now()
+	rpmLimited := false
+	for range length {
+		// 1. Atomically rotate the key ID from the list
+		keyIDStr, err := p.store.Rotate(listKey)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, rpmLimited, nil
+			}
+			return nil, false, fmt.Errorf("failed to rotate key from store: %w", err)
+		}
 
-	// Decrypt the key value for use by channels
-	encryptedKeyValue := keyDetails["key_string"]
-	decryptedKeyValue, err := p.encryptionSvc.Decrypt(encryptedKeyValue)
-	if err != nil {
-		// If decryption fails, try to use the value as-is (backward compatibility for unencrypted keys)
-		logrus.WithFields(logrus.Fields{
-			"keyID": keyID,
-			"error": err,
-		}).Debug("Failed to decrypt key value, using as-is for backward compatibility")
-		decryptedKeyValue = encryptedKeyValue
+		keyID, err := strconv.ParseUint(keyIDStr, 10, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
+		}
+
+		// 2. Get key details from HASH
+		keyHashKey := fmt.Sprintf("key:%d", keyID)
+		keyDetails, err := p.store.HGetAll(keyHashKey)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get key details for key ID %d: %w", keyID, err)
+		}
+
+		apiKey := p.apiKeyFromMap(uint(keyID), groupID, keyDetails)
+		if apiKey.IsExpired(now) {
+			go p.disableKey(apiKey.ID, groupID, "Key has expired, disabling.")
+			continue
+		}
+		// The scheduler takes keys out when their window closes; until it runs they are skipped here.
+		if !utils.IsRawScheduleActive(keyDetails["active_schedule"], now) {
+			continue
+		}
+		if apiKey.RPMLimit > 0 && !p.takeKeyRPM(apiKey.ID, apiKey.RPMLimit, now) {
+			rpmLimited = true
+			continue
+		}
+
+		// Decrypt the key value for use by channels
+		encryptedKeyValue := keyDetails["key_string"]
+		decryptedKeyValue, err := p.encryptionSvc.Decrypt(encryptedKeyValue)
+		if err != nil {
+			// If decryption fails, try to use the value as-is (backward compatibility for unencrypted keys)
+			logrus.WithFields(logrus.Fields{
+				"keyID": keyID,
+				"error": err,
+			}).Debug("Failed to decrypt key value, using as-is for backward compatibility")
+			decryptedKeyValue = encryptedKeyValue
+		}
+		apiKey.KeyValue = decryptedKeyValue
+
+		return apiKey, false, nil
 	}
 
+	return nil, rpmLimited, nil
+}
+
+// apiKeyFromMap manually unmarshals the store HASH of a key into an APIKey struct, without its value.
+func (p *KeyProvider) apiKeyFromMap(keyID, groupID uint, keyDetails map[string]string) *models.APIKey {
+	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
+	createdAt, _ := strconv.ParseInt(keyDetails["created_at"], 10, 64)
+	priority, _ := strconv.Atoi(keyDetails["priority"])
+	rpmLimit, _ := strconv.Atoi(keyDetails["rpm_limit"])
+
 	apiKey := &models.APIKey{
-		ID:           uint(keyID),
-		KeyValue:     decryptedKeyValue,
+		ID:           keyID,
 		Status:       keyDetails["status"],
 		FailureCount: failureCount,
 		GroupID:      groupID,
+		Priority:     priority,
+		RPMLimit:     rpmLimit,
 		CreatedAt:    time.Unix(createdAt, 0),
 	}
+	if expiresAt, _ := strconv.ParseInt(keyDetails["expires_at"], 10, 64); expiresAt > 0 {
+		t := time.Unix(expiresAt, 0)
+		apiKey.ExpiresAt = &t
+	}
+	return apiKey
+}
 
-	return apiKey, nil
+// takeKeyRPM counts a request against the RPM limit of a key for the current minute.
+// It reports false, without counting, once the limit is reached. Store errors let the request through.
+func (p *KeyProvider) takeKeyRPM(keyID uint, limit int, now time.Time) bool {
+	counterKey := fmt.Sprintf("key:%d:rpm:%d", keyID, now.Unix()/60)
+	count, err := p.store.IncrBy(counterKey, 1, keyRPMWindowTTL)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to count key rpm, allowing request")
+		return true
+	}
+	if count <= int64(limit) {
+		return true
+	}
+	if _, err := p.store.IncrBy(counterKey, -1, keyRPMWindowTTL); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Warn("Failed to roll back key rpm counter")
+	}
+	return false
+}
+
+// disableKey marks an active key as invalid and takes it out of rotation, logging the reason.
+func (p *KeyProvider) disableKey(keyID, groupID uint, reason string) {
+	err := p.executeTransactionWithRetry(func(tx *gorm.DB) error {
+		result := tx.Model(&models.APIKey{}).
+			Where("id = ? AND status = ?", keyID, models.KeyStatusActive).
+			Update("status", models.KeyStatusInvalid)
+		if result.Error != nil {
+			return fmt.Errorf("failed to disable key in DB: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		keyHashKey := fmt.Sprintf("key:%d", keyID)
+		keyDetails, err := p.store.HGetAll(keyHashKey)
+		if err != nil {
+			return fmt.Errorf("failed to get key details from store: %w", err)
+		}
+		if err := p.store.LRem(p.activeKeysListKeyOf(groupID, keyDetails), 0, keyID); err != nil {
+			return fmt.Errorf("failed to LRem disabled key from active list: %w", err)
+		}
+		if err := p.store.HSet(keyHashKey, map[string]any{"status": models.KeyStatusInvalid}); err != nil {
+			return fmt.Errorf("failed to update disabled key status in store: %w", err)
+		}
+
+		logrus.WithField("keyID", keyID).Info(reason)
+		return nil
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "error": err}).Error("Failed to disable key")
+	}
+}
+
+// activeKeysListKeyOf returns the active key list of a key from its store HASH.
+func (p *KeyProvider) activeKeysListKeyOf(groupID uint, keyDetails map[string]string) string {
+	priority, _ := strconv.Atoi(keyDetails["priority"])
+	return ActiveKeysListKey(groupID, priority)
 }
 
 // UpdateStatus 异步地提交一个 Key 状态更新任务。
 func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, isSuccess bool, errorMessage string) {
 	go func() {
 		keyHashKey := fmt.Sprintf("key:%d", apiKey.ID)
-		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
 
 		if isSuccess {
-			if err := p.handleSuccess(apiKey.ID, keyHashKey, activeKeysListKey); err != nil {
+			if err := p.handleSuccess(apiKey.ID, group.ID, keyHashKey); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key success")
 			}
 		} else {
@@ -104,7 +263,7 @@ func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, i
 					"error": errorMessage,
 				}).Debug("Uncounted error, skipping failure handling")
 			} else {
-				if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey); err != nil {
+				if err := p.handleFailure(apiKey, group, keyHashKey); err != nil {
 					logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key failure")
 				}
 			}
@@ -139,16 +298,19 @@ func (p *KeyProvider) executeTransactionWithRetry(operation func(tx *gorm.DB) er
 	return err
 }
 
-func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey string) error {
+func (p *KeyProvider) handleSuccess(keyID, groupID uint, keyHashKey string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
 		return fmt.Errorf("failed to get key details from store: %w", err)
 	}
 
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
-	isActive := keyDetails["status"] == models.KeyStatusActive
+	// An expired key stays disabled even when it still works upstream, and a key
+	// disabled by hand or by its source is never restored by traffic or validation.
+	shouldRestore := keyDetails["status"] == models.KeyStatusInvalid && !p.apiKeyFromMap(keyID, groupID, keyDetails).IsExpired(time.Now())
+	activeKeysListKey := p.activeKeysListKeyOf(groupID, keyDetails)
 
-	if failureCount == 0 && isActive {
+	if failureCount == 0 && !shouldRestore {
 		return nil
 	}
 
@@ -159,7 +321,7 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 		}
 
 		updates := map[string]any{"failure_count": 0}
-		if !isActive {
+		if shouldRestore {
 			updates["status"] = models.KeyStatusActive
 		}
 
@@ -171,7 +333,7 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 			return fmt.Errorf("failed to update key details in store: %w", err)
 		}
 
-		if !isActive {
+		if shouldRestore && keyDetails["schedule_closed"] != "1" {
 			logrus.WithField("keyID", keyID).Debug("Key has recovered and is being restored to active pool.")
 			if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
 				return fmt.Errorf("failed to LRem key before LPush on recovery: %w", err)
@@ -185,11 +347,12 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 	})
 }
 
-func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey, activeKeysListKey string) error {
+func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
 		return fmt.Errorf("failed to get key details from store: %w", err)
 	}
+	activeKeysListKey := p.activeKeysListKeyOf(group.ID, keyDetails)
 
 	if keyDetails["status"] == models.KeyStatusInvalid {
 		return nil
@@ -241,7 +404,7 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 	logrus.Debug("First time startup, loading keys from DB...")
 
 	// 1. 分批从数据库加载并使用 Pipeline 写入 Redis
-	allActiveKeyIDs := make(map[uint][]any)
+	allActiveKeyIDs := make(map[uint]map[int][]any)
 	batchSize := 10000
 	var batchKeys []*models.APIKey
 
@@ -265,8 +428,11 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 				}
 			}
 
-			if key.Status == models.KeyStatusActive {
-				allActiveKeyIDs[key.GroupID] = append(allActiveKeyIDs[key.GroupID], key.ID)
+			if isServable(key) {
+				if allActiveKeyIDs[key.GroupID] == nil {
+					allActiveKeyIDs[key.GroupID] = make(map[int][]any)
+				}
+				allActiveKeyIDs[key.GroupID][key.Priority] = append(allActiveKeyIDs[key.GroupID][key.Priority], key.ID)
 			}
 		}
 
@@ -284,13 +450,17 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 
 	// 2. 更新所有分组的 active_keys 列表
 	logrus.Info("Updating active key lists for all groups...")
-	for groupID, activeIDs := range allActiveKeyIDs {
-		if len(activeIDs) > 0 {
-			activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	for groupID, tiers := range allActiveKeyIDs {
+		p.store.Delete(keyPrioritiesKey(groupID))
+		for priority, activeIDs := range tiers {
+			activeKeysListKey := ActiveKeysListKey(groupID, priority)
 			p.store.Delete(activeKeysListKey)
 			if err := p.store.LPush(activeKeysListKey, activeIDs...); err != nil {
 				logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Error("Failed to LPush active keys for group")
 			}
+			if err := p.trackPriority(groupID, priority); err != nil {
+				logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Error("Failed to record key priority for group")
+			}
 		}
 	}
 
@@ -327,10 +497,7 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		var keyHashes []string
 		for _, keyValue := range keyValues {
-			keyHash := p.encryptionSvc.Hash(keyValue)
-			if keyHash != "" {
-				keyHashes = append(keyHashes, keyHash)
-			}
+			keyHashes = append(keyHashes, encryption.HashCandidates(p.encryptionSvc, keyValue)...)
 		}
 
 		if len(keyHashes) == 0 {
@@ -416,10 +583,7 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		var keyHashes []string
 		for _, keyValue := range keyValues {
-			keyHash := p.encryptionSvc.Hash(keyValue)
-			if keyHash != "" {
-				keyHashes = append(keyHashes, keyHash)
-			}
+			keyHashes = append(keyHashes, encryption.HashCandidates(p.encryptionSvc, keyValue)...)
 		}
 
 		if len(keyHashes) == 0 {
@@ -461,6 +625,251 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 	return restoredCount, err
 }
 
+// SetKeysDisabled 停用或重新启用指定的 Key。
+// Disabling takes active and invalid keys out of rotation; enabling makes disabled keys active again.
+func (p *KeyProvider) SetKeysDisabled(groupID uint, keyIDs []uint, disabled bool) (int64, error) {
+	if len(keyIDs) == 0 {
+		return 0, nil
+	}
+
+	var keys []models.APIKey
+	var changedCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("group_id = ? AND id IN ?", groupID, keyIDs)
+		if disabled {
+			query = query.Where("status <> ?", models.KeyStatusDisabled)
+		} else {
+			query = query.Where("status = ?", models.KeyStatusDisabled)
+		}
+		if err := query.Find(&keys).Error; err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		updates := map[string]any{"status": models.KeyStatusDisabled}
+		if !disabled {
+			updates = map[string]any{"status": models.KeyStatusActive, "failure_count": 0}
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keys)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		changedCount = result.RowsAffected
+
+		for _, key := range keys {
+			if err := p.removeKeyFromStore(key.ID, key.GroupID); err != nil {
+				return err
+			}
+			if disabled {
+				key.Status = models.KeyStatusDisabled
+			} else {
+				key.Status = models.KeyStatusActive
+				key.FailureCount = 0
+			}
+			if err := p.addKeyToStore(&key); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to update key status in store")
+				return err
+			}
+		}
+		return nil
+	})
+
+	return changedCount, err
+}
+
+// RemoveKeysByID 按 ID 从池和数据库中移除 Key。
+func (p *KeyProvider) RemoveKeysByID(groupID uint, keyIDs []uint) (int64, error) {
+	if len(keyIDs) == 0 {
+		return 0, nil
+	}
+
+	var deletedCount int64
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("group_id = ? AND id IN ?", groupID, keyIDs).Delete(&models.APIKey{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deletedCount = result.RowsAffected
+
+		if err := p.UncacheKeys(groupID, keyIDs); err != nil {
+			logrus.WithError(err).Error("Failed to remove keys from store after DB deletion, rolling back transaction")
+			return err
+		}
+		return nil
+	})
+
+	return deletedCount, err
+}
+
+// UncacheKeys 从缓存中移除已在数据库中删除的 Key，用于调用方自行管理事务的场景。
+func (p *KeyProvider) UncacheKeys(groupID uint, keyIDs []uint) error {
+	for _, keyID := range keyIDs {
+		if err := p.removeKeyFromStore(keyID, groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveKeys 将指定的 Key 从一个分组移动到另一个分组，并同步缓存。
+// Keys keep their ID, status and statistics, but are no longer owned by a key source of the old group.
+func (p *KeyProvider) MoveKeys(fromGroupID, toGroupID uint, keyIDs []uint) (int64, error) {
+	if len(keyIDs) == 0 {
+		return 0, nil
+	}
+
+	var movedCount int64
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		var keys []models.APIKey
+		if err := tx.Where("group_id = ? AND id IN ?", fromGroupID, keyIDs).Find(&keys).Error; err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keys)).
+			Updates(map[string]any{"group_id": toGroupID, "source_id": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		movedCount = result.RowsAffected
+
+		for i := range keys {
+			if err := p.removeKeyFromStore(keys[i].ID, fromGroupID); err != nil {
+				return err
+			}
+			keys[i].GroupID = toGroupID
+			keys[i].SourceID = nil
+		}
+		return p.addKeysToCacheBatch(toGroupID, keys)
+	})
+
+	return movedCount, err
+}
+
+// UpdateKeyMetadata 保存 Key 的标签、优先级、过期时间、RPM 限制和时间窗口，并同步到缓存。
+// An active key moves to the active list of its new priority.
+func (p *KeyProvider) UpdateKeyMetadata(key *models.APIKey) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := p.SaveKeyMetadata(tx, key); err != nil {
+			return err
+		}
+		return p.CacheKey(key)
+	})
+}
+
+// SaveKeyMetadata 只在数据库中保存 Key 的元数据，事务提交后需调用 CacheKey 同步缓存。
+func (p *KeyProvider) SaveKeyMetadata(tx *gorm.DB, key *models.APIKey) error {
+	updates := map[string]any{
+		"tags":       key.Tags,
+		"priority":   key.Priority,
+		"expires_at": key.ExpiresAt,
+		"rpm_limit":  key.RPMLimit,
+		"schedule":   key.Schedule,
+	}
+	return tx.Model(&models.APIKey{}).Where("id = ?", key.ID).Updates(updates).Error
+}
+
+// CacheKey 用 Key 的当前状态替换缓存中的记录，活跃的 Key 会移到其优先级的列表中。
+func (p *KeyProvider) CacheKey(key *models.APIKey) error {
+	if err := p.removeKeyFromStore(key.ID, key.GroupID); err != nil {
+		return err
+	}
+	return p.addKeyToStore(key)
+}
+
+// CacheKeys 将调用方在事务中创建的 Key 添加到缓存。
+func (p *KeyProvider) CacheKeys(groupID uint, keys []models.APIKey) error {
+	return p.addKeysToCacheBatch(groupID, keys)
+}
+
+// RefreshScheduledKeys adds keys with a schedule to their active list when a window opens and
+// takes them out when it closes. Only keys whose window changed since the last run are touched.
+func (p *KeyProvider) RefreshScheduledKeys(now time.Time) (opened, closed int, err error) {
+	var keys []models.APIKey
+	err = p.db.Model(&models.APIKey{}).Select("id, group_id").Where("schedule IS NOT NULL").
+		FindInBatches(&keys, 1000, func(tx *gorm.DB, batch int) error {
+			for _, key := range keys {
+				keyHashKey := fmt.Sprintf("key:%d", key.ID)
+				keyDetails, err := p.store.HGetAll(keyHashKey)
+				if err != nil || len(keyDetails) == 0 {
+					continue
+				}
+
+				isOpen := utils.IsRawScheduleActive(keyDetails["active_schedule"], now)
+				if isOpen == (keyDetails["schedule_closed"] != "1") {
+					continue
+				}
+
+				flag := "1"
+				if isOpen {
+					flag = "0"
+				}
+				if err := p.store.HSet(keyHashKey, map[string]any{"schedule_closed": flag}); err != nil {
+					return fmt.Errorf("failed to update schedule state of key %d: %w", key.ID, err)
+				}
+				if keyDetails["status"] != models.KeyStatusActive {
+					continue
+				}
+
+				activeKeysListKey := p.activeKeysListKeyOf(key.GroupID, keyDetails)
+				if err := p.store.LRem(activeKeysListKey, 0, key.ID); err != nil {
+					return fmt.Errorf("failed to LRem scheduled key %d: %w", key.ID, err)
+				}
+				if isOpen {
+					priority, _ := strconv.Atoi(keyDetails["priority"])
+					if err := p.trackPriority(key.GroupID, priority); err != nil {
+						return fmt.Errorf("failed to record priority of key %d: %w", key.ID, err)
+					}
+					if err := p.store.LPush(activeKeysListKey, key.ID); err != nil {
+						return fmt.Errorf("failed to LPush scheduled key %d: %w", key.ID, err)
+					}
+					opened++
+				} else {
+					closed++
+				}
+			}
+			return nil
+		}).Error
+	return opened, closed, err
+}
+
+// UpdateKeyBalance records the balance probed for a key. An expiry reported by the
+// upstream is applied to the key, and keys the upstream reports with no balance left are disabled.
+func (p *KeyProvider) UpdateKeyBalance(keyID, groupID uint, balance *channel.KeyBalance) (disabled bool, err error) {
+	updates := map[string]any{
+		"balance":            balance.Balance,
+		"balance_currency":   balance.Currency,
+		"rate_tier":          balance.RateTier,
+		"balance_checked_at": time.Now(),
+	}
+	if balance.ExpiresAt != nil {
+		updates["expires_at"] = balance.ExpiresAt
+	}
+	if err := p.db.Model(&models.APIKey{}).Where("id = ?", keyID).Updates(updates).Error; err != nil {
+		return false, fmt.Errorf("failed to update key balance: %w", err)
+	}
+
+	if balance.ExpiresAt != nil {
+		keyHashKey := fmt.Sprintf("key:%d", keyID)
+		if exists, err := p.store.Exists(keyHashKey); err == nil && exists {
+			if err := p.store.HSet(keyHashKey, map[string]any{"expires_at": balance.ExpiresAt.Unix()}); err != nil {
+				return false, fmt.Errorf("failed to update key expiry in store: %w", err)
+			}
+		}
+	}
+
+	if balance.Balance != nil && *balance.Balance <= 0 && !balance.Estimated {
+		p.disableKey(keyID, groupID, "Key has no balance left, disabling.")
+		return true, nil
+	}
+	return false, nil
+}
+
 // RemoveInvalidKeys 移除组内所有无效的 Key。
 func (p *KeyProvider) RemoveInvalidKeys(groupID uint) (int64, error) {
 	return p.removeKeysByStatus(groupID, models.KeyStatusInvalid)
@@ -520,17 +929,22 @@ func (p *KeyProvider) RemoveKeysFromStore(groupID uint, keyIDs []uint) error {
 		return nil
 	}
 
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-
-	// 第一步：直接删除整个 active_keys 列表
-	if err := p.store.Delete(activeKeysListKey); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"groupID": groupID,
-			"error":   err,
-		}).Error("Failed to delete active keys list")
+	activeKeyLists, err := ActiveKeyLists(p.store, groupID)
+	if err != nil {
 		return err
 	}
 
+	// 第一步：直接删除所有优先级的 active_keys 列表
+	for _, activeKeysListKey := range append(activeKeyLists, keyPrioritiesKey(groupID)) {
+		if err := p.store.Delete(activeKeysListKey); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"groupID": groupID,
+				"error":   err,
+			}).Error("Failed to delete active keys list")
+			return err
+		}
+	}
+
 	// 第二步：批量删除所有相关的key hash
 	for _, keyID := range keyIDs {
 		keyHashKey := fmt.Sprintf("key:%d", keyID)
@@ -559,9 +973,12 @@ func (p *KeyProvider) addKeyToStore(key *models.APIKey) error {
 		return fmt.Errorf("failed to HSet key details for key %d: %w", key.ID, err)
 	}
 
-	// 2. If active, add to the active LIST
-	if key.Status == models.KeyStatusActive {
-		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", key.GroupID)
+	// 2. If active and inside its schedule, add to the active LIST
+	if isServable(key) {
+		activeKeysListKey := ActiveKeysListKey(key.GroupID, key.Priority)
+		if err := p.trackPriority(key.GroupID, key.Priority); err != nil {
+			return fmt.Errorf("failed to record priority of key %d: %w", key.ID, err)
+		}
 		if err := p.store.LRem(activeKeysListKey, 0, key.ID); err != nil {
 			return fmt.Errorf("failed to LRem key %d before LPush for group %d: %w", key.ID, key.GroupID, err)
 		}
@@ -599,16 +1016,23 @@ func (p *KeyProvider) addKeysToCacheBatch(groupID uint, keys []models.APIKey) er
 		}
 	}
 
-	// 2. 收集所有密钥 ID
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
-	activeKeyIDs := make([]any, len(keys))
+	// 2. 按优先级收集所有活跃密钥 ID
+	activeKeyIDs := make(map[int][]any)
 	for i := range keys {
-		activeKeyIDs[i] = keys[i].ID
+		if !isServable(&keys[i]) {
+			continue
+		}
+		activeKeyIDs[keys[i].Priority] = append(activeKeyIDs[keys[i].Priority], keys[i].ID)
 	}
 
 	// 3. 批量 LPush 活跃密钥
-	if err := p.store.LPush(activeKeysListKey, activeKeyIDs...); err != nil {
-		return fmt.Errorf("failed to batch LPush keys to group %d: %w", groupID, err)
+	for priority, ids := range activeKeyIDs {
+		if err := p.trackPriority(groupID, priority); err != nil {
+			return fmt.Errorf("failed to record key priority for group %d: %w", groupID, err)
+		}
+		if err := p.store.LPush(ActiveKeysListKey(groupID, priority), ids...); err != nil {
+			return fmt.Errorf("failed to batch LPush keys to group %d: %w", groupID, err)
+		}
 	}
 
 	return nil
@@ -616,12 +1040,17 @@ func (p *KeyProvider) addKeysToCacheBatch(groupID uint, keys []models.APIKey) er
 
 // removeKeyFromStore is a helper to remove a single key from the cache.
 func (p *KeyProvider) removeKeyFromStore(keyID, groupID uint) error {
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	keyHashKey := fmt.Sprintf("key:%d", keyID)
+	keyDetails, err := p.store.HGetAll(keyHashKey)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to get key details before removal")
+	}
+
+	activeKeysListKey := p.activeKeysListKeyOf(groupID, keyDetails)
 	if err := p.store.LRem(activeKeysListKey, 0, keyID); err != nil {
 		logrus.WithFields(logrus.Fields{"keyID": keyID, "groupID": groupID, "error": err}).Error("Failed to LRem key from active list")
 	}
 
-	keyHashKey := fmt.Sprintf("key:%d", keyID)
 	if err := p.store.Delete(keyHashKey); err != nil {
 		return fmt.Errorf("failed to delete key HASH for key %d: %w", keyID, err)
 	}
@@ -630,6 +1059,10 @@ func (p *KeyProvider) removeKeyFromStore(keyID, groupID uint) error {
 
 // apiKeyToMap converts an APIKey model to a map for HSET.
 func (p *KeyProvider) apiKeyToMap(key *models.APIKey) map[string]any {
+	var expiresAt int64
+	if key.ExpiresAt != nil {
+		expiresAt = key.ExpiresAt.Unix()
+	}
 	return map[string]any{
 		"id":            fmt.Sprint(key.ID),
 		"key_string":    key.KeyValue,
@@ -637,7 +1070,36 @@ func (p *KeyProvider) apiKeyToMap(key *models.APIKey) map[string]any {
 		"failure_count": key.FailureCount,
 		"group_id":      key.GroupID,
 		"created_at":    key.CreatedAt.Unix(),
+		"priority":      key.Priority,
+		"rpm_limit":     key.RPMLimit,
+		"expires_at":    expiresAt,
+		// The schedule is kept as JSON so selection can check it without the database
+		"active_schedule": string(key.Schedule),
+		"schedule_closed": scheduleClosedFlag(key, time.Now()),
+	}
+}
+
+// isServable reports whether an active key belongs in its active list, which is not
+// the case while its schedule is closed.
+func isServable(key *models.APIKey) bool {
+	return key.Status == models.KeyStatusActive && utils.IsRawScheduleActive(string(key.Schedule), time.Now())
+}
+
+// scheduleClosedFlag returns the schedule_closed store field of a key: "1" while its schedule is closed.
+func scheduleClosedFlag(key *models.APIKey, now time.Time) string {
+	if utils.IsRawScheduleActive(string(key.Schedule), now) {
+		return "0"
+	}
+	return "1"
+}
+
+// trackPriority records that a group has keys at a priority, so SelectKey looks at its list.
+// Priority 0 is always looked at and needs no record.
+func (p *KeyProvider) trackPriority(groupID uint, priority int) error {
+	if priority == 0 {
+		return nil
 	}
+	return p.store.HSet(keyPrioritiesKey(groupID), map[string]any{strconv.Itoa(priority): 1})
 }
 
 // pluckIDs extracts IDs from a slice of APIKey.
@@ -2,6 +2,7 @@ package keypool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
@@ -71,6 +72,13 @@ func (s *KeyValidator) ValidateSingleKey(key *models.APIKey, group *models.Group
 	}
 	s.keypoolProvider.UpdateStatus(key, group, isValid, errorMsg)
 
+	if err := s.DB.Model(&models.APIKey{}).Where("id = ?", key.ID).Updates(map[string]any{
+		"last_validated_at":     time.Now(),
+		"last_validation_error": errorMsg,
+	}).Error; err != nil {
+		logrus.WithError(err).WithField("key_id", key.ID).Warn("Failed to record key validation result")
+	}
+
 	if !isValid {
 		logrus.WithFields(logrus.Fields{
 			"error":    validationErr,
@@ -88,6 +96,40 @@ func (s *KeyValidator) ValidateSingleKey(key *models.APIKey, group *models.Group
 	return true, nil
 }
 
+// ProbeKeyBalance fetches the balance of a key through the group's channel and records it.
+// It reports whether the key was disabled because it has no balance left.
+func (s *KeyValidator) ProbeKeyBalance(key *models.APIKey, group *models.Group) (bool, error) {
+	if group.EffectiveConfig.AppUrl == "" {
+		group.EffectiveConfig = s.SettingsManager.GetEffectiveConfig(group.Config)
+	}
+
+	ch, err := s.channelFactory.GetChannel(group)
+	if err != nil {
+		return false, fmt.Errorf("failed to get channel for group %s: %w", group.Name, err)
+	}
+	prober, ok := ch.(channel.BalanceProber)
+	if !ok {
+		return false, channel.ErrBalanceProbeUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(group.EffectiveConfig.KeyValidationTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	balance, err := prober.ProbeBalance(ctx, key, group)
+	if err != nil {
+		if !errors.Is(err, channel.ErrBalanceProbeUnsupported) {
+			logrus.WithFields(logrus.Fields{
+				"error":    err,
+				"key_id":   key.ID,
+				"group_id": group.ID,
+			}).Warn("Key balance probe failed")
+		}
+		return false, err
+	}
+
+	return s.keypoolProvider.UpdateKeyBalance(key.ID, group.ID, balance)
+}
+
 // TestMultipleKeys performs a synchronous validation for a list of key values within a specific group.
 func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string) ([]KeyTestResult, error) {
 	results := make([]KeyTestResult, len(keyValues))
@@ -95,11 +137,7 @@ func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string)
 	// Generate hashes for all key values
 	var keyHashes []string
 	for _, keyValue := range keyValues {
-		keyHash := s.encryptionSvc.Hash(keyValue)
-		if keyHash == "" {
-			continue
-		}
-		keyHashes = append(keyHashes, keyHash)
+		keyHashes = append(keyHashes, encryption.HashCandidates(s.encryptionSvc, keyValue)...)
 	}
 
 	// Find which of the provided keys actually exist in the database for this group
@@ -117,8 +155,13 @@ func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string)
 	}
 
 	for i, kv := range keyValues {
-		keyHash := s.encryptionSvc.Hash(kv)
-		apiKey, exists := existingKeyMap[keyHash]
+		var apiKey models.APIKey
+		exists := false
+		for _, keyHash := range encryption.HashCandidates(s.encryptionSvc, kv) {
+			if apiKey, exists = existingKeyMap[keyHash]; exists {
+				break
+			}
+		}
 		if !exists {
 			results[i] = KeyTestResult{
 				KeyValue: kv,
@@ -2,15 +2,19 @@
 package middleware
 
 import (
-	"crypto/subtle"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"gpt-load/internal/config"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/services"
 	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -115,8 +119,13 @@ func CORS(config types.CORSConfig) gin.HandlerFunc {
 	}
 }
 
-// Auth creates an authentication middleware
-func Auth(authConfig types.AuthConfig) gin.HandlerFunc {
+// AdminPrincipalKey is the context key of the authenticated admin principal
+const AdminPrincipalKey = "admin_principal"
+
+// Auth authenticates admin requests with the AUTH_KEY, a login session or an API token.
+// Failed credentials are tracked by authGuard in the login scope, so guessing against any
+// admin route is delayed and banned like guessing against the login form.
+func Auth(authService *services.AdminAuthService, authGuard *services.AuthGuardService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 
@@ -126,21 +135,71 @@ func Auth(authConfig types.AuthConfig) gin.HandlerFunc {
 		}
 
 		key := extractAuthKey(c)
+		if key == "" {
+			// SSO logins keep their session in a cookie
+			key, _ = c.Cookie(services.AdminSessionCookie)
+		}
 
-		isValid := key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(authConfig.Key)) == 1
+		attempt := services.AuthAttempt{Scope: services.AuthScopeLogin, IP: c.ClientIP(), Key: key}
+		if ban := authGuard.Check(attempt); ban != nil {
+			c.Header("Retry-After", strconv.Itoa(ban.RetryAfter()))
+			response.Error(c, app_errors.ErrTooManyAttempts)
+			c.Abort()
+			return
+		}
 
-		if !isValid {
+		principal, err := authService.Authenticate(c.Request.Context(), key)
+		if err != nil {
+			if !errors.Is(err, services.ErrAdminUnauthorized) {
+				logrus.WithError(err).Error("Failed to authenticate admin request")
+			} else if key != "" {
+				// Requests without a credential, such as the web UI before login, are not guesses
+				rejectCredential(c, authGuard, attempt)
+				return
+			}
 			response.Error(c, app_errors.ErrUnauthorized)
 			c.Abort()
 			return
 		}
 
+		c.Set(AdminPrincipalKey, principal)
+		c.Request = c.Request.WithContext(services.WithAuditActor(c.Request.Context(), services.AuditActor{
+			Username:   principal.Username,
+			Role:       principal.Role,
+			AuthMethod: principal.AuthMethod,
+			SourceIP:   c.ClientIP(),
+		}))
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests whose principal does not have at least the given role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetAdminPrincipal(c)
+		if !principal.HasRole(role) {
+			response.Error(c, app_errors.ErrForbidden)
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// GetAdminPrincipal returns the principal set by Auth, or nil
+func GetAdminPrincipal(c *gin.Context) *models.AdminPrincipal {
+	if value, exists := c.Get(AdminPrincipalKey); exists {
+		if principal, ok := value.(*models.AdminPrincipal); ok {
+			return principal
+		}
+	}
+	return nil
+}
+
 // ProxyAuth
-func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
+// The client IP must pass the global, group and proxy key IP rules in addition to the key check.
+// Failed attempts are tracked by authGuard, which delays and temporarily bans repeated failures.
+func ProxyAuth(gm *services.GroupManager, proxyKeyService *services.ProxyKeyService, settingsManager *config.SystemSettingsManager, authGuard *services.AuthGuardService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check key
 		key := extractAuthKey(c)
@@ -150,6 +209,15 @@ func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
 			return
 		}
 
+		clientIP := c.ClientIP()
+		attempt := services.AuthAttempt{Scope: services.AuthScopeProxy, IP: clientIP, Key: key}
+		if ban := authGuard.Check(attempt); ban != nil {
+			c.Header("Retry-After", strconv.Itoa(ban.RetryAfter()))
+			response.Error(c, app_errors.ErrTooManyAttempts)
+			c.Abort()
+			return
+		}
+
 		group, err := gm.GetGroupByName(c.Param("group_name"))
 		if err != nil {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to retrieve proxy group"))
@@ -157,21 +225,178 @@ func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
 			return
 		}
 
-		// Check both key collections to prevent timing attacks
-		_, existsInEffective := group.EffectiveConfig.ProxyKeysMap[key]
-		_, existsInGroup := group.ProxyKeysMap[key]
+		settings := settingsManager.GetSettings()
+		if !ipRulesAllow(settings.ProxyIPAllowlist, settings.ProxyIPDenylist, clientIP) {
+			denyProxyIP(c, group.Name, "global", "")
+			return
+		}
+		if !groupIPRulesAllow(settings, group, clientIP) {
+			denyProxyIP(c, group.Name, "group", "")
+			return
+		}
 
-		if existsInEffective || existsInGroup {
-			c.Next()
+		match := proxyKeyService.Authenticate(group.ID, key, clientIP)
+		if match == nil {
+			rejectCredential(c, authGuard, attempt)
+			return
+		}
+		if !match.IPAllowed {
+			denyProxyIP(c, group.Name, "proxy_key", match.Preview)
+			return
+		}
+
+		c.Set(ProxyKeyMatchKey, match)
+		c.Next()
+	}
+}
+
+// ProxyKeyMatchKey is the context key of the *services.ProxyKeyMatch set by ProxyAuth
+const ProxyKeyMatchKey = "proxyKeyMatch"
+
+// IntegrationGroupsKey is the context key of the []*models.Group set by IntegrationAuth
+const IntegrationGroupsKey = "integrationGroups"
+
+// IntegrationAuth authenticates the global integration info request, which lists every group a proxy key
+// may access. It applies the same failure tracking and IP rules as ProxyAuth, and only groups whose
+// group and proxy key IP rules allow the client are passed on.
+func IntegrationAuth(groupService *services.GroupService, gm *services.GroupManager, proxyKeyService *services.ProxyKeyService, settingsManager *config.SystemSettingsManager, authGuard *services.AuthGuardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := extractAuthKey(c)
+		if key == "" {
+			response.Error(c, app_errors.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		clientIP := c.ClientIP()
+		attempt := services.AuthAttempt{Scope: services.AuthScopeProxy, IP: clientIP, Key: key}
+		if ban := authGuard.Check(attempt); ban != nil {
+			c.Header("Retry-After", strconv.Itoa(ban.RetryAfter()))
+			response.Error(c, app_errors.ErrTooManyAttempts)
+			c.Abort()
+			return
+		}
+
+		settings := settingsManager.GetSettings()
+		if !ipRulesAllow(settings.ProxyIPAllowlist, settings.ProxyIPDenylist, clientIP) {
+			denyProxyIP(c, "", "global", "")
+			return
+		}
+
+		groups, err := groupService.ListGroups(c.Request.Context())
+		if err != nil {
+			response.Error(c, app_errors.ErrInternalServer)
+			c.Abort()
 			return
 		}
 
+		var allowed []*models.Group
+		authenticated := false
+		for i := range groups {
+			group, err := gm.GetGroupByName(groups[i].Name)
+			if err != nil {
+				logrus.Warnf("Failed to get group %s from cache: %v", groups[i].Name, err)
+				continue
+			}
+			match := proxyKeyService.Authenticate(group.ID, key, clientIP)
+			if match == nil {
+				continue
+			}
+			authenticated = true
+			if match.IPAllowed && groupIPRulesAllow(settings, group, clientIP) {
+				allowed = append(allowed, group)
+			}
+		}
+
+		if !authenticated {
+			rejectCredential(c, authGuard, attempt)
+			return
+		}
+		if len(allowed) == 0 {
+			denyProxyIP(c, "", "group", "")
+			return
+		}
+
+		c.Set(IntegrationGroupsKey, allowed)
+		c.Next()
+	}
+}
+
+// groupIPRulesAllow reports whether ip passes the proxy IP rules of a group. Group rules are checked
+// in addition to the global rules, so they can only narrow access.
+func groupIPRulesAllow(settings types.SystemSettings, group *models.Group, ip string) bool {
+	groupConfig := group.EffectiveConfig
+	if groupConfig.ProxyIPAllowlist == settings.ProxyIPAllowlist && groupConfig.ProxyIPDenylist == settings.ProxyIPDenylist {
+		return true
+	}
+	return ipRulesAllow(groupConfig.ProxyIPAllowlist, groupConfig.ProxyIPDenylist, ip)
+}
+
+// rejectCredential records a failed authentication attempt, delays the response and rejects the request,
+// with a ban once the attempt's subjects reached the failure limit.
+func rejectCredential(c *gin.Context, authGuard *services.AuthGuardService, attempt services.AuthAttempt) {
+	services.DelayFailure(c.Request.Context(), authGuard.RecordFailure(attempt))
+	if ban := authGuard.CheckFailure(attempt); ban != nil {
+		c.Header("Retry-After", strconv.Itoa(ban.RetryAfter()))
+		response.Error(c, app_errors.ErrTooManyAttempts)
+	} else {
 		response.Error(c, app_errors.ErrUnauthorized)
+	}
+	c.Abort()
+}
+
+// AdminIPFilter rejects admin API requests from addresses outside the admin IP rules
+func AdminIPFilter(settingsManager *config.SystemSettingsManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := settingsManager.GetSettings()
+		if ipRulesAllow(settings.AdminIPAllowlist, settings.AdminIPDenylist, c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"client_ip": c.ClientIP(),
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+		}).Warn("Admin API request denied by IP rules")
+		response.ErrorI18nFromAPIError(c, app_errors.ErrForbidden, "auth.ip_not_allowed")
 		c.Abort()
 	}
 }
 
-// ProxyRouteDispatcher dispatches special routes before proxy authentication
+// ipRulesAllow reports whether ip passes the given allow and deny lists.
+// Lists that cannot be parsed deny every address, as they are validated when saved.
+func ipRulesAllow(allow, deny, ip string) bool {
+	if allow == "" && deny == "" {
+		return true
+	}
+	rules, err := utils.CompileIPRules(allow, deny)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid IP rules, denying request")
+		return false
+	}
+	return rules.Allowed(ip)
+}
+
+// denyProxyIP logs and rejects a proxy request whose client IP is not allowed
+func denyProxyIP(c *gin.Context, groupName, level, keyPreview string) {
+	fields := logrus.Fields{
+		"client_ip": c.ClientIP(),
+		"rule":      level,
+	}
+	if groupName != "" {
+		fields["group"] = groupName
+	}
+	if keyPreview != "" {
+		fields["proxy_key"] = keyPreview
+	}
+	logrus.WithFields(fields).Warn("Proxy request denied by IP rules")
+
+	response.Error(c, app_errors.NewAPIError(app_errors.ErrForbidden, "Access from this IP address is not allowed"))
+	c.Abort()
+}
+
+// ProxyRouteDispatcher dispatches special routes of an authenticated proxy group
 func ProxyRouteDispatcher(serverHandler interface{ GetIntegrationInfo(*gin.Context) }) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Param("path") == "/api/integration/info" {
@@ -193,9 +418,9 @@ func Recovery() gin.HandlerFunc {
 	})
 }
 
-// RateLimiter creates a simple rate limiting middleware
+// RateLimiter caps the number of requests served concurrently by this node.
+// Client limits are enforced per group and proxy key by services.RateLimitService.
 func RateLimiter(config types.PerformanceConfig) gin.HandlerFunc {
-	// Simple semaphore-based rate limiting
 	semaphore := make(chan struct{}, config.MaxConcurrentRequests)
 
 	return func(c *gin.Context) {
@@ -204,7 +429,8 @@ func RateLimiter(config types.PerformanceConfig) gin.HandlerFunc {
 			defer func() { <-semaphore }()
 			c.Next()
 		default:
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Too many concurrent requests"))
+			c.Header("Retry-After", "1")
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrRateLimited, "Too many concurrent requests on this node"))
 			c.Abort()
 		}
 	}
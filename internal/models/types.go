@@ -9,8 +9,9 @@ import (
 
 // Key状态
 const (
-	KeyStatusActive  = "active"
-	KeyStatusInvalid = "invalid"
+	KeyStatusActive   = "active"
+	KeyStatusInvalid  = "invalid"
+	KeyStatusDisabled = "disabled" // 被手动或由密钥来源停用，不参与轮询也不会被自动恢复
 )
 
 // SystemSetting 对应 system_settings 表
@@ -25,19 +26,34 @@ type SystemSetting struct {
 
 // GroupConfig 存储特定于分组的配置
 type GroupConfig struct {
-	RequestTimeout               *int    `json:"request_timeout,omitempty"`
-	IdleConnTimeout              *int    `json:"idle_conn_timeout,omitempty"`
-	ConnectTimeout               *int    `json:"connect_timeout,omitempty"`
-	MaxIdleConns                 *int    `json:"max_idle_conns,omitempty"`
-	MaxIdleConnsPerHost          *int    `json:"max_idle_conns_per_host,omitempty"`
-	ResponseHeaderTimeout        *int    `json:"response_header_timeout,omitempty"`
-	ProxyURL                     *string `json:"proxy_url,omitempty"`
-	MaxRetries                   *int    `json:"max_retries,omitempty"`
-	BlacklistThreshold           *int    `json:"blacklist_threshold,omitempty"`
-	KeyValidationIntervalMinutes *int    `json:"key_validation_interval_minutes,omitempty"`
-	KeyValidationConcurrency     *int    `json:"key_validation_concurrency,omitempty"`
-	KeyValidationTimeoutSeconds  *int    `json:"key_validation_timeout_seconds,omitempty"`
-	EnableRequestBodyLogging     *bool   `json:"enable_request_body_logging,omitempty"`
+	RequestTimeout                     *int    `json:"request_timeout,omitempty"`
+	IdleConnTimeout                    *int    `json:"idle_conn_timeout,omitempty"`
+	ConnectTimeout                     *int    `json:"connect_timeout,omitempty"`
+	MaxIdleConns                       *int    `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost                *int    `json:"max_idle_conns_per_host,omitempty"`
+	ResponseHeaderTimeout              *int    `json:"response_header_timeout,omitempty"`
+	ProxyURL                           *string `json:"proxy_url,omitempty"`
+	MaxRetries                         *int    `json:"max_retries,omitempty"`
+	BlacklistThreshold                 *int    `json:"blacklist_threshold,omitempty"`
+	KeyValidationIntervalMinutes       *int    `json:"key_validation_interval_minutes,omitempty"`
+	KeyValidationConcurrency           *int    `json:"key_validation_concurrency,omitempty"`
+	KeyValidationTimeoutSeconds        *int    `json:"key_validation_timeout_seconds,omitempty"`
+	EnableRequestBodyLogging           *bool   `json:"enable_request_body_logging,omitempty"`
+	EnableAdaptiveWeights              *bool   `json:"enable_adaptive_weights,omitempty"`
+	AdaptiveMinWeight                  *int    `json:"adaptive_min_weight,omitempty"`
+	AdaptiveMaxWeight                  *int    `json:"adaptive_max_weight,omitempty"`
+	ProxyIPAllowlist                   *string `json:"proxy_ip_allowlist,omitempty"`
+	ProxyIPDenylist                    *string `json:"proxy_ip_denylist,omitempty"`
+	RateLimitRPM                       *int    `json:"rate_limit_rpm,omitempty"`
+	RateLimitTPM                       *int    `json:"rate_limit_tpm,omitempty"`
+	RateLimitConcurrency               *int    `json:"rate_limit_concurrency,omitempty"`
+	RequestQueueSize                   *int    `json:"request_queue_size,omitempty"`
+	RequestQueueTimeoutSeconds         *int    `json:"request_queue_timeout_seconds,omitempty"`
+	BalanceProbe                       *string `json:"balance_probe,omitempty"`
+	KeyValidationCron                  *string `json:"key_validation_cron,omitempty"`
+	KeyValidationJitterSeconds         *int    `json:"key_validation_jitter_seconds,omitempty"`
+	ActiveKeyValidationIntervalMinutes *int    `json:"active_key_validation_interval_minutes,omitempty"`
+	ActiveKeyValidationSamplePercent   *int    `json:"active_key_validation_sample_percent,omitempty"`
 }
 
 // HeaderRule defines a single rule for header manipulation.
@@ -47,26 +63,70 @@ type HeaderRule struct {
 	Action string `json:"action"` // "set" or "remove"
 }
 
+// Budget periods and metrics
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodMonthly = "monthly"
+
+	BudgetMetricRequests = "requests"
+	BudgetMetricTokens   = "tokens"
+	BudgetMetricCost     = "cost"
+)
+
+// BudgetRule defines a spend limit of a group for a period. A zero limit is disabled.
+type BudgetRule struct {
+	Period    string  `json:"period"`     // "daily" or "monthly"
+	Metric    string  `json:"metric"`     // "requests", "tokens" or "cost"
+	SoftLimit float64 `json:"soft_limit"` // Raises an alert when reached
+	HardLimit float64 `json:"hard_limit"` // Rejects requests when reached
+}
+
+// ActiveSchedule limits a key or sub-group to time windows. Without windows it is always active.
+type ActiveSchedule struct {
+	Timezone string         `json:"timezone,omitempty"` // IANA name such as "Asia/Shanghai", server time when empty
+	Windows  []ActiveWindow `json:"windows"`
+}
+
+// ActiveWindow is one period of an ActiveSchedule: either a cron expression matching every
+// active minute, or a time range on some days of the week.
+type ActiveWindow struct {
+	Cron  string `json:"cron,omitempty"`  // e.g. "* 0-7 * * 1-5" for 00:00-07:59 on weekdays
+	Days  []int  `json:"days,omitempty"`  // 0 (Sunday) to 6, every day when empty
+	Start string `json:"start,omitempty"` // "HH:MM", inclusive
+	End   string `json:"end,omitempty"`   // "HH:MM", exclusive; an end before the start runs past midnight
+}
+
 // GroupSubGroup 聚合分组和子分组的关联表
 type GroupSubGroup struct {
-	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	GroupID    uint      `gorm:"not null;uniqueIndex:idx_group_sub" json:"group_id"`
-	SubGroupID uint      `gorm:"not null;uniqueIndex:idx_group_sub" json:"sub_group_id"`
-	Weight     int       `gorm:"default:0" json:"weight"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	GroupID    uint           `gorm:"not null;uniqueIndex:idx_group_sub" json:"group_id"`
+	SubGroupID uint           `gorm:"not null;uniqueIndex:idx_group_sub" json:"sub_group_id"`
+	Weight     int            `gorm:"default:0" json:"weight"`
+	Priority   int            `gorm:"default:0" json:"priority"` // Lower values are served first
+	Models     datatypes.JSON `gorm:"type:json" json:"models"`   // Declared supported models, empty means auto-discover
+	Schedule   datatypes.JSON `gorm:"type:json" json:"active_schedule"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
 
 	// Lightweight association - only store necessary info for performance
 	SubGroupName string `gorm:"-" json:"sub_group_name,omitempty"`
+
+	// For cache
+	SupportedModels []string        `gorm:"-" json:"-"`
+	ActiveSchedule  *ActiveSchedule `gorm:"-" json:"-"`
+	SubGroupState   string          `gorm:"-" json:"-"`
 }
 
 // SubGroupInfo 用于API响应的子分组信息
 type SubGroupInfo struct {
-	Group       Group `json:"group"`
-	Weight      int   `json:"weight"`
-	TotalKeys   int64 `json:"total_keys"`
-	ActiveKeys  int64 `json:"active_keys"`
-	InvalidKeys int64 `json:"invalid_keys"`
+	Group       Group           `json:"group"`
+	Weight      int             `json:"weight"`
+	Priority    int             `json:"priority"`
+	Models      []string        `json:"models"`
+	Schedule    *ActiveSchedule `json:"active_schedule"`
+	TotalKeys   int64           `json:"total_keys"`
+	ActiveKeys  int64           `json:"active_keys"`
+	InvalidKeys int64           `json:"invalid_keys"`
 }
 
 // ParentAggregateGroupInfo 用于API响应的父聚合分组信息
@@ -77,51 +137,86 @@ type ParentAggregateGroupInfo struct {
 	Weight      int    `json:"weight"`
 }
 
+// 分组状态，只有 enabled 状态的分组接受新的代理请求
+const (
+	GroupStateEnabled     = "enabled"
+	GroupStateDraining    = "draining" // 不再接受新请求，已在处理中的请求（包括流式响应）正常完成
+	GroupStateDisabled    = "disabled"
+	GroupStateMaintenance = "maintenance"
+)
+
 // Group 对应 groups 表
 type Group struct {
-	ID                  uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
-	EffectiveConfig     types.SystemSettings `gorm:"-" json:"effective_config,omitempty"`
-	Name                string               `gorm:"type:varchar(255);not null;unique" json:"name"`
-	Endpoint            string               `gorm:"-" json:"endpoint"`
-	DisplayName         string               `gorm:"type:varchar(255)" json:"display_name"`
-	ProxyKeys           string               `gorm:"type:text" json:"proxy_keys"`
-	Description         string               `gorm:"type:varchar(512)" json:"description"`
-	GroupType           string               `gorm:"type:varchar(50);default:'standard'" json:"group_type"` // 'standard' or 'aggregate'
-	Upstreams           datatypes.JSON       `gorm:"type:json;not null" json:"upstreams"`
-	ValidationEndpoint  string               `gorm:"type:varchar(255)" json:"validation_endpoint"`
-	ChannelType         string               `gorm:"type:varchar(50);not null" json:"channel_type"`
-	Sort                int                  `gorm:"default:0" json:"sort"`
-	TestModel           string               `gorm:"type:varchar(255);not null" json:"test_model"`
-	ParamOverrides      datatypes.JSONMap    `gorm:"type:json" json:"param_overrides"`
-	Config              datatypes.JSONMap    `gorm:"type:json" json:"config"`
-	HeaderRules         datatypes.JSON       `gorm:"type:json" json:"header_rules"`
-	ModelRedirectRules  datatypes.JSONMap    `gorm:"type:json" json:"model_redirect_rules"`
-	ModelRedirectStrict bool                 `gorm:"default:false" json:"model_redirect_strict"`
-	APIKeys             []APIKey             `gorm:"foreignKey:GroupID" json:"api_keys"`
-	SubGroups           []GroupSubGroup      `gorm:"-" json:"sub_groups,omitempty"`
-	LastValidatedAt     *time.Time           `json:"last_validated_at"`
-	CreatedAt           time.Time            `json:"created_at"`
-	UpdatedAt           time.Time            `json:"updated_at"`
+	ID                    uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	EffectiveConfig       types.SystemSettings `gorm:"-" json:"effective_config,omitempty"`
+	Name                  string               `gorm:"type:varchar(255);not null;unique" json:"name"`
+	Endpoint              string               `gorm:"-" json:"endpoint"`
+	DisplayName           string               `gorm:"type:varchar(255)" json:"display_name"`
+	ProxyKeys             string               `gorm:"type:text" json:"proxy_keys"` // 旧版明文代理密钥，执行 migrate-proxy-keys 后为空
+	Description           string               `gorm:"type:varchar(512)" json:"description"`
+	GroupType             string               `gorm:"type:varchar(50);default:'standard'" json:"group_type"` // 'standard' or 'aggregate'
+	Upstreams             datatypes.JSON       `gorm:"type:json;not null" json:"upstreams"`
+	ValidationEndpoint    string               `gorm:"type:varchar(255)" json:"validation_endpoint"`
+	ChannelType           string               `gorm:"type:varchar(50);not null" json:"channel_type"`
+	Sort                  int                  `gorm:"default:0" json:"sort"`
+	TestModel             string               `gorm:"type:varchar(255);not null" json:"test_model"`
+	ParamOverrides        datatypes.JSONMap    `gorm:"type:json" json:"param_overrides"`
+	Config                datatypes.JSONMap    `gorm:"type:json" json:"config"`
+	HeaderRules           datatypes.JSON       `gorm:"type:json" json:"header_rules"`
+	ModelRedirectRules    datatypes.JSONMap    `gorm:"type:json" json:"model_redirect_rules"`
+	ModelRedirectStrict   bool                 `gorm:"default:false" json:"model_redirect_strict"`
+	Budgets               datatypes.JSON       `gorm:"type:json" json:"budgets"`
+	State                 string               `gorm:"type:varchar(20);not null;default:'enabled'" json:"state"` // enabled, draining, disabled or maintenance
+	StateMessage          string               `gorm:"type:varchar(512);default:''" json:"state_message"`        // Error message returned while the group is not enabled
+	StateStatusCode       int                  `gorm:"not null;default:0" json:"state_status_code"`              // HTTP status returned while the group is not enabled, 0 means 503
+	APIKeys               []APIKey             `gorm:"foreignKey:GroupID" json:"api_keys"`
+	SubGroups             []GroupSubGroup      `gorm:"-" json:"sub_groups,omitempty"`
+	LastValidatedAt       *time.Time           `json:"last_validated_at"`
+	LastActiveValidatedAt *time.Time           `json:"last_active_validated_at"`
+	CreatedAt             time.Time            `json:"created_at"`
+	UpdatedAt             time.Time            `json:"updated_at"`
 
 	// For cache
-	ProxyKeysMap     map[string]struct{} `gorm:"-" json:"-"`
-	HeaderRuleList   []HeaderRule        `gorm:"-" json:"-"`
-	ModelRedirectMap map[string]string   `gorm:"-" json:"-"`
+	HeaderRuleList   []HeaderRule      `gorm:"-" json:"-"`
+	ModelRedirectMap map[string]string `gorm:"-" json:"-"`
+	BudgetRuleList   []BudgetRule      `gorm:"-" json:"-"`
+}
+
+// IsEnabled reports whether the group accepts new proxy requests.
+func (g *Group) IsEnabled() bool {
+	return g.State == "" || g.State == GroupStateEnabled
 }
 
 // APIKey 对应 api_keys 表
 type APIKey struct {
-	ID           uint       `gorm:"primaryKey;autoIncrement;index:idx_api_keys_group_last_used_id,priority:3" json:"id"`
-	KeyValue     string     `gorm:"type:text;not null" json:"key_value"`
-	KeyHash      string     `gorm:"type:varchar(128);index" json:"key_hash"`
-	GroupID      uint       `gorm:"not null;index;index:idx_api_keys_group_last_used_id,priority:1" json:"group_id"`
-	Status       string     `gorm:"type:varchar(50);not null;default:'active';index" json:"status"`
-	Notes        string     `gorm:"type:varchar(255);default:''" json:"notes"`
-	RequestCount int64      `gorm:"not null;default:0" json:"request_count"`
-	FailureCount int64      `gorm:"not null;default:0" json:"failure_count"`
-	LastUsedAt   *time.Time `gorm:"index:idx_api_keys_group_last_used_id,priority:2" json:"last_used_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID                  uint              `gorm:"primaryKey;autoIncrement;index:idx_api_keys_group_last_used_id,priority:3" json:"id"`
+	KeyValue            string            `gorm:"type:text;not null" json:"key_value"`
+	KeyHash             string            `gorm:"type:varchar(128);index" json:"key_hash"`
+	GroupID             uint              `gorm:"not null;index;index:idx_api_keys_group_last_used_id,priority:1" json:"group_id"`
+	Status              string            `gorm:"type:varchar(50);not null;default:'active';index" json:"status"`
+	Notes               string            `gorm:"type:varchar(255);default:''" json:"notes"`
+	Tags                datatypes.JSONMap `gorm:"type:json" json:"tags"`               // Labels such as org, account or tier
+	Priority            int               `gorm:"not null;default:0" json:"priority"`  // Lower values are served first
+	ExpiresAt           *time.Time        `gorm:"index" json:"expires_at"`             // Optional, the key is disabled once it passes
+	RPMLimit            int               `gorm:"not null;default:0" json:"rpm_limit"` // Requests per minute through this key, 0 means unlimited
+	Schedule            datatypes.JSON    `gorm:"type:json" json:"active_schedule"`    // Optional ActiveSchedule, the key is only served inside its windows
+	Balance             *float64          `json:"balance"`                             // Remaining balance or credit reported by the upstream, nil when unknown
+	BalanceCurrency     string            `gorm:"type:varchar(16);default:''" json:"balance_currency"`
+	RateTier            string            `gorm:"type:varchar(64);default:''" json:"rate_tier"`
+	BalanceCheckedAt    *time.Time        `json:"balance_checked_at"`
+	LastValidatedAt     *time.Time        `json:"last_validated_at"`                      // Last background or manual validation of this key
+	LastValidationError string            `gorm:"type:text" json:"last_validation_error"` // Reason of the last failed validation, empty when it passed
+	SourceID            *uint             `gorm:"index" json:"source_id"`                 // Key source that imported the key, nil for keys added by hand
+	RequestCount        int64             `gorm:"not null;default:0" json:"request_count"`
+	FailureCount        int64             `gorm:"not null;default:0" json:"failure_count"`
+	LastUsedAt          *time.Time        `gorm:"index:idx_api_keys_group_last_used_id,priority:2" json:"last_used_at"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+// IsExpired reports whether the key has passed its expiry date.
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
 }
 
 // RequestType 请求类型常量
@@ -132,26 +227,86 @@ const (
 
 // RequestLog 对应 request_logs 表
 type RequestLog struct {
-	ID              string    `gorm:"type:varchar(36);primaryKey" json:"id"`
-	Timestamp       time.Time `gorm:"not null;index" json:"timestamp"`
-	GroupID         uint      `gorm:"not null;index" json:"group_id"`
-	GroupName       string    `gorm:"type:varchar(255);index" json:"group_name"`
-	ParentGroupID   uint      `gorm:"index" json:"parent_group_id"`
-	ParentGroupName string    `gorm:"type:varchar(255);index" json:"parent_group_name"`
-	KeyValue        string    `gorm:"type:text" json:"key_value"`
-	KeyHash         string    `gorm:"type:varchar(128);index" json:"key_hash"`
-	Model           string    `gorm:"type:varchar(255);index" json:"model"`
-	IsSuccess       bool      `gorm:"not null" json:"is_success"`
-	SourceIP        string    `gorm:"type:varchar(64)" json:"source_ip"`
-	StatusCode      int       `gorm:"not null" json:"status_code"`
-	RequestPath     string    `gorm:"type:varchar(500)" json:"request_path"`
-	Duration        int64     `gorm:"not null" json:"duration_ms"`
-	ErrorMessage    string    `gorm:"type:text" json:"error_message"`
-	UserAgent       string    `gorm:"type:varchar(512)" json:"user_agent"`
-	RequestType     string    `gorm:"type:varchar(20);not null;default:'final';index" json:"request_type"`
-	UpstreamAddr    string    `gorm:"type:varchar(500)" json:"upstream_addr"`
-	IsStream        bool      `gorm:"not null" json:"is_stream"`
-	RequestBody     string    `gorm:"type:text" json:"request_body"`
+	ID                string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Timestamp         time.Time `gorm:"not null;index" json:"timestamp"`
+	GroupID           uint      `gorm:"not null;index" json:"group_id"`
+	GroupName         string    `gorm:"type:varchar(255);index" json:"group_name"`
+	ParentGroupID     uint      `gorm:"index" json:"parent_group_id"`
+	ParentGroupName   string    `gorm:"type:varchar(255);index" json:"parent_group_name"`
+	KeyValue          string    `gorm:"type:text" json:"key_value"`
+	KeyHash           string    `gorm:"type:varchar(128);index" json:"key_hash"`
+	KeyID             uint      `gorm:"index" json:"key_id"`
+	Model             string    `gorm:"type:varchar(255);index" json:"model"`
+	IsSuccess         bool      `gorm:"not null" json:"is_success"`
+	SourceIP          string    `gorm:"type:varchar(64)" json:"source_ip"`
+	StatusCode        int       `gorm:"not null" json:"status_code"`
+	RequestPath       string    `gorm:"type:varchar(500)" json:"request_path"`
+	Duration          int64     `gorm:"not null" json:"duration_ms"`
+	ErrorMessage      string    `gorm:"type:text" json:"error_message"`
+	UserAgent         string    `gorm:"type:varchar(512)" json:"user_agent"`
+	RequestType       string    `gorm:"type:varchar(20);not null;default:'final';index" json:"request_type"`
+	UpstreamAddr      string    `gorm:"type:varchar(500)" json:"upstream_addr"`
+	IsStream          bool      `gorm:"not null" json:"is_stream"`
+	RequestBody       string    `gorm:"type:text" json:"request_body"`
+	InputTokens       int64     `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens      int64     `gorm:"not null;default:0" json:"output_tokens"`
+	CachedInputTokens int64     `gorm:"not null;default:0" json:"cached_input_tokens"`
+	Cost              float64   `gorm:"not null;default:0" json:"cost"`
+}
+
+// TokenUsage 上游响应中解析出的 token 用量，InputTokens 包含 CachedInputTokens
+type TokenUsage struct {
+	InputTokens       int64 `json:"input_tokens"`
+	OutputTokens      int64 `json:"output_tokens"`
+	CachedInputTokens int64 `json:"cached_input_tokens"`
+}
+
+// ModelPrice 对应 model_prices 表，价格单位为每百万 token
+type ModelPrice struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChannelType      string    `gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_model_price" json:"channel_type"` // 为空表示适用于所有渠道
+	ModelPattern     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_model_price" json:"model_pattern"`          // 精确模型名或以 * 结尾的前缀
+	InputPrice       float64   `gorm:"not null;default:0" json:"input_price"`
+	OutputPrice      float64   `gorm:"not null;default:0" json:"output_price"`
+	CachedInputPrice float64   `gorm:"not null;default:0" json:"cached_input_price"` // 为 0 时按输入价格计费
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Key 来源类型
+const (
+	KeySourceTypeURL       = "url"
+	KeySourceTypeVault     = "vault"
+	KeySourceTypeDirectory = "directory"
+)
+
+// Key 来源中已消失的 Key 的处理方式
+const (
+	KeySourceMissingKeep    = "keep"
+	KeySourceMissingDisable = "disable"
+	KeySourceMissingRemove  = "remove"
+)
+
+// KeySource 对应 key_sources 表，分组的 Key 会定期从该来源同步
+type KeySource struct {
+	ID              uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	GroupID         uint              `gorm:"not null;index" json:"group_id"`
+	Name            string            `gorm:"type:varchar(100);not null" json:"name"`
+	Type            string            `gorm:"type:varchar(20);not null" json:"type"`
+	Address         string            `gorm:"type:varchar(1024);not null" json:"address"` // URL, Vault address or directory path
+	Path            string            `gorm:"type:varchar(512);default:''" json:"path"`   // Vault secret path, such as secret/data/openai
+	Field           string            `gorm:"type:varchar(100);default:''" json:"field"`  // Vault secret field holding the keys, all fields when empty
+	Token           string            `gorm:"type:text" json:"-"`                         // Encrypted Vault token or URL bearer token
+	HasToken        bool              `gorm:"-" json:"has_token"`
+	Format          string            `gorm:"type:varchar(10);not null;default:'auto'" json:"format"` // auto, txt, csv or json
+	IntervalMinutes int               `gorm:"not null;default:0" json:"interval_minutes"`             // 0 means manual sync only
+	MissingAction   string            `gorm:"type:varchar(20);not null;default:'keep'" json:"missing_action"`
+	Enabled         bool              `gorm:"not null;default:true" json:"enabled"`
+	LastSyncAt      *time.Time        `json:"last_sync_at"`
+	LastSyncError   string            `gorm:"type:text" json:"last_sync_error"`
+	LastSyncResult  datatypes.JSONMap `gorm:"type:json" json:"last_sync_result"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
 }
 
 // StatCard 用于仪表盘的单个统计卡片数据
@@ -177,6 +332,7 @@ type DashboardStatsResponse struct {
 	RPM              StatCard          `json:"rpm"`
 	RequestCount     StatCard          `json:"request_count"`
 	ErrorRate        StatCard          `json:"error_rate"`
+	Cost             StatCard          `json:"cost"`
 	SecurityWarnings []SecurityWarning `json:"security_warnings"`
 }
 
@@ -193,6 +349,19 @@ type ChartData struct {
 	Datasets []ChartDataset `json:"datasets"`
 }
 
+// CostChartDataset 用于费用图表的数据集
+type CostChartDataset struct {
+	Label string    `json:"label"`
+	Data  []float64 `json:"data"`
+	Color string    `json:"color"`
+}
+
+// CostChartData 用于费用图表的API响应
+type CostChartData struct {
+	Labels   []string           `json:"labels"`
+	Datasets []CostChartDataset `json:"datasets"`
+}
+
 // GroupHourlyStat 对应 group_hourly_stats 表，用于存储每个分组每小时的请求统计
 type GroupHourlyStat struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -200,6 +369,138 @@ type GroupHourlyStat struct {
 	GroupID      uint      `gorm:"not null;uniqueIndex:idx_group_time" json:"group_id"`
 	SuccessCount int64     `gorm:"not null;default:0" json:"success_count"`
 	FailureCount int64     `gorm:"not null;default:0" json:"failure_count"`
+	InputTokens  int64     `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens int64     `gorm:"not null;default:0" json:"output_tokens"`
+	Cost         float64   `gorm:"not null;default:0" json:"cost"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// KeyHourlyStat 对应 key_hourly_stats 表，用于存储每个密钥每小时的请求统计
+// Retry attempts are included, since each attempt is a request made with the key.
+type KeyHourlyStat struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"-"`
+	Time           time.Time  `gorm:"not null;uniqueIndex:idx_key_time" json:"time"` // 整点时间
+	KeyID          uint       `gorm:"not null;uniqueIndex:idx_key_time;index" json:"key_id"`
+	GroupID        uint       `gorm:"not null;index" json:"group_id"`
+	SuccessCount   int64      `gorm:"not null;default:0" json:"success_count"`
+	FailureCount   int64      `gorm:"not null;default:0" json:"failure_count"`
+	InputTokens    int64      `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens   int64      `gorm:"not null;default:0" json:"output_tokens"`
+	Cost           float64    `gorm:"not null;default:0" json:"cost"`
+	LastStatusCode int        `gorm:"not null;default:0" json:"last_status_code"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	LastErrorAt    *time.Time `json:"last_error_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// 管理员角色，权限依次递减
+const (
+	AdminRoleOwner    = "owner"
+	AdminRoleOperator = "operator"
+	AdminRoleViewer   = "viewer"
+)
+
+// AdminUser 对应 admin_users 表
+// SSO 账号在首次登录时创建，以 OIDCIssuer 和 OIDCSubject 标识，不设密码，角色在每次登录时按身份提供方同步
+type AdminUser struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username     string     `gorm:"type:varchar(100);not null;unique" json:"username"`
+	PasswordHash string     `gorm:"type:varchar(255);not null" json:"-"`
+	Role         string     `gorm:"type:varchar(20);not null" json:"role"`
+	Enabled      bool       `gorm:"not null" json:"enabled"`
+	OIDCIssuer   string     `gorm:"column:oidc_issuer;type:varchar(255);not null;default:'';index:idx_admin_users_oidc" json:"oidc_issuer,omitempty"`
+	OIDCSubject  string     `gorm:"column:oidc_subject;type:varchar(255);not null;default:'';index:idx_admin_users_oidc" json:"oidc_subject,omitempty"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// AdminToken 对应 admin_tokens 表，用于自动化调用管理接口，只保存令牌哈希
+type AdminToken struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"` // 0 表示由 AUTH_KEY 创建
+	Name        string     `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash   string     `gorm:"type:varchar(128);not null;uniqueIndex" json:"-"`
+	TokenPrefix string     `gorm:"type:varchar(20)" json:"token_prefix"`
+	Role        string     `gorm:"type:varchar(20);not null" json:"role"` // 令牌权限范围，不超过所属用户的角色
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AdminPrincipal 当前请求的管理端身份
+type AdminPrincipal struct {
+	UserID     uint   `json:"user_id"` // 0 仅表示 AUTH_KEY 对应的 root
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	AuthMethod string `json:"auth_method"` // "auth_key", "session", "oidc" 或 "token"
+	TokenID    uint   `json:"token_id,omitempty"`
+}
+
+// AdminRoleRank 返回角色的权限等级，未知角色为 0
+func AdminRoleRank(role string) int {
+	switch role {
+	case AdminRoleOwner:
+		return 3
+	case AdminRoleOperator:
+		return 2
+	case AdminRoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// HasRole 判断身份是否具备指定角色的权限
+func (p *AdminPrincipal) HasRole(role string) bool {
+	return p != nil && AdminRoleRank(p.Role) >= AdminRoleRank(role) && AdminRoleRank(role) > 0
+}
+
+// 审计日志目标类型
+const (
+	AuditTargetGroup    = "group"
+	AuditTargetKey      = "key"
+	AuditTargetSettings = "settings"
+	AuditTargetAdmin    = "admin"
+	AuditTargetProxyKey = "proxy_key"
+	AuditTargetTask     = "task"
+)
+
+// AuditLog 对应 audit_logs 表，记录管理端的每次变更操作
+type AuditLog struct {
+	ID         uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp  time.Time      `gorm:"not null;index" json:"timestamp"`
+	Actor      string         `gorm:"type:varchar(100);index" json:"actor"`
+	ActorRole  string         `gorm:"type:varchar(20)" json:"actor_role"`
+	AuthMethod string         `gorm:"type:varchar(20)" json:"auth_method"`
+	Action     string         `gorm:"type:varchar(64);not null;index" json:"action"`
+	TargetType string         `gorm:"type:varchar(32);not null;index" json:"target_type"`
+	TargetID   string         `gorm:"type:varchar(64);index" json:"target_id"`
+	TargetName string         `gorm:"type:varchar(255)" json:"target_name"`
+	SourceIP   string         `gorm:"type:varchar(64)" json:"source_ip"`
+	Changes    datatypes.JSON `gorm:"type:json" json:"changes"` // 字段名 -> AuditChange
+}
+
+// AuditChange 审计日志中单个字段的变更前后值
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// ProxyKey 对应 proxy_keys 表，只保存代理密钥的加盐哈希，明文仅在创建时返回一次
+type ProxyKey struct {
+	ID                   uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	GroupID              uint       `gorm:"not null;uniqueIndex:idx_proxy_key_group_hash" json:"group_id"` // 0 表示全局代理密钥
+	Name                 string     `gorm:"type:varchar(100)" json:"name"`
+	KeyHash              string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_proxy_key_group_hash" json:"-"`
+	KeyPreview           string     `gorm:"type:varchar(32)" json:"key_preview"`
+	AllowedIPs           string     `gorm:"type:text" json:"allowed_ips"` // 逗号分隔的 IP/CIDR，空表示不限制
+	DeniedIPs            string     `gorm:"type:text" json:"denied_ips"`
+	RateLimitRPM         int        `gorm:"not null;default:0" json:"rate_limit_rpm"` // 0 表示不限制
+	RateLimitTPM         int        `gorm:"not null;default:0" json:"rate_limit_tpm"`
+	RateLimitConcurrency int        `gorm:"not null;default:0" json:"rate_limit_concurrency"`
+	ExpiresAt            *time.Time `gorm:"index" json:"expires_at"` // 轮换后的旧密钥在宽限期结束时失效
+	CreatedAt            time.Time  `json:"created_at"`
+}
@@ -4,14 +4,42 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/middleware"
 	"gpt-load/internal/models"
+	"gpt-load/internal/services"
 	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+// extractAggregateModel extracts the requested model for an aggregate group using the channel of its sub-groups.
+// Returns an empty string for standard groups or when the model cannot be determined.
+func (ps *ProxyServer) extractAggregateModel(c *gin.Context, group *models.Group, bodyBytes []byte) string {
+	if group.GroupType != "aggregate" {
+		return ""
+	}
+
+	// All sub-groups share the same channel type, so any resolvable sub-group can parse the request
+	for _, sg := range group.SubGroups {
+		subGroup, err := ps.groupManager.GetGroupByName(sg.SubGroupName)
+		if err != nil {
+			continue
+		}
+		channelHandler, err := ps.channelFactory.GetChannel(subGroup)
+		if err != nil {
+			continue
+		}
+		return channelHandler.ExtractModel(c, bodyBytes)
+	}
+
+	return ""
+}
+
 func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group) ([]byte, error) {
 	if len(group.ParamOverrides) == 0 || len(bodyBytes) == 0 {
 		return bodyBytes, nil
@@ -30,6 +58,32 @@ func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group
 	return json.Marshal(requestData)
 }
 
+// groupStateError returns the error served to new requests while the group is not enabled.
+// The group's own message and status replace the defaults when set.
+func groupStateError(group *models.Group) *app_errors.APIError {
+	if group.IsEnabled() {
+		return nil
+	}
+
+	message := group.StateMessage
+	if message == "" {
+		switch group.State {
+		case models.GroupStateDraining:
+			message = fmt.Sprintf("Group '%s' is draining and not accepting new requests", group.Name)
+		case models.GroupStateMaintenance:
+			message = fmt.Sprintf("Group '%s' is under maintenance", group.Name)
+		default:
+			message = fmt.Sprintf("Group '%s' is disabled", group.Name)
+		}
+	}
+
+	apiErr := app_errors.NewAPIError(app_errors.ErrGroupUnavailable, message)
+	if group.StateStatusCode != 0 {
+		apiErr.HTTPStatus = group.StateStatusCode
+	}
+	return apiErr
+}
+
 // logUpstreamError provides a centralized way to log errors from upstream interactions.
 func logUpstreamError(context string, err error) {
 	if err == nil {
@@ -61,3 +115,72 @@ func handleGzipCompression(resp *http.Response, bodyBytes []byte) []byte {
 	}
 	return bodyBytes
 }
+
+// rateLimitLeaseKey is the context key of the *services.RateLimitLease held by a proxy request.
+const rateLimitLeaseKey = "rateLimitLease"
+
+// budgetReservationsKey is the context key of the []*services.BudgetReservation held by a proxy request.
+const budgetReservationsKey = "budgetReservations"
+
+// proxyKeyMatch returns the proxy key that authenticated the request, if any.
+func proxyKeyMatch(c *gin.Context) *services.ProxyKeyMatch {
+	if value, ok := c.Get(middleware.ProxyKeyMatchKey); ok {
+		if match, ok := value.(*services.ProxyKeyMatch); ok {
+			return match
+		}
+	}
+	return nil
+}
+
+// budgetReservations returns the budget reservations of the request, the aggregate group's first.
+func budgetReservations(c *gin.Context) []*services.BudgetReservation {
+	if value, ok := c.Get(budgetReservationsKey); ok {
+		if reservations, ok := value.([]*services.BudgetReservation); ok {
+			return reservations
+		}
+	}
+	return nil
+}
+
+// addBudgetReservation keeps a budget reservation on the request, so it is settled when the request is logged.
+func addBudgetReservation(c *gin.Context, reservation *services.BudgetReservation) {
+	if reservation == nil {
+		return
+	}
+	c.Set(budgetReservationsKey, append(budgetReservations(c), reservation))
+}
+
+// rateLimitLease returns the rate limit lease of the request, or nil outside HandleProxy.
+func rateLimitLease(c *gin.Context) *services.RateLimitLease {
+	if value, ok := c.Get(rateLimitLeaseKey); ok {
+		if lease, ok := value.(*services.RateLimitLease); ok {
+			return lease
+		}
+	}
+	return nil
+}
+
+// setRateLimitHeaders reports the most restrictive request and token limits of the client.
+func setRateLimitHeaders(c *gin.Context, status services.RateLimitStatus) {
+	if status.RequestLimit > 0 {
+		c.Header("X-RateLimit-Limit-Requests", strconv.Itoa(status.RequestLimit))
+		c.Header("X-RateLimit-Remaining-Requests", strconv.Itoa(status.RequestRemaining))
+		c.Header("X-RateLimit-Reset-Requests", strconv.Itoa(status.RequestReset))
+	}
+	if status.TokenLimit > 0 {
+		c.Header("X-RateLimit-Limit-Tokens", strconv.Itoa(status.TokenLimit))
+		c.Header("X-RateLimit-Remaining-Tokens", strconv.Itoa(status.TokenRemaining))
+		c.Header("X-RateLimit-Reset-Tokens", strconv.Itoa(status.TokenReset))
+	}
+}
+
+// queueClient identifies the client of a request for fair scheduling in request queues.
+func queueClient(c *gin.Context) string {
+	if match := proxyKeyMatch(c); match != nil {
+		if match.ID != 0 {
+			return "proxy_key:" + strconv.FormatUint(uint64(match.ID), 10)
+		}
+		return "proxy_key:" + match.Preview
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -4,11 +4,13 @@ import (
 	"io"
 	"net/http"
 
+	"gpt-load/internal/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Response) {
+func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Response) *models.TokenUsage {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -17,8 +19,13 @@ func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Respon
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
 		logrus.Error("Streaming unsupported by the writer, falling back to normal response")
-		ps.handleNormalResponse(c, resp)
-		return
+		return ps.handleNormalResponse(c, resp)
+	}
+
+	// Compressed streams cannot be parsed chunk by chunk, so usage is only collected for plain streams
+	var collector *usageCollector
+	if resp.Header.Get("Content-Encoding") == "" {
+		collector = &usageCollector{}
 	}
 
 	buf := make([]byte, 4*1024)
@@ -27,22 +34,41 @@ func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Respon
 		if n > 0 {
 			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
 				logUpstreamError("writing stream to client", writeErr)
-				return
+				return nil
 			}
 			flusher.Flush()
+			if collector != nil {
+				collector.FeedStream(buf[:n])
+			}
 		}
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
 			logUpstreamError("reading from upstream", err)
-			return
+			return nil
 		}
 	}
+
+	if collector == nil {
+		return nil
+	}
+	collector.FlushStream()
+	return collector.Usage()
 }
 
-func (ps *ProxyServer) handleNormalResponse(c *gin.Context, resp *http.Response) {
-	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+func (ps *ProxyServer) handleNormalResponse(c *gin.Context, resp *http.Response) *models.TokenUsage {
+	capture := &limitedBuffer{limit: maxUsageCaptureBytes}
+	if _, err := io.Copy(io.MultiWriter(c.Writer, capture), resp.Body); err != nil {
 		logUpstreamError("copying response body", err)
+		return nil
+	}
+
+	if capture.truncated {
+		return nil
 	}
+
+	collector := &usageCollector{}
+	collector.ParseBody(handleGzipCompression(resp, capture.buf.Bytes()))
+	return collector.Usage()
 }
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"gpt-load/internal/channel"
@@ -33,6 +34,10 @@ type ProxyServer struct {
 	settingsManager   *config.SystemSettingsManager
 	channelFactory    *channel.Factory
 	requestLogService *services.RequestLogService
+	modelPriceService *services.ModelPriceService
+	budgetService     *services.BudgetService
+	rateLimitService  *services.RateLimitService
+	requestQueue      *services.RequestQueueService
 	encryptionSvc     encryption.Service
 }
 
@@ -44,6 +49,10 @@ func NewProxyServer(
 	settingsManager *config.SystemSettingsManager,
 	channelFactory *channel.Factory,
 	requestLogService *services.RequestLogService,
+	modelPriceService *services.ModelPriceService,
+	budgetService *services.BudgetService,
+	rateLimitService *services.RateLimitService,
+	requestQueue *services.RequestQueueService,
 	encryptionSvc encryption.Service,
 ) (*ProxyServer, error) {
 	return &ProxyServer{
@@ -53,6 +62,10 @@ func NewProxyServer(
 		settingsManager:   settingsManager,
 		channelFactory:    channelFactory,
 		requestLogService: requestLogService,
+		modelPriceService: modelPriceService,
+		budgetService:     budgetService,
+		rateLimitService:  rateLimitService,
+		requestQueue:      requestQueue,
 		encryptionSvc:     encryptionSvc,
 	}, nil
 }
@@ -68,13 +81,68 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 		return
 	}
 
+	// Only new requests are turned away, requests already in flight are not affected by state changes
+	if apiErr := groupStateError(originalGroup); apiErr != nil {
+		response.Error(c, apiErr)
+		return
+	}
+
+	reservation, apiErr := ps.budgetService.ReserveBudget(originalGroup)
+	if apiErr != nil {
+		response.Error(c, apiErr)
+		return
+	}
+	addBudgetReservation(c, reservation)
+	// Reservations not settled by a logged request are given back
+	defer func() {
+		for _, reservation := range budgetReservations(c) {
+			reservation.Release()
+		}
+	}()
+
+	keyMatch := proxyKeyMatch(c)
+	lease, limitErr := ps.rateLimitService.Acquire(originalGroup, keyMatch)
+	if limitErr != nil && limitErr.Limit == "concurrency" && ps.requestQueue.Enabled(originalGroup) {
+		// Wait for a slot; other limits end the wait, as they are not freed by finishing requests
+		ps.requestQueue.Wait(c.Request.Context(), originalGroup, queueClient(c), services.QueueReasonConcurrency, func() bool {
+			lease, limitErr = ps.rateLimitService.Acquire(originalGroup, keyMatch)
+			return limitErr == nil || limitErr.Limit != "concurrency"
+		})
+	}
+	if limitErr != nil {
+		setRateLimitHeaders(c, limitErr.Status)
+		c.Header("Retry-After", strconv.Itoa(limitErr.RetryAfter))
+		response.Error(c, limitErr.APIError())
+		return
+	}
+	defer func() {
+		lease.Release()
+		ps.requestQueue.Notify(originalGroup.ID)
+	}()
+	c.Set(rateLimitLeaseKey, lease)
+	setRateLimitHeaders(c, lease.Status())
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logrus.Errorf("Failed to read request body: %v", err)
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+		return
+	}
+	c.Request.Body.Close()
+
 	// Select sub-group if this is an aggregate group
-	subGroupName, err := ps.subGroupManager.SelectSubGroup(originalGroup)
+	requestedModel := ps.extractAggregateModel(c, originalGroup, bodyBytes)
+	subGroupName, err := ps.subGroupManager.SelectSubGroup(originalGroup, requestedModel)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"aggregate_group": originalGroup.Name,
+			"model":           requestedModel,
 			"error":           err,
 		}).Error("Failed to select sub-group from aggregate")
+		if errors.Is(err, services.ErrNoSubGroupForModel) {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrModelNotSupported, fmt.Sprintf("Model '%s' is not supported by group '%s'", requestedModel, originalGroup.Name)))
+			return
+		}
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, "No available sub-groups"))
 		return
 	}
@@ -86,6 +154,16 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 			response.Error(c, app_errors.ParseDBError(err))
 			return
 		}
+		if apiErr := groupStateError(group); apiErr != nil {
+			response.Error(c, apiErr)
+			return
+		}
+		subReservation, apiErr := ps.budgetService.ReserveBudget(group)
+		if apiErr != nil {
+			response.Error(c, apiErr)
+			return
+		}
+		addBudgetReservation(c, subReservation)
 	}
 
 	channelHandler, err := ps.channelFactory.GetChannel(group)
@@ -94,14 +172,6 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		logrus.Errorf("Failed to read request body: %v", err)
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
-		return
-	}
-	c.Request.Body.Close()
-
 	finalBodyBytes, err := ps.applyParamOverrides(bodyBytes, group)
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to apply parameter overrides: %v", err)))
@@ -127,10 +197,19 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	cfg := group.EffectiveConfig
 
 	apiKey, err := ps.keyProvider.SelectKey(group.ID)
+	if errors.Is(err, app_errors.ErrNoActiveKeys) && ps.requestQueue.Enabled(group) {
+		queueErr := ps.requestQueue.Wait(c.Request.Context(), group, queueClient(c), services.QueueReasonNoKeys, func() bool {
+			apiKey, err = ps.keyProvider.SelectKey(group.ID)
+			return !errors.Is(err, app_errors.ErrNoActiveKeys)
+		})
+		if queueErr != nil && err != nil {
+			err = fmt.Errorf("%w: %v", err, queueErr)
+		}
+	}
 	if err != nil {
 		logrus.Errorf("Failed to select a key for group %s on attempt %d: %v", group.Name, retryCount+1, err)
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error()))
-		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusServiceUnavailable, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal)
+		ps.logRequest(c, originalGroup, group, nil, startTime, http.StatusServiceUnavailable, err, isStream, "", channelHandler, bodyBytes, models.RequestTypeFinal, nil)
 		return
 	}
 
@@ -169,7 +248,7 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	finalBodyBytes, err := channelHandler.ApplyModelRedirect(req, bodyBytes, group)
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
-		ps.logRequest(c, originalGroup, group, apiKey, startTime, http.StatusBadRequest, err, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal)
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, http.StatusBadRequest, err, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal, nil)
 		return
 	}
 
@@ -195,6 +274,7 @@ func (ps *ProxyServer) executeRequestWithRetry(
 		client = channelHandler.GetHTTPClient()
 	}
 
+	attemptStart := time.Now()
 	resp, err := client.Do(req)
 	if resp != nil {
 		defer resp.Body.Close()
@@ -204,7 +284,7 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	if err != nil || (resp != nil && resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound) {
 		if err != nil && app_errors.IsIgnorableError(err) {
 			logrus.Debugf("Client-side ignorable error for key %s, aborting retries: %v", utils.MaskAPIKey(apiKey.KeyValue), err)
-			ps.logRequest(c, originalGroup, group, apiKey, startTime, 499, err, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal)
+			ps.logRequest(c, originalGroup, group, apiKey, startTime, 499, err, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal, nil)
 			return
 		}
 
@@ -234,6 +314,11 @@ func (ps *ProxyServer) executeRequestWithRetry(
 
 		// 使用解析后的错误信息更新密钥状态
 		ps.keyProvider.UpdateStatus(apiKey, group, false, parsedError)
+		// Only transport errors, 5xx and 429 count against the sub-group's health. Other 4xx
+		// responses are caused by the request itself and say nothing about the provider.
+		if statusCode >= 500 || statusCode == http.StatusTooManyRequests {
+			ps.subGroupManager.RecordResult(originalGroup, group.ID, false, time.Since(attemptStart))
+		}
 
 		// 判断是否为最后一次尝试
 		isLastAttempt := retryCount >= cfg.MaxRetries
@@ -242,7 +327,7 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			requestType = models.RequestTypeFinal
 		}
 
-		ps.logRequest(c, originalGroup, group, apiKey, startTime, statusCode, errors.New(parsedError), isStream, upstreamURL, channelHandler, bodyBytes, requestType)
+		ps.logRequest(c, originalGroup, group, apiKey, startTime, statusCode, errors.New(parsedError), isStream, upstreamURL, channelHandler, bodyBytes, requestType, nil)
 
 		// 如果是最后一次尝试，直接返回错误，不再递归
 		if isLastAttempt {
@@ -261,8 +346,10 @@ func (ps *ProxyServer) executeRequestWithRetry(
 
 	// ps.keyProvider.UpdateStatus(apiKey, group, true) // 请求成功不再重置成功次数，减少IO消耗
 	logrus.Debugf("Request for group %s succeeded on attempt %d with key %s", group.Name, retryCount+1, utils.MaskAPIKey(apiKey.KeyValue))
+	ps.subGroupManager.RecordResult(originalGroup, group.ID, true, time.Since(attemptStart))
 
 	// Check if this is a model list request (needs special handling)
+	var usage *models.TokenUsage
 	if shouldInterceptModelList(c.Request.URL.Path, c.Request.Method) {
 		ps.handleModelListResponse(c, resp, group, channelHandler)
 	} else {
@@ -271,16 +358,18 @@ func (ps *ProxyServer) executeRequestWithRetry(
 				c.Header(key, value)
 			}
 		}
+		// The client sees its own limits rather than those of the upstream key
+		setRateLimitHeaders(c, rateLimitLease(c).Status())
 		c.Status(resp.StatusCode)
 
 		if isStream {
-			ps.handleStreamingResponse(c, resp)
+			usage = ps.handleStreamingResponse(c, resp)
 		} else {
-			ps.handleNormalResponse(c, resp)
+			usage = ps.handleNormalResponse(c, resp)
 		}
 	}
 
-	ps.logRequest(c, originalGroup, group, apiKey, startTime, resp.StatusCode, nil, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal)
+	ps.logRequest(c, originalGroup, group, apiKey, startTime, resp.StatusCode, nil, isStream, upstreamURL, channelHandler, bodyBytes, models.RequestTypeFinal, usage)
 }
 
 // logRequest is a helper function to create and record a request log.
@@ -297,6 +386,7 @@ func (ps *ProxyServer) logRequest(
 	channelHandler channel.ChannelProxy,
 	bodyBytes []byte,
 	requestType string,
+	usage *models.TokenUsage,
 ) {
 	if ps.requestLogService == nil {
 		return
@@ -336,6 +426,23 @@ func (ps *ProxyServer) logRequest(
 		logEntry.Model = channelHandler.ExtractModel(c, bodyBytes)
 	}
 
+	if usage != nil {
+		logEntry.InputTokens = usage.InputTokens
+		logEntry.OutputTokens = usage.OutputTokens
+		logEntry.CachedInputTokens = usage.CachedInputTokens
+		logEntry.Cost = ps.modelPriceService.CalculateCost(group.ChannelType, logEntry.Model, *usage)
+	}
+
+	// Budget and rate limit counters follow the hourly stats and ignore intermediate retry attempts
+	if requestType != models.RequestTypeRetry {
+		if usage != nil {
+			rateLimitLease(c).RecordTokens(usage.InputTokens + usage.OutputTokens)
+		}
+		for _, reservation := range budgetReservations(c) {
+			reservation.Settle(usage, logEntry.Cost)
+		}
+	}
+
 	if apiKey != nil {
 		// 加密密钥值用于日志存储
 		encryptedKeyValue, err := ps.encryptionSvc.Encrypt(apiKey.KeyValue)
@@ -347,6 +454,7 @@ func (ps *ProxyServer) logRequest(
 		}
 		// 添加 KeyHash 用于反查
 		logEntry.KeyHash = ps.encryptionSvc.Hash(apiKey.KeyValue)
+		logEntry.KeyID = apiKey.ID
 	}
 
 	if finalError != nil {
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gpt-load/internal/models"
+)
+
+const (
+	// maxUsageCaptureBytes limits how much of a non-stream response body is buffered for usage parsing
+	maxUsageCaptureBytes = 8 * 1024 * 1024
+	// maxUsageLineBytes limits the size of a single pending SSE line
+	maxUsageLineBytes = 1024 * 1024
+)
+
+// usageFields covers the usage object of OpenAI chat, OpenAI responses and Anthropic messages.
+type usageFields struct {
+	PromptTokens             int64 `json:"prompt_tokens"`
+	CompletionTokens         int64 `json:"completion_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	PromptTokensDetails      *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	InputTokensDetails *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+// geminiUsageFields covers the usageMetadata object of Gemini responses.
+type geminiUsageFields struct {
+	PromptTokenCount        int64 `json:"promptTokenCount"`
+	CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+}
+
+// usagePayload lists every place a usage object can appear in a response or stream event.
+type usagePayload struct {
+	Usage         *usageFields       `json:"usage"`
+	UsageMetadata *geminiUsageFields `json:"usageMetadata"`
+	Message       *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"message"`
+	Response *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"response"`
+}
+
+// usageCollector accumulates token usage from upstream response bodies and SSE streams.
+type usageCollector struct {
+	usage   models.TokenUsage
+	found   bool
+	pending []byte
+}
+
+// Usage returns the collected usage, or nil when the response did not report any.
+func (u *usageCollector) Usage() *models.TokenUsage {
+	if !u.found {
+		return nil
+	}
+	usage := u.usage
+	return &usage
+}
+
+// FeedStream consumes a chunk of an SSE stream and parses every complete data line.
+func (u *usageCollector) FeedStream(chunk []byte) {
+	u.pending = append(u.pending, chunk...)
+	for {
+		idx := bytes.IndexByte(u.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		u.parseStreamLine(u.pending[:idx])
+		u.pending = u.pending[idx+1:]
+	}
+	if len(u.pending) > maxUsageLineBytes {
+		u.pending = nil
+	}
+}
+
+// FlushStream parses a trailing line that was not terminated by a newline.
+func (u *usageCollector) FlushStream() {
+	if len(u.pending) > 0 {
+		u.parseStreamLine(u.pending)
+		u.pending = nil
+	}
+}
+
+// ParseBody parses a complete non-stream response body.
+func (u *usageCollector) ParseBody(body []byte) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return
+	}
+
+	// Gemini streamGenerateContent without alt=sse returns a JSON array of responses
+	if body[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return
+		}
+		for _, item := range items {
+			u.parseJSON(item)
+		}
+		return
+	}
+
+	u.parseJSON(body)
+}
+
+func (u *usageCollector) parseStreamLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return
+	}
+	u.parseJSON(data)
+}
+
+func (u *usageCollector) parseJSON(data []byte) {
+	// Cheap pre-check to skip the majority of stream chunks that carry no usage
+	if !bytes.Contains(data, []byte("usage")) {
+		return
+	}
+
+	var payload usagePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return
+	}
+
+	if payload.Usage != nil {
+		u.merge(payload.Usage.toTokenUsage())
+	}
+	if payload.Message != nil && payload.Message.Usage != nil {
+		u.merge(payload.Message.Usage.toTokenUsage())
+	}
+	if payload.Response != nil && payload.Response.Usage != nil {
+		u.merge(payload.Response.Usage.toTokenUsage())
+	}
+	if payload.UsageMetadata != nil {
+		meta := payload.UsageMetadata
+		u.merge(models.TokenUsage{
+			InputTokens:       meta.PromptTokenCount,
+			OutputTokens:      meta.CandidatesTokenCount + meta.ThoughtsTokenCount,
+			CachedInputTokens: meta.CachedContentTokenCount,
+		})
+	}
+}
+
+// merge keeps the largest value of each counter, as streaming providers report cumulative usage.
+func (u *usageCollector) merge(usage models.TokenUsage) {
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return
+	}
+	u.found = true
+	u.usage.InputTokens = max(u.usage.InputTokens, usage.InputTokens)
+	u.usage.OutputTokens = max(u.usage.OutputTokens, usage.OutputTokens)
+	u.usage.CachedInputTokens = max(u.usage.CachedInputTokens, usage.CachedInputTokens)
+}
+
+// toTokenUsage normalizes provider specific fields so that InputTokens includes cached tokens.
+func (f *usageFields) toTokenUsage() models.TokenUsage {
+	// OpenAI chat completions
+	if f.PromptTokens > 0 || f.CompletionTokens > 0 {
+		usage := models.TokenUsage{
+			InputTokens:  f.PromptTokens,
+			OutputTokens: f.CompletionTokens,
+		}
+		if f.PromptTokensDetails != nil {
+			usage.CachedInputTokens = f.PromptTokensDetails.CachedTokens
+		}
+		return usage
+	}
+
+	// OpenAI responses API reports cached tokens inside input_tokens
+	if f.InputTokensDetails != nil {
+		return models.TokenUsage{
+			InputTokens:       f.InputTokens,
+			OutputTokens:      f.OutputTokens,
+			CachedInputTokens: f.InputTokensDetails.CachedTokens,
+		}
+	}
+
+	// Anthropic reports cache reads and writes separately from input_tokens
+	return models.TokenUsage{
+		InputTokens:       f.InputTokens + f.CacheReadInputTokens + f.CacheCreationInputTokens,
+		OutputTokens:      f.OutputTokens,
+		CachedInputTokens: f.CacheReadInputTokens,
+	}
+}
+
+// limitedBuffer captures written bytes up to a limit and silently drops the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if !b.truncated {
+		if b.buf.Len()+len(p) > b.limit {
+			b.truncated = true
+			b.buf.Reset()
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -5,6 +5,7 @@ import (
 	"gpt-load/internal/handler"
 	"gpt-load/internal/i18n"
 	"gpt-load/internal/middleware"
+	"gpt-load/internal/models"
 	"gpt-load/internal/proxy"
 	"gpt-load/internal/services"
 	"gpt-load/internal/types"
@@ -17,6 +18,7 @@ import (
 	"github.com/gin-contrib/static"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 type embedFileSystem struct {
@@ -50,6 +52,11 @@ func NewRouter(
 
 	router := gin.New()
 
+	// 仅信任来自这些代理的转发头，用于获取真实客户端 IP
+	if err := router.SetTrustedProxies(configManager.GetEffectiveServerConfig().TrustedProxies); err != nil {
+		logrus.WithError(err).Error("Failed to set trusted proxies")
+	}
+
 	// 注册全局中间件
 	router.Use(middleware.Recovery())
 	router.Use(middleware.ErrorHandler())
@@ -65,7 +72,7 @@ func NewRouter(
 
 	// 注册路由
 	registerSystemRoutes(router, serverHandler)
-	registerAPIRoutes(router, serverHandler, configManager)
+	registerAPIRoutes(router, serverHandler)
 	registerProxyRoutes(router, proxyServer, groupManager, serverHandler)
 	registerFrontendRoutes(router, buildFS, indexPage)
 
@@ -81,77 +88,123 @@ func registerSystemRoutes(router *gin.Engine, serverHandler *handler.Server) {
 func registerAPIRoutes(
 	router *gin.Engine,
 	serverHandler *handler.Server,
-	configManager types.ConfigManager,
 ) {
 	api := router.Group("/api")
 	api.Use(i18n.Middleware())
 
-	authConfig := configManager.GetAuthConfig()
+	// 客户端集成信息使用代理密钥鉴权，不受管理端 IP 规则限制，但与代理请求一样受代理 IP 规则和失败次数限制
+	api.GET("/integration/info",
+		middleware.IntegrationAuth(serverHandler.GroupService, serverHandler.GroupManager, serverHandler.ProxyKeyService, serverHandler.SettingsManager, serverHandler.AuthGuardService),
+		serverHandler.GetIntegrationInfo)
+
+	// 管理端 IP 访问控制
+	adminAPI := api.Group("")
+	adminAPI.Use(middleware.AdminIPFilter(serverHandler.SettingsManager))
 
 	// 公开
-	registerPublicAPIRoutes(api, serverHandler)
+	registerPublicAPIRoutes(adminAPI, serverHandler)
 
 	// 认证
-	protectedAPI := api.Group("")
-	protectedAPI.Use(middleware.Auth(authConfig))
+	protectedAPI := adminAPI.Group("")
+	protectedAPI.Use(middleware.Auth(serverHandler.AdminAuthService, serverHandler.AuthGuardService))
 	registerProtectedAPIRoutes(protectedAPI, serverHandler)
 }
 
 // registerPublicAPIRoutes 公开API路由
 func registerPublicAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server) {
 	api.POST("/auth/login", serverHandler.Login)
-	api.GET("/integration/info", serverHandler.GetIntegrationInfo)
+	api.GET("/auth/oidc/config", serverHandler.GetOIDCConfig)
+	api.GET("/auth/oidc/login", serverHandler.OIDCLogin)
+	api.GET("/auth/oidc/callback", serverHandler.OIDCCallback)
 }
 
 // registerProtectedAPIRoutes 认证API路由
+// 只读接口对所有角色开放，operator 可管理密钥，owner 可修改分组、设置和账号
 func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server) {
+	viewer := middleware.RequireRole(models.AdminRoleViewer)
+	operator := middleware.RequireRole(models.AdminRoleOperator)
+	owner := middleware.RequireRole(models.AdminRoleOwner)
+
 	api.GET("/channel-types", serverHandler.CommonHandler.GetChannelTypes)
 
+	// 当前会话
+	api.POST("/auth/logout", serverHandler.Logout)
+	api.GET("/auth/me", serverHandler.GetCurrentAdmin)
+
 	groups := api.Group("/groups")
 	{
-		groups.POST("", serverHandler.CreateGroup)
+		groups.POST("", owner, serverHandler.CreateGroup)
 		groups.GET("", serverHandler.ListGroups)
 		groups.GET("/list", serverHandler.List)
 		groups.GET("/config-options", serverHandler.GetGroupConfigOptions)
-		groups.PUT("/reorder", serverHandler.ReorderGroups)
-		groups.PUT("/:id", serverHandler.UpdateGroup)
-		groups.DELETE("/:id", serverHandler.DeleteGroup)
+		groups.PUT("/reorder", owner, serverHandler.ReorderGroups)
+		groups.PUT("/:id", owner, serverHandler.UpdateGroup)
+		groups.PUT("/:id/state", owner, serverHandler.UpdateGroupState)
+		groups.DELETE("/:id", owner, serverHandler.DeleteGroup)
 		groups.GET("/:id/stats", serverHandler.GetGroupStats)
-		groups.POST("/:id/copy", serverHandler.CopyGroup)
+		groups.GET("/:id/budgets", serverHandler.GetGroupBudgets)
+		groups.GET("/:id/queue", serverHandler.GetGroupQueue)
+		groups.POST("/:id/copy", owner, serverHandler.CopyGroup)
 
 		groups.GET("/:id/sub-groups", serverHandler.GetSubGroups)
-		groups.POST("/:id/sub-groups", serverHandler.AddSubGroups)
-		groups.PUT("/:id/sub-groups/:subGroupId/weight", serverHandler.UpdateSubGroupWeight)
-		groups.DELETE("/:id/sub-groups/:subGroupId", serverHandler.DeleteSubGroup)
+		groups.POST("/:id/sub-groups", owner, serverHandler.AddSubGroups)
+		groups.PUT("/:id/sub-groups/:subGroupId/weight", owner, serverHandler.UpdateSubGroupWeight)
+		groups.PUT("/:id/sub-groups/:subGroupId/priority", owner, serverHandler.UpdateSubGroupPriority)
+		groups.PUT("/:id/sub-groups/:subGroupId/models", owner, serverHandler.UpdateSubGroupModels)
+		groups.PUT("/:id/sub-groups/:subGroupId/schedule", owner, serverHandler.UpdateSubGroupSchedule)
+		groups.DELETE("/:id/sub-groups/:subGroupId", owner, serverHandler.DeleteSubGroup)
 		groups.GET("/:id/parent-aggregate-groups", serverHandler.GetParentAggregateGroups)
+
+		groups.GET("/:id/key-sources", serverHandler.ListKeySources)
+		groups.POST("/:id/key-sources", operator, serverHandler.CreateKeySource)
+	}
+
+	keySources := api.Group("/key-sources")
+	{
+		keySources.PUT("/:id", operator, serverHandler.UpdateKeySource)
+		keySources.DELETE("/:id", operator, serverHandler.DeleteKeySource)
+		keySources.POST("/:id/sync", operator, serverHandler.SyncKeySource)
 	}
 
 	// Key Management Routes
 	keys := api.Group("/keys")
 	{
 		keys.GET("", serverHandler.ListKeysInGroup)
-		keys.GET("/export", serverHandler.ExportKeys)
-		keys.POST("/add-multiple", serverHandler.AddMultipleKeys)
-		keys.POST("/add-async", serverHandler.AddMultipleKeysAsync)
-		keys.POST("/delete-multiple", serverHandler.DeleteMultipleKeys)
-		keys.POST("/delete-async", serverHandler.DeleteMultipleKeysAsync)
-		keys.POST("/restore-multiple", serverHandler.RestoreMultipleKeys)
-		keys.POST("/restore-all-invalid", serverHandler.RestoreAllInvalidKeys)
-		keys.POST("/clear-all-invalid", serverHandler.ClearAllInvalidKeys)
-		keys.POST("/clear-all", serverHandler.ClearAllKeys)
-		keys.POST("/validate-group", serverHandler.ValidateGroupKeys)
-		keys.POST("/test-multiple", serverHandler.TestMultipleKeys)
-		keys.PUT("/:id/notes", serverHandler.UpdateKeyNotes)
+		keys.GET("/export", operator, serverHandler.ExportKeys)
+		keys.GET("/duplicates", serverHandler.ListDuplicateKeys)
+		keys.GET("/:id", serverHandler.GetKeyDetails)
+		keys.POST("/add-multiple", operator, serverHandler.AddMultipleKeys)
+		keys.POST("/add-async", operator, serverHandler.AddMultipleKeysAsync)
+		keys.POST("/import-url", operator, serverHandler.ImportKeysFromURL)
+		keys.POST("/delete-multiple", operator, serverHandler.DeleteMultipleKeys)
+		keys.POST("/delete-async", operator, serverHandler.DeleteMultipleKeysAsync)
+		keys.POST("/restore-multiple", operator, serverHandler.RestoreMultipleKeys)
+		keys.POST("/restore-all-invalid", operator, serverHandler.RestoreAllInvalidKeys)
+		keys.POST("/clear-all-invalid", operator, serverHandler.ClearAllInvalidKeys)
+		keys.POST("/clear-all", operator, serverHandler.ClearAllKeys)
+		keys.POST("/validate-group", operator, serverHandler.ValidateGroupKeys)
+		keys.POST("/test-multiple", operator, serverHandler.TestMultipleKeys)
+		keys.POST("/transfer", operator, serverHandler.TransferKeys)
+		keys.PUT("/tags", operator, serverHandler.UpdateKeyTags)
+		keys.PUT("/:id/notes", operator, serverHandler.UpdateKeyNotes)
+		keys.PUT("/:id/metadata", operator, serverHandler.UpdateKeyMetadata)
 	}
 
 	// Tasks
-	api.GET("/tasks/status", serverHandler.GetTaskStatus)
+	tasks := api.Group("/tasks")
+	{
+		tasks.GET("", serverHandler.ListTasks)
+		tasks.GET("/status", serverHandler.GetTaskStatus)
+		tasks.GET("/:id", serverHandler.GetTask)
+		tasks.POST("/:id/cancel", operator, serverHandler.CancelTask)
+	}
 
 	// 仪表板和日志
 	dashboard := api.Group("/dashboard")
 	{
 		dashboard.GET("/stats", serverHandler.Stats)
 		dashboard.GET("/chart", serverHandler.Chart)
+		dashboard.GET("/cost-chart", serverHandler.CostChart)
 		dashboard.GET("/encryption-status", serverHandler.EncryptionStatus)
 	}
 
@@ -159,14 +212,80 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 	logs := api.Group("/logs")
 	{
 		logs.GET("", serverHandler.GetLogs)
-		logs.GET("/export", serverHandler.ExportLogs)
+		logs.GET("/export", operator, serverHandler.ExportLogs)
+	}
+
+	// 模型价格
+	modelPrices := api.Group("/model-prices")
+	{
+		modelPrices.GET("", serverHandler.ListModelPrices)
+		modelPrices.POST("", owner, serverHandler.CreateModelPrice)
+		modelPrices.PUT("/:id", owner, serverHandler.UpdateModelPrice)
+		modelPrices.DELETE("/:id", owner, serverHandler.DeleteModelPrice)
+	}
+
+	// 代理密钥，只保存哈希，完整密钥仅在创建或轮换时返回一次
+	proxyKeys := api.Group("/proxy-keys")
+	{
+		proxyKeys.GET("", serverHandler.ListProxyKeys)
+		proxyKeys.POST("", owner, serverHandler.CreateProxyKey)
+		proxyKeys.PUT("/:id", owner, serverHandler.UpdateProxyKey)
+		proxyKeys.POST("/:id/rotate", owner, serverHandler.RotateProxyKey)
+		proxyKeys.DELETE("/:id", owner, serverHandler.DeleteProxyKey)
+	}
+
+	// 信封加密，主密钥轮换后重新包装数据密钥
+	encryptionRoutes := api.Group("/encryption", owner)
+	{
+		encryptionRoutes.GET("/rewrap", serverHandler.GetKeyRewrapStatus)
+		encryptionRoutes.POST("/rewrap", serverHandler.StartKeyRewrap)
+		encryptionRoutes.GET("/key-rotation", serverHandler.GetEncryptionKeyRotationStatus)
+		encryptionRoutes.POST("/key-rotation", serverHandler.StartEncryptionKeyRotation)
+	}
+
+	// 登录及代理鉴权的失败次数限制与临时封禁
+	authGuard := api.Group("/auth-guard", owner)
+	{
+		authGuard.GET("", serverHandler.GetAuthGuardStatus)
+		authGuard.DELETE("/bans", serverHandler.DeleteAuthBan)
+	}
+
+	// 审计日志
+	auditLogs := api.Group("/audit-logs", owner)
+	{
+		auditLogs.GET("", serverHandler.GetAuditLogs)
+		auditLogs.GET("/export", serverHandler.ExportAuditLogs)
 	}
 
 	// 设置
 	settings := api.Group("/settings")
 	{
 		settings.GET("", serverHandler.GetSettings)
-		settings.PUT("", serverHandler.UpdateSettings)
+		settings.PUT("", owner, serverHandler.UpdateSettings)
+	}
+
+	// 声明式配置导入导出
+	configRoutes := api.Group("/config", owner)
+	{
+		configRoutes.GET("/export", serverHandler.ExportConfig)
+		configRoutes.POST("/import", serverHandler.ImportConfig)
+	}
+
+	// 管理员账号
+	adminUsers := api.Group("/admin-users", owner)
+	{
+		adminUsers.GET("", serverHandler.ListAdminUsers)
+		adminUsers.POST("", serverHandler.CreateAdminUser)
+		adminUsers.PUT("/:id", serverHandler.UpdateAdminUser)
+		adminUsers.DELETE("/:id", serverHandler.DeleteAdminUser)
+	}
+
+	// API 令牌，需要具备角色的账号或 AUTH_KEY，非 owner 只能管理自己的令牌
+	adminTokens := api.Group("/admin-tokens", viewer)
+	{
+		adminTokens.GET("", serverHandler.ListAdminTokens)
+		adminTokens.POST("", serverHandler.CreateAdminToken)
+		adminTokens.DELETE("/:id", serverHandler.DeleteAdminToken)
 	}
 }
 
@@ -179,8 +298,8 @@ func registerProxyRoutes(
 ) {
 	proxyGroup := router.Group("/proxy/:group_name")
 
+	proxyGroup.Use(middleware.ProxyAuth(groupManager, serverHandler.ProxyKeyService, serverHandler.SettingsManager, serverHandler.AuthGuardService))
 	proxyGroup.Use(middleware.ProxyRouteDispatcher(serverHandler))
-	proxyGroup.Use(middleware.ProxyAuth(groupManager))
 
 	proxyGroup.Any("/*path", proxyServer.HandleProxy)
 }
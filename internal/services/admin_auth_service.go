@@ -0,0 +1,636 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// AdminSessionTTL is the lifetime of a web UI session
+	AdminSessionTTL = 24 * time.Hour
+
+	adminSessionPrefix   = "gls_"
+	adminTokenPrefix     = "glt_"
+	adminRootUsername    = "root"
+	minAdminPasswordLen  = 8
+	tokenLastUsedMinStep = time.Minute
+)
+
+var (
+	// ErrAdminUnauthorized is returned when a credential does not match any principal.
+	ErrAdminUnauthorized = errors.New("admin credential is invalid")
+	// ErrAdminUsernameTaken is returned when an SSO login's username belongs to another admin account.
+	ErrAdminUsernameTaken = errors.New("admin username belongs to another account")
+)
+
+// dummyPasswordHash is compared against on unknown usernames to keep login timing uniform.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("gpt-load-dummy-password"), bcrypt.DefaultCost)
+
+// adminSession is the session payload kept in the store.
+type adminSession struct {
+	UserID     uint   `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	AuthMethod string `json:"auth_method,omitempty"`
+}
+
+// AdminUserParams defines the fields for creating or updating an admin user.
+type AdminUserParams struct {
+	Username string  `json:"username"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+	Enabled  *bool   `json:"enabled"`
+}
+
+// AdminTokenParams defines the fields for creating an API token.
+type AdminTokenParams struct {
+	Name          string `json:"name"`
+	Role          string `json:"role"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+// AdminTokenCreated is returned once when a token is created, as only its hash is stored.
+type AdminTokenCreated struct {
+	models.AdminToken
+	Token string `json:"token"`
+}
+
+// LoginResult holds the session created by a successful login.
+type LoginResult struct {
+	Token     string                 `json:"token"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Principal *models.AdminPrincipal `json:"principal"`
+}
+
+// AdminAuthService authenticates admin requests and manages admin accounts, sessions and API tokens.
+// The AUTH_KEY remains valid as a built-in owner credential so that a deployment can never lock itself out.
+type AdminAuthService struct {
+	db            *gorm.DB
+	store         store.Store
+	configManager types.ConfigManager
+}
+
+// NewAdminAuthService creates a new AdminAuthService.
+func NewAdminAuthService(db *gorm.DB, store store.Store, configManager types.ConfigManager) *AdminAuthService {
+	return &AdminAuthService{
+		db:            db,
+		store:         store,
+		configManager: configManager,
+	}
+}
+
+// Authenticate resolves a bearer credential to a principal.
+// The credential can be the AUTH_KEY, a session token or an API token.
+func (s *AdminAuthService) Authenticate(ctx context.Context, credential string) (*models.AdminPrincipal, error) {
+	if credential == "" {
+		return nil, ErrAdminUnauthorized
+	}
+
+	if s.isAuthKey(credential) {
+		return rootPrincipal(), nil
+	}
+
+	switch {
+	case strings.HasPrefix(credential, adminSessionPrefix):
+		return s.authenticateSession(ctx, credential)
+	case strings.HasPrefix(credential, adminTokenPrefix):
+		return s.authenticateToken(ctx, credential)
+	default:
+		return nil, ErrAdminUnauthorized
+	}
+}
+
+// Login verifies either the AUTH_KEY or a username and password, and creates a session.
+func (s *AdminAuthService) Login(ctx context.Context, authKey, username, password string) (*LoginResult, error) {
+	var principal *models.AdminPrincipal
+
+	if authKey != "" {
+		if !s.isAuthKey(authKey) {
+			return nil, ErrAdminUnauthorized
+		}
+		principal = rootPrincipal()
+	} else {
+		var user models.AdminUser
+		err := s.db.WithContext(ctx).Where("username = ?", strings.TrimSpace(username)).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.ParseDBError(err)
+		}
+
+		hash := dummyPasswordHash
+		if err == nil {
+			hash = []byte(user.PasswordHash)
+		}
+		passwordErr := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if err != nil || passwordErr != nil || !user.Enabled {
+			return nil, ErrAdminUnauthorized
+		}
+
+		now := time.Now()
+		if err := s.db.WithContext(ctx).Model(&user).Update("last_login_at", &now).Error; err != nil {
+			logrus.WithError(err).Warn("Failed to update admin last login time")
+		}
+
+		principal = &models.AdminPrincipal{
+			UserID:   user.ID,
+			Username: user.Username,
+			Role:     user.Role,
+		}
+	}
+
+	principal.AuthMethod = "session"
+	return s.CreateSession(principal, AdminSessionTTL)
+}
+
+// LoginOIDCUser signs in the SSO account identified by issuer and subject and creates a session.
+// The account is created on the first login; its username and role follow the provider on every login,
+// while an owner can still disable it to end its sessions and tokens immediately.
+func (s *AdminAuthService) LoginOIDCUser(ctx context.Context, issuer, subject, username, role string, ttl time.Duration) (*LoginResult, error) {
+	if strings.EqualFold(username, adminRootUsername) {
+		return nil, ErrAdminUsernameTaken
+	}
+
+	db := s.db.WithContext(ctx)
+	var user models.AdminUser
+	err := db.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	taken, err := s.usernameTaken(ctx, username, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if user.ID == 0 {
+		if taken {
+			return nil, ErrAdminUsernameTaken
+		}
+		user = models.AdminUser{
+			Username:    username,
+			Role:        role,
+			Enabled:     true,
+			OIDCIssuer:  issuer,
+			OIDCSubject: subject,
+			LastLoginAt: &now,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			return nil, app_errors.ParseDBError(err)
+		}
+	} else {
+		if !user.Enabled {
+			return nil, ErrAdminUnauthorized
+		}
+		if taken {
+			logrus.WithFields(logrus.Fields{"username": username, "user_id": user.ID}).
+				Warn("OIDC username belongs to another admin account, keeping the previous username")
+		} else {
+			user.Username = username
+		}
+		user.Role = role
+		updates := map[string]any{"username": user.Username, "role": role, "last_login_at": &now}
+		if err := db.Model(&user).Updates(updates).Error; err != nil {
+			return nil, app_errors.ParseDBError(err)
+		}
+	}
+
+	return s.CreateSession(&models.AdminPrincipal{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		AuthMethod: "oidc",
+	}, ttl)
+}
+
+// CreateSession stores a new session for an authenticated principal.
+// Sessions re-read their user on every request, only the AUTH_KEY root (UserID 0) keeps the stored role.
+func (s *AdminAuthService) CreateSession(principal *models.AdminPrincipal, ttl time.Duration) (*LoginResult, error) {
+	token, err := generateAdminSecret(adminSessionPrefix)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(adminSession{
+		UserID:     principal.UserID,
+		Username:   principal.Username,
+		Role:       principal.Role,
+		AuthMethod: principal.AuthMethod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Set(sessionKey(token), payload, ttl); err != nil {
+		return nil, fmt.Errorf("failed to store admin session: %w", err)
+	}
+
+	return &LoginResult{
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+		Principal: principal,
+	}, nil
+}
+
+// Logout revokes a session token. Other credentials are left untouched.
+func (s *AdminAuthService) Logout(credential string) error {
+	if !strings.HasPrefix(credential, adminSessionPrefix) {
+		return nil
+	}
+	return s.store.Delete(sessionKey(credential))
+}
+
+// ListUsers returns all admin users.
+func (s *AdminAuthService) ListUsers(ctx context.Context) ([]models.AdminUser, error) {
+	var users []models.AdminUser
+	if err := s.db.WithContext(ctx).Order("id asc").Find(&users).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return users, nil
+}
+
+// CreateUser creates a new admin user with a hashed password.
+func (s *AdminAuthService) CreateUser(ctx context.Context, params AdminUserParams) (*models.AdminUser, error) {
+	username := strings.TrimSpace(params.Username)
+	if username == "" || strings.EqualFold(username, adminRootUsername) {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_username_invalid", nil)
+	}
+	if params.Password == nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_password_too_short", map[string]any{"min": minAdminPasswordLen})
+	}
+	role := models.AdminRoleViewer
+	if params.Role != nil {
+		role = *params.Role
+	}
+	if models.AdminRoleRank(role) == 0 {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_role_invalid", map[string]any{"role": role})
+	}
+
+	passwordHash, err := hashAdminPassword(*params.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.AdminUser{
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         role,
+		Enabled:      params.Enabled == nil || *params.Enabled,
+	}
+	if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return &user, nil
+}
+
+// UpdateUser changes the password, role or enabled state of an admin user.
+// The last enabled owner cannot be demoted or disabled.
+func (s *AdminAuthService) UpdateUser(ctx context.Context, id uint, params AdminUserParams) (*models.AdminUser, error) {
+	var user models.AdminUser
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "admin.user_not_found", nil)
+		}
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	updates := map[string]any{}
+	if params.Password != nil {
+		passwordHash, err := hashAdminPassword(*params.Password)
+		if err != nil {
+			return nil, err
+		}
+		updates["password_hash"] = passwordHash
+	}
+	if params.Role != nil {
+		if models.AdminRoleRank(*params.Role) == 0 {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_role_invalid", map[string]any{"role": *params.Role})
+		}
+		updates["role"] = *params.Role
+	}
+	if params.Enabled != nil {
+		updates["enabled"] = *params.Enabled
+	}
+	if len(updates) == 0 {
+		return &user, nil
+	}
+
+	losesOwner := user.Role == models.AdminRoleOwner && user.Enabled &&
+		((params.Role != nil && *params.Role != models.AdminRoleOwner) || (params.Enabled != nil && !*params.Enabled))
+	if losesOwner {
+		if err := s.ensureAnotherOwner(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(&user).Updates(updates).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return &user, nil
+}
+
+// DeleteUser removes an admin user together with its API tokens.
+func (s *AdminAuthService) DeleteUser(ctx context.Context, id uint) error {
+	var user models.AdminUser
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewI18nError(app_errors.ErrResourceNotFound, "admin.user_not_found", nil)
+		}
+		return app_errors.ParseDBError(err)
+	}
+
+	if user.Role == models.AdminRoleOwner && user.Enabled {
+		if err := s.ensureAnotherOwner(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.AdminToken{}).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		return nil
+	})
+}
+
+// ListTokens returns the API tokens visible to the principal. Owners see every token.
+func (s *AdminAuthService) ListTokens(ctx context.Context, principal *models.AdminPrincipal) ([]models.AdminToken, error) {
+	ownerID, err := tokenOwnerID(principal)
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.WithContext(ctx).Order("id desc")
+	if !principal.HasRole(models.AdminRoleOwner) {
+		query = query.Where("user_id = ?", ownerID)
+	}
+
+	var tokens []models.AdminToken
+	if err := query.Find(&tokens).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return tokens, nil
+}
+
+// CreateToken issues a new API token for the principal. Its scope cannot exceed the principal's role.
+func (s *AdminAuthService) CreateToken(ctx context.Context, principal *models.AdminPrincipal, params AdminTokenParams) (*AdminTokenCreated, error) {
+	ownerID, err := tokenOwnerID(principal)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_token_name_required", nil)
+	}
+	role := params.Role
+	if role == "" {
+		role = models.AdminRoleViewer
+	}
+	if models.AdminRoleRank(role) == 0 {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_role_invalid", map[string]any{"role": role})
+	}
+	if !principal.HasRole(role) {
+		return nil, NewI18nError(app_errors.ErrForbidden, "admin.token_scope_exceeds_role", nil)
+	}
+	if params.ExpiresInDays < 0 {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.admin_token_expiry_invalid", nil)
+	}
+
+	secret, err := generateAdminSecret(adminTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	token := models.AdminToken{
+		UserID:      ownerID,
+		Name:        name,
+		TokenHash:   hashAdminSecret(secret),
+		TokenPrefix: secret[:len(adminTokenPrefix)+6],
+		Role:        role,
+	}
+	if params.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, params.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.WithContext(ctx).Create(&token).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return &AdminTokenCreated{AdminToken: token, Token: secret}, nil
+}
+
+// DeleteToken revokes an API token. Non-owners can only revoke their own tokens.
+func (s *AdminAuthService) DeleteToken(ctx context.Context, principal *models.AdminPrincipal, id uint) error {
+	ownerID, err := tokenOwnerID(principal)
+	if err != nil {
+		return err
+	}
+	query := s.db.WithContext(ctx).Where("id = ?", id)
+	if !principal.HasRole(models.AdminRoleOwner) {
+		query = query.Where("user_id = ?", ownerID)
+	}
+
+	result := query.Delete(&models.AdminToken{})
+	if result.Error != nil {
+		return app_errors.ParseDBError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return NewI18nError(app_errors.ErrResourceNotFound, "admin.token_not_found", nil)
+	}
+	return nil
+}
+
+func (s *AdminAuthService) isAuthKey(credential string) bool {
+	authKey := s.configManager.GetAuthConfig().Key
+	return authKey != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(authKey)) == 1
+}
+
+// authenticateSession resolves a session and re-reads the user so that role changes apply immediately.
+func (s *AdminAuthService) authenticateSession(ctx context.Context, token string) (*models.AdminPrincipal, error) {
+	payload, err := s.store.Get(sessionKey(token))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrAdminUnauthorized
+		}
+		return nil, err
+	}
+
+	var session adminSession
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return nil, ErrAdminUnauthorized
+	}
+
+	principal := &models.AdminPrincipal{
+		UserID:     session.UserID,
+		Username:   session.Username,
+		Role:       session.Role,
+		AuthMethod: session.AuthMethod,
+	}
+	if principal.AuthMethod == "" {
+		principal.AuthMethod = "session"
+	}
+	if session.UserID == 0 {
+		// SSO sessions created before SSO users had accounts cannot be told apart from root
+		if principal.AuthMethod != "session" || principal.Username != adminRootUsername {
+			return nil, ErrAdminUnauthorized
+		}
+		return principal, nil
+	}
+
+	user, err := s.loadEnabledUser(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	principal.Username = user.Username
+	principal.Role = user.Role
+	return principal, nil
+}
+
+// authenticateToken resolves an API token. The effective role is the lower of the token scope and the user role.
+func (s *AdminAuthService) authenticateToken(ctx context.Context, secret string) (*models.AdminPrincipal, error) {
+	var token models.AdminToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hashAdminSecret(secret)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminUnauthorized
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, ErrAdminUnauthorized
+	}
+
+	principal := &models.AdminPrincipal{
+		UserID:     token.UserID,
+		Username:   adminRootUsername,
+		Role:       token.Role,
+		AuthMethod: "token",
+		TokenID:    token.ID,
+	}
+	if token.UserID != 0 {
+		user, err := s.loadEnabledUser(ctx, token.UserID)
+		if err != nil {
+			return nil, err
+		}
+		principal.Username = user.Username
+		if models.AdminRoleRank(user.Role) < models.AdminRoleRank(token.Role) {
+			principal.Role = user.Role
+		}
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > tokenLastUsedMinStep {
+		if err := s.db.WithContext(ctx).Model(&token).UpdateColumn("last_used_at", &now).Error; err != nil {
+			logrus.WithError(err).Warn("Failed to update admin token last used time")
+		}
+	}
+
+	return principal, nil
+}
+
+func (s *AdminAuthService) loadEnabledUser(ctx context.Context, id uint) (*models.AdminUser, error) {
+	var user models.AdminUser
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminUnauthorized
+		}
+		return nil, err
+	}
+	if !user.Enabled {
+		return nil, ErrAdminUnauthorized
+	}
+	return &user, nil
+}
+
+// usernameTaken reports whether username belongs to an admin account other than excludeID.
+func (s *AdminAuthService) usernameTaken(ctx context.Context, username string, excludeID uint) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.AdminUser{}).
+		Where("username = ? AND id <> ?", username, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return false, app_errors.ParseDBError(err)
+	}
+	return count > 0, nil
+}
+
+// ensureAnotherOwner fails when no other enabled owner would remain.
+// The AUTH_KEY still grants owner access, but accounts should not be orphaned from an owner by accident.
+func (s *AdminAuthService) ensureAnotherOwner(ctx context.Context, excludeID uint) error {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.AdminUser{}).
+		Where("role = ? AND enabled = ? AND id <> ?", models.AdminRoleOwner, true, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	if count == 0 {
+		return NewI18nError(app_errors.ErrValidation, "admin.last_owner", nil)
+	}
+	return nil
+}
+
+// tokenOwnerID returns the account that owns the API tokens of a principal.
+// UserID 0 stands for the AUTH_KEY root, any other principal must have an admin account.
+func tokenOwnerID(principal *models.AdminPrincipal) (uint, error) {
+	if principal == nil || (principal.UserID == 0 && principal.Username != adminRootUsername) {
+		return 0, NewI18nError(app_errors.ErrForbidden, "admin.token_requires_account", nil)
+	}
+	return principal.UserID, nil
+}
+
+func rootPrincipal() *models.AdminPrincipal {
+	return &models.AdminPrincipal{
+		Username:   adminRootUsername,
+		Role:       models.AdminRoleOwner,
+		AuthMethod: "auth_key",
+	}
+}
+
+func hashAdminPassword(password string) (string, error) {
+	if len(password) < minAdminPasswordLen {
+		return "", NewI18nError(app_errors.ErrValidation, "validation.admin_password_too_short", map[string]any{"min": minAdminPasswordLen})
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func generateAdminSecret(prefix string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// hashAdminSecret hashes random session and token secrets. A plain digest is enough for high-entropy values.
+func hashAdminSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func sessionKey(token string) string {
+	return "admin_session:" + hashAdminSecret(token)
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"sync"
 
 	app_errors "gpt-load/internal/errors"
@@ -9,13 +11,18 @@ import (
 	"gpt-load/internal/utils"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // SubGroupInput defines the input payload for aggregate group member configuration.
 type SubGroupInput struct {
-	GroupID uint `json:"group_id"`
-	Weight  int  `json:"weight"`
+	GroupID  uint     `json:"group_id"`
+	Weight   int      `json:"weight"`
+	Priority int      `json:"priority"`
+	Models   []string `json:"models"`
+	// ActiveSchedule limits the sub-group to time windows, nil keeps it always active
+	ActiveSchedule *models.ActiveSchedule `json:"active_schedule"`
 }
 
 // AggregateValidationResult captures the normalized aggregate group parameters.
@@ -28,18 +35,25 @@ type AggregateValidationResult struct {
 type AggregateGroupService struct {
 	db           *gorm.DB
 	groupManager *GroupManager
+	auditService *AuditService
 }
 
 // NewAggregateGroupService constructs an AggregateGroupService instance.
-func NewAggregateGroupService(db *gorm.DB, groupManager *GroupManager) *AggregateGroupService {
+func NewAggregateGroupService(db *gorm.DB, groupManager *GroupManager, auditService *AuditService) *AggregateGroupService {
 	return &AggregateGroupService{
 		db:           db,
 		groupManager: groupManager,
+		auditService: auditService,
 	}
 }
 
 // ValidateSubGroups validates sub-groups with an optional existing validation endpoint for consistency check.
 func (s *AggregateGroupService) ValidateSubGroups(ctx context.Context, channelType string, inputs []SubGroupInput, existingEndpoint string) (*AggregateValidationResult, error) {
+	return validateSubGroups(s.db.WithContext(ctx), channelType, inputs, existingEndpoint)
+}
+
+// validateSubGroups validates sub-groups, reading them through db, which may be a transaction.
+func validateSubGroups(db *gorm.DB, channelType string, inputs []SubGroupInput, existingEndpoint string) (*AggregateValidationResult, error) {
 	if len(inputs) == 0 {
 		return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_groups_required", nil)
 	}
@@ -55,11 +69,20 @@ func (s *AggregateGroupService) ValidateSubGroups(ctx context.Context, channelTy
 		if input.Weight > 1000 {
 			return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_max_exceeded", nil)
 		}
+		if err := validateSubGroupPriority(input.Priority); err != nil {
+			return nil, err
+		}
+		if _, err := normalizeSubGroupModels(input.Models); err != nil {
+			return nil, err
+		}
+		if _, err := normalizeSubGroupSchedule(input.ActiveSchedule); err != nil {
+			return nil, err
+		}
 		subGroupIDs = append(subGroupIDs, input.GroupID)
 	}
 
 	var subGroupModels []models.Group
-	if err := s.db.WithContext(ctx).Where("id IN ?", subGroupIDs).Find(&subGroupModels).Error; err != nil {
+	if err := db.Where("id IN ?", subGroupIDs).Find(&subGroupModels).Error; err != nil {
 		return nil, app_errors.ParseDBError(err)
 	}
 
@@ -97,9 +120,20 @@ func (s *AggregateGroupService) ValidateSubGroups(ctx context.Context, channelTy
 		if _, ok := subGroupMap[input.GroupID]; !ok {
 			return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_group_not_found", nil)
 		}
+		modelsJSON, err := normalizeSubGroupModels(input.Models)
+		if err != nil {
+			return nil, err
+		}
+		scheduleJSON, err := normalizeSubGroupSchedule(input.ActiveSchedule)
+		if err != nil {
+			return nil, err
+		}
 		resultSubGroups = append(resultSubGroups, models.GroupSubGroup{
 			SubGroupID: input.GroupID,
 			Weight:     input.Weight,
+			Priority:   input.Priority,
+			Models:     modelsJSON,
+			Schedule:   scheduleJSON,
 		})
 	}
 
@@ -134,10 +168,16 @@ func (s *AggregateGroupService) GetSubGroups(ctx context.Context, groupID uint)
 
 	subGroupIDs := make([]uint, 0, len(groupSubGroups))
 	weightMap := make(map[uint]int, len(groupSubGroups))
+	priorityMap := make(map[uint]int, len(groupSubGroups))
+	modelsMap := make(map[uint][]string, len(groupSubGroups))
+	scheduleMap := make(map[uint]*models.ActiveSchedule, len(groupSubGroups))
 
 	for _, gsg := range groupSubGroups {
 		subGroupIDs = append(subGroupIDs, gsg.SubGroupID)
 		weightMap[gsg.SubGroupID] = gsg.Weight
+		priorityMap[gsg.SubGroupID] = gsg.Priority
+		modelsMap[gsg.SubGroupID] = parseSubGroupModels(gsg.Models)
+		scheduleMap[gsg.SubGroupID] = parseSubGroupSchedule(gsg.Schedule)
 	}
 
 	var subGroupModels []models.Group
@@ -160,6 +200,9 @@ func (s *AggregateGroupService) GetSubGroups(ctx context.Context, groupID uint)
 		subGroups = append(subGroups, models.SubGroupInfo{
 			Group:       subGroup,
 			Weight:      weightMap[subGroup.ID],
+			Priority:    priorityMap[subGroup.ID],
+			Models:      modelsMap[subGroup.ID],
+			Schedule:    scheduleMap[subGroup.ID],
 			TotalKeys:   stats.TotalKeys,
 			ActiveKeys:  stats.ActiveKeys,
 			InvalidKeys: stats.InvalidKeys,
@@ -171,36 +214,45 @@ func (s *AggregateGroupService) GetSubGroups(ctx context.Context, groupID uint)
 
 // AddSubGroups adds new sub groups to an aggregate group
 func (s *AggregateGroupService) AddSubGroups(ctx context.Context, groupID uint, inputs []SubGroupInput) error {
-	var group models.Group
-	if err := s.db.WithContext(ctx).First(&group, groupID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
-		}
+	var entry AuditEntry
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = s.addSubGroups(tx, groupID, inputs)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	if group.GroupType != "aggregate" {
-		return NewI18nError(app_errors.ErrBadRequest, "group.not_aggregate", nil)
+	s.afterSubGroupChange(ctx, entry)
+	return nil
+}
+
+// addSubGroups validates and stores new sub groups in tx and returns the audit entry of the change.
+func (s *AggregateGroupService) addSubGroups(tx *gorm.DB, groupID uint, inputs []SubGroupInput) (AuditEntry, error) {
+	group, err := loadAggregateGroup(tx, groupID)
+	if err != nil {
+		return AuditEntry{}, err
 	}
 
 	// Check if there are existing sub groups and get their validation endpoint
 	var existingEndpoint string
 	var existingSubGroups []models.GroupSubGroup
-	if err := s.db.WithContext(ctx).Where("group_id = ?", groupID).Find(&existingSubGroups).Error; err != nil {
-		return err
+	if err := tx.Where("group_id = ?", groupID).Find(&existingSubGroups).Error; err != nil {
+		return AuditEntry{}, err
 	}
 
 	if len(existingSubGroups) > 0 {
 		var existingGroup models.Group
-		if err := s.db.WithContext(ctx).First(&existingGroup, existingSubGroups[0].SubGroupID).Error; err == nil {
+		if err := tx.First(&existingGroup, existingSubGroups[0].SubGroupID).Error; err == nil {
 			existingEndpoint = utils.GetValidationEndpoint(&existingGroup)
 		}
 	}
 
 	// Validate sub groups with existing endpoint for consistency
-	result, err := s.ValidateSubGroups(ctx, group.ChannelType, inputs, existingEndpoint)
+	result, err := validateSubGroups(tx, group.ChannelType, inputs, existingEndpoint)
 	if err != nil {
-		return err
+		return AuditEntry{}, err
 	}
 
 	// Check for duplicates with existing sub groups
@@ -211,125 +263,261 @@ func (s *AggregateGroupService) AddSubGroups(ctx context.Context, groupID uint,
 
 	for _, newSg := range result.SubGroups {
 		if existingSubGroupIDs[newSg.SubGroupID] {
-			return NewI18nError(app_errors.ErrBadRequest, "group.sub_group_already_exists",
+			return AuditEntry{}, NewI18nError(app_errors.ErrBadRequest, "group.sub_group_already_exists",
 				map[string]any{"sub_group_id": newSg.SubGroupID})
 		}
 	}
 
 	// Add new sub groups
-	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		for _, newSg := range result.SubGroups {
-			newSg.GroupID = groupID
-			if err := tx.Create(&newSg).Error; err != nil {
-				return app_errors.ParseDBError(err)
-			}
+	added := make([]map[string]any, 0, len(result.SubGroups))
+	for i := range result.SubGroups {
+		result.SubGroups[i].GroupID = groupID
+		if err := tx.Create(&result.SubGroups[i]).Error; err != nil {
+			return AuditEntry{}, app_errors.ParseDBError(err)
 		}
+		added = append(added, subGroupAuditSnapshot(&result.SubGroups[i]))
+	}
 
-		return nil
-	})
+	return AuditEntry{
+		Action:     "sub_group.add",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		After:      map[string]any{"sub_groups": added},
+	}, nil
+}
 
+// UpdateSubGroupWeight updates the weight of a specific sub group
+func (s *AggregateGroupService) UpdateSubGroupWeight(ctx context.Context, groupID, subGroupID uint, weight int) error {
+	entry, err := s.updateSubGroupWeight(s.db.WithContext(ctx), groupID, subGroupID, weight)
 	if err != nil {
 		return err
 	}
+	s.afterSubGroupChange(ctx, entry)
+	return nil
+}
 
-	// 触发缓存更新
-	if err := s.groupManager.Invalidate(); err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after adding sub groups")
+func (s *AggregateGroupService) updateSubGroupWeight(db *gorm.DB, groupID, subGroupID uint, weight int) (AuditEntry, error) {
+	group, err := loadAggregateGroup(db, groupID)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if weight < 0 {
+		return AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_negative", nil)
 	}
 
+	if weight > 1000 {
+		return AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_max_exceeded", nil)
+	}
+
+	return updateSubGroupColumn(db, group, subGroupID, "sub_group.update_weight", "weight", weight, func(record *models.GroupSubGroup) {
+		record.Weight = weight
+	})
+}
+
+// UpdateSubGroupPriority updates the priority tier of a specific sub group
+func (s *AggregateGroupService) UpdateSubGroupPriority(ctx context.Context, groupID, subGroupID uint, priority int) error {
+	entry, err := s.updateSubGroupPriority(s.db.WithContext(ctx), groupID, subGroupID, priority)
+	if err != nil {
+		return err
+	}
+	s.afterSubGroupChange(ctx, entry)
 	return nil
 }
 
-// UpdateSubGroupWeight updates the weight of a specific sub group
-func (s *AggregateGroupService) UpdateSubGroupWeight(ctx context.Context, groupID, subGroupID uint, weight int) error {
-	var group models.Group
-	if err := s.db.WithContext(ctx).First(&group, groupID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
-		}
+func (s *AggregateGroupService) updateSubGroupPriority(db *gorm.DB, groupID, subGroupID uint, priority int) (AuditEntry, error) {
+	group, err := loadAggregateGroup(db, groupID)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	if err := validateSubGroupPriority(priority); err != nil {
+		return AuditEntry{}, err
+	}
+
+	return updateSubGroupColumn(db, group, subGroupID, "sub_group.update_priority", "priority", priority, func(record *models.GroupSubGroup) {
+		record.Priority = priority
+	})
+}
+
+// UpdateSubGroupModels updates the declared model list of a specific sub group
+func (s *AggregateGroupService) UpdateSubGroupModels(ctx context.Context, groupID, subGroupID uint, modelList []string) error {
+	entry, err := s.updateSubGroupModels(s.db.WithContext(ctx), groupID, subGroupID, modelList)
+	if err != nil {
 		return err
 	}
+	s.afterSubGroupChange(ctx, entry)
+	return nil
+}
 
-	if group.GroupType != "aggregate" {
-		return NewI18nError(app_errors.ErrBadRequest, "group.not_aggregate", nil)
+func (s *AggregateGroupService) updateSubGroupModels(db *gorm.DB, groupID, subGroupID uint, modelList []string) (AuditEntry, error) {
+	group, err := loadAggregateGroup(db, groupID)
+	if err != nil {
+		return AuditEntry{}, err
 	}
 
-	if weight < 0 {
-		return NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_negative", nil)
+	modelsJSON, err := normalizeSubGroupModels(modelList)
+	if err != nil {
+		return AuditEntry{}, err
 	}
 
-	if weight > 1000 {
-		return NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_max_exceeded", nil)
+	return updateSubGroupColumn(db, group, subGroupID, "sub_group.update_models", "models", modelsJSON, func(record *models.GroupSubGroup) {
+		record.Models = modelsJSON
+	})
+}
+
+// UpdateSubGroupSchedule updates the active time windows of a specific sub group.
+// A nil schedule or one without windows keeps the sub group always active.
+func (s *AggregateGroupService) UpdateSubGroupSchedule(ctx context.Context, groupID, subGroupID uint, schedule *models.ActiveSchedule) error {
+	entry, err := s.updateSubGroupSchedule(s.db.WithContext(ctx), groupID, subGroupID, schedule)
+	if err != nil {
+		return err
+	}
+	s.afterSubGroupChange(ctx, entry)
+	return nil
+}
+
+func (s *AggregateGroupService) updateSubGroupSchedule(db *gorm.DB, groupID, subGroupID uint, schedule *models.ActiveSchedule) (AuditEntry, error) {
+	group, err := loadAggregateGroup(db, groupID)
+	if err != nil {
+		return AuditEntry{}, err
 	}
 
-	// 检查子分组关联是否存在
-	var existingRecord models.GroupSubGroup
-	if err := s.db.WithContext(ctx).Where("group_id = ? AND sub_group_id = ?", groupID, subGroupID).First(&existingRecord).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
-		}
+	scheduleJSON, err := normalizeSubGroupSchedule(schedule)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	return updateSubGroupColumn(db, group, subGroupID, "sub_group.update_schedule", "schedule", scheduleJSON, func(record *models.GroupSubGroup) {
+		record.Schedule = scheduleJSON
+	})
+}
+
+// DeleteSubGroup removes a sub group from an aggregate group
+func (s *AggregateGroupService) DeleteSubGroup(ctx context.Context, groupID, subGroupID uint) error {
+	entry, err := s.deleteSubGroup(s.db.WithContext(ctx), groupID, subGroupID)
+	if err != nil {
 		return err
 	}
+	s.afterSubGroupChange(ctx, entry)
+	return nil
+}
 
-	result := s.db.WithContext(ctx).
-		Model(&models.GroupSubGroup{}).
+func (s *AggregateGroupService) deleteSubGroup(db *gorm.DB, groupID, subGroupID uint) (AuditEntry, error) {
+	group, err := loadAggregateGroup(db, groupID)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	existingRecord, err := findSubGroupRecord(db, groupID, subGroupID)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	result := db.
 		Where("group_id = ? AND sub_group_id = ?", groupID, subGroupID).
-		Update("weight", weight)
+		Delete(&models.GroupSubGroup{})
 
 	if result.Error != nil {
-		return result.Error
+		return AuditEntry{}, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
+		return AuditEntry{}, NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
 	}
 
+	return subGroupChangeEntry("sub_group.delete", group, existingRecord, nil), nil
+}
+
+// afterSubGroupChange refreshes the group cache and records the audit entry once a change is stored
+func (s *AggregateGroupService) afterSubGroupChange(ctx context.Context, entry AuditEntry) {
 	// 触发缓存更新
 	if err := s.groupManager.Invalidate(); err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after updating sub group weight")
+		logrus.WithContext(ctx).WithError(err).WithField("action", entry.Action).Error("failed to invalidate group cache after changing sub groups")
 	}
-
-	return nil
+	s.auditService.Record(ctx, entry)
 }
 
-// DeleteSubGroup removes a sub group from an aggregate group
-func (s *AggregateGroupService) DeleteSubGroup(ctx context.Context, groupID, subGroupID uint) error {
+// loadAggregateGroup loads a group and checks that it is an aggregate group
+func loadAggregateGroup(db *gorm.DB, groupID uint) (*models.Group, error) {
 	var group models.Group
-	if err := s.db.WithContext(ctx).First(&group, groupID).Error; err != nil {
+	if err := db.First(&group, groupID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
 		}
-		return err
+		return nil, err
 	}
 
 	if group.GroupType != "aggregate" {
-		return NewI18nError(app_errors.ErrBadRequest, "group.not_aggregate", nil)
+		return nil, NewI18nError(app_errors.ErrBadRequest, "group.not_aggregate", nil)
+	}
+	return &group, nil
+}
+
+// updateSubGroupColumn sets one column of a sub group membership and returns the audit entry of the change
+func updateSubGroupColumn(db *gorm.DB, group *models.Group, subGroupID uint, action, column string, value any, apply func(*models.GroupSubGroup)) (AuditEntry, error) {
+	existingRecord, err := findSubGroupRecord(db, group.ID, subGroupID)
+	if err != nil {
+		return AuditEntry{}, err
 	}
 
-	result := s.db.WithContext(ctx).
-		Where("group_id = ? AND sub_group_id = ?", groupID, subGroupID).
-		Delete(&models.GroupSubGroup{})
+	result := db.
+		Model(&models.GroupSubGroup{}).
+		Where("group_id = ? AND sub_group_id = ?", group.ID, subGroupID).
+		Update(column, value)
 
 	if result.Error != nil {
-		return result.Error
+		return AuditEntry{}, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
+		return AuditEntry{}, NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
 	}
 
-	// 触发缓存更新
-	if err := s.groupManager.Invalidate(); err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after deleting sub group")
+	updated := *existingRecord
+	apply(&updated)
+	return subGroupChangeEntry(action, group, existingRecord, &updated), nil
+}
+
+// findSubGroupRecord loads a sub group membership of an aggregate group
+func findSubGroupRecord(db *gorm.DB, groupID, subGroupID uint) (*models.GroupSubGroup, error) {
+	var record models.GroupSubGroup
+	if err := db.Where("group_id = ? AND sub_group_id = ?", groupID, subGroupID).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "group.sub_group_not_found", nil)
+		}
+		return nil, err
 	}
+	return &record, nil
+}
 
-	return nil
+// subGroupChangeEntry builds the audit entry for a change to a sub group membership
+func subGroupChangeEntry(action string, group *models.Group, before, after *models.GroupSubGroup) AuditEntry {
+	entry := AuditEntry{
+		Action:     action,
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+	}
+	if before != nil {
+		entry.Before = subGroupAuditSnapshot(before)
+	}
+	if after != nil {
+		entry.After = subGroupAuditSnapshot(after)
+	}
+	return entry
 }
 
 // CountAggregateGroupsUsingSubGroup returns the number of aggregate groups that use the specified group as a sub-group
 func (s *AggregateGroupService) CountAggregateGroupsUsingSubGroup(ctx context.Context, subGroupID uint) (int64, error) {
+	return countAggregateGroupsUsingSubGroup(s.db.WithContext(ctx), subGroupID)
+}
+
+// countAggregateGroupsUsingSubGroup counts the aggregate groups using a sub-group through db, which may be a transaction
+func countAggregateGroupsUsingSubGroup(db *gorm.DB, subGroupID uint) (int64, error) {
 	var count int64
-	err := s.db.WithContext(ctx).
+	err := db.
 		Model(&models.GroupSubGroup{}).
 		Where("sub_group_id = ?", subGroupID).
 		Count(&count).Error
@@ -378,6 +566,48 @@ func (s *AggregateGroupService) GetParentAggregateGroups(ctx context.Context, su
 	return parentGroups, nil
 }
 
+// validateSubGroupPriority checks the priority tier range of a sub group
+func validateSubGroupPriority(priority int) error {
+	if priority < 0 || priority > 100 {
+		return NewI18nError(app_errors.ErrValidation, "validation.sub_group_priority_out_of_range", nil)
+	}
+	return nil
+}
+
+// normalizeSubGroupModels trims and de-duplicates a declared model list and encodes it as JSON.
+// An empty list means the sub-group serves every model.
+func normalizeSubGroupModels(modelList []string) (datatypes.JSON, error) {
+	seen := make(map[string]bool, len(modelList))
+	normalized := make([]string, 0, len(modelList))
+	for _, model := range modelList {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_group_model_empty", nil)
+		}
+		if seen[model] {
+			continue
+		}
+		seen[model] = true
+		normalized = append(normalized, model)
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+// normalizeSubGroupSchedule validates an active schedule and encodes it as JSON.
+// A schedule without windows is stored as NULL.
+func normalizeSubGroupSchedule(schedule *models.ActiveSchedule) (datatypes.JSON, error) {
+	scheduleJSON, err := activeScheduleToJSON(schedule)
+	if err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_active_schedule", map[string]any{"error": err.Error()})
+	}
+	return scheduleJSON, nil
+}
+
 // keyStatsResult stores key statistics for a single group
 type keyStatsResult struct {
 	GroupID     uint
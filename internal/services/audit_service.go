@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// auditActorKey is the context key of the audit actor.
+type auditActorKey struct{}
+
+// AuditActor identifies who performed an admin change and from where.
+type AuditActor struct {
+	Username   string
+	Role       string
+	AuthMethod string
+	SourceIP   string
+}
+
+// AuditEntry describes a single change to be recorded.
+// Before and After are snapshots that are diffed field by field; nil means the target did not exist.
+type AuditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   any
+	TargetName string
+	Before     any
+	After      any
+}
+
+// WithAuditActor returns a context carrying the actor of the current admin request.
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext returns the actor stored in the context, or a system actor for background work.
+func AuditActorFromContext(ctx context.Context) AuditActor {
+	if ctx != nil {
+		if actor, ok := ctx.Value(auditActorKey{}).(AuditActor); ok {
+			return actor
+		}
+	}
+	return AuditActor{Username: "system"}
+}
+
+// AuditService records admin changes and serves the audit log.
+type AuditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService creates a new AuditService.
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record writes an audit log entry. Failures are logged and never fail the audited operation.
+func (s *AuditService) Record(ctx context.Context, entry AuditEntry) {
+	changes, err := json.Marshal(computeAuditChanges(entry.Before, entry.After))
+	if err != nil {
+		logrus.WithError(err).WithField("action", entry.Action).Error("Failed to marshal audit changes")
+		return
+	}
+
+	actor := AuditActorFromContext(ctx)
+	auditLog := models.AuditLog{
+		Timestamp:  time.Now(),
+		Actor:      actor.Username,
+		ActorRole:  actor.Role,
+		AuthMethod: actor.AuthMethod,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetName: utils.TruncateString(entry.TargetName, 255),
+		SourceIP:   actor.SourceIP,
+		Changes:    changes,
+	}
+	if entry.TargetID != nil {
+		auditLog.TargetID = fmt.Sprint(entry.TargetID)
+	}
+
+	// Use a fresh context so that a cancelled request still leaves its audit trail
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Create(&auditLog).Error; err != nil {
+		logrus.WithError(err).WithField("action", entry.Action).Error("Failed to record audit log")
+	}
+}
+
+// auditFiltersScope returns a GORM scope function that applies filters from the Gin context.
+func (s *AuditService) auditFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if actor := c.Query("actor"); actor != "" {
+			db = db.Where("actor LIKE ?", "%"+actor+"%")
+		}
+		if action := c.Query("action"); action != "" {
+			db = db.Where("action LIKE ?", action+"%")
+		}
+		if targetType := c.Query("target_type"); targetType != "" {
+			db = db.Where("target_type = ?", targetType)
+		}
+		if targetID := c.Query("target_id"); targetID != "" {
+			db = db.Where("target_id = ?", targetID)
+		}
+		if targetName := c.Query("target_name"); targetName != "" {
+			db = db.Where("target_name LIKE ?", "%"+targetName+"%")
+		}
+		if sourceIP := c.Query("source_ip"); sourceIP != "" {
+			db = db.Where("source_ip = ?", sourceIP)
+		}
+		if startTimeStr := c.Query("start_time"); startTimeStr != "" {
+			if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+				db = db.Where("timestamp >= ?", startTime)
+			}
+		}
+		if endTimeStr := c.Query("end_time"); endTimeStr != "" {
+			if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+				db = db.Where("timestamp <= ?", endTime)
+			}
+		}
+		return db
+	}
+}
+
+// GetAuditLogsQuery returns a GORM query for fetching audit logs with filters.
+func (s *AuditService) GetAuditLogsQuery(c *gin.Context) *gorm.DB {
+	return s.db.Model(&models.AuditLog{}).Scopes(s.auditFiltersScope(c))
+}
+
+// StreamAuditLogsToCSV streams the filtered audit logs as CSV, newest first.
+func (s *AuditService) StreamAuditLogsToCSV(c *gin.Context, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	defer csvWriter.Flush()
+
+	header := []string{"timestamp", "actor", "actor_role", "auth_method", "action", "target_type", "target_id", "target_name", "source_ip", "changes"}
+	if err := csvWriter.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	var batch []models.AuditLog
+	err := s.GetAuditLogsQuery(c).Order("id desc").FindInBatches(&batch, chunkSize, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			record := []string{
+				entry.Timestamp.Format(time.RFC3339),
+				entry.Actor,
+				entry.ActorRole,
+				entry.AuthMethod,
+				entry.Action,
+				entry.TargetType,
+				entry.TargetID,
+				entry.TargetName,
+				entry.SourceIP,
+				string(entry.Changes),
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV record: %w", err)
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to export audit logs: %w", err)
+	}
+
+	return nil
+}
+
+// computeAuditChanges diffs the top-level JSON fields of two snapshots.
+func computeAuditChanges(before, after any) map[string]models.AuditChange {
+	beforeMap := toAuditMap(before)
+	afterMap := toAuditMap(after)
+
+	fields := make(map[string]struct{}, len(beforeMap)+len(afterMap))
+	for field := range beforeMap {
+		fields[field] = struct{}{}
+	}
+	for field := range afterMap {
+		fields[field] = struct{}{}
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for field := range fields {
+		oldValue, newValue := beforeMap[field], afterMap[field]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = models.AuditChange{Before: oldValue, After: newValue}
+		}
+	}
+	return changes
+}
+
+// toAuditMap converts a snapshot to a generic map through its JSON representation.
+func toAuditMap(snapshot any) map[string]any {
+	result := make(map[string]any)
+	if snapshot == nil {
+		return result
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to marshal audit snapshot")
+		return result
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		// Scalars and arrays are recorded under a single field
+		var value any
+		if err := json.Unmarshal(data, &value); err == nil {
+			result["value"] = value
+		}
+	}
+	return result
+}
+
+// groupAuditSnapshot returns the auditable fields of a group. Proxy keys are masked.
+func groupAuditSnapshot(group *models.Group) map[string]any {
+	if group == nil {
+		return nil
+	}
+
+	proxyKeys := utils.SplitAndTrim(group.ProxyKeys, ",")
+	maskedKeys := make([]string, len(proxyKeys))
+	for i, key := range proxyKeys {
+		maskedKeys[i] = utils.MaskAPIKey(key)
+	}
+	sort.Strings(maskedKeys)
+
+	return map[string]any{
+		"name":                  group.Name,
+		"display_name":          group.DisplayName,
+		"description":           group.Description,
+		"group_type":            group.GroupType,
+		"upstreams":             group.Upstreams,
+		"channel_type":          group.ChannelType,
+		"sort":                  group.Sort,
+		"test_model":            group.TestModel,
+		"validation_endpoint":   group.ValidationEndpoint,
+		"param_overrides":       group.ParamOverrides,
+		"model_redirect_rules":  group.ModelRedirectRules,
+		"model_redirect_strict": group.ModelRedirectStrict,
+		"config":                group.Config,
+		"header_rules":          group.HeaderRules,
+		"budgets":               group.Budgets,
+		"state":                 group.State,
+		"proxy_keys":            strings.Join(maskedKeys, ","),
+	}
+}
+
+// subGroupAuditSnapshot returns the auditable fields of a sub-group membership.
+func subGroupAuditSnapshot(sg *models.GroupSubGroup) map[string]any {
+	if sg == nil {
+		return nil
+	}
+	return map[string]any{
+		"sub_group_id": sg.SubGroupID,
+		"weight":       sg.Weight,
+		"priority":     sg.Priority,
+		"models":       sg.Models,
+		"schedule":     sg.Schedule,
+	}
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpt-load/internal/config"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/syncer"
+
+	"github.com/sirupsen/logrus"
+)
+
+const AuthGuardUpdateChannel = "auth_guard:updated"
+
+// Scopes of failed authentication attempts. Each scope is tracked and banned separately,
+// so a misconfigured proxy client does not lock its address out of the admin console.
+const (
+	AuthScopeLogin = "login"
+	AuthScopeProxy = "proxy"
+)
+
+// Ban subject types
+const (
+	AuthBanTypeIP        = "ip"
+	AuthBanTypeKeyPrefix = "key_prefix"
+	AuthBanTypeUsername  = "username"
+)
+
+const (
+	authGuardBansKey        = "auth_guard:bans" // hash of subject -> JSON AuthBan
+	authGuardStatsKey       = "auth_guard:stats"
+	authGuardFailuresPrefix = "auth_guard:failures:"
+	authGuardBanCountPrefix = "auth_guard:ban_count:"
+
+	// authKeyPrefixLength is the number of leading characters of a presented key that are tracked.
+	// Guessing attempts against one key share its prefix even when they come from many addresses.
+	authKeyPrefixLength = 12
+
+	authFailureBaseDelay = 250 * time.Millisecond
+	authFailureMaxDelay  = 5 * time.Second
+
+	// Repeated bans of a subject within authBanHistoryWindow double the ban duration, up to maxAuthBanDuration
+	authBanHistoryWindow = 24 * time.Hour
+	maxAuthBanDuration   = 24 * time.Hour
+)
+
+// AuthBan describes a subject that is temporarily blocked after repeated failed attempts.
+type AuthBan struct {
+	Subject   string    `json:"subject"`
+	Scope     string    `json:"scope"`
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	Failures  int64     `json:"failures"`
+	BannedAt  time.Time `json:"banned_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AuthGuardStats holds the counters of failed attempts and bans across all nodes.
+type AuthGuardStats struct {
+	FailedAttempts  int64 `json:"failed_attempts"`
+	Bans            int64 `json:"bans"`
+	BlockedRequests int64 `json:"blocked_requests"`
+	ActiveBans      int   `json:"active_bans"`
+}
+
+// RetryAfter returns the remaining ban time in whole seconds, for the Retry-After header.
+func (b *AuthBan) RetryAfter() int {
+	return max(int(time.Until(b.ExpiresAt).Seconds())+1, 1)
+}
+
+// AuthAttempt identifies the source of an authentication attempt.
+type AuthAttempt struct {
+	Scope    string
+	IP       string
+	Key      string // presented key, tracked by its prefix
+	Username string // username of a password login
+}
+
+// authSubject is a tracked source of failed attempts.
+type authSubject struct {
+	key       string
+	banType   string
+	value     string
+	failCount int64
+}
+
+// AuthGuardService tracks failed login and proxy authentication attempts per source IP and
+// per key prefix in the shared store, delays repeated failures and bans subjects temporarily.
+// Active bans are cached on every node and reloaded through the store's pub/sub.
+type AuthGuardService struct {
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+	auditService    *AuditService
+	syncer          *syncer.CacheSyncer[map[string]AuthBan]
+}
+
+// NewAuthGuardService creates a new, uninitialized AuthGuardService.
+func NewAuthGuardService(store store.Store, settingsManager *config.SystemSettingsManager, auditService *AuditService) *AuthGuardService {
+	return &AuthGuardService{
+		store:           store,
+		settingsManager: settingsManager,
+		auditService:    auditService,
+	}
+}
+
+// Initialize sets up the CacheSyncer for active bans.
+func (s *AuthGuardService) Initialize() error {
+	banSyncer, err := syncer.NewCacheSyncer(
+		s.loadBans,
+		s.store,
+		AuthGuardUpdateChannel,
+		logrus.WithField("syncer", "auth_guard"),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create auth guard syncer: %w", err)
+	}
+	s.syncer = banSyncer
+	return nil
+}
+
+// Stop gracefully stops the background syncer.
+func (s *AuthGuardService) Stop(ctx context.Context) {
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+}
+
+// Check returns the active ban of the client IP of an attempt, or nil. It runs before the credential
+// is verified, so key prefix and username bans, which anyone can trigger, are left to CheckFailure.
+func (s *AuthGuardService) Check(attempt AuthAttempt) *AuthBan {
+	return s.findBan(AuthAttempt{Scope: attempt.Scope, IP: attempt.IP})
+}
+
+// CheckFailure returns the active ban of any subject of an attempt whose credential failed verification,
+// or nil. Key prefix and username bans only apply to failed attempts and never block a valid credential.
+func (s *AuthGuardService) CheckFailure(attempt AuthAttempt) *AuthBan {
+	return s.findBan(attempt)
+}
+
+// findBan returns the first active ban of the subjects of an attempt and counts it as a blocked request.
+func (s *AuthGuardService) findBan(attempt AuthAttempt) *AuthBan {
+	if s.syncer == nil {
+		return nil
+	}
+	bans := s.syncer.Get()
+	if len(bans) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, subject := range authSubjects(attempt) {
+		if ban, ok := bans[subject.key]; ok && now.Before(ban.ExpiresAt) {
+			if _, err := s.store.HIncrBy(authGuardStatsKey, "blocked_requests", 1); err != nil {
+				logrus.WithError(err).Debug("Failed to count blocked request")
+			}
+			return &ban
+		}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt, bans subjects that reached the limit and returns how long
+// the failure response should be delayed. The delay doubles with every failure of the window.
+func (s *AuthGuardService) RecordFailure(attempt AuthAttempt) time.Duration {
+	settings := s.settingsManager.GetSettings()
+	if settings.AuthMaxFailures <= 0 {
+		return 0
+	}
+	window := time.Duration(settings.AuthFailureWindowMinutes) * time.Minute
+
+	if _, err := s.store.HIncrBy(authGuardStatsKey, "failed_attempts", 1); err != nil {
+		logrus.WithError(err).Debug("Failed to count failed attempt")
+	}
+
+	var maxFailures int64
+	banned := false
+	for _, subject := range authSubjects(attempt) {
+		count, err := s.store.IncrBy(authGuardFailuresPrefix+subject.key, 1, window)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to record failed authentication attempt")
+			continue
+		}
+		maxFailures = max(maxFailures, count)
+
+		switch {
+		// The counter restarts after a ban, so concurrent failures do not ban a subject twice
+		case count == int64(settings.AuthMaxFailures):
+			subject.failCount = count
+			if s.ban(attempt.Scope, subject, settings.AuthBanMinutes) {
+				banned = true
+			}
+		case count == int64((settings.AuthMaxFailures+1)/2):
+			logrus.WithFields(logrus.Fields{
+				"scope":    attempt.Scope,
+				"type":     subject.banType,
+				"value":    subject.value,
+				"failures": count,
+			}).Warn("Repeated failed authentication attempts")
+		}
+	}
+	if banned {
+		s.invalidate()
+	}
+
+	if maxFailures <= 1 {
+		return 0
+	}
+	delay := authFailureBaseDelay << min(maxFailures-2, 10)
+	return min(delay, authFailureMaxDelay)
+}
+
+// DelayFailure waits for the delay returned by RecordFailure, or until ctx is done.
+func DelayFailure(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// RecordSuccess clears the failure counters of a successful attempt.
+func (s *AuthGuardService) RecordSuccess(attempt AuthAttempt) {
+	subjects := authSubjects(attempt)
+	keys := make([]string, len(subjects))
+	for i, subject := range subjects {
+		keys[i] = authGuardFailuresPrefix + subject.key
+	}
+	if err := s.store.Del(keys...); err != nil {
+		logrus.WithError(err).Debug("Failed to clear failed authentication attempts")
+	}
+}
+
+// ListBans returns the active bans, the most recent first.
+func (s *AuthGuardService) ListBans() []AuthBan {
+	bans := make([]AuthBan, 0)
+	if s.syncer == nil {
+		return bans
+	}
+	now := time.Now()
+	for _, ban := range s.syncer.Get() {
+		if now.Before(ban.ExpiresAt) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].BannedAt.After(bans[j].BannedAt)
+	})
+	return bans
+}
+
+// Stats returns the failed attempt and ban counters.
+func (s *AuthGuardService) Stats() AuthGuardStats {
+	stats := AuthGuardStats{ActiveBans: len(s.ListBans())}
+	values, err := s.store.HGetAll(authGuardStatsKey)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load auth guard stats")
+		return stats
+	}
+	stats.FailedAttempts, _ = strconv.ParseInt(values["failed_attempts"], 10, 64)
+	stats.Bans, _ = strconv.ParseInt(values["bans"], 10, 64)
+	stats.BlockedRequests, _ = strconv.ParseInt(values["blocked_requests"], 10, 64)
+	return stats
+}
+
+// Unban lifts a ban and resets the failure and ban history of its subject.
+func (s *AuthGuardService) Unban(ctx context.Context, subject string) error {
+	var ban *AuthBan
+	if s.syncer != nil {
+		if existing, ok := s.syncer.Get()[subject]; ok {
+			ban = &existing
+		}
+	}
+	if ban == nil {
+		return NewI18nError(app_errors.ErrResourceNotFound, "auth_guard.ban_not_found", nil)
+	}
+
+	if err := s.store.HDel(authGuardBansKey, subject); err != nil {
+		return app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error())
+	}
+	if err := s.store.Del(authGuardFailuresPrefix+subject, authGuardBanCountPrefix+subject); err != nil {
+		logrus.WithContext(ctx).WithError(err).Warn("Failed to reset auth guard counters")
+	}
+	s.invalidate()
+
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"scope": ban.Scope,
+		"type":  ban.Type,
+		"value": ban.Value,
+	}).Info("Authentication ban lifted manually")
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "auth_guard.unban",
+		TargetType: models.AuditTargetSettings,
+		TargetName: ban.Value,
+		Before: map[string]any{
+			"scope":      ban.Scope,
+			"type":       ban.Type,
+			"value":      ban.Value,
+			"expires_at": ban.ExpiresAt,
+		},
+	})
+	return nil
+}
+
+// ban blocks a subject. Repeated bans within authBanHistoryWindow double the duration.
+func (s *AuthGuardService) ban(scope string, subject authSubject, banMinutes int) bool {
+	banCount, err := s.store.IncrBy(authGuardBanCountPrefix+subject.key, 1, authBanHistoryWindow)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to record authentication ban")
+		banCount = 1
+	}
+	duration := time.Duration(banMinutes) * time.Minute << min(banCount-1, 10)
+	duration = min(duration, maxAuthBanDuration)
+
+	now := time.Now()
+	ban := AuthBan{
+		Subject:   subject.key,
+		Scope:     scope,
+		Type:      subject.banType,
+		Value:     subject.value,
+		Failures:  subject.failCount,
+		BannedAt:  now,
+		ExpiresAt: now.Add(duration),
+	}
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return false
+	}
+	if err := s.store.HSet(authGuardBansKey, map[string]any{subject.key: string(data)}); err != nil {
+		logrus.WithError(err).Error("Failed to store authentication ban")
+		return false
+	}
+	if err := s.store.Delete(authGuardFailuresPrefix + subject.key); err != nil {
+		logrus.WithError(err).Debug("Failed to reset failed authentication attempts")
+	}
+	if _, err := s.store.HIncrBy(authGuardStatsKey, "bans", 1); err != nil {
+		logrus.WithError(err).Debug("Failed to count authentication ban")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"scope":    scope,
+		"type":     subject.banType,
+		"value":    subject.value,
+		"failures": subject.failCount,
+		"duration": duration.String(),
+	}).Warn("Temporarily banned after repeated failed authentication attempts")
+	return true
+}
+
+// loadBans loads the active bans from the store and removes expired ones.
+func (s *AuthGuardService) loadBans() (map[string]AuthBan, error) {
+	values, err := s.store.HGetAll(authGuardBansKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authentication bans: %w", err)
+	}
+
+	bans := make(map[string]AuthBan, len(values))
+	var expired []string
+	now := time.Now()
+	for subject, value := range values {
+		var ban AuthBan
+		if err := json.Unmarshal([]byte(value), &ban); err != nil || !now.Before(ban.ExpiresAt) {
+			expired = append(expired, subject)
+			continue
+		}
+		bans[subject] = ban
+	}
+	if len(expired) > 0 {
+		if err := s.store.HDel(authGuardBansKey, expired...); err != nil {
+			logrus.WithError(err).Debug("Failed to remove expired authentication bans")
+		}
+	}
+	return bans, nil
+}
+
+func (s *AuthGuardService) invalidate() {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Invalidate(); err != nil {
+		logrus.WithError(err).Error("failed to invalidate auth guard cache")
+	}
+}
+
+// authSubjects returns the tracked subjects of an attempt: the source IP, and the key prefix
+// or username. Key prefixes are stored as hashes and shown in masked form.
+func authSubjects(attempt AuthAttempt) []authSubject {
+	subjects := make([]authSubject, 0, 2)
+	if attempt.IP != "" {
+		subjects = append(subjects, authSubject{
+			key:     attempt.Scope + ":ip:" + attempt.IP,
+			banType: AuthBanTypeIP,
+			value:   attempt.IP,
+		})
+	}
+
+	if attempt.Username != "" {
+		subjects = append(subjects, authSubject{
+			key:     attempt.Scope + ":user:" + strings.ToLower(attempt.Username),
+			banType: AuthBanTypeUsername,
+			value:   attempt.Username,
+		})
+	} else if attempt.Key != "" {
+		prefix := attempt.Key
+		if len(prefix) > authKeyPrefixLength {
+			prefix = prefix[:authKeyPrefixLength]
+		}
+		sum := sha256.Sum256([]byte(prefix))
+		subjects = append(subjects, authSubject{
+			key:     attempt.Scope + ":key:" + hex.EncodeToString(sum[:8]),
+			banType: AuthBanTypeKeyPrefix,
+			value:   maskKeyPrefix(prefix),
+		})
+	}
+	return subjects
+}
+
+// maskKeyPrefix shows only the start of a tracked key prefix.
+func maskKeyPrefix(prefix string) string {
+	if len(prefix) <= 8 {
+		return "****"
+	}
+	return prefix[:6] + "****"
+}
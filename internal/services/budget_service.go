@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// costMicrosPerUnit converts costs to integer counters, as the store only supports integer increments.
+const costMicrosPerUnit = 1_000_000
+
+// budgetCounterGrace keeps counters around for a while after their period ends.
+const budgetCounterGrace = 24 * time.Hour
+
+// BudgetStatus describes the current usage of a group against one budget rule.
+type BudgetStatus struct {
+	Period      string    `json:"period"`
+	Metric      string    `json:"metric"`
+	SoftLimit   float64   `json:"soft_limit"`
+	HardLimit   float64   `json:"hard_limit"`
+	Usage       float64   `json:"usage"`
+	SoftReached bool      `json:"soft_reached"`
+	HardReached bool      `json:"hard_reached"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+// BudgetService tracks per-group usage counters in the shared store and enforces budget rules.
+// Counters are seeded from group_hourly_stats when missing, so they survive store resets.
+type BudgetService struct {
+	db    *gorm.DB
+	store store.Store
+}
+
+// NewBudgetService creates a new BudgetService.
+func NewBudgetService(db *gorm.DB, store store.Store) *BudgetService {
+	return &BudgetService{
+		db:    db,
+		store: store,
+	}
+}
+
+// BudgetReservation is the request counted against the budgets of a group while the request is in flight.
+// Request limits are enforced on admission, token and cost limits only on usage already recorded,
+// as these are unknown until the response arrives; requests in flight may therefore overshoot them.
+type BudgetReservation struct {
+	service  *BudgetService
+	group    *models.Group
+	now      time.Time
+	reserved map[string]float64 // period -> request counter value after the reservation
+	doneOnce sync.Once
+}
+
+// ReserveBudget admits a request against the hard limits of the group.
+// The request is counted right away, so concurrent requests cannot exceed a request limit together.
+// Store failures are logged and the request is allowed, so a store outage never blocks traffic.
+func (s *BudgetService) ReserveBudget(group *models.Group) (*BudgetReservation, *app_errors.APIError) {
+	if group == nil || len(group.BudgetRuleList) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	for _, rule := range group.BudgetRuleList {
+		if rule.HardLimit <= 0 || rule.Metric == models.BudgetMetricRequests {
+			continue
+		}
+
+		usage, err := s.getUsage(group.ID, rule.Period, rule.Metric, now)
+		if err != nil {
+			logrus.WithError(err).WithField("group_name", group.Name).Warn("Failed to read budget usage, allowing request")
+			continue
+		}
+		if usage >= rule.HardLimit {
+			return nil, budgetExceededError(group, rule, now)
+		}
+	}
+
+	reservation := &BudgetReservation{
+		service:  s,
+		group:    group,
+		now:      now,
+		reserved: make(map[string]float64),
+	}
+	for _, period := range budgetPeriods(group.BudgetRuleList) {
+		// Seed the counter first, an increment on a missing key would drop the usage already persisted
+		if _, err := s.getUsage(group.ID, period, models.BudgetMetricRequests, now); err != nil {
+			logrus.WithError(err).WithField("group_name", group.Name).Warn("Failed to read budget usage, allowing request")
+			continue
+		}
+		ttl := time.Until(budgetPeriodEnd(period, now)) + budgetCounterGrace
+		val, err := s.store.IncrBy(budgetCounterKey(group.ID, period, models.BudgetMetricRequests, now), 1, ttl)
+		if err != nil {
+			logrus.WithError(err).WithField("group_name", group.Name).Warn("Failed to reserve budget, allowing request")
+			continue
+		}
+		reservation.reserved[period] = float64(val)
+	}
+
+	for _, rule := range group.BudgetRuleList {
+		if rule.HardLimit <= 0 || rule.Metric != models.BudgetMetricRequests {
+			continue
+		}
+		if value, ok := reservation.reserved[rule.Period]; ok && value > rule.HardLimit {
+			reservation.Release()
+			return nil, budgetExceededError(group, rule, now)
+		}
+	}
+
+	return reservation, nil
+}
+
+// Settle records the usage of the finished request and raises alerts when limits are crossed.
+func (r *BudgetReservation) Settle(usage *models.TokenUsage, cost float64) {
+	if r == nil {
+		return
+	}
+	r.doneOnce.Do(func() {
+		r.service.recordUsage(r, usage, cost)
+	})
+}
+
+// Release gives back the reserved request when the request ends without being settled.
+func (r *BudgetReservation) Release() {
+	if r == nil {
+		return
+	}
+	r.doneOnce.Do(func() {
+		for period := range r.reserved {
+			ttl := time.Until(budgetPeriodEnd(period, r.now)) + budgetCounterGrace
+			if _, err := r.service.store.IncrBy(budgetCounterKey(r.group.ID, period, models.BudgetMetricRequests, r.now), -1, ttl); err != nil {
+				logrus.WithError(err).WithField("group_name", r.group.Name).Error("Failed to release budget reservation")
+			}
+		}
+	})
+}
+
+// recordUsage adds a finished request to the group's counters, the request itself was counted on admission.
+func (s *BudgetService) recordUsage(r *BudgetReservation, usage *models.TokenUsage, cost float64) {
+	group := r.group
+	var tokens int64
+	if usage != nil {
+		tokens = usage.InputTokens + usage.OutputTokens
+	}
+	increments := map[string]int64{
+		models.BudgetMetricRequests: 1,
+		models.BudgetMetricTokens:   tokens,
+		models.BudgetMetricCost:     int64(cost * costMicrosPerUnit),
+	}
+
+	// Every metric of a budgeted period is counted, so rules added later start from accurate values.
+	// Counters use the admission time, so a request spanning a period boundary settles in the period it was admitted in.
+	updated := make(map[string]float64)
+	for _, period := range budgetPeriods(group.BudgetRuleList) {
+		ttl := time.Until(budgetPeriodEnd(period, r.now)) + budgetCounterGrace
+		for metric, incr := range increments {
+			if metric == models.BudgetMetricRequests {
+				if value, ok := r.reserved[period]; ok {
+					updated[period+":"+metric] = value
+					continue
+				}
+			}
+			if incr == 0 {
+				continue
+			}
+			val, err := s.store.IncrBy(budgetCounterKey(group.ID, period, metric, r.now), incr, ttl)
+			if err != nil {
+				logrus.WithError(err).WithField("group_name", group.Name).Error("Failed to update budget counter")
+				continue
+			}
+			updated[period+":"+metric] = counterToUsage(metric, val)
+		}
+	}
+
+	for _, rule := range group.BudgetRuleList {
+		value, ok := updated[rule.Period+":"+rule.Metric]
+		if !ok {
+			continue
+		}
+		if rule.SoftLimit > 0 && value >= rule.SoftLimit {
+			s.raiseAlert(group, rule, "soft", value, r.now)
+		}
+		if rule.HardLimit > 0 && value >= rule.HardLimit {
+			s.raiseAlert(group, rule, "hard", value, r.now)
+		}
+	}
+}
+
+// budgetExceededError builds the error returned when a group has reached a hard limit.
+func budgetExceededError(group *models.Group, rule models.BudgetRule, now time.Time) *app_errors.APIError {
+	return app_errors.NewAPIError(app_errors.ErrQuotaExceeded, fmt.Sprintf(
+		"Group '%s' has reached its %s %s limit of %s, resets at %s",
+		group.Name, rule.Period, rule.Metric, formatBudgetValue(rule.HardLimit),
+		budgetPeriodEnd(rule.Period, now).Format(time.RFC3339),
+	))
+}
+
+// GetBudgetStatus returns the usage of a group against each of its budget rules.
+func (s *BudgetService) GetBudgetStatus(ctx context.Context, groupID uint) ([]BudgetStatus, error) {
+	var group models.Group
+	if err := s.db.WithContext(ctx).First(&group, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
+		}
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	rules, err := parseBudgetRules(group.Budgets)
+	if err != nil {
+		return nil, NewI18nError(app_errors.ErrInternalServer, "error.process_budgets", map[string]any{"error": err.Error()})
+	}
+
+	now := time.Now()
+	statuses := make([]BudgetStatus, 0, len(rules))
+	for _, rule := range rules {
+		usage, err := s.getUsage(group.ID, rule.Period, rule.Metric, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read budget usage: %w", err)
+		}
+		statuses = append(statuses, BudgetStatus{
+			Period:      rule.Period,
+			Metric:      rule.Metric,
+			SoftLimit:   rule.SoftLimit,
+			HardLimit:   rule.HardLimit,
+			Usage:       usage,
+			SoftReached: rule.SoftLimit > 0 && usage >= rule.SoftLimit,
+			HardReached: rule.HardLimit > 0 && usage >= rule.HardLimit,
+			ResetAt:     budgetPeriodEnd(rule.Period, now),
+		})
+	}
+
+	return statuses, nil
+}
+
+// getUsage reads a counter, seeding it from the hourly statistics when it does not exist yet.
+func (s *BudgetService) getUsage(groupID uint, period, metric string, now time.Time) (float64, error) {
+	key := budgetCounterKey(groupID, period, metric, now)
+	raw, err := s.store.Get(key)
+	if errors.Is(err, store.ErrNotFound) {
+		if err := s.seedCounter(groupID, period, metric, now); err != nil {
+			return 0, err
+		}
+		raw, err = s.store.Get(key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid budget counter value for key %s: %w", key, err)
+	}
+	return counterToUsage(metric, val), nil
+}
+
+// seedCounter initializes a counter with the usage already persisted for the current period.
+func (s *BudgetService) seedCounter(groupID uint, period, metric string, now time.Time) error {
+	var result struct {
+		Requests int64
+		Tokens   int64
+		Cost     float64
+	}
+	err := s.db.Model(&models.GroupHourlyStat{}).
+		Select("COALESCE(SUM(success_count + failure_count), 0) as requests, COALESCE(SUM(input_tokens + output_tokens), 0) as tokens, COALESCE(SUM(cost), 0) as cost").
+		Where("group_id = ? AND time >= ?", groupID, budgetPeriodStart(period, now)).
+		Scan(&result).Error
+	if err != nil {
+		return fmt.Errorf("failed to load budget usage from hourly stats: %w", err)
+	}
+
+	var value int64
+	switch metric {
+	case models.BudgetMetricRequests:
+		value = result.Requests
+	case models.BudgetMetricTokens:
+		value = result.Tokens
+	case models.BudgetMetricCost:
+		value = int64(result.Cost * costMicrosPerUnit)
+	}
+
+	// SetNX keeps increments made by other nodes in the meantime
+	ttl := time.Until(budgetPeriodEnd(period, now)) + budgetCounterGrace
+	_, err = s.store.SetNX(budgetCounterKey(groupID, period, metric, now), []byte(strconv.FormatInt(value, 10)), ttl)
+	return err
+}
+
+// raiseAlert logs a budget alert once per group, rule, level and period across all nodes.
+func (s *BudgetService) raiseAlert(group *models.Group, rule models.BudgetRule, level string, value float64, now time.Time) {
+	alertKey := budgetCounterKey(group.ID, rule.Period, rule.Metric, now) + ":" + level + "_alert"
+	ttl := time.Until(budgetPeriodEnd(rule.Period, now)) + budgetCounterGrace
+	first, err := s.store.SetNX(alertKey, []byte("1"), ttl)
+	if err != nil || !first {
+		return
+	}
+
+	limit := rule.SoftLimit
+	message := "Group budget soft limit reached"
+	if level == "hard" {
+		limit = rule.HardLimit
+		message = "Group budget hard limit reached, further requests will be rejected"
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group_name": group.Name,
+		"period":     rule.Period,
+		"metric":     rule.Metric,
+		"limit":      formatBudgetValue(limit),
+		"usage":      formatBudgetValue(value),
+	}).Warn(message)
+}
+
+// parseBudgetRules decodes the budgets column of a group.
+func parseBudgetRules(raw []byte) ([]models.BudgetRule, error) {
+	rules := make([]models.BudgetRule, 0)
+	if len(raw) == 0 {
+		return rules, nil
+	}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// budgetPeriods returns the distinct periods used by the rules.
+func budgetPeriods(rules []models.BudgetRule) []string {
+	periods := make([]string, 0, 2)
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if !seen[rule.Period] {
+			seen[rule.Period] = true
+			periods = append(periods, rule.Period)
+		}
+	}
+	return periods
+}
+
+// budgetCounterKey builds the store key of a counter for the period containing now.
+func budgetCounterKey(groupID uint, period, metric string, now time.Time) string {
+	layout := "20060102"
+	if period == models.BudgetPeriodMonthly {
+		layout = "200601"
+	}
+	return fmt.Sprintf("budget:%d:%s:%s:%s", groupID, period, now.Format(layout), metric)
+}
+
+// budgetPeriodStart returns the start of the period containing now, in server local time.
+func budgetPeriodStart(period string, now time.Time) time.Time {
+	if period == models.BudgetPeriodMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// budgetPeriodEnd returns the end of the period containing now, which is also when the budget resets.
+func budgetPeriodEnd(period string, now time.Time) time.Time {
+	start := budgetPeriodStart(period, now)
+	if period == models.BudgetPeriodMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// counterToUsage converts a raw counter value to the unit of its metric.
+func counterToUsage(metric string, val int64) float64 {
+	if metric == models.BudgetMetricCost {
+		return float64(val) / costMicrosPerUnit
+	}
+	return float64(val)
+}
+
+func formatBudgetValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+)
+
+func newTestBudgetService(t *testing.T) *BudgetService {
+	t.Helper()
+	return NewBudgetService(newTestDB(t, &models.GroupHourlyStat{}), store.NewMemoryStore())
+}
+
+func budgetGroup(rules ...models.BudgetRule) *models.Group {
+	return &models.Group{ID: 1, Name: "test", BudgetRuleList: rules}
+}
+
+func budgetCounter(t *testing.T, s *BudgetService, period, metric string) float64 {
+	t.Helper()
+	raw, err := s.store.Get(budgetCounterKey(1, period, metric, time.Now()))
+	if err != nil {
+		t.Fatalf("failed to read %s %s counter: %v", period, metric, err)
+	}
+	val, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counterToUsage(metric, val)
+}
+
+func TestReserveBudget(t *testing.T) {
+	daily := func(metric string, hard float64) models.BudgetRule {
+		return models.BudgetRule{Period: models.BudgetPeriodDaily, Metric: metric, HardLimit: hard}
+	}
+
+	tests := []struct {
+		name     string
+		rules    []models.BudgetRule
+		requests int
+		settle   bool // Settle each admitted request instead of keeping it in flight
+		tokens   int64
+		cost     float64
+		wantOK   int
+	}{
+		{name: "request limit counts requests in flight", rules: []models.BudgetRule{daily(models.BudgetMetricRequests, 3)}, requests: 5, wantOK: 3},
+		{name: "request limit counts settled requests", rules: []models.BudgetRule{daily(models.BudgetMetricRequests, 3)}, requests: 5, settle: true, wantOK: 3},
+		{name: "token limit applies to settled usage", rules: []models.BudgetRule{daily(models.BudgetMetricTokens, 100)}, requests: 5, settle: true, tokens: 40, wantOK: 3},
+		{name: "token limit ignores requests in flight", rules: []models.BudgetRule{daily(models.BudgetMetricTokens, 100)}, requests: 5, wantOK: 5},
+		{name: "cost limit", rules: []models.BudgetRule{daily(models.BudgetMetricCost, 1)}, requests: 5, settle: true, cost: 0.3, wantOK: 4},
+		{name: "soft limits never reject", rules: []models.BudgetRule{{Period: models.BudgetPeriodDaily, Metric: models.BudgetMetricRequests, SoftLimit: 1}}, requests: 3, wantOK: 3},
+		{name: "strictest period wins", rules: []models.BudgetRule{
+			{Period: models.BudgetPeriodMonthly, Metric: models.BudgetMetricRequests, HardLimit: 2},
+			daily(models.BudgetMetricRequests, 10),
+		}, requests: 4, settle: true, wantOK: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestBudgetService(t)
+			group := budgetGroup(tt.rules...)
+
+			ok := 0
+			for i := 0; i < tt.requests; i++ {
+				reservation, apiErr := s.ReserveBudget(group)
+				if apiErr != nil {
+					continue
+				}
+				ok++
+				if tt.settle {
+					reservation.Settle(&models.TokenUsage{InputTokens: tt.tokens / 2, OutputTokens: tt.tokens - tt.tokens/2}, tt.cost)
+				}
+			}
+			if ok != tt.wantOK {
+				t.Errorf("admitted %d of %d requests, want %d", ok, tt.requests, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBudgetReservationSettleAndRelease(t *testing.T) {
+	s := newTestBudgetService(t)
+	group := budgetGroup(models.BudgetRule{Period: models.BudgetPeriodDaily, Metric: models.BudgetMetricRequests, HardLimit: 10})
+
+	settled, apiErr := s.ReserveBudget(group)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	released, apiErr := s.ReserveBudget(group)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if got := budgetCounter(t, s, models.BudgetPeriodDaily, models.BudgetMetricRequests); got != 2 {
+		t.Fatalf("requests after two reservations = %v, want 2", got)
+	}
+
+	// A settled request stays counted once, however often it is settled or released afterwards
+	settled.Settle(&models.TokenUsage{InputTokens: 10, OutputTokens: 5}, 0.25)
+	settled.Settle(&models.TokenUsage{InputTokens: 10, OutputTokens: 5}, 0.25)
+	settled.Release()
+	// A released request is no longer counted, and releasing it again does nothing
+	released.Release()
+	released.Release()
+	released.Settle(&models.TokenUsage{InputTokens: 100}, 1)
+
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metric: models.BudgetMetricRequests, want: 1},
+		{metric: models.BudgetMetricTokens, want: 15},
+		{metric: models.BudgetMetricCost, want: 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			if got := budgetCounter(t, s, models.BudgetPeriodDaily, tt.metric); got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.metric, got, tt.want)
+			}
+		})
+	}
+
+	var nilReservation *BudgetReservation
+	nilReservation.Settle(nil, 0)
+	nilReservation.Release()
+}
+
+func TestReserveBudgetSeedsFromHourlyStats(t *testing.T) {
+	s := newTestBudgetService(t)
+	now := time.Now()
+	stats := []models.GroupHourlyStat{
+		{GroupID: 1, Time: now.Truncate(time.Hour), SuccessCount: 4, FailureCount: 1, InputTokens: 70, OutputTokens: 30, Cost: 0.5},
+		// Other groups and earlier periods are not counted
+		{GroupID: 2, Time: now.Truncate(time.Hour), SuccessCount: 100},
+		{GroupID: 1, Time: budgetPeriodStart(models.BudgetPeriodMonthly, now).AddDate(0, -1, 0), SuccessCount: 100},
+	}
+	if err := s.db.Create(&stats).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		rule   models.BudgetRule
+		wantOK bool
+	}{
+		{name: "requests below limit", rule: models.BudgetRule{Period: models.BudgetPeriodDaily, Metric: models.BudgetMetricRequests, HardLimit: 6}, wantOK: true},
+		{name: "requests at limit", rule: models.BudgetRule{Period: models.BudgetPeriodDaily, Metric: models.BudgetMetricRequests, HardLimit: 5}},
+		{name: "tokens at limit", rule: models.BudgetRule{Period: models.BudgetPeriodMonthly, Metric: models.BudgetMetricTokens, HardLimit: 100}},
+		{name: "cost below limit", rule: models.BudgetRule{Period: models.BudgetPeriodMonthly, Metric: models.BudgetMetricCost, HardLimit: 0.6}, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.store = store.NewMemoryStore()
+			reservation, apiErr := s.ReserveBudget(budgetGroup(tt.rule))
+			if (apiErr == nil) != tt.wantOK {
+				t.Errorf("ReserveBudget admitted = %v, want %v (error %v)", apiErr == nil, tt.wantOK, apiErr)
+			}
+			reservation.Release()
+		})
+	}
+}
+
+func TestBudgetPeriods(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2024, 2, 29, 23, 30, 0, 0, loc)
+
+	tests := []struct {
+		period    string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantKey   string
+	}{
+		{period: models.BudgetPeriodDaily, wantStart: time.Date(2024, 2, 29, 0, 0, 0, 0, loc), wantEnd: time.Date(2024, 3, 1, 0, 0, 0, 0, loc), wantKey: "budget:1:daily:20240229:cost"},
+		{period: models.BudgetPeriodMonthly, wantStart: time.Date(2024, 2, 1, 0, 0, 0, 0, loc), wantEnd: time.Date(2024, 3, 1, 0, 0, 0, 0, loc), wantKey: "budget:1:monthly:202402:cost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			if got := budgetPeriodStart(tt.period, now); !got.Equal(tt.wantStart) {
+				t.Errorf("budgetPeriodStart = %s, want %s", got, tt.wantStart)
+			}
+			if got := budgetPeriodEnd(tt.period, now); !got.Equal(tt.wantEnd) {
+				t.Errorf("budgetPeriodEnd = %s, want %s", got, tt.wantEnd)
+			}
+			if got := budgetCounterKey(1, tt.period, models.BudgetMetricCost, now); got != tt.wantKey {
+				t.Errorf("budgetCounterKey = %s, want %s", got, tt.wantKey)
+			}
+		})
+	}
+}
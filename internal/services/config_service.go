@@ -0,0 +1,1320 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/encryption"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/i18n"
+	"gpt-load/internal/models"
+	"gpt-load/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ConfigDocumentVersion is the version of the declarative configuration format.
+const ConfigDocumentVersion = 1
+
+// Config document formats.
+const (
+	ConfigFormatYAML = "yaml"
+	ConfigFormatJSON = "json"
+)
+
+// Config change actions and kinds.
+const (
+	ConfigActionCreate = "create"
+	ConfigActionUpdate = "update"
+	ConfigActionDelete = "delete"
+
+	ConfigKindSetting  = "setting"
+	ConfigKindGroup    = "group"
+	ConfigKindSubGroup = "sub_group"
+	ConfigKindKeys     = "keys"
+)
+
+// groupStateFields are the GroupConfig fields applied through UpdateGroupState.
+var groupStateFields = map[string]bool{"state": true, "state_message": true, "state_status_code": true}
+
+// ConfigDocument is the declarative configuration of system settings, groups and aggregate membership.
+type ConfigDocument struct {
+	Version  int            `json:"version"`
+	Settings map[string]any `json:"settings,omitempty"`
+	Groups   []GroupConfig  `json:"groups"`
+}
+
+// GroupConfig declares a group. Groups are matched by name, and omitted fields take their empty value.
+type GroupConfig struct {
+	Name                string              `json:"name"`
+	DisplayName         string              `json:"display_name,omitempty"`
+	Description         string              `json:"description,omitempty"`
+	GroupType           string              `json:"group_type,omitempty"` // "aggregate", or empty for standard groups
+	ChannelType         string              `json:"channel_type"`
+	Sort                int                 `json:"sort,omitempty"`
+	TestModel           string              `json:"test_model,omitempty"`
+	ValidationEndpoint  string              `json:"validation_endpoint,omitempty"`
+	Upstreams           json.RawMessage     `json:"upstreams,omitempty"`
+	ParamOverrides      map[string]any      `json:"param_overrides,omitempty"`
+	Config              map[string]any      `json:"config,omitempty"`
+	HeaderRules         []models.HeaderRule `json:"header_rules,omitempty"`
+	ModelRedirectRules  map[string]string   `json:"model_redirect_rules,omitempty"`
+	ModelRedirectStrict bool                `json:"model_redirect_strict,omitempty"`
+	Budgets             []models.BudgetRule `json:"budgets,omitempty"`
+	State               string              `json:"state,omitempty"` // empty for enabled groups
+	StateMessage        string              `json:"state_message,omitempty"`
+	StateStatusCode     int                 `json:"state_status_code,omitempty"`
+	SubGroups           []SubGroupConfig    `json:"sub_groups,omitempty"`
+	Keys                []ConfigKey         `json:"keys,omitempty"` // Keys are only reconciled for groups that list them
+}
+
+// SubGroupConfig declares a member of an aggregate group by the name of the sub-group.
+type SubGroupConfig struct {
+	Group          string                 `json:"group"`
+	Weight         int                    `json:"weight"`
+	Priority       int                    `json:"priority,omitempty"`
+	Models         []string               `json:"models,omitempty"`
+	ActiveSchedule *models.ActiveSchedule `json:"active_schedule,omitempty"`
+}
+
+// ConfigKey declares an API key by its encrypted value, which can only be read by instances
+// sharing the encryption configuration.
+type ConfigKey struct {
+	EncryptedKey string            `json:"encrypted_key"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Priority     int               `json:"priority,omitempty"`
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty"`
+	RPMLimit     int               `json:"rpm_limit,omitempty"`
+}
+
+// ConfigChange is one difference between a configuration document and the database.
+// For updates, Before and After hold the changed values only; for creations, After holds the declared values.
+type ConfigChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+	Count  int    `json:"count,omitempty"` // Number of keys for key changes
+}
+
+// ConfigPlan lists the changes needed to make the database match a configuration document.
+type ConfigPlan struct {
+	DryRun  bool           `json:"dry_run"`
+	Prune   bool           `json:"prune"`
+	Changes []ConfigChange `json:"changes"`
+}
+
+// ConfigGroupError reports the group of a configuration document that is invalid or could not be applied.
+type ConfigGroupError struct {
+	Group string
+	Err   error
+}
+
+// Error implements the error interface, with the message of the underlying error in English.
+func (e *ConfigGroupError) Error() string {
+	return fmt.Sprintf("group '%s': %s", e.Group, ConfigErrorMessage(e.Err))
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigGroupError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrorMessage returns the English message of an error from reading or applying a configuration
+// document, for use outside of HTTP requests.
+func ConfigErrorMessage(err error) string {
+	var groupErr *ConfigGroupError
+	if errors.As(err, &groupErr) {
+		return groupErr.Error()
+	}
+	var i18nErr *I18nError
+	if errors.As(err, &i18nErr) {
+		return i18n.T(i18n.GetLocalizer("en-US"), i18nErr.MessageID, i18nErr.Template)
+	}
+	return err.Error()
+}
+
+// ConfigService exports the configuration as a document and reconciles the database with one.
+type ConfigService struct {
+	db                    *gorm.DB
+	configManager         types.ConfigManager
+	settingsManager       *config.SystemSettingsManager
+	groupService          *GroupService
+	aggregateGroupService *AggregateGroupService
+	keyService            *KeyService
+	auditService          *AuditService
+	mu                    sync.Mutex
+}
+
+// NewConfigService creates a new ConfigService.
+func NewConfigService(
+	db *gorm.DB,
+	configManager types.ConfigManager,
+	settingsManager *config.SystemSettingsManager,
+	groupService *GroupService,
+	aggregateGroupService *AggregateGroupService,
+	keyService *KeyService,
+	auditService *AuditService,
+) *ConfigService {
+	return &ConfigService{
+		db:                    db,
+		configManager:         configManager,
+		settingsManager:       settingsManager,
+		groupService:          groupService,
+		aggregateGroupService: aggregateGroupService,
+		keyService:            keyService,
+		auditService:          auditService,
+	}
+}
+
+// ParseConfigDocument reads a configuration document in YAML or JSON.
+func ParseConfigDocument(data []byte) (*ConfigDocument, error) {
+	// JSON is valid YAML, and going through JSON applies the same decoding as the API
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_config_document", map[string]any{"error": err.Error()})
+	}
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_config_document", map[string]any{"error": err.Error()})
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	var doc ConfigDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_config_document", map[string]any{"error": err.Error()})
+	}
+	if doc.Version != ConfigDocumentVersion {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.unsupported_config_version", map[string]any{"version": doc.Version})
+	}
+	return &doc, nil
+}
+
+// MarshalConfigDocument writes a configuration document as YAML or JSON.
+func MarshalConfigDocument(doc *ConfigDocument, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format == ConfigFormatJSON {
+		return append(data, '\n'), nil
+	}
+
+	// Convert through a node tree to keep the field order of the JSON encoding
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetYAMLStyle drops the flow and quoting style inherited from JSON so the document is written in block style.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// Export returns the current settings and groups as a configuration document.
+// Keys are only included on request, and only when they are stored encrypted.
+func (s *ConfigService) Export(ctx context.Context, includeKeys bool) (*ConfigDocument, error) {
+	if includeKeys && !s.encryptionEnabled() {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.config_keys_require_encryption", nil)
+	}
+
+	settings, err := s.currentSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.currentGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ConfigDocument{
+		Version:  ConfigDocumentVersion,
+		Settings: settings,
+		Groups:   make([]GroupConfig, 0, len(groups)),
+	}
+	for _, current := range groups {
+		gc := current.config
+		if includeKeys && gc.GroupType != "aggregate" {
+			keys, err := s.exportKeys(ctx, current.id)
+			if err != nil {
+				return nil, err
+			}
+			gc.Keys = keys
+		}
+		doc.Groups = append(doc.Groups, gc)
+	}
+	return doc, nil
+}
+
+// Plan returns the changes that applying the document would make, without making them.
+func (s *ConfigService) Plan(ctx context.Context, doc *ConfigDocument, prune bool) (*ConfigPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.plan(ctx, doc, prune)
+	if err != nil {
+		return nil, err
+	}
+	return p.result(true), nil
+}
+
+// Apply makes the database match the document. Groups and settings missing from the document
+// are kept unless prune is set. Changes are applied in one transaction, so an error leaves the database unchanged.
+func (s *ConfigService) Apply(ctx context.Context, doc *ConfigDocument, prune bool) (*ConfigPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.plan(ctx, doc, prune)
+	if err != nil {
+		return nil, err
+	}
+	result := p.result(false)
+	if len(result.Changes) == 0 {
+		return result, nil
+	}
+
+	if err := s.apply(ctx, p); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "config.import",
+		TargetType: models.AuditTargetSettings,
+		TargetName: "config",
+		After:      map[string]any{"prune": prune, "changes": result.Changes},
+	})
+
+	return result, nil
+}
+
+// ReconcileFile applies the configuration document at path, as done on startup.
+func (s *ConfigService) ReconcileFile(ctx context.Context, path string, prune bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	doc, err := ParseConfigDocument(data)
+	if err != nil {
+		return errors.New(ConfigErrorMessage(err))
+	}
+
+	result, err := s.Apply(ctx, doc, prune)
+	if err != nil {
+		return errors.New(ConfigErrorMessage(err))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file":    path,
+		"prune":   prune,
+		"changes": len(result.Changes),
+	}).Info("Reconciled database with config file")
+	return nil
+}
+
+func (s *ConfigService) encryptionEnabled() bool {
+	return s.configManager.GetEncryptionKey() != "" || s.configManager.GetEncryptionConfig().Provider != ""
+}
+
+// currentSettings returns the system settings as a map, without proxy keys which are only stored as hashes.
+func (s *ConfigService) currentSettings() (map[string]any, error) {
+	data, err := json.Marshal(s.settingsManager.GetSettings())
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]any)
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	delete(settings, "proxy_keys")
+	return settings, nil
+}
+
+// currentGroup is a group of the database with its configuration.
+type currentGroup struct {
+	id     uint
+	config GroupConfig
+}
+
+// currentGroups returns the configuration of all groups in the database, in display order.
+func (s *ConfigService) currentGroups(ctx context.Context) ([]currentGroup, error) {
+	var groups []models.Group
+	if err := s.db.WithContext(ctx).Order("sort asc, id asc").Find(&groups).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	var links []models.GroupSubGroup
+	if err := s.db.WithContext(ctx).Order("priority asc, id asc").Find(&links).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	names := make(map[uint]string, len(groups))
+	for _, group := range groups {
+		names[group.ID] = group.Name
+	}
+	members := make(map[uint][]SubGroupConfig)
+	for _, link := range links {
+		name, ok := names[link.SubGroupID]
+		if !ok {
+			continue
+		}
+		member := SubGroupConfig{
+			Group:          name,
+			Weight:         link.Weight,
+			Priority:       link.Priority,
+			ActiveSchedule: parseSubGroupSchedule(link.Schedule),
+		}
+		if len(link.Models) > 0 {
+			if err := json.Unmarshal(link.Models, &member.Models); err != nil {
+				logrus.WithError(err).WithField("group_id", link.GroupID).Warn("Failed to parse sub-group models")
+			}
+		}
+		members[link.GroupID] = append(members[link.GroupID], member)
+	}
+
+	result := make([]currentGroup, 0, len(groups))
+	for i := range groups {
+		gc := groupConfigFromModel(&groups[i])
+		gc.SubGroups = members[groups[i].ID]
+		result = append(result, currentGroup{id: groups[i].ID, config: gc})
+	}
+	return result, nil
+}
+
+// groupConfigFromModel converts a stored group to its configuration, leaving out defaults.
+func groupConfigFromModel(group *models.Group) GroupConfig {
+	gc := GroupConfig{
+		Name:                group.Name,
+		DisplayName:         group.DisplayName,
+		Description:         group.Description,
+		ChannelType:         group.ChannelType,
+		Sort:                group.Sort,
+		ModelRedirectStrict: group.ModelRedirectStrict,
+		StateMessage:        group.StateMessage,
+		StateStatusCode:     group.StateStatusCode,
+	}
+	if group.GroupType == "aggregate" {
+		gc.GroupType = "aggregate"
+	} else {
+		gc.TestModel = group.TestModel
+		gc.ValidationEndpoint = group.ValidationEndpoint
+		gc.Upstreams = json.RawMessage(group.Upstreams)
+	}
+	if !group.IsEnabled() {
+		gc.State = group.State
+	}
+	if len(group.ParamOverrides) > 0 {
+		gc.ParamOverrides = group.ParamOverrides
+	}
+	if len(group.Config) > 0 {
+		gc.Config = group.Config
+	}
+	if len(group.ModelRedirectRules) > 0 {
+		gc.ModelRedirectRules = make(map[string]string, len(group.ModelRedirectRules))
+		for from, to := range group.ModelRedirectRules {
+			gc.ModelRedirectRules[from] = fmt.Sprint(to)
+		}
+	}
+	if len(group.HeaderRules) > 0 {
+		if err := json.Unmarshal(group.HeaderRules, &gc.HeaderRules); err != nil {
+			logrus.WithError(err).WithField("group_name", group.Name).Warn("Failed to parse header rules for export")
+		}
+	}
+	if len(group.Budgets) > 0 {
+		if err := json.Unmarshal(group.Budgets, &gc.Budgets); err != nil {
+			logrus.WithError(err).WithField("group_name", group.Name).Warn("Failed to parse budget rules for export")
+		}
+	}
+	if len(gc.HeaderRules) == 0 {
+		gc.HeaderRules = nil
+	}
+	if len(gc.Budgets) == 0 {
+		gc.Budgets = nil
+	}
+	return gc
+}
+
+// exportKeys returns the keys of a group with their stored, encrypted values.
+func (s *ConfigService) exportKeys(ctx context.Context, groupID uint) ([]ConfigKey, error) {
+	var keys []models.APIKey
+	if err := s.db.WithContext(ctx).Where("group_id = ?", groupID).Order("id asc").Find(&keys).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	result := make([]ConfigKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, ConfigKey{
+			EncryptedKey: key.KeyValue,
+			Tags:         keyTagsFromJSON(key.Tags),
+			Priority:     key.Priority,
+			ExpiresAt:    key.ExpiresAt,
+			RPMLimit:     key.RPMLimit,
+		})
+	}
+	return result, nil
+}
+
+func keyTagsFromJSON(tags map[string]any) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(tags))
+	for name, value := range tags {
+		result[name] = fmt.Sprint(value)
+	}
+	return result
+}
+
+// configPlan holds the changes of a document together with what is needed to apply them.
+type configPlan struct {
+	prune     bool
+	settings  map[string]any
+	groups    []groupPlan
+	deletions []currentGroup
+	changes   []ConfigChange
+}
+
+// groupPlan holds the changes of one group of the document.
+type groupPlan struct {
+	desired      GroupConfig
+	create       bool
+	updateFields bool
+	updateState  bool
+	subGroups    []subGroupPlan
+	keysToAdd    []KeyEntry
+	keysToUpdate map[uint]KeyEntry
+	keysToRemove []uint
+}
+
+// subGroupPlan holds the change of one member of an aggregate group.
+type subGroupPlan struct {
+	action  string
+	desired SubGroupConfig
+	fields  map[string]bool
+}
+
+func (p *configPlan) result(dryRun bool) *ConfigPlan {
+	changes := p.changes
+	if changes == nil {
+		changes = []ConfigChange{}
+	}
+	return &ConfigPlan{DryRun: dryRun, Prune: p.prune, Changes: changes}
+}
+
+// plan validates the document and compares it with the database.
+func (s *ConfigService) plan(ctx context.Context, doc *ConfigDocument, prune bool) (*configPlan, error) {
+	p := &configPlan{prune: prune}
+
+	if err := s.planSettings(p, doc.Settings); err != nil {
+		return nil, err
+	}
+
+	current, err := s.currentGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentByName := make(map[string]currentGroup, len(current))
+	for _, group := range current {
+		currentByName[group.config.Name] = group
+	}
+
+	desiredByName := make(map[string]GroupConfig, len(doc.Groups))
+	desired := make([]GroupConfig, 0, len(doc.Groups))
+	for _, gc := range doc.Groups {
+		name := strings.TrimSpace(gc.Name)
+		if err := s.normalizeGroupConfig(&gc); err != nil {
+			return nil, &ConfigGroupError{Group: name, Err: err}
+		}
+		if _, exists := desiredByName[gc.Name]; exists {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.config_duplicate_group", map[string]any{"group": gc.Name})
+		}
+		desiredByName[gc.Name] = gc
+		desired = append(desired, gc)
+	}
+
+	// Standard groups go first so aggregate groups can reference newly created ones
+	sort.SliceStable(desired, func(i, j int) bool {
+		return desired[i].GroupType != "aggregate" && desired[j].GroupType == "aggregate"
+	})
+
+	for _, gc := range desired {
+		existing, exists := currentByName[gc.Name]
+		if exists && existing.config.GroupType != gc.GroupType {
+			return nil, &ConfigGroupError{Group: gc.Name, Err: NewI18nError(app_errors.ErrValidation, "validation.config_group_type_changed", nil)}
+		}
+
+		gp := groupPlan{desired: gc, create: !exists}
+		if !exists {
+			p.changes = append(p.changes, ConfigChange{Action: ConfigActionCreate, Kind: ConfigKindGroup, Target: gc.Name, After: configFields(gc)})
+			gp.updateState = gc.State != ""
+		} else if before, after := diffConfigFields(existing.config, gc); len(after) > 0 {
+			p.changes = append(p.changes, ConfigChange{Action: ConfigActionUpdate, Kind: ConfigKindGroup, Target: gc.Name, Before: before, After: after})
+			for field := range after {
+				if groupStateFields[field] {
+					gp.updateState = true
+				} else {
+					gp.updateFields = true
+				}
+			}
+		}
+
+		if gc.GroupType == "aggregate" {
+			if err := s.planSubGroups(p, &gp, existing.config.SubGroups, desiredByName, currentByName); err != nil {
+				return nil, &ConfigGroupError{Group: gc.Name, Err: err}
+			}
+		}
+
+		if gc.Keys != nil {
+			var groupID uint
+			if exists {
+				groupID = existing.id
+			}
+			if err := s.planKeys(ctx, p, &gp, groupID); err != nil {
+				return nil, &ConfigGroupError{Group: gc.Name, Err: err}
+			}
+		}
+
+		p.groups = append(p.groups, gp)
+	}
+
+	if prune {
+		for _, group := range current {
+			if _, exists := desiredByName[group.config.Name]; !exists {
+				p.deletions = append(p.deletions, group)
+				p.changes = append(p.changes, ConfigChange{Action: ConfigActionDelete, Kind: ConfigKindGroup, Target: group.config.Name})
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// planSettings validates the settings of the document and records those that differ.
+func (s *ConfigService) planSettings(p *configPlan, desired map[string]any) error {
+	if len(desired) == 0 {
+		return nil
+	}
+
+	current, err := s.currentSettings()
+	if err != nil {
+		return err
+	}
+	for key := range desired {
+		if _, known := current[key]; !known {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_settings", map[string]any{"error": fmt.Sprintf("unknown setting '%s'", key)})
+		}
+	}
+	if err := s.settingsManager.ValidateSettings(desired); err != nil {
+		return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_settings", map[string]any{"error": err.Error()})
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// Settings are stored as text, so values are compared the same way
+		if fmt.Sprint(desired[key]) == fmt.Sprint(current[key]) {
+			continue
+		}
+		if p.settings == nil {
+			p.settings = make(map[string]any)
+		}
+		p.settings[key] = desired[key]
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionUpdate, Kind: ConfigKindSetting, Target: key, Before: current[key], After: desired[key]})
+	}
+	return nil
+}
+
+// normalizeGroupConfig validates a group of the document and brings it to the form it is stored in,
+// so it can be compared with the exported configuration.
+func (s *ConfigService) normalizeGroupConfig(gc *GroupConfig) error {
+	gs := s.groupService
+
+	gc.Name = strings.TrimSpace(gc.Name)
+	if !isValidGroupName(gc.Name) {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_group_name", nil)
+	}
+	gc.DisplayName = strings.TrimSpace(gc.DisplayName)
+	gc.Description = strings.TrimSpace(gc.Description)
+
+	gc.GroupType = strings.TrimSpace(gc.GroupType)
+	if gc.GroupType == "standard" {
+		gc.GroupType = ""
+	}
+	if gc.GroupType != "" && gc.GroupType != "aggregate" {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_group_type", nil)
+	}
+
+	gc.ChannelType = strings.TrimSpace(gc.ChannelType)
+	if !gs.isValidChannelType(gc.ChannelType) {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": strings.Join(gs.channelRegistry, ", ")})
+	}
+
+	if gc.GroupType == "aggregate" {
+		gc.TestModel = ""
+		gc.ValidationEndpoint = ""
+		gc.Upstreams = nil
+		if len(gc.ModelRedirectRules) > 0 {
+			return NewI18nError(app_errors.ErrValidation, "validation.aggregate_no_model_redirect", nil)
+		}
+		if gc.Keys != nil {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_aggregate_keys", nil)
+		}
+	} else {
+		gc.TestModel = strings.TrimSpace(gc.TestModel)
+		if gc.TestModel == "" {
+			return NewI18nError(app_errors.ErrValidation, "validation.test_model_required", nil)
+		}
+		upstreams, err := gs.validateAndCleanUpstreams(gc.Upstreams)
+		if err != nil {
+			return err
+		}
+		gc.Upstreams = json.RawMessage(upstreams)
+		gc.ValidationEndpoint = strings.TrimSpace(gc.ValidationEndpoint)
+		if !isValidValidationEndpoint(gc.ValidationEndpoint) {
+			return NewI18nError(app_errors.ErrValidation, "validation.invalid_test_path", nil)
+		}
+		if len(gc.SubGroups) > 0 {
+			return NewI18nError(app_errors.ErrValidation, "group.not_aggregate", nil)
+		}
+	}
+
+	if err := validateModelRedirectRules(gc.ModelRedirectRules); err != nil {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
+	}
+	if len(gc.ModelRedirectRules) == 0 {
+		gc.ModelRedirectRules = nil
+	}
+	if len(gc.ParamOverrides) == 0 {
+		gc.ParamOverrides = nil
+	}
+
+	cleanedConfig, err := gs.validateAndCleanConfig(gc.Config)
+	if err != nil {
+		return err
+	}
+	gc.Config = nil
+	if len(cleanedConfig) > 0 {
+		gc.Config = cleanedConfig
+	}
+
+	headerRules, err := gs.normalizeHeaderRules(gc.HeaderRules)
+	if err != nil {
+		return err
+	}
+	gc.HeaderRules = nil
+	if headerRules != nil {
+		if err := json.Unmarshal(headerRules, &gc.HeaderRules); err != nil {
+			return err
+		}
+	}
+
+	budgets, err := gs.normalizeBudgetRules(gc.Budgets)
+	if err != nil {
+		return err
+	}
+	gc.Budgets = nil
+	if err := json.Unmarshal(budgets, &gc.Budgets); err != nil {
+		return err
+	}
+	if len(gc.Budgets) == 0 {
+		gc.Budgets = nil
+	}
+
+	state := gc.State
+	if state == "" {
+		state = models.GroupStateEnabled
+	}
+	stateParams, err := normalizeGroupStateParams(GroupStateParams{State: state, Message: gc.StateMessage, StatusCode: gc.StateStatusCode})
+	if err != nil {
+		return err
+	}
+	gc.State = stateParams.State
+	if gc.State == models.GroupStateEnabled {
+		gc.State = ""
+	}
+	gc.StateMessage = stateParams.Message
+
+	seen := make(map[string]bool, len(gc.SubGroups))
+	for i := range gc.SubGroups {
+		member := &gc.SubGroups[i]
+		member.Group = strings.TrimSpace(member.Group)
+		if member.Group == "" || seen[member.Group] {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_sub_group", map[string]any{"sub_group": member.Group})
+		}
+		seen[member.Group] = true
+		if member.Weight < 0 {
+			return NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_negative", nil)
+		}
+		if member.Weight > 1000 {
+			return NewI18nError(app_errors.ErrValidation, "validation.sub_group_weight_max_exceeded", nil)
+		}
+		if err := validateSubGroupPriority(member.Priority); err != nil {
+			return err
+		}
+		modelsJSON, err := normalizeSubGroupModels(member.Models)
+		if err != nil {
+			return err
+		}
+		member.Models = nil
+		if len(modelsJSON) > 0 {
+			if err := json.Unmarshal(modelsJSON, &member.Models); err != nil {
+				return err
+			}
+		}
+		scheduleJSON, err := normalizeSubGroupSchedule(member.ActiveSchedule)
+		if err != nil {
+			return err
+		}
+		member.ActiveSchedule = parseSubGroupSchedule(scheduleJSON)
+	}
+
+	return nil
+}
+
+// planSubGroups compares the members of an aggregate group. Membership always matches the document.
+func (s *ConfigService) planSubGroups(p *configPlan, gp *groupPlan, current []SubGroupConfig, desiredByName map[string]GroupConfig, currentByName map[string]currentGroup) error {
+	aggregate := gp.desired.Name
+	currentMembers := make(map[string]SubGroupConfig, len(current))
+	for _, member := range current {
+		currentMembers[member.Group] = member
+	}
+
+	for _, member := range gp.desired.SubGroups {
+		// Members must be standard groups that remain after the import
+		groupType := ""
+		if gc, ok := desiredByName[member.Group]; ok {
+			groupType = gc.GroupType
+		} else if existing, ok := currentByName[member.Group]; ok && !p.prune {
+			groupType = existing.config.GroupType
+		} else {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_sub_group", map[string]any{"sub_group": member.Group})
+		}
+		if groupType == "aggregate" {
+			return NewI18nError(app_errors.ErrValidation, "validation.sub_group_cannot_be_aggregate", nil)
+		}
+
+		target := aggregate + "/" + member.Group
+		existing, exists := currentMembers[member.Group]
+		if !exists {
+			gp.subGroups = append(gp.subGroups, subGroupPlan{action: ConfigActionCreate, desired: member})
+			p.changes = append(p.changes, ConfigChange{Action: ConfigActionCreate, Kind: ConfigKindSubGroup, Target: target, After: configFields(member)})
+			continue
+		}
+		before, after := diffConfigFields(existing, member)
+		if len(after) == 0 {
+			continue
+		}
+		fields := make(map[string]bool, len(after))
+		for field := range after {
+			fields[field] = true
+		}
+		gp.subGroups = append(gp.subGroups, subGroupPlan{action: ConfigActionUpdate, desired: member, fields: fields})
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionUpdate, Kind: ConfigKindSubGroup, Target: target, Before: before, After: after})
+	}
+
+	desiredMembers := make(map[string]bool, len(gp.desired.SubGroups))
+	for _, member := range gp.desired.SubGroups {
+		desiredMembers[member.Group] = true
+	}
+	for _, member := range current {
+		if desiredMembers[member.Group] {
+			continue
+		}
+		gp.subGroups = append(gp.subGroups, subGroupPlan{action: ConfigActionDelete, desired: member})
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionDelete, Kind: ConfigKindSubGroup, Target: aggregate + "/" + member.Group})
+	}
+	return nil
+}
+
+// planKeys compares the keys listed for a group with the keys it holds. Keys are matched by value;
+// the keys of a group that are not listed are only removed when pruning.
+func (s *ConfigService) planKeys(ctx context.Context, p *configPlan, gp *groupPlan, groupID uint) error {
+	if !s.encryptionEnabled() {
+		return NewI18nError(app_errors.ErrValidation, "validation.config_keys_require_encryption", nil)
+	}
+
+	entries := make([]KeyEntry, 0, len(gp.desired.Keys))
+	for i, key := range gp.desired.Keys {
+		value, err := s.keyService.EncryptionSvc.Decrypt(strings.TrimSpace(key.EncryptedKey))
+		if err != nil || value == "" {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_key_decrypt_failed", map[string]any{"index": i + 1})
+		}
+		entry := KeyEntry{Key: value, Tags: key.Tags, Priority: key.Priority, ExpiresAt: key.ExpiresAt, RPMLimit: key.RPMLimit}
+		if err := validateKeyEntry(&entry); err != nil {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_key", map[string]any{"index": i + 1, "error": err.Error()})
+		}
+		entries = append(entries, entry)
+	}
+	// Encrypted values differ on every export, so the plan carries no values
+	gp.desired.Keys = nil
+
+	var currentKeys []models.APIKey
+	if groupID != 0 {
+		if err := s.db.WithContext(ctx).Where("group_id = ?", groupID).Find(&currentKeys).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+	}
+	keyByHash := make(map[string]*models.APIKey, len(currentKeys))
+	for i := range currentKeys {
+		keyByHash[currentKeys[i].KeyHash] = &currentKeys[i]
+	}
+
+	matched := make(map[uint]bool, len(entries))
+	gp.keysToUpdate = make(map[uint]KeyEntry)
+	for _, entry := range entries {
+		var existing *models.APIKey
+		for _, hash := range encryption.HashCandidates(s.keyService.EncryptionSvc, entry.Key) {
+			if key, ok := keyByHash[hash]; ok {
+				existing = key
+				break
+			}
+		}
+		if existing == nil {
+			gp.keysToAdd = append(gp.keysToAdd, entry)
+			continue
+		}
+		if matched[existing.ID] {
+			continue
+		}
+		matched[existing.ID] = true
+		if !keyMetadataEqual(existing, &entry) {
+			gp.keysToUpdate[existing.ID] = entry
+		}
+	}
+	if p.prune {
+		for _, key := range currentKeys {
+			if !matched[key.ID] {
+				gp.keysToRemove = append(gp.keysToRemove, key.ID)
+			}
+		}
+	}
+
+	name := gp.desired.Name
+	if len(gp.keysToAdd) > 0 {
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionCreate, Kind: ConfigKindKeys, Target: name, Count: len(gp.keysToAdd)})
+	}
+	if len(gp.keysToUpdate) > 0 {
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionUpdate, Kind: ConfigKindKeys, Target: name, Count: len(gp.keysToUpdate)})
+	}
+	if len(gp.keysToRemove) > 0 {
+		p.changes = append(p.changes, ConfigChange{Action: ConfigActionDelete, Kind: ConfigKindKeys, Target: name, Count: len(gp.keysToRemove)})
+	}
+	return nil
+}
+
+func keyMetadataEqual(key *models.APIKey, entry *KeyEntry) bool {
+	if key.Priority != entry.Priority || key.RPMLimit != entry.RPMLimit {
+		return false
+	}
+	if (key.ExpiresAt == nil) != (entry.ExpiresAt == nil) || (key.ExpiresAt != nil && !key.ExpiresAt.Equal(*entry.ExpiresAt)) {
+		return false
+	}
+	tags := keyTagsFromJSON(key.Tags)
+	if len(tags) != len(entry.Tags) {
+		return false
+	}
+	for name, value := range entry.Tags {
+		if tags[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// diffConfigFields compares two values field by field through their JSON form and returns the changed fields.
+func diffConfigFields(current, desired any) (map[string]any, map[string]any) {
+	currentFields := configFields(current)
+	desiredFields := configFields(desired)
+
+	before := make(map[string]any)
+	after := make(map[string]any)
+	for field, value := range desiredFields {
+		if !reflect.DeepEqual(currentFields[field], value) {
+			before[field] = currentFields[field]
+			after[field] = value
+		}
+	}
+	for field, value := range currentFields {
+		if _, ok := desiredFields[field]; !ok {
+			before[field] = value
+			after[field] = nil
+		}
+	}
+	return before, after
+}
+
+func configFields(value any) map[string]any {
+	if gc, ok := value.(GroupConfig); ok {
+		// Members and keys are compared on their own
+		gc.SubGroups = nil
+		gc.Keys = nil
+		value = gc
+	}
+	fields := make(map[string]any)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		logrus.WithError(err).Warn("Failed to compare configuration fields")
+	}
+	return fields
+}
+
+// configCommit collects what follows a committed apply: key pool changes and audit entries.
+type configCommit struct {
+	audits      []AuditEntry
+	newKeys     map[uint][]models.APIKey
+	updatedKeys []models.APIKey
+	removedKeys map[uint][]uint
+}
+
+// apply makes the changes of the plan in one transaction. Settings go first, then groups in document order
+// with standard groups before aggregate groups, and pruned groups last. Caches are refreshed after the commit.
+func (s *ConfigService) apply(ctx context.Context, p *configPlan) error {
+	// Settings are reloaded after the commit, so keys are imported with the settings of the document
+	settings := s.settingsManager.SettingsWith(p.settings)
+	commit := &configCommit{
+		newKeys:     make(map[uint][]models.APIKey),
+		removedKeys: make(map[uint][]uint),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(p.settings) > 0 {
+			if err := s.settingsManager.SaveSettings(tx, p.settings); err != nil {
+				return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_settings", map[string]any{"error": err.Error()})
+			}
+		}
+
+		for i := range p.groups {
+			gp := &p.groups[i]
+			if err := s.applyGroup(ctx, tx, gp, settings, commit); err != nil {
+				return &ConfigGroupError{Group: gp.desired.Name, Err: err}
+			}
+		}
+
+		for _, group := range p.deletions {
+			keyIDs, entry, err := s.groupService.deleteGroup(tx, group.id)
+			if err != nil {
+				return &ConfigGroupError{Group: group.config.Name, Err: err}
+			}
+			commit.removedKeys[group.id] = append(commit.removedKeys[group.id], keyIDs...)
+			commit.audits = append(commit.audits, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.afterCommit(ctx, p, commit)
+	return nil
+}
+
+// afterCommit brings the caches in line with the committed changes and records their audit entries.
+// The database is already changed, so failures are logged rather than returned.
+func (s *ConfigService) afterCommit(ctx context.Context, p *configPlan, commit *configCommit) {
+	if len(p.settings) > 0 {
+		if err := s.settingsManager.Invalidate(); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to reload system settings after applying config")
+		}
+	}
+	if err := s.groupService.groupManager.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after applying config")
+	}
+	if len(p.deletions) > 0 {
+		s.groupService.proxyKeyService.InvalidateCache(ctx)
+	}
+
+	provider := s.keyService.KeyProvider
+	for groupID, keyIDs := range commit.removedKeys {
+		if err := provider.UncacheKeys(groupID, keyIDs); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("group_id", groupID).Error("failed to remove keys from key pool after applying config")
+		}
+	}
+	for groupID, keys := range commit.newKeys {
+		if err := provider.CacheKeys(groupID, keys); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("group_id", groupID).Error("failed to add keys to key pool after applying config")
+		}
+	}
+	for i := range commit.updatedKeys {
+		if err := provider.CacheKey(&commit.updatedKeys[i]); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("key_id", commit.updatedKeys[i].ID).Error("failed to update key in key pool after applying config")
+		}
+	}
+
+	for _, entry := range commit.audits {
+		s.auditService.Record(ctx, entry)
+	}
+}
+
+func (s *ConfigService) applyGroup(ctx context.Context, tx *gorm.DB, gp *groupPlan, settings types.SystemSettings, commit *configCommit) error {
+	gc := gp.desired
+	groupType := gc.GroupType
+	if groupType == "" {
+		groupType = "standard"
+	}
+
+	var groupID uint
+	if gp.create {
+		group, entry, err := s.groupService.createGroup(ctx, tx, GroupCreateParams{
+			Name:                gc.Name,
+			DisplayName:         gc.DisplayName,
+			Description:         gc.Description,
+			GroupType:           groupType,
+			Upstreams:           gc.Upstreams,
+			ChannelType:         gc.ChannelType,
+			Sort:                gc.Sort,
+			TestModel:           gc.TestModel,
+			ValidationEndpoint:  gc.ValidationEndpoint,
+			ParamOverrides:      gc.ParamOverrides,
+			ModelRedirectRules:  gc.ModelRedirectRules,
+			ModelRedirectStrict: gc.ModelRedirectStrict,
+			Config:              gc.Config,
+			HeaderRules:         gc.HeaderRules,
+			Budgets:             gc.Budgets,
+		})
+		if err != nil {
+			return err
+		}
+		groupID = group.ID
+		commit.audits = append(commit.audits, entry)
+	} else {
+		var group models.Group
+		if err := tx.Where("name = ?", gc.Name).First(&group).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		groupID = group.ID
+
+		if gp.updateFields {
+			params := GroupUpdateParams{
+				DisplayName:         &gc.DisplayName,
+				Description:         &gc.Description,
+				Sort:                &gc.Sort,
+				ParamOverrides:      gc.ParamOverrides,
+				ModelRedirectRules:  gc.ModelRedirectRules,
+				ModelRedirectStrict: &gc.ModelRedirectStrict,
+				Config:              gc.Config,
+				HeaderRules:         &gc.HeaderRules,
+				Budgets:             &gc.Budgets,
+			}
+			// Empty values clear the stored ones
+			if params.ParamOverrides == nil {
+				params.ParamOverrides = map[string]any{}
+			}
+			if params.ModelRedirectRules == nil {
+				params.ModelRedirectRules = map[string]string{}
+			}
+			if params.Config == nil {
+				params.Config = map[string]any{}
+			}
+			if groupType == "standard" {
+				params.ChannelType = &gc.ChannelType
+				params.Upstreams = gc.Upstreams
+				params.HasUpstreams = true
+				params.TestModel = gc.TestModel
+				params.HasTestModel = true
+				params.ValidationEndpoint = &gc.ValidationEndpoint
+			}
+			_, entry, err := s.groupService.updateGroup(ctx, tx, groupID, params)
+			if err != nil {
+				return err
+			}
+			commit.audits = append(commit.audits, entry)
+		}
+	}
+
+	if gp.updateState {
+		state := gc.State
+		if state == "" {
+			state = models.GroupStateEnabled
+		}
+		_, entry, err := s.groupService.updateGroupState(tx, groupID, GroupStateParams{State: state, Message: gc.StateMessage, StatusCode: gc.StateStatusCode})
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+
+	if err := s.applySubGroups(tx, groupID, gp.subGroups, commit); err != nil {
+		return err
+	}
+
+	return s.applyKeys(tx, groupID, gp, settings, commit)
+}
+
+// applySubGroups removes members first, so a replaced member frees its place before new ones are added.
+func (s *ConfigService) applySubGroups(tx *gorm.DB, groupID uint, changes []subGroupPlan, commit *configCommit) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var groups []models.Group
+	if err := tx.Select("id", "name").Find(&groups).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	idByName := make(map[string]uint, len(groups))
+	for _, group := range groups {
+		idByName[group.Name] = group.ID
+	}
+
+	svc := s.aggregateGroupService
+	var inputs []SubGroupInput
+	for _, change := range changes {
+		member := change.desired
+		subGroupID, ok := idByName[member.Group]
+		if !ok {
+			return NewI18nError(app_errors.ErrValidation, "validation.config_invalid_sub_group", map[string]any{"sub_group": member.Group})
+		}
+
+		switch change.action {
+		case ConfigActionDelete:
+			entry, err := svc.deleteSubGroup(tx, groupID, subGroupID)
+			if err != nil {
+				return err
+			}
+			commit.audits = append(commit.audits, entry)
+		case ConfigActionCreate:
+			inputs = append(inputs, SubGroupInput{
+				GroupID:        subGroupID,
+				Weight:         member.Weight,
+				Priority:       member.Priority,
+				Models:         member.Models,
+				ActiveSchedule: member.ActiveSchedule,
+			})
+		case ConfigActionUpdate:
+			if err := s.updateSubGroup(tx, groupID, subGroupID, change, commit); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(inputs) > 0 {
+		entry, err := svc.addSubGroups(tx, groupID, inputs)
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+	return nil
+}
+
+func (s *ConfigService) updateSubGroup(tx *gorm.DB, groupID, subGroupID uint, change subGroupPlan, commit *configCommit) error {
+	member := change.desired
+	svc := s.aggregateGroupService
+	if change.fields["weight"] {
+		entry, err := svc.updateSubGroupWeight(tx, groupID, subGroupID, member.Weight)
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+	if change.fields["priority"] {
+		entry, err := svc.updateSubGroupPriority(tx, groupID, subGroupID, member.Priority)
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+	if change.fields["models"] {
+		entry, err := svc.updateSubGroupModels(tx, groupID, subGroupID, member.Models)
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+	if change.fields["active_schedule"] {
+		entry, err := svc.updateSubGroupSchedule(tx, groupID, subGroupID, member.ActiveSchedule)
+		if err != nil {
+			return err
+		}
+		commit.audits = append(commit.audits, entry)
+	}
+	return nil
+}
+
+// applyKeys changes the keys of a group in tx, the key pool follows after the commit.
+func (s *ConfigService) applyKeys(tx *gorm.DB, groupID uint, gp *groupPlan, settings types.SystemSettings, commit *configCommit) error {
+	if len(gp.keysToAdd) > 0 {
+		newKeys, err := s.keyService.prepareKeys(tx, groupID, gp.keysToAdd, settings.EnforceGlobalKeyUniqueness)
+		if err != nil {
+			return err
+		}
+		if len(newKeys) > 0 {
+			if err := tx.CreateInBatches(&newKeys, chunkSize).Error; err != nil {
+				return app_errors.ParseDBError(err)
+			}
+			commit.newKeys[groupID] = append(commit.newKeys[groupID], newKeys...)
+		}
+	}
+
+	for keyID, entry := range gp.keysToUpdate {
+		tags := entry.Tags
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		expiresAt := ""
+		if entry.ExpiresAt != nil {
+			expiresAt = entry.ExpiresAt.Format(time.RFC3339)
+		}
+
+		var key models.APIKey
+		if err := tx.First(&key, keyID).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		if err := applyKeyMetadataParams(&key, KeyMetadataParams{
+			Tags:      tags,
+			Priority:  &entry.Priority,
+			ExpiresAt: &expiresAt,
+			RPMLimit:  &entry.RPMLimit,
+		}); err != nil {
+			return err
+		}
+		if err := s.keyService.KeyProvider.SaveKeyMetadata(tx, &key); err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		commit.updatedKeys = append(commit.updatedKeys, key)
+	}
+
+	if len(gp.keysToRemove) > 0 {
+		if err := tx.Where("group_id = ? AND id IN ?", groupID, gp.keysToRemove).Delete(&models.APIKey{}).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		commit.removedKeys[groupID] = append(commit.removedKeys[groupID], gp.keysToRemove...)
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/db"
+	"gpt-load/internal/encryption"
+	"gpt-load/internal/keypool"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+)
+
+// stubConfigManager serves a fixed configuration, the methods not overridden are not used by the tests.
+type stubConfigManager struct {
+	types.ConfigManager
+	auth       types.AuthConfig
+	oidc       types.OIDCConfig
+	encryption types.EncryptionConfig
+}
+
+func (m *stubConfigManager) GetAuthConfig() types.AuthConfig             { return m.auth }
+func (m *stubConfigManager) GetOIDCConfig() types.OIDCConfig             { return m.oidc }
+func (m *stubConfigManager) GetEncryptionKey() string                    { return "" }
+func (m *stubConfigManager) GetEncryptionConfig() types.EncryptionConfig { return m.encryption }
+
+// syncPubSubStore delivers published messages before Publish returns, so the cache syncers
+// can be stopped at the end of a test without notifications still in flight.
+type syncPubSubStore struct {
+	store.Store
+	mu   sync.RWMutex
+	subs map[string]map[*syncSubscription]bool
+}
+
+type syncSubscription struct {
+	store   *syncPubSubStore
+	channel string
+	ch      chan *store.Message
+}
+
+func (sub *syncSubscription) Channel() <-chan *store.Message {
+	return sub.ch
+}
+
+func (sub *syncSubscription) Close() error {
+	sub.store.mu.Lock()
+	defer sub.store.mu.Unlock()
+	delete(sub.store.subs[sub.channel], sub)
+	close(sub.ch)
+	return nil
+}
+
+func (s *syncPubSubStore) Subscribe(channel string) (store.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := &syncSubscription{store: s, channel: channel, ch: make(chan *store.Message, 16)}
+	if s.subs[channel] == nil {
+		s.subs[channel] = make(map[*syncSubscription]bool)
+	}
+	s.subs[channel][sub] = true
+	return sub, nil
+}
+
+func (s *syncPubSubStore) Publish(channel string, payload []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for sub := range s.subs[channel] {
+		select {
+		case sub.ch <- &store.Message{Channel: channel, Payload: payload}:
+		default: // A reload is already pending
+		}
+	}
+	return nil
+}
+
+// newTestConfigService wires a ConfigService with its dependencies on a temporary database.
+func newTestConfigService(t *testing.T) *ConfigService {
+	t.Helper()
+	testDB := newTestDB(t,
+		&models.SystemSetting{},
+		&models.Group{},
+		&models.GroupSubGroup{},
+		&models.APIKey{},
+		&models.AuditLog{},
+		&models.ProxyKey{},
+		&models.KeySource{},
+	)
+	// The settings manager loads from the global connection
+	previousDB := db.DB
+	db.DB = testDB
+	t.Cleanup(func() { db.DB = previousDB })
+
+	st := &syncPubSubStore{Store: store.NewMemoryStore(), subs: make(map[string]map[*syncSubscription]bool)}
+	encryptionSvc, err := encryption.NewService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settingsManager := config.NewSystemSettingsManager()
+	subGroupManager := NewSubGroupManager(st)
+	groupManager := NewGroupManager(testDB, st, settingsManager, subGroupManager)
+	if err := settingsManager.Initialize(st, groupManager, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupManager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		groupManager.Stop(context.Background())
+		settingsManager.Stop(context.Background())
+	})
+
+	auditService := NewAuditService(testDB)
+	keyService := NewKeyService(testDB, keypool.NewProvider(testDB, st, settingsManager, encryptionSvc), nil, encryptionSvc, settingsManager)
+	aggregateGroupService := NewAggregateGroupService(testDB, groupManager, auditService)
+	proxyKeyService := NewProxyKeyService(testDB, st, settingsManager, auditService)
+	groupService := NewGroupService(testDB, settingsManager, groupManager, keyService, nil, encryptionSvc, aggregateGroupService, auditService, proxyKeyService)
+
+	return NewConfigService(testDB, &stubConfigManager{}, settingsManager, groupService, aggregateGroupService, keyService, auditService)
+}
+
+const testConfigDocument = `
+version: 1
+settings:
+  request_timeout: 120
+groups:
+  - name: pool
+    group_type: aggregate
+    channel_type: openai
+    sub_groups:
+      - group: openai-a
+        weight: 3
+        models: ["gpt-4*"]
+      - group: openai-b
+        weight: 1
+        priority: 1
+  - name: openai-a
+    channel_type: openai
+    test_model: gpt-4o-mini
+    upstreams:
+      - url: https://api.openai.com
+        weight: 1
+    budgets:
+      - period: daily
+        metric: requests
+        hard_limit: 1000
+  - name: openai-b
+    display_name: Backup
+    channel_type: openai
+    test_model: gpt-4o-mini
+    upstreams:
+      - url: " https://backup.example.com "
+        weight: 1
+`
+
+// applyTestConfig applies testConfigDocument and waits for its settings to be reloaded,
+// which happens asynchronously after the commit.
+func applyTestConfig(t *testing.T, s *ConfigService) *ConfigPlan {
+	t.Helper()
+	plan, err := s.Apply(context.Background(), mustParseConfig(t, testConfigDocument), false)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.settingsManager.GetSettings().RequestTimeout != 120 {
+		if time.Now().After(deadline) {
+			t.Fatal("settings were not reloaded after Apply")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return plan
+}
+
+func mustParseConfig(t *testing.T, data string) *ConfigDocument {
+	t.Helper()
+	doc, err := ParseConfigDocument([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseConfigDocument: %v", err)
+	}
+	return doc
+}
+
+// changeList summarizes plan changes as "action kind target", sorted.
+func changeList(plan *ConfigPlan) []string {
+	list := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		list = append(list, change.Action+" "+change.Kind+" "+change.Target)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func assertChanges(t *testing.T, step string, plan *ConfigPlan, want ...string) {
+	t.Helper()
+	got := changeList(plan)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("%s: changes = %q, want %q", step, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: changes = %q, want %q", step, got, want)
+		}
+	}
+}
+
+func TestConfigPlanApplyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := newTestConfigService(t)
+	doc := mustParseConfig(t, testConfigDocument)
+
+	wantCreate := []string{
+		"update setting request_timeout",
+		"create group openai-a",
+		"create group openai-b",
+		"create group pool",
+		"create sub_group pool/openai-a",
+		"create sub_group pool/openai-b",
+	}
+
+	plan, err := s.Plan(ctx, doc, false)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !plan.DryRun {
+		t.Error("Plan result is not marked as a dry run")
+	}
+	assertChanges(t, "plan", plan, wantCreate...)
+
+	var count int64
+	s.db.Model(&models.Group{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("Plan created %d groups", count)
+	}
+
+	applied := applyTestConfig(t, s)
+	if applied.DryRun {
+		t.Error("Apply result is marked as a dry run")
+	}
+	assertChanges(t, "apply", applied, wantCreate...)
+
+	// Applying the same document again and planning the export both find nothing to do
+	again, err := s.Plan(ctx, mustParseConfig(t, testConfigDocument), false)
+	if err != nil {
+		t.Fatalf("Plan after apply: %v", err)
+	}
+	assertChanges(t, "plan after apply", again)
+
+	exported, err := s.Export(ctx, false)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data, err := MarshalConfigDocument(exported, ConfigFormatYAML)
+	if err != nil {
+		t.Fatalf("MarshalConfigDocument: %v", err)
+	}
+	roundTrip, err := s.Plan(ctx, mustParseConfig(t, string(data)), true)
+	if err != nil {
+		t.Fatalf("Plan of the export: %v", err)
+	}
+	assertChanges(t, "plan of the export", roundTrip)
+
+	var links []models.GroupSubGroup
+	if err := s.db.Order("priority asc").Find(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].Weight != 3 || links[1].Weight != 1 || links[1].Priority != 1 {
+		t.Errorf("stored sub-groups = %+v, want weights 3 and 1 with priorities 0 and 1", links)
+	}
+}
+
+func TestConfigApplyChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		prune  bool
+		modify func(doc *ConfigDocument)
+		want   []string
+	}{
+		{
+			name: "sub-group weight and models",
+			modify: func(doc *ConfigDocument) {
+				doc.Groups[0].SubGroups[0].Weight = 5
+				doc.Groups[0].SubGroups[0].Models = nil
+			},
+			want: []string{"update sub_group pool/openai-a"},
+		},
+		{
+			name:   "sub-group removed",
+			modify: func(doc *ConfigDocument) { doc.Groups[0].SubGroups = doc.Groups[0].SubGroups[:1] },
+			want:   []string{"delete sub_group pool/openai-b"},
+		},
+		{
+			name:   "group fields",
+			modify: func(doc *ConfigDocument) { doc.Groups[2].DisplayName = ""; doc.Groups[2].Description = "Fallback" },
+			want:   []string{"update group openai-b"},
+		},
+		{
+			name: "group state",
+			modify: func(doc *ConfigDocument) {
+				doc.Groups[1].State = models.GroupStateMaintenance
+				doc.Groups[1].StateMessage = "Upstream maintenance"
+			},
+			want: []string{"update group openai-a"},
+		},
+		{
+			name: "group omitted without prune is kept",
+			modify: func(doc *ConfigDocument) {
+				doc.Groups = doc.Groups[:2]
+				doc.Groups[0].SubGroups = doc.Groups[0].SubGroups[:1]
+			},
+			want: []string{"delete sub_group pool/openai-b"},
+		},
+		{
+			name:  "group omitted with prune is deleted",
+			prune: true,
+			modify: func(doc *ConfigDocument) {
+				doc.Groups = doc.Groups[:2]
+				doc.Groups[0].SubGroups = doc.Groups[0].SubGroups[:1]
+			},
+			want: []string{"delete sub_group pool/openai-b", "delete group openai-b"},
+		},
+		{
+			name:   "settings omitted are kept",
+			modify: func(doc *ConfigDocument) { doc.Settings = nil },
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestConfigService(t)
+			applyTestConfig(t, s)
+
+			doc := mustParseConfig(t, testConfigDocument)
+			tt.modify(doc)
+
+			plan, err := s.Plan(ctx, doc, tt.prune)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			assertChanges(t, "plan", plan, tt.want...)
+
+			if _, err := s.Apply(ctx, doc, tt.prune); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			after, err := s.Plan(ctx, doc, tt.prune)
+			if err != nil {
+				t.Fatalf("Plan after apply: %v", err)
+			}
+			assertChanges(t, "plan after apply", after)
+		})
+	}
+}
+
+func TestConfigPlanErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(doc *ConfigDocument)
+		wantGroup string
+	}{
+		{name: "unknown setting", modify: func(doc *ConfigDocument) { doc.Settings["no_such_setting"] = 1 }},
+		{name: "invalid setting value", modify: func(doc *ConfigDocument) { doc.Settings["request_timeout"] = 0 }},
+		{name: "duplicate group", modify: func(doc *ConfigDocument) { doc.Groups = append(doc.Groups, doc.Groups[1]) }},
+		{name: "invalid channel type", modify: func(doc *ConfigDocument) { doc.Groups[1].ChannelType = "carrier-pigeon" }, wantGroup: "openai-a"},
+		{name: "missing test model", modify: func(doc *ConfigDocument) { doc.Groups[2].TestModel = "" }, wantGroup: "openai-b"},
+		{name: "unknown sub-group", modify: func(doc *ConfigDocument) { doc.Groups[0].SubGroups[1].Group = "missing" }, wantGroup: "pool"},
+		{
+			name: "aggregate as sub-group",
+			modify: func(doc *ConfigDocument) {
+				doc.Groups = append(doc.Groups, GroupConfig{Name: "nested", GroupType: "aggregate", ChannelType: "openai", SubGroups: []SubGroupConfig{{Group: "pool", Weight: 1}}})
+			},
+			wantGroup: "nested",
+		},
+		{
+			name: "group type changed",
+			modify: func(doc *ConfigDocument) {
+				doc.Groups[0] = GroupConfig{Name: "pool", ChannelType: "openai", TestModel: "gpt-4o-mini", Upstreams: []byte(`[{"url":"https://api.openai.com","weight":1}]`)}
+			},
+			wantGroup: "pool",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestConfigService(t)
+			applyTestConfig(t, s)
+
+			doc := mustParseConfig(t, testConfigDocument)
+			tt.modify(doc)
+
+			_, err := s.Apply(ctx, doc, false)
+			if err == nil {
+				t.Fatal("Apply succeeded, want error")
+			}
+			var groupErr *ConfigGroupError
+			if tt.wantGroup != "" && (!errors.As(err, &groupErr) || groupErr.Group != tt.wantGroup) {
+				t.Errorf("Apply error = %v, want an error for group %q", err, tt.wantGroup)
+			}
+
+			// Nothing changed, so the original document still matches
+			plan, err := s.Plan(ctx, mustParseConfig(t, testConfigDocument), false)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			assertChanges(t, "plan after failed apply", plan)
+		})
+	}
+}
+
+func TestParseConfigDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "yaml", data: "version: 1\ngroups: []\n"},
+		{name: "json", data: `{"version": 1, "groups": []}`},
+		{name: "unsupported version", data: "version: 2\ngroups: []\n", wantErr: true},
+		{name: "unknown field", data: "version: 1\ngroups:\n  - name: a\n    colour: red\n", wantErr: true},
+		{name: "invalid yaml", data: "version: [1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfigDocument([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseConfigDocument error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
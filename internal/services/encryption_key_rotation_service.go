@@ -0,0 +1,703 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gpt-load/internal/encryption"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/syncer"
+	"gpt-load/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const EncryptionKeyRotationUpdateChannel = "encryption_key_rotation:updated"
+
+const (
+	// encryptionKeyRotationSetting is the system_settings row holding the rotation state
+	encryptionKeyRotationSetting = "encryption_key_rotation"
+
+	// keyCheckPlaintext is hashed to tell encryption keys apart without storing them
+	keyCheckPlaintext = "gpt-load-encryption-key-check"
+
+	keyRotationBatchSize     = 500
+	keyRotationRetryInterval = 30 * time.Second
+
+	// keyRotationSweepMargin covers records encrypted by other nodes or queued request logs
+	// that were written with the previous key shortly after the rotation started
+	keyRotationSweepMargin = 5 * time.Minute
+)
+
+const (
+	KeyRotationStatusIdle      = "idle"
+	KeyRotationStatusRunning   = "running"
+	KeyRotationStatusCompleted = "completed"
+)
+
+// EncryptionKeyRotationParams defines the new ENCRYPTION_KEY for an online rotation.
+type EncryptionKeyRotationParams struct {
+	NewKey string `json:"new_key"`
+}
+
+// EncryptionKeyRotationStatus describes the progress of an online ENCRYPTION_KEY rotation.
+type EncryptionKeyRotationStatus struct {
+	Status          string     `json:"status"`
+	Total           int        `json:"total"`
+	Processed       int        `json:"processed"`
+	Failed          int        `json:"failed"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	RestartRequired bool       `json:"restart_required"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// keyRotationState is persisted in system_settings so a rotation resumes after a crash.
+// Key and PreviousKey are encrypted with the configured ENCRYPTION_KEY, whose check value is WrapCheck.
+type keyRotationState struct {
+	Status      string     `json:"status"`
+	Key         string     `json:"key"`
+	PreviousKey string     `json:"previous_key,omitempty"`
+	KeyCheck    string     `json:"key_check"`
+	WrapCheck   string     `json:"wrap_check"`
+	KeysDone    bool       `json:"keys_done"`
+	KeyCursor   uint       `json:"key_cursor"`
+	LogCursor   string     `json:"log_cursor"`
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Failed      int        `json:"failed"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// keyRotationSnapshot is the stored rotation state resolved against the configured ENCRYPTION_KEY.
+type keyRotationSnapshot struct {
+	state     *keyRotationState
+	key       string
+	current   encryption.Service
+	previous  encryption.Service
+	finalized bool
+	problem   string
+}
+
+// EncryptionKeyRotationResult is the task result of a finished rotation.
+type EncryptionKeyRotationResult struct {
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+}
+
+// EncryptionKeyRotationService replaces ENCRYPTION_KEY while the service keeps running.
+// All nodes decrypt with both keys during the rotation, while the master re-encrypts
+// api_keys and request_logs in batches and then retires the previous key. Until
+// ENCRYPTION_KEY is updated, the new key is kept in system_settings encrypted with it.
+type EncryptionKeyRotationService struct {
+	db            *gorm.DB
+	store         store.Store
+	keyRing       *encryption.KeyRing
+	encryptionSvc encryption.Service
+	configManager types.ConfigManager
+	taskService   *TaskService
+	auditService  *AuditService
+	syncer        *syncer.CacheSyncer[*keyRotationSnapshot]
+
+	envKey  string
+	envSvc  encryption.Service
+	started atomic.Bool
+	running atomic.Bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewEncryptionKeyRotationService creates a new, uninitialized EncryptionKeyRotationService.
+func NewEncryptionKeyRotationService(
+	db *gorm.DB,
+	store store.Store,
+	keyRing *encryption.KeyRing,
+	encryptionSvc encryption.Service,
+	configManager types.ConfigManager,
+	taskService *TaskService,
+	auditService *AuditService,
+) *EncryptionKeyRotationService {
+	return &EncryptionKeyRotationService{
+		db:            db,
+		store:         store,
+		keyRing:       keyRing,
+		encryptionSvc: encryptionSvc,
+		configManager: configManager,
+		taskService:   taskService,
+		auditService:  auditService,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Initialize loads the rotation state and applies its keys to the key ring.
+// It must run before any key is decrypted.
+func (s *EncryptionKeyRotationService) Initialize() error {
+	s.envKey = s.configManager.GetEncryptionKey()
+	envSvc, err := encryption.NewService(s.envKey)
+	if err != nil {
+		return err
+	}
+	s.envSvc = envSvc
+
+	rotationSyncer, err := syncer.NewCacheSyncer(
+		s.loadSnapshot,
+		s.store,
+		EncryptionKeyRotationUpdateChannel,
+		logrus.WithField("syncer", "encryption_key_rotation"),
+		s.applySnapshot,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create encryption key rotation syncer: %w", err)
+	}
+	s.syncer = rotationSyncer
+	return nil
+}
+
+// Start resumes an unfinished rotation. Only the master re-encrypts records.
+func (s *EncryptionKeyRotationService) Start() {
+	s.started.Store(true)
+	if snapshot := s.snapshot(); snapshot != nil {
+		s.resume(snapshot)
+	}
+}
+
+// Stop stops the syncer and interrupts a running rotation, which resumes on the next start.
+func (s *EncryptionKeyRotationService) Stop(ctx context.Context) {
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+	close(s.stopCh)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("EncryptionKeyRotationService stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("EncryptionKeyRotationService stop timed out.")
+	}
+}
+
+// InProgress reports whether records are still being re-encrypted with the new key.
+func (s *EncryptionKeyRotationService) InProgress() bool {
+	return s.keyRing.Rotating()
+}
+
+// StartRotation switches all nodes to the new key and starts re-encrypting records on the master.
+func (s *EncryptionKeyRotationService) StartRotation(ctx context.Context, params EncryptionKeyRotationParams) (*EncryptionKeyRotationStatus, error) {
+	if params.NewKey == "" {
+		return nil, NewI18nError(app_errors.ErrValidation, "encryption.new_key_required", nil)
+	}
+	// Without ENCRYPTION_KEY the new and previous keys could not be wrapped before being stored
+	if s.envKey == "" {
+		return nil, NewI18nError(app_errors.ErrValidation, "encryption.key_rotation_requires_key", nil)
+	}
+
+	snapshot := s.snapshot()
+	if snapshot.problem != "" {
+		return nil, NewI18nError(app_errors.ErrValidation, "encryption.key_rotation_blocked", map[string]any{"reason": snapshot.problem})
+	}
+	if snapshot.state != nil && snapshot.state.Status == KeyRotationStatusRunning {
+		return nil, NewI18nError(app_errors.ErrTaskInProgress, "encryption.key_rotation_running", nil)
+	}
+	if params.NewKey == snapshot.key {
+		return nil, NewI18nError(app_errors.ErrValidation, "encryption.key_unchanged", nil)
+	}
+	newSvc, err := encryption.NewService(params.NewKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := s.envSvc.Encrypt(params.NewKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedPrevious, err := s.envSvc.Encrypt(snapshot.key)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyCount, logCount int64
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).Count(&keyCount).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.RequestLog{}).Count(&logCount).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	state := &keyRotationState{
+		Status:      KeyRotationStatusRunning,
+		Key:         wrappedKey,
+		PreviousKey: wrappedPrevious,
+		KeyCheck:    keyCheck(newSvc),
+		WrapCheck:   keyCheck(s.envSvc),
+		Total:       int(keyCount + logCount),
+		StartedAt:   time.Now(),
+	}
+	if err := s.saveState(ctx, state); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx)
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "encryption_key.rotate",
+		TargetType: models.AuditTargetSettings,
+		TargetName: "ENCRYPTION_KEY",
+		After:      map[string]any{"status": KeyRotationStatusRunning, "total": state.Total},
+	})
+	logrus.WithField("total", state.Total).Info("Encryption key rotation started")
+
+	return statusFromState(state, s.envSvc), nil
+}
+
+// GetStatus returns the state of the current or last rotation.
+func (s *EncryptionKeyRotationService) GetStatus() *EncryptionKeyRotationStatus {
+	snapshot := s.snapshot()
+	if snapshot == nil || snapshot.state == nil {
+		return &EncryptionKeyRotationStatus{Status: KeyRotationStatusIdle, Error: snapshotProblem(snapshot)}
+	}
+	status := statusFromState(snapshot.state, s.envSvc)
+	status.Error = snapshot.problem
+	return status
+}
+
+func statusFromState(state *keyRotationState, envSvc encryption.Service) *EncryptionKeyRotationStatus {
+	startedAt := state.StartedAt
+	return &EncryptionKeyRotationStatus{
+		Status:          state.Status,
+		Total:           state.Total,
+		Processed:       state.Processed,
+		Failed:          state.Failed,
+		StartedAt:       &startedAt,
+		FinishedAt:      state.FinishedAt,
+		RestartRequired: state.Status == KeyRotationStatusCompleted && keyCheck(envSvc) != state.KeyCheck,
+	}
+}
+
+func snapshotProblem(snapshot *keyRotationSnapshot) string {
+	if snapshot == nil {
+		return ""
+	}
+	return snapshot.problem
+}
+
+// loadSnapshot reads the rotation state and resolves the keys the key ring should use.
+func (s *EncryptionKeyRotationService) loadSnapshot() (*keyRotationSnapshot, error) {
+	snapshot := &keyRotationSnapshot{key: s.envKey, current: s.envSvc}
+
+	var settings []models.SystemSetting
+	if err := s.db.Where("setting_key = ?", encryptionKeyRotationSetting).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return snapshot, nil
+	}
+
+	var state keyRotationState
+	if err := json.Unmarshal([]byte(settings[0].SettingValue), &state); err != nil {
+		snapshot.problem = "the stored rotation state is corrupted"
+		return snapshot, nil
+	}
+	snapshot.state = &state
+
+	envCheck := keyCheck(s.envSvc)
+	switch {
+	case envCheck == state.KeyCheck && state.Status == KeyRotationStatusCompleted:
+		// ENCRYPTION_KEY was updated to the new key, the stored copy is no longer needed
+		snapshot.state = nil
+		snapshot.finalized = true
+	case envCheck == state.KeyCheck:
+		snapshot.problem = "ENCRYPTION_KEY was changed to the new key before the rotation completed, restore the previous key to finish it"
+	case envCheck != state.WrapCheck:
+		snapshot.problem = "ENCRYPTION_KEY does not match the key configured when the rotation started"
+	default:
+		newKey, err := s.envSvc.Decrypt(state.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt rotation key: %w", err)
+		}
+		newSvc, err := encryption.NewService(newKey)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.key = newKey
+		snapshot.current = newSvc
+
+		if state.Status == KeyRotationStatusRunning {
+			previousKey, err := s.envSvc.Decrypt(state.PreviousKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt previous rotation key: %w", err)
+			}
+			if snapshot.previous, err = encryption.NewService(previousKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// applySnapshot switches the key ring to the loaded keys and resumes the rotation on the master.
+func (s *EncryptionKeyRotationService) applySnapshot(snapshot *keyRotationSnapshot) {
+	s.keyRing.SetKeys(snapshot.current, snapshot.previous)
+
+	switch {
+	case snapshot.problem != "":
+		logrus.Errorf("Encryption key rotation cannot continue: %s", snapshot.problem)
+	case snapshot.finalized:
+		if s.configManager.IsMaster() {
+			if err := s.db.Where("setting_key = ?", encryptionKeyRotationSetting).Delete(&models.SystemSetting{}).Error; err != nil {
+				logrus.WithError(err).Error("Failed to remove finished encryption key rotation state")
+			} else {
+				logrus.Info("ENCRYPTION_KEY matches the rotated key, removed the stored rotation key.")
+			}
+		}
+	case snapshot.state != nil && snapshot.state.Status == KeyRotationStatusCompleted:
+		logrus.Warn("Encryption key rotation completed, set ENCRYPTION_KEY to the new key and restart to remove the stored key.")
+	}
+
+	if s.started.Load() {
+		s.resume(snapshot)
+	}
+}
+
+// resume starts the re-encryption worker on the master for a running rotation.
+func (s *EncryptionKeyRotationService) resume(snapshot *keyRotationSnapshot) {
+	if !s.configManager.IsMaster() || snapshot.problem != "" || snapshot.state == nil || snapshot.state.Status != KeyRotationStatusRunning {
+		return
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	state := *snapshot.state
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Store(false)
+		s.run(&state)
+	}()
+}
+
+// run re-encrypts all records, retrying after failures until it completes or the service stops.
+func (s *EncryptionKeyRotationService) run(state *keyRotationState) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Run as a task so the progress is reported by the task API. The lock of a rotation
+	// task left by a stopped node expires shortly.
+	var task *Task
+	for {
+		var err error
+		if task, err = s.taskService.StartTask(TaskTypeEncryptionKeyRotation, "", state.Total); err == nil {
+			break
+		}
+		logrus.Debug("Waiting for the previous encryption key rotation task to expire")
+		if !s.wait(ctx) {
+			return
+		}
+	}
+	s.reportProgress(task, state)
+
+	for {
+		err := s.reencrypt(ctx, task, state)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			logrus.Info("Encryption key rotation interrupted, it resumes on the next start.")
+			if endErr := task.End(nil, errors.New("interrupted by shutdown")); endErr != nil {
+				logrus.WithError(endErr).Error("Failed to end encryption key rotation task")
+			}
+			return
+		}
+		logrus.WithError(err).Error("Encryption key rotation failed, retrying")
+		if !s.wait(ctx) {
+			return
+		}
+	}
+
+	finishedAt := time.Now()
+	state.Status = KeyRotationStatusCompleted
+	state.PreviousKey = ""
+	state.FinishedAt = &finishedAt
+	if err := s.saveState(ctx, state); err != nil {
+		logrus.WithError(err).Error("Failed to complete encryption key rotation")
+		if endErr := task.End(nil, err); endErr != nil {
+			logrus.WithError(endErr).Error("Failed to end encryption key rotation task")
+		}
+		return
+	}
+	s.invalidate(ctx)
+
+	if err := task.End(EncryptionKeyRotationResult{Processed: state.Processed, Failed: state.Failed}, nil); err != nil {
+		logrus.WithError(err).Error("Failed to end encryption key rotation task")
+	}
+	logrus.WithFields(logrus.Fields{
+		"processed": state.Processed,
+		"failed":    state.Failed,
+		"duration":  finishedAt.Sub(state.StartedAt).String(),
+	}).Info("Encryption key rotation completed, the previous key is retired")
+}
+
+// reencrypt processes api_keys and request_logs from the saved cursors, then sweeps the
+// records written since the rotation started.
+func (s *EncryptionKeyRotationService) reencrypt(ctx context.Context, task *Task, state *keyRotationState) error {
+	for !state.KeysDone {
+		var keys []models.APIKey
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("id > ?", state.KeyCursor).
+			Order("id asc").Limit(keyRotationBatchSize).Find(&keys).Error; err != nil {
+			return fmt.Errorf("failed to load api keys: %w", err)
+		}
+		if len(keys) == 0 {
+			state.KeysDone = true
+		} else {
+			if err := s.reencryptKeys(ctx, keys, state); err != nil {
+				return err
+			}
+			state.KeyCursor = keys[len(keys)-1].ID
+		}
+		if err := s.checkpoint(ctx, task, state); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var logs []models.RequestLog
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("id > ?", state.LogCursor).
+			Order("id asc").Limit(keyRotationBatchSize).Find(&logs).Error; err != nil {
+			return fmt.Errorf("failed to load request logs: %w", err)
+		}
+		if len(logs) == 0 {
+			break
+		}
+		if err := s.reencryptLogs(ctx, logs, state); err != nil {
+			return err
+		}
+		state.LogCursor = logs[len(logs)-1].ID
+		if err := s.checkpoint(ctx, task, state); err != nil {
+			return err
+		}
+	}
+
+	since := state.StartedAt.Add(-keyRotationSweepMargin)
+	var keyCursor uint
+	for {
+		var keys []models.APIKey
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("created_at >= ? AND id > ?", since, keyCursor).
+			Order("id asc").Limit(keyRotationBatchSize).Find(&keys).Error; err != nil {
+			return fmt.Errorf("failed to load recent api keys: %w", err)
+		}
+		if len(keys) == 0 {
+			break
+		}
+		if err := s.reencryptKeys(ctx, keys, nil); err != nil {
+			return err
+		}
+		keyCursor = keys[len(keys)-1].ID
+	}
+	logCursor := ""
+	for {
+		var logs []models.RequestLog
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("timestamp >= ? AND id > ?", since, logCursor).
+			Order("id asc").Limit(keyRotationBatchSize).Find(&logs).Error; err != nil {
+			return fmt.Errorf("failed to load recent request logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err := s.reencryptLogs(ctx, logs, nil); err != nil {
+			return err
+		}
+		logCursor = logs[len(logs)-1].ID
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// reencryptKeys re-encrypts a batch of api keys and the copies cached in the store.
+// A nil state means the records are part of the final sweep and not counted.
+func (s *EncryptionKeyRotationService) reencryptKeys(ctx context.Context, keys []models.APIKey, state *keyRotationState) error {
+	updated := make(map[uint]string, len(keys))
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			newValue, newHash, ok := s.reencryptValue(key.KeyValue, state)
+			if !ok {
+				continue
+			}
+			// Skip keys changed since they were loaded, e.g. by a data key re-wrap
+			result := tx.Model(&models.APIKey{}).
+				Where("id = ? AND key_value = ?", key.ID, key.KeyValue).
+				Updates(map[string]any{"key_value": newValue, "key_hash": newHash})
+			if result.Error != nil {
+				return fmt.Errorf("failed to update api key %d: %w", key.ID, result.Error)
+			}
+			if result.RowsAffected > 0 {
+				updated[key.ID] = newValue
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for keyID, newValue := range updated {
+		keyHashKey := fmt.Sprintf("key:%d", keyID)
+		if exists, err := s.store.Exists(keyHashKey); err == nil && exists {
+			if err := s.store.HSet(keyHashKey, map[string]any{"key_string": newValue}); err != nil {
+				logrus.WithError(err).WithField("keyID", keyID).Warn("Failed to update re-encrypted key in store")
+			}
+		}
+	}
+	return nil
+}
+
+// reencryptLogs re-encrypts the key values recorded in a batch of request logs.
+func (s *EncryptionKeyRotationService) reencryptLogs(ctx context.Context, logs []models.RequestLog, state *keyRotationState) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, log := range logs {
+			if log.KeyValue == "" {
+				if state != nil {
+					state.Processed++
+				}
+				continue
+			}
+			newValue, newHash, ok := s.reencryptValue(log.KeyValue, state)
+			if !ok {
+				continue
+			}
+			if err := tx.Model(&models.RequestLog{}).
+				Where("id = ? AND key_value = ?", log.ID, log.KeyValue).
+				Updates(map[string]any{"key_value": newValue, "key_hash": newHash}).Error; err != nil {
+				return fmt.Errorf("failed to update request log %s: %w", log.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// reencryptValue decrypts a value with either key and encrypts it with the new one.
+// Values that cannot be decrypted are counted as failed and left unchanged.
+func (s *EncryptionKeyRotationService) reencryptValue(value string, state *keyRotationState) (string, string, bool) {
+	if state != nil {
+		state.Processed++
+	}
+	plaintext, err := s.encryptionSvc.Decrypt(value)
+	if err != nil {
+		if state != nil {
+			state.Failed++
+		}
+		logrus.WithError(err).Debug("Failed to decrypt value during encryption key rotation")
+		return "", "", false
+	}
+	newValue, err := s.encryptionSvc.Encrypt(plaintext)
+	if err != nil {
+		if state != nil {
+			state.Failed++
+		}
+		logrus.WithError(err).Debug("Failed to encrypt value during encryption key rotation")
+		return "", "", false
+	}
+	return newValue, s.encryptionSvc.Hash(plaintext), true
+}
+
+// checkpoint saves the cursors so the rotation resumes after a crash, and reports progress.
+func (s *EncryptionKeyRotationService) checkpoint(ctx context.Context, task *Task, state *keyRotationState) error {
+	if err := s.saveState(ctx, state); err != nil {
+		return err
+	}
+	s.reportProgress(task, state)
+	return ctx.Err()
+}
+
+func (s *EncryptionKeyRotationService) reportProgress(task *Task, state *keyRotationState) {
+	// Records written during the rotation may push the count beyond the initial total
+	processed := min(state.Processed, state.Total)
+	if err := task.UpdateProgress(processed); err != nil {
+		logrus.WithError(err).Warn("Failed to update encryption key rotation progress")
+	}
+}
+
+func (s *EncryptionKeyRotationService) wait(ctx context.Context) bool {
+	select {
+	case <-time.After(keyRotationRetryInterval):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *EncryptionKeyRotationService) saveState(ctx context.Context, state *keyRotationState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize encryption key rotation state: %w", err)
+	}
+	setting := models.SystemSetting{
+		SettingKey:   encryptionKeyRotationSetting,
+		SettingValue: string(data),
+		Description:  "Online ENCRYPTION_KEY rotation state. Removed after ENCRYPTION_KEY is set to the new key.",
+	}
+	// A shutdown must not prevent saving the last cursor
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "setting_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"setting_value", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	return nil
+}
+
+func (s *EncryptionKeyRotationService) snapshot() *keyRotationSnapshot {
+	if s.syncer == nil {
+		return nil
+	}
+	return s.syncer.Get()
+}
+
+func (s *EncryptionKeyRotationService) invalidate(ctx context.Context) {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate encryption key rotation cache")
+	}
+}
+
+// keyCheck returns a value that identifies an encryption key without revealing it.
+func keyCheck(svc encryption.Service) string {
+	return svc.Hash(keyCheckPlaintext)
+}
+
+// HasPendingEncryptionKeyRotation reports whether an online ENCRYPTION_KEY rotation is recorded,
+// in which case ENCRYPTION_KEY alone may not decrypt the stored keys.
+func HasPendingEncryptionKeyRotation(db *gorm.DB) (bool, error) {
+	var count int64
+	if err := db.Model(&models.SystemSetting{}).Where("setting_key = ?", encryptionKeyRotationSetting).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -8,7 +8,6 @@ import (
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
 	"gpt-load/internal/syncer"
-	"gpt-load/internal/utils"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -70,7 +69,6 @@ func (gm *GroupManager) Initialize() error {
 		for _, group := range groups {
 			g := *group
 			g.EffectiveConfig = gm.settingsManager.GetEffectiveConfig(g.Config)
-			g.ProxyKeysMap = utils.StringToSet(g.ProxyKeys, ",")
 
 			// Parse header rules with error handling
 			if len(group.HeaderRules) > 0 {
@@ -82,6 +80,16 @@ func (gm *GroupManager) Initialize() error {
 				g.HeaderRuleList = []models.HeaderRule{}
 			}
 
+			// Parse budget rules with error handling
+			if len(group.Budgets) > 0 {
+				if err := json.Unmarshal(group.Budgets, &g.BudgetRuleList); err != nil {
+					logrus.WithError(err).WithField("group_name", g.Name).Warn("Failed to parse budget rules for group")
+					g.BudgetRuleList = []models.BudgetRule{}
+				}
+			} else {
+				g.BudgetRuleList = []models.BudgetRule{}
+			}
+
 			// Parse model redirect rules with error handling
 			g.ModelRedirectMap = make(map[string]string)
 			if len(group.ModelRedirectRules) > 0 {
@@ -112,6 +120,9 @@ func (gm *GroupManager) Initialize() error {
 						g.SubGroups[i] = sg
 						if subGroup, exists := groupByID[sg.SubGroupID]; exists {
 							g.SubGroups[i].SubGroupName = subGroup.Name
+							g.SubGroups[i].SupportedModels = resolveSubGroupModels(&sg, subGroup)
+							g.SubGroups[i].ActiveSchedule = parseSubGroupSchedule(sg.Schedule)
+							g.SubGroups[i].SubGroupState = subGroup.State
 						}
 					}
 				}
@@ -176,4 +187,5 @@ func (gm *GroupManager) Stop(ctx context.Context) {
 	if gm.syncer != nil {
 		gm.syncer.Stop()
 	}
+	gm.subGroupManager.Stop()
 }
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
@@ -58,6 +59,8 @@ type GroupService struct {
 	keyImportSvc          *KeyImportService
 	encryptionSvc         encryption.Service
 	aggregateGroupService *AggregateGroupService
+	auditService          *AuditService
+	proxyKeyService       *ProxyKeyService
 	channelRegistry       []string
 }
 
@@ -70,6 +73,8 @@ func NewGroupService(
 	keyImportSvc *KeyImportService,
 	encryptionSvc encryption.Service,
 	aggregateGroupService *AggregateGroupService,
+	auditService *AuditService,
+	proxyKeyService *ProxyKeyService,
 ) *GroupService {
 	return &GroupService{
 		db:                    db,
@@ -79,6 +84,8 @@ func NewGroupService(
 		keyImportSvc:          keyImportSvc,
 		encryptionSvc:         encryptionSvc,
 		aggregateGroupService: aggregateGroupService,
+		auditService:          auditService,
+		proxyKeyService:       proxyKeyService,
 		channelRegistry:       channel.GetChannels(),
 	}
 }
@@ -99,6 +106,7 @@ type GroupCreateParams struct {
 	ModelRedirectStrict bool
 	Config              map[string]any
 	HeaderRules         []models.HeaderRule
+	Budgets             []models.BudgetRule
 	ProxyKeys           string
 	SubGroups           []SubGroupInput
 }
@@ -121,6 +129,7 @@ type GroupUpdateParams struct {
 	ModelRedirectStrict *bool
 	Config              map[string]any
 	HeaderRules         *[]models.HeaderRule
+	Budgets             *[]models.BudgetRule
 	ProxyKeys           *string
 	SubGroups           *[]SubGroupInput
 }
@@ -163,15 +172,45 @@ type ConfigOption struct {
 
 // CreateGroup validates and persists a new group.
 func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams) (*models.Group, error) {
+	tx := s.db.WithContext(ctx).Begin()
+	if err := tx.Error; err != nil {
+		return nil, app_errors.ErrDatabase
+	}
+
+	group, entry, err := s.createGroup(ctx, tx, params)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	if err := s.groupManager.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
+	}
+	if len(utils.SplitAndTrim(params.ProxyKeys, ",")) > 0 {
+		s.proxyKeyService.InvalidateCache(ctx)
+	}
+
+	s.auditService.Record(ctx, entry)
+
+	return group, nil
+}
+
+// createGroup validates and stores a new group in tx and returns it with the audit entry of the change.
+// The caller commits the transaction and invalidates the group and proxy key caches.
+func (s *GroupService) createGroup(ctx context.Context, tx *gorm.DB, params GroupCreateParams) (*models.Group, AuditEntry, error) {
 	name := strings.TrimSpace(params.Name)
 	if !isValidGroupName(name) {
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_name", nil)
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_name", nil)
 	}
 
 	channelType := strings.TrimSpace(params.ChannelType)
 	if !s.isValidChannelType(channelType) {
 		supported := strings.Join(s.channelRegistry, ", ")
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": supported})
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": supported})
 	}
 
 	groupType := strings.TrimSpace(params.GroupType)
@@ -179,7 +218,7 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		groupType = "standard"
 	}
 	if groupType != "standard" && groupType != "aggregate" {
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_type", nil)
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_type", nil)
 	}
 
 	var cleanedUpstreams datatypes.JSON
@@ -194,41 +233,46 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 	case "standard":
 		testModel = strings.TrimSpace(params.TestModel)
 		if testModel == "" {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.test_model_required", nil)
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.test_model_required", nil)
 		}
 		cleaned, err := s.validateAndCleanUpstreams(params.Upstreams)
 		if err != nil {
-			return nil, err
+			return nil, AuditEntry{}, err
 		}
 		cleanedUpstreams = cleaned
 
 		validationEndpoint = strings.TrimSpace(params.ValidationEndpoint)
 		if !isValidValidationEndpoint(validationEndpoint) {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_test_path", nil)
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_test_path", nil)
 		}
 	}
 
 	cleanedConfig, err := s.validateAndCleanConfig(params.Config)
 	if err != nil {
-		return nil, err
+		return nil, AuditEntry{}, err
 	}
 
 	headerRulesJSON, err := s.normalizeHeaderRules(params.HeaderRules)
 	if err != nil {
-		return nil, err
+		return nil, AuditEntry{}, err
 	}
 	if headerRulesJSON == nil {
 		headerRulesJSON = datatypes.JSON("[]")
 	}
 
+	budgetsJSON, err := s.normalizeBudgetRules(params.Budgets)
+	if err != nil {
+		return nil, AuditEntry{}, err
+	}
+
 	// Validate model redirect rules for aggregate groups
 	if groupType == "aggregate" && len(params.ModelRedirectRules) > 0 {
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.aggregate_no_model_redirect", nil)
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.aggregate_no_model_redirect", nil)
 	}
 
 	// Validate model redirect rules format
 	if err := validateModelRedirectRules(params.ModelRedirectRules); err != nil {
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
 	}
 
 	group := models.Group{
@@ -246,28 +290,28 @@ func (s *GroupService) CreateGroup(ctx context.Context, params GroupCreateParams
 		ModelRedirectStrict: params.ModelRedirectStrict,
 		Config:              cleanedConfig,
 		HeaderRules:         headerRulesJSON,
-		ProxyKeys:           strings.TrimSpace(params.ProxyKeys),
-	}
-
-	tx := s.db.WithContext(ctx).Begin()
-	if err := tx.Error; err != nil {
-		return nil, app_errors.ErrDatabase
+		Budgets:             budgetsJSON,
+		State:               models.GroupStateEnabled,
 	}
 
 	if err := tx.Create(&group).Error; err != nil {
-		tx.Rollback()
-		return nil, app_errors.ParseDBError(err)
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, app_errors.ParseDBError(err)
-	}
-
-	if err := s.groupManager.Invalidate(); err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
+	addedProxyKeys, err := s.proxyKeyService.addKeys(ctx, tx, group.ID, utils.SplitAndTrim(params.ProxyKeys, ","))
+	if err != nil {
+		return nil, AuditEntry{}, err
 	}
 
-	return &group, nil
+	after := groupAuditSnapshot(&group)
+	after["proxy_keys_added"] = addedProxyKeys
+	return &group, AuditEntry{
+		Action:     "group.create",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		After:      after,
+	}, nil
 }
 
 // ListGroups returns all groups without sub-group relations.
@@ -324,6 +368,11 @@ func (s *GroupService) ReorderGroups(ctx context.Context, items []GroupReorderIt
 		return NewI18nError(app_errors.ErrValidation, "validation.reorder_group_not_found", nil)
 	}
 
+	var before []models.Group
+	if err := tx.Select("id", "sort").Where("id IN ?", ids).Find(&before).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+
 	for _, item := range items {
 		result := tx.Model(&models.Group{}).Where("id = ?", item.ID).Update("sort", item.Sort)
 		if result.Error != nil {
@@ -343,26 +392,66 @@ func (s *GroupService) ReorderGroups(ctx context.Context, items []GroupReorderIt
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
 
+	beforeSorts := make(map[string]int, len(before))
+	for _, g := range before {
+		beforeSorts[fmt.Sprint(g.ID)] = g.Sort
+	}
+	afterSorts := make(map[string]int, len(items))
+	for _, item := range items {
+		afterSorts[fmt.Sprint(item.ID)] = item.Sort
+	}
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "group.reorder",
+		TargetType: models.AuditTargetGroup,
+		Before:     beforeSorts,
+		After:      afterSorts,
+	})
+
 	return nil
 }
 
 // UpdateGroup validates and updates an existing group.
 func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpdateParams) (*models.Group, error) {
-	var group models.Group
-	if err := s.db.WithContext(ctx).First(&group, id).Error; err != nil {
-		return nil, app_errors.ParseDBError(err)
-	}
-
 	tx := s.db.WithContext(ctx).Begin()
 	if err := tx.Error; err != nil {
 		return nil, app_errors.ErrDatabase
 	}
 	defer tx.Rollback()
 
+	group, entry, err := s.updateGroup(ctx, tx, id, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, app_errors.ErrDatabase
+	}
+
+	if err := s.groupManager.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
+	}
+	if params.ProxyKeys != nil && len(utils.SplitAndTrim(*params.ProxyKeys, ",")) > 0 {
+		s.proxyKeyService.InvalidateCache(ctx)
+	}
+
+	s.auditService.Record(ctx, entry)
+
+	return group, nil
+}
+
+// updateGroup validates and stores the changes of a group in tx and returns it with the audit entry of the change.
+// The caller commits the transaction and invalidates the group and proxy key caches.
+func (s *GroupService) updateGroup(ctx context.Context, tx *gorm.DB, id uint, params GroupUpdateParams) (*models.Group, AuditEntry, error) {
+	var group models.Group
+	if err := tx.First(&group, id).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+	before := groupAuditSnapshot(&group)
+
 	if params.Name != nil {
 		cleanedName := strings.TrimSpace(*params.Name)
 		if !isValidGroupName(cleanedName) {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_name", nil)
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_name", nil)
 		}
 		group.Name = cleanedName
 	}
@@ -378,16 +467,16 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 	if params.HasUpstreams {
 		cleanedUpstreams, err := s.validateAndCleanUpstreams(params.Upstreams)
 		if err != nil {
-			return nil, err
+			return nil, AuditEntry{}, err
 		}
 		group.Upstreams = cleanedUpstreams
 	}
 
 	// Check if this group is used as a sub-group in aggregate groups before allowing critical changes
 	if group.GroupType != "aggregate" && (params.ChannelType != nil || params.ValidationEndpoint != nil) {
-		count, err := s.aggregateGroupService.CountAggregateGroupsUsingSubGroup(ctx, group.ID)
+		count, err := countAggregateGroupsUsingSubGroup(tx, group.ID)
 		if err != nil {
-			return nil, err
+			return nil, AuditEntry{}, err
 		}
 
 		if count > 0 {
@@ -395,7 +484,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 			if params.ChannelType != nil {
 				cleanedChannelType := strings.TrimSpace(*params.ChannelType)
 				if group.ChannelType != cleanedChannelType {
-					return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_group_referenced_cannot_modify",
+					return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.sub_group_referenced_cannot_modify",
 						map[string]any{"count": count})
 				}
 			}
@@ -404,7 +493,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 			if params.ValidationEndpoint != nil {
 				cleanedValidationEndpoint := strings.TrimSpace(*params.ValidationEndpoint)
 				if group.ValidationEndpoint != cleanedValidationEndpoint {
-					return nil, NewI18nError(app_errors.ErrValidation, "validation.sub_group_referenced_cannot_modify",
+					return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.sub_group_referenced_cannot_modify",
 						map[string]any{"count": count})
 				}
 			}
@@ -415,7 +504,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		cleanedChannelType := strings.TrimSpace(*params.ChannelType)
 		if !s.isValidChannelType(cleanedChannelType) {
 			supported := strings.Join(s.channelRegistry, ", ")
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": supported})
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": supported})
 		}
 		group.ChannelType = cleanedChannelType
 	}
@@ -427,7 +516,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 	if params.HasTestModel {
 		cleanedTestModel := strings.TrimSpace(params.TestModel)
 		if cleanedTestModel == "" {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.test_model_empty", nil)
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.test_model_empty", nil)
 		}
 		group.TestModel = cleanedTestModel
 	}
@@ -438,13 +527,13 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 
 	// Validate model redirect rules for aggregate groups
 	if group.GroupType == "aggregate" && params.ModelRedirectRules != nil && len(params.ModelRedirectRules) > 0 {
-		return nil, NewI18nError(app_errors.ErrValidation, "validation.aggregate_no_model_redirect", nil)
+		return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.aggregate_no_model_redirect", nil)
 	}
 
 	// Validate model redirect rules format
 	if params.ModelRedirectRules != nil {
 		if err := validateModelRedirectRules(params.ModelRedirectRules); err != nil {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_model_redirect", map[string]any{"error": err.Error()})
 		}
 		group.ModelRedirectRules = convertToJSONMap(params.ModelRedirectRules)
 	}
@@ -456,7 +545,7 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 	if params.ValidationEndpoint != nil {
 		validationEndpoint := strings.TrimSpace(*params.ValidationEndpoint)
 		if !isValidValidationEndpoint(validationEndpoint) {
-			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_test_path", nil)
+			return nil, AuditEntry{}, NewI18nError(app_errors.ErrValidation, "validation.invalid_test_path", nil)
 		}
 		group.ValidationEndpoint = validationEndpoint
 	}
@@ -464,19 +553,15 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 	if params.Config != nil {
 		cleanedConfig, err := s.validateAndCleanConfig(params.Config)
 		if err != nil {
-			return nil, err
+			return nil, AuditEntry{}, err
 		}
 		group.Config = cleanedConfig
 	}
 
-	if params.ProxyKeys != nil {
-		group.ProxyKeys = strings.TrimSpace(*params.ProxyKeys)
-	}
-
 	if params.HeaderRules != nil {
 		headerRulesJSON, err := s.normalizeHeaderRules(*params.HeaderRules)
 		if err != nil {
-			return nil, err
+			return nil, AuditEntry{}, err
 		}
 		if headerRulesJSON == nil {
 			headerRulesJSON = datatypes.JSON("[]")
@@ -484,33 +569,124 @@ func (s *GroupService) UpdateGroup(ctx context.Context, id uint, params GroupUpd
 		group.HeaderRules = headerRulesJSON
 	}
 
+	if params.Budgets != nil {
+		budgetsJSON, err := s.normalizeBudgetRules(*params.Budgets)
+		if err != nil {
+			return nil, AuditEntry{}, err
+		}
+		group.Budgets = budgetsJSON
+	}
+
 	if err := tx.Save(&group).Error; err != nil {
-		return nil, app_errors.ParseDBError(err)
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, app_errors.ErrDatabase
+	// Proxy keys entered on the group form are added to the existing ones, which are only stored as hashes
+	addedProxyKeys := 0
+	if params.ProxyKeys != nil {
+		added, err := s.proxyKeyService.addKeys(ctx, tx, group.ID, utils.SplitAndTrim(*params.ProxyKeys, ","))
+		if err != nil {
+			return nil, AuditEntry{}, err
+		}
+		addedProxyKeys = added
+	}
+
+	after := groupAuditSnapshot(&group)
+	if addedProxyKeys > 0 {
+		after["proxy_keys_added"] = addedProxyKeys
+	}
+	return &group, AuditEntry{
+		Action:     "group.update",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		Before:     before,
+		After:      after,
+	}, nil
+}
+
+// GroupStateParams captures the state of a group and the error served while it is not enabled.
+type GroupStateParams struct {
+	State      string
+	Message    string
+	StatusCode int
+}
+
+// UpdateGroupState changes whether a group accepts new proxy requests. The change reaches
+// every node through the group cache, requests already in flight are left to finish.
+func (s *GroupService) UpdateGroupState(ctx context.Context, id uint, params GroupStateParams) (*models.Group, error) {
+	group, entry, err := s.updateGroupState(s.db.WithContext(ctx), id, params)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.groupManager.Invalidate(); err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache after updating group state")
 	}
 
-	return &group, nil
+	s.auditService.Record(ctx, entry)
+
+	return group, nil
 }
 
-// DeleteGroup removes a group and associated resources.
-func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
-	var apiKeys []models.APIKey
-	if err := s.db.WithContext(ctx).Where("group_id = ?", id).Find(&apiKeys).Error; err != nil {
-		return app_errors.ParseDBError(err)
+// updateGroupState stores the state of a group through db, which may be a transaction,
+// and returns the group with the audit entry of the change. The caller invalidates the group cache.
+func (s *GroupService) updateGroupState(db *gorm.DB, id uint, params GroupStateParams) (*models.Group, AuditEntry, error) {
+	params, err := normalizeGroupStateParams(params)
+	if err != nil {
+		return nil, AuditEntry{}, err
 	}
 
-	keyIDs := make([]uint, 0, len(apiKeys))
-	for _, key := range apiKeys {
-		keyIDs = append(keyIDs, key.ID)
+	var group models.Group
+	if err := db.First(&group, id).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+	before := groupStateSnapshot(&group)
+
+	group.State = params.State
+	group.StateMessage = params.Message
+	group.StateStatusCode = params.StatusCode
+	if err := db.Model(&group).Select("state", "state_message", "state_status_code").Updates(&group).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	return &group, AuditEntry{
+		Action:     "group.update_state",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		Before:     before,
+		After:      groupStateSnapshot(&group),
+	}, nil
+}
+
+// normalizeGroupStateParams validates a group state and trims its message.
+func normalizeGroupStateParams(params GroupStateParams) (GroupStateParams, error) {
+	switch params.State {
+	case models.GroupStateEnabled, models.GroupStateDraining, models.GroupStateDisabled, models.GroupStateMaintenance:
+	default:
+		return params, NewI18nError(app_errors.ErrValidation, "validation.invalid_group_state", nil)
+	}
+	if params.StatusCode != 0 && (params.StatusCode < 400 || params.StatusCode > 599) {
+		return params, NewI18nError(app_errors.ErrValidation, "validation.invalid_state_status_code", nil)
+	}
+	params.Message = strings.TrimSpace(params.Message)
+	if utf8.RuneCountInString(params.Message) > 512 {
+		return params, NewI18nError(app_errors.ErrValidation, "validation.state_message_too_long", nil)
+	}
+	return params, nil
+}
+
+func groupStateSnapshot(group *models.Group) map[string]any {
+	return map[string]any{
+		"state":             group.State,
+		"state_message":     group.StateMessage,
+		"state_status_code": group.StateStatusCode,
 	}
+}
 
+// DeleteGroup removes a group and associated resources.
+func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
 	tx := s.db.WithContext(ctx).Begin()
 	if err := tx.Error; err != nil {
 		return app_errors.ErrDatabase
@@ -521,21 +697,9 @@ func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
 		}
 	}()
 
-	var group models.Group
-	if err := tx.First(&group, id).Error; err != nil {
-		return app_errors.ParseDBError(err)
-	}
-
-	if err := tx.Where("group_id = ? OR sub_group_id = ?", id, id).Delete(&models.GroupSubGroup{}).Error; err != nil {
-		return app_errors.ParseDBError(err)
-	}
-
-	if err := tx.Where("group_id = ?", id).Delete(&models.APIKey{}).Error; err != nil {
-		return app_errors.ErrDatabase
-	}
-
-	if err := tx.Delete(&models.Group{}, id).Error; err != nil {
-		return app_errors.ParseDBError(err)
+	keyIDs, entry, err := s.deleteGroup(tx, id)
+	if err != nil {
+		return err
 	}
 
 	if len(keyIDs) > 0 {
@@ -556,10 +720,58 @@ func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
 	if err := s.groupManager.Invalidate(); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
+	s.proxyKeyService.InvalidateCache(ctx)
+
+	s.auditService.Record(ctx, entry)
 
 	return nil
 }
 
+// deleteGroup removes a group and associated resources in tx and returns the IDs of its keys,
+// which the caller removes from the key pool, with the audit entry of the change.
+// The caller commits the transaction and invalidates the group and proxy key caches.
+func (s *GroupService) deleteGroup(tx *gorm.DB, id uint) ([]uint, AuditEntry, error) {
+	var group models.Group
+	if err := tx.First(&group, id).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	var keyIDs []uint
+	if err := tx.Model(&models.APIKey{}).Where("group_id = ?", id).Pluck("id", &keyIDs).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	if err := tx.Where("group_id = ? OR sub_group_id = ?", id, id).Delete(&models.GroupSubGroup{}).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	if err := tx.Where("group_id = ?", id).Delete(&models.APIKey{}).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ErrDatabase
+	}
+
+	if err := tx.Where("group_id = ?", id).Delete(&models.ProxyKey{}).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	if err := tx.Where("group_id = ?", id).Delete(&models.KeySource{}).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	if err := tx.Delete(&models.Group{}, id).Error; err != nil {
+		return nil, AuditEntry{}, app_errors.ParseDBError(err)
+	}
+
+	before := groupAuditSnapshot(&group)
+	before["key_count"] = len(keyIDs)
+	return keyIDs, AuditEntry{
+		Action:     "group.delete",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		Before:     before,
+	}, nil
+}
+
 // CopyGroup duplicates a group and optionally copies active keys.
 func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKeysOption string) (*models.Group, error) {
 	option := strings.TrimSpace(copyKeysOption)
@@ -594,11 +806,20 @@ func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKe
 	newGroup.CreatedAt = time.Time{}
 	newGroup.UpdatedAt = time.Time{}
 	newGroup.LastValidatedAt = nil
+	newGroup.LastActiveValidatedAt = nil
+	newGroup.ProxyKeys = ""
 
 	if err := tx.Create(&newGroup).Error; err != nil {
 		return nil, app_errors.ParseDBError(err)
 	}
 
+	if err := s.proxyKeyService.CopyGroupKeys(tx, sourceGroupID, newGroup.ID); err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if _, err := s.proxyKeyService.addKeys(ctx, tx, newGroup.ID, utils.SplitAndTrim(sourceGroup.ProxyKeys, ",")); err != nil {
+		return nil, err
+	}
+
 	var sourceKeyValues []string
 	if option != "none" {
 		var sourceKeys []models.APIKey
@@ -628,6 +849,7 @@ func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKe
 	if err := s.groupManager.Invalidate(); err != nil {
 		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate group cache")
 	}
+	s.proxyKeyService.InvalidateCache(ctx)
 
 	if len(sourceKeyValues) > 0 {
 		keysText := strings.Join(sourceKeyValues, "\n")
@@ -644,6 +866,17 @@ func (s *GroupService) CopyGroup(ctx context.Context, sourceGroupID uint, copyKe
 		}
 	}
 
+	after := groupAuditSnapshot(&newGroup)
+	after["copied_from"] = sourceGroup.Name
+	after["copied_key_count"] = len(sourceKeyValues)
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "group.copy",
+		TargetType: models.AuditTargetGroup,
+		TargetID:   newGroup.ID,
+		TargetName: newGroup.Name,
+		After:      after,
+	})
+
 	return &newGroup, nil
 }
 
@@ -933,6 +1166,53 @@ func (s *GroupService) normalizeHeaderRules(rules []models.HeaderRule) (datatype
 	return datatypes.JSON(headerRulesBytes), nil
 }
 
+// normalizeBudgetRules validates budget rules and rejects duplicate period and metric pairs.
+func (s *GroupService) normalizeBudgetRules(rules []models.BudgetRule) (datatypes.JSON, error) {
+	normalized := make([]models.BudgetRule, 0, len(rules))
+	seen := make(map[string]bool)
+
+	for _, rule := range rules {
+		period := strings.ToLower(strings.TrimSpace(rule.Period))
+		metric := strings.ToLower(strings.TrimSpace(rule.Metric))
+
+		if period != models.BudgetPeriodDaily && period != models.BudgetPeriodMonthly {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_budget_period", map[string]any{"period": rule.Period})
+		}
+		if metric != models.BudgetMetricRequests && metric != models.BudgetMetricTokens && metric != models.BudgetMetricCost {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_budget_metric", map[string]any{"metric": rule.Metric})
+		}
+		if rule.SoftLimit < 0 || rule.HardLimit < 0 {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.budget_limit_negative", nil)
+		}
+		if rule.SoftLimit == 0 && rule.HardLimit == 0 {
+			continue
+		}
+		if rule.SoftLimit > 0 && rule.HardLimit > 0 && rule.SoftLimit > rule.HardLimit {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.budget_soft_exceeds_hard", nil)
+		}
+
+		pairKey := period + ":" + metric
+		if seen[pairKey] {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.duplicate_budget", map[string]any{"period": period, "metric": metric})
+		}
+		seen[pairKey] = true
+
+		normalized = append(normalized, models.BudgetRule{
+			Period:    period,
+			Metric:    metric,
+			SoftLimit: rule.SoftLimit,
+			HardLimit: rule.HardLimit,
+		})
+	}
+
+	budgetsBytes, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, NewI18nError(app_errors.ErrInternalServer, "error.process_budgets", map[string]any{"error": err.Error()})
+	}
+
+	return datatypes.JSON(budgetsBytes), nil
+}
+
 // validateAndCleanUpstreams validates upstream definitions.
 func (s *GroupService) validateAndCleanUpstreams(upstreams json.RawMessage) (datatypes.JSON, error) {
 	if len(upstreams) == 0 {
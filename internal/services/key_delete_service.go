@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"gpt-load/internal/models"
 
@@ -38,26 +39,28 @@ func (s *KeyDeleteService) StartDeleteTask(group *models.Group, keysText string)
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
 
-	initialStatus, err := s.TaskService.StartTask(TaskTypeKeyDelete, group.Name, len(keys))
+	task, err := s.TaskService.StartTask(TaskTypeKeyDelete, group.Name, len(keys))
 	if err != nil {
 		return nil, err
 	}
 
-	go s.runDelete(group, keys)
+	go s.runDelete(task, group, keys)
 
-	return initialStatus, nil
+	status := task.Status()
+	return &status, nil
 }
 
-func (s *KeyDeleteService) runDelete(group *models.Group, keys []string) {
+func (s *KeyDeleteService) runDelete(task *Task, group *models.Group, keys []string) {
 	progressCallback := func(processed int) {
-		if err := s.TaskService.UpdateProgress(processed); err != nil {
+		if err := task.UpdateProgress(processed); err != nil {
 			logrus.Warnf("Failed to update task progress for group %d: %v", group.ID, err)
 		}
 	}
 
-	deletedCount, ignoredCount, err := s.processAndDeleteKeys(group.ID, keys, progressCallback)
+	deletedCount, ignoredCount, err := s.processAndDeleteKeys(task.Context(), group.ID, keys, progressCallback)
 	if err != nil {
-		if endErr := s.TaskService.EndTask(nil, err); endErr != nil {
+		result := KeyDeleteResult{DeletedCount: deletedCount, IgnoredCount: ignoredCount}
+		if endErr := task.End(result, err); endErr != nil {
 			logrus.Errorf("Failed to end task with error for group %d: %v (original error: %v)", group.ID, endErr, err)
 		}
 		return
@@ -68,13 +71,14 @@ func (s *KeyDeleteService) runDelete(group *models.Group, keys []string) {
 		IgnoredCount: ignoredCount,
 	}
 
-	if endErr := s.TaskService.EndTask(result, nil); endErr != nil {
+	if endErr := task.End(result, nil); endErr != nil {
 		logrus.Errorf("Failed to end task with success result for group %d: %v", group.ID, endErr)
 	}
 }
 
 // processAndDeleteKeys is the core function for deleting keys with progress tracking.
 func (s *KeyDeleteService) processAndDeleteKeys(
+	ctx context.Context,
 	groupID uint,
 	keys []string,
 	progressCallback func(processed int),
@@ -82,6 +86,10 @@ func (s *KeyDeleteService) processAndDeleteKeys(
 	var totalDeletedCount int64
 
 	for i := 0; i < len(keys); i += deleteChunkSize {
+		if err := ctx.Err(); err != nil {
+			return int(totalDeletedCount), len(keys) - int(totalDeletedCount), err
+		}
+
 		end := i + deleteChunkSize
 		if end > len(keys) {
 			end = len(keys)
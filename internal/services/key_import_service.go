@@ -29,31 +29,42 @@ func NewKeyImportService(taskService *TaskService, keyService *KeyService) *KeyI
 
 // StartImportTask initiates a new asynchronous key import task.
 func (s *KeyImportService) StartImportTask(group *models.Group, keysText string) (*TaskStatus, error) {
-	keys := s.KeyService.ParseKeysFromText(keysText)
+	keys, err := s.KeyService.ParseKeyEntriesFromText(keysText)
+	if err != nil {
+		return nil, err
+	}
+	return s.StartImportEntriesTask(group, keys)
+}
+
+// StartImportEntriesTask initiates an asynchronous import of already parsed keys,
+// such as keys read from an uploaded CSV file or fetched from a URL.
+func (s *KeyImportService) StartImportEntriesTask(group *models.Group, keys []KeyEntry) (*TaskStatus, error) {
 	if len(keys) == 0 {
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
 
-	initialStatus, err := s.TaskService.StartTask(TaskTypeKeyImport, group.Name, len(keys))
+	task, err := s.TaskService.StartTask(TaskTypeKeyImport, group.Name, len(keys))
 	if err != nil {
 		return nil, err
 	}
 
-	go s.runImport(group, keys)
+	go s.runImport(task, group, keys)
 
-	return initialStatus, nil
+	status := task.Status()
+	return &status, nil
 }
 
-func (s *KeyImportService) runImport(group *models.Group, keys []string) {
+func (s *KeyImportService) runImport(task *Task, group *models.Group, keys []KeyEntry) {
 	progressCallback := func(processed int) {
-		if err := s.TaskService.UpdateProgress(processed); err != nil {
+		if err := task.UpdateProgress(processed); err != nil {
 			logrus.Warnf("Failed to update task progress for group %d: %v", group.ID, err)
 		}
 	}
 
-	addedCount, ignoredCount, err := s.KeyService.processAndCreateKeys(group.ID, keys, progressCallback)
+	addedCount, ignoredCount, err := s.KeyService.processAndCreateKeys(task.Context(), group.ID, keys, progressCallback)
 	if err != nil {
-		if endErr := s.TaskService.EndTask(nil, err); endErr != nil {
+		result := KeyImportResult{AddedCount: addedCount, IgnoredCount: ignoredCount}
+		if endErr := task.End(result, err); endErr != nil {
 			logrus.Errorf("Failed to end task with error for group %d: %v (original error: %v)", group.ID, endErr, err)
 		}
 		return
@@ -64,7 +75,7 @@ func (s *KeyImportService) runImport(group *models.Group, keys []string) {
 		IgnoredCount: ignoredCount,
 	}
 
-	if endErr := s.TaskService.EndTask(result, nil); endErr != nil {
+	if endErr := task.End(result, nil); endErr != nil {
 		logrus.Errorf("Failed to end task with success result for group %d: %v", group.ID, endErr)
 	}
 }
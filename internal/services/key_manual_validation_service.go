@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"gpt-load/internal/config"
 	"gpt-load/internal/encryption"
@@ -58,18 +59,19 @@ func (s *KeyManualValidationService) StartValidationTask(group *models.Group, st
 		return nil, fmt.Errorf("no keys to validate in group %s", group.Name)
 	}
 
-	taskStatus, err := s.TaskService.StartTask(TaskTypeKeyValidation, group.Name, len(keys))
+	task, err := s.TaskService.StartTask(TaskTypeKeyValidation, group.Name, len(keys))
 	if err != nil {
 		return nil, err
 	}
 
 	// Run the validation in a separate goroutine
-	go s.runValidation(group, keys, status)
+	go s.runValidation(task, group, keys, status)
 
-	return taskStatus, nil
+	taskStatus := task.Status()
+	return &taskStatus, nil
 }
 
-func (s *KeyManualValidationService) runValidation(group *models.Group, keys []models.APIKey, status string) {
+func (s *KeyManualValidationService) runValidation(task *Task, group *models.Group, keys []models.APIKey, status string) {
 	logFields := logrus.Fields{
 		"group":  group.Name,
 		"status": status,
@@ -87,7 +89,7 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 	var wg sync.WaitGroup
 	for range concurrency {
 		wg.Add(1)
-		go s.validationWorker(&wg, group, jobs, results)
+		go s.validationWorker(task.Context(), &wg, group, jobs, results)
 	}
 
 	for _, key := range keys {
@@ -112,7 +114,7 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 
 		// Throttle progress updates to once per second
 		if time.Since(lastUpdateTime) > time.Second {
-			if err := s.TaskService.UpdateProgress(processedCount); err != nil {
+			if err := task.UpdateProgress(processedCount); err != nil {
 				logrus.Warnf("Failed to update task progress: %v", err)
 			}
 			lastUpdateTime = time.Now()
@@ -120,27 +122,31 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 	}
 
 	// Ensure the final progress is always updated
-	if err := s.TaskService.UpdateProgress(processedCount); err != nil {
+	if err := task.UpdateProgress(processedCount); err != nil {
 		logrus.Warnf("Failed to update final task progress: %v", err)
 	}
 
 	result := ManualValidationResult{
-		TotalKeys:   len(keys),
+		TotalKeys:   processedCount,
 		ValidKeys:   validCount,
-		InvalidKeys: len(keys) - validCount,
+		InvalidKeys: processedCount - validCount,
 	}
 
-	// End the task and store the final result
-	if err := s.TaskService.EndTask(result, nil); err != nil {
+	// End the task and store the final result, which only covers the validated keys when it was cancelled
+	if err := task.End(result, task.Context().Err()); err != nil {
 		logrus.Errorf("Failed to end task for group %s: %v", group.Name, err)
 	}
 	logrus.Infof("Manual validation finished for group %s: %+v", group.Name, result)
 }
 
 // validationResult 包含验证结果信息
-func (s *KeyManualValidationService) validationWorker(wg *sync.WaitGroup, group *models.Group, jobs <-chan models.APIKey, results chan<- bool) {
+func (s *KeyManualValidationService) validationWorker(ctx context.Context, wg *sync.WaitGroup, group *models.Group, jobs <-chan models.APIKey, results chan<- bool) {
 	defer wg.Done()
 	for key := range jobs {
+		if ctx.Err() != nil {
+			continue
+		}
+
 		// Decrypt the key before validation
 		decryptedKey, err := s.EncryptionSvc.Decrypt(key.KeyValue)
 		if err != nil {
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gpt-load/internal/encryption"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	rewrapStatusKey = "encryption:rewrap:status"
+	rewrapLockKey   = "encryption:rewrap:lock"
+	rewrapLockTTL   = 10 * time.Minute
+	rewrapBatchSize = 500
+)
+
+// KeyRewrapStatus describes the master key state and the progress of the last re-wrap run.
+type KeyRewrapStatus struct {
+	Enabled        bool       `json:"enabled"`
+	Provider       string     `json:"provider"`
+	CurrentVersion uint32     `json:"current_version"`
+	IsRunning      bool       `json:"is_running"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	Processed      int        `json:"processed"`
+	Rewrapped      int        `json:"rewrapped"`
+	Failed         int        `json:"failed"`
+	Error          string     `json:"error,omitempty"`
+	StaleKeys      int64      `json:"stale_keys"`
+	StaleLogs      int64      `json:"stale_logs"`
+	LegacyKeys     int64      `json:"legacy_keys"`
+}
+
+// KeyRewrapService re-wraps the data keys of stored ciphertexts with the current master key
+// version, so old master key versions can be retired without taking the service offline.
+// Only the wrapped data keys change, the encrypted records are not decrypted.
+type KeyRewrapService struct {
+	db            *gorm.DB
+	store         store.Store
+	encryptionSvc encryption.Service
+	configManager types.ConfigManager
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewKeyRewrapService creates a new KeyRewrapService.
+func NewKeyRewrapService(db *gorm.DB, store store.Store, encryptionSvc encryption.Service, configManager types.ConfigManager) *KeyRewrapService {
+	return &KeyRewrapService{
+		db:            db,
+		store:         store,
+		encryptionSvc: encryptionSvc,
+		configManager: configManager,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start runs the periodic re-wrap check when envelope encryption is enabled.
+func (s *KeyRewrapService) Start() {
+	interval := s.configManager.GetEncryptionConfig().RewrapIntervalMinutes
+	if _, ok := s.envelope(); !ok || interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+
+		s.runOnce()
+		for {
+			select {
+			case <-ticker.C:
+				s.runOnce()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+	logrus.Debug("Key rewrap service started")
+}
+
+// Stop stops the periodic check and any running re-wrap.
+func (s *KeyRewrapService) Stop(ctx context.Context) {
+	close(s.stopCh)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("KeyRewrapService stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("KeyRewrapService stop timed out.")
+	}
+}
+
+// TriggerRewrap starts a re-wrap run in the background.
+func (s *KeyRewrapService) TriggerRewrap(ctx context.Context) error {
+	if _, ok := s.envelope(); !ok {
+		return NewI18nError(app_errors.ErrValidation, "encryption.envelope_disabled", nil)
+	}
+
+	status, err := s.loadStatus()
+	if err != nil {
+		return err
+	}
+	if status.IsRunning {
+		return NewI18nError(app_errors.ErrTaskInProgress, "encryption.rewrap_running", nil)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runOnce()
+	}()
+	return nil
+}
+
+// GetStatus returns the master key version, the last run and the number of records still
+// wrapped with an older master key version.
+func (s *KeyRewrapService) GetStatus(ctx context.Context) (*KeyRewrapStatus, error) {
+	envelopeSvc, ok := s.envelope()
+	if !ok {
+		return &KeyRewrapStatus{}, nil
+	}
+
+	status, err := s.loadStatus()
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = true
+	status.Provider = envelopeSvc.ProviderName()
+
+	current, err := envelopeSvc.CurrentKeyVersion(ctx)
+	if err != nil {
+		return nil, NewI18nError(app_errors.ErrBadGateway, "encryption.provider_unavailable", map[string]any{"error": err.Error()})
+	}
+	status.CurrentVersion = current
+
+	prefix := encryption.EnvelopePrefix() + "%"
+	currentPrefix := encryption.EnvelopeVersionPrefix(current) + "%"
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("key_value LIKE ? AND key_value NOT LIKE ?", prefix, currentPrefix).
+		Count(&status.StaleKeys).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.RequestLog{}).
+		Where("key_value LIKE ? AND key_value NOT LIKE ?", prefix, currentPrefix).
+		Count(&status.StaleLogs).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("key_value NOT LIKE ?", prefix).
+		Count(&status.LegacyKeys).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	return status, nil
+}
+
+// runOnce re-wraps all stale records unless another node is already doing so.
+func (s *KeyRewrapService) runOnce() {
+	envelopeSvc, ok := s.envelope()
+	if !ok {
+		return
+	}
+
+	acquired, err := s.store.SetNX(rewrapLockKey, []byte("1"), rewrapLockTTL)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to acquire key rewrap lock")
+		return
+	}
+	if !acquired {
+		logrus.Debug("Key rewrap is already running on another node")
+		return
+	}
+	defer func() {
+		if err := s.store.Delete(rewrapLockKey); err != nil {
+			logrus.WithError(err).Warn("Failed to release key rewrap lock")
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	startedAt := time.Now()
+	status := &KeyRewrapStatus{IsRunning: true, StartedAt: &startedAt}
+
+	current, err := envelopeSvc.CurrentKeyVersion(ctx)
+	if err == nil {
+		status.CurrentVersion = current
+		s.saveStatus(status)
+		err = s.rewrapAPIKeys(ctx, envelopeSvc, current, status)
+		if err == nil {
+			err = s.rewrapRequestLogs(ctx, envelopeSvc, current, status)
+		}
+	}
+
+	finishedAt := time.Now()
+	status.IsRunning = false
+	status.FinishedAt = &finishedAt
+	if err != nil {
+		status.Error = err.Error()
+		logrus.WithError(err).Error("Key rewrap failed")
+	}
+	s.saveStatus(status)
+
+	if status.Rewrapped > 0 || status.Failed > 0 {
+		logrus.WithFields(logrus.Fields{
+			"version":   status.CurrentVersion,
+			"rewrapped": status.Rewrapped,
+			"failed":    status.Failed,
+			"duration":  finishedAt.Sub(startedAt).String(),
+		}).Info("Key rewrap finished")
+	}
+}
+
+// rewrapAPIKeys re-wraps the api_keys table and the key values cached in the store.
+func (s *KeyRewrapService) rewrapAPIKeys(ctx context.Context, envelopeSvc encryption.EnvelopeService, current uint32, status *KeyRewrapStatus) error {
+	var lastID uint
+	for {
+		var keys []models.APIKey
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("id > ? AND key_value LIKE ? AND key_value NOT LIKE ?", lastID, encryption.EnvelopePrefix()+"%", encryption.EnvelopeVersionPrefix(current)+"%").
+			Order("id asc").Limit(rewrapBatchSize).Find(&keys).Error; err != nil {
+			return fmt.Errorf("failed to load api keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		for _, key := range keys {
+			lastID = key.ID
+			newValue, ok := s.rewrapValue(ctx, envelopeSvc, key.KeyValue, status)
+			if !ok {
+				continue
+			}
+			result := s.db.WithContext(ctx).Model(&models.APIKey{}).
+				Where("id = ? AND key_value = ?", key.ID, key.KeyValue).
+				Update("key_value", newValue)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update api key %d: %w", key.ID, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			status.Rewrapped++
+
+			keyHashKey := fmt.Sprintf("key:%d", key.ID)
+			if exists, err := s.store.Exists(keyHashKey); err == nil && exists {
+				if err := s.store.HSet(keyHashKey, map[string]any{"key_string": newValue}); err != nil {
+					logrus.WithError(err).WithField("keyID", key.ID).Warn("Failed to update rewrapped key in store")
+				}
+			}
+		}
+
+		if err := s.checkpoint(ctx, status); err != nil {
+			return err
+		}
+	}
+}
+
+// rewrapRequestLogs re-wraps the key values recorded in request_logs.
+func (s *KeyRewrapService) rewrapRequestLogs(ctx context.Context, envelopeSvc encryption.EnvelopeService, current uint32, status *KeyRewrapStatus) error {
+	lastID := ""
+	for {
+		var logs []models.RequestLog
+		if err := s.db.WithContext(ctx).Select("id, key_value").
+			Where("id > ? AND key_value LIKE ? AND key_value NOT LIKE ?", lastID, encryption.EnvelopePrefix()+"%", encryption.EnvelopeVersionPrefix(current)+"%").
+			Order("id asc").Limit(rewrapBatchSize).Find(&logs).Error; err != nil {
+			return fmt.Errorf("failed to load request logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		for _, log := range logs {
+			lastID = log.ID
+			newValue, ok := s.rewrapValue(ctx, envelopeSvc, log.KeyValue, status)
+			if !ok {
+				continue
+			}
+			result := s.db.WithContext(ctx).Model(&models.RequestLog{}).
+				Where("id = ? AND key_value = ?", log.ID, log.KeyValue).
+				Update("key_value", newValue)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update request log %s: %w", log.ID, result.Error)
+			}
+			if result.RowsAffected > 0 {
+				status.Rewrapped++
+			}
+		}
+
+		if err := s.checkpoint(ctx, status); err != nil {
+			return err
+		}
+	}
+}
+
+// rewrapValue re-wraps one ciphertext. Failures are counted and the record is left unchanged.
+func (s *KeyRewrapService) rewrapValue(ctx context.Context, envelopeSvc encryption.EnvelopeService, value string, status *KeyRewrapStatus) (string, bool) {
+	status.Processed++
+	newValue, changed, err := envelopeSvc.Rewrap(ctx, value)
+	if err != nil {
+		status.Failed++
+		logrus.WithError(err).Debug("Failed to rewrap data key")
+		return "", false
+	}
+	return newValue, changed
+}
+
+// checkpoint publishes progress, extends the lock and stops when the service shuts down.
+func (s *KeyRewrapService) checkpoint(ctx context.Context, status *KeyRewrapStatus) error {
+	s.saveStatus(status)
+	if err := s.store.Set(rewrapLockKey, []byte("1"), rewrapLockTTL); err != nil {
+		logrus.WithError(err).Warn("Failed to extend key rewrap lock")
+	}
+	if ctx.Err() != nil {
+		return errors.New("key rewrap interrupted by shutdown")
+	}
+	return nil
+}
+
+func (s *KeyRewrapService) envelope() (encryption.EnvelopeService, bool) {
+	envelopeSvc, ok := s.encryptionSvc.(encryption.EnvelopeService)
+	return envelopeSvc, ok
+}
+
+func (s *KeyRewrapService) loadStatus() (*KeyRewrapStatus, error) {
+	status := &KeyRewrapStatus{}
+	data, err := s.store.Get(rewrapStatusKey)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return status, nil
+		}
+		return nil, fmt.Errorf("failed to load key rewrap status: %w", err)
+	}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, fmt.Errorf("failed to parse key rewrap status: %w", err)
+	}
+
+	// A run whose lock expired was interrupted, e.g. by a crash
+	if status.IsRunning {
+		if exists, err := s.store.Exists(rewrapLockKey); err == nil && !exists {
+			status.IsRunning = false
+		}
+	}
+	return status, nil
+}
+
+func (s *KeyRewrapService) saveStatus(status *KeyRewrapStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to serialize key rewrap status")
+		return
+	}
+	if err := s.store.Set(rewrapStatusKey, data, 0); err != nil {
+		logrus.WithError(err).Warn("Failed to save key rewrap status")
+	}
+}
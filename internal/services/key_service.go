@@ -1,24 +1,61 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gpt-load/internal/config"
 	"gpt-load/internal/encryption"
 	"gpt-load/internal/keypool"
 	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
 	"io"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 const (
 	maxRequestKeys = 5000
 	chunkSize      = 500
+
+	maxKeyTags        = 20
+	maxKeyTagValueLen = 128
+	maxKeyPriority    = 1000
 )
 
+// ErrInvalidKeyMetadata is returned when the metadata given next to a key cannot be used.
+var ErrInvalidKeyMetadata = errors.New("invalid key metadata")
+
+// keyTagNamePattern limits tag names to characters that are safe in JSON paths of every database.
+var keyTagNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// KeyEntry is a key to import together with its metadata.
+// In text input the metadata follows the key on the same line, e.g. "sk-xxx,tag=org-a,tier=paid,priority=2".
+type KeyEntry struct {
+	Key       string            `json:"key"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Priority  int               `json:"priority,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	RPMLimit  int               `json:"rpm_limit,omitempty"`
+	SourceID  *uint             `json:"-"` // Set for keys synced from a key source
+}
+
+// KeyTagFilter matches keys carrying a tag, with any value when Value is empty.
+type KeyTagFilter struct {
+	Name  string
+	Value string
+}
+
 // AddKeysResult holds the result of adding multiple keys.
 type AddKeysResult struct {
 	AddedCount   int   `json:"added_count"`
@@ -42,26 +79,31 @@ type RestoreKeysResult struct {
 
 // KeyService provides services related to API keys.
 type KeyService struct {
-	DB            *gorm.DB
-	KeyProvider   *keypool.KeyProvider
-	KeyValidator  *keypool.KeyValidator
-	EncryptionSvc encryption.Service
+	DB              *gorm.DB
+	KeyProvider     *keypool.KeyProvider
+	KeyValidator    *keypool.KeyValidator
+	EncryptionSvc   encryption.Service
+	SettingsManager *config.SystemSettingsManager
 }
 
 // NewKeyService creates a new KeyService.
-func NewKeyService(db *gorm.DB, keyProvider *keypool.KeyProvider, keyValidator *keypool.KeyValidator, encryptionSvc encryption.Service) *KeyService {
+func NewKeyService(db *gorm.DB, keyProvider *keypool.KeyProvider, keyValidator *keypool.KeyValidator, encryptionSvc encryption.Service, settingsManager *config.SystemSettingsManager) *KeyService {
 	return &KeyService{
-		DB:            db,
-		KeyProvider:   keyProvider,
-		KeyValidator:  keyValidator,
-		EncryptionSvc: encryptionSvc,
+		DB:              db,
+		KeyProvider:     keyProvider,
+		KeyValidator:    keyValidator,
+		EncryptionSvc:   encryptionSvc,
+		SettingsManager: settingsManager,
 	}
 }
 
 // AddMultipleKeys handles the business logic of creating new keys from a text block.
 // deprecated: use KeyImportService for large imports
 func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysResult, error) {
-	keys := s.ParseKeysFromText(keysText)
+	keys, err := s.ParseKeyEntriesFromText(keysText)
+	if err != nil {
+		return nil, err
+	}
 	if len(keys) > maxRequestKeys {
 		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keys))
 	}
@@ -69,7 +111,7 @@ func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysRes
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
 
-	addedCount, ignoredCount, err := s.processAndCreateKeys(groupID, keys, nil)
+	addedCount, ignoredCount, err := s.processAndCreateKeys(context.Background(), groupID, keys, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -88,33 +130,75 @@ func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysRes
 
 // processAndCreateKeys is the lowest-level reusable function for adding keys.
 func (s *KeyService) processAndCreateKeys(
+	ctx context.Context,
 	groupID uint,
-	keys []string,
+	keys []KeyEntry,
 	progressCallback func(processed int),
 ) (addedCount int, ignoredCount int, err error) {
+	newKeysToCreate, err := s.prepareKeys(s.DB, groupID, keys, s.SettingsManager.GetSettings().EnforceGlobalKeyUniqueness)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(newKeysToCreate) == 0 {
+		return 0, len(keys), nil
+	}
+
+	// Use KeyProvider to add keys in chunks
+	for i := 0; i < len(newKeysToCreate); i += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return addedCount, len(keys) - addedCount, err
+		}
+
+		end := i + chunkSize
+		if end > len(newKeysToCreate) {
+			end = len(newKeysToCreate)
+		}
+		chunk := newKeysToCreate[i:end]
+		if err := s.KeyProvider.AddKeys(groupID, chunk); err != nil {
+			return addedCount, len(keys) - addedCount, err
+		}
+		addedCount += len(chunk)
+
+		if progressCallback != nil {
+			progressCallback(i + len(chunk))
+		}
+	}
+
+	return addedCount, len(keys) - addedCount, nil
+}
+
+// prepareKeys encrypts the valid keys that are not in the group yet, reading existing keys through db,
+// which may be a transaction. With enforceUniqueness, keys held by other groups are skipped too.
+func (s *KeyService) prepareKeys(db *gorm.DB, groupID uint, keys []KeyEntry, enforceUniqueness bool) ([]models.APIKey, error) {
 	// 1. Get existing key hashes in the group for deduplication
 	var existingHashes []string
-	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Pluck("key_hash", &existingHashes).Error; err != nil {
-		return 0, 0, err
+	if err := db.Model(&models.APIKey{}).Where("group_id = ?", groupID).Pluck("key_hash", &existingHashes).Error; err != nil {
+		return nil, err
 	}
 	existingHashMap := make(map[string]bool)
 	for _, h := range existingHashes {
 		existingHashMap[h] = true
 	}
+	if enforceUniqueness {
+		if err := s.addKeyHashesInOtherGroups(db, groupID, keys, existingHashMap); err != nil {
+			return nil, err
+		}
+	}
 
 	// 2. Prepare new keys for creation
 	var newKeysToCreate []models.APIKey
 	uniqueNewKeys := make(map[string]bool)
 
-	for _, keyVal := range keys {
-		trimmedKey := strings.TrimSpace(keyVal)
+	now := time.Now()
+	for _, entry := range keys {
+		trimmedKey := strings.TrimSpace(entry.Key)
 		if trimmedKey == "" || uniqueNewKeys[trimmedKey] || !s.isValidKeyFormat(trimmedKey) {
 			continue
 		}
 
 		// Generate hash for deduplication check
 		keyHash := s.EncryptionSvc.Hash(trimmedKey)
-		if existingHashMap[keyHash] {
+		if hasAnyHash(existingHashMap, encryption.HashCandidates(s.EncryptionSvc, trimmedKey)) {
 			continue
 		}
 
@@ -125,60 +209,388 @@ func (s *KeyService) processAndCreateKeys(
 		}
 
 		uniqueNewKeys[trimmedKey] = true
-		newKeysToCreate = append(newKeysToCreate, models.APIKey{
-			GroupID:  groupID,
-			KeyValue: encryptedKey,
-			KeyHash:  keyHash,
-			Status:   models.KeyStatusActive,
-		})
-	}
-
-	if len(newKeysToCreate) == 0 {
-		return 0, len(keys), nil
-	}
-
-	// 3. Use KeyProvider to add keys in chunks
-	for i := 0; i < len(newKeysToCreate); i += chunkSize {
-		end := i + chunkSize
-		if end > len(newKeysToCreate) {
-			end = len(newKeysToCreate)
+		apiKey := models.APIKey{
+			GroupID:   groupID,
+			KeyValue:  encryptedKey,
+			KeyHash:   keyHash,
+			Status:    models.KeyStatusActive,
+			Tags:      keyTagsToJSON(entry.Tags),
+			Priority:  entry.Priority,
+			ExpiresAt: entry.ExpiresAt,
+			RPMLimit:  entry.RPMLimit,
+			SourceID:  entry.SourceID,
 		}
-		chunk := newKeysToCreate[i:end]
-		if err := s.KeyProvider.AddKeys(groupID, chunk); err != nil {
-			return addedCount, len(keys) - addedCount, err
-		}
-		addedCount += len(chunk)
-
-		if progressCallback != nil {
-			progressCallback(i + len(chunk))
+		if apiKey.IsExpired(now) {
+			apiKey.Status = models.KeyStatusInvalid
 		}
+		newKeysToCreate = append(newKeysToCreate, apiKey)
 	}
 
-	return addedCount, len(keys) - addedCount, nil
+	return newKeysToCreate, nil
 }
 
 // ParseKeysFromText parses a string of keys from various formats into a string slice.
+// Metadata given next to a key is ignored.
 // This function is exported to be shared with the handler layer.
 func (s *KeyService) ParseKeysFromText(text string) []string {
-	var keys []string
+	entries, _ := s.ParseKeyEntriesFromText(text)
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
 
+// ParseKeyEntriesFromText parses keys and their metadata from a JSON array of strings or objects,
+// or from text. A text line whose comma-separated fields after the first contain "=" is a key with metadata:
+//
+//	sk-xxx,tag=org-a,tag=tier:paid,team=search,priority=1,expires=2026-12-31,rpm=60
+//
+// "tag=name" or "tag=name:value" adds a tag, and any other name=value pair is a tag as well.
+// Other text is split on whitespace, commas and semicolons as before.
+// Keys are returned even when their metadata is invalid, together with the first error.
+func (s *KeyService) ParseKeyEntriesFromText(text string) ([]KeyEntry, error) {
 	// First, try to parse as a JSON array of strings
+	var keys []string
 	if json.Unmarshal([]byte(text), &keys) == nil && len(keys) > 0 {
-		return s.filterValidKeys(keys)
+		return keyEntriesFromStrings(s.filterValidKeys(keys)), nil
+	}
+
+	// Then as a JSON array of keys with metadata
+	var objects []KeyEntry
+	if json.Unmarshal([]byte(text), &objects) == nil && len(objects) > 0 {
+		var firstErr error
+		entries := make([]KeyEntry, 0, len(objects))
+		for i, entry := range objects {
+			entry.Key = strings.TrimSpace(entry.Key)
+			if !s.isValidKeyFormat(entry.Key) {
+				continue
+			}
+			if err := validateKeyEntry(&entry); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("%w: entry %d: %v", ErrInvalidKeyMetadata, i+1, err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries, firstErr
 	}
 
 	// 通用解析：通过分隔符分割文本，不使用复杂的正则表达式
 	delimiters := regexp.MustCompile(`[\s,;\n\r\t]+`)
-	splitKeys := delimiters.Split(strings.TrimSpace(text), -1)
+	var entries []KeyEntry
+	var firstErr error
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 
-	for _, key := range splitKeys {
-		key = strings.TrimSpace(key)
-		if key != "" {
-			keys = append(keys, key)
+		fields := strings.Split(line, ",")
+		if hasKeyMetadata(fields) {
+			entry, err := parseKeyMetadataLine(fields)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("%w: line %d: %v", ErrInvalidKeyMetadata, i+1, err)
+			}
+			if s.isValidKeyFormat(entry.Key) {
+				entries = append(entries, entry)
+			}
+			continue
 		}
+
+		for _, key := range delimiters.Split(line, -1) {
+			key = strings.TrimSpace(key)
+			if s.isValidKeyFormat(key) {
+				entries = append(entries, KeyEntry{Key: key})
+			}
+		}
+	}
+
+	return entries, firstErr
+}
+
+// keyEntriesFromStrings wraps plain keys without metadata.
+func keyEntriesFromStrings(keys []string) []KeyEntry {
+	entries := make([]KeyEntry, len(keys))
+	for i, key := range keys {
+		entries[i] = KeyEntry{Key: key}
+	}
+	return entries
+}
+
+// keyMetadataNames are the metadata names that mark a line as a key followed by metadata.
+var keyMetadataNames = map[string]bool{
+	"tag": true, "priority": true, "expires": true, "expires_at": true, "rpm": true, "rpm_limit": true,
+}
+
+// hasKeyMetadata reports whether the fields of a line hold a key followed by name=value metadata.
+// Only the fields after the first are checked and a known metadata name is required,
+// since keys themselves may contain "=", as in a "keyA,keyB=" list of base64 keys.
+func hasKeyMetadata(fields []string) bool {
+	if len(fields) < 2 {
+		return false
 	}
+	for _, field := range fields[1:] {
+		name, _, ok := strings.Cut(field, "=")
+		if ok && keyMetadataNames[strings.ToLower(strings.TrimSpace(name))] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseKeyMetadataLine parses a "key,name=value,..." line.
+func parseKeyMetadataLine(fields []string) (KeyEntry, error) {
+	entry := KeyEntry{Key: strings.TrimSpace(fields[0])}
+	for _, field := range fields[1:] {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return entry, fmt.Errorf("%q is not a name=value pair", field)
+		}
+		if err := applyKeyMetadata(&entry, strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+			return entry, err
+		}
+	}
+	return entry, validateKeyEntry(&entry)
+}
+
+// applyKeyMetadata sets one name=value metadata pair on an entry. Unknown names become tags.
+func applyKeyMetadata(entry *KeyEntry, name, value string) error {
+	switch strings.ToLower(name) {
+	case "priority":
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("priority %q is not a number", value)
+		}
+		entry.Priority = priority
+	case "expires", "expires_at":
+		expiresAt, err := ParseKeyExpiry(value)
+		if err != nil {
+			return err
+		}
+		entry.ExpiresAt = expiresAt
+	case "rpm", "rpm_limit":
+		rpm, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("rpm %q is not a number", value)
+		}
+		entry.RPMLimit = rpm
+	case "tag":
+		tagName, tagValue, _ := strings.Cut(value, ":")
+		setKeyTag(entry, tagName, tagValue)
+	default:
+		setKeyTag(entry, name, value)
+	}
+	return nil
+}
+
+// keyCSVIgnoredColumns are columns of the CSV export that describe the state of a key
+// rather than what to import, so an export can be imported again.
+var keyCSVIgnoredColumns = map[string]bool{
+	"id": true, "status": true, "notes": true, "balance": true, "balance_currency": true,
+	"rate_tier": true, "balance_checked_at": true, "last_validated_at": true, "last_validation_error": true,
+}
+
+// ParseKeyEntriesFromCSV parses a CSV file with a header row. The key is read from the
+// "key" (or "key_value") column; priority, expires_at, rpm_limit and tag columns work as in
+// text input, a "tags" column holds a JSON object or "name=value;name=value" pairs, and any
+// other non-empty column becomes a tag.
+func (s *KeyService) ParseKeyEntriesFromCSV(content []byte) ([]KeyEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid CSV: %v", ErrInvalidKeyMetadata, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := make([]string, len(records[0]))
+	keyColumn := -1
+	for i, name := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if keyColumn < 0 && (header[i] == "key" || header[i] == "key_value") {
+			keyColumn = i
+		}
+	}
+	if keyColumn < 0 {
+		return nil, fmt.Errorf("%w: the CSV header has no key column", ErrInvalidKeyMetadata)
+	}
+
+	var entries []KeyEntry
+	var firstErr error
+	for row, record := range records[1:] {
+		if keyColumn >= len(record) || !s.isValidKeyFormat(record[keyColumn]) {
+			continue
+		}
+		entry := KeyEntry{Key: strings.TrimSpace(record[keyColumn])}
+		if err := applyKeyCSVColumns(&entry, header, record, keyColumn); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%w: row %d: %v", ErrInvalidKeyMetadata, row+2, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, firstErr
+}
+
+// applyKeyCSVColumns sets the metadata columns of a CSV row on an entry.
+func applyKeyCSVColumns(entry *KeyEntry, header, record []string, keyColumn int) error {
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if i == keyColumn || i >= len(header) || value == "" || keyCSVIgnoredColumns[header[i]] {
+			continue
+		}
+
+		if header[i] != "tags" {
+			if err := applyKeyMetadata(entry, header[i], value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var tags map[string]string
+		if strings.HasPrefix(value, "{") {
+			if err := json.Unmarshal([]byte(value), &tags); err != nil {
+				return fmt.Errorf("tags must be a JSON object of strings: %v", err)
+			}
+		} else {
+			tags = make(map[string]string)
+			for _, pair := range strings.Split(value, ";") {
+				if name, tagValue, _ := strings.Cut(pair, "="); strings.TrimSpace(name) != "" {
+					tags[name] = tagValue
+				}
+			}
+		}
+		for name, tagValue := range tags {
+			setKeyTag(entry, name, tagValue)
+		}
+	}
+	return validateKeyEntry(entry)
+}
+
+// Key file formats accepted by ParseKeyEntries.
+const (
+	KeyFormatAuto = "auto"
+	KeyFormatText = "txt"
+	KeyFormatCSV  = "csv"
+	KeyFormatJSON = "json"
+)
+
+// DetectKeyFormat picks the format of a key file from its name and content type.
+// Text is assumed when neither tells, since text parsing also accepts JSON arrays.
+func DetectKeyFormat(name, contentType string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return KeyFormatCSV
+	case ".json":
+		return KeyFormatJSON
+	}
+	if strings.Contains(strings.ToLower(contentType), "csv") {
+		return KeyFormatCSV
+	}
+	return KeyFormatText
+}
+
+// ParseKeyEntries parses keys in the given format, which is one of txt, csv or json.
+func (s *KeyService) ParseKeyEntries(content []byte, format string) ([]KeyEntry, error) {
+	if format == KeyFormatCSV {
+		return s.ParseKeyEntriesFromCSV(content)
+	}
+	return s.ParseKeyEntriesFromText(string(content))
+}
+
+// setKeyTag adds a tag to an entry, leaving validation to validateKeyEntry.
+func setKeyTag(entry *KeyEntry, name, value string) {
+	if entry.Tags == nil {
+		entry.Tags = make(map[string]string)
+	}
+	entry.Tags[strings.TrimSpace(name)] = strings.TrimSpace(value)
+}
+
+// ParseKeyExpiry parses an expiry given as RFC 3339 or as a date, which keeps the key usable through that day (UTC).
+// An empty value means no expiry.
+func ParseKeyExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		t = t.AddDate(0, 0, 1)
+		return &t, nil
+	}
+	return nil, fmt.Errorf("expiry %q must be a date (YYYY-MM-DD) or an RFC 3339 time", value)
+}
+
+// validateKeyEntry checks the metadata of an entry.
+func validateKeyEntry(entry *KeyEntry) error {
+	if entry.Priority < -maxKeyPriority || entry.Priority > maxKeyPriority {
+		return fmt.Errorf("priority must be between %d and %d", -maxKeyPriority, maxKeyPriority)
+	}
+	if entry.RPMLimit < 0 {
+		return fmt.Errorf("rpm limit must not be negative")
+	}
+	return ValidateKeyTags(entry.Tags)
+}
+
+// ValidateKeyTags checks tag names and values.
+func ValidateKeyTags(tags map[string]string) error {
+	if len(tags) > maxKeyTags {
+		return fmt.Errorf("a key can have at most %d tags", maxKeyTags)
+	}
+	for name, value := range tags {
+		if !keyTagNamePattern.MatchString(name) {
+			return fmt.Errorf("tag name %q must be 1-64 letters, digits, '_', '.' or '-'", name)
+		}
+		if len(value) > maxKeyTagValueLen {
+			return fmt.Errorf("value of tag %q must be at most %d characters", name, maxKeyTagValueLen)
+		}
+	}
+	return nil
+}
+
+// ParseKeyTagFilter parses a "name" or "name:value" tag filter.
+func ParseKeyTagFilter(value string) (KeyTagFilter, error) {
+	name, tagValue, _ := strings.Cut(strings.TrimSpace(value), ":")
+	filter := KeyTagFilter{Name: strings.TrimSpace(name), Value: strings.TrimSpace(tagValue)}
+	if !keyTagNamePattern.MatchString(filter.Name) {
+		return filter, fmt.Errorf("%w: invalid tag filter %q", ErrInvalidKeyMetadata, value)
+	}
+	return filter, nil
+}
+
+// activeScheduleToJSON validates a schedule and converts it to the column value of a schedule.
+// A nil schedule or one without windows is stored as NULL, meaning always active.
+func activeScheduleToJSON(schedule *models.ActiveSchedule) (datatypes.JSON, error) {
+	if schedule == nil || len(schedule.Windows) == 0 {
+		return nil, nil
+	}
+	schedule.Timezone = strings.TrimSpace(schedule.Timezone)
+	if err := utils.ValidateActiveSchedule(schedule); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
 
-	return s.filterValidKeys(keys)
+// keyTagsToJSON converts tags to the column value of APIKey.Tags.
+func keyTagsToJSON(tags map[string]string) datatypes.JSONMap {
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make(datatypes.JSONMap, len(tags))
+	for name, value := range tags {
+		result[name] = value
+	}
+	return result
 }
 
 // filterValidKeys validates and filters potential API keys
@@ -289,16 +701,25 @@ func (s *KeyService) DeleteMultipleKeys(groupID uint, keysText string) (*DeleteK
 	}, nil
 }
 
-// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status.
-func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHash string) *gorm.DB {
+// ListKeysInGroupQuery builds a query to list all keys within a specific group, filtered by status and tags.
+// A key must match every tag filter.
+func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, searchHashes []string, tagFilters []KeyTagFilter) *gorm.DB {
 	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID)
 
 	if statusFilter != "" {
 		query = query.Where("status = ?", statusFilter)
 	}
 
-	if searchHash != "" {
-		query = query.Where("key_hash = ?", searchHash)
+	if len(searchHashes) > 0 {
+		query = query.Where("key_hash IN ?", searchHashes)
+	}
+
+	for _, filter := range tagFilters {
+		if filter.Value == "" {
+			query = query.Where(datatypes.JSONQuery("tags").HasKey(filter.Name))
+		} else {
+			query = query.Where(datatypes.JSONQuery("tags").Equals(filter.Value, filter.Name))
+		}
 	}
 
 	orderBy := "last_used_at desc, id desc"
@@ -311,6 +732,147 @@ func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, sea
 	return query
 }
 
+// KeyTagUpdate describes a bulk tag edit. Removals are applied before additions.
+type KeyTagUpdate struct {
+	KeyIDs     []uint
+	KeysText   string
+	AddTags    map[string]string
+	RemoveTags []string
+}
+
+// UpdateKeyTags adds and removes tags on the selected keys of a group, which are picked by ID and/or by value.
+// It returns how many keys were changed.
+func (s *KeyService) UpdateKeyTags(groupID uint, update KeyTagUpdate) (int, error) {
+	if err := ValidateKeyTags(update.AddTags); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidKeyMetadata, err)
+	}
+	if len(update.AddTags) == 0 && len(update.RemoveTags) == 0 {
+		return 0, fmt.Errorf("%w: no tags to add or remove", ErrInvalidKeyMetadata)
+	}
+
+	keyValues := s.ParseKeysFromText(update.KeysText)
+	if len(update.KeyIDs) == 0 && len(keyValues) == 0 {
+		return 0, fmt.Errorf("no valid keys found in the input text")
+	}
+	if len(update.KeyIDs)+len(keyValues) > maxRequestKeys {
+		return 0, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(update.KeyIDs)+len(keyValues))
+	}
+
+	var keyHashes []string
+	for _, key := range keyValues {
+		keyHashes = append(keyHashes, encryption.HashCandidates(s.EncryptionSvc, key)...)
+	}
+
+	query := s.DB.Model(&models.APIKey{}).Select("id, tags").Where("group_id = ?", groupID)
+	switch {
+	case len(update.KeyIDs) > 0 && len(keyHashes) > 0:
+		query = query.Where("id IN ? OR key_hash IN ?", update.KeyIDs, keyHashes)
+	case len(update.KeyIDs) > 0:
+		query = query.Where("id IN ?", update.KeyIDs)
+	default:
+		query = query.Where("key_hash IN ?", keyHashes)
+	}
+
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		return 0, err
+	}
+
+	updatedCount := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range keys {
+			tags := make(map[string]string, len(keys[i].Tags)+len(update.AddTags))
+			for name, value := range keys[i].Tags {
+				tags[name] = fmt.Sprint(value)
+			}
+			for _, name := range update.RemoveTags {
+				delete(tags, strings.TrimSpace(name))
+			}
+			for name, value := range update.AddTags {
+				tags[name] = value
+			}
+			if err := ValidateKeyTags(tags); err != nil {
+				return fmt.Errorf("%w: key %d: %v", ErrInvalidKeyMetadata, keys[i].ID, err)
+			}
+
+			if err := tx.Model(&models.APIKey{}).Where("id = ?", keys[i].ID).Update("tags", keyTagsToJSON(tags)).Error; err != nil {
+				return err
+			}
+			updatedCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return updatedCount, nil
+}
+
+// KeyMetadataParams holds the metadata of a single key to change; nil fields are left unchanged.
+type KeyMetadataParams struct {
+	Tags      map[string]string
+	Priority  *int
+	ExpiresAt *string
+	RPMLimit  *int
+	// ActiveSchedule replaces the active time windows of the key; a schedule without windows removes them
+	ActiveSchedule *models.ActiveSchedule
+}
+
+// UpdateKeyMetadata changes the tags, priority, expiry, RPM limit and schedule of a key and returns the key before the change.
+// An empty ExpiresAt clears the expiry.
+func (s *KeyService) UpdateKeyMetadata(keyID uint, params KeyMetadataParams) (*models.APIKey, *models.APIKey, error) {
+	var key models.APIKey
+	if err := s.DB.First(&key, keyID).Error; err != nil {
+		return nil, nil, err
+	}
+	original := key
+
+	if err := applyKeyMetadataParams(&key, params); err != nil {
+		return nil, nil, err
+	}
+	if err := s.KeyProvider.UpdateKeyMetadata(&key); err != nil {
+		return nil, nil, err
+	}
+	return &original, &key, nil
+}
+
+// applyKeyMetadataParams validates the metadata changes and sets them on the key.
+func applyKeyMetadataParams(key *models.APIKey, params KeyMetadataParams) error {
+	entry := KeyEntry{Tags: params.Tags, Priority: key.Priority, ExpiresAt: key.ExpiresAt, RPMLimit: key.RPMLimit}
+	if params.Priority != nil {
+		entry.Priority = *params.Priority
+	}
+	if params.RPMLimit != nil {
+		entry.RPMLimit = *params.RPMLimit
+	}
+	if params.ExpiresAt != nil {
+		expiresAt, err := ParseKeyExpiry(strings.TrimSpace(*params.ExpiresAt))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKeyMetadata, err)
+		}
+		entry.ExpiresAt = expiresAt
+	}
+	if err := validateKeyEntry(&entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKeyMetadata, err)
+	}
+	if params.ActiveSchedule != nil {
+		schedule, err := activeScheduleToJSON(params.ActiveSchedule)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKeyMetadata, err)
+		}
+		key.Schedule = schedule
+	}
+
+	if params.Tags != nil {
+		key.Tags = keyTagsToJSON(params.Tags)
+	}
+	key.Priority = entry.Priority
+	key.ExpiresAt = entry.ExpiresAt
+	key.RPMLimit = entry.RPMLimit
+	return nil
+}
+
 // TestMultipleKeys handles a one-off validation test for multiple keys.
 func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string) ([]keypool.KeyTestResult, error) {
 	keysToTest := s.ParseKeysFromText(keysText)
@@ -343,7 +905,7 @@ func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, write
 	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Select("id, key_value")
 
 	switch statusFilter {
-	case models.KeyStatusActive, models.KeyStatusInvalid:
+	case models.KeyStatusActive, models.KeyStatusInvalid, models.KeyStatusDisabled:
 		query = query.Where("status = ?", statusFilter)
 	case "all":
 	default:
@@ -367,3 +929,366 @@ func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, write
 
 	return err
 }
+
+// StreamKeysCSVToWriter writes the keys of a group as CSV, including the metadata and
+// the last probed balance of each key.
+func (s *KeyService) StreamKeysCSVToWriter(groupID uint, statusFilter string, writer io.Writer) error {
+	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Order("id asc")
+
+	switch statusFilter {
+	case models.KeyStatusActive, models.KeyStatusInvalid, models.KeyStatusDisabled:
+		query = query.Where("status = ?", statusFilter)
+	case "all":
+	default:
+		return fmt.Errorf("invalid status filter: %s", statusFilter)
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	header := []string{"key", "status", "priority", "rpm_limit", "expires_at", "tags", "balance", "balance_currency", "rate_tier", "balance_checked_at", "notes"}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	var keys []models.APIKey
+	err := query.FindInBatches(&keys, chunkSize, func(tx *gorm.DB, batch int) error {
+		for _, key := range keys {
+			decryptedKey, err := s.EncryptionSvc.Decrypt(key.KeyValue)
+			if err != nil {
+				logrus.WithError(err).WithField("key_id", key.ID).Error("Failed to decrypt key for streaming, skipping")
+				continue
+			}
+
+			tags := ""
+			if len(key.Tags) > 0 {
+				tagBytes, err := json.Marshal(key.Tags)
+				if err != nil {
+					return err
+				}
+				tags = string(tagBytes)
+			}
+			balance := ""
+			if key.Balance != nil {
+				balance = strconv.FormatFloat(*key.Balance, 'f', -1, 64)
+			}
+
+			record := []string{
+				decryptedKey,
+				key.Status,
+				strconv.Itoa(key.Priority),
+				strconv.Itoa(key.RPMLimit),
+				formatTime(key.ExpiresAt),
+				tags,
+				balance,
+				key.BalanceCurrency,
+				key.RateTier,
+				formatTime(key.BalanceCheckedAt),
+				key.Notes,
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// addKeyHashesInOtherGroups adds the hashes of the given keys that already exist in other groups to the set.
+func (s *KeyService) addKeyHashesInOtherGroups(db *gorm.DB, groupID uint, keys []KeyEntry, set map[string]bool) error {
+	var candidates []string
+	for _, entry := range keys {
+		if trimmedKey := strings.TrimSpace(entry.Key); trimmedKey != "" {
+			candidates = append(candidates, encryption.HashCandidates(s.EncryptionSvc, trimmedKey)...)
+		}
+	}
+
+	for i := 0; i < len(candidates); i += chunkSize {
+		end := min(i+chunkSize, len(candidates))
+		var found []string
+		if err := db.Model(&models.APIKey{}).
+			Where("group_id <> ? AND key_hash IN ?", groupID, candidates[i:end]).
+			Distinct().Pluck("key_hash", &found).Error; err != nil {
+			return err
+		}
+		for _, hash := range found {
+			set[hash] = true
+		}
+	}
+	return nil
+}
+
+// hasAnyHash reports whether any of the hashes is in the set.
+func hasAnyHash(set map[string]bool, hashes []string) bool {
+	for _, hash := range hashes {
+		if set[hash] {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultKeyHistoryHours = 24
+	maxKeyHistoryHours     = 24 * 30
+	maxKeyRecentErrors     = 20
+)
+
+// KeyUsageSummary totals the hourly statistics of a key over a period.
+type KeyUsageSummary struct {
+	SuccessCount   int64      `json:"success_count"`
+	FailureCount   int64      `json:"failure_count"`
+	FailureRate    float64    `json:"failure_rate"`
+	InputTokens    int64      `json:"input_tokens"`
+	OutputTokens   int64      `json:"output_tokens"`
+	Cost           float64    `json:"cost"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	LastErrorAt    *time.Time `json:"last_error_at"`
+}
+
+// KeyErrorEntry is a failed request made with a key.
+type KeyErrorEntry struct {
+	ID           string    `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	GroupName    string    `json:"group_name"`
+	Model        string    `json:"model"`
+	StatusCode   int       `json:"status_code"`
+	RequestType  string    `json:"request_type"`
+	ErrorMessage string    `json:"error_message"`
+}
+
+// KeyGroupUsage is a group holding the key. The same key value may be added to several groups.
+type KeyGroupUsage struct {
+	GroupID         uint                              `json:"group_id"`
+	GroupName       string                            `json:"group_name"`
+	DisplayName     string                            `json:"display_name"`
+	KeyID           uint                              `json:"key_id"`
+	KeyStatus       string                            `json:"key_status"`
+	AggregateGroups []models.ParentAggregateGroupInfo `json:"aggregate_groups"`
+}
+
+// KeyDetails describes a key with its usage history, recent errors and the groups it is used in.
+type KeyDetails struct {
+	Key          models.APIKey          `json:"key"`
+	Hours        int                    `json:"hours"`
+	Summary      KeyUsageSummary        `json:"summary"`
+	History      []models.KeyHourlyStat `json:"history"`
+	RecentErrors []KeyErrorEntry        `json:"recent_errors"`
+	Groups       []KeyGroupUsage        `json:"groups"`
+}
+
+// GetKeyDetails returns a key with its hourly statistics over the last hours, its most recent errors
+// and the groups holding the same key value. Hours outside 1-720 fall back to 24.
+func (s *KeyService) GetKeyDetails(ctx context.Context, keyID uint, hours int) (*KeyDetails, error) {
+	if hours <= 0 || hours > maxKeyHistoryHours {
+		hours = defaultKeyHistoryHours
+	}
+
+	var key models.APIKey
+	if err := s.DB.WithContext(ctx).First(&key, keyID).Error; err != nil {
+		return nil, err
+	}
+	// The plaintext is only used to find the groups holding the same key, details show the masked value
+	decryptedValue, err := s.EncryptionSvc.Decrypt(key.KeyValue)
+	if err != nil {
+		logrus.WithError(err).WithField("key_id", key.ID).Error("Failed to decrypt key value for details")
+		decryptedValue = ""
+		key.KeyValue = "failed-to-decrypt"
+	} else {
+		key.KeyValue = utils.MaskAPIKey(decryptedValue)
+	}
+
+	details := &KeyDetails{Key: key, Hours: hours}
+
+	// 1. Hourly history, including the current hour
+	endTime := time.Now().Truncate(time.Hour).Add(time.Hour)
+	startTime := endTime.Add(-time.Duration(hours) * time.Hour)
+	if err := s.DB.WithContext(ctx).
+		Where("key_id = ? AND time >= ? AND time < ?", key.ID, startTime, endTime).
+		Order("time asc").
+		Find(&details.History).Error; err != nil {
+		return nil, err
+	}
+	for _, stat := range details.History {
+		summary := &details.Summary
+		summary.SuccessCount += stat.SuccessCount
+		summary.FailureCount += stat.FailureCount
+		summary.InputTokens += stat.InputTokens
+		summary.OutputTokens += stat.OutputTokens
+		summary.Cost += stat.Cost
+		if stat.LastStatusCode != 0 {
+			summary.LastStatusCode = stat.LastStatusCode
+		}
+		if stat.LastErrorAt != nil {
+			summary.LastError = stat.LastError
+			summary.LastErrorAt = stat.LastErrorAt
+		}
+	}
+	if total := details.Summary.SuccessCount + details.Summary.FailureCount; total > 0 {
+		details.Summary.FailureRate = float64(details.Summary.FailureCount) / float64(total)
+	}
+
+	// 2. Recent errors from the request logs
+	details.RecentErrors = []KeyErrorEntry{}
+	if err := s.DB.WithContext(ctx).Model(&models.RequestLog{}).
+		Select("id, timestamp, group_name, model, status_code, request_type, error_message").
+		Where("key_id = ? AND is_success = ?", key.ID, false).
+		Order("timestamp desc").
+		Limit(maxKeyRecentErrors).
+		Scan(&details.RecentErrors).Error; err != nil {
+		return nil, err
+	}
+
+	// 3. Groups holding the same key value
+	keyHashes := []string{key.KeyHash}
+	if decryptedValue != "" {
+		keyHashes = append(keyHashes, encryption.HashCandidates(s.EncryptionSvc, decryptedValue)...)
+	}
+	var sameKeys []models.APIKey
+	if err := s.DB.WithContext(ctx).Select("id, group_id, status").Where("key_hash IN ?", keyHashes).Order("group_id asc").Find(&sameKeys).Error; err != nil {
+		return nil, err
+	}
+	groupIDs := make([]uint, 0, len(sameKeys))
+	for _, sameKey := range sameKeys {
+		groupIDs = append(groupIDs, sameKey.GroupID)
+	}
+	var groups []models.Group
+	if err := s.DB.WithContext(ctx).Select("id, name, display_name").Where("id IN ?", groupIDs).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	groupByID := make(map[uint]models.Group, len(groups))
+	for _, group := range groups {
+		groupByID[group.ID] = group
+	}
+	details.Groups = make([]KeyGroupUsage, 0, len(sameKeys))
+	for _, sameKey := range sameKeys {
+		group := groupByID[sameKey.GroupID]
+		details.Groups = append(details.Groups, KeyGroupUsage{
+			GroupID:     sameKey.GroupID,
+			GroupName:   group.Name,
+			DisplayName: group.DisplayName,
+			KeyID:       sameKey.ID,
+			KeyStatus:   sameKey.Status,
+		})
+	}
+
+	return details, nil
+}
+
+const defaultDuplicateReportLimit = 100
+
+// DuplicateKeyEntry is one copy of a key value that exists in several groups.
+type DuplicateKeyEntry struct {
+	KeyID        uint       `json:"key_id"`
+	GroupID      uint       `json:"group_id"`
+	GroupName    string     `json:"group_name"`
+	Status       string     `json:"status"`
+	RequestCount int64      `json:"request_count"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// DuplicateKeyGroup lists the copies of a key value held by more than one group.
+type DuplicateKeyGroup struct {
+	KeyHash   string              `json:"key_hash"`
+	MaskedKey string              `json:"masked_key"`
+	Keys      []DuplicateKeyEntry `json:"keys"`
+}
+
+// DuplicateKeyReport is the result of a cross-group duplicate scan.
+type DuplicateKeyReport struct {
+	Total      int64               `json:"total"`
+	Duplicates []DuplicateKeyGroup `json:"duplicates"`
+}
+
+// FindDuplicateKeys reports key values that are held by more than one group. When groupID is
+// non-zero, only key values held by that group are reported. At most limit values are returned.
+func (s *KeyService) FindDuplicateKeys(ctx context.Context, groupID uint, limit int) (*DuplicateKeyReport, error) {
+	if limit <= 0 {
+		limit = defaultDuplicateReportLimit
+	}
+
+	duplicateHashes := s.DB.WithContext(ctx).Model(&models.APIKey{}).
+		Select("key_hash").
+		Group("key_hash").
+		Having("COUNT(DISTINCT group_id) > 1")
+	if groupID != 0 {
+		duplicateHashes = duplicateHashes.Where("key_hash IN (?)",
+			s.DB.Model(&models.APIKey{}).Select("key_hash").Where("group_id = ?", groupID))
+	}
+
+	report := &DuplicateKeyReport{Duplicates: []DuplicateKeyGroup{}}
+	if err := s.DB.WithContext(ctx).Table("(?) AS duplicates", duplicateHashes).Count(&report.Total).Error; err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	if err := duplicateHashes.Session(&gorm.Session{}).Order("key_hash asc").Limit(limit).Pluck("key_hash", &hashes).Error; err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return report, nil
+	}
+
+	var keys []models.APIKey
+	if err := s.DB.WithContext(ctx).
+		Select("id, group_id, key_value, key_hash, status, request_count, last_used_at").
+		Where("key_hash IN ?", hashes).
+		Order("key_hash asc, group_id asc").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]uint, 0, len(keys))
+	for _, key := range keys {
+		groupIDs = append(groupIDs, key.GroupID)
+	}
+	var groups []models.Group
+	if err := s.DB.WithContext(ctx).Select("id, name").Where("id IN ?", groupIDs).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	groupNames := make(map[uint]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.ID] = group.Name
+	}
+
+	byHash := make(map[string]*DuplicateKeyGroup, len(hashes))
+	for _, key := range keys {
+		entry, ok := byHash[key.KeyHash]
+		if !ok {
+			maskedKey := "failed-to-decrypt"
+			if decrypted, err := s.EncryptionSvc.Decrypt(key.KeyValue); err == nil {
+				maskedKey = utils.MaskAPIKey(decrypted)
+			}
+			entry = &DuplicateKeyGroup{KeyHash: key.KeyHash, MaskedKey: maskedKey}
+			byHash[key.KeyHash] = entry
+		}
+		entry.Keys = append(entry.Keys, DuplicateKeyEntry{
+			KeyID:        key.ID,
+			GroupID:      key.GroupID,
+			GroupName:    groupNames[key.GroupID],
+			Status:       key.Status,
+			RequestCount: key.RequestCount,
+			LastUsedAt:   key.LastUsedAt,
+		})
+	}
+	for _, hash := range hashes {
+		if entry, ok := byHash[hash]; ok {
+			report.Duplicates = append(report.Duplicates, *entry)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,714 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpt-load/internal/encryption"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/types"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	maxKeySourceBytes    = 20 << 20
+	keySourceHTTPTimeout = 30 * time.Second
+)
+
+// ErrKeySourceFetch is returned when keys cannot be read from a source.
+var ErrKeySourceFetch = errors.New("failed to fetch keys from source")
+
+// ErrKeySourceAddressNotAllowed is returned when a source resolves to a loopback, private or link-local address.
+var ErrKeySourceAddressNotAllowed = errors.New("address is not allowed for key sources, set KEY_SOURCE_ALLOW_PRIVATE_NETWORKS=true to allow it")
+
+// sharedAddressSpace is the carrier-grade NAT range, also used by some cloud metadata services
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// KeySourceParams defines the mutable fields of a key source.
+type KeySourceParams struct {
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	Address         string  `json:"address"`
+	Path            string  `json:"path"`
+	Field           string  `json:"field"`
+	Token           *string `json:"token"` // nil keeps the current token, an empty string clears it
+	Format          string  `json:"format"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	MissingAction   string  `json:"missing_action"`
+	Enabled         *bool   `json:"enabled"`
+}
+
+// KeySyncResult holds the result of a key source sync.
+type KeySyncResult struct {
+	Fetched  int `json:"fetched"`
+	Added    int `json:"added"`
+	Ignored  int `json:"ignored"`
+	Disabled int `json:"disabled"`
+	Enabled  int `json:"enabled"`
+	Removed  int `json:"removed"`
+}
+
+// KeySourceService manages key sources and syncs the keys of groups from them.
+type KeySourceService struct {
+	db            *gorm.DB
+	keyService    *KeyService
+	taskService   *TaskService
+	encryptionSvc encryption.Service
+	configManager types.ConfigManager
+	httpClient    *http.Client
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewKeySourceService creates a new KeySourceService.
+func NewKeySourceService(
+	db *gorm.DB,
+	keyService *KeyService,
+	taskService *TaskService,
+	encryptionSvc encryption.Service,
+	configManager types.ConfigManager,
+) *KeySourceService {
+	return &KeySourceService{
+		db:            db,
+		keyService:    keyService,
+		taskService:   taskService,
+		encryptionSvc: encryptionSvc,
+		configManager: configManager,
+		httpClient:    newKeySourceHTTPClient(configManager.GetKeySourceConfig().AllowPrivateNetworks),
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// newKeySourceHTTPClient creates the client of URL and Vault sources. Unless private networks are
+// allowed, it refuses to connect to restricted addresses. The check runs on the resolved address of
+// every connection, so DNS names and redirects cannot reach them either.
+func newKeySourceHTTPClient(allowPrivateNetworks bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateNetworks {
+		dialer := &net.Dialer{
+			Timeout:   keySourceHTTPTimeout,
+			KeepAlive: 30 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip, err := netip.ParseAddr(host); err != nil || isRestrictedAddress(ip) {
+					return ErrKeySourceAddressNotAllowed
+				}
+				return nil
+			},
+		}
+		transport.DialContext = dialer.DialContext
+		// A proxy would connect to the target on our behalf, bypassing the check
+		transport.Proxy = nil
+	}
+	return &http.Client{Timeout: keySourceHTTPTimeout, Transport: transport}
+}
+
+// isRestrictedAddress reports whether an address belongs to the host itself or an internal network.
+func isRestrictedAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// Start runs the periodic sync of key sources.
+func (s *KeySourceService) Start() {
+	s.wg.Add(1)
+	go s.run()
+	logrus.Debug("Key source sync service started")
+}
+
+// Stop stops the periodic sync.
+func (s *KeySourceService) Stop(ctx context.Context) {
+	close(s.stopCh)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("KeySourceService stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("KeySourceService stop timed out.")
+	}
+}
+
+func (s *KeySourceService) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.syncDueSources()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// syncDueSources starts a sync for each enabled source whose interval has passed.
+// A source that cannot start because another task is running is retried on the next tick.
+func (s *KeySourceService) syncDueSources() {
+	var sources []models.KeySource
+	if err := s.db.Where("enabled = ? AND interval_minutes > 0", true).Order("id asc").Find(&sources).Error; err != nil {
+		logrus.WithError(err).Error("KeySourceService: failed to load key sources")
+		return
+	}
+
+	now := time.Now()
+	for i := range sources {
+		source := &sources[i]
+		interval := time.Duration(source.IntervalMinutes) * time.Minute
+		if source.LastSyncAt != nil && now.Sub(*source.LastSyncAt) < interval {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), keySourceHTTPTimeout)
+		_, err := s.StartSync(ctx, source.ID)
+		cancel()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"source_id": source.ID, "error": err}).Warn("KeySourceService: periodic sync did not start")
+		}
+	}
+}
+
+// ListSources returns the key sources of a group.
+func (s *KeySourceService) ListSources(ctx context.Context, groupID uint) ([]models.KeySource, error) {
+	var sources []models.KeySource
+	if err := s.db.WithContext(ctx).Where("group_id = ?", groupID).Order("id asc").Find(&sources).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	for i := range sources {
+		sources[i].HasToken = sources[i].Token != ""
+	}
+	return sources, nil
+}
+
+// GetSource returns a key source by ID.
+func (s *KeySourceService) GetSource(ctx context.Context, id uint) (*models.KeySource, error) {
+	var source models.KeySource
+	if err := s.db.WithContext(ctx).First(&source, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "key_source.not_found", nil)
+		}
+		return nil, app_errors.ParseDBError(err)
+	}
+	source.HasToken = source.Token != ""
+	return &source, nil
+}
+
+// CreateSource validates and persists a new key source for a group.
+func (s *KeySourceService) CreateSource(ctx context.Context, groupID uint, params KeySourceParams) (*models.KeySource, error) {
+	source := models.KeySource{GroupID: groupID, Enabled: true}
+	if err := s.applyParams(&source, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Create(&source).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return &source, nil
+}
+
+// UpdateSource validates and updates an existing key source.
+func (s *KeySourceService) UpdateSource(ctx context.Context, id uint, params KeySourceParams) (*models.KeySource, error) {
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyParams(source, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(source).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return source, nil
+}
+
+// DeleteSource removes a key source. Keys it imported stay in the group as keys added by hand.
+func (s *KeySourceService) DeleteSource(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.KeySource{}, id)
+		if result.Error != nil {
+			return app_errors.ParseDBError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return NewI18nError(app_errors.ErrResourceNotFound, "key_source.not_found", nil)
+		}
+		if err := tx.Model(&models.APIKey{}).Where("source_id = ?", id).Update("source_id", nil).Error; err != nil {
+			return app_errors.ParseDBError(err)
+		}
+		return nil
+	})
+}
+
+// applyParams validates the params and copies them onto the source.
+func (s *KeySourceService) applyParams(source *models.KeySource, params KeySourceParams) error {
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return NewI18nError(app_errors.ErrValidation, "validation.key_source_name_required", nil)
+	}
+
+	sourceType := strings.TrimSpace(params.Type)
+	address := strings.TrimSpace(params.Address)
+	switch sourceType {
+	case models.KeySourceTypeURL, models.KeySourceTypeVault:
+		parsed, err := url.Parse(address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return NewI18nError(app_errors.ErrValidation, "validation.invalid_key_source_address", nil)
+		}
+		// Names are checked when connecting, literal addresses can be rejected right away
+		if ip, err := netip.ParseAddr(parsed.Hostname()); err == nil && isRestrictedAddress(ip) &&
+			!s.configManager.GetKeySourceConfig().AllowPrivateNetworks {
+			return NewI18nError(app_errors.ErrValidation, "validation.key_source_address_not_allowed", nil)
+		}
+	case models.KeySourceTypeDirectory:
+		dir, err := s.sourceDirectory(address)
+		if err != nil {
+			return err
+		}
+		address = dir
+	default:
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_key_source_type", nil)
+	}
+
+	path := strings.Trim(strings.TrimSpace(params.Path), "/")
+	if sourceType == models.KeySourceTypeVault && path == "" {
+		return NewI18nError(app_errors.ErrValidation, "validation.key_source_path_required", nil)
+	}
+
+	format := strings.TrimSpace(params.Format)
+	if format == "" {
+		format = KeyFormatAuto
+	}
+	switch format {
+	case KeyFormatAuto, KeyFormatText, KeyFormatCSV, KeyFormatJSON:
+	default:
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_key_format", nil)
+	}
+
+	missingAction := strings.TrimSpace(params.MissingAction)
+	if missingAction == "" {
+		missingAction = models.KeySourceMissingKeep
+	}
+	switch missingAction {
+	case models.KeySourceMissingKeep, models.KeySourceMissingDisable, models.KeySourceMissingRemove:
+	default:
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_missing_action", nil)
+	}
+
+	if params.IntervalMinutes < 0 {
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_sync_interval", nil)
+	}
+
+	if params.Token != nil {
+		token := strings.TrimSpace(*params.Token)
+		if token == "" {
+			source.Token = ""
+		} else {
+			encrypted, err := s.encryptionSvc.Encrypt(token)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt key source token: %w", err)
+			}
+			source.Token = encrypted
+		}
+	}
+
+	source.Name = name
+	source.Type = sourceType
+	source.Address = address
+	source.Path = path
+	source.Field = strings.TrimSpace(params.Field)
+	source.Format = format
+	source.IntervalMinutes = params.IntervalMinutes
+	source.MissingAction = missingAction
+	if params.Enabled != nil {
+		source.Enabled = *params.Enabled
+	}
+	source.HasToken = source.Token != ""
+	return nil
+}
+
+// StartSync fetches the keys of a source and starts reconciling its group with them
+// as a task. Fetch errors are recorded on the source and returned.
+func (s *KeySourceService) StartSync(ctx context.Context, id uint) (*TaskStatus, error) {
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var group models.Group
+	if err := s.db.WithContext(ctx).First(&group, source.GroupID).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	entries, err := s.fetchSource(ctx, source)
+	if err == nil && len(entries) == 0 {
+		// Never reconcile against an empty result, which would disable or remove every key.
+		err = errors.New("the source returned no keys")
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrKeySourceFetch, err)
+		s.recordSync(source, nil, err)
+		return nil, err
+	}
+
+	task, err := s.taskService.StartTask(TaskTypeKeySync, group.Name, len(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	go s.runSync(task, source, &group, entries)
+
+	status := task.Status()
+	return &status, nil
+}
+
+func (s *KeySourceService) runSync(task *Task, source *models.KeySource, group *models.Group, entries []KeyEntry) {
+	result, err := s.syncKeys(task, source, group, entries)
+	s.recordSync(source, result, err)
+
+	if endErr := task.End(result, err); endErr != nil {
+		logrus.Errorf("Failed to end key sync task for group %d: %v", group.ID, endErr)
+	}
+}
+
+// syncKeys adds new keys of the source to the group and applies the missing action to
+// keys the source imported earlier but no longer has. Keys added by hand are never touched.
+func (s *KeySourceService) syncKeys(task *Task, source *models.KeySource, group *models.Group, entries []KeyEntry) (*KeySyncResult, error) {
+	progressCallback := func(processed int) {
+		if err := task.UpdateProgress(processed); err != nil {
+			logrus.Warnf("Failed to update task progress for group %d: %v", group.ID, err)
+		}
+	}
+
+	sourceHashes := make(map[string]bool, len(entries))
+	for i := range entries {
+		entries[i].SourceID = &source.ID
+		for _, hash := range encryption.HashCandidates(s.encryptionSvc, strings.TrimSpace(entries[i].Key)) {
+			sourceHashes[hash] = true
+		}
+	}
+
+	added, ignored, err := s.keyService.processAndCreateKeys(task.Context(), group.ID, entries, progressCallback)
+	result := &KeySyncResult{Fetched: len(entries), Added: added, Ignored: ignored}
+	if err != nil {
+		return result, err
+	}
+
+	var ownedKeys []models.APIKey
+	if err := s.db.Select("id", "key_hash", "status").Where("group_id = ? AND source_id = ?", group.ID, source.ID).Find(&ownedKeys).Error; err != nil {
+		return result, err
+	}
+
+	var missingIDs, returnedIDs []uint
+	for _, key := range ownedKeys {
+		switch {
+		case !sourceHashes[key.KeyHash]:
+			missingIDs = append(missingIDs, key.ID)
+		case key.Status == models.KeyStatusDisabled:
+			returnedIDs = append(returnedIDs, key.ID)
+		}
+	}
+
+	switch source.MissingAction {
+	case models.KeySourceMissingDisable:
+		disabled, err := s.keyService.KeyProvider.SetKeysDisabled(group.ID, missingIDs, true)
+		if err != nil {
+			return result, err
+		}
+		result.Disabled = int(disabled)
+
+		// Keys that are back in the source were disabled by an earlier sync.
+		enabled, err := s.keyService.KeyProvider.SetKeysDisabled(group.ID, returnedIDs, false)
+		if err != nil {
+			return result, err
+		}
+		result.Enabled = int(enabled)
+	case models.KeySourceMissingRemove:
+		removed, err := s.keyService.KeyProvider.RemoveKeysByID(group.ID, missingIDs)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = int(removed)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"source":   source.Name,
+		"group":    group.Name,
+		"fetched":  result.Fetched,
+		"added":    result.Added,
+		"disabled": result.Disabled,
+		"enabled":  result.Enabled,
+		"removed":  result.Removed,
+	}).Info("Key source sync finished")
+
+	return result, nil
+}
+
+// recordSync saves the outcome of a sync on the source.
+func (s *KeySourceService) recordSync(source *models.KeySource, result *KeySyncResult, syncErr error) {
+	updates := map[string]any{
+		"last_sync_at":    time.Now(),
+		"last_sync_error": "",
+	}
+	if syncErr != nil {
+		updates["last_sync_error"] = syncErr.Error()
+	}
+	if result != nil {
+		updates["last_sync_result"] = datatypes.JSONMap{
+			"fetched":  result.Fetched,
+			"added":    result.Added,
+			"ignored":  result.Ignored,
+			"disabled": result.Disabled,
+			"enabled":  result.Enabled,
+			"removed":  result.Removed,
+		}
+	}
+	if err := s.db.Model(&models.KeySource{}).Where("id = ?", source.ID).Updates(updates).Error; err != nil {
+		logrus.WithError(err).WithField("source_id", source.ID).Error("Failed to record key source sync")
+	}
+}
+
+// fetchSource reads the keys of a source.
+func (s *KeySourceService) fetchSource(ctx context.Context, source *models.KeySource) ([]KeyEntry, error) {
+	token := ""
+	if source.Token != "" {
+		decrypted, err := s.encryptionSvc.Decrypt(source.Token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt source token: %w", err)
+		}
+		token = decrypted
+	}
+
+	switch source.Type {
+	case models.KeySourceTypeURL:
+		return s.FetchURL(ctx, source.Address, token, source.Format)
+	case models.KeySourceTypeVault:
+		return s.fetchVault(ctx, source, token)
+	case models.KeySourceTypeDirectory:
+		return s.fetchDirectory(source)
+	default:
+		return nil, fmt.Errorf("unknown key source type %q", source.Type)
+	}
+}
+
+// FetchURL downloads keys from an HTTP(S) URL, sending the token as a bearer token when set.
+// The auto format is detected from the URL path and the response content type.
+func (s *KeySourceService) FetchURL(ctx context.Context, rawURL, token, format string) ([]KeyEntry, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	body, contentType, err := s.get(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" || format == KeyFormatAuto {
+		format = DetectKeyFormat(parsed.Path, contentType)
+	}
+	return s.keyService.ParseKeyEntries(body, format)
+}
+
+// fetchVault reads a secret from Vault over its HTTP API. Both KV v1 and KV v2 (with
+// "data" in the path, e.g. secret/data/openai) are supported. Each string field of the
+// secret, or only the configured field, holds one or more keys.
+func (s *KeySourceService) fetchVault(ctx context.Context, source *models.KeySource, token string) ([]KeyEntry, error) {
+	endpoint := strings.TrimRight(source.Address, "/") + "/v1/" + source.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	body, _, err := s.get(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("invalid Vault response: %w", err)
+	}
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, isV2 := data["metadata"]; isV2 {
+			data = inner
+		}
+	}
+
+	names := make([]string, 0, len(data))
+	for name := range data {
+		if source.Field == "" || name == source.Field {
+			names = append(names, name)
+		}
+	}
+	if source.Field != "" && len(names) == 0 {
+		return nil, fmt.Errorf("field %q not found in Vault secret", source.Field)
+	}
+	sort.Strings(names)
+
+	format := source.Format
+	if format == KeyFormatAuto {
+		format = KeyFormatText
+	}
+
+	var entries []KeyEntry
+	for _, name := range names {
+		value, ok := data[name].(string)
+		if !ok {
+			continue
+		}
+		fieldEntries, err := s.keyService.ParseKeyEntries([]byte(value), format)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		entries = append(entries, fieldEntries...)
+	}
+	return entries, nil
+}
+
+// sourceDirectory resolves the directory of a source, relative paths being relative to KEY_SOURCE_DIR.
+// Directories outside KEY_SOURCE_DIR are rejected, and directory sources are disabled without it.
+func (s *KeySourceService) sourceDirectory(address string) (string, error) {
+	root := s.configManager.GetKeySourceConfig().Dir
+	if root == "" {
+		return "", NewI18nError(app_errors.ErrValidation, "validation.key_source_directory_disabled", nil)
+	}
+	if address == "" {
+		return "", NewI18nError(app_errors.ErrValidation, "validation.invalid_key_source_address", nil)
+	}
+	if !filepath.IsAbs(address) {
+		address = filepath.Join(root, address)
+	}
+	address = filepath.Clean(address)
+	if !isWithinDir(root, address) {
+		return "", NewI18nError(app_errors.ErrValidation, "validation.key_source_directory_outside_root", map[string]any{"root": root})
+	}
+	return address, nil
+}
+
+// isWithinDir reports whether path is dir or one of its descendants.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fetchDirectory reads the keys of all files in a directory, skipping hidden files and symbolic links.
+// The auto format is detected from each file name.
+func (s *KeySourceService) fetchDirectory(source *models.KeySource) ([]KeyEntry, error) {
+	root := s.configManager.GetKeySourceConfig().Dir
+	if root == "" {
+		return nil, errors.New("directory sources are disabled, set KEY_SOURCE_DIR to enable them")
+	}
+	// Resolve symbolic links so a link inside the root cannot point outside of it
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.EvalSymlinks(source.Address)
+	if err != nil {
+		return nil, err
+	}
+	if !isWithinDir(realRoot, dir) {
+		return nil, fmt.Errorf("directory %s is outside KEY_SOURCE_DIR", source.Address)
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []KeyEntry
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > maxKeySourceBytes {
+			return nil, fmt.Errorf("file %s is larger than %d bytes", dirEntry.Name(), maxKeySourceBytes)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, dirEntry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		format := source.Format
+		if format == KeyFormatAuto {
+			format = DetectKeyFormat(dirEntry.Name(), "")
+		}
+		fileEntries, err := s.keyService.ParseKeyEntries(content, format)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", dirEntry.Name(), err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+// get sends a request and returns the body of a successful response.
+func (s *KeySourceService) get(req *http.Request) ([]byte, string, error) {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySourceBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// The body is not returned, it ends up in last_sync_error and may echo internal details
+		logrus.WithField("host", req.URL.Host).Debugf("Key source returned status %d: %s", resp.StatusCode, app_errors.ParseUpstreamError(body))
+		return nil, "", fmt.Errorf("unexpected status %d from key source", resp.StatusCode)
+	}
+	if len(body) > maxKeySourceBytes {
+		return nil, "", fmt.Errorf("response is larger than %d bytes", maxKeySourceBytes)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"gpt-load/internal/encryption"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Key transfer modes.
+const (
+	KeyTransferMove = "move"
+	KeyTransferCopy = "copy"
+)
+
+// KeyTransferParams selects the keys of the source group to move or copy. Keys are picked
+// by ID and/or by value; when none are given, all keys matching the status and tag filters are used.
+type KeyTransferParams struct {
+	Mode       string
+	KeyIDs     []uint
+	KeysText   string
+	Status     string
+	TagFilters []KeyTagFilter
+}
+
+// KeyTransferResult holds the result of a key transfer task.
+type KeyTransferResult struct {
+	Mode             string `json:"mode"`
+	TargetGroup      string `json:"target_group"`
+	SelectedCount    int    `json:"selected_count"`
+	TransferredCount int    `json:"transferred_count"`
+	DuplicateCount   int    `json:"duplicate_count"`
+}
+
+// KeyTransferService moves or copies keys between groups as a background task.
+type KeyTransferService struct {
+	DB          *gorm.DB
+	TaskService *TaskService
+	KeyService  *KeyService
+}
+
+// NewKeyTransferService creates a new KeyTransferService.
+func NewKeyTransferService(db *gorm.DB, taskService *TaskService, keyService *KeyService) *KeyTransferService {
+	return &KeyTransferService{
+		DB:          db,
+		TaskService: taskService,
+		KeyService:  keyService,
+	}
+}
+
+// StartTransferTask selects the keys of the source group and starts moving or copying them
+// to the target group. Keys already in the target group are skipped and reported as duplicates.
+func (s *KeyTransferService) StartTransferTask(source, target *models.Group, params KeyTransferParams) (*TaskStatus, error) {
+	switch params.Mode {
+	case KeyTransferMove:
+	case KeyTransferCopy:
+		if s.KeyService.SettingsManager.GetSettings().EnforceGlobalKeyUniqueness {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.copy_keys_not_unique", nil)
+		}
+	default:
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.invalid_transfer_mode", nil)
+	}
+	if source.ID == target.ID {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.transfer_same_group", nil)
+	}
+	if target.GroupType == "aggregate" {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.transfer_to_aggregate_group", nil)
+	}
+
+	keyIDs, err := s.selectKeyIDs(source.ID, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyIDs) == 0 {
+		return nil, NewI18nError(app_errors.ErrValidation, "validation.no_keys_selected", nil)
+	}
+
+	// Both groups are locked, so no other task adds the same keys to the target meanwhile
+	task, err := s.TaskService.StartGroupsTask(TaskTypeKeyTransfer, []string{source.Name, target.Name}, len(keyIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	go s.runTransfer(task, source, target, params.Mode, keyIDs)
+
+	status := task.Status()
+	return &status, nil
+}
+
+// selectKeyIDs returns the IDs of the source group keys selected by the params.
+func (s *KeyTransferService) selectKeyIDs(groupID uint, params KeyTransferParams) ([]uint, error) {
+	keyValues := s.KeyService.ParseKeysFromText(params.KeysText)
+	if len(params.KeyIDs)+len(keyValues) > maxRequestKeys {
+		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(params.KeyIDs)+len(keyValues))
+	}
+
+	var keyHashes []string
+	for _, key := range keyValues {
+		keyHashes = append(keyHashes, encryption.HashCandidates(s.KeyService.EncryptionSvc, key)...)
+	}
+
+	query := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID)
+	switch {
+	case len(params.KeyIDs) > 0 && len(keyHashes) > 0:
+		query = query.Where("id IN ? OR key_hash IN ?", params.KeyIDs, keyHashes)
+	case len(params.KeyIDs) > 0:
+		query = query.Where("id IN ?", params.KeyIDs)
+	case len(keyHashes) > 0:
+		query = query.Where("key_hash IN ?", keyHashes)
+	default:
+		query = s.KeyService.ListKeysInGroupQuery(groupID, params.Status, nil, params.TagFilters)
+	}
+
+	var keyIDs []uint
+	if err := query.Pluck("id", &keyIDs).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return keyIDs, nil
+}
+
+func (s *KeyTransferService) runTransfer(task *Task, source, target *models.Group, mode string, keyIDs []uint) {
+	result, err := s.transferKeys(task.Context(), task, source, target, mode, keyIDs)
+	if endErr := task.End(result, err); endErr != nil {
+		logrus.Errorf("Failed to end key transfer task for group %d: %v", source.ID, endErr)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mode":        mode,
+		"source":      source.Name,
+		"target":      target.Name,
+		"selected":    result.SelectedCount,
+		"transferred": result.TransferredCount,
+		"duplicates":  result.DuplicateCount,
+	}).Info("Key transfer finished")
+}
+
+// transferKeys processes the keys in chunks. Keys whose value already exists in the target
+// group are left where they are.
+func (s *KeyTransferService) transferKeys(ctx context.Context, task *Task, source, target *models.Group, mode string, keyIDs []uint) (*KeyTransferResult, error) {
+	result := &KeyTransferResult{Mode: mode, TargetGroup: target.Name, SelectedCount: len(keyIDs)}
+
+	var targetHashes []string
+	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", target.ID).Pluck("key_hash", &targetHashes).Error; err != nil {
+		return result, err
+	}
+	existing := make(map[string]bool, len(targetHashes))
+	for _, hash := range targetHashes {
+		existing[hash] = true
+	}
+
+	for i := 0; i < len(keyIDs); i += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		end := min(i+chunkSize, len(keyIDs))
+
+		var keys []models.APIKey
+		if err := s.DB.Where("group_id = ? AND id IN ?", source.ID, keyIDs[i:end]).Find(&keys).Error; err != nil {
+			return result, err
+		}
+
+		var toTransfer []models.APIKey
+		for _, key := range keys {
+			if existing[key.KeyHash] {
+				result.DuplicateCount++
+				continue
+			}
+			existing[key.KeyHash] = true
+			toTransfer = append(toTransfer, key)
+		}
+
+		if mode == KeyTransferMove {
+			moved, err := s.KeyService.KeyProvider.MoveKeys(source.ID, target.ID, pluckKeyIDs(toTransfer))
+			if err != nil {
+				return result, err
+			}
+			result.TransferredCount += int(moved)
+		} else if len(toTransfer) > 0 {
+			copies := copyKeysForGroup(toTransfer, target.ID)
+			if err := s.KeyService.KeyProvider.AddKeys(target.ID, copies); err != nil {
+				return result, err
+			}
+			result.TransferredCount += len(copies)
+		}
+
+		if err := task.UpdateProgress(end); err != nil {
+			logrus.Warnf("Failed to update task progress for group %d: %v", source.ID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// copyKeysForGroup creates new keys for the target group with the value and metadata of the
+// given keys. Like imported keys, copies start as active unless they have expired.
+func copyKeysForGroup(keys []models.APIKey, groupID uint) []models.APIKey {
+	now := time.Now()
+	copies := make([]models.APIKey, 0, len(keys))
+	for _, key := range keys {
+		keyCopy := models.APIKey{
+			KeyValue:         key.KeyValue,
+			KeyHash:          key.KeyHash,
+			GroupID:          groupID,
+			Status:           models.KeyStatusActive,
+			Notes:            key.Notes,
+			Tags:             key.Tags,
+			Priority:         key.Priority,
+			ExpiresAt:        key.ExpiresAt,
+			RPMLimit:         key.RPMLimit,
+			Schedule:         key.Schedule,
+			Balance:          key.Balance,
+			BalanceCurrency:  key.BalanceCurrency,
+			RateTier:         key.RateTier,
+			BalanceCheckedAt: key.BalanceCheckedAt,
+		}
+		if keyCopy.IsExpired(now) {
+			keyCopy.Status = models.KeyStatusInvalid
+		}
+		copies = append(copies, keyCopy)
+	}
+	return copies
+}
+
+func pluckKeyIDs(keys []models.APIKey) []uint {
+	ids := make([]uint, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	return ids
+}
@@ -102,4 +102,11 @@ func (s *LogCleanupService) cleanupExpiredLogs() {
 	} else {
 		logrus.Debug("No expired request logs found to cleanup")
 	}
+	// 密钥小时统计与请求日志保留相同天数
+	keyStatsResult := s.db.Where("time < ?", cutoffTime.Truncate(time.Hour)).Delete(&models.KeyHourlyStat{})
+	if keyStatsResult.Error != nil {
+		logrus.WithError(keyStatsResult.Error).Error("Failed to cleanup expired key hourly stats")
+	} else if keyStatsResult.RowsAffected > 0 {
+		logrus.WithField("deleted_count", keyStatsResult.RowsAffected).Info("Successfully cleaned up expired key hourly stats")
+	}
 }
@@ -45,8 +45,7 @@ func (s *LogService) logFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB
 			db = db.Where("group_name LIKE ?", "%"+groupName+"%")
 		}
 		if keyValue := c.Query("key_value"); keyValue != "" {
-			keyHash := s.EncryptionSvc.Hash(keyValue)
-			db = db.Where("key_hash = ?", keyHash)
+			db = db.Where("key_hash IN ?", encryption.HashCandidates(s.EncryptionSvc, keyValue))
 		}
 		if model := c.Query("model"); model != "" {
 			db = db.Where("model LIKE ?", "%"+model+"%")
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gpt-load/internal/channel"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/syncer"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const ModelPriceUpdateChannel = "model_prices:updated"
+
+// ModelPriceParams defines the mutable fields of a model price entry.
+type ModelPriceParams struct {
+	ChannelType      string  `json:"channel_type"`
+	ModelPattern     string  `json:"model_pattern"`
+	InputPrice       float64 `json:"input_price"`
+	OutputPrice      float64 `json:"output_price"`
+	CachedInputPrice float64 `json:"cached_input_price"`
+}
+
+// ModelPriceService manages the model price table and computes request costs.
+type ModelPriceService struct {
+	db     *gorm.DB
+	store  store.Store
+	syncer *syncer.CacheSyncer[[]models.ModelPrice]
+}
+
+// NewModelPriceService creates a new, uninitialized ModelPriceService.
+func NewModelPriceService(db *gorm.DB, store store.Store) *ModelPriceService {
+	return &ModelPriceService{
+		db:    db,
+		store: store,
+	}
+}
+
+// Initialize sets up the CacheSyncer for the price table.
+func (s *ModelPriceService) Initialize() error {
+	loader := func() ([]models.ModelPrice, error) {
+		var prices []models.ModelPrice
+		if err := s.db.Find(&prices).Error; err != nil {
+			return nil, fmt.Errorf("failed to load model prices from db: %w", err)
+		}
+		return prices, nil
+	}
+
+	priceSyncer, err := syncer.NewCacheSyncer(
+		loader,
+		s.store,
+		ModelPriceUpdateChannel,
+		logrus.WithField("syncer", "model_prices"),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create model price syncer: %w", err)
+	}
+	s.syncer = priceSyncer
+	return nil
+}
+
+// Stop gracefully stops the background syncer.
+func (s *ModelPriceService) Stop(ctx context.Context) {
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+}
+
+// ListPrices returns all configured model prices.
+func (s *ModelPriceService) ListPrices(ctx context.Context) ([]models.ModelPrice, error) {
+	var prices []models.ModelPrice
+	if err := s.db.WithContext(ctx).Order("channel_type asc, model_pattern asc").Find(&prices).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return prices, nil
+}
+
+// CreatePrice validates and persists a new model price.
+func (s *ModelPriceService) CreatePrice(ctx context.Context, params ModelPriceParams) (*models.ModelPrice, error) {
+	price := models.ModelPrice{}
+	if err := applyModelPriceParams(&price, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Create(&price).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	s.invalidate(ctx)
+	return &price, nil
+}
+
+// UpdatePrice validates and updates an existing model price.
+func (s *ModelPriceService) UpdatePrice(ctx context.Context, id uint, params ModelPriceParams) (*models.ModelPrice, error) {
+	var price models.ModelPrice
+	if err := s.db.WithContext(ctx).First(&price, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "model_price.not_found", nil)
+		}
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	if err := applyModelPriceParams(&price, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(&price).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	s.invalidate(ctx)
+	return &price, nil
+}
+
+// DeletePrice removes a model price.
+func (s *ModelPriceService) DeletePrice(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Delete(&models.ModelPrice{}, id)
+	if result.Error != nil {
+		return app_errors.ParseDBError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return NewI18nError(app_errors.ErrResourceNotFound, "model_price.not_found", nil)
+	}
+
+	s.invalidate(ctx)
+	return nil
+}
+
+// CalculateCost returns the cost of the given token usage using the best matching price entry.
+// Returns 0 when no price is configured for the model.
+func (s *ModelPriceService) CalculateCost(channelType, model string, usage models.TokenUsage) float64 {
+	if s.syncer == nil || model == "" {
+		return 0
+	}
+
+	price := findModelPrice(s.syncer.Get(), channelType, model)
+	if price == nil {
+		return 0
+	}
+
+	cachedPrice := price.CachedInputPrice
+	if cachedPrice == 0 {
+		cachedPrice = price.InputPrice
+	}
+
+	cachedTokens := min(usage.CachedInputTokens, usage.InputTokens)
+	uncachedTokens := usage.InputTokens - cachedTokens
+
+	cost := float64(uncachedTokens)*price.InputPrice +
+		float64(cachedTokens)*cachedPrice +
+		float64(usage.OutputTokens)*price.OutputPrice
+
+	return cost / 1_000_000
+}
+
+// invalidate triggers a price cache reload across all instances.
+func (s *ModelPriceService) invalidate(ctx context.Context) {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate model price cache")
+	}
+}
+
+// applyModelPriceParams validates the params and copies them onto the price entry.
+func applyModelPriceParams(price *models.ModelPrice, params ModelPriceParams) error {
+	channelType := strings.TrimSpace(params.ChannelType)
+	if channelType != "" && !isRegisteredChannelType(channelType) {
+		supported := strings.Join(channel.GetChannels(), ", ")
+		return NewI18nError(app_errors.ErrValidation, "validation.invalid_channel_type", map[string]any{"types": supported})
+	}
+
+	modelPattern := strings.TrimSpace(params.ModelPattern)
+	if modelPattern == "" {
+		return NewI18nError(app_errors.ErrValidation, "validation.model_pattern_required", nil)
+	}
+
+	if params.InputPrice < 0 || params.OutputPrice < 0 || params.CachedInputPrice < 0 {
+		return NewI18nError(app_errors.ErrValidation, "validation.model_price_negative", nil)
+	}
+
+	price.ChannelType = channelType
+	price.ModelPattern = modelPattern
+	price.InputPrice = params.InputPrice
+	price.OutputPrice = params.OutputPrice
+	price.CachedInputPrice = params.CachedInputPrice
+	return nil
+}
+
+// isRegisteredChannelType checks channel type against registered channels.
+func isRegisteredChannelType(channelType string) bool {
+	for _, t := range channel.GetChannels() {
+		if t == channelType {
+			return true
+		}
+	}
+	return false
+}
+
+// findModelPrice picks the most specific price for a model.
+// Channel specific entries win over global ones, exact names over patterns, and longer patterns over shorter ones.
+func findModelPrice(prices []models.ModelPrice, channelType, model string) *models.ModelPrice {
+	var best *models.ModelPrice
+	bestScore := -1
+
+	for i := range prices {
+		price := &prices[i]
+		if price.ChannelType != "" && price.ChannelType != channelType {
+			continue
+		}
+		if !matchModelPattern(price.ModelPattern, model) {
+			continue
+		}
+
+		score := len(price.ModelPattern)
+		if !strings.HasSuffix(price.ModelPattern, "*") {
+			score += 1 << 16
+		}
+		if price.ChannelType != "" {
+			score += 1 << 17
+		}
+
+		if score > bestScore {
+			best = price
+			bestScore = score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,644 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID    = "gpt-load"
+	testOIDCAccessToken = "access-1"
+)
+
+// testOIDCProvider is an OpenID provider serving discovery, JWKS, token and userinfo endpoints.
+// The token endpoint returns an ID token with the claims and key set by the test.
+type testOIDCProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu          sync.Mutex
+	keys        []oidcJWK
+	kid         string
+	claims      func(nonce string) map[string]any
+	nonce       string
+	tokenStatus int
+	tokenForm   url.Values
+	tokenAuth   [2]string
+	userinfo    map[string]any
+	jwksFetches int
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testOIDCProvider{rsaKey: rsaKey, ecKey: ecKey, kid: "rsa-1"}
+	p.keys = []oidcJWK{rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey)}
+	p.claims = p.defaultClaims
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, oidcProviderMetadata{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			UserinfoEndpoint:      p.URL + "/userinfo",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.jwksFetches++
+		writeTestJSON(w, http.StatusOK, map[string]any{"keys": p.keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.tokenForm = r.PostForm
+		p.tokenAuth[0], p.tokenAuth[1], _ = r.BasicAuth()
+		if p.tokenStatus != 0 {
+			writeTestJSON(w, p.tokenStatus, map[string]string{"error": "invalid_grant", "error_description": "code expired"})
+			return
+		}
+		idToken, err := p.sign(p.kid, "", nil, p.claims(p.nonce))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "access_token": testOIDCAccessToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+testOIDCAccessToken || p.userinfo == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeTestJSON(w, http.StatusOK, p.userinfo)
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testOIDCProvider) defaultClaims(nonce string) map[string]any {
+	return map[string]any{
+		"iss":    p.URL,
+		"aud":    testOIDCClientID,
+		"sub":    "user-1",
+		"email":  "alice@example.com",
+		"groups": []string{"admins"},
+		"exp":    time.Now().Add(5 * time.Minute).Unix(),
+		"iat":    time.Now().Unix(),
+		"nonce":  nonce,
+	}
+}
+
+// sign creates a JWT signed by the provider key with the given id. alg overrides the algorithm of the header
+// and key overrides the signing key, to produce tokens the service must reject.
+func (p *testOIDCProvider) sign(kid, alg string, key crypto.Signer, claims map[string]any) (string, error) {
+	if key == nil {
+		key = p.rsaKey
+		if strings.HasPrefix(kid, "ec") {
+			key = p.ecKey
+		}
+	}
+	if alg == "" {
+		alg = "RS256"
+		if _, ok := key.(*ecdsa.PrivateKey); ok {
+			alg = "ES256"
+		}
+	}
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) oidcJWK {
+	return oidcJWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) oidcJWK {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return oidcJWK{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(x),
+		Y:   base64.RawURLEncoding.EncodeToString(y),
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func testOIDCConfig(issuer string) types.OIDCConfig {
+	return types.OIDCConfig{
+		Enabled:       true,
+		Issuer:        issuer,
+		ClientID:      testOIDCClientID,
+		ClientSecret:  "client-secret",
+		RedirectURL:   "https://gpt-load.example.com/api/auth/oidc/callback",
+		Scopes:        []string{"openid", "email"},
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "groups",
+		RoleMapping:   map[string]string{"admins": models.AdminRoleOwner, "ops": models.AdminRoleOperator},
+	}
+}
+
+func newTestOIDCService(t *testing.T, cfg types.OIDCConfig) (*OIDCService, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t, &models.AdminUser{})
+	st := store.NewMemoryStore()
+	configManager := &stubConfigManager{oidc: cfg}
+	return NewOIDCService(configManager, st, NewAdminAuthService(db, st, configManager)), db
+}
+
+// startTestLogin starts a login and tells the provider the nonce to put into the next ID token.
+func startTestLogin(t *testing.T, s *OIDCService, p *testOIDCProvider) (url.Values, string) {
+	t.Helper()
+	authURL, state, err := s.StartLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartLogin failed: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	p.mu.Lock()
+	p.nonce = query.Get("nonce")
+	p.mu.Unlock()
+	return query, state
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testOIDCConfig(p.URL)
+	metadata := &oidcProviderMetadata{Issuer: p.URL, JWKSURI: p.URL + "/jwks"}
+
+	tests := []struct {
+		name    string
+		kid     string
+		alg     string
+		key     crypto.Signer
+		claims  func(claims map[string]any)
+		nonce   string
+		raw     string // Used instead of a signed token
+		wantErr string
+	}{
+		{name: "RS256", kid: "rsa-1"},
+		{name: "ES256", kid: "ec-1"},
+		{name: "audience list", kid: "rsa-1", claims: func(c map[string]any) {
+			c["aud"] = []string{"other", testOIDCClientID}
+			c["azp"] = testOIDCClientID
+		}},
+		{name: "issuer with trailing slash", kid: "rsa-1", claims: func(c map[string]any) { c["iss"] = p.URL + "/" }},
+		{name: "expired within clock skew", kid: "rsa-1", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-30 * time.Second).Unix() }},
+		{name: "bad signature", kid: "rsa-1", key: otherKey, wantErr: "invalid id_token signature"},
+		{name: "tampered payload", raw: "tampered", wantErr: "invalid id_token signature"},
+		{name: "unknown key", kid: "rsa-2", wantErr: "unknown id_token signing key"},
+		{name: "no key id with several keys", wantErr: "unknown id_token signing key"},
+		{name: "algorithm of another key type", kid: "ec-1", alg: "RS256", wantErr: "does not match the signing key"},
+		{name: "symmetric algorithm", kid: "rsa-1", alg: "HS256", wantErr: "unsupported id_token algorithm"},
+		{name: "unsigned", kid: "rsa-1", alg: "none", wantErr: "unsupported id_token algorithm"},
+		{name: "malformed", raw: "not-a-jwt", wantErr: "malformed id_token"},
+		{name: "wrong issuer", kid: "rsa-1", claims: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, wantErr: "issuer mismatch"},
+		{name: "wrong audience", kid: "rsa-1", claims: func(c map[string]any) { c["aud"] = "other" }, wantErr: "audience"},
+		{name: "authorized party of another client", kid: "rsa-1", claims: func(c map[string]any) {
+			c["aud"] = []string{"other", testOIDCClientID}
+			c["azp"] = "other"
+		}, wantErr: "authorized party"},
+		{name: "expired", kid: "rsa-1", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, wantErr: "expired"},
+		{name: "no expiry", kid: "rsa-1", claims: func(c map[string]any) { delete(c, "exp") }, wantErr: "expired"},
+		{name: "nonce mismatch", kid: "rsa-1", nonce: "other-nonce", wantErr: "nonce mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestOIDCService(t, cfg)
+			claims := p.defaultClaims("nonce-1")
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+
+			var idToken string
+			switch tt.raw {
+			case "":
+				if idToken, err = p.sign(tt.kid, tt.alg, tt.key, claims); err != nil {
+					t.Fatal(err)
+				}
+			case "tampered":
+				signed, err := p.sign("rsa-1", "", nil, claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				claims["sub"] = "admin"
+				payload, _ := json.Marshal(claims)
+				parts := strings.Split(signed, ".")
+				idToken = parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+			default:
+				idToken = tt.raw
+			}
+
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			got, err := s.verifyIDToken(context.Background(), metadata, cfg, idToken, nonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyIDToken error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyIDToken failed: %v", err)
+			}
+			if got["sub"] != "user-1" {
+				t.Errorf("sub = %v, want user-1", got["sub"])
+			}
+		})
+	}
+}
+
+func TestOIDCSigningKeyRotation(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	cfg := testOIDCConfig(p.URL)
+	metadata := &oidcProviderMetadata{Issuer: p.URL, JWKSURI: p.URL + "/jwks"}
+	s, _ := newTestOIDCService(t, cfg)
+	ctx := context.Background()
+
+	verify := func(kid string, key crypto.Signer) error {
+		idToken, err := p.sign(kid, "", key, p.defaultClaims("nonce-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.verifyIDToken(ctx, metadata, cfg, idToken, "nonce-1")
+		return err
+	}
+
+	if err := verify("rsa-1", nil); err != nil {
+		t.Fatalf("initial key rejected: %v", err)
+	}
+	if err := verify("rsa-1", nil); err != nil {
+		t.Fatalf("cached key rejected: %v", err)
+	}
+
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.keys = []oidcJWK{rsaJWK("rsa-2", &rotated.PublicKey)}
+	p.mu.Unlock()
+
+	// Misses do not refetch the key set more than once per oidcJWKSRefreshMin
+	if err := verify("rsa-2", rotated); err == nil {
+		t.Fatal("rotated key accepted before the refresh interval")
+	}
+	s.mu.Lock()
+	s.keysFetchedAt = time.Now().Add(-2 * oidcJWKSRefreshMin)
+	s.mu.Unlock()
+	if err := verify("rsa-2", rotated); err != nil {
+		t.Fatalf("rotated key rejected after the refresh interval: %v", err)
+	}
+	// The provider has a single key now, so tokens without a key id use it
+	if err := verify("", rotated); err != nil {
+		t.Fatalf("token without key id rejected: %v", err)
+	}
+	if err := verify("rsa-1", nil); err == nil {
+		t.Fatal("retired key still accepted")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jwksFetches != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", p.jwksFetches)
+	}
+}
+
+func TestOIDCStartLogin(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	cfg := testOIDCConfig(p.URL)
+	s, _ := newTestOIDCService(t, cfg)
+
+	query, state := startTestLogin(t, s, p)
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testOIDCClientID,
+		"redirect_uri":          cfg.RedirectURL,
+		"scope":                 "openid email",
+		"state":                 state,
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if query.Get("nonce") == "" || query.Get("code_challenge") == "" {
+		t.Errorf("auth URL is missing the nonce or code challenge: %v", query)
+	}
+
+	_, otherState := startTestLogin(t, s, p)
+	if otherState == state {
+		t.Error("two logins got the same state")
+	}
+
+	disabled, _ := newTestOIDCService(t, types.OIDCConfig{})
+	if _, _, err := disabled.StartLogin(context.Background()); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("StartLogin without OIDC = %v, want ErrOIDCDisabled", err)
+	}
+}
+
+func TestOIDCHandleCallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		claims       func(claims map[string]any)
+		userinfo     map[string]any
+		kid          string
+		tokenStatus  int
+		browserState string // Defaults to the state of the login
+		code         string
+		defaultRole  string
+		wantRole     string
+		wantUsername string
+		wantErr      error
+		wantErrText  string
+	}{
+		{name: "RS256 owner", wantRole: models.AdminRoleOwner, wantUsername: "alice@example.com"},
+		{name: "ES256", kid: "ec-1", wantRole: models.AdminRoleOwner, wantUsername: "alice@example.com"},
+		{name: "username claim", claims: func(c map[string]any) { c["preferred_username"] = "alice" }, wantRole: models.AdminRoleOwner, wantUsername: "alice"},
+		{name: "highest mapped role", claims: func(c map[string]any) { c["groups"] = []string{"ops", "admins"} }, wantRole: models.AdminRoleOwner, wantUsername: "alice@example.com"},
+		{name: "default role", claims: func(c map[string]any) { c["groups"] = []string{"staff"} }, defaultRole: models.AdminRoleViewer, wantRole: models.AdminRoleViewer, wantUsername: "alice@example.com"},
+		{
+			name:         "roles from userinfo",
+			claims:       func(c map[string]any) { delete(c, "groups") },
+			userinfo:     map[string]any{"sub": "user-1", "groups": []string{"ops"}},
+			wantRole:     models.AdminRoleOperator,
+			wantUsername: "alice@example.com",
+		},
+		{
+			name:     "userinfo of another subject is ignored",
+			claims:   func(c map[string]any) { delete(c, "groups") },
+			userinfo: map[string]any{"sub": "user-2", "groups": []string{"admins"}},
+			wantErr:  ErrOIDCNoRole,
+		},
+		{name: "no mapped role", claims: func(c map[string]any) { c["groups"] = []string{"staff"} }, wantErr: ErrOIDCNoRole},
+		{name: "state of another browser", browserState: "other-state", wantErr: ErrOIDCStateMismatch},
+		{name: "missing code", code: "-", wantErrText: "missing state or code"},
+		{name: "missing subject", claims: func(c map[string]any) { delete(c, "sub") }, wantErrText: "no subject"},
+		{name: "nonce of another login", claims: func(c map[string]any) { c["nonce"] = "other-nonce" }, wantErrText: "nonce mismatch"},
+		{name: "rejected code", tokenStatus: http.StatusBadRequest, wantErrText: "invalid_grant"},
+		{name: "reserved username", claims: func(c map[string]any) { c["preferred_username"] = adminRootUsername }, wantErr: ErrAdminUsernameTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestOIDCProvider(t)
+			cfg := testOIDCConfig(p.URL)
+			cfg.DefaultRole = tt.defaultRole
+			s, db := newTestOIDCService(t, cfg)
+
+			p.mu.Lock()
+			if tt.kid != "" {
+				p.kid = tt.kid
+			}
+			p.tokenStatus = tt.tokenStatus
+			p.userinfo = tt.userinfo
+			p.claims = func(nonce string) map[string]any {
+				claims := p.defaultClaims(nonce)
+				if tt.claims != nil {
+					tt.claims(claims)
+				}
+				return claims
+			}
+			p.mu.Unlock()
+
+			query, state := startTestLogin(t, s, p)
+			browserState := state
+			if tt.browserState != "" {
+				browserState = tt.browserState
+			}
+			code := "code-1"
+			if tt.code == "-" {
+				code = ""
+			}
+
+			result, err := s.HandleCallback(context.Background(), state, browserState, code)
+			if tt.wantErr != nil || tt.wantErrText != "" {
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("HandleCallback error = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Fatalf("HandleCallback error = %v, want %q", err, tt.wantErrText)
+				}
+				var count int64
+				db.Model(&models.AdminUser{}).Count(&count)
+				if count != 0 {
+					t.Errorf("%d admin users created by a failed login", count)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HandleCallback failed: %v", err)
+			}
+
+			if result.Principal.Role != tt.wantRole || result.Principal.Username != tt.wantUsername || result.Principal.AuthMethod != "oidc" {
+				t.Errorf("principal = %+v, want %s with role %s", result.Principal, tt.wantUsername, tt.wantRole)
+			}
+			if remaining := time.Until(result.ExpiresAt); remaining > OIDCSessionTTL || remaining < OIDCSessionTTL-time.Minute {
+				t.Errorf("session expires in %s, want %s", remaining, OIDCSessionTTL)
+			}
+
+			// The token request proves possession of the verifier behind the challenge of the auth URL
+			p.mu.Lock()
+			form, auth := p.tokenForm, p.tokenAuth
+			p.mu.Unlock()
+			challenge := sha256.Sum256([]byte(form.Get("code_verifier")))
+			if got := base64.RawURLEncoding.EncodeToString(challenge[:]); got != query.Get("code_challenge") {
+				t.Errorf("code_verifier does not match the code_challenge")
+			}
+			if form.Get("code") != code || form.Get("redirect_uri") != cfg.RedirectURL || form.Get("grant_type") != "authorization_code" {
+				t.Errorf("token request form = %v", form)
+			}
+			if auth != [2]string{cfg.ClientID, cfg.ClientSecret} {
+				t.Errorf("token request basic auth = %v, want the client credentials", auth)
+			}
+
+			var user models.AdminUser
+			if err := db.Where("oidc_issuer = ? AND oidc_subject = ?", p.URL, "user-1").First(&user).Error; err != nil {
+				t.Fatalf("admin user not created: %v", err)
+			}
+			if user.ID != result.Principal.UserID || user.Role != tt.wantRole || !user.Enabled {
+				t.Errorf("admin user = %+v, want enabled user %d with role %s", user, result.Principal.UserID, tt.wantRole)
+			}
+
+			// Each state can only be used once
+			if _, err := s.HandleCallback(context.Background(), state, state, code); err == nil || !strings.Contains(err.Error(), "unknown or expired") {
+				t.Errorf("reused state error = %v, want unknown or expired state", err)
+			}
+		})
+	}
+}
+
+func TestOIDCReturningUser(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	s, db := newTestOIDCService(t, testOIDCConfig(p.URL))
+	ctx := context.Background()
+
+	login := func() *LoginResult {
+		t.Helper()
+		_, state := startTestLogin(t, s, p)
+		result, err := s.HandleCallback(ctx, state, state, "code")
+		if err != nil {
+			t.Fatalf("HandleCallback failed: %v", err)
+		}
+		return result
+	}
+
+	first := login()
+
+	// Username and role follow the provider on every login, the account stays the same
+	p.mu.Lock()
+	p.claims = func(nonce string) map[string]any {
+		claims := p.defaultClaims(nonce)
+		claims["email"] = "alice@corp.example.com"
+		claims["groups"] = []string{"ops"}
+		return claims
+	}
+	p.mu.Unlock()
+	second := login()
+
+	if second.Principal.UserID != first.Principal.UserID {
+		t.Errorf("returning user got account %d, want %d", second.Principal.UserID, first.Principal.UserID)
+	}
+	if second.Principal.Username != "alice@corp.example.com" || second.Principal.Role != models.AdminRoleOperator {
+		t.Errorf("principal = %+v, want updated username and operator role", second.Principal)
+	}
+	var count int64
+	db.Model(&models.AdminUser{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d admin users, want 1", count)
+	}
+
+	// A disabled account cannot sign in again
+	if err := db.Model(&models.AdminUser{}).Where("id = ?", first.Principal.UserID).Update("enabled", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, state := startTestLogin(t, s, p)
+	if _, err := s.HandleCallback(ctx, state, state, "code"); !errors.Is(err, ErrAdminUnauthorized) {
+		t.Errorf("disabled user login = %v, want ErrAdminUnauthorized", err)
+	}
+}
+
+func TestMapOIDCRole(t *testing.T) {
+	cfg := types.OIDCConfig{
+		RoleClaim:   "realm_access.roles",
+		RoleMapping: map[string]string{"gpt-admin": models.AdminRoleOwner, "gpt-ops": models.AdminRoleOperator},
+		DefaultRole: models.AdminRoleViewer,
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   string
+	}{
+		{name: "nested claim", claims: map[string]any{"realm_access": map[string]any{"roles": []any{"gpt-ops"}}}, want: models.AdminRoleOperator},
+		{name: "highest role wins", claims: map[string]any{"realm_access": map[string]any{"roles": []any{"gpt-ops", "gpt-admin"}}}, want: models.AdminRoleOwner},
+		{name: "single string", claims: map[string]any{"realm_access": map[string]any{"roles": "gpt-admin"}}, want: models.AdminRoleOwner},
+		{name: "flat claim with dots", claims: map[string]any{"realm_access.roles": []any{"gpt-ops"}}, want: models.AdminRoleOperator},
+		{name: "unmapped values", claims: map[string]any{"realm_access": map[string]any{"roles": []any{"other"}}}, want: models.AdminRoleViewer},
+		{name: "missing claim", claims: map[string]any{}, want: models.AdminRoleViewer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapOIDCRole(tt.claims, cfg); got != tt.want {
+				t.Errorf("mapOIDCRole = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
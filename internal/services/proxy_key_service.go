@@ -0,0 +1,830 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gpt-load/internal/config"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/syncer"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const ProxyKeyUpdateChannel = "proxy_keys:updated"
+
+const (
+	// proxyKeySaltSetting is the system_settings row holding the install-wide hashing salt
+	proxyKeySaltSetting = "proxy_key_salt"
+
+	generatedProxyKeyPrefix = "sk-gl-"
+	defaultRotationGrace    = 24 * time.Hour
+	maxRotationGrace        = 30 * 24 * time.Hour
+	maxProxyKeyLength       = 256
+	// proxyKeyCleanupInterval is how often the master removes expired proxy keys
+	proxyKeyCleanupInterval = time.Hour
+)
+
+// ProxyKeyParams defines the fields for creating a proxy key.
+// An empty Key generates a random one.
+type ProxyKeyParams struct {
+	GroupID    uint   `json:"group_id"`
+	Name       string `json:"name"`
+	Key        string `json:"key"`
+	AllowedIPs string `json:"allowed_ips"`
+	DeniedIPs  string `json:"denied_ips"`
+	RateLimits
+}
+
+// ProxyKeyUpdateParams defines the editable fields of a proxy key. Nil fields are left unchanged.
+type ProxyKeyUpdateParams struct {
+	Name                 *string `json:"name"`
+	AllowedIPs           *string `json:"allowed_ips"`
+	DeniedIPs            *string `json:"denied_ips"`
+	RateLimitRPM         *int    `json:"rate_limit_rpm"`
+	RateLimitTPM         *int    `json:"rate_limit_tpm"`
+	RateLimitConcurrency *int    `json:"rate_limit_concurrency"`
+}
+
+// ProxyKeyRotateParams defines how long the old key keeps working after a rotation.
+type ProxyKeyRotateParams struct {
+	GraceMinutes *int `json:"grace_minutes"`
+}
+
+// ProxyKeyCreated is returned once when a key is created or rotated, as only its hash is stored.
+type ProxyKeyCreated struct {
+	models.ProxyKey
+	Key string `json:"key"`
+}
+
+// ProxyKeyMatch identifies the proxy key that authenticated a request.
+// ID is 0 for legacy plaintext keys.
+type ProxyKeyMatch struct {
+	ID        uint
+	GroupID   uint
+	Preview   string
+	IPAllowed bool
+	Limits    RateLimits
+}
+
+// proxyKeyEntry is the cached state of a proxy key needed to authenticate requests.
+type proxyKeyEntry struct {
+	id        uint
+	preview   string
+	expiresAt *time.Time
+	ipRules   *utils.IPRules
+	limits    RateLimits
+}
+
+// proxyKeyIndex maps a group ID (0 for global keys) to key hashes and their entries.
+type proxyKeyIndex map[uint]map[string]*proxyKeyEntry
+
+// ProxyKeyService stores proxy keys as salted hashes and authenticates proxy requests against them.
+// Plaintext keys left in groups.proxy_keys or the proxy_keys setting by older versions keep working
+// until they are converted with the migrate-proxy-keys command.
+type ProxyKeyService struct {
+	db              *gorm.DB
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+	auditService    *AuditService
+	syncer          *syncer.CacheSyncer[proxyKeyIndex]
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+
+	saltOnce sync.Once
+	salt     []byte
+	saltErr  error
+}
+
+// NewProxyKeyService creates a new, uninitialized ProxyKeyService.
+func NewProxyKeyService(db *gorm.DB, store store.Store, settingsManager *config.SystemSettingsManager, auditService *AuditService) *ProxyKeyService {
+	return &ProxyKeyService{
+		db:              db,
+		store:           store,
+		settingsManager: settingsManager,
+		auditService:    auditService,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+// Initialize sets up the CacheSyncer for the key hash index.
+func (s *ProxyKeyService) Initialize() error {
+	if _, err := s.getSalt(); err != nil {
+		return err
+	}
+
+	keySyncer, err := syncer.NewCacheSyncer(
+		s.loadIndex,
+		s.store,
+		ProxyKeyUpdateChannel,
+		logrus.WithField("syncer", "proxy_keys"),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy key syncer: %w", err)
+	}
+	s.syncer = keySyncer
+	return nil
+}
+
+// Start starts the removal of expired keys, it only runs on the master node.
+func (s *ProxyKeyService) Start() {
+	s.wg.Add(1)
+	go s.runCleanup()
+}
+
+// Stop gracefully stops the background syncer and the cleanup of expired keys.
+func (s *ProxyKeyService) Stop(ctx context.Context) {
+	close(s.stopCh)
+	s.wg.Wait()
+	if s.syncer != nil {
+		s.syncer.Stop()
+	}
+}
+
+// runCleanup periodically removes expired keys.
+func (s *ProxyKeyService) runCleanup() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(proxyKeyCleanupInterval)
+	defer ticker.Stop()
+
+	s.cleanupExpiredKeys()
+	for {
+		select {
+		case <-ticker.C:
+			s.cleanupExpiredKeys()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// cleanupExpiredKeys deletes keys past their expiry, such as old keys whose rotation grace period has ended.
+func (s *ProxyKeyService) cleanupExpiredKeys() {
+	ctx := context.Background()
+	result := s.db.WithContext(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Delete(&models.ProxyKey{})
+	if result.Error != nil {
+		logrus.WithError(result.Error).Error("Failed to remove expired proxy keys")
+		return
+	}
+	if result.RowsAffected > 0 {
+		logrus.WithField("deleted_count", result.RowsAffected).Info("Removed expired proxy keys")
+		s.InvalidateCache(ctx)
+	}
+}
+
+// EnsureInitialKey makes the AUTH_KEY the first global proxy key of a fresh installation,
+// matching the behavior of versions that stored proxy keys in plain text.
+func (s *ProxyKeyService) EnsureInitialKey(ctx context.Context, authKey string) error {
+	if authKey == "" {
+		return nil
+	}
+
+	var keyCount, groupCount int64
+	if err := s.db.WithContext(ctx).Model(&models.ProxyKey{}).Count(&keyCount).Error; err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Group{}).Count(&groupCount).Error; err != nil {
+		return err
+	}
+	if keyCount > 0 || groupCount > 0 || s.settingsManager.GetSettings().ProxyKeys != "" {
+		return nil
+	}
+
+	if _, err := s.AddKeys(ctx, 0, []string{authKey}); err != nil {
+		return err
+	}
+	logrus.Info("Initialized AUTH_KEY as the global proxy key.")
+	return nil
+}
+
+// Authenticate returns the proxy key that grants access to the group, or nil if the key is unknown.
+// IPAllowed reports whether the key's own IP rules allow clientIP; when the key exists both
+// globally and in the group, a match that allows the address is preferred.
+func (s *ProxyKeyService) Authenticate(groupID uint, key, clientIP string) *ProxyKeyMatch {
+	if key == "" || s.syncer == nil {
+		return nil
+	}
+
+	hash, err := s.hash(key)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to hash proxy key")
+		return nil
+	}
+
+	index := s.syncer.Get()
+	now := time.Now()
+	var match *ProxyKeyMatch
+	for _, id := range []uint{groupID, 0} {
+		entry := index.lookup(id, hash, now)
+		if entry == nil {
+			continue
+		}
+		candidate := &ProxyKeyMatch{
+			ID:        entry.id,
+			GroupID:   id,
+			Preview:   entry.preview,
+			IPAllowed: entry.ipRules.Allowed(clientIP),
+			Limits:    entry.limits,
+		}
+		if match == nil || (!match.IPAllowed && candidate.IPAllowed) {
+			match = candidate
+		}
+	}
+	return match
+}
+
+// CountKeys returns the number of usable proxy keys of a group, including legacy plaintext keys.
+func (s *ProxyKeyService) CountKeys(groupID uint) int {
+	if s.syncer == nil {
+		return 0
+	}
+	count := 0
+	now := time.Now()
+	for _, entry := range s.syncer.Get()[groupID] {
+		if entry.expiresAt == nil || now.Before(*entry.expiresAt) {
+			count++
+		}
+	}
+	return count
+}
+
+// ListKeys returns the unexpired proxy keys of a group, or the global keys for group 0.
+// Expired keys are left to the background cleanup.
+func (s *ProxyKeyService) ListKeys(ctx context.Context, groupID uint) ([]models.ProxyKey, error) {
+	keys := make([]models.ProxyKey, 0)
+	if err := s.db.WithContext(ctx).
+		Where("group_id = ? AND (expires_at IS NULL OR expires_at > ?)", groupID, time.Now()).
+		Order("id asc").Find(&keys).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	return keys, nil
+}
+
+// CreateKey stores a new proxy key and returns it in full, which is the only time it is available.
+func (s *ProxyKeyService) CreateKey(ctx context.Context, params ProxyKeyParams) (*ProxyKeyCreated, error) {
+	if err := s.ensureGroupExists(ctx, params.GroupID); err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimSpace(params.Key)
+	if key == "" {
+		generated, err := generateProxyKey()
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	} else if err := validateProxyKey(key); err != nil {
+		return nil, err
+	}
+
+	allowedIPs, deniedIPs := strings.TrimSpace(params.AllowedIPs), strings.TrimSpace(params.DeniedIPs)
+	if err := validateProxyKeyIPRules(allowedIPs, deniedIPs); err != nil {
+		return nil, err
+	}
+	if err := validateProxyKeyRateLimits(params.RateLimits); err != nil {
+		return nil, err
+	}
+
+	created, err := s.insertKey(ctx, s.db.WithContext(ctx), models.ProxyKey{
+		GroupID:              params.GroupID,
+		Name:                 strings.TrimSpace(params.Name),
+		AllowedIPs:           allowedIPs,
+		DeniedIPs:            deniedIPs,
+		RateLimitRPM:         params.RPM,
+		RateLimitTPM:         params.TPM,
+		RateLimitConcurrency: params.Concurrency,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx)
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "proxy_key.create",
+		TargetType: models.AuditTargetProxyKey,
+		TargetID:   created.ID,
+		TargetName: created.Name,
+		After:      proxyKeyAuditSnapshot(&created.ProxyKey),
+	})
+
+	return created, nil
+}
+
+// RotateKey issues a replacement key. The old key keeps working until the grace period ends.
+func (s *ProxyKeyService) RotateKey(ctx context.Context, id uint, params ProxyKeyRotateParams) (*ProxyKeyCreated, error) {
+	grace := defaultRotationGrace
+	if params.GraceMinutes != nil {
+		grace = time.Duration(*params.GraceMinutes) * time.Minute
+		if grace < 0 || grace > maxRotationGrace {
+			return nil, NewI18nError(app_errors.ErrValidation, "validation.proxy_key_grace_invalid", map[string]any{"max": int(maxRotationGrace.Minutes())})
+		}
+	}
+
+	newKey, err := generateProxyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var oldKey models.ProxyKey
+	var created *ProxyKeyCreated
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&oldKey, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NewI18nError(app_errors.ErrResourceNotFound, "proxy_key.not_found", nil)
+			}
+			return app_errors.ParseDBError(err)
+		}
+		if oldKey.ExpiresAt != nil {
+			return NewI18nError(app_errors.ErrValidation, "proxy_key.already_rotated", nil)
+		}
+
+		var err error
+		created, err = s.insertKey(ctx, tx, models.ProxyKey{
+			GroupID:              oldKey.GroupID,
+			Name:                 oldKey.Name,
+			AllowedIPs:           oldKey.AllowedIPs,
+			DeniedIPs:            oldKey.DeniedIPs,
+			RateLimitRPM:         oldKey.RateLimitRPM,
+			RateLimitTPM:         oldKey.RateLimitTPM,
+			RateLimitConcurrency: oldKey.RateLimitConcurrency,
+		}, newKey)
+		if err != nil {
+			return err
+		}
+
+		if grace == 0 {
+			return tx.Delete(&oldKey).Error
+		}
+		expiresAt := time.Now().Add(grace)
+		oldKey.ExpiresAt = &expiresAt
+		return tx.Model(&oldKey).Update("expires_at", expiresAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx)
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "proxy_key.rotate",
+		TargetType: models.AuditTargetProxyKey,
+		TargetID:   oldKey.ID,
+		TargetName: oldKey.Name,
+		Before:     proxyKeyAuditSnapshot(&oldKey),
+		After:      proxyKeyAuditSnapshot(&created.ProxyKey),
+	})
+
+	return created, nil
+}
+
+// UpdateKey changes the name, the IP rules or the rate limits of a proxy key.
+func (s *ProxyKeyService) UpdateKey(ctx context.Context, id uint, params ProxyKeyUpdateParams) (*models.ProxyKey, error) {
+	var key models.ProxyKey
+	if err := s.db.WithContext(ctx).First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewI18nError(app_errors.ErrResourceNotFound, "proxy_key.not_found", nil)
+		}
+		return nil, app_errors.ParseDBError(err)
+	}
+	before := proxyKeyAuditSnapshot(&key)
+
+	if params.Name != nil {
+		key.Name = strings.TrimSpace(*params.Name)
+	}
+	if params.AllowedIPs != nil {
+		key.AllowedIPs = strings.TrimSpace(*params.AllowedIPs)
+	}
+	if params.DeniedIPs != nil {
+		key.DeniedIPs = strings.TrimSpace(*params.DeniedIPs)
+	}
+	if params.RateLimitRPM != nil {
+		key.RateLimitRPM = *params.RateLimitRPM
+	}
+	if params.RateLimitTPM != nil {
+		key.RateLimitTPM = *params.RateLimitTPM
+	}
+	if params.RateLimitConcurrency != nil {
+		key.RateLimitConcurrency = *params.RateLimitConcurrency
+	}
+	if err := validateProxyKeyIPRules(key.AllowedIPs, key.DeniedIPs); err != nil {
+		return nil, err
+	}
+	if err := validateProxyKeyRateLimits(proxyKeyRateLimits(&key)); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Model(&key).Select("name", "allowed_ips", "denied_ips", "rate_limit_rpm", "rate_limit_tpm", "rate_limit_concurrency").Updates(&key).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	s.invalidate(ctx)
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "proxy_key.update",
+		TargetType: models.AuditTargetProxyKey,
+		TargetID:   key.ID,
+		TargetName: key.Name,
+		Before:     before,
+		After:      proxyKeyAuditSnapshot(&key),
+	})
+
+	return &key, nil
+}
+
+// DeleteKey revokes a proxy key immediately.
+func (s *ProxyKeyService) DeleteKey(ctx context.Context, id uint) error {
+	var key models.ProxyKey
+	if err := s.db.WithContext(ctx).First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewI18nError(app_errors.ErrResourceNotFound, "proxy_key.not_found", nil)
+		}
+		return app_errors.ParseDBError(err)
+	}
+
+	if err := s.db.WithContext(ctx).Delete(&key).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	s.invalidate(ctx)
+
+	s.auditService.Record(ctx, AuditEntry{
+		Action:     "proxy_key.delete",
+		TargetType: models.AuditTargetProxyKey,
+		TargetID:   key.ID,
+		TargetName: key.Name,
+		Before:     proxyKeyAuditSnapshot(&key),
+	})
+
+	return nil
+}
+
+// AddKeys hashes and stores plaintext keys for a group, skipping keys it already has.
+// It is used where keys are still entered as comma-separated text, such as group forms and settings.
+func (s *ProxyKeyService) AddKeys(ctx context.Context, groupID uint, keys []string) (int, error) {
+	added, err := s.addKeys(ctx, s.db.WithContext(ctx), groupID, keys)
+	if err != nil {
+		return 0, err
+	}
+	if added > 0 {
+		s.invalidate(ctx)
+	}
+	return added, nil
+}
+
+// ImportSettingKeys moves the keys entered in the proxy_keys setting, together with any legacy
+// plaintext value of that setting, into the hashed global keys.
+func (s *ProxyKeyService) ImportSettingKeys(ctx context.Context, input string) (int, error) {
+	keys := utils.SplitAndTrim(input, ",")
+	keys = append(keys, utils.SplitAndTrim(s.settingsManager.GetSettings().ProxyKeys, ",")...)
+	return s.AddKeys(ctx, 0, keys)
+}
+
+// CopyGroupKeys gives the target group the same proxy keys as the source group.
+func (s *ProxyKeyService) CopyGroupKeys(tx *gorm.DB, sourceGroupID, targetGroupID uint) error {
+	var keys []models.ProxyKey
+	if err := tx.Where("group_id = ? AND (expires_at IS NULL OR expires_at > ?)", sourceGroupID, time.Now()).Find(&keys).Error; err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	copies := make([]models.ProxyKey, len(keys))
+	for i, key := range keys {
+		copies[i] = models.ProxyKey{
+			GroupID:              targetGroupID,
+			Name:                 key.Name,
+			KeyHash:              key.KeyHash,
+			KeyPreview:           key.KeyPreview,
+			AllowedIPs:           key.AllowedIPs,
+			DeniedIPs:            key.DeniedIPs,
+			RateLimitRPM:         key.RateLimitRPM,
+			RateLimitTPM:         key.RateLimitTPM,
+			RateLimitConcurrency: key.RateLimitConcurrency,
+			ExpiresAt:            key.ExpiresAt,
+		}
+	}
+	return tx.Create(&copies).Error
+}
+
+// InvalidateCache reloads the key index on all nodes.
+func (s *ProxyKeyService) InvalidateCache(ctx context.Context) {
+	s.invalidate(ctx)
+}
+
+// MigrateLegacyKeys converts the plaintext keys of the proxy_keys setting and of every group
+// into hashed keys and clears the plaintext values. It returns the number of keys converted.
+func (s *ProxyKeyService) MigrateLegacyKeys(ctx context.Context) (int, error) {
+	// Load the salt before the transaction, as it may need to be written on a separate connection
+	if _, err := s.getSalt(); err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var setting models.SystemSetting
+		err := tx.Where("setting_key = ?", "proxy_keys").First(&setting).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && strings.TrimSpace(setting.SettingValue) != "" {
+			added, err := s.addKeys(ctx, tx, 0, utils.SplitAndTrim(setting.SettingValue, ","))
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&setting).Update("setting_value", "").Error; err != nil {
+				return err
+			}
+			converted += added
+			logrus.Infof("Converted %d global proxy keys", added)
+		}
+
+		var groups []models.Group
+		if err := tx.Where("proxy_keys IS NOT NULL AND proxy_keys != ''").Find(&groups).Error; err != nil {
+			return err
+		}
+		for _, group := range groups {
+			added, err := s.addKeys(ctx, tx, group.ID, utils.SplitAndTrim(group.ProxyKeys, ","))
+			if err != nil {
+				return fmt.Errorf("group %s: %w", group.Name, err)
+			}
+			if err := tx.Model(&models.Group{}).Where("id = ?", group.ID).Update("proxy_keys", "").Error; err != nil {
+				return err
+			}
+			converted += added
+			logrus.Infof("Converted %d proxy keys of group %s", added, group.Name)
+		}
+		return nil
+	})
+	return converted, err
+}
+
+// loadIndex builds the hash index from the proxy_keys table and any legacy plaintext keys.
+func (s *ProxyKeyService) loadIndex() (proxyKeyIndex, error) {
+	var keys []models.ProxyKey
+	if err := s.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to load proxy keys from db: %w", err)
+	}
+
+	index := make(proxyKeyIndex)
+	for _, key := range keys {
+		ipRules, err := utils.CompileIPRules(key.AllowedIPs, key.DeniedIPs)
+		if err != nil {
+			logrus.WithError(err).Errorf("Proxy key %d has invalid IP rules and is disabled", key.ID)
+			continue
+		}
+		index.add(key.GroupID, key.KeyHash, &proxyKeyEntry{
+			id:        key.ID,
+			preview:   key.KeyPreview,
+			expiresAt: key.ExpiresAt,
+			ipRules:   ipRules,
+			limits:    proxyKeyRateLimits(&key),
+		})
+	}
+
+	legacyCount := 0
+	for _, key := range utils.SplitAndTrim(s.settingsManager.GetSettings().ProxyKeys, ",") {
+		hash, err := s.hash(key)
+		if err != nil {
+			return nil, err
+		}
+		index.add(0, hash, &proxyKeyEntry{preview: proxyKeyPreview(key)})
+		legacyCount++
+	}
+
+	var groups []models.Group
+	if err := s.db.Select("id, proxy_keys").Where("proxy_keys IS NOT NULL AND proxy_keys != ''").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to load legacy group proxy keys: %w", err)
+	}
+	for _, group := range groups {
+		for _, key := range utils.SplitAndTrim(group.ProxyKeys, ",") {
+			hash, err := s.hash(key)
+			if err != nil {
+				return nil, err
+			}
+			index.add(group.ID, hash, &proxyKeyEntry{preview: proxyKeyPreview(key)})
+			legacyCount++
+		}
+	}
+
+	if legacyCount > 0 {
+		logrus.Warnf("Found %d proxy keys stored in plain text. Run 'gpt-load migrate-proxy-keys' to store them as hashes.", legacyCount)
+	}
+
+	return index, nil
+}
+
+// insertKey hashes and stores a single key with the other fields of proxyKey.
+func (s *ProxyKeyService) insertKey(ctx context.Context, tx *gorm.DB, proxyKey models.ProxyKey, key string) (*ProxyKeyCreated, error) {
+	hash, err := s.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.ProxyKey{}).Where("group_id = ? AND key_hash = ?", proxyKey.GroupID, hash).Count(&count).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+	if count > 0 {
+		return nil, NewI18nError(app_errors.ErrDuplicateResource, "proxy_key.duplicate", nil)
+	}
+
+	proxyKey.KeyHash = hash
+	proxyKey.KeyPreview = proxyKeyPreview(key)
+	if err := tx.Create(&proxyKey).Error; err != nil {
+		return nil, app_errors.ParseDBError(err)
+	}
+
+	return &ProxyKeyCreated{ProxyKey: proxyKey, Key: key}, nil
+}
+
+// addKeys stores the given keys within tx, ignoring keys already present for the group.
+func (s *ProxyKeyService) addKeys(ctx context.Context, tx *gorm.DB, groupID uint, keys []string) (int, error) {
+	added := 0
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := validateProxyKey(key); err != nil {
+			return added, err
+		}
+
+		hash, err := s.hash(key)
+		if err != nil {
+			return added, err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProxyKey{
+			GroupID:    groupID,
+			KeyHash:    hash,
+			KeyPreview: proxyKeyPreview(key),
+		})
+		if result.Error != nil {
+			return added, app_errors.ParseDBError(result.Error)
+		}
+		added += int(result.RowsAffected)
+	}
+	return added, nil
+}
+
+func (s *ProxyKeyService) ensureGroupExists(ctx context.Context, groupID uint) error {
+	if groupID == 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Group{}).Where("id = ?", groupID).Count(&count).Error; err != nil {
+		return app_errors.ParseDBError(err)
+	}
+	if count == 0 {
+		return NewI18nError(app_errors.ErrResourceNotFound, "group.not_found", nil)
+	}
+	return nil
+}
+
+// hash returns the salted HMAC-SHA256 of a key.
+func (s *ProxyKeyService) hash(key string) (string, error) {
+	salt, err := s.getSalt()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// getSalt loads the install-wide salt, creating it on first use. Concurrent nodes agree on
+// the first value written because later inserts are ignored.
+func (s *ProxyKeyService) getSalt() ([]byte, error) {
+	s.saltOnce.Do(func() {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			s.saltErr = fmt.Errorf("failed to generate proxy key salt: %w", err)
+			return
+		}
+		candidate := models.SystemSetting{
+			SettingKey:   proxyKeySaltSetting,
+			SettingValue: hex.EncodeToString(buf),
+			Description:  "Salt for hashing proxy keys. Changing it invalidates all proxy keys.",
+		}
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&candidate).Error; err != nil {
+			s.saltErr = fmt.Errorf("failed to store proxy key salt: %w", err)
+			return
+		}
+
+		var setting models.SystemSetting
+		if err := s.db.Where("setting_key = ?", proxyKeySaltSetting).First(&setting).Error; err != nil {
+			s.saltErr = fmt.Errorf("failed to load proxy key salt: %w", err)
+			return
+		}
+		salt, err := hex.DecodeString(setting.SettingValue)
+		if err != nil || len(salt) == 0 {
+			s.saltErr = errors.New("invalid proxy key salt in system settings")
+			return
+		}
+		s.salt = salt
+	})
+	return s.salt, s.saltErr
+}
+
+func (s *ProxyKeyService) invalidate(ctx context.Context) {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Invalidate(); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to invalidate proxy key cache")
+	}
+}
+
+func (idx proxyKeyIndex) add(groupID uint, hash string, entry *proxyKeyEntry) {
+	if idx[groupID] == nil {
+		idx[groupID] = make(map[string]*proxyKeyEntry)
+	}
+	idx[groupID][hash] = entry
+}
+
+// lookup returns the entry of an unexpired key, or nil.
+func (idx proxyKeyIndex) lookup(groupID uint, hash string, now time.Time) *proxyKeyEntry {
+	entry, ok := idx[groupID][hash]
+	if !ok || (entry.expiresAt != nil && !now.Before(*entry.expiresAt)) {
+		return nil
+	}
+	return entry
+}
+
+// validateProxyKey rejects values that cannot be used as a proxy key.
+func validateProxyKey(key string) error {
+	if len(key) > maxProxyKeyLength || strings.ContainsAny(key, ", \t\r\n") {
+		return NewI18nError(app_errors.ErrValidation, "validation.proxy_key_invalid", map[string]any{"max": maxProxyKeyLength})
+	}
+	return nil
+}
+
+// validateProxyKeyIPRules rejects IP rules of a proxy key that cannot be parsed.
+func validateProxyKeyIPRules(allowedIPs, deniedIPs string) error {
+	if _, err := utils.CompileIPRules(allowedIPs, deniedIPs); err != nil {
+		return NewI18nError(app_errors.ErrValidation, "validation.ip_list_invalid", map[string]any{"error": err.Error()})
+	}
+	return nil
+}
+
+// validateProxyKeyRateLimits rejects negative limits. Zero disables a limit.
+func validateProxyKeyRateLimits(limits RateLimits) error {
+	if limits.RPM < 0 || limits.TPM < 0 || limits.Concurrency < 0 {
+		return NewI18nError(app_errors.ErrValidation, "validation.rate_limit_invalid", nil)
+	}
+	return nil
+}
+
+// proxyKeyPreview returns the masked form of a key shown in lists, hiding short keys entirely.
+func proxyKeyPreview(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return utils.MaskAPIKey(key)
+}
+
+// generateProxyKey creates a random proxy key.
+func generateProxyKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate proxy key: %w", err)
+	}
+	return generatedProxyKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// proxyKeyAuditSnapshot returns the auditable fields of a proxy key.
+func proxyKeyAuditSnapshot(key *models.ProxyKey) map[string]any {
+	return map[string]any{
+		"group_id":               key.GroupID,
+		"name":                   key.Name,
+		"key_preview":            key.KeyPreview,
+		"allowed_ips":            key.AllowedIPs,
+		"denied_ips":             key.DeniedIPs,
+		"expires_at":             key.ExpiresAt,
+		"rate_limit_rpm":         key.RateLimitRPM,
+		"rate_limit_tpm":         key.RateLimitTPM,
+		"rate_limit_concurrency": key.RateLimitConcurrency,
+	}
+}
+
+// proxyKeyRateLimits returns the rate limits of a proxy key.
+func proxyKeyRateLimits(key *models.ProxyKey) RateLimits {
+	return RateLimits{
+		RPM:         key.RateLimitRPM,
+		TPM:         key.RateLimitTPM,
+		Concurrency: key.RateLimitConcurrency,
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// rateLimitWindow is the length of the sliding window used for RPM and TPM limits.
+	rateLimitWindow = time.Minute
+	// rateLimitSlotTTL bounds how long a concurrency slot leaked by a crashed node stays counted.
+	// Slots of running requests are refreshed every rateLimitSlotRefresh, however long they run.
+	rateLimitSlotTTL     = 2 * time.Minute
+	rateLimitSlotRefresh = rateLimitSlotTTL / 4
+)
+
+// RateLimits are the limits applied to one client. A zero value disables the limit.
+type RateLimits struct {
+	RPM         int `json:"rate_limit_rpm"`
+	TPM         int `json:"rate_limit_tpm"`
+	Concurrency int `json:"rate_limit_concurrency"`
+}
+
+// Empty reports whether no limit is set.
+func (l RateLimits) Empty() bool {
+	return l.RPM <= 0 && l.TPM <= 0 && l.Concurrency <= 0
+}
+
+// RateLimitStatus describes the most restrictive request and token limits of a request,
+// as reported in the X-RateLimit-* response headers. Zero limits are not reported.
+type RateLimitStatus struct {
+	RequestLimit     int
+	RequestRemaining int
+	RequestReset     int // Seconds until the current window ends
+	TokenLimit       int
+	TokenRemaining   int
+	TokenReset       int
+}
+
+// RateLimitError is returned when a request exceeds one of its limits.
+type RateLimitError struct {
+	Subject    string
+	Limit      string // "rpm", "tpm" or "concurrency"
+	Value      int
+	RetryAfter int // Seconds
+	Status     RateLimitStatus
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded for %s", e.Limit, e.Value, e.Subject)
+}
+
+// APIError converts the error to the response returned to the client.
+func (e *RateLimitError) APIError() *app_errors.APIError {
+	return app_errors.NewAPIError(app_errors.ErrRateLimited, fmt.Sprintf(
+		"Rate limit exceeded: %s limit of %d for %s, retry after %d seconds",
+		e.Limit, e.Value, e.Subject, e.RetryAfter,
+	))
+}
+
+// rateLimitSubject is a group or proxy key whose limits apply to a request.
+type rateLimitSubject struct {
+	key    string // Store key prefix
+	name   string // Display name for errors and logs
+	limits RateLimits
+}
+
+// RateLimitLease is held by an admitted request. It owns the request's concurrency slots,
+// keeping them alive until it is released, and records its token usage against the TPM limits.
+type RateLimitLease struct {
+	service    *RateLimitService
+	id         string
+	subjects   []rateLimitSubject
+	concurrent []string // Slot set keys holding a slot of this lease
+	status     RateLimitStatus
+
+	mu       sync.Mutex
+	refresh  *time.Timer
+	released bool
+}
+
+// Status returns the limit status computed when the request was admitted.
+func (l *RateLimitLease) Status() RateLimitStatus {
+	if l == nil {
+		return RateLimitStatus{}
+	}
+	return l.status
+}
+
+// RecordTokens adds the tokens used by the request to the TPM counters.
+func (l *RateLimitLease) RecordTokens(tokens int64) {
+	if l == nil || tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, subject := range l.subjects {
+		if subject.limits.TPM <= 0 {
+			continue
+		}
+		if _, err := l.service.store.IncrBy(rateLimitWindowKey(subject.key, "tpm", now, 0), tokens, 2*rateLimitWindow); err != nil {
+			logrus.WithError(err).WithField("subject", subject.name).Warn("Failed to record rate limit token usage")
+		}
+	}
+}
+
+// Release frees the concurrency slots of the request. It is safe to call more than once.
+func (l *RateLimitLease) Release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return
+	}
+	l.released = true
+	if l.refresh != nil {
+		l.refresh.Stop()
+	}
+	for _, key := range l.concurrent {
+		if err := l.service.store.ReleaseSlot(key, l.id); err != nil {
+			logrus.WithError(err).Warn("Failed to release concurrency slot")
+		}
+	}
+}
+
+// refreshSlots extends the expiry of the lease's concurrency slots while the request runs.
+func (l *RateLimitLease) refreshSlots() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return
+	}
+	for _, key := range l.concurrent {
+		if held, err := l.service.store.RefreshSlot(key, l.id, rateLimitSlotTTL); err != nil {
+			logrus.WithError(err).Warn("Failed to refresh concurrency slot")
+		} else if !held {
+			logrus.WithField("slot", key).Warn("Concurrency slot expired while its request was running")
+		}
+	}
+	l.refresh.Reset(rateLimitSlotRefresh)
+}
+
+// RateLimitService enforces per-group and per-proxy-key request, token and concurrency limits.
+// Counters live in the shared store, so limits hold across all nodes of a cluster.
+// RPM and TPM use a sliding window approximated from the current and previous one-minute counters.
+type RateLimitService struct {
+	store store.Store
+}
+
+// NewRateLimitService creates a new RateLimitService.
+func NewRateLimitService(store store.Store) *RateLimitService {
+	return &RateLimitService{store: store}
+}
+
+// Acquire admits a request to the group, made with the given proxy key, or returns the limit it exceeds.
+// The returned lease must be released when the request ends. Store failures are logged and the
+// request is allowed, so a store outage never blocks traffic.
+func (s *RateLimitService) Acquire(group *models.Group, key *ProxyKeyMatch) (*RateLimitLease, *RateLimitError) {
+	lease := &RateLimitLease{service: s, id: uuid.NewString()}
+	if group != nil {
+		cfg := group.EffectiveConfig
+		lease.addSubject(fmt.Sprintf("group:%d", group.ID), fmt.Sprintf("group '%s'", group.Name), RateLimits{
+			RPM:         cfg.RateLimitRPM,
+			TPM:         cfg.RateLimitTPM,
+			Concurrency: cfg.RateLimitConcurrency,
+		})
+	}
+	// Legacy plaintext keys have no ID and no limits of their own
+	if key != nil && key.ID != 0 {
+		lease.addSubject(fmt.Sprintf("proxy_key:%d", key.ID), fmt.Sprintf("proxy key '%s'", key.Preview), key.Limits)
+	}
+
+	now := time.Now()
+	var rpmKeys []string
+	rollback := func() {
+		for _, k := range rpmKeys {
+			if _, err := s.store.IncrBy(k, -1, 2*rateLimitWindow); err != nil {
+				logrus.WithError(err).Warn("Failed to roll back rate limit counter")
+			}
+		}
+		lease.Release()
+	}
+
+	for _, subject := range lease.subjects {
+		limits := subject.limits
+
+		if limits.TPM > 0 {
+			used, ok := s.windowUsage(subject, "tpm", now)
+			if ok {
+				lease.status.observeTokens(limits.TPM, used, now)
+				if used >= float64(limits.TPM) {
+					rollback()
+					return nil, s.reject(subject, "tpm", limits.TPM, s.retryAfter(subject, "tpm", limits.TPM, now), lease.status)
+				}
+			}
+		}
+
+		if limits.RPM > 0 {
+			currentKey := rateLimitWindowKey(subject.key, "rpm", now, 0)
+			if _, err := s.store.IncrBy(currentKey, 1, 2*rateLimitWindow); err != nil {
+				logrus.WithError(err).WithField("subject", subject.name).Warn("Failed to update rate limit counter, allowing request")
+			} else {
+				rpmKeys = append(rpmKeys, currentKey)
+				used, ok := s.windowUsage(subject, "rpm", now)
+				if ok {
+					lease.status.observeRequests(limits.RPM, used, now)
+					if used > float64(limits.RPM) {
+						rollback()
+						return nil, s.reject(subject, "rpm", limits.RPM, s.retryAfter(subject, "rpm", limits.RPM, now), lease.status)
+					}
+				}
+			}
+		}
+
+		if limits.Concurrency > 0 {
+			slotsKey := "ratelimit:" + subject.key + ":slots"
+			acquired, err := s.store.AcquireSlot(slotsKey, lease.id, int64(limits.Concurrency), rateLimitSlotTTL)
+			if err != nil {
+				logrus.WithError(err).WithField("subject", subject.name).Warn("Failed to acquire concurrency slot, allowing request")
+				continue
+			}
+			if !acquired {
+				rollback()
+				return nil, s.reject(subject, "concurrency", limits.Concurrency, 1, lease.status)
+			}
+			lease.concurrent = append(lease.concurrent, slotsKey)
+		}
+	}
+
+	if len(lease.concurrent) > 0 {
+		lease.mu.Lock()
+		lease.refresh = time.AfterFunc(rateLimitSlotRefresh, lease.refreshSlots)
+		lease.mu.Unlock()
+	}
+	return lease, nil
+}
+
+// reject logs a rejected request and builds its error.
+func (s *RateLimitService) reject(subject rateLimitSubject, limit string, value, retryAfter int, status RateLimitStatus) *RateLimitError {
+	logrus.WithFields(logrus.Fields{
+		"subject":     subject.name,
+		"limit":       limit,
+		"value":       value,
+		"retry_after": retryAfter,
+	}).Warn("Proxy request rejected by rate limit")
+
+	return &RateLimitError{
+		Subject:    subject.name,
+		Limit:      limit,
+		Value:      value,
+		RetryAfter: retryAfter,
+		Status:     status,
+	}
+}
+
+// windowUsage returns the sliding window estimate of a counter at the given time.
+// The previous window is weighted by the part of it that still overlaps the sliding window.
+func (s *RateLimitService) windowUsage(subject rateLimitSubject, metric string, now time.Time) (float64, bool) {
+	current, err := s.counter(rateLimitWindowKey(subject.key, metric, now, 0))
+	if err != nil {
+		logrus.WithError(err).WithField("subject", subject.name).Warn("Failed to read rate limit counter, allowing request")
+		return 0, false
+	}
+	previous, err := s.counter(rateLimitWindowKey(subject.key, metric, now, -1))
+	if err != nil {
+		logrus.WithError(err).WithField("subject", subject.name).Warn("Failed to read rate limit counter, allowing request")
+		return 0, false
+	}
+	return float64(previous)*(1-windowElapsed(now)) + float64(current), true
+}
+
+// counter reads an integer counter, treating a missing key as zero.
+func (s *RateLimitService) counter(key string) (int64, error) {
+	raw, err := s.store.Get(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(raw), 10, 64)
+}
+
+func (l *RateLimitLease) addSubject(key, name string, limits RateLimits) {
+	if limits.Empty() {
+		return
+	}
+	l.subjects = append(l.subjects, rateLimitSubject{key: key, name: name, limits: limits})
+}
+
+// observeRequests keeps the request limit with the fewest remaining requests.
+func (st *RateLimitStatus) observeRequests(limit int, used float64, now time.Time) {
+	remaining := max(limit-int(math.Ceil(used)), 0)
+	if st.RequestLimit == 0 || remaining < st.RequestRemaining {
+		st.RequestLimit = limit
+		st.RequestRemaining = remaining
+		st.RequestReset = windowResetSeconds(now)
+	}
+}
+
+// observeTokens keeps the token limit with the fewest remaining tokens.
+func (st *RateLimitStatus) observeTokens(limit int, used float64, now time.Time) {
+	remaining := max(limit-int(math.Ceil(used)), 0)
+	if st.TokenLimit == 0 || remaining < st.TokenRemaining {
+		st.TokenLimit = limit
+		st.TokenRemaining = remaining
+		st.TokenReset = windowResetSeconds(now)
+	}
+}
+
+// retryAfter returns the seconds until the sliding window has room for one more unit.
+// The current window count excludes the rejected request, which has been rolled back.
+func (s *RateLimitService) retryAfter(subject rateLimitSubject, metric string, limit int, now time.Time) int {
+	current, err := s.counter(rateLimitWindowKey(subject.key, metric, now, 0))
+	if err != nil {
+		return 1
+	}
+	previous, err := s.counter(rateLimitWindowKey(subject.key, metric, now, -1))
+	if err != nil {
+		return 1
+	}
+
+	elapsed := windowElapsed(now)
+	windowSeconds := rateLimitWindow.Seconds()
+	var wait float64
+	if current+1 > int64(limit) {
+		// The current window alone is full: wait for it to become the previous window and decay enough
+		needed := 1 - float64(int64(limit)-1)/float64(current)
+		wait = (1-elapsed)*windowSeconds + max(needed, 0)*windowSeconds
+	} else if previous > 0 {
+		needed := 1 - float64(int64(limit)-current-1)/float64(previous)
+		wait = (needed - elapsed) * windowSeconds
+	}
+	return max(int(math.Ceil(wait)), 1)
+}
+
+// rateLimitWindowKey returns the store key of a counter for the window containing now,
+// shifted by offset windows.
+func rateLimitWindowKey(subjectKey, metric string, now time.Time, offset int64) string {
+	window := now.Unix()/int64(rateLimitWindow.Seconds()) + offset
+	return fmt.Sprintf("ratelimit:%s:%s:%d", subjectKey, metric, window)
+}
+
+// windowElapsed returns the elapsed fraction of the current window.
+func windowElapsed(now time.Time) float64 {
+	return float64(now.UnixNano()%int64(rateLimitWindow)) / float64(rateLimitWindow)
+}
+
+// windowResetSeconds returns the seconds until the current window ends.
+func windowResetSeconds(now time.Time) int {
+	return int(math.Ceil((1 - windowElapsed(now)) * rateLimitWindow.Seconds()))
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+)
+
+// windowTime returns a time at the given fraction of a rate limit window.
+func windowTime(elapsed float64) time.Time {
+	start := time.Unix(1_700_000_040, 0) // A window boundary
+	return start.Add(time.Duration(elapsed * float64(rateLimitWindow)))
+}
+
+func setWindowCounters(t *testing.T, s store.Store, subject rateLimitSubject, metric string, now time.Time, previous, current int64) {
+	t.Helper()
+	for offset, value := range map[int64]int64{-1: previous, 0: current} {
+		if value == 0 {
+			continue
+		}
+		if err := s.Set(rateLimitWindowKey(subject.key, metric, now, offset), []byte(strconv.FormatInt(value, 10)), 2*rateLimitWindow); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRateLimitWindowUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous int64
+		current  int64
+		elapsed  float64
+		want     float64
+	}{
+		{name: "empty", want: 0},
+		{name: "current window only", current: 7, elapsed: 0.5, want: 7},
+		{name: "previous window fully weighted at the boundary", previous: 10, current: 0, elapsed: 0, want: 10},
+		{name: "previous window half weighted", previous: 10, current: 3, elapsed: 0.5, want: 8},
+		{name: "previous window nearly expired", previous: 10, current: 3, elapsed: 0.9, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRateLimitService(store.NewMemoryStore())
+			subject := rateLimitSubject{key: "group:1", name: "group 'test'"}
+			now := windowTime(tt.elapsed)
+			setWindowCounters(t, s.store, subject, "rpm", now, tt.previous, tt.current)
+
+			got, ok := s.windowUsage(subject, "rpm", now)
+			if !ok {
+				t.Fatal("windowUsage failed")
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("windowUsage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		previous int64
+		current  int64
+		elapsed  float64
+		want     int
+	}{
+		{name: "nothing used", limit: 10, want: 1},
+		// The current window is full: wait for the rest of it, then until the count decays to limit-1
+		{name: "current window full", limit: 10, current: 10, elapsed: 0.5, want: 36},
+		{name: "current window full at its start", limit: 10, current: 10, elapsed: 0, want: 66},
+		// Room in the current window: wait until the previous window's weight drops enough
+		{name: "previous window decaying", limit: 10, previous: 10, current: 5, elapsed: 0.25, want: 21},
+		{name: "previous window already decayed", limit: 10, previous: 20, current: 3, elapsed: 0.9, want: 1},
+		{name: "limit of one", limit: 1, current: 1, elapsed: 0.75, want: 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRateLimitService(store.NewMemoryStore())
+			subject := rateLimitSubject{key: "group:1", name: "group 'test'"}
+			now := windowTime(tt.elapsed)
+			setWindowCounters(t, s.store, subject, "rpm", now, tt.previous, tt.current)
+
+			if got := s.retryAfter(subject, "rpm", tt.limit, now); got != tt.want {
+				t.Errorf("retryAfter = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func rateLimitedGroup(rpm, tpm, concurrency int) *models.Group {
+	return &models.Group{
+		ID:   1,
+		Name: "test",
+		EffectiveConfig: types.SystemSettings{
+			RateLimitRPM:         rpm,
+			RateLimitTPM:         tpm,
+			RateLimitConcurrency: concurrency,
+		},
+	}
+}
+
+func TestRateLimitAcquire(t *testing.T) {
+	tests := []struct {
+		name      string
+		group     *models.Group
+		key       *ProxyKeyMatch
+		requests  int
+		tokens    int64 // Recorded by each admitted request
+		release   bool  // Release each lease right away
+		wantOK    int
+		wantLimit string
+	}{
+		{name: "no limits", group: rateLimitedGroup(0, 0, 0), requests: 5, wantOK: 5},
+		{name: "group RPM", group: rateLimitedGroup(3, 0, 0), requests: 5, release: true, wantOK: 3, wantLimit: "rpm"},
+		{name: "group TPM", group: rateLimitedGroup(0, 100, 0), requests: 5, tokens: 40, release: true, wantOK: 3, wantLimit: "tpm"},
+		{name: "group concurrency", group: rateLimitedGroup(0, 0, 2), requests: 4, wantOK: 2, wantLimit: "concurrency"},
+		{name: "released slots are reused", group: rateLimitedGroup(0, 0, 2), requests: 4, release: true, wantOK: 4},
+		{
+			name:      "proxy key limit is stricter",
+			group:     rateLimitedGroup(10, 0, 0),
+			key:       &ProxyKeyMatch{ID: 7, Preview: "sk-p...1234", Limits: RateLimits{RPM: 2}},
+			requests:  4,
+			release:   true,
+			wantOK:    2,
+			wantLimit: "rpm",
+		},
+		{
+			name:     "legacy key has no limits",
+			group:    rateLimitedGroup(0, 0, 0),
+			key:      &ProxyKeyMatch{Limits: RateLimits{RPM: 1}},
+			requests: 3,
+			wantOK:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRateLimitService(store.NewMemoryStore())
+			var leases []*RateLimitLease
+			defer func() {
+				for _, lease := range leases {
+					lease.Release()
+				}
+			}()
+
+			ok := 0
+			var lastErr *RateLimitError
+			for i := 0; i < tt.requests; i++ {
+				lease, rlErr := s.Acquire(tt.group, tt.key)
+				if rlErr != nil {
+					lastErr = rlErr
+					continue
+				}
+				ok++
+				lease.RecordTokens(tt.tokens)
+				if tt.release {
+					lease.Release()
+				} else {
+					leases = append(leases, lease)
+				}
+			}
+
+			if ok != tt.wantOK {
+				t.Errorf("admitted %d of %d requests, want %d", ok, tt.requests, tt.wantOK)
+			}
+			if tt.wantLimit == "" {
+				if lastErr != nil {
+					t.Errorf("unexpected rejection: %v", lastErr)
+				}
+				return
+			}
+			if lastErr == nil || lastErr.Limit != tt.wantLimit {
+				t.Fatalf("rejection = %v, want %s limit", lastErr, tt.wantLimit)
+			}
+			if lastErr.RetryAfter < 1 {
+				t.Errorf("RetryAfter = %d, want at least 1", lastErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimitRejectionRollsBack(t *testing.T) {
+	s := NewRateLimitService(store.NewMemoryStore())
+	group := rateLimitedGroup(2, 0, 1)
+
+	first, rlErr := s.Acquire(group, nil)
+	if rlErr != nil {
+		t.Fatal(rlErr)
+	}
+	// Rejected by the concurrency limit after its RPM counter was incremented
+	if _, rlErr := s.Acquire(group, nil); rlErr == nil || rlErr.Limit != "concurrency" {
+		t.Fatalf("second request = %v, want concurrency rejection", rlErr)
+	}
+	first.Release()
+	first.Release()
+
+	// The rejected request must not have consumed RPM, so one more request fits
+	lease, rlErr := s.Acquire(group, nil)
+	if rlErr != nil {
+		t.Fatalf("third request rejected: %v", rlErr)
+	}
+	if status := lease.Status(); status.RequestLimit != 2 || status.RequestRemaining != 0 {
+		t.Errorf("Status = %+v, want limit 2 with 0 remaining", status)
+	}
+	lease.Release()
+}
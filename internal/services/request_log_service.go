@@ -267,10 +267,15 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 		}
 
 		// 更新统计表
+		type hourlyCounts struct {
+			Success, Failure          int64
+			InputTokens, OutputTokens int64
+			Cost                      float64
+		}
 		hourlyStats := make(map[struct {
 			Time    time.Time
 			GroupID uint
-		}]struct{ Success, Failure int64 })
+		}]hourlyCounts)
 		for _, log := range logs {
 			if log.RequestType == models.RequestTypeRetry {
 				continue
@@ -287,6 +292,9 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 			} else {
 				counts.Failure++
 			}
+			counts.InputTokens += log.InputTokens
+			counts.OutputTokens += log.OutputTokens
+			counts.Cost += log.Cost
 			hourlyStats[key] = counts
 
 			if log.ParentGroupID > 0 {
@@ -301,6 +309,9 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 				} else {
 					parentCounts.Failure++
 				}
+				parentCounts.InputTokens += log.InputTokens
+				parentCounts.OutputTokens += log.OutputTokens
+				parentCounts.Cost += log.Cost
 				hourlyStats[parentKey] = parentCounts
 			}
 		}
@@ -312,6 +323,9 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 					DoUpdates: clause.Assignments(map[string]any{
 						"success_count": gorm.Expr("group_hourly_stats.success_count + ?", counts.Success),
 						"failure_count": gorm.Expr("group_hourly_stats.failure_count + ?", counts.Failure),
+						"input_tokens":  gorm.Expr("group_hourly_stats.input_tokens + ?", counts.InputTokens),
+						"output_tokens": gorm.Expr("group_hourly_stats.output_tokens + ?", counts.OutputTokens),
+						"cost":          gorm.Expr("group_hourly_stats.cost + ?", counts.Cost),
 						"updated_at":    time.Now(),
 					}),
 				}).Create(&models.GroupHourlyStat{
@@ -319,6 +333,9 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 					GroupID:      key.GroupID,
 					SuccessCount: counts.Success,
 					FailureCount: counts.Failure,
+					InputTokens:  counts.InputTokens,
+					OutputTokens: counts.OutputTokens,
+					Cost:         counts.Cost,
 				}).Error
 
 				if err != nil {
@@ -327,6 +344,95 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 			}
 		}
 
-		return nil
+		return s.upsertKeyHourlyStats(tx, logs)
 	})
 }
+
+// upsertKeyHourlyStats aggregates a batch of logs into per-key hourly statistics,
+// so keys are only written once per flush instead of once per request.
+func (s *RequestLogService) upsertKeyHourlyStats(tx *gorm.DB, logs []*models.RequestLog) error {
+	type statKey struct {
+		Time  time.Time
+		KeyID uint
+	}
+	type keyCounts struct {
+		GroupID                   uint
+		Success, Failure          int64
+		InputTokens, OutputTokens int64
+		Cost                      float64
+		LastAt                    time.Time
+		LastStatusCode            int
+		LastError                 string
+		LastErrorAt               *time.Time
+	}
+
+	keyStats := make(map[statKey]*keyCounts)
+	for _, log := range logs {
+		if log.KeyID == 0 {
+			continue
+		}
+		key := statKey{Time: log.Timestamp.Truncate(time.Hour), KeyID: log.KeyID}
+		counts, ok := keyStats[key]
+		if !ok {
+			counts = &keyCounts{GroupID: log.GroupID}
+			keyStats[key] = counts
+		}
+
+		if log.IsSuccess {
+			counts.Success++
+		} else {
+			counts.Failure++
+		}
+		counts.InputTokens += log.InputTokens
+		counts.OutputTokens += log.OutputTokens
+		counts.Cost += log.Cost
+
+		if !log.Timestamp.Before(counts.LastAt) {
+			counts.LastAt = log.Timestamp
+			counts.LastStatusCode = log.StatusCode
+		}
+		if !log.IsSuccess && log.ErrorMessage != "" && (counts.LastErrorAt == nil || !log.Timestamp.Before(*counts.LastErrorAt)) {
+			timestamp := log.Timestamp
+			counts.LastError = log.ErrorMessage
+			counts.LastErrorAt = &timestamp
+		}
+	}
+
+	for key, counts := range keyStats {
+		updates := map[string]any{
+			"success_count":    gorm.Expr("key_hourly_stats.success_count + ?", counts.Success),
+			"failure_count":    gorm.Expr("key_hourly_stats.failure_count + ?", counts.Failure),
+			"input_tokens":     gorm.Expr("key_hourly_stats.input_tokens + ?", counts.InputTokens),
+			"output_tokens":    gorm.Expr("key_hourly_stats.output_tokens + ?", counts.OutputTokens),
+			"cost":             gorm.Expr("key_hourly_stats.cost + ?", counts.Cost),
+			"last_status_code": counts.LastStatusCode,
+			"updated_at":       time.Now(),
+		}
+		if counts.LastErrorAt != nil {
+			updates["last_error"] = counts.LastError
+			updates["last_error_at"] = counts.LastErrorAt
+		}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "time"}, {Name: "key_id"}},
+			DoUpdates: clause.Assignments(updates),
+		}).Create(&models.KeyHourlyStat{
+			Time:           key.Time,
+			KeyID:          key.KeyID,
+			GroupID:        counts.GroupID,
+			SuccessCount:   counts.Success,
+			FailureCount:   counts.Failure,
+			InputTokens:    counts.InputTokens,
+			OutputTokens:   counts.OutputTokens,
+			Cost:           counts.Cost,
+			LastStatusCode: counts.LastStatusCode,
+			LastError:      counts.LastError,
+			LastErrorAt:    counts.LastErrorAt,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to upsert key hourly stat: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrRequestQueueFull is returned when the queue of a group has no room for another request.
+	ErrRequestQueueFull = errors.New("request queue is full")
+	// ErrRequestQueueTimeout is returned when a queued request waited longer than the group allows.
+	ErrRequestQueueTimeout = errors.New("timed out waiting in request queue")
+)
+
+const (
+	// requestQueuePollInterval is how often the waiter whose turn it is retries without a wake-up,
+	// which covers slots freed on other nodes and keys restored in the background.
+	requestQueuePollInterval = 250 * time.Millisecond
+	// requestQueueDepthTTL bounds how long entries leaked by a crashed node stay counted.
+	requestQueueDepthTTL = 10 * time.Minute
+)
+
+// Queue wait reasons
+const (
+	QueueReasonConcurrency = "concurrency"
+	QueueReasonNoKeys      = "no_keys"
+)
+
+// RequestQueueStatus describes the queue of a group. Counters are shared by all nodes.
+type RequestQueueStatus struct {
+	Enabled        bool  `json:"enabled"`
+	MaxSize        int   `json:"max_size"`
+	MaxWaitSeconds int   `json:"max_wait_seconds"`
+	Depth          int64 `json:"depth"`
+	Queued         int64 `json:"queued"`
+	Admitted       int64 `json:"admitted"`
+	TimedOut       int64 `json:"timed_out"`
+	Rejected       int64 `json:"rejected"`
+	Canceled       int64 `json:"canceled"`
+	AvgWaitMs      int64 `json:"avg_wait_ms"`
+	MaxWaitMs      int64 `json:"max_wait_ms"`
+}
+
+// queueWaiter is a request waiting in a group queue.
+type queueWaiter struct {
+	client string
+	wake   chan struct{}
+}
+
+// requestQueue holds the waiters of one group on this node. Waiters of the same client are
+// served in arrival order, and clients take turns so that one busy proxy key cannot starve others.
+type requestQueue struct {
+	mu      sync.Mutex
+	clients []string
+	waiters map[string][]*queueWaiter
+	turn    int
+}
+
+// RequestQueueService lets proxy requests wait for a free concurrency slot or an active key
+// instead of failing immediately when a group is saturated.
+type RequestQueueService struct {
+	store  store.Store
+	mu     sync.Mutex
+	queues map[uint]*requestQueue
+}
+
+// NewRequestQueueService creates a new RequestQueueService.
+func NewRequestQueueService(store store.Store) *RequestQueueService {
+	return &RequestQueueService{
+		store:  store,
+		queues: make(map[uint]*requestQueue),
+	}
+}
+
+// Enabled reports whether the group queues requests.
+func (s *RequestQueueService) Enabled(group *models.Group) bool {
+	return group != nil && group.EffectiveConfig.RequestQueueSize > 0
+}
+
+// Wait queues the request until attempt succeeds, the group's maximum wait passes or ctx ends.
+// attempt is only called when it is the request's turn, and must not block.
+func (s *RequestQueueService) Wait(ctx context.Context, group *models.Group, client, reason string, attempt func() bool) error {
+	cfg := group.EffectiveConfig
+	fields := logrus.Fields{
+		"group_name": group.Name,
+		"client":     client,
+		"reason":     reason,
+	}
+
+	// The depth counter bounds the queue across all nodes; a store failure falls back to no bound
+	depthKey := requestQueueDepthKey(group.ID)
+	depth, err := s.store.IncrBy(depthKey, 1, requestQueueDepthTTL)
+	counted := err == nil
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to update request queue depth")
+	} else if depth > int64(cfg.RequestQueueSize) {
+		s.leave(depthKey)
+		s.count(group.ID, "rejected", 1)
+		logrus.WithFields(fields).WithField("max_size", cfg.RequestQueueSize).Warn("Request queue is full, rejecting request")
+		return ErrRequestQueueFull
+	}
+	s.count(group.ID, "queued", 1)
+
+	queue := s.queue(group.ID)
+	waiter := queue.push(client)
+	start := time.Now()
+	leave := func() {
+		queue.remove(waiter)
+		if counted {
+			s.leave(depthKey)
+		}
+		// The next waiter may be able to proceed, or now holds the turn
+		queue.wakeTurn()
+	}
+
+	timer := time.NewTimer(time.Duration(cfg.RequestQueueTimeoutSeconds) * time.Second)
+	defer timer.Stop()
+	ticker := time.NewTicker(requestQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if queue.isTurn(waiter) {
+			ok := attempt()
+			queue.passTurn()
+			if ok {
+				leave()
+				wait := time.Since(start)
+				s.recordWait(group.ID, wait)
+				logrus.WithFields(fields).WithFields(logrus.Fields{
+					"wait_ms":     wait.Milliseconds(),
+					"queue_depth": depth,
+				}).Info("Queued request admitted")
+				return nil
+			}
+		}
+
+		select {
+		case <-waiter.wake:
+		case <-ticker.C:
+		case <-timer.C:
+			leave()
+			s.count(group.ID, "timed_out", 1)
+			logrus.WithFields(fields).WithField("wait_ms", time.Since(start).Milliseconds()).Warn("Queued request timed out")
+			return fmt.Errorf("%w after %d seconds", ErrRequestQueueTimeout, cfg.RequestQueueTimeoutSeconds)
+		case <-ctx.Done():
+			leave()
+			s.count(group.ID, "canceled", 1)
+			return ctx.Err()
+		}
+	}
+}
+
+// Notify wakes the next waiter of a group, after a slot was freed on this node.
+func (s *RequestQueueService) Notify(groupID uint) {
+	s.mu.Lock()
+	queue, ok := s.queues[groupID]
+	s.mu.Unlock()
+	if ok {
+		queue.wakeTurn()
+	}
+}
+
+// GetStatus returns the queue settings and counters of a group.
+func (s *RequestQueueService) GetStatus(group *models.Group) (*RequestQueueStatus, error) {
+	cfg := group.EffectiveConfig
+	status := &RequestQueueStatus{
+		Enabled:        cfg.RequestQueueSize > 0,
+		MaxSize:        cfg.RequestQueueSize,
+		MaxWaitSeconds: cfg.RequestQueueTimeoutSeconds,
+	}
+
+	raw, err := s.store.Get(requestQueueDepthKey(group.ID))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		status.Depth, _ = strconv.ParseInt(string(raw), 10, 64)
+	}
+
+	stats, err := s.store.HGetAll(requestQueueStatsKey(group.ID))
+	if err != nil {
+		return nil, err
+	}
+	parse := func(field string) int64 {
+		v, _ := strconv.ParseInt(stats[field], 10, 64)
+		return v
+	}
+	status.Queued = parse("queued")
+	status.Admitted = parse("admitted")
+	status.TimedOut = parse("timed_out")
+	status.Rejected = parse("rejected")
+	status.Canceled = parse("canceled")
+	status.MaxWaitMs = parse("max_wait_ms")
+	if status.Admitted > 0 {
+		status.AvgWaitMs = parse("wait_ms") / status.Admitted
+	}
+	return status, nil
+}
+
+// queue returns the local queue of a group, creating it on first use.
+func (s *RequestQueueService) queue(groupID uint) *requestQueue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue, ok := s.queues[groupID]
+	if !ok {
+		queue = &requestQueue{waiters: make(map[string][]*queueWaiter)}
+		s.queues[groupID] = queue
+	}
+	return queue
+}
+
+// leave decrements the depth counter, removing it when the queue is empty.
+func (s *RequestQueueService) leave(depthKey string) {
+	depth, err := s.store.IncrBy(depthKey, -1, requestQueueDepthTTL)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to update request queue depth")
+		return
+	}
+	if depth <= 0 {
+		if err := s.store.Delete(depthKey); err != nil {
+			logrus.WithError(err).Warn("Failed to remove request queue depth counter")
+		}
+	}
+}
+
+// count increments a queue statistic of a group.
+func (s *RequestQueueService) count(groupID uint, field string, incr int64) {
+	if _, err := s.store.HIncrBy(requestQueueStatsKey(groupID), field, incr); err != nil {
+		logrus.WithError(err).Warn("Failed to update request queue statistics")
+	}
+}
+
+// recordWait adds an admitted request and its wait time to the statistics of a group.
+func (s *RequestQueueService) recordWait(groupID uint, wait time.Duration) {
+	waitMs := wait.Milliseconds()
+	s.count(groupID, "admitted", 1)
+	s.count(groupID, "wait_ms", waitMs)
+
+	// The maximum is kept per node without a compare-and-set, which is close enough for monitoring
+	stats, err := s.store.HGetAll(requestQueueStatsKey(groupID))
+	if err != nil {
+		return
+	}
+	if current, _ := strconv.ParseInt(stats["max_wait_ms"], 10, 64); waitMs > current {
+		s.count(groupID, "max_wait_ms", waitMs-current)
+	}
+}
+
+// push appends a waiter for the client.
+func (q *requestQueue) push(client string) *queueWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiter := &queueWaiter{client: client, wake: make(chan struct{}, 1)}
+	if len(q.waiters[client]) == 0 {
+		q.clients = append(q.clients, client)
+	}
+	q.waiters[client] = append(q.waiters[client], waiter)
+	return waiter
+}
+
+// remove drops a waiter, and its client once it has no waiters left.
+func (q *requestQueue) remove(waiter *queueWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	list := q.waiters[waiter.client]
+	for i, w := range list {
+		if w == waiter {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) > 0 {
+		q.waiters[waiter.client] = list
+		return
+	}
+
+	delete(q.waiters, waiter.client)
+	for i, client := range q.clients {
+		if client != waiter.client {
+			continue
+		}
+		q.clients = append(q.clients[:i], q.clients[i+1:]...)
+		if i < q.turn {
+			q.turn--
+		}
+		break
+	}
+	if q.turn >= len(q.clients) {
+		q.turn = 0
+	}
+}
+
+// isTurn reports whether the waiter is the oldest waiter of the client whose turn it is.
+func (q *requestQueue) isTurn(waiter *queueWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.clients) == 0 {
+		return false
+	}
+	list := q.waiters[q.clients[q.turn]]
+	return len(list) > 0 && list[0] == waiter
+}
+
+// passTurn gives the next client the turn.
+func (q *requestQueue) passTurn() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.clients) > 0 {
+		q.turn = (q.turn + 1) % len(q.clients)
+	}
+}
+
+// wakeTurn wakes the waiter whose turn it is.
+func (q *requestQueue) wakeTurn() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.clients) == 0 {
+		return
+	}
+	list := q.waiters[q.clients[q.turn]]
+	if len(list) == 0 {
+		return
+	}
+	select {
+	case list[0].wake <- struct{}{}:
+	default:
+	}
+}
+
+func requestQueueDepthKey(groupID uint) string {
+	return fmt.Sprintf("request_queue:%d:depth", groupID)
+}
+
+func requestQueueStatsKey(groupID uint) string {
+	return fmt.Sprintf("request_queue:%d:stats", groupID)
+}
@@ -1,12 +1,38 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"gpt-load/internal/keypool"
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+// ErrNoSubGroupForModel is returned when no sub-group of an aggregate group can serve the requested model.
+var ErrNoSubGroupForModel = errors.New("no sub-group supports the requested model")
+
+const (
+	// subGroupCircuitFailureThreshold is the number of consecutive failures that opens a sub-group circuit
+	subGroupCircuitFailureThreshold = 5
+	// subGroupCircuitCooldown is how long an open circuit keeps the sub-group out of selection
+	subGroupCircuitCooldown = 30 * time.Second
+	// subGroupHealthAlpha is the smoothing factor of the latency and error rate EWMA
+	subGroupHealthAlpha = 0.2
+	// subGroupHealthRefreshInterval is how often adaptive selectors reload health stats from the store
+	subGroupHealthRefreshInterval = 5 * time.Second
+	// subGroupHealthFlushInterval is how often request outcomes collected on this node are folded into the shared health stats
+	subGroupHealthFlushInterval = 2 * time.Second
 )
 
 // SubGroupManager manages weighted round-robin selection for all aggregate groups
@@ -14,14 +40,43 @@ type SubGroupManager struct {
 	store     store.Store
 	selectors map[uint]*selector
 	mu        sync.RWMutex
+
+	// Request outcomes of adaptive aggregates are collected in memory and flushed periodically,
+	// so requests do not touch the store and updates of the same sub-group are not lost
+	samplesMu sync.Mutex
+	samples   map[uint]*subGroupSamples
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// subGroupSamples holds the request outcomes of a sub-group since the last flush
+type subGroupSamples struct {
+	requests   int
+	failures   int
+	successes  int
+	latencySum float64
 }
 
 // subGroupItem represents a sub-group with its weight and current weight for round-robin
 type subGroupItem struct {
-	name          string
-	subGroupID    uint
-	weight        int
-	currentWeight int
+	name                string
+	subGroupID          uint
+	weight              int
+	priority            int
+	effectiveWeight     int
+	currentWeight       int
+	models              []string
+	schedule            *models.ActiveSchedule
+	state               string
+	consecutiveFailures int
+}
+
+// subGroupHealth holds the rolling latency and error rate of a sub-group
+type subGroupHealth struct {
+	latencyMs float64
+	errorRate float64
 }
 
 // NewSubGroupManager creates a new sub-group manager service
@@ -29,11 +84,39 @@ func NewSubGroupManager(store store.Store) *SubGroupManager {
 	return &SubGroupManager{
 		store:     store,
 		selectors: make(map[uint]*selector),
+		samples:   make(map[uint]*subGroupSamples),
+		stopCh:    make(chan struct{}),
 	}
 }
 
-// SelectSubGroup selects an appropriate sub-group for the given aggregate group
-func (m *SubGroupManager) SelectSubGroup(group *models.Group) (string, error) {
+// Stop stops the health flusher and flushes the outcomes collected so far.
+func (m *SubGroupManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+		m.wg.Wait()
+	})
+}
+
+// runHealthFlusher periodically folds the collected outcomes into the shared health stats.
+func (m *SubGroupManager) runHealthFlusher() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(subGroupHealthFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.flushHealth()
+		case <-m.stopCh:
+			m.flushHealth()
+			return
+		}
+	}
+}
+
+// SelectSubGroup selects an appropriate sub-group for the given aggregate group and requested model.
+// An empty model disables model filtering.
+func (m *SubGroupManager) SelectSubGroup(group *models.Group, model string) (string, error) {
 	if group.GroupType != "aggregate" {
 		return "", nil
 	}
@@ -43,7 +126,11 @@ func (m *SubGroupManager) SelectSubGroup(group *models.Group) (string, error) {
 		return "", fmt.Errorf("no valid sub-groups available for aggregate group '%s'", group.Name)
 	}
 
-	selectedName := selector.selectNext()
+	if !selector.hasSubGroupForModel(model) {
+		return "", fmt.Errorf("%w: model '%s' is not served by any sub-group of aggregate group '%s'", ErrNoSubGroupForModel, model, group.Name)
+	}
+
+	selectedName := selector.selectNext(model)
 	if selectedName == "" {
 		return "", fmt.Errorf("no sub-groups with active keys for aggregate group '%s'", group.Name)
 	}
@@ -51,11 +138,102 @@ func (m *SubGroupManager) SelectSubGroup(group *models.Group) (string, error) {
 	logrus.WithFields(logrus.Fields{
 		"aggregate_group": group.Name,
 		"selected_group":  selectedName,
+		"model":           model,
 	}).Debug("Selected sub-group from aggregate")
 
 	return selectedName, nil
 }
 
+// RecordResult records the outcome and latency of an upstream request routed to a sub-group.
+// Consecutive failures open a short-lived circuit so traffic spills to other sub-groups,
+// and in adaptive mode the outcome also feeds the shared latency and error rate EWMA.
+func (m *SubGroupManager) RecordResult(group *models.Group, subGroupID uint, success bool, latency time.Duration) {
+	if group.GroupType != "aggregate" {
+		return
+	}
+
+	m.mu.RLock()
+	sel, exists := m.selectors[group.ID]
+	m.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	sel.recordResult(subGroupID, success)
+
+	if sel.adaptive {
+		m.startOnce.Do(func() {
+			m.wg.Add(1)
+			go m.runHealthFlusher()
+		})
+
+		m.samplesMu.Lock()
+		samples, ok := m.samples[subGroupID]
+		if !ok {
+			samples = &subGroupSamples{}
+			m.samples[subGroupID] = samples
+		}
+		samples.requests++
+		if success {
+			samples.successes++
+			samples.latencySum += float64(latency.Milliseconds())
+		} else {
+			samples.failures++
+		}
+		m.samplesMu.Unlock()
+	}
+}
+
+// flushHealth folds the outcomes collected since the last flush into the shared health stats.
+func (m *SubGroupManager) flushHealth() {
+	m.samplesMu.Lock()
+	pending := m.samples
+	m.samples = make(map[uint]*subGroupSamples)
+	m.samplesMu.Unlock()
+
+	for subGroupID, samples := range pending {
+		m.updateHealth(subGroupID, samples)
+	}
+}
+
+// updateHealth folds the outcomes of a sub-group into the EWMA health stats stored in the shared store
+func (m *SubGroupManager) updateHealth(subGroupID uint, samples *subGroupSamples) {
+	key := subGroupHealthKey(subGroupID)
+	health, found, err := loadSubGroupHealth(m.store, key)
+	if err != nil {
+		logrus.WithError(err).WithField("group_id", subGroupID).Debug("Failed to load sub-group health stats")
+		return
+	}
+
+	errorRate := float64(samples.failures) / float64(samples.requests)
+	// Failed requests often return early, so only successful requests update latency
+	var latencyMs float64
+	if samples.successes > 0 {
+		latencyMs = samples.latencySum / float64(samples.successes)
+	}
+
+	if !found {
+		health.errorRate = errorRate
+		health.latencyMs = latencyMs
+	} else {
+		health.errorRate = ewma(health.errorRate, errorRate, samples.requests)
+		if samples.successes > 0 {
+			if health.latencyMs == 0 {
+				health.latencyMs = latencyMs
+			} else {
+				health.latencyMs = ewma(health.latencyMs, latencyMs, samples.successes)
+			}
+		}
+	}
+
+	if err := m.store.HSet(key, map[string]any{
+		"latency_ms": strconv.FormatFloat(health.latencyMs, 'f', 2, 64),
+		"error_rate": strconv.FormatFloat(health.errorRate, 'f', 4, 64),
+	}); err != nil {
+		logrus.WithError(err).WithField("group_id", subGroupID).Debug("Failed to save sub-group health stats")
+	}
+}
+
 // RebuildSelectors rebuild all selectors based on the incoming group
 func (m *SubGroupManager) RebuildSelectors(groups map[string]*models.Group) {
 	newSelectors := make(map[uint]*selector)
@@ -113,10 +291,15 @@ func (m *SubGroupManager) createSelector(group *models.Group) *selector {
 	var items []subGroupItem
 	for _, sg := range group.SubGroups {
 		items = append(items, subGroupItem{
-			name:          sg.SubGroupName,
-			subGroupID:    sg.SubGroupID,
-			weight:        sg.Weight,
-			currentWeight: 0,
+			name:            sg.SubGroupName,
+			subGroupID:      sg.SubGroupID,
+			weight:          sg.Weight,
+			priority:        sg.Priority,
+			effectiveWeight: sg.Weight,
+			currentWeight:   0,
+			models:          sg.SupportedModels,
+			schedule:        sg.ActiveSchedule,
+			state:           sg.SubGroupState,
 		})
 	}
 
@@ -124,11 +307,25 @@ func (m *SubGroupManager) createSelector(group *models.Group) *selector {
 		return nil
 	}
 
+	// Lower priority values are served first
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].priority < items[j].priority
+	})
+
+	cfg := group.EffectiveConfig
+	maxWeight := cfg.AdaptiveMaxWeight
+	if maxWeight < cfg.AdaptiveMinWeight {
+		maxWeight = cfg.AdaptiveMinWeight
+	}
+
 	return &selector{
 		groupID:   group.ID,
 		groupName: group.Name,
 		subGroups: items,
 		store:     m.store,
+		adaptive:  cfg.EnableAdaptiveWeights,
+		minWeight: cfg.AdaptiveMinWeight,
+		maxWeight: maxWeight,
 	}
 }
 
@@ -139,31 +336,84 @@ type selector struct {
 	subGroups []subGroupItem
 	store     store.Store
 	mu        sync.Mutex
+
+	// Adaptive weight settings
+	adaptive        bool
+	minWeight       int
+	maxWeight       int
+	healthUpdatedAt time.Time
 }
 
-// selectNext uses weighted round-robin algorithm to select a sub-group with active keys
-func (s *selector) selectNext() string {
+// hasSubGroupForModel reports whether at least one sub-group can serve the model
+func (s *selector) hasSubGroupForModel(model string) bool {
+	for i := range s.subGroups {
+		if s.subGroups[i].supportsModel(model) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectNext selects a sub-group that serves the model from the highest-priority tier with available capacity.
+// Lower tiers are only used when every sub-group of the higher tiers is exhausted or circuit-broken.
+func (s *selector) selectNext(model string) string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(s.subGroups) == 0 {
-		return ""
+	if s.adaptive && time.Since(s.healthUpdatedAt) >= subGroupHealthRefreshInterval {
+		s.refreshEffectiveWeights()
 	}
 
-	if len(s.subGroups) == 1 {
-		if s.hasActiveKeys(s.subGroups[0].subGroupID) {
-			return s.subGroups[0].name
+	// subGroups are sorted by priority, so candidates are grouped into consecutive tiers
+	var tier []*subGroupItem
+	totalCandidates := 0
+	for i := range s.subGroups {
+		item := &s.subGroups[i]
+		if !item.supportsModel(model) {
+			continue
+		}
+		if len(tier) > 0 && tier[0].priority != item.priority {
+			if name := s.selectFromTier(tier); name != "" {
+				return name
+			}
+			tier = nil
+		}
+		tier = append(tier, item)
+		totalCandidates++
+	}
+
+	if len(tier) > 0 {
+		if name := s.selectFromTier(tier); name != "" {
+			return name
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"aggregate_group":  s.groupName,
+		"model":            model,
+		"total_candidates": totalCandidates,
+	}).Warn("No sub-groups with active keys available")
+
+	return ""
+}
+
+// selectFromTier uses weighted round-robin algorithm to select an available sub-group within a single priority tier
+func (s *selector) selectFromTier(candidates []*subGroupItem) string {
+	if len(candidates) == 1 {
+		if s.isAvailable(candidates[0]) {
+			return candidates[0].name
 		}
 		logrus.WithFields(logrus.Fields{
-			"group_id":   s.subGroups[0].subGroupID,
-			"group_name": s.subGroups[0].name,
-		}).Debug("Single sub-group has no active keys")
+			"group_id":   candidates[0].subGroupID,
+			"group_name": candidates[0].name,
+			"priority":   candidates[0].priority,
+		}).Debug("Single sub-group in tier is unavailable")
 		return ""
 	}
 
 	attempted := make(map[uint]bool)
-	for len(attempted) < len(s.subGroups) {
-		item := s.selectByWeight()
+	for len(attempted) < len(candidates) {
+		item := s.selectByWeight(candidates)
 		if item == nil {
 			break
 		}
@@ -173,10 +423,11 @@ func (s *selector) selectNext() string {
 		}
 		attempted[item.subGroupID] = true
 
-		if s.hasActiveKeys(item.subGroupID) {
+		if s.isAvailable(item) {
 			logrus.WithFields(logrus.Fields{
 				"aggregate_group": s.groupName,
 				"selected_group":  item.name,
+				"priority":        item.priority,
 				"attempts":        len(attempted),
 			}).Debug("Selected sub-group with active keys")
 			return item.name
@@ -185,27 +436,22 @@ func (s *selector) selectNext() string {
 		logrus.WithFields(logrus.Fields{
 			"group_id":   item.subGroupID,
 			"group_name": item.name,
+			"priority":   item.priority,
 			"attempts":   len(attempted),
-		}).Debug("Sub-group has no active keys, trying next")
+		}).Debug("Sub-group is unavailable, trying next")
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"aggregate_group":  s.groupName,
-		"total_sub_groups": len(s.subGroups),
-	}).Warn("No sub-groups with active keys available")
-
 	return ""
 }
 
-// selectByWeight implements smooth weighted round-robin algorithm
-func (s *selector) selectByWeight() *subGroupItem {
+// selectByWeight implements smooth weighted round-robin algorithm over the candidate sub-groups
+func (s *selector) selectByWeight(candidates []*subGroupItem) *subGroupItem {
 	totalWeight := 0
 	var best *subGroupItem
 
-	for i := range s.subGroups {
-		item := &s.subGroups[i]
-		totalWeight += item.weight
-		item.currentWeight += item.weight
+	for _, item := range candidates {
+		totalWeight += item.effectiveWeight
+		item.currentWeight += item.effectiveWeight
 
 		if best == nil || item.currentWeight > best.currentWeight {
 			best = item
@@ -213,17 +459,117 @@ func (s *selector) selectByWeight() *subGroupItem {
 	}
 
 	if best == nil {
-		return &s.subGroups[0]
+		return candidates[0]
 	}
 
 	best.currentWeight -= totalWeight
 	return best
 }
 
+// refreshEffectiveWeights reloads shared health stats and scales each sub-group weight down by
+// its error rate and by its latency relative to the fastest sub-group of the aggregate.
+func (s *selector) refreshEffectiveWeights() {
+	s.healthUpdatedAt = time.Now()
+
+	healthByID := make(map[uint]subGroupHealth, len(s.subGroups))
+	fastestLatency := 0.0
+	for i := range s.subGroups {
+		health, found, err := loadSubGroupHealth(s.store, subGroupHealthKey(s.subGroups[i].subGroupID))
+		if err != nil || !found {
+			continue
+		}
+		healthByID[s.subGroups[i].subGroupID] = health
+		if health.latencyMs > 0 && (fastestLatency == 0 || health.latencyMs < fastestLatency) {
+			fastestLatency = health.latencyMs
+		}
+	}
+
+	for i := range s.subGroups {
+		item := &s.subGroups[i]
+		factor := 1.0
+		if health, ok := healthByID[item.subGroupID]; ok {
+			factor = 1 - health.errorRate
+			if fastestLatency > 0 && health.latencyMs > 0 {
+				factor *= fastestLatency / health.latencyMs
+			}
+		}
+
+		// A sub-group with weight 0 gets no traffic, the minimum weight only keeps degraded ones in rotation
+		if item.weight <= 0 {
+			item.effectiveWeight = 0
+			continue
+		}
+		weight := int(math.Round(float64(item.weight) * factor))
+		item.effectiveWeight = max(s.minWeight, min(s.maxWeight, weight))
+	}
+
+	logrus.WithField("aggregate_group", s.groupName).Debug("Refreshed adaptive sub-group weights")
+}
+
+// isAvailable checks if a sub-group is enabled, inside its active windows, has capacity and its circuit is closed.
+// Draining, disabled and maintenance sub-groups receive no new requests.
+func (s *selector) isAvailable(item *subGroupItem) bool {
+	return (item.state == "" || item.state == models.GroupStateEnabled) &&
+		utils.IsScheduleActive(item.schedule, time.Now()) &&
+		!s.isCircuitOpen(item.subGroupID) && s.hasActiveKeys(item.subGroupID)
+}
+
+// recordResult updates the consecutive failure counter of a sub-group and opens its circuit when the threshold is reached
+func (s *selector) recordResult(subGroupID uint, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.subGroups {
+		item := &s.subGroups[i]
+		if item.subGroupID != subGroupID {
+			continue
+		}
+
+		if success {
+			item.consecutiveFailures = 0
+			return
+		}
+
+		item.consecutiveFailures++
+		if item.consecutiveFailures < subGroupCircuitFailureThreshold {
+			return
+		}
+		item.consecutiveFailures = 0
+
+		if err := s.store.Set(s.circuitKey(subGroupID), []byte("1"), subGroupCircuitCooldown); err != nil {
+			logrus.WithError(err).WithField("group_id", subGroupID).Error("Failed to open sub-group circuit")
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"aggregate_group": s.groupName,
+			"sub_group":       item.name,
+			"cooldown":        subGroupCircuitCooldown,
+		}).Warn("Sub-group circuit opened after consecutive failures")
+		return
+	}
+}
+
+// isCircuitOpen checks if the sub-group circuit is currently open
+func (s *selector) isCircuitOpen(subGroupID uint) bool {
+	open, err := s.store.Exists(s.circuitKey(subGroupID))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"group_id": subGroupID,
+			"error":    err,
+		}).Debug("Error checking sub-group circuit, assuming closed")
+		return false
+	}
+	return open
+}
+
+// circuitKey returns the store key of the circuit state for a sub-group within this aggregate
+func (s *selector) circuitKey(subGroupID uint) string {
+	return fmt.Sprintf("aggregate:%d:sub_group:%d:circuit_open", s.groupID, subGroupID)
+}
+
 // hasActiveKeys checks if a sub-group has available API keys
 func (s *selector) hasActiveKeys(groupID uint) bool {
-	key := fmt.Sprintf("group:%d:active_keys", groupID)
-	length, err := s.store.LLen(key)
+	lists, err := keypool.ActiveKeyLists(s.store, groupID)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"group_id": groupID,
@@ -231,5 +577,108 @@ func (s *selector) hasActiveKeys(groupID uint) bool {
 		}).Debug("Error checking active keys, assuming available")
 		return true
 	}
-	return length > 0
+	for _, key := range lists {
+		length, err := s.store.LLen(key)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"group_id": groupID,
+				"error":    err,
+			}).Debug("Error checking active keys, assuming available")
+			return true
+		}
+		if length > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsModel reports whether the sub-group can serve the model.
+// A sub-group without a model list serves every model.
+func (item *subGroupItem) supportsModel(model string) bool {
+	if model == "" || len(item.models) == 0 {
+		return true
+	}
+	for _, pattern := range item.models {
+		if matchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// ewma returns the exponentially weighted moving average after adding count samples with the given mean
+func ewma(previous, mean float64, count int) float64 {
+	weight := 1 - math.Pow(1-subGroupHealthAlpha, float64(count))
+	return weight*mean + (1-weight)*previous
+}
+
+// subGroupHealthKey returns the store key of the shared health stats of a sub-group
+func subGroupHealthKey(subGroupID uint) string {
+	return fmt.Sprintf("sub_group:%d:health", subGroupID)
+}
+
+// loadSubGroupHealth reads the health stats of a sub-group from the store
+func loadSubGroupHealth(st store.Store, key string) (subGroupHealth, bool, error) {
+	var health subGroupHealth
+	fields, err := st.HGetAll(key)
+	if err != nil {
+		return health, false, err
+	}
+	if len(fields) == 0 {
+		return health, false, nil
+	}
+	health.latencyMs, _ = strconv.ParseFloat(fields["latency_ms"], 64)
+	health.errorRate, _ = strconv.ParseFloat(fields["error_rate"], 64)
+	return health, true, nil
+}
+
+// matchModelPattern matches a model against an exact name or a prefix pattern ending with '*'
+func matchModelPattern(pattern, model string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return pattern == model
+}
+
+// parseSubGroupModels decodes the declared model list of a sub-group relation
+func parseSubGroupModels(raw datatypes.JSON) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var modelList []string
+	if err := json.Unmarshal(raw, &modelList); err != nil {
+		logrus.WithError(err).Warn("Failed to parse sub-group model list, treating as unrestricted")
+		return nil
+	}
+	return modelList
+}
+
+// parseSubGroupSchedule decodes the active schedule of a sub-group relation
+func parseSubGroupSchedule(raw datatypes.JSON) *models.ActiveSchedule {
+	schedule, err := utils.ParseActiveSchedule(raw)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to parse sub-group schedule, treating as always active")
+		return nil
+	}
+	return schedule
+}
+
+// resolveSubGroupModels returns the models a sub-group serves inside an aggregate.
+// Declared models take precedence; otherwise the list is discovered from the sub-group's
+// strict model redirect rules, which act as its model whitelist. Nil means unrestricted.
+func resolveSubGroupModels(sg *models.GroupSubGroup, subGroup *models.Group) []string {
+	if declared := parseSubGroupModels(sg.Models); len(declared) > 0 {
+		return declared
+	}
+
+	if subGroup == nil || !subGroup.ModelRedirectStrict || len(subGroup.ModelRedirectRules) == 0 {
+		return nil
+	}
+
+	discovered := make([]string, 0, len(subGroup.ModelRedirectRules))
+	for sourceModel := range subGroup.ModelRedirectRules {
+		discovered = append(discovered, sourceModel)
+	}
+	return discovered
 }
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gpt-load/internal/keypool"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+)
+
+// never is a schedule whose only window can never match.
+var never = &models.ActiveSchedule{Windows: []models.ActiveWindow{{Cron: "0 0 31 2 *"}}}
+
+type testSubGroup struct {
+	id       uint
+	weight   int
+	priority int
+	models   []string
+	noKeys   bool
+	state    string
+	schedule *models.ActiveSchedule
+}
+
+// newTestAggregate builds an aggregate group and gives each sub-group an active key unless noKeys is set.
+func newTestAggregate(t *testing.T, s store.Store, cfg types.SystemSettings, subGroups ...testSubGroup) *models.Group {
+	t.Helper()
+	group := &models.Group{ID: 100, Name: "aggregate", GroupType: "aggregate", EffectiveConfig: cfg}
+	for _, sg := range subGroups {
+		group.SubGroups = append(group.SubGroups, models.GroupSubGroup{
+			GroupID:         group.ID,
+			SubGroupID:      sg.id,
+			SubGroupName:    subGroupName(sg.id),
+			Weight:          sg.weight,
+			Priority:        sg.priority,
+			SupportedModels: sg.models,
+			ActiveSchedule:  sg.schedule,
+			SubGroupState:   sg.state,
+		})
+		if !sg.noKeys {
+			if err := s.LPush(keypool.ActiveKeysListKey(sg.id, 0), "key"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return group
+}
+
+func subGroupName(id uint) string {
+	return string(rune('a' + id - 1))
+}
+
+var staticWeights = types.SystemSettings{AdaptiveMinWeight: 1, AdaptiveMaxWeight: 1000}
+
+// selectCounts runs n selections and counts the picks of each sub-group.
+func selectCounts(t *testing.T, m *SubGroupManager, group *models.Group, model string, n int) (map[string]int, error) {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		name, err := m.SelectSubGroup(group, model)
+		if err != nil {
+			return counts, err
+		}
+		counts[name]++
+	}
+	return counts, nil
+}
+
+func TestSelectSubGroupTiers(t *testing.T) {
+	tests := []struct {
+		name      string
+		subGroups []testSubGroup
+		want      map[string]int
+		wantErr   bool
+	}{
+		{
+			name:      "highest tier is preferred",
+			subGroups: []testSubGroup{{id: 1, weight: 1, priority: 0}, {id: 2, weight: 10, priority: 1}},
+			want:      map[string]int{"a": 6},
+		},
+		{
+			name:      "tiers are ordered by priority value, not by position",
+			subGroups: []testSubGroup{{id: 1, weight: 1, priority: 5}, {id: 2, weight: 1, priority: 2}},
+			want:      map[string]int{"b": 6},
+		},
+		{
+			name:      "weighted round-robin within a tier",
+			subGroups: []testSubGroup{{id: 1, weight: 2, priority: 0}, {id: 2, weight: 1, priority: 0}, {id: 3, weight: 5, priority: 1}},
+			want:      map[string]int{"a": 4, "b": 2},
+		},
+		{
+			name:      "exhausted tier spills to the next",
+			subGroups: []testSubGroup{{id: 1, weight: 1, noKeys: true}, {id: 2, weight: 1, noKeys: true}, {id: 3, weight: 1, priority: 1}},
+			want:      map[string]int{"c": 6},
+		},
+		{
+			name:      "partly exhausted tier keeps serving",
+			subGroups: []testSubGroup{{id: 1, weight: 1, noKeys: true}, {id: 2, weight: 1}, {id: 3, weight: 1, priority: 1}},
+			want:      map[string]int{"b": 6},
+		},
+		{
+			name:      "draining sub-group is skipped",
+			subGroups: []testSubGroup{{id: 1, weight: 1, state: models.GroupStateDraining}, {id: 2, weight: 1, priority: 1}},
+			want:      map[string]int{"b": 6},
+		},
+		{
+			name:      "sub-group outside its schedule is skipped",
+			subGroups: []testSubGroup{{id: 1, weight: 1, schedule: never}, {id: 2, weight: 1, priority: 1}},
+			want:      map[string]int{"b": 6},
+		},
+		{
+			name:      "nothing available",
+			subGroups: []testSubGroup{{id: 1, weight: 1, noKeys: true}, {id: 2, weight: 1, priority: 1, state: models.GroupStateDisabled}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			m := NewSubGroupManager(s)
+			group := newTestAggregate(t, s, staticWeights, tt.subGroups...)
+
+			counts, err := selectCounts(t, m, group, "", 6)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectSubGroup error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(counts) != len(tt.want) {
+				t.Fatalf("picks = %v, want %v", counts, tt.want)
+			}
+			for name, want := range tt.want {
+				if counts[name] != want {
+					t.Errorf("picks = %v, want %v", counts, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSelectSubGroupCircuit(t *testing.T) {
+	s := store.NewMemoryStore()
+	m := NewSubGroupManager(s)
+	group := newTestAggregate(t, s, staticWeights, testSubGroup{id: 1, weight: 1}, testSubGroup{id: 2, weight: 1, priority: 1})
+
+	if name, err := m.SelectSubGroup(group, ""); err != nil || name != "a" {
+		t.Fatalf("SelectSubGroup = %q, %v, want a", name, err)
+	}
+
+	// A success resets the failure streak, so only the last run of failures counts
+	for i := 0; i < subGroupCircuitFailureThreshold-1; i++ {
+		m.RecordResult(group, 1, false, 0)
+	}
+	m.RecordResult(group, 1, true, 0)
+	for i := 0; i < subGroupCircuitFailureThreshold-1; i++ {
+		m.RecordResult(group, 1, false, 0)
+	}
+	if name, _ := m.SelectSubGroup(group, ""); name != "a" {
+		t.Fatalf("circuit opened before %d consecutive failures", subGroupCircuitFailureThreshold)
+	}
+
+	m.RecordResult(group, 1, false, 0)
+	if name, _ := m.SelectSubGroup(group, ""); name != "b" {
+		t.Errorf("SelectSubGroup = %q with an open circuit, want b", name)
+	}
+}
+
+func TestSelectSubGroupModels(t *testing.T) {
+	subGroups := []testSubGroup{
+		{id: 1, weight: 1, priority: 0, models: []string{"gpt-4*"}},
+		{id: 2, weight: 1, priority: 0, models: []string{"claude-3-opus"}},
+		{id: 3, weight: 1, priority: 1, models: []string{"gemini-*", "gpt-4o-mini"}},
+	}
+
+	tests := []struct {
+		name    string
+		model   string
+		want    map[string]int
+		wantErr error
+	}{
+		{name: "prefix pattern", model: "gpt-4-turbo", want: map[string]int{"a": 4}},
+		{name: "exact name", model: "claude-3-opus", want: map[string]int{"b": 4}},
+		{name: "served only by a lower tier", model: "gemini-1.5-pro", want: map[string]int{"c": 4}},
+		{name: "higher tier wins when both serve it", model: "gpt-4o-mini", want: map[string]int{"a": 4}},
+		{name: "no filtering without a model", model: "", want: map[string]int{"a": 2, "b": 2}},
+		{name: "exact name is not a prefix", model: "claude-3-opus-latest", wantErr: ErrNoSubGroupForModel},
+		{name: "unknown model", model: "llama-3", wantErr: ErrNoSubGroupForModel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			m := NewSubGroupManager(s)
+			group := newTestAggregate(t, s, staticWeights, subGroups...)
+
+			counts, err := selectCounts(t, m, group, tt.model, 4)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SelectSubGroup error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if counts[name] != want {
+					t.Errorf("picks for %q = %v, want %v", tt.model, counts, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAdaptiveWeights(t *testing.T) {
+	type health struct {
+		latencyMs string
+		errorRate string
+	}
+
+	tests := []struct {
+		name      string
+		minWeight int
+		maxWeight int
+		weights   map[uint]int
+		health    map[uint]health
+		want      map[uint]int
+	}{
+		{
+			name:      "error rate and relative latency scale weights",
+			minWeight: 1, maxWeight: 1000,
+			weights: map[uint]int{1: 100, 2: 100, 3: 100, 4: 100},
+			health: map[uint]health{
+				1: {latencyMs: "100", errorRate: "0"},
+				2: {latencyMs: "100", errorRate: "0.5"},
+				3: {latencyMs: "400", errorRate: "0"},
+			},
+			// Sub-group 4 has no stats yet and keeps its configured weight
+			want: map[uint]int{1: 100, 2: 50, 3: 25, 4: 100},
+		},
+		{
+			name:      "minimum weight keeps failing sub-groups in rotation",
+			minWeight: 5, maxWeight: 1000,
+			weights: map[uint]int{1: 100, 2: 100},
+			health:  map[uint]health{1: {latencyMs: "100", errorRate: "0"}, 2: {latencyMs: "0", errorRate: "1"}},
+			want:    map[uint]int{1: 100, 2: 5},
+		},
+		{
+			name:      "maximum weight caps configured weights",
+			minWeight: 1, maxWeight: 50,
+			weights: map[uint]int{1: 100, 2: 10},
+			want:    map[uint]int{1: 50, 2: 10},
+		},
+		{
+			name:      "zero weight gets no traffic",
+			minWeight: 5, maxWeight: 1000,
+			weights: map[uint]int{1: 100, 2: 0},
+			want:    map[uint]int{1: 100, 2: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			m := NewSubGroupManager(s)
+			var subGroups []testSubGroup
+			for id := uint(1); id <= uint(len(tt.weights)); id++ {
+				subGroups = append(subGroups, testSubGroup{id: id, weight: tt.weights[id]})
+			}
+			group := newTestAggregate(t, s, types.SystemSettings{
+				EnableAdaptiveWeights: true,
+				AdaptiveMinWeight:     tt.minWeight,
+				AdaptiveMaxWeight:     tt.maxWeight,
+			}, subGroups...)
+			for id, h := range tt.health {
+				if err := s.HSet(subGroupHealthKey(id), map[string]any{"latency_ms": h.latencyMs, "error_rate": h.errorRate}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sel := m.createSelector(group)
+			sel.refreshEffectiveWeights()
+			for _, item := range sel.subGroups {
+				if item.effectiveWeight != tt.want[item.subGroupID] {
+					t.Errorf("effective weight of sub-group %d = %d, want %d", item.subGroupID, item.effectiveWeight, tt.want[item.subGroupID])
+				}
+			}
+		})
+	}
+}
+
+func TestAdaptiveWeightsShiftTraffic(t *testing.T) {
+	s := store.NewMemoryStore()
+	m := NewSubGroupManager(s)
+	defer m.Stop()
+	group := newTestAggregate(t, s, types.SystemSettings{EnableAdaptiveWeights: true, AdaptiveMinWeight: 1, AdaptiveMaxWeight: 1000},
+		testSubGroup{id: 1, weight: 10}, testSubGroup{id: 2, weight: 10})
+	if err := s.HSet(subGroupHealthKey(2), map[string]any{"latency_ms": "100", "error_rate": "0.9"}); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := selectCounts(t, m, group, "", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["a"] != 10 || counts["b"] != 1 {
+		t.Errorf("picks = %v, want a: 10, b: 1", counts)
+	}
+}
+
+func TestUpdateHealth(t *testing.T) {
+	s := store.NewMemoryStore()
+	m := NewSubGroupManager(s)
+
+	steps := []struct {
+		samples       subGroupSamples
+		wantLatency   float64
+		wantErrorRate float64
+	}{
+		// The first flush is taken as is
+		{samples: subGroupSamples{requests: 4, successes: 3, failures: 1, latencySum: 600}, wantLatency: 200, wantErrorRate: 0.25},
+		// Only failures: the error rate moves, latency is kept
+		{samples: subGroupSamples{requests: 1, failures: 1}, wantLatency: 200, wantErrorRate: 0.4},
+		// One success at 100ms: both move by the smoothing factor
+		{samples: subGroupSamples{requests: 1, successes: 1, latencySum: 100}, wantLatency: 180, wantErrorRate: 0.32},
+	}
+
+	for i, step := range steps {
+		samples := step.samples
+		m.updateHealth(1, &samples)
+		health, found, err := loadSubGroupHealth(s, subGroupHealthKey(1))
+		if err != nil || !found {
+			t.Fatalf("step %d: health not saved: %v", i, err)
+		}
+		if math.Abs(health.latencyMs-step.wantLatency) > 0.01 || math.Abs(health.errorRate-step.wantErrorRate) > 0.0001 {
+			t.Errorf("step %d: health = %+v, want latency %v and error rate %v", i, health, step.wantLatency, step.wantErrorRate)
+		}
+	}
+}
+
+func TestRecordResultCollectsSamples(t *testing.T) {
+	s := store.NewMemoryStore()
+	m := NewSubGroupManager(s)
+	group := newTestAggregate(t, s, types.SystemSettings{EnableAdaptiveWeights: true, AdaptiveMinWeight: 1, AdaptiveMaxWeight: 1000},
+		testSubGroup{id: 1, weight: 1})
+	if _, err := m.SelectSubGroup(group, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	m.RecordResult(group, 1, true, 300*time.Millisecond)
+	m.RecordResult(group, 1, false, time.Second)
+	m.Stop()
+
+	health, found, err := loadSubGroupHealth(s, subGroupHealthKey(1))
+	if err != nil || !found {
+		t.Fatalf("health not flushed on stop: %v", err)
+	}
+	if health.latencyMs != 300 || health.errorRate != 0.5 {
+		t.Errorf("health = %+v, want latency 300 and error rate 0.5", health)
+	}
+}
+
+func TestMatchModelPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		model   string
+		want    bool
+	}{
+		{pattern: "gpt-4o", model: "gpt-4o", want: true},
+		{pattern: "gpt-4o", model: "gpt-4o-mini", want: false},
+		{pattern: "gpt-4*", model: "gpt-4o-mini", want: true},
+		{pattern: "gpt-4*", model: "gpt-3.5-turbo", want: false},
+		{pattern: "*", model: "anything", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.model, func(t *testing.T) {
+			if got := matchModelPattern(tt.pattern, tt.model); got != tt.want {
+				t.Errorf("matchModelPattern(%q, %q) = %v, want %v", tt.pattern, tt.model, got, tt.want)
+			}
+		})
+	}
+}
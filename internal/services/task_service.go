@@ -1,41 +1,101 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"gpt-load/internal/store"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	globalTaskKey = "global_task"
-	ResultTTL     = 60 * time.Minute
+	taskKeyPrefix       = "task:"
+	taskLockKeyPrefix   = "task_lock:"
+	taskCancelKeyPrefix = "task_cancel:"
+	taskHistoryKey      = "task_history"
+	taskRunningKey      = "task_running"
+
+	// ResultTTL is how long finished tasks are kept in the history.
+	ResultTTL = 24 * time.Hour
+
+	maxTaskHistory        = 200
+	taskHeartbeatInterval = 2 * time.Second
+	// taskStaleAfter is how long a running task may go without a heartbeat before it is
+	// considered lost, e.g. because its node stopped. Its lock expires at the same time.
+	taskStaleAfter = 30 * time.Second
 )
 
 const (
 	TaskTypeKeyValidation = "KEY_VALIDATION"
 	TaskTypeKeyImport     = "KEY_IMPORT"
 	TaskTypeKeyDelete     = "KEY_DELETE"
+	TaskTypeKeySync       = "KEY_SYNC"
+	TaskTypeKeyTransfer   = "KEY_TRANSFER"
+
+	TaskTypeEncryptionKeyRotation = "ENCRYPTION_KEY_ROTATION"
+)
+
+// Task states.
+const (
+	TaskStateRunning   = "running"
+	TaskStateCompleted = "completed"
+	TaskStateFailed    = "failed"
+	TaskStateCancelled = "cancelled"
+)
+
+// nonCancelableTaskTypes are tasks that resume after a restart and cannot be stopped halfway.
+var nonCancelableTaskTypes = map[string]bool{
+	TaskTypeEncryptionKeyRotation: true,
+}
+
+var (
+	// ErrTaskAlreadyRunning is returned when a task is started for a group that already has one running.
+	ErrTaskAlreadyRunning = errors.New("a task is already running for this group, please wait")
+	// ErrTaskNotFound is returned when a task does not exist or its result has expired.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskNotCancelable is returned when cancelling a finished task or one that cannot be stopped.
+	ErrTaskNotCancelable = errors.New("task cannot be cancelled")
+)
+
+var (
+	// errTaskInterrupted is recorded on running tasks whose node stopped reporting.
+	errTaskInterrupted = errors.New("task was interrupted, its node stopped responding")
+	// errTaskLockLost is recorded on tasks cancelled because their lock expired and may be held by another task.
+	errTaskLockLost = errors.New("task lock was lost, the task was stopped to avoid running concurrently with another task")
 )
 
 // TaskStatus represents the full lifecycle of a long-running task.
 type TaskStatus struct {
+	ID              string     `json:"id"`
 	TaskType        string     `json:"task_type"`
+	State           string     `json:"state"`
 	IsRunning       bool       `json:"is_running"`
 	GroupName       string     `json:"group_name,omitempty"`
 	Processed       int        `json:"processed"`
 	Total           int        `json:"total"`
 	Result          any        `json:"result,omitempty"`
 	Error           string     `json:"error,omitempty"`
+	Cancelable      bool       `json:"cancelable"`
+	CancelRequested bool       `json:"cancel_requested,omitempty"`
 	StartedAt       time.Time  `json:"started_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 	FinishedAt      *time.Time `json:"finished_at,omitempty"`
 	DurationSeconds float64    `json:"duration_seconds,omitempty"`
 }
 
-// TaskService manages the state of a single, global, long-running task using the store interface.
+// TaskService runs long-running tasks as a queue shared by all nodes through the store.
+// Each group runs one task at a time, while tasks of different groups run concurrently.
 type TaskService struct {
 	store store.Store
+	local sync.Map // Task ID -> *Task running on this node
 }
 
 // NewTaskService creates a new TaskService.
@@ -45,43 +105,235 @@ func NewTaskService(store store.Store) *TaskService {
 	}
 }
 
-// StartTask attempts to start a new task. It returns an error if a task is already running.
-func (s *TaskService) StartTask(taskType, groupName string, total int) (*TaskStatus, error) {
-	currentStatus, err := s.GetTaskStatus()
-	if err != nil {
-		return nil, fmt.Errorf("failed to check current task status before starting a new one: %w", err)
+// Task is a handle to a running task, used by its owner to report progress and the result.
+type Task struct {
+	service  *TaskService
+	ctx      context.Context
+	cancel   context.CancelFunc
+	lockKeys []string
+	done     chan struct{}
+
+	mu       sync.Mutex
+	status   TaskStatus
+	ended    bool
+	lockLost bool
+}
+
+// StartTask starts a new task. Tasks of a group are locked per group, and tasks without a
+// group are locked per task type. It returns ErrTaskAlreadyRunning if the lock is taken.
+func (s *TaskService) StartTask(taskType, groupName string, total int) (*Task, error) {
+	if groupName == "" {
+		return s.startTask(taskType, "", []string{taskLockKeyPrefix + "type:" + taskType}, total)
 	}
+	return s.StartGroupsTask(taskType, []string{groupName}, total)
+}
 
-	if currentStatus.IsRunning {
-		return nil, errors.New("a task is already running, please wait")
+// StartGroupsTask starts a new task that changes several groups, locking all of them.
+// The task is shown under the first group.
+func (s *TaskService) StartGroupsTask(taskType string, groupNames []string, total int) (*Task, error) {
+	lockKeys := make([]string, 0, len(groupNames))
+	for _, groupName := range groupNames {
+		lockKey := taskLockKeyPrefix + "group:" + groupName
+		if !slices.Contains(lockKeys, lockKey) {
+			lockKeys = append(lockKeys, lockKey)
+		}
 	}
+	return s.startTask(taskType, groupNames[0], lockKeys, total)
+}
 
-	status := &TaskStatus{
-		TaskType:  taskType,
-		IsRunning: true,
-		GroupName: groupName,
-		Total:     total,
-		Processed: 0,
-		StartedAt: time.Now(),
+func (s *TaskService) startTask(taskType, groupName string, lockKeys []string, total int) (*Task, error) {
+	id := uuid.NewString()
+	if err := s.acquireLocks(lockKeys, id); err != nil {
+		return nil, err
 	}
-	statusBytes, err := json.Marshal(status)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize new task status: %w", err)
+
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{
+		service:  s,
+		ctx:      ctx,
+		cancel:   cancel,
+		lockKeys: lockKeys,
+		done:     make(chan struct{}),
+		status: TaskStatus{
+			ID:         id,
+			TaskType:   taskType,
+			State:      TaskStateRunning,
+			IsRunning:  true,
+			GroupName:  groupName,
+			Total:      total,
+			Cancelable: !nonCancelableTaskTypes[taskType],
+			StartedAt:  now,
+			UpdatedAt:  now,
+		},
 	}
 
-	if err := s.store.Set(globalTaskKey, statusBytes, ResultTTL); err != nil {
+	if err := s.saveStatus(&task.status); err != nil {
+		cancel()
+		s.releaseLocks(lockKeys, id)
 		return nil, fmt.Errorf("failed to set initial task status: %w", err)
 	}
 
-	return status, nil
+	startedAt := strconv.FormatInt(now.UnixNano(), 10)
+	if err := s.store.HSet(taskHistoryKey, map[string]any{id: startedAt}); err != nil {
+		logrus.WithError(err).Warn("Failed to add task to history")
+	}
+	if err := s.store.HSet(taskRunningKey, map[string]any{id: startedAt}); err != nil {
+		logrus.WithError(err).Warn("Failed to add task to the running list")
+	}
+	s.pruneHistory()
+
+	s.local.Store(id, task)
+	go task.heartbeat()
+
+	return task, nil
 }
 
-// GetTaskStatus returns the current status of the task.
-func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
-	statusBytes, err := s.store.Get(globalTaskKey)
+// ID returns the ID of the task.
+func (t *Task) ID() string {
+	return t.status.ID
+}
+
+// Context is cancelled when the task is cancelled. Task runners should stop at the next
+// safe point and end the task with the context error.
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Status returns a copy of the current status of the task.
+func (t *Task) Status() TaskStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// UpdateProgress updates the number of processed items of the task.
+func (t *Task) UpdateProgress(processed int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return nil
+	}
+
+	t.status.Processed = processed
+	t.status.UpdatedAt = time.Now()
+	return t.service.saveStatus(&t.status)
+}
+
+// End marks the task as finished and stores its result. A task ended with an error after
+// it was cancelled is recorded as cancelled, and a task that lost its lock as failed,
+// both keeping the partial result.
+func (t *Task) End(resultData any, taskErr error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return nil
+	}
+	t.ended = true
+	close(t.done)
+
+	now := time.Now()
+	t.status.IsRunning = false
+	t.status.UpdatedAt = now
+	t.status.FinishedAt = &now
+	t.status.DurationSeconds = now.Sub(t.status.StartedAt).Seconds()
+	if resultData != nil {
+		t.status.Result = resultData
+	}
+	switch {
+	case t.lockLost:
+		t.status.State = TaskStateFailed
+		t.status.Error = errTaskLockLost.Error()
+	case taskErr == nil:
+		t.status.State = TaskStateCompleted
+	case t.ctx.Err() != nil:
+		t.status.State = TaskStateCancelled
+		t.status.Error = "cancelled"
+	default:
+		t.status.State = TaskStateFailed
+		t.status.Error = taskErr.Error()
+	}
+	t.cancel()
+
+	s := t.service
+	s.local.Delete(t.status.ID)
+	if err := s.store.HDel(taskRunningKey, t.status.ID); err != nil {
+		logrus.WithError(err).Warn("Failed to remove task from the running list")
+	}
+	if err := s.store.Delete(taskCancelKeyPrefix + t.status.ID); err != nil {
+		logrus.WithError(err).Debug("Failed to clear task cancel flag")
+	}
+	err := s.saveStatus(&t.status)
+	s.releaseLocks(t.lockKeys, t.status.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save final task status: %w", err)
+	}
+	return nil
+}
+
+// heartbeat keeps the lock and status of the task alive and picks up cancellations
+// requested on other nodes, until the task ends. A task whose lock was lost is cancelled
+// and fails, as the lock no longer keeps other tasks of its scope out.
+func (t *Task) heartbeat() {
+	ticker := time.NewTicker(taskHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+
+		s := t.service
+		if cancelled, err := s.store.Exists(taskCancelKeyPrefix + t.status.ID); err == nil && cancelled {
+			t.requestCancel()
+		}
+
+		lockLost := false
+		t.mu.Lock()
+		if !t.ended {
+			t.status.UpdatedAt = time.Now()
+			if err := s.saveStatus(&t.status); err != nil {
+				logrus.WithError(err).WithField("task_id", t.status.ID).Warn("Failed to refresh task status")
+			}
+			// A lock may have expired and been taken by another task in the meantime
+			for _, lockKey := range t.lockKeys {
+				if held, err := s.store.CompareAndSet(lockKey, []byte(t.status.ID), []byte(t.status.ID), taskStaleAfter); err != nil {
+					logrus.WithError(err).WithField("task_id", t.status.ID).Warn("Failed to refresh task lock")
+				} else if !held {
+					lockLost = true
+				}
+			}
+			if lockLost && !t.lockLost {
+				t.lockLost = true
+				logrus.WithField("task_id", t.status.ID).Error("Task lock was lost, stopping the task")
+			}
+		}
+		t.mu.Unlock()
+
+		if lockLost {
+			t.requestCancel()
+		}
+	}
+}
+
+func (t *Task) requestCancel() {
+	t.mu.Lock()
+	if !t.status.CancelRequested {
+		t.status.CancelRequested = true
+		logrus.WithFields(logrus.Fields{"task_id": t.status.ID, "task_type": t.status.TaskType, "group": t.status.GroupName}).Info("Cancelling task")
+	}
+	t.mu.Unlock()
+	t.cancel()
+}
+
+// GetTask returns the status of a task by its ID.
+func (s *TaskService) GetTask(id string) (*TaskStatus, error) {
+	statusBytes, err := s.store.Get(taskKeyPrefix + id)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return &TaskStatus{IsRunning: false}, nil
+			return nil, ErrTaskNotFound
 		}
 		return nil, fmt.Errorf("failed to get task status: %w", err)
 	}
@@ -91,6 +343,15 @@ func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
 		return nil, fmt.Errorf("failed to deserialize task status: %w", err)
 	}
 
+	if status.IsRunning {
+		if time.Since(status.UpdatedAt) > taskStaleAfter {
+			s.markInterrupted(&status)
+		} else if !status.CancelRequested {
+			if cancelled, err := s.store.Exists(taskCancelKeyPrefix + id); err == nil && cancelled {
+				status.CancelRequested = true
+			}
+		}
+	}
 	if !status.IsRunning && status.FinishedAt != nil {
 		status.DurationSeconds = status.FinishedAt.Sub(status.StartedAt).Seconds()
 	}
@@ -98,49 +359,159 @@ func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
 	return &status, nil
 }
 
-// UpdateProgress updates the progress of the current task.
-func (s *TaskService) UpdateProgress(processed int) error {
-	status, err := s.GetTaskStatus()
+// ListTasks returns the most recent tasks, newest first, optionally only those of a group
+// or those still running.
+func (s *TaskService) ListTasks(groupName string, runningOnly bool, limit int) ([]*TaskStatus, error) {
+	indexKey := taskHistoryKey
+	if runningOnly {
+		indexKey = taskRunningKey
+	}
+	ids, err := s.sortedTaskIDs(indexKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !status.IsRunning {
-		return nil
+
+	tasks := make([]*TaskStatus, 0, min(len(ids), limit))
+	for _, id := range ids {
+		if len(tasks) >= limit {
+			break
+		}
+		status, err := s.GetTask(id)
+		if errors.Is(err, ErrTaskNotFound) {
+			_ = s.store.HDel(indexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (groupName != "" && status.GroupName != groupName) || (runningOnly && !status.IsRunning) {
+			continue
+		}
+		tasks = append(tasks, status)
 	}
+	return tasks, nil
+}
 
-	status.Processed = processed
-	statusBytes, err := json.Marshal(status)
+// GetTaskStatus returns the most recently started running task, or the most recently
+// started task when none is running. It serves clients that follow a single task.
+func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
+	running, err := s.ListTasks("", true, 1)
 	if err != nil {
-		return fmt.Errorf("failed to serialize updated status: %w", err)
+		return nil, err
+	}
+	if len(running) > 0 {
+		return running[0], nil
 	}
 
-	return s.store.Set(globalTaskKey, statusBytes, ResultTTL)
+	recent, err := s.ListTasks("", false, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(recent) > 0 {
+		return recent[0], nil
+	}
+	return &TaskStatus{IsRunning: false}, nil
 }
 
-// EndTask marks the current task as finished and stores its final result.
-func (s *TaskService) EndTask(resultData any, taskErr error) error {
-	status, err := s.GetTaskStatus()
+// CancelTask requests a running task to stop. The node running the task stops it at the
+// next safe point, and the task ends as cancelled with its partial result.
+func (s *TaskService) CancelTask(id string) (*TaskStatus, error) {
+	status, err := s.GetTask(id)
 	if err != nil {
-		return fmt.Errorf("failed to get task object to end task: %w", err)
+		return nil, err
 	}
-	if !status.IsRunning {
-		return nil
+	if !status.IsRunning || !status.Cancelable {
+		return nil, ErrTaskNotCancelable
 	}
 
-	now := time.Now()
+	if err := s.store.Set(taskCancelKeyPrefix+id, []byte("1"), taskStaleAfter*2); err != nil {
+		return nil, fmt.Errorf("failed to request task cancellation: %w", err)
+	}
+	if task, ok := s.local.Load(id); ok {
+		task.(*Task).requestCancel()
+	}
+
+	status.CancelRequested = true
+	return status, nil
+}
+
+// markInterrupted records a running task whose node stopped sending heartbeats as failed.
+func (s *TaskService) markInterrupted(status *TaskStatus) {
+	finishedAt := status.UpdatedAt
 	status.IsRunning = false
-	status.FinishedAt = &now
-	status.DurationSeconds = now.Sub(status.StartedAt).Seconds()
-	if taskErr != nil {
-		status.Error = taskErr.Error()
-	} else {
-		status.Result = resultData
+	status.State = TaskStateFailed
+	status.Error = errTaskInterrupted.Error()
+	status.FinishedAt = &finishedAt
+
+	if err := s.saveStatus(status); err != nil {
+		logrus.WithError(err).WithField("task_id", status.ID).Warn("Failed to record interrupted task")
+	}
+	_ = s.store.HDel(taskRunningKey, status.ID)
+}
+
+func (s *TaskService) saveStatus(status *TaskStatus) error {
+	statusBytes, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to serialize task status: %w", err)
+	}
+	return s.store.Set(taskKeyPrefix+status.ID, statusBytes, ResultTTL)
+}
+
+// acquireLocks takes the task locks in sorted order, so tasks locking the same groups cannot
+// each hold a part of them. Locks already taken are released when one is held by another task.
+func (s *TaskService) acquireLocks(lockKeys []string, id string) error {
+	sorted := slices.Sorted(slices.Values(lockKeys))
+	for i, lockKey := range sorted {
+		acquired, err := s.store.SetNX(lockKey, []byte(id), taskStaleAfter)
+		if err != nil || !acquired {
+			s.releaseLocks(sorted[:i], id)
+			if err != nil {
+				return fmt.Errorf("failed to acquire task lock: %w", err)
+			}
+			return ErrTaskAlreadyRunning
+		}
 	}
+	return nil
+}
+
+// releaseLocks deletes the task locks still held by the given task.
+func (s *TaskService) releaseLocks(lockKeys []string, id string) {
+	for _, lockKey := range lockKeys {
+		if _, err := s.store.CompareAndDelete(lockKey, []byte(id)); err != nil {
+			logrus.WithError(err).WithField("task_id", id).Warn("Failed to release task lock")
+		}
+	}
+}
 
-	updatedTaskBytes, err := json.Marshal(status)
+// sortedTaskIDs returns the task IDs of an index hash, most recently started first.
+func (s *TaskService) sortedTaskIDs(indexKey string) ([]string, error) {
+	index, err := s.store.HGetAll(indexKey)
 	if err != nil {
-		return fmt.Errorf("failed to serialize final task status: %w", err)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
 	}
 
-	return s.store.Set(globalTaskKey, updatedTaskBytes, ResultTTL)
+	ids := make([]string, 0, len(index))
+	startedAt := make(map[string]int64, len(index))
+	for id, value := range index {
+		ids = append(ids, id)
+		startedAt[id], _ = strconv.ParseInt(value, 10, 64)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return startedAt[ids[i]] > startedAt[ids[j]]
+	})
+	return ids, nil
+}
+
+// pruneHistory drops the oldest tasks beyond the history limit.
+func (s *TaskService) pruneHistory() {
+	ids, err := s.sortedTaskIDs(taskHistoryKey)
+	if err != nil || len(ids) <= maxTaskHistory {
+		return
+	}
+	if err := s.store.HDel(taskHistoryKey, ids[maxTaskHistory:]...); err != nil {
+		logrus.WithError(err).Warn("Failed to prune task history")
+	}
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"sync"
@@ -146,6 +147,105 @@ func (s *MemoryStore) SetNX(key string, value []byte, ttl time.Duration) (bool,
 	return true, nil
 }
 
+// IncrBy atomically increments an integer counter. The TTL is applied when the counter is created.
+func (s *MemoryStore) IncrBy(key string, incr int64, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	var item memoryStoreItem
+	rawItem, exists := s.data[key]
+	if exists {
+		var ok bool
+		item, ok = rawItem.(memoryStoreItem)
+		if !ok {
+			return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+		}
+		if item.expiresAt > 0 && now > item.expiresAt {
+			exists = false
+		}
+	}
+
+	var currentVal int64
+	if exists {
+		var err error
+		currentVal, err = strconv.ParseInt(string(item.value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value of key '%s' is not an integer", key)
+		}
+	} else {
+		item = memoryStoreItem{}
+		if ttl > 0 {
+			item.expiresAt = now + ttl.Nanoseconds()
+		}
+	}
+
+	newVal := currentVal + incr
+	item.value = []byte(strconv.FormatInt(newVal, 10))
+	s.data[key] = item
+	return newVal, nil
+}
+
+// CompareAndSet replaces the value and TTL of a key if it currently holds expected.
+func (s *MemoryStore) CompareAndSet(key string, expected, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(key, expected) {
+		return false, nil
+	}
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().UnixNano() + ttl.Nanoseconds()
+	}
+	s.data[key] = memoryStoreItem{
+		value:     value,
+		expiresAt: expiresAt,
+	}
+	return true, nil
+}
+
+// CompareAndDelete deletes a key if it currently holds expected.
+func (s *MemoryStore) CompareAndDelete(key string, expected []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(key, expected) {
+		return false, nil
+	}
+	delete(s.data, key)
+	return true, nil
+}
+
+// GetDel atomically retrieves and deletes a key.
+func (s *MemoryStore) GetDel(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawItem, exists := s.data[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	item, ok := rawItem.(memoryStoreItem)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	delete(s.data, key)
+	if item.expiresAt > 0 && time.Now().UnixNano() > item.expiresAt {
+		return nil, ErrNotFound
+	}
+	return item.value, nil
+}
+
+// holds reports whether a key is an unexpired K/V item with the given value. The caller must hold the lock.
+func (s *MemoryStore) holds(key string, expected []byte) bool {
+	item, ok := s.data[key].(memoryStoreItem)
+	if !ok || (item.expiresAt > 0 && time.Now().UnixNano() > item.expiresAt) {
+		return false
+	}
+	return bytes.Equal(item.value, expected)
+}
+
 // --- HASH operations ---
 
 func (s *MemoryStore) HSet(key string, values map[string]any) error {
@@ -217,6 +317,28 @@ func (s *MemoryStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return newVal, nil
 }
 
+func (s *MemoryStore) HDel(key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawHash, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+	hash, ok := rawHash.(map[string]string)
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
 // --- LIST operations ---
 
 func (s *MemoryStore) LPush(key string, values ...any) error {
@@ -377,6 +499,84 @@ func (s *MemoryStore) SPopN(key string, count int64) ([]string, error) {
 	return popped, nil
 }
 
+// --- SLOT operations ---
+
+// memorySlotSet maps the members of a slot set to their Unix-nano expiry.
+type memorySlotSet map[string]int64
+
+// AcquireSlot drops expired members and adds member if fewer than limit remain.
+func (s *MemoryStore) AcquireSlot(key, member string, limit int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, err := s.slotSet(key, true)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UnixNano()
+	for held, expiresAt := range slots {
+		if expiresAt <= now {
+			delete(slots, held)
+		}
+	}
+	if _, held := slots[member]; !held && int64(len(slots)) >= limit {
+		return false, nil
+	}
+	slots[member] = now + ttl.Nanoseconds()
+	return true, nil
+}
+
+// RefreshSlot extends the expiry of a held member.
+func (s *MemoryStore) RefreshSlot(key, member string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, err := s.slotSet(key, false)
+	if err != nil || slots == nil {
+		return false, err
+	}
+	now := time.Now().UnixNano()
+	if expiresAt, held := slots[member]; !held || expiresAt <= now {
+		return false, nil
+	}
+	slots[member] = now + ttl.Nanoseconds()
+	return true, nil
+}
+
+// ReleaseSlot removes a member, and the slot set once it is empty.
+func (s *MemoryStore) ReleaseSlot(key, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, err := s.slotSet(key, false)
+	if err != nil || slots == nil {
+		return err
+	}
+	delete(slots, member)
+	if len(slots) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+// slotSet returns the slot set at key, creating it if requested. The caller must hold the lock.
+func (s *MemoryStore) slotSet(key string, create bool) (memorySlotSet, error) {
+	rawSlots, exists := s.data[key]
+	if !exists {
+		if !create {
+			return nil, nil
+		}
+		slots := make(memorySlotSet)
+		s.data[key] = slots
+		return slots, nil
+	}
+	slots, ok := rawSlots.(memorySlotSet)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	return slots, nil
+}
+
 // --- Pub/Sub operations ---
 
 // memorySubscription implements the Subscription interface for the in-memory store.
@@ -81,6 +81,83 @@ func (s *RedisStore) SetNX(key string, value []byte, ttl time.Duration) (bool, e
 	return s.client.SetNX(context.Background(), s.prefixKey(key), value, ttl).Result()
 }
 
+// incrByScript increments KEYS[1] by ARGV[1] and sets a TTL of ARGV[2] milliseconds if it created
+// the counter, so a counter never outlives a failure between the two steps without a TTL
+var incrByScript = redis.NewScript(`
+local created = redis.call("EXISTS", KEYS[1]) == 0
+local val = redis.call("INCRBY", KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return val
+`)
+
+// IncrBy atomically increments an integer counter in Redis. The TTL is applied when the counter is created.
+func (s *RedisStore) IncrBy(key string, incr int64, ttl time.Duration) (int64, error) {
+	return incrByScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, incr, ttl.Milliseconds()).Int64()
+}
+
+// compareAndSetScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] milliseconds (0 for none)
+// if it holds ARGV[1]
+var compareAndSetScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// compareAndDeleteScript deletes KEYS[1] if it holds ARGV[1]
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+return redis.call("DEL", KEYS[1])
+`)
+
+// getDelScript returns KEYS[1] and deletes it, GETDEL needs Redis 6.2
+var getDelScript = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value then
+	redis.call("DEL", KEYS[1])
+end
+return value
+`)
+
+// CompareAndSet atomically replaces the value and TTL of a key in Redis if it currently holds expected.
+func (s *RedisStore) CompareAndSet(key string, expected, value []byte, ttl time.Duration) (bool, error) {
+	res, err := compareAndSetScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, expected, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// CompareAndDelete atomically deletes a key in Redis if it currently holds expected.
+func (s *RedisStore) CompareAndDelete(key string, expected []byte) (bool, error) {
+	res, err := compareAndDeleteScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, expected).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// GetDel atomically retrieves and deletes a key in Redis.
+func (s *RedisStore) GetDel(key string) ([]byte, error) {
+	val, err := getDelScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
 // Close closes the Redis client connection.
 func (s *RedisStore) Close() error {
 	return s.client.Close()
@@ -100,6 +177,10 @@ func (s *RedisStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return s.client.HIncrBy(context.Background(), s.prefixKey(key), field, incr).Result()
 }
 
+func (s *RedisStore) HDel(key string, fields ...string) error {
+	return s.client.HDel(context.Background(), s.prefixKey(key), fields...).Err()
+}
+
 // --- LIST operations ---
 
 func (s *RedisStore) LPush(key string, values ...any) error {
@@ -137,6 +218,58 @@ func (s *RedisStore) SPopN(key string, count int64) ([]string, error) {
 	return s.client.SPopN(context.Background(), s.prefixKey(key), count).Result()
 }
 
+// --- SLOT operations ---
+
+// Slot sets are sorted sets scored by the Unix-millisecond expiry of each member. The key itself
+// expires with its most recently acquired or refreshed member.
+
+// acquireSlotScript adds ARGV[1] to KEYS[1] if fewer than ARGV[2] members expire after ARGV[3],
+// with an expiry of ARGV[3] + ARGV[4]
+var acquireSlotScript = redis.NewScript(`
+local now = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) and redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[4]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return 1
+`)
+
+// refreshSlotScript extends the expiry of ARGV[1] in KEYS[1] to ARGV[2] + ARGV[3] if it has not expired
+var refreshSlotScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not score or tonumber(score) <= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// AcquireSlot atomically drops expired members and adds member if fewer than limit remain.
+func (s *RedisStore) AcquireSlot(key, member string, limit int64, ttl time.Duration) (bool, error) {
+	res, err := acquireSlotScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, member, limit, time.Now().UnixMilli(), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// RefreshSlot extends the expiry of a held member.
+func (s *RedisStore) RefreshSlot(key, member string, ttl time.Duration) (bool, error) {
+	res, err := refreshSlotScript.Run(context.Background(), s.client, []string{s.prefixKey(key)}, member, time.Now().UnixMilli(), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// ReleaseSlot removes a member.
+func (s *RedisStore) ReleaseSlot(key, member string) error {
+	return s.client.ZRem(context.Background(), s.prefixKey(key), member).Err()
+}
+
 // --- Pipeliner implementation ---
 
 type redisPipeliner struct {
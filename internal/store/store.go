@@ -40,10 +40,23 @@ type Store interface {
 	// SetNX sets a key-value pair if the key does not already exist.
 	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
 
+	// IncrBy atomically increments an integer counter. The TTL is applied when the counter is created.
+	IncrBy(key string, incr int64, ttl time.Duration) (int64, error)
+
+	// CompareAndSet atomically replaces the value and TTL of a key if it currently holds expected.
+	CompareAndSet(key string, expected, value []byte, ttl time.Duration) (bool, error)
+
+	// CompareAndDelete atomically deletes a key if it currently holds expected.
+	CompareAndDelete(key string, expected []byte) (bool, error)
+
+	// GetDel atomically retrieves and deletes a key. It returns ErrNotFound if the key does not exist.
+	GetDel(key string) ([]byte, error)
+
 	// HASH operations
 	HSet(key string, values map[string]any) error
 	HGetAll(key string) (map[string]string, error)
 	HIncrBy(key, field string, incr int64) (int64, error)
+	HDel(key string, fields ...string) error
 
 	// LIST operations
 	LPush(key string, values ...any) error
@@ -55,6 +68,14 @@ type Store interface {
 	SAdd(key string, members ...any) error
 	SPopN(key string, count int64) ([]string, error)
 
+	// SLOT operations, a set of members that each expire on their own, for counting holders across nodes.
+	// AcquireSlot atomically drops expired members and adds member, expiring after ttl, if fewer than limit remain.
+	AcquireSlot(key, member string, limit int64, ttl time.Duration) (bool, error)
+	// RefreshSlot extends the expiry of a held member. It reports false if the member is no longer held.
+	RefreshSlot(key, member string, ttl time.Duration) (bool, error)
+	// ReleaseSlot removes a member.
+	ReleaseSlot(key, member string) error
+
 	// Close closes the store and releases any underlying resources.
 	Close() error
 
@@ -47,8 +47,11 @@ func NewCacheSyncer[T any](
 		return nil, fmt.Errorf("initial load for %s failed: %w", channelName, err)
 	}
 
+	// Subscribe before returning so invalidations published right after startup are not missed
+	subscription := s.initialSubscription()
+
 	s.wg.Add(1)
-	go s.listenForUpdates()
+	go s.listenForUpdates(subscription)
 
 	return s, nil
 }
@@ -95,8 +98,22 @@ func (s *CacheSyncer[T]) reload() error {
 	return nil
 }
 
+// initialSubscription subscribes to the channel, or returns nil to let the listener retry.
+func (s *CacheSyncer[T]) initialSubscription() store.Subscription {
+	if s.store == nil {
+		return nil
+	}
+	subscription, err := s.store.Subscribe(s.channelName)
+	if err != nil {
+		s.logger.Errorf("failed to subscribe, retrying in background: %v", err)
+		return nil
+	}
+	return subscription
+}
+
 // listenForUpdates runs in the background, listening for invalidation messages.
-func (s *CacheSyncer[T]) listenForUpdates() {
+// An initial subscription, when given, is used before subscribing again.
+func (s *CacheSyncer[T]) listenForUpdates(initial store.Subscription) {
 	defer s.wg.Done()
 
 	for {
@@ -112,7 +129,12 @@ func (s *CacheSyncer[T]) listenForUpdates() {
 			return
 		}
 
-		subscription, err := s.store.Subscribe(s.channelName)
+		subscription := initial
+		initial = nil
+		var err error
+		if subscription == nil {
+			subscription, err = s.store.Subscribe(s.channelName)
+		}
 		if err != nil {
 			s.logger.Errorf("failed to subscribe, retrying in 5s: %v", err)
 			select {
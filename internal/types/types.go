@@ -4,11 +4,15 @@ package types
 type ConfigManager interface {
 	IsMaster() bool
 	GetAuthConfig() AuthConfig
+	GetOIDCConfig() OIDCConfig
 	GetCORSConfig() CORSConfig
 	GetPerformanceConfig() PerformanceConfig
 	GetLogConfig() LogConfig
 	GetDatabaseConfig() DatabaseConfig
 	GetEncryptionKey() string
+	GetEncryptionConfig() EncryptionConfig
+	GetDeclarativeConfig() DeclarativeConfig
+	GetKeySourceConfig() KeySourceConfig
 	GetEffectiveServerConfig() ServerConfig
 	GetRedisDSN() string
 	Validate() error
@@ -20,7 +24,7 @@ type ConfigManager interface {
 type SystemSettings struct {
 	// 基础参数
 	AppUrl                         string `json:"app_url" default:"http://localhost:3001" name:"config.app_url" category:"config.category.basic" desc:"config.app_url_desc" validate:"required"`
-	ProxyKeys                      string `json:"proxy_keys" name:"config.proxy_keys" category:"config.category.basic" desc:"config.proxy_keys_desc" validate:"required"`
+	ProxyKeys                      string `json:"proxy_keys" name:"config.proxy_keys" category:"config.category.basic" desc:"config.proxy_keys_desc"`
 	RequestLogRetentionDays        int    `json:"request_log_retention_days" default:"7" name:"config.log_retention_days" category:"config.category.basic" desc:"config.log_retention_days_desc" validate:"required,min=0"`
 	RequestLogWriteIntervalMinutes int    `json:"request_log_write_interval_minutes" default:"1" name:"config.log_write_interval" category:"config.category.basic" desc:"config.log_write_interval_desc" validate:"required,min=0"`
 	EnableRequestBodyLogging       bool   `json:"enable_request_body_logging" default:"false" name:"config.enable_request_body_logging" category:"config.category.basic" desc:"config.enable_request_body_logging_desc"`
@@ -35,25 +39,50 @@ type SystemSettings struct {
 	ProxyURL              string `json:"proxy_url" name:"config.proxy_url" category:"config.category.request" desc:"config.proxy_url_desc"`
 
 	// 密钥配置
-	MaxRetries                   int `json:"max_retries" default:"3" name:"config.max_retries" category:"config.category.key" desc:"config.max_retries_desc" validate:"required,min=0"`
-	BlacklistThreshold           int `json:"blacklist_threshold" default:"3" name:"config.blacklist_threshold" category:"config.category.key" desc:"config.blacklist_threshold_desc" validate:"required,min=0"`
-	KeyValidationIntervalMinutes int `json:"key_validation_interval_minutes" default:"60" name:"config.key_validation_interval" category:"config.category.key" desc:"config.key_validation_interval_desc" validate:"required,min=1"`
-	KeyValidationConcurrency     int `json:"key_validation_concurrency" default:"10" name:"config.key_validation_concurrency" category:"config.category.key" desc:"config.key_validation_concurrency_desc" validate:"required,min=1"`
-	KeyValidationTimeoutSeconds  int `json:"key_validation_timeout_seconds" default:"20" name:"config.key_validation_timeout" category:"config.category.key" desc:"config.key_validation_timeout_desc" validate:"required,min=1"`
-
-	// For cache
-	ProxyKeysMap map[string]struct{} `json:"-"`
+	MaxRetries                         int    `json:"max_retries" default:"3" name:"config.max_retries" category:"config.category.key" desc:"config.max_retries_desc" validate:"required,min=0"`
+	BlacklistThreshold                 int    `json:"blacklist_threshold" default:"3" name:"config.blacklist_threshold" category:"config.category.key" desc:"config.blacklist_threshold_desc" validate:"required,min=0"`
+	KeyValidationIntervalMinutes       int    `json:"key_validation_interval_minutes" default:"60" name:"config.key_validation_interval" category:"config.category.key" desc:"config.key_validation_interval_desc" validate:"required,min=1"`
+	KeyValidationConcurrency           int    `json:"key_validation_concurrency" default:"10" name:"config.key_validation_concurrency" category:"config.category.key" desc:"config.key_validation_concurrency_desc" validate:"required,min=1"`
+	KeyValidationTimeoutSeconds        int    `json:"key_validation_timeout_seconds" default:"20" name:"config.key_validation_timeout" category:"config.category.key" desc:"config.key_validation_timeout_desc" validate:"required,min=1"`
+	BalanceProbe                       string `json:"balance_probe" default:"off" name:"config.balance_probe" category:"config.category.key" desc:"config.balance_probe_desc" validate:"required,oneof=off auto openai_billing deepseek openrouter"`
+	KeyValidationCron                  string `json:"key_validation_cron" name:"config.key_validation_cron" category:"config.category.key" desc:"config.key_validation_cron_desc" validate:"cron"`
+	KeyValidationJitterSeconds         int    `json:"key_validation_jitter_seconds" default:"0" name:"config.key_validation_jitter" category:"config.category.key" desc:"config.key_validation_jitter_desc" validate:"required,min=0"`
+	ActiveKeyValidationIntervalMinutes int    `json:"active_key_validation_interval_minutes" default:"0" name:"config.active_key_validation_interval" category:"config.category.key" desc:"config.active_key_validation_interval_desc" validate:"required,min=0"`
+	ActiveKeyValidationSamplePercent   int    `json:"active_key_validation_sample_percent" default:"100" name:"config.active_key_validation_sample_percent" category:"config.category.key" desc:"config.active_key_validation_sample_percent_desc" validate:"required,min=1,max=100"`
+	EnforceGlobalKeyUniqueness         bool   `json:"enforce_global_key_uniqueness" default:"false" name:"config.enforce_global_key_uniqueness" category:"config.category.key" desc:"config.enforce_global_key_uniqueness_desc"`
+
+	// 聚合分组
+	EnableAdaptiveWeights bool `json:"enable_adaptive_weights" default:"false" name:"config.enable_adaptive_weights" category:"config.category.aggregate" desc:"config.enable_adaptive_weights_desc"`
+	AdaptiveMinWeight     int  `json:"adaptive_min_weight" default:"1" name:"config.adaptive_min_weight" category:"config.category.aggregate" desc:"config.adaptive_min_weight_desc" validate:"required,min=1"`
+	AdaptiveMaxWeight     int  `json:"adaptive_max_weight" default:"1000" name:"config.adaptive_max_weight" category:"config.category.aggregate" desc:"config.adaptive_max_weight_desc" validate:"required,min=1"`
+
+	// 访问控制
+	ProxyIPAllowlist         string `json:"proxy_ip_allowlist" name:"config.proxy_ip_allowlist" category:"config.category.access" desc:"config.proxy_ip_allowlist_desc" validate:"ip_list"`
+	ProxyIPDenylist          string `json:"proxy_ip_denylist" name:"config.proxy_ip_denylist" category:"config.category.access" desc:"config.proxy_ip_denylist_desc" validate:"ip_list"`
+	AdminIPAllowlist         string `json:"admin_ip_allowlist" name:"config.admin_ip_allowlist" category:"config.category.access" desc:"config.admin_ip_allowlist_desc" validate:"ip_list"`
+	AdminIPDenylist          string `json:"admin_ip_denylist" name:"config.admin_ip_denylist" category:"config.category.access" desc:"config.admin_ip_denylist_desc" validate:"ip_list"`
+	AuthMaxFailures          int    `json:"auth_max_failures" default:"10" name:"config.auth_max_failures" category:"config.category.access" desc:"config.auth_max_failures_desc" validate:"required,min=0"`
+	AuthFailureWindowMinutes int    `json:"auth_failure_window_minutes" default:"15" name:"config.auth_failure_window" category:"config.category.access" desc:"config.auth_failure_window_desc" validate:"required,min=1"`
+	AuthBanMinutes           int    `json:"auth_ban_minutes" default:"30" name:"config.auth_ban_minutes" category:"config.category.access" desc:"config.auth_ban_minutes_desc" validate:"required,min=1"`
+
+	// 限流
+	RateLimitRPM               int `json:"rate_limit_rpm" default:"0" name:"config.rate_limit_rpm" category:"config.category.rate_limit" desc:"config.rate_limit_rpm_desc" validate:"required,min=0"`
+	RateLimitTPM               int `json:"rate_limit_tpm" default:"0" name:"config.rate_limit_tpm" category:"config.category.rate_limit" desc:"config.rate_limit_tpm_desc" validate:"required,min=0"`
+	RateLimitConcurrency       int `json:"rate_limit_concurrency" default:"0" name:"config.rate_limit_concurrency" category:"config.category.rate_limit" desc:"config.rate_limit_concurrency_desc" validate:"required,min=0"`
+	RequestQueueSize           int `json:"request_queue_size" default:"0" name:"config.request_queue_size" category:"config.category.rate_limit" desc:"config.request_queue_size_desc" validate:"required,min=0"`
+	RequestQueueTimeoutSeconds int `json:"request_queue_timeout_seconds" default:"30" name:"config.request_queue_timeout" category:"config.category.rate_limit" desc:"config.request_queue_timeout_desc" validate:"required,min=1"`
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
-	Port                    int    `json:"port"`
-	Host                    string `json:"host"`
-	IsMaster                bool   `json:"is_master"`
-	ReadTimeout             int    `json:"read_timeout"`
-	WriteTimeout            int    `json:"write_timeout"`
-	IdleTimeout             int    `json:"idle_timeout"`
-	GracefulShutdownTimeout int    `json:"graceful_shutdown_timeout"`
+	Port                    int      `json:"port"`
+	Host                    string   `json:"host"`
+	IsMaster                bool     `json:"is_master"`
+	ReadTimeout             int      `json:"read_timeout"`
+	WriteTimeout            int      `json:"write_timeout"`
+	IdleTimeout             int      `json:"idle_timeout"`
+	GracefulShutdownTimeout int      `json:"graceful_shutdown_timeout"`
+	TrustedProxies          []string `json:"trusted_proxies"`
 }
 
 // AuthConfig represents authentication configuration
@@ -61,6 +90,20 @@ type AuthConfig struct {
 	Key string `json:"key"`
 }
 
+// OIDCConfig represents OpenID Connect single sign-on configuration
+type OIDCConfig struct {
+	Enabled       bool              `json:"enabled"`
+	Issuer        string            `json:"issuer"`
+	ClientID      string            `json:"client_id"`
+	ClientSecret  string            `json:"-"`
+	RedirectURL   string            `json:"redirect_url"`
+	Scopes        []string          `json:"scopes"`
+	UsernameClaim string            `json:"username_claim"`
+	RoleClaim     string            `json:"role_claim"`
+	RoleMapping   map[string]string `json:"role_mapping"`
+	DefaultRole   string            `json:"default_role"`
+}
+
 // CORSConfig represents CORS configuration
 type CORSConfig struct {
 	Enabled          bool     `json:"enabled"`
@@ -88,6 +131,36 @@ type DatabaseConfig struct {
 	DSN string `json:"dsn"`
 }
 
+// DeclarativeConfig represents the configuration file the database is reconciled with on startup.
+type DeclarativeConfig struct {
+	File  string `json:"file"`
+	Prune bool   `json:"prune"`
+}
+
+// KeySourceConfig restricts where key sources may read keys from.
+// An empty Dir disables directory sources.
+type KeySourceConfig struct {
+	Dir                  string `json:"dir"`
+	AllowPrivateNetworks bool   `json:"allow_private_networks"`
+}
+
+// EncryptionConfig represents envelope encryption configuration.
+// An empty Provider keeps the single-key encryption derived from ENCRYPTION_KEY.
+type EncryptionConfig struct {
+	Provider              string `json:"provider"`
+	LocalKeyFile          string `json:"local_key_file"`
+	VaultAddr             string `json:"vault_addr"`
+	VaultToken            string `json:"-"`
+	VaultNamespace        string `json:"vault_namespace"`
+	VaultTransitMount     string `json:"vault_transit_mount"`
+	VaultTransitKey       string `json:"vault_transit_key"`
+	KMSURL                string `json:"kms_url"`
+	KMSToken              string `json:"-"`
+	KMSKeyID              string `json:"kms_key_id"`
+	DataKeyMaxUses        int    `json:"data_key_max_uses"`
+	RewrapIntervalMinutes int    `json:"rewrap_interval_minutes"`
+}
+
 type RetryError struct {
 	StatusCode         int    `json:"status_code"`
 	ErrorMessage       string `json:"error_message"`
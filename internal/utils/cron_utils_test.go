@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every minute", expr: "* * * * *"},
+		{name: "steps", expr: "*/15 */2 * * *"},
+		{name: "ranges and lists", expr: "0,30 9-17 * * 1-5"},
+		{name: "value with step", expr: "5/20 * * * *"},
+		{name: "sunday as 7", expr: "0 0 * * 7"},
+		{name: "macro", expr: "@daily"},
+		{name: "macro is case insensitive", expr: "@Hourly"},
+		{name: "surrounding space", expr: "  0 3 * * *  "},
+		{name: "too few fields", expr: "* * * *", wantErr: true},
+		{name: "too many fields", expr: "* * * * * *", wantErr: true},
+		{name: "minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "reversed range", expr: "0 17-9 * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "not a number", expr: "a * * * *", wantErr: true},
+		{name: "unknown macro", expr: "@often", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	base := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{name: "next minute", expr: "* * * * *", from: base, want: time.Date(2024, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{name: "next quarter hour", expr: "*/15 * * * *", from: base, want: time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{name: "later today", expr: "30 14 * * *", from: base, want: time.Date(2024, 1, 1, 14, 30, 0, 0, time.UTC)},
+		{name: "tomorrow", expr: "0 3 * * *", from: base, want: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{name: "strictly after the given time", expr: "7 10 * * *", from: base, want: time.Date(2024, 1, 2, 10, 7, 0, 0, time.UTC)},
+		{name: "next weekday", expr: "0 9 * * 5", from: base, want: time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", expr: "0 0 * * 7", from: base, want: time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{name: "next month", expr: "0 0 1 * *", from: base, want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", expr: "0 0 29 2 *", from: base, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "restricted day of month or week", expr: "0 0 15 * 3", from: base, want: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 31 2 *", from: base, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned error: %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", tt.from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{name: "weekday night", expr: "* 0-7 * * 1-5", at: time.Date(2024, 1, 2, 3, 45, 0, 0, time.UTC), want: true},
+		{name: "weekday day", expr: "* 0-7 * * 1-5", at: time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC), want: false},
+		{name: "weekend night", expr: "* 0-7 * * 1-5", at: time.Date(2024, 1, 6, 3, 45, 0, 0, time.UTC), want: false},
+		{name: "minute list", expr: "0,30 * * * *", at: time.Date(2024, 1, 2, 3, 30, 59, 0, time.UTC), want: true},
+		{name: "month", expr: "* * * 6 *", at: time.Date(2024, 1, 2, 3, 30, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned error: %v", tt.expr, err)
+			}
+			if got := schedule.Matches(tt.at); got != tt.want {
+				t.Errorf("Matches(%s) for %q = %v, want %v", tt.at, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
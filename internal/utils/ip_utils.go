@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// IPRules holds an allow list and a deny list of IP prefixes.
+// An address is allowed when it matches no deny entry and the allow list is empty or matches it.
+type IPRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ipRulesCache keeps compiled rules by their text, so request paths do not parse lists repeatedly.
+var ipRulesCache sync.Map
+
+// ParseIPList parses IPs and CIDRs separated by commas, spaces or newlines. A single IP is
+// treated as a /32 or /128 prefix.
+func ParseIPList(text string) ([]netip.Prefix, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+
+	prefixes := make([]netip.Prefix, 0, len(fields))
+	for _, field := range fields {
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR '%s'", field)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address '%s'", field)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// CompileIPRules returns the rules for the given allow and deny lists. Results are cached.
+func CompileIPRules(allow, deny string) (*IPRules, error) {
+	cacheKey := allow + "\x00" + deny
+	if cached, ok := ipRulesCache.Load(cacheKey); ok {
+		return cached.(*IPRules), nil
+	}
+
+	allowList, err := ParseIPList(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyList, err := ParseIPList(deny)
+	if err != nil {
+		return nil, err
+	}
+	rules := &IPRules{allow: allowList, deny: denyList}
+	ipRulesCache.Store(cacheKey, rules)
+	return rules, nil
+}
+
+// Empty reports whether the rules allow every address.
+func (r *IPRules) Empty() bool {
+	return r == nil || (len(r.allow) == 0 && len(r.deny) == 0)
+}
+
+// Allowed reports whether ip passes the rules. An unparsable ip only passes empty rules.
+func (r *IPRules) Allowed(ip string) bool {
+	if r.Empty() {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range r.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, prefix := range r.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
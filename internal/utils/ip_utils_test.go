@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseIPList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: []string{}},
+		{name: "single IPv4", input: "10.0.0.1", want: []string{"10.0.0.1/32"}},
+		{name: "single IPv6", input: "::1", want: []string{"::1/128"}},
+		{name: "IPv4-mapped IPv6 is unmapped", input: "::ffff:10.0.0.1", want: []string{"10.0.0.1/32"}},
+		{name: "CIDR is masked", input: "192.168.1.77/24", want: []string{"192.168.1.0/24"}},
+		{
+			name:  "mixed separators",
+			input: "10.0.0.0/8, 172.16.0.0/12;192.168.0.1\n fd00::/8\t::1",
+			want:  []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.1/32", "fd00::/8", "::1/128"},
+		},
+		{name: "invalid IP", input: "10.0.0.256", wantErr: true},
+		{name: "invalid CIDR", input: "10.0.0.0/33", wantErr: true},
+		{name: "hostname", input: "localhost", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIPList(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseIPList(%q) = %v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseIPList(%q) returned error: %v", tt.input, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseIPList(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for i, prefix := range got {
+				if prefix != netip.MustParsePrefix(tt.want[i]) {
+					t.Errorf("ParseIPList(%q)[%d] = %s, want %s", tt.input, i, prefix, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIPRulesAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow string
+		deny  string
+		ip    string
+		want  bool
+	}{
+		{name: "no rules allow everything", ip: "203.0.113.5", want: true},
+		{name: "no rules allow unparsable addresses", ip: "unknown", want: true},
+		{name: "allow list match", allow: "10.0.0.0/8", ip: "10.1.2.3", want: true},
+		{name: "allow list miss", allow: "10.0.0.0/8", ip: "11.1.2.3", want: false},
+		{name: "deny list match", deny: "10.0.0.0/8", ip: "10.1.2.3", want: false},
+		{name: "deny list miss", deny: "10.0.0.0/8", ip: "11.1.2.3", want: true},
+		{name: "deny wins over allow", allow: "10.0.0.0/8", deny: "10.1.0.0/16", ip: "10.1.2.3", want: false},
+		{name: "allowed outside the denied range", allow: "10.0.0.0/8", deny: "10.1.0.0/16", ip: "10.2.2.3", want: true},
+		{name: "IPv6 allow", allow: "2001:db8::/32", ip: "2001:db8::1", want: true},
+		{name: "IPv4-mapped client matches IPv4 rule", allow: "10.0.0.0/8", ip: "::ffff:10.0.0.1", want: true},
+		{name: "zoned IPv6 client", allow: "fe80::/10", ip: "fe80::1%eth0", want: true},
+		{name: "unparsable client with rules", allow: "10.0.0.0/8", ip: "unknown", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := CompileIPRules(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("CompileIPRules(%q, %q) returned error: %v", tt.allow, tt.deny, err)
+			}
+			if got := rules.Allowed(tt.ip); got != tt.want {
+				t.Errorf("Allowed(%q) with allow %q and deny %q = %v, want %v", tt.ip, tt.allow, tt.deny, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileIPRulesInvalid(t *testing.T) {
+	if _, err := CompileIPRules("10.0.0.0/8", "not-an-ip"); err == nil {
+		t.Fatal("CompileIPRules with an invalid deny list returned no error")
+	}
+	if _, err := CompileIPRules("10.0.0.0/99", ""); err == nil {
+		t.Fatal("CompileIPRules with an invalid allow list returned no error")
+	}
+}
+
+func TestCompileIPRulesCached(t *testing.T) {
+	first, err := CompileIPRules("10.0.0.0/8", "10.9.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CompileIPRules("10.0.0.0/8", "10.9.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("CompileIPRules did not reuse the cached rules")
+	}
+	if !(*IPRules)(nil).Empty() {
+		t.Error("nil rules should be empty")
+	}
+}
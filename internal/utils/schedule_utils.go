@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"gpt-load/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activeScheduleCache keeps parsed schedules by their JSON encoding, so the key pool can
+// check the schedule stored with a key on every selection.
+var activeScheduleCache sync.Map
+
+// locationCache keeps loaded timezones by name.
+var locationCache sync.Map
+
+// ParseActiveSchedule decodes and validates a schedule stored as JSON.
+// It returns nil for an empty value or a schedule without windows, which are always active.
+func ParseActiveSchedule(raw []byte) (*models.ActiveSchedule, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var schedule models.ActiveSchedule
+	if err := json.Unmarshal(raw, &schedule); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if len(schedule.Windows) == 0 {
+		return nil, nil
+	}
+	if err := ValidateActiveSchedule(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ValidateActiveSchedule checks the timezone and windows of a schedule. Each window needs
+// either a cron expression or a start and end time.
+func ValidateActiveSchedule(schedule *models.ActiveSchedule) error {
+	if _, err := loadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone '%s'", schedule.Timezone)
+	}
+	for i, window := range schedule.Windows {
+		if window.Cron != "" {
+			if window.Start != "" || window.End != "" || len(window.Days) > 0 {
+				return fmt.Errorf("window %d: a cron window cannot also set days or times", i+1)
+			}
+			if _, err := ParseCron(window.Cron); err != nil {
+				return fmt.Errorf("window %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		start, err := parseClock(window.Start)
+		if err != nil || start == 24*60 {
+			return fmt.Errorf("window %d: invalid start '%s'", i+1, window.Start)
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return fmt.Errorf("window %d: invalid end: %w", i+1, err)
+		}
+		if start == end {
+			return fmt.Errorf("window %d: start and end must differ", i+1)
+		}
+		for _, day := range window.Days {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("window %d: day %d is out of range 0-6", i+1, day)
+			}
+		}
+	}
+	return nil
+}
+
+// IsScheduleActive reports whether t falls in one of the windows of the schedule.
+// A nil schedule is always active.
+func IsScheduleActive(schedule *models.ActiveSchedule, t time.Time) bool {
+	if schedule == nil || len(schedule.Windows) == 0 {
+		return true
+	}
+	if loc, err := loadLocation(schedule.Timezone); err == nil {
+		t = t.In(loc)
+	}
+
+	for _, window := range schedule.Windows {
+		if window.Cron != "" {
+			if cron, err := ParseCron(window.Cron); err == nil && cron.Matches(t) {
+				return true
+			}
+			continue
+		}
+		if windowContains(window, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRawScheduleActive is IsScheduleActive for a schedule stored as JSON. Parsed schedules are
+// cached; an empty or invalid schedule counts as always active.
+func IsRawScheduleActive(raw string, t time.Time) bool {
+	if raw == "" {
+		return true
+	}
+	if cached, ok := activeScheduleCache.Load(raw); ok {
+		return IsScheduleActive(cached.(*models.ActiveSchedule), t)
+	}
+	schedule, err := ParseActiveSchedule([]byte(raw))
+	if err != nil {
+		return true
+	}
+	activeScheduleCache.Store(raw, schedule)
+	return IsScheduleActive(schedule, t)
+}
+
+// windowContains checks a time range window. A range that ends before it starts runs past
+// midnight, and its days are the days it starts on.
+func windowContains(window models.ActiveWindow, t time.Time) bool {
+	start, err1 := parseClock(window.Start)
+	end, err2 := parseClock(window.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	weekday := int(t.Weekday())
+
+	if start < end {
+		return minute >= start && minute < end && dayAllowed(window.Days, weekday)
+	}
+	if minute >= start {
+		return dayAllowed(window.Days, weekday)
+	}
+	return minute < end && dayAllowed(window.Days, (weekday+6)%7)
+}
+
+func dayAllowed(days []int, weekday int) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, day := range days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" into minutes after midnight. "24:00" is accepted as the end of the day.
+func parseClock(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		if value == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("'%s' is not a HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// loadLocation returns the named timezone, or the server's local time for an empty name.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	if cached, ok := locationCache.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
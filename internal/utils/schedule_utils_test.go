@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"gpt-load/internal/models"
+)
+
+func TestParseActiveSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty", raw: "", wantNil: true},
+		{name: "null", raw: "null", wantNil: true},
+		{name: "no windows", raw: `{"timezone":"UTC","windows":[]}`, wantNil: true},
+		{name: "time range", raw: `{"timezone":"UTC","windows":[{"days":[1,2],"start":"09:00","end":"17:00"}]}`},
+		{name: "end of day", raw: `{"windows":[{"start":"18:00","end":"24:00"}]}`},
+		{name: "cron", raw: `{"windows":[{"cron":"* 0-7 * * 1-5"}]}`},
+		{name: "invalid JSON", raw: `{"windows":`, wantErr: true},
+		{name: "unknown timezone", raw: `{"timezone":"Mars/Olympus","windows":[{"start":"09:00","end":"17:00"}]}`, wantErr: true},
+		{name: "cron with times", raw: `{"windows":[{"cron":"* * * * *","start":"09:00"}]}`, wantErr: true},
+		{name: "invalid cron", raw: `{"windows":[{"cron":"* * *"}]}`, wantErr: true},
+		{name: "start at end of day", raw: `{"windows":[{"start":"24:00","end":"01:00"}]}`, wantErr: true},
+		{name: "invalid end", raw: `{"windows":[{"start":"09:00","end":"9pm"}]}`, wantErr: true},
+		{name: "empty range", raw: `{"windows":[{"start":"09:00","end":"09:00"}]}`, wantErr: true},
+		{name: "day out of range", raw: `{"windows":[{"days":[7],"start":"09:00","end":"17:00"}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseActiveSchedule([]byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseActiveSchedule(%s) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && (schedule == nil) != tt.wantNil {
+				t.Errorf("ParseActiveSchedule(%s) = %v, want nil %v", tt.raw, schedule, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestIsScheduleActive(t *testing.T) {
+	weekdays := &models.ActiveSchedule{
+		Timezone: "UTC",
+		Windows:  []models.ActiveWindow{{Days: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "17:00"}},
+	}
+	overnight := &models.ActiveSchedule{
+		Timezone: "UTC",
+		Windows:  []models.ActiveWindow{{Days: []int{5}, Start: "22:00", End: "06:00"}},
+	}
+	shanghai := &models.ActiveSchedule{
+		Timezone: "Asia/Shanghai",
+		Windows:  []models.ActiveWindow{{Start: "09:00", End: "10:00"}},
+	}
+	combined := &models.ActiveSchedule{
+		Timezone: "UTC",
+		Windows: []models.ActiveWindow{
+			{Cron: "* 0-1 * * 0"},
+			{Start: "12:00", End: "13:00"},
+		},
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name     string
+		schedule *models.ActiveSchedule
+		at       time.Time
+		want     bool
+	}{
+		{name: "nil schedule", schedule: nil, at: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), want: true},
+		{name: "inside range", schedule: weekdays, at: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), want: true},
+		{name: "end is exclusive", schedule: weekdays, at: time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC), want: false},
+		{name: "wrong day", schedule: weekdays, at: time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC), want: false},
+		{name: "overnight on its start day", schedule: overnight, at: time.Date(2024, 1, 5, 23, 0, 0, 0, time.UTC), want: true},
+		{name: "overnight past midnight", schedule: overnight, at: time.Date(2024, 1, 6, 5, 59, 0, 0, time.UTC), want: true},
+		{name: "overnight after its end", schedule: overnight, at: time.Date(2024, 1, 6, 6, 0, 0, 0, time.UTC), want: false},
+		{name: "overnight past midnight of another day", schedule: overnight, at: time.Date(2024, 1, 5, 5, 0, 0, 0, time.UTC), want: false},
+		{name: "timezone applied", schedule: shanghai, at: time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC), want: true},
+		{name: "timezone outside", schedule: shanghai, at: time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC), want: false},
+		{name: "cron window", schedule: combined, at: time.Date(2024, 1, 7, 1, 59, 0, 0, time.UTC), want: true},
+		{name: "range window of combined", schedule: combined, at: time.Date(2024, 1, 3, 12, 30, 0, 0, time.UTC), want: true},
+		{name: "outside every window", schedule: combined, at: time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsScheduleActive(tt.schedule, tt.at); got != tt.want {
+				t.Errorf("IsScheduleActive at %s = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRawScheduleActive(t *testing.T) {
+	raw := `{"timezone":"UTC","windows":[{"start":"09:00","end":"17:00"}]}`
+	tests := []struct {
+		name string
+		raw  string
+		at   time.Time
+		want bool
+	}{
+		{name: "empty", raw: "", at: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), want: true},
+		{name: "invalid counts as active", raw: `{"windows":[{"start":"x"}]}`, at: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), want: true},
+		{name: "inside", raw: raw, at: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), want: true},
+		{name: "outside, from cache", raw: raw, at: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRawScheduleActive(tt.raw, tt.at); got != tt.want {
+				t.Errorf("IsRawScheduleActive(%s) at %s = %v, want %v", tt.raw, tt.at, got, tt.want)
+			}
+		})
+	}
+}
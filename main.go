@@ -41,6 +41,10 @@ func runCommand() {
 	switch command {
 	case "migrate-keys":
 		commands.RunMigrateKeys(args)
+	case "migrate-proxy-keys":
+		commands.RunMigrateProxyKeys(args)
+	case "config":
+		commands.RunConfig(args)
 	case "help", "-h", "--help":
 		printHelp()
 	default:
@@ -59,8 +63,10 @@ func printHelp() {
 	fmt.Println("  gpt-load <command> [args]   Execute a command")
 	fmt.Println()
 	fmt.Println("Available Commands:")
-	fmt.Println("  migrate-keys    Migrate encryption keys")
-	fmt.Println("  help            Display this help message")
+	fmt.Println("  migrate-keys         Migrate encryption keys")
+	fmt.Println("  migrate-proxy-keys   Convert plain-text proxy keys to hashes")
+	fmt.Println("  config               Export, diff or apply the declarative configuration")
+	fmt.Println("  help                 Display this help message")
 	fmt.Println()
 	fmt.Println("Use 'gpt-load <command> --help' for more information about a command.")
 }
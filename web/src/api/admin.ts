@@ -0,0 +1,63 @@
+import type { AdminPrincipal, AdminRole, AdminToken, AdminUser } from "@/types/models";
+import http from "@/utils/http";
+
+export interface AdminUserPayload {
+  username?: string;
+  password?: string;
+  role?: AdminRole;
+  enabled?: boolean;
+}
+
+export interface AdminTokenPayload {
+  name: string;
+  role: AdminRole;
+  expires_in_days?: number;
+}
+
+export const adminApi = {
+  // 获取当前登录身份
+  async getCurrentAdmin(): Promise<AdminPrincipal> {
+    const res = await http.get("/auth/me");
+    return res.data;
+  },
+
+  // 退出并撤销当前会话
+  logout(): Promise<void> {
+    return http.post("/auth/logout", undefined, { hideMessage: true });
+  },
+
+  // 管理员账号
+  async listUsers(): Promise<AdminUser[]> {
+    const res = await http.get("/admin-users");
+    return res.data || [];
+  },
+
+  async createUser(data: AdminUserPayload): Promise<AdminUser> {
+    const res = await http.post("/admin-users", data);
+    return res.data;
+  },
+
+  async updateUser(id: number, data: AdminUserPayload): Promise<AdminUser> {
+    const res = await http.put(`/admin-users/${id}`, data);
+    return res.data;
+  },
+
+  deleteUser(id: number): Promise<void> {
+    return http.delete(`/admin-users/${id}`);
+  },
+
+  // API 令牌
+  async listTokens(): Promise<AdminToken[]> {
+    const res = await http.get("/admin-tokens");
+    return res.data || [];
+  },
+
+  async createToken(data: AdminTokenPayload): Promise<AdminToken> {
+    const res = await http.post("/admin-tokens", data);
+    return res.data;
+  },
+
+  deleteToken(id: number): Promise<void> {
+    return http.delete(`/admin-tokens/${id}`);
+  },
+};
@@ -0,0 +1,44 @@
+import i18n from "@/locales";
+import { hasSsoSession } from "@/services/auth";
+import type { ApiResponse, AuditLogFilter, AuditLogsResponse } from "@/types/models";
+import http from "@/utils/http";
+
+export const auditApi = {
+  // 获取审计日志列表
+  getAuditLogs: (params: AuditLogFilter): Promise<ApiResponse<AuditLogsResponse>> => {
+    return http.get("/audit-logs", { params });
+  },
+
+  // 导出审计日志
+  exportAuditLogs: (params: Omit<AuditLogFilter, "page" | "page_size">) => {
+    const authKey = localStorage.getItem("authKey");
+    if (!authKey && !hasSsoSession()) {
+      window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
+      return;
+    }
+
+    const queryParams = new URLSearchParams(
+      Object.entries(params).reduce(
+        (acc, [key, value]) => {
+          if (value !== undefined && value !== null && value !== "") {
+            acc[key] = String(value);
+          }
+          return acc;
+        },
+        {} as Record<string, string>
+      )
+    );
+    if (authKey) {
+      queryParams.append("key", authKey);
+    }
+
+    const url = `${http.defaults.baseURL}/audit-logs/export?${queryParams.toString()}`;
+
+    const link = document.createElement("a");
+    link.href = url;
+    link.setAttribute("download", `audit-logs-${Date.now()}.csv`);
+    document.body.appendChild(link);
+    link.click();
+    document.body.removeChild(link);
+  },
+};
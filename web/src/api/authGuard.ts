@@ -0,0 +1,14 @@
+import type { ApiResponse, AuthGuardStatus } from "@/types/models";
+import http from "@/utils/http";
+
+export const authGuardApi = {
+  // 获取当前封禁列表及失败统计
+  getStatus: (): Promise<ApiResponse<AuthGuardStatus>> => {
+    return http.get("/auth-guard");
+  },
+
+  // 解除封禁
+  unban: (subject: string): Promise<ApiResponse<null>> => {
+    return http.delete("/auth-guard/bans", { params: { subject } });
+  },
+};
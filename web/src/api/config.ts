@@ -0,0 +1,50 @@
+import i18n from "@/locales";
+import { hasSsoSession } from "@/services/auth";
+import type { ConfigPlan } from "@/types/models";
+import http from "@/utils/http";
+
+export type ConfigFormat = "yaml" | "json";
+
+export const configApi = {
+  // 导出声明式配置，密钥仅以加密形式导出
+  exportConfig: (format: ConfigFormat = "yaml", includeKeys = false) => {
+    const authKey = localStorage.getItem("authKey");
+    if (!authKey && !hasSsoSession()) {
+      window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
+      return;
+    }
+
+    const queryParams = new URLSearchParams({ format });
+    if (includeKeys) {
+      queryParams.append("include_keys", "true");
+    }
+    if (authKey) {
+      queryParams.append("key", authKey);
+    }
+
+    const url = `${http.defaults.baseURL}/config/export?${queryParams.toString()}`;
+
+    const link = document.createElement("a");
+    link.href = url;
+    link.setAttribute("download", `gpt-load-config-${Date.now()}.${format}`);
+    document.body.appendChild(link);
+    link.click();
+    document.body.removeChild(link);
+  },
+
+  // 导入声明式配置，dryRun 时只返回变更列表
+  async importConfig(
+    content: string,
+    options: { dryRun?: boolean; prune?: boolean } = {}
+  ): Promise<ConfigPlan> {
+    const res = await http.post("/config/import", content, {
+      params: {
+        dry_run: options.dryRun ? "true" : undefined,
+        prune: options.prune ? "true" : undefined,
+      },
+      headers: { "Content-Type": "application/yaml" },
+      hideMessage: options.dryRun,
+    });
+    return res.data;
+  },
+};
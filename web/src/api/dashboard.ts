@@ -18,6 +18,16 @@ export const getDashboardChart = (groupId?: number) => {
   });
 };
 
+/**
+ * 获取仪表盘费用图表数据
+ * @param groupId 可选的分组ID
+ */
+export const getDashboardCostChart = (groupId?: number) => {
+  return http.get<ChartData>("/dashboard/cost-chart", {
+    params: groupId ? { groupId } : {},
+  });
+};
+
 /**
  * 获取用于筛选的分组列表
  */
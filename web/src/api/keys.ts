@@ -1,15 +1,32 @@
 import i18n from "@/locales";
+import { hasSsoSession } from "@/services/auth";
 import type {
+  ActiveSchedule,
   APIKey,
+  BudgetStatus,
+  DuplicateKeyReport,
   Group,
   GroupConfigOption,
+  GroupState,
   GroupStatsResponse,
+  KeyDetails,
+  KeySource,
   KeyStatus,
+  KeyTransferMode,
   ParentAggregateGroup,
+  RequestQueueStatus,
   TaskInfo,
 } from "@/types/models";
 import http from "@/utils/http";
 
+export type KeySourceParams = Pick<
+  KeySource,
+  "name" | "type" | "address" | "path" | "field" | "format" | "interval_minutes" | "missing_action"
+> & {
+  token?: string;
+  enabled?: boolean;
+};
+
 export const keysApi = {
   // 获取所有分组
   async getGroups(): Promise<Group[]> {
@@ -29,6 +46,21 @@ export const keysApi = {
     return res.data;
   },
 
+  // 修改分组状态，已在处理中的请求不受影响
+  async updateGroupState(
+    groupId: number,
+    state: GroupState,
+    message = "",
+    statusCode = 0
+  ): Promise<Group> {
+    const res = await http.put(`/groups/${groupId}/state`, {
+      state,
+      message,
+      status_code: statusCode,
+    });
+    return res.data;
+  },
+
   // 批量重排分组
   async reorderGroups(items: { id: number; sort: number }[]): Promise<void> {
     await http.put(
@@ -51,6 +83,18 @@ export const keysApi = {
     return res.data;
   },
 
+  // 获取分组预算使用情况
+  async getGroupBudgets(groupId: number): Promise<BudgetStatus[]> {
+    const res = await http.get(`/groups/${groupId}/budgets`);
+    return res.data || [];
+  },
+
+  // 获取分组请求队列状态
+  async getGroupQueue(groupId: number): Promise<RequestQueueStatus> {
+    const res = await http.get(`/groups/${groupId}/queue`);
+    return res.data;
+  },
+
   // 获取分组可配置参数
   async getGroupConfigOptions(): Promise<GroupConfigOption[]> {
     const res = await http.get("/groups/config-options");
@@ -85,6 +129,7 @@ export const keysApi = {
     page_size: number;
     key_value?: string;
     status?: KeyStatus;
+    tag?: string;
   }): Promise<{
     items: APIKey[];
     pagination: {
@@ -135,11 +180,105 @@ export const keysApi = {
     return res.data;
   },
 
+  // 从 HTTP(S) URL 导入密钥
+  async importKeysFromUrl(
+    group_id: number,
+    url: string,
+    token?: string,
+    format: "auto" | "txt" | "csv" | "json" = "auto"
+  ): Promise<TaskInfo> {
+    const res = await http.post(
+      "/keys/import-url",
+      { group_id, url, token, format },
+      { hideMessage: true }
+    );
+    return res.data;
+  },
+
+  // 获取分组的密钥来源
+  async listKeySources(groupId: number): Promise<KeySource[]> {
+    const res = await http.get(`/groups/${groupId}/key-sources`);
+    return res.data || [];
+  },
+
+  // 创建密钥来源，token 为空字符串时清除，不传时保持不变
+  async createKeySource(groupId: number, source: KeySourceParams): Promise<KeySource> {
+    const res = await http.post(`/groups/${groupId}/key-sources`, source);
+    return res.data;
+  },
+
+  async updateKeySource(sourceId: number, source: KeySourceParams): Promise<KeySource> {
+    const res = await http.put(`/key-sources/${sourceId}`, source);
+    return res.data;
+  },
+
+  async deleteKeySource(sourceId: number): Promise<void> {
+    await http.delete(`/key-sources/${sourceId}`);
+  },
+
+  // 立即同步密钥来源
+  async syncKeySource(sourceId: number): Promise<TaskInfo> {
+    const res = await http.post(`/key-sources/${sourceId}/sync`, {}, { hideMessage: true });
+    return res.data;
+  },
+
+  // 获取密钥详情
+  async getKeyDetails(keyId: number, hours?: number): Promise<KeyDetails> {
+    const res = await http.get(`/keys/${keyId}`, { params: { hours } });
+    return res.data;
+  },
+
   // 更新密钥备注
   async updateKeyNotes(keyId: number, notes: string): Promise<void> {
     await http.put(`/keys/${keyId}/notes`, { notes }, { hideMessage: true });
   },
 
+  // 更新密钥的标签、优先级、过期时间、RPM 限制和生效时间窗口（windows 为空时移除）
+  async updateKeyMetadata(
+    keyId: number,
+    metadata: {
+      tags?: Record<string, string>;
+      priority?: number;
+      expires_at?: string;
+      rpm_limit?: number;
+      active_schedule?: ActiveSchedule;
+    }
+  ): Promise<void> {
+    await http.put(`/keys/${keyId}/metadata`, metadata, { hideMessage: true });
+  },
+
+  // 批量编辑密钥标签
+  async updateKeyTags(params: {
+    group_id: number;
+    key_ids?: number[];
+    keys_text?: string;
+    add_tags?: Record<string, string>;
+    remove_tags?: string[];
+  }): Promise<{ updated_count: number }> {
+    const res = await http.put("/keys/tags", params);
+    return res.data;
+  },
+
+  // 将密钥移动或复制到其他分组，未指定 key_ids 和 keys_text 时按状态和标签筛选
+  async transferKeys(params: {
+    source_group_id: number;
+    target_group_id: number;
+    mode: KeyTransferMode;
+    key_ids?: number[];
+    keys_text?: string;
+    status?: KeyStatus;
+    tags?: string[];
+  }): Promise<TaskInfo> {
+    const res = await http.post("/keys/transfer", params, { hideMessage: true });
+    return res.data;
+  },
+
+  // 获取存在于多个分组中的重复密钥
+  async getDuplicateKeys(group_id?: number, limit?: number): Promise<DuplicateKeyReport> {
+    const res = await http.get("/keys/duplicates", { params: { group_id, limit } });
+    return res.data;
+  },
+
   // 测试密钥
   async testKeys(
     group_id: number,
@@ -228,27 +367,36 @@ export const keysApi = {
   },
 
   // 导出密钥
-  exportKeys(groupId: number, status: "all" | "active" | "invalid" = "all"): void {
+  exportKeys(
+    groupId: number,
+    status: "all" | "active" | "invalid" | "disabled" = "all",
+    format: "txt" | "csv" = "txt"
+  ): void {
     const authKey = localStorage.getItem("authKey");
-    if (!authKey) {
+    if (!authKey && !hasSsoSession()) {
       window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
       return;
     }
 
     const params = new URLSearchParams({
       group_id: groupId.toString(),
-      key: authKey,
     });
+    if (authKey) {
+      params.append("key", authKey);
+    }
 
     if (status !== "all") {
       params.append("status", status);
     }
+    if (format !== "txt") {
+      params.append("format", format);
+    }
 
     const url = `${http.defaults.baseURL}/keys/export?${params.toString()}`;
 
     const link = document.createElement("a");
     link.href = url;
-    link.setAttribute("download", `keys-group_${groupId}-${status}-${Date.now()}.txt`);
+    link.setAttribute("download", `keys-group_${groupId}-${status}-${Date.now()}.${format}`);
     document.body.appendChild(link);
     link.click();
     document.body.removeChild(link);
@@ -279,6 +427,28 @@ export const keysApi = {
     return res.data;
   },
 
+  // 获取最近的任务，可按分组名或运行状态过滤
+  async listTasks(params?: {
+    group_name?: string;
+    running?: boolean;
+    limit?: number;
+  }): Promise<TaskInfo[]> {
+    const res = await http.get("/tasks", { params });
+    return res.data || [];
+  },
+
+  // 获取单个任务的进度和结果
+  async getTask(taskId: string): Promise<TaskInfo> {
+    const res = await http.get(`/tasks/${taskId}`);
+    return res.data;
+  },
+
+  // 取消正在运行的任务
+  async cancelTask(taskId: string): Promise<TaskInfo> {
+    const res = await http.post(`/tasks/${taskId}/cancel`);
+    return res.data;
+  },
+
   // 获取聚合分组的子分组列表
   async getSubGroups(aggregateGroupId: number): Promise<import("@/types/models").SubGroupInfo[]> {
     const res = await http.get(`/groups/${aggregateGroupId}/sub-groups`);
@@ -288,7 +458,7 @@ export const keysApi = {
   // 为聚合分组添加子分组
   async addSubGroups(
     aggregateGroupId: number,
-    subGroups: { group_id: number; weight: number }[]
+    subGroups: { group_id: number; weight: number; priority?: number; models?: string[] }[]
   ): Promise<void> {
     await http.post(`/groups/${aggregateGroupId}/sub-groups`, {
       sub_groups: subGroups,
@@ -306,6 +476,39 @@ export const keysApi = {
     });
   },
 
+  // 更新子分组优先级
+  async updateSubGroupPriority(
+    aggregateGroupId: number,
+    subGroupId: number,
+    priority: number
+  ): Promise<void> {
+    await http.put(`/groups/${aggregateGroupId}/sub-groups/${subGroupId}/priority`, {
+      priority,
+    });
+  },
+
+  // 更新子分组支持的模型
+  async updateSubGroupModels(
+    aggregateGroupId: number,
+    subGroupId: number,
+    models: string[]
+  ): Promise<void> {
+    await http.put(`/groups/${aggregateGroupId}/sub-groups/${subGroupId}/models`, {
+      models,
+    });
+  },
+
+  // 更新子分组的生效时间窗口，windows 为空时始终生效
+  async updateSubGroupSchedule(
+    aggregateGroupId: number,
+    subGroupId: number,
+    activeSchedule: ActiveSchedule
+  ): Promise<void> {
+    await http.put(`/groups/${aggregateGroupId}/sub-groups/${subGroupId}/schedule`, {
+      active_schedule: activeSchedule,
+    });
+  },
+
   // 删除子分组
   async deleteSubGroup(aggregateGroupId: number, subGroupId: number): Promise<void> {
     await http.delete(`/groups/${aggregateGroupId}/sub-groups/${subGroupId}`);
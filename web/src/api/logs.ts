@@ -1,4 +1,5 @@
 import i18n from "@/locales";
+import { hasSsoSession } from "@/services/auth";
 import type { ApiResponse, Group, LogFilter, LogsResponse } from "@/types/models";
 import http from "@/utils/http";
 
@@ -16,7 +17,7 @@ export const logApi = {
   // 导出日志
   exportLogs: (params: Omit<LogFilter, "page" | "page_size">) => {
     const authKey = localStorage.getItem("authKey");
-    if (!authKey) {
+    if (!authKey && !hasSsoSession()) {
       window.$message.error(i18n.global.t("auth.noAuthKeyFound"));
       return;
     }
@@ -32,7 +33,9 @@ export const logApi = {
         {} as Record<string, string>
       )
     );
-    queryParams.append("key", authKey);
+    if (authKey) {
+      queryParams.append("key", authKey);
+    }
 
     const url = `${http.defaults.baseURL}/logs/export?${queryParams.toString()}`;
 
@@ -0,0 +1,51 @@
+import type { ProxyKey, ProxyKeyCreated } from "@/types/models";
+import http from "@/utils/http";
+
+export interface ProxyKeyPayload {
+  group_id: number;
+  name?: string;
+  key?: string; // 为空时由服务端生成
+  allowed_ips?: string;
+  denied_ips?: string;
+  rate_limit_rpm?: number;
+  rate_limit_tpm?: number;
+  rate_limit_concurrency?: number;
+}
+
+export interface ProxyKeyUpdatePayload {
+  name?: string;
+  allowed_ips?: string;
+  denied_ips?: string;
+  rate_limit_rpm?: number;
+  rate_limit_tpm?: number;
+  rate_limit_concurrency?: number;
+}
+
+export const proxyKeysApi = {
+  // 获取分组的代理密钥，group_id 为 0 时返回全局代理密钥
+  async list(groupId = 0): Promise<ProxyKey[]> {
+    const res = await http.get("/proxy-keys", { params: { group_id: groupId } });
+    return res.data || [];
+  },
+
+  async create(data: ProxyKeyPayload): Promise<ProxyKeyCreated> {
+    const res = await http.post("/proxy-keys", data);
+    return res.data;
+  },
+
+  // 修改名称或 IP 规则
+  async update(id: number, data: ProxyKeyUpdatePayload): Promise<ProxyKey> {
+    const res = await http.put(`/proxy-keys/${id}`, data);
+    return res.data;
+  },
+
+  // 轮换代理密钥，旧密钥在宽限期内仍然有效
+  async rotate(id: number, graceMinutes?: number): Promise<ProxyKeyCreated> {
+    const res = await http.post(`/proxy-keys/${id}/rotate`, { grace_minutes: graceMinutes });
+    return res.data;
+  },
+
+  delete(id: number): Promise<void> {
+    return http.delete(`/proxy-keys/${id}`);
+  },
+};
@@ -68,6 +68,11 @@ export default {
     loginButton: "Login",
     loginSuccess: "Login successful",
     authKeyRequired: "Please enter auth key",
+    or: "or",
+    ssoButton: "Sign in with SSO",
+    ssoFailed: "SSO login failed, please try again",
+    ssoDenied: "Your account has no access to this console",
+    ssoUnavailable: "SSO login is not available",
   },
   nav: {
     dashboard: "Dashboard",
@@ -162,6 +167,7 @@ export default {
     blacklistCount: "Blacklist Count",
     valid: "Valid",
     invalid: "Invalid",
+    disabled: "Disabled",
     checking: "Checking",
     unchecked: "Unchecked",
     addToBlacklist: "Add to Blacklist",
@@ -322,6 +328,7 @@ export default {
     proxyKeysTooltip:
       "Group-specific proxy keys for accessing this group's proxy endpoint. Separate multiple keys with commas.",
     proxyKeysCopied: "Proxy keys copied to clipboard",
+    proxyKeysHashed: "{count} proxy keys (stored as hashes, shown only when created)",
     multiKeysPlaceholder: "Separate multiple keys with commas",
     descriptionTooltip:
       "Detailed description of the group to help team members understand its purpose and features. Supports multi-line text",
@@ -405,10 +412,12 @@ export default {
     restore: "Restore",
     requestsShort: "RQ",
     failuresShort: "FL",
+    balanceShort: "BAL",
     testShort: "Go",
     restoreShort: "↻",
     validShort: "OK",
     invalidShort: "NG",
+    disabledShort: "OFF",
     testKey: "Test Key",
     totalRecords: "Total {total} records",
     recordsPerPage: "{count} per page",
@@ -429,7 +438,7 @@ export default {
     uploadFile: "Upload File",
     manualInput: "Manual Input",
     clickOrDragFile: "Click to upload",
-    onlyTxtFileSupported: "Only .txt files are supported",
+    supportedKeyFiles: "Only .txt, .csv and .json files are supported",
     fileImportedSuccessfully: "File imported successfully",
     fileReadError: "Failed to read file",
   },
@@ -590,10 +599,20 @@ export default {
     validatingKeys: "Validating keys for group [{groupName}]",
     importingKeys: "Importing keys to group [{groupName}]",
     deletingKeys: "Deleting keys from group [{groupName}]",
+    syncingKeys: "Syncing keys of group [{groupName}] from its source",
+    transferringKeys: "Transferring keys of group [{groupName}]",
+    rotatingEncryptionKey: "Re-encrypting keys with the new encryption key",
     validationCompleted:
       "Key validation completed, processed {total} keys, {valid} successful, {invalid} failed. Note: Failed validations do not immediately blacklist keys - failure count must reach threshold to blacklist.",
     importCompleted: "Key import completed, added {added} keys, ignored {ignored}.",
     deleteCompleted: "Key deletion completed, deleted {deleted} keys, ignored {ignored}.",
+    cancelled: "Task cancelled, changes made before cancelling are kept.",
+    cancelling: "Cancelling",
+    syncCompleted: "Key sync completed, added {added}, disabled {disabled}, re-enabled {enabled}, removed {removed}.",
+    moveCompleted: "Key move completed, moved {transferred} keys to group [{target}] and skipped {duplicates} keys already in it.",
+    copyCompleted: "Key copy completed, copied {transferred} keys to group [{target}] and skipped {duplicates} keys already in it.",
+    encryptionKeyRotationCompleted:
+      "Encryption key rotation completed, re-encrypted {processed} records, {failed} failed. Set ENCRYPTION_KEY to the new key before the next deployment.",
   },
   theme: {
     auto: "Auto Mode",
@@ -68,6 +68,11 @@ export default {
     loginButton: "ログイン",
     loginSuccess: "ログイン成功",
     authKeyRequired: "認証キーを入力してください",
+    or: "または",
+    ssoButton: "SSO でログイン",
+    ssoFailed: "SSO ログインに失敗しました。もう一度お試しください",
+    ssoDenied: "このアカウントにはコンソールへのアクセス権がありません",
+    ssoUnavailable: "SSO ログインは現在利用できません",
   },
   nav: {
     dashboard: "ダッシュボード",
@@ -161,6 +166,7 @@ export default {
     blacklistCount: "ブラックリスト回数",
     valid: "有効",
     invalid: "無効",
+    disabled: "無効化",
     checking: "チェック中",
     unchecked: "未チェック",
     addToBlacklist: "ブラックリストに追加",
@@ -321,6 +327,7 @@ export default {
     proxyKeysTooltip:
       "このグループのプロキシエンドポイントにアクセスするためのグループ固有のプロキシキー。複数のキーはカンマで区切ってください。",
     proxyKeysCopied: "プロキシキーがクリップボードにコピーされました",
+    proxyKeysHashed: "{count} 個のプロキシキー（ハッシュとして保存、作成時のみ表示）",
     multiKeysPlaceholder: "複数のキーはカンマで区切ってください",
     descriptionTooltip:
       "チームメンバーがその目的と特徴を理解できるようにするグループの詳細説明。複数行テキストをサポート",
@@ -405,10 +412,12 @@ export default {
     restore: "復元",
     requestsShort: "要求",
     failuresShort: "失敗",
+    balanceShort: "残高",
     testShort: "試験",
     restoreShort: "復元",
     validShort: "有効",
     invalidShort: "無効",
+    disabledShort: "停止",
     testKey: "キーをテスト",
     totalRecords: "合計 {total} 件",
     recordsPerPage: "{count}件/ページ",
@@ -429,7 +438,7 @@ export default {
     uploadFile: "ファイルをアップロード",
     manualInput: "手動入力",
     clickOrDragFile: "アップロードをクリック",
-    onlyTxtFileSupported: ".txt ファイルのみサポート",
+    supportedKeyFiles: ".txt、.csv、.json ファイルのみサポート",
     fileImportedSuccessfully: "ファイルのインポートに成功しました",
     fileReadError: "ファイルの読み取りに失敗しました",
   },
@@ -591,10 +600,20 @@ export default {
     validatingKeys: "グループ [{groupName}] のキーを検証中",
     importingKeys: "グループ [{groupName}] にキーをインポート中",
     deletingKeys: "グループ [{groupName}] からキーを削除中",
+    syncingKeys: "ソースからグループ [{groupName}] のキーを同期中",
+    transferringKeys: "グループ [{groupName}] のキーを転送しています",
+    rotatingEncryptionKey: "新しい暗号化キーで再暗号化中",
     validationCompleted:
       "キー検証完了、{total}個のキーを処理、{valid}個成功、{invalid}個失敗。注意：検証失敗でもすぐにブラックリストに追加されるわけではありません。失敗回数が闾値に達する必要があります。",
     importCompleted: "キーインポート完了、{added}個追加、{ignored}個無視。",
     deleteCompleted: "キー削除完了、{deleted}個削除、{ignored}個無視。",
+    cancelled: "タスクはキャンセルされました。キャンセル前の変更は保持されます。",
+    cancelling: "キャンセル中",
+    syncCompleted: "キー同期完了、{added}個追加、{disabled}個無効化、{enabled}個再有効化、{removed}個削除。",
+    moveCompleted: "キーの移動が完了しました。{transferred} 個のキーをグループ [{target}] に移動し、既に存在する {duplicates} 個のキーをスキップしました。",
+    copyCompleted: "キーのコピーが完了しました。{transferred} 個のキーをグループ [{target}] にコピーし、既に存在する {duplicates} 個のキーをスキップしました。",
+    encryptionKeyRotationCompleted:
+      "暗号化キーのローテーション完了、{processed}件を再暗号化、{failed}件失敗。次回のデプロイ前に ENCRYPTION_KEY を新しいキーに設定してください。",
   },
   theme: {
     auto: "自動モード",
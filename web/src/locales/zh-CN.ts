@@ -68,6 +68,11 @@ export default {
     loginButton: "登录",
     loginSuccess: "登录成功",
     authKeyRequired: "请输入授权密钥",
+    or: "或",
+    ssoButton: "使用 SSO 登录",
+    ssoFailed: "SSO 登录失败，请重试",
+    ssoDenied: "您的账号没有访问此控制台的权限",
+    ssoUnavailable: "SSO 登录当前不可用",
   },
   nav: {
     dashboard: "仪表盘",
@@ -160,6 +165,7 @@ export default {
     blacklistCount: "黑名单次数",
     valid: "有效",
     invalid: "无效",
+    disabled: "已禁用",
     checking: "检查中",
     unchecked: "未检查",
     addToBlacklist: "加入黑名单",
@@ -315,6 +321,7 @@ export default {
     optionalCustomValidationPath: "可选，自定义用于验证key的API路径",
     proxyKeysTooltip: "分组专用代理密钥，用于访问此分组的代理端点。多个密钥请用逗号分隔。",
     proxyKeysCopied: "代理密钥已复制到剪贴板",
+    proxyKeysHashed: "{count} 个代理密钥（以哈希形式保存，仅在创建时显示）",
     multiKeysPlaceholder: "多个密钥请用英文逗号 , 分隔",
     descriptionTooltip: "分组的详细说明，帮助团队成员了解该分组的用途和特点。支持多行文本",
     upstreamTooltip: "API服务器的完整URL地址。多个上游可以实现负载均衡和故障转移，提高服务可用性",
@@ -389,10 +396,12 @@ export default {
     restore: "恢复",
     requestsShort: "请求",
     failuresShort: "失败",
+    balanceShort: "余额",
     testShort: "测试",
     restoreShort: "恢复",
     validShort: "有效",
     invalidShort: "无效",
+    disabledShort: "禁用",
     testKey: "测试密钥",
     totalRecords: "共 {total} 条记录",
     recordsPerPage: "{count}条/页",
@@ -413,7 +422,7 @@ export default {
     uploadFile: "上传文件",
     manualInput: "手动输入",
     clickOrDragFile: "点击上传",
-    onlyTxtFileSupported: "仅支持 .txt 文件",
+    supportedKeyFiles: "仅支持 .txt、.csv 和 .json 文件",
     fileImportedSuccessfully: "文件导入成功",
     fileReadError: "文件读取失败",
   },
@@ -573,10 +582,20 @@ export default {
     validatingKeys: "正在验证分组 [{groupName}] 的密钥",
     importingKeys: "正在向分组 [{groupName}] 导入密钥",
     deletingKeys: "正在删除分组 [{groupName}] 的密钥",
+    syncingKeys: "正在从来源同步分组 [{groupName}] 的密钥",
+    transferringKeys: "正在转移分组 [{groupName}] 的密钥",
+    rotatingEncryptionKey: "正在使用新的加密密钥重新加密",
     validationCompleted:
       "密钥验证完成，处理了 {total} 个密钥，其中 {valid} 个成功，{invalid} 个失败。请注意：验证失败并不一定拉黑该密钥，需要失败次数达到阈值才会拉黑。",
     importCompleted: "密钥导入完成，成功添加 {added} 个密钥，忽略了 {ignored} 个。",
     deleteCompleted: "密钥删除完成，成功删除 {deleted} 个密钥，忽略了 {ignored} 个。",
+    cancelled: "任务已取消，取消前已完成的更改会保留。",
+    cancelling: "正在取消",
+    syncCompleted: "密钥同步完成，新增 {added} 个，禁用 {disabled} 个，重新启用 {enabled} 个，移除 {removed} 个。",
+    moveCompleted: "密钥移动完成，已将 {transferred} 个密钥移动到分组 [{target}]，跳过了 {duplicates} 个目标分组中已存在的密钥。",
+    copyCompleted: "密钥复制完成，已将 {transferred} 个密钥复制到分组 [{target}]，跳过了 {duplicates} 个目标分组中已存在的密钥。",
+    encryptionKeyRotationCompleted:
+      "加密密钥轮换完成，已重新加密 {processed} 条记录，失败 {failed} 条。请在下次部署前将 ENCRYPTION_KEY 设置为新密钥。",
   },
   theme: {
     auto: "自动模式",
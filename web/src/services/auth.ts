@@ -2,6 +2,10 @@ import http from "@/utils/http";
 import { useState } from "@/utils/state";
 
 const AUTH_KEY = "authKey";
+// SSO 登录的会话保存在 HttpOnly Cookie 中，前端只记录登录方式
+const AUTH_MODE = "authMode";
+
+export const hasSsoSession = (): boolean => localStorage.getItem(AUTH_MODE) === "sso";
 
 export const useAuthKey = () => {
   return useState<string | null>(AUTH_KEY, () => null);
@@ -10,11 +14,14 @@ export const useAuthKey = () => {
 export function useAuthService() {
   const authKey = useAuthKey();
 
-  const login = async (key: string): Promise<boolean> => {
+  // 支持 AUTH_KEY 登录，或传入 password 时以用户名密码登录，登录成功后保存会话令牌
+  const login = async (key: string, password?: string): Promise<boolean> => {
     try {
-      await http.post("/auth/login", { auth_key: key });
-      localStorage.setItem(AUTH_KEY, key);
-      authKey.value = key;
+      const payload = password ? { username: key, password } : { auth_key: key };
+      const res = (await http.post("/auth/login", payload)) as { token?: string };
+      const token = res.token || key;
+      localStorage.setItem(AUTH_KEY, token);
+      authKey.value = token;
       return true;
     } catch (_error) {
       // 错误已记录
@@ -22,13 +29,30 @@ export function useAuthService() {
     }
   };
 
+  // 跳转到身份提供方进行 SSO 登录
+  const loginWithSso = (): void => {
+    window.location.href = `${http.defaults.baseURL}/auth/oidc/login`;
+  };
+
+  // SSO 回调后通过 Cookie 校验会话
+  const completeSsoLogin = async (): Promise<boolean> => {
+    try {
+      await http.get("/auth/me");
+      localStorage.setItem(AUTH_MODE, "sso");
+      return true;
+    } catch (_error) {
+      return false;
+    }
+  };
+
   const logout = (): void => {
     localStorage.removeItem(AUTH_KEY);
+    localStorage.removeItem(AUTH_MODE);
     authKey.value = null;
   };
 
   const checkLogin = (): boolean => {
-    if (authKey.value) {
+    if (authKey.value || hasSsoSession()) {
       return true;
     }
 
@@ -41,6 +65,8 @@ export function useAuthService() {
 
   return {
     login,
+    loginWithSso,
+    completeSsoLogin,
     logout,
     checkLogin,
   };
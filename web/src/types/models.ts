@@ -6,20 +6,49 @@ export interface ApiResponse<T> {
 }
 
 // 密钥状态
-export type KeyStatus = "active" | "invalid" | undefined;
+export type KeyStatus = "active" | "invalid" | "disabled" | undefined;
 
 // 分组类型
 export type GroupType = "standard" | "aggregate";
 
+// 分组状态，只有 enabled 状态的分组接受新的代理请求
+export type GroupState = "enabled" | "draining" | "disabled" | "maintenance";
+
 // 渠道类型
 export type ChannelType = "openai" | "openai-response" | "gemini" | "anthropic";
 
+// 生效时间窗口：cron 表达式匹配的每一分钟，或按星期几的时间段（HH:MM，结束早于开始时跨越午夜）
+export interface ActiveWindow {
+  cron?: string;
+  days?: number[]; // 0（周日）到 6，为空表示每天
+  start?: string;
+  end?: string;
+}
+
+// 生效时间计划，timezone 为空时使用服务器时间，没有时间窗口时始终生效
+export interface ActiveSchedule {
+  timezone?: string;
+  windows: ActiveWindow[];
+}
+
 // 数据模型定义
 export interface APIKey {
   id: number;
   group_id: number;
   key_value: string;
   notes?: string;
+  tags?: Record<string, string> | null;
+  priority: number;
+  expires_at?: string | null;
+  rpm_limit: number;
+  active_schedule?: ActiveSchedule | null;
+  balance?: number | null;
+  balance_currency?: string;
+  rate_tier?: string;
+  balance_checked_at?: string | null;
+  last_validated_at?: string | null;
+  last_validation_error?: string;
+  source_id?: number | null;
   status: KeyStatus;
   request_count: number;
   failure_count: number;
@@ -39,6 +68,87 @@ export interface HeaderRule {
   action: "set" | "remove";
 }
 
+// 分组预算规则，限额为 0 表示不启用
+export interface BudgetRule {
+  period: "daily" | "monthly";
+  metric: "requests" | "tokens" | "cost";
+  soft_limit: number;
+  hard_limit: number;
+}
+
+// 分组预算使用情况
+export interface BudgetStatus extends BudgetRule {
+  usage: number;
+  soft_reached: boolean;
+  hard_reached: boolean;
+  reset_at: string;
+}
+
+// 分组请求队列状态，计数在所有节点间共享
+export interface RequestQueueStatus {
+  enabled: boolean;
+  max_size: number;
+  max_wait_seconds: number;
+  depth: number;
+  queued: number;
+  admitted: number;
+  timed_out: number;
+  rejected: number;
+  canceled: number;
+  avg_wait_ms: number;
+  max_wait_ms: number;
+}
+
+// 密钥每小时统计，包含重试请求
+export interface KeyHourlyStat {
+  time: string;
+  key_id: number;
+  group_id: number;
+  success_count: number;
+  failure_count: number;
+  input_tokens: number;
+  output_tokens: number;
+  cost: number;
+  last_status_code: number;
+  last_error: string;
+  last_error_at?: string | null;
+}
+
+// 密钥详情：使用历史、最近错误及所在分组
+export interface KeyDetails {
+  key: APIKey;
+  hours: number;
+  summary: {
+    success_count: number;
+    failure_count: number;
+    failure_rate: number;
+    input_tokens: number;
+    output_tokens: number;
+    cost: number;
+    last_status_code: number;
+    last_error: string;
+    last_error_at?: string | null;
+  };
+  history: KeyHourlyStat[];
+  recent_errors: {
+    id: string;
+    timestamp: string;
+    group_name: string;
+    model: string;
+    status_code: number;
+    request_type: string;
+    error_message: string;
+  }[];
+  groups: {
+    group_id: number;
+    group_name: string;
+    display_name: string;
+    key_id: number;
+    key_status: KeyStatus;
+    aggregate_groups: ParentAggregateGroup[];
+  }[];
+}
+
 // 子分组配置（创建/更新时使用）
 export interface SubGroupConfig {
   group_id: number;
@@ -49,6 +159,9 @@ export interface SubGroupConfig {
 export interface SubGroupInfo {
   group: Group;
   weight: number;
+  priority: number; // 优先级，数值越小越优先
+  models: string[] | null; // 声明支持的模型，为空表示支持全部
+  active_schedule: ActiveSchedule | null; // 生效时间窗口，为空表示始终生效
   total_keys: number;
   active_keys: number;
   invalid_keys: number;
@@ -79,7 +192,12 @@ export interface Group {
   model_redirect_rules: Record<string, string>;
   model_redirect_strict: boolean;
   header_rules?: HeaderRule[];
-  proxy_keys: string;
+  budgets?: BudgetRule[];
+  state?: GroupState;
+  state_message?: string; // 分组未启用时返回给新请求的错误信息
+  state_status_code?: number; // 分组未启用时返回的 HTTP 状态码，0 表示 503
+  proxy_keys: string; // 仅用于提交新增的代理密钥，服务端不回显
+  proxy_key_count?: number;
   group_type?: GroupType;
   sub_groups?: SubGroupInfo[]; // 子分组列表（仅聚合分组）
   sub_group_ids?: number[]; // 子分组ID列表
@@ -116,7 +234,13 @@ export interface RequestStats {
   failure_rate: number;
 }
 
-export type TaskType = "KEY_VALIDATION" | "KEY_IMPORT" | "KEY_DELETE";
+export type TaskType =
+  | "KEY_VALIDATION"
+  | "KEY_IMPORT"
+  | "KEY_DELETE"
+  | "KEY_SYNC"
+  | "KEY_TRANSFER"
+  | "ENCRYPTION_KEY_ROTATION";
 
 export interface KeyValidationResult {
   invalid_keys: number;
@@ -129,20 +253,96 @@ export interface KeyImportResult {
   ignored_count: number;
 }
 
+export type KeyTransferMode = "move" | "copy";
+
+export interface KeyTransferResult {
+  mode: KeyTransferMode;
+  target_group: string;
+  selected_count: number;
+  transferred_count: number;
+  duplicate_count: number;
+}
+
+export interface DuplicateKeyEntry {
+  key_id: number;
+  group_id: number;
+  group_name: string;
+  status: KeyStatus;
+  request_count: number;
+  last_used_at?: string;
+}
+
+export interface DuplicateKeyGroup {
+  key_hash: string;
+  masked_key: string;
+  keys: DuplicateKeyEntry[];
+}
+
+export interface DuplicateKeyReport {
+  total: number;
+  duplicates: DuplicateKeyGroup[];
+}
+
 export interface KeyDeleteResult {
   deleted_count: number;
   ignored_count: number;
 }
 
+export interface KeySyncResult {
+  fetched: number;
+  added: number;
+  ignored: number;
+  disabled: number;
+  enabled: number;
+  removed: number;
+}
+
+// 密钥来源，定期从 URL、Vault 或本地目录同步分组的密钥
+export interface KeySource {
+  id: number;
+  group_id: number;
+  name: string;
+  type: "url" | "vault" | "directory";
+  address: string;
+  path?: string;
+  field?: string;
+  has_token: boolean;
+  format: "auto" | "txt" | "csv" | "json";
+  interval_minutes: number;
+  missing_action: "keep" | "disable" | "remove";
+  enabled: boolean;
+  last_sync_at?: string | null;
+  last_sync_error?: string;
+  last_sync_result?: KeySyncResult | null;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface EncryptionKeyRotationResult {
+  processed: number;
+  failed: number;
+}
+
+export type TaskState = "running" | "completed" | "failed" | "cancelled";
+
 export interface TaskInfo {
+  id?: string;
   task_type: TaskType;
+  state?: TaskState;
   is_running: boolean;
+  cancelable?: boolean;
+  cancel_requested?: boolean;
   group_name?: string;
   processed?: number;
   total?: number;
   started_at?: string;
   finished_at?: string;
-  result?: KeyValidationResult | KeyImportResult | KeyDeleteResult;
+  result?:
+    | KeyValidationResult
+    | KeyImportResult
+    | KeyDeleteResult
+    | KeySyncResult
+    | EncryptionKeyRotationResult;
   error?: string;
 }
 
@@ -167,6 +367,10 @@ export interface RequestLog {
   upstream_addr: string;
   is_stream: boolean;
   request_body?: string;
+  input_tokens: number;
+  output_tokens: number;
+  cached_input_tokens: number;
+  cost: number;
 }
 
 export interface Pagination {
@@ -232,6 +436,7 @@ export interface DashboardStatsResponse {
   rpm: StatCard;
   request_count: StatCard;
   error_rate: StatCard;
+  cost: StatCard;
   security_warnings: SecurityWarning[];
 }
 
@@ -247,3 +452,156 @@ export interface ChartData {
   labels: string[];
   datasets: ChartDataset[];
 }
+
+// 模型价格（每百万 token）
+export interface ModelPrice {
+  id: number;
+  channel_type: string; // 为空表示适用于所有渠道
+  model_pattern: string;
+  input_price: number;
+  output_price: number;
+  cached_input_price: number;
+  created_at: string;
+  updated_at: string;
+}
+
+// 代理密钥，服务端只保存哈希
+export interface ProxyKey {
+  id: number;
+  group_id: number; // 0 表示全局代理密钥
+  name: string;
+  key_preview: string;
+  allowed_ips: string; // 逗号分隔的 IP/CIDR，空表示不限制
+  denied_ips: string;
+  rate_limit_rpm: number; // 0 表示不限制
+  rate_limit_tpm: number;
+  rate_limit_concurrency: number;
+  expires_at: string | null;
+  created_at: string;
+}
+
+// 创建或轮换后返回的代理密钥，key 仅返回这一次
+export interface ProxyKeyCreated extends ProxyKey {
+  key: string;
+}
+
+// 管理员角色
+export type AdminRole = "owner" | "operator" | "viewer";
+
+// 管理员账号
+export interface AdminUser {
+  id: number;
+  username: string;
+  role: AdminRole;
+  enabled: boolean;
+  oidc_issuer?: string;
+  oidc_subject?: string;
+  last_login_at: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+// 管理接口 API 令牌，token 仅在创建时返回
+export interface AdminToken {
+  id: number;
+  user_id: number;
+  name: string;
+  token_prefix: string;
+  role: AdminRole;
+  expires_at: string | null;
+  last_used_at: string | null;
+  created_at: string;
+  token?: string;
+}
+
+// 当前登录身份
+export interface AdminPrincipal {
+  user_id: number;
+  username: string;
+  role: AdminRole;
+  auth_method: "auth_key" | "session" | "token";
+  token_id?: number;
+}
+
+// 审计日志
+export type AuditTargetType = "group" | "key" | "settings" | "admin" | "proxy_key" | "task";
+
+export interface AuditChange {
+  before: unknown;
+  after: unknown;
+}
+
+export interface AuditLog {
+  id: number;
+  timestamp: string;
+  actor: string;
+  actor_role: string;
+  auth_method: string;
+  action: string;
+  target_type: AuditTargetType;
+  target_id: string;
+  target_name: string;
+  source_ip: string;
+  changes: Record<string, AuditChange>;
+}
+
+export interface AuditLogsResponse {
+  items: AuditLog[];
+  pagination: Pagination;
+}
+
+// 鉴权失败后的临时封禁
+export type AuthBanScope = "login" | "proxy";
+export type AuthBanType = "ip" | "key_prefix" | "username";
+
+export interface AuthBan {
+  subject: string; // 解除封禁时使用
+  scope: AuthBanScope;
+  type: AuthBanType;
+  value: string; // IP、用户名或脱敏后的密钥前缀
+  failures: number;
+  banned_at: string;
+  expires_at: string;
+}
+
+export interface AuthGuardStatus {
+  stats: {
+    failed_attempts: number;
+    bans: number;
+    blocked_requests: number;
+    active_bans: number;
+  };
+  bans: AuthBan[];
+}
+
+export interface AuditLogFilter {
+  page?: number;
+  page_size?: number;
+  actor?: string;
+  action?: string;
+  target_type?: AuditTargetType | "";
+  target_id?: string;
+  target_name?: string;
+  source_ip?: string;
+  start_time?: string;
+  end_time?: string;
+}
+
+// 声明式配置导入的变更
+export type ConfigChangeAction = "create" | "update" | "delete";
+export type ConfigChangeKind = "setting" | "group" | "sub_group" | "keys";
+
+export interface ConfigChange {
+  action: ConfigChangeAction;
+  kind: ConfigChangeKind;
+  target: string; // 设置项名称、分组名称或 聚合分组/子分组
+  before?: unknown;
+  after?: unknown;
+  count?: number; // 密钥变更的数量
+}
+
+export interface ConfigPlan {
+  dry_run: boolean;
+  prune: boolean;
+  changes: ConfigChange[];
+}